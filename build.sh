@@ -26,7 +26,7 @@ expandpath() {
     )
 }
 
-### Ensure >= go1.12 is installed.
+### Ensure >= go1.16 is installed.
 go_ver_str="$(go version | cut -d ' ' -f 3)"
 go_ver="${go_ver_str#go}"
 
@@ -49,9 +49,9 @@ then
     go_min=0
 fi
 
-if [ ! "$go_maj" -gt 1 ] && [ ! "$go_min" -ge 12 ]
+if [ ! "$go_maj" -gt 1 ] && [ ! "$go_min" -ge 16 ]
 then
-    printf "* Error: go 1.12 or later is required (detected version: %s)\n" \
+    printf "* Error: go 1.16 or later is required (detected version: %s)\n" \
         "$go_maj"."$go_min".X
     exit 1
 fi
@@ -1,14 +1,14 @@
 module mynewt.apache.org/newt
 
-go 1.13
+go 1.16
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/apache/mynewt-artifact v0.0.25-0.20230515081815-85acad353839
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/kardianos/osext v0.0.0-20190222173326-2bc1f35cddc0
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
 	github.com/otiai10/copy v1.9.0
-	github.com/pkg/errors v0.9.1 // indirect
 	github.com/shirou/gopsutil v2.21.11+incompatible
 	github.com/sirupsen/logrus v1.9.0
 	github.com/spf13/cast v1.5.0
@@ -0,0 +1,357 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// analyze - Runs static analyzers over the source files of a target build.
+
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/util"
+)
+
+const TOOL_CLANG_TIDY = "clang-tidy"
+const TOOL_CPPCHECK = "cppcheck"
+
+var Tools = []string{TOOL_CLANG_TIDY, TOOL_CPPCHECK}
+
+const FORMAT_TEXT = "text"
+const FORMAT_JSON = "json"
+const FORMAT_SARIF = "sarif"
+
+var Formats = []string{FORMAT_TEXT, FORMAT_JSON, FORMAT_SARIF}
+
+// A single diagnostic reported by an analyzer.
+type Finding struct {
+	Pkg      string `json:"pkg"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+}
+
+// Both tools are configured to emit diagnostics in the following form:
+//
+//	<file>:<line>:<col>: <severity>: <message> [<check>]
+//
+// Some diagnostics (e.g., clang errors) are not associated with a check, so
+// the check is optional.
+var findingRe = regexp.MustCompile(
+	`^(.+?):(\d+):(\d+): (warning|error|note|style|performance|` +
+		`portability|information): (.*?)(?: \[([^\]]+)\])?$`)
+
+// Extracts the subset of a compile command that is meaningful to an analyzer:
+// include paths, preprocessor definitions, and the language standard.
+// Target-specific code generation flags are discarded because the analyzers
+// generally do not understand them.
+func analyzerFlags(cmd []string) []string {
+	var flags []string
+
+	for i := 0; i < len(cmd); i++ {
+		f := cmd[i]
+		switch {
+		case f == "-include" || f == "-isystem":
+			if i+1 < len(cmd) {
+				flags = append(flags, f, cmd[i+1])
+				i++
+			}
+		case strings.HasPrefix(f, "-I") || strings.HasPrefix(f, "-D") ||
+			strings.HasPrefix(f, "-U") || strings.HasPrefix(f, "-std="):
+
+			flags = append(flags, f)
+		}
+	}
+
+	return flags
+}
+
+func toolCmd(tool string, unit builder.SourceUnit) ([]string, error) {
+	flags := analyzerFlags(unit.Command)
+
+	switch tool {
+	case TOOL_CLANG_TIDY:
+		cmd := []string{tool, "--quiet", unit.File, "--"}
+		return append(cmd, flags...), nil
+
+	case TOOL_CPPCHECK:
+		cmd := []string{
+			tool,
+			"--quiet",
+			"--enable=warning,style,performance,portability",
+			"--template={file}:{line}:{column}: {severity}: {message} [{id}]",
+		}
+		cmd = append(cmd, flags...)
+		return append(cmd, unit.File), nil
+
+	default:
+		return nil, util.FmtNewtError(
+			"unsupported analysis tool \"%s\"; must be one of: %s",
+			tool, strings.Join(Tools, ", "))
+	}
+}
+
+// Reads the set of checks that a package suppresses.  Suppressions are
+// specified in a package's `pkg.yml` file:
+//
+//	pkg.analyze_suppress:
+//	    - clang-analyzer-deadcode.DeadStores
+//	    - bugprone-*
+func suppressions(lpkg *pkg.LocalPackage) []string {
+	supp, err := lpkg.PkgY.GetValStringSlice("pkg.analyze_suppress", nil)
+	util.OneTimeWarningError(err)
+
+	return supp
+}
+
+func isSuppressed(check string, supp []string) bool {
+	for _, s := range supp {
+		if ok, _ := filepath.Match(s, check); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Maps source files to the packages that contain them.
+type pkgOwners struct {
+	// Sorted by decreasing path length, so that a nested package is found
+	// before the package that contains it.
+	lpkgs []*pkg.LocalPackage
+}
+
+func newPkgOwners() *pkgOwners {
+	o := &pkgOwners{}
+	for _, p := range project.GetProject().PackagesOfType(-1) {
+		o.lpkgs = append(o.lpkgs, p.(*pkg.LocalPackage))
+	}
+
+	sort.Slice(o.lpkgs, func(i int, j int) bool {
+		return len(o.lpkgs[i].BasePath()) > len(o.lpkgs[j].BasePath())
+	})
+
+	return o
+}
+
+// Finds the package that contains the specified file, or returns `dflt` if
+// the file is not in any package (e.g., a system header).
+func (o *pkgOwners) owner(file string,
+	dflt *pkg.LocalPackage) *pkg.LocalPackage {
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return dflt
+	}
+
+	for _, lpkg := range o.lpkgs {
+		if strings.HasPrefix(abs, filepath.Clean(lpkg.BasePath())+"/") {
+			return lpkg
+		}
+	}
+
+	return dflt
+}
+
+// Parses an analyzer's output.  Each finding is attributed to, and suppressed
+// according to, the package that contains the finding's file; a finding in a
+// header belongs to the header's package rather than to the package of the
+// source file being analyzed.
+func parseFindings(output string, unitPkg *pkg.LocalPackage,
+	owners *pkgOwners, projPath string) []Finding {
+
+	var findings []Finding
+	for _, line := range strings.Split(output, "\n") {
+		m := findingRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		lpkg := owners.owner(m[1], unitPkg)
+
+		lineNum, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		f := Finding{
+			Pkg:      lpkg.FullName(),
+			File:     strings.TrimPrefix(filepath.ToSlash(m[1]), projPath+"/"),
+			Line:     lineNum,
+			Column:   col,
+			Severity: m[4],
+			Message:  m[5],
+			Check:    m[6],
+		}
+
+		if isSuppressed(f.Check, suppressions(lpkg)) {
+			log.Debugf("suppressed %s finding in %s: %s",
+				f.Check, f.File, f.Message)
+			continue
+		}
+
+		findings = append(findings, f)
+	}
+
+	return findings
+}
+
+// Run executes the specified analyzer over each source unit and returns the
+// resulting set of findings.  Findings that are suppressed by the package
+// that owns the finding's file are omitted.
+func Run(tool string, units []builder.SourceUnit,
+	projPath string) ([]Finding, error) {
+
+	var findings []Finding
+	seen := map[Finding]struct{}{}
+	owners := newPkgOwners()
+
+	for _, unit := range units {
+		cmd, err := toolCmd(tool, unit)
+		if err != nil {
+			return nil, err
+		}
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "Analyzing %s\n",
+			strings.TrimPrefix(unit.File, projPath+"/"))
+
+		// Analyzers exit with a nonzero status when they report errors.  Only
+		// treat the failure as fatal if the tool could not be executed at
+		// all.
+		o, err := util.ShellCommand(cmd, nil)
+		if err != nil && !util.IsExit(err) {
+			return nil, err
+		}
+
+		// Header files get analyzed once per including source file; only
+		// report each finding once.
+		for _, f := range parseFindings(string(o), unit.Lpkg, owners,
+			projPath) {
+
+			if _, ok := seen[f]; !ok {
+				seen[f] = struct{}{}
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i int, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+
+	return findings, nil
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case "error":
+		return "error"
+	case "note", "information":
+		return "note"
+	default:
+		return "warning"
+	}
+}
+
+func formatSarif(tool string, findings []Finding) ([]byte, error) {
+	results := make([]interface{}, 0, len(findings))
+	for _, f := range findings {
+		result := map[string]interface{}{
+			"level":   sarifLevel(f.Severity),
+			"message": map[string]interface{}{"text": f.Message},
+			"locations": []interface{}{
+				map[string]interface{}{
+					"physicalLocation": map[string]interface{}{
+						"artifactLocation": map[string]interface{}{
+							"uri": f.File,
+						},
+						"region": map[string]interface{}{
+							"startLine":   f.Line,
+							"startColumn": f.Column,
+						},
+					},
+				},
+			},
+		}
+		if f.Check != "" {
+			result["ruleId"] = f.Check
+		}
+		results = append(results, result)
+	}
+
+	doc := map[string]interface{}{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []interface{}{
+			map[string]interface{}{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{"name": tool},
+				},
+				"results": results,
+			},
+		},
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// Format renders a set of findings in the specified output format.
+func Format(format string, tool string, findings []Finding) ([]byte, error) {
+	switch format {
+	case FORMAT_TEXT:
+		s := ""
+		for _, f := range findings {
+			check := ""
+			if f.Check != "" {
+				check = " [" + f.Check + "]"
+			}
+			s += fmt.Sprintf("%s:%d:%d: %s: %s%s (%s)\n",
+				f.File, f.Line, f.Column, f.Severity, f.Message, check,
+				f.Pkg)
+		}
+		return []byte(s), nil
+
+	case FORMAT_JSON:
+		if findings == nil {
+			findings = []Finding{}
+		}
+		return json.MarshalIndent(findings, "", "  ")
+
+	case FORMAT_SARIF:
+		return formatSarif(tool, findings)
+
+	default:
+		return nil, util.FmtNewtError(
+			"unsupported output format \"%s\"; must be one of: %s",
+			format, strings.Join(Formats, ", "))
+	}
+}
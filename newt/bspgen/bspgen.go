@@ -0,0 +1,584 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// bspgen - Generates the skeleton of a new BSP package.
+
+package bspgen
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/config"
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/util"
+)
+
+const (
+	PROBE_JLINK   = "jlink"
+	PROBE_OPENOCD = "openocd"
+	PROBE_PYOCD   = "pyocd"
+)
+
+var Probes = []string{PROBE_JLINK, PROBE_OPENOCD, PROBE_PYOCD}
+
+// Sizes of the fixed flash areas.  The two image slots share the rest of
+// flash.
+const (
+	bootAreaSize      = 32 * 1024
+	scratchAreaSize   = 16 * 1024
+	rebootLogAreaSize = 16 * 1024
+	nffsAreaSize      = 32 * 1024
+	minImageAreaSize  = 32 * 1024
+	areaAlign         = 4 * 1024
+)
+
+// Describes the BSP to generate.
+type Config struct {
+	// Package name, relative to the project's local repo (e.g.,
+	// "hw/bsp/myboard").
+	Name string
+
+	// The MCU package that the BSP depends on; nil if there is none.
+	Mcu *pkg.LocalPackage
+
+	Arch     string
+	Compiler string
+
+	FlashBase int
+	FlashSize int
+	RamBase   int
+	RamSize   int
+
+	// Debug probe (one of Probes) and the name the probe software uses for
+	// the MCU.
+	Probe  string
+	Device string
+
+	// MCU linker scripts that define the image's sections.  These are listed
+	// after the BSP's own script, which only defines the memory regions.
+	McuLinkerScripts []string
+}
+
+// Settings of an MCU, gathered from an existing BSP that uses it.
+type McuInfo struct {
+	Pkg           *pkg.LocalPackage
+	Bsp           *pkg.LocalPackage
+	Arch          string
+	Compiler      string
+	LinkerScripts []string
+}
+
+type flashArea struct {
+	name   string
+	userId int // -1 for system areas.
+	offset int
+	size   int
+}
+
+// Indicates whether package string `s`, written in a package belonging to
+// repo `repoName`, refers to the specified package.
+func refersTo(s string, repoName string, lpkg *pkg.LocalPackage) bool {
+	rname, pname, err := newtutil.ParsePackageString(s)
+	if err != nil {
+		return false
+	}
+	if rname == "" {
+		rname = repoName
+	}
+
+	return rname == lpkg.Repo().Name() && pname == lpkg.Name()
+}
+
+// Finds the MCU packages matching the specified string.  An exact package
+// name selects a single package; otherwise, every package under hw/mcu whose
+// name contains the string is returned.
+func FindMcus(proj *project.Project, s string) []*pkg.LocalPackage {
+	var mcus []*pkg.LocalPackage
+
+	lower := strings.ToLower(s)
+	for _, p := range proj.PackagesOfType(-1) {
+		lpkg := p.(*pkg.LocalPackage)
+		if lpkg.FullName() == s || lpkg.Name() == s {
+			return []*pkg.LocalPackage{lpkg}
+		}
+
+		if strings.HasPrefix(lpkg.Name(), "hw/mcu/") &&
+			strings.Contains(strings.ToLower(lpkg.Name()), lower) {
+
+			mcus = append(mcus, lpkg)
+		}
+	}
+
+	sort.Slice(mcus, func(i int, j int) bool {
+		return mcus[i].FullName() < mcus[j].FullName()
+	})
+
+	return mcus
+}
+
+// Gathers the architecture, compiler, and linker scripts of an MCU from the
+// first BSP (in name order) that depends on it.  If no BSP uses the MCU, the
+// linker scripts in the MCU package's directory are reported.
+func InspectMcu(proj *project.Project, mcu *pkg.LocalPackage) McuInfo {
+	info := McuInfo{
+		Pkg: mcu,
+	}
+
+	var bsps []*pkg.LocalPackage
+	for _, p := range proj.PackagesOfType(pkg.PACKAGE_TYPE_BSP) {
+		bsps = append(bsps, p.(*pkg.LocalPackage))
+	}
+	sort.Slice(bsps, func(i int, j int) bool {
+		return bsps[i].FullName() < bsps[j].FullName()
+	})
+
+	for _, bsp := range bsps {
+		deps, _ := bsp.PkgY.GetValStringSlice("pkg.deps", nil)
+
+		found := false
+		for _, d := range deps {
+			if refersTo(d, bsp.Repo().Name(), mcu) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+
+		yc, err := config.ReadFile(bsp.BasePath() + "/" +
+			pkg.BSP_YAML_FILENAME)
+		if err != nil {
+			continue
+		}
+
+		info.Bsp = bsp
+		info.Arch, _ = yc.GetValString("bsp.arch", nil)
+		info.Compiler, _ = yc.GetValString("bsp.compiler", nil)
+
+		scripts, _ := yc.GetValStringSlice("bsp.linkerscript", nil)
+		for _, s := range scripts {
+			rname, pname, err := newtutil.ParsePackageString(s)
+			if err != nil {
+				continue
+			}
+			if rname == "" {
+				rname = bsp.Repo().Name()
+			}
+			if rname == mcu.Repo().Name() &&
+				strings.HasPrefix(pname, mcu.Name()+"/") {
+
+				info.LinkerScripts = append(info.LinkerScripts,
+					"@"+rname+"/"+pname)
+			}
+		}
+
+		return info
+	}
+
+	matches, _ := filepath.Glob(mcu.BasePath() + "/*.ld")
+	for _, m := range matches {
+		info.LinkerScripts = append(info.LinkerScripts,
+			mcu.FullName()+"/"+filepath.Base(m))
+	}
+
+	return info
+}
+
+// Divides flash into the standard set of areas: bootloader, two image slots,
+// scratch, reboot log, and file system.
+func flashLayout(base int, size int) ([]flashArea, error) {
+	fixed := bootAreaSize + scratchAreaSize + rebootLogAreaSize + nffsAreaSize
+	imgSize := (size - fixed) / 2 / areaAlign * areaAlign
+	if imgSize < minImageAreaSize {
+		return nil, util.FmtNewtError(
+			"flash too small (%d bytes); must be at least %d bytes",
+			size, fixed+2*minImageAreaSize)
+	}
+
+	areas := []flashArea{
+		{"FLASH_AREA_BOOTLOADER", -1, 0, bootAreaSize},
+		{"FLASH_AREA_IMAGE_0", -1, 0, imgSize},
+		{"FLASH_AREA_IMAGE_1", -1, 0, imgSize},
+		{"FLASH_AREA_IMAGE_SCRATCH", -1, 0, scratchAreaSize},
+		{"FLASH_AREA_REBOOT_LOG", 0, 0, rebootLogAreaSize},
+		{"FLASH_AREA_NFFS", 1, 0, nffsAreaSize},
+	}
+
+	off := base
+	for i, _ := range areas {
+		areas[i].offset = off
+		off += areas[i].size
+	}
+
+	return areas, nil
+}
+
+func findArea(areas []flashArea, name string) flashArea {
+	for _, a := range areas {
+		if a.name == name {
+			return a
+		}
+	}
+
+	panic("missing flash area " + name)
+}
+
+func boardName(cfg Config) string {
+	return path.Base(cfg.Name)
+}
+
+func pkgYml(cfg Config) string {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "pkg.name: %s\n", cfg.Name)
+	fmt.Fprintf(buf, "pkg.type: bsp\n")
+	fmt.Fprintf(buf, "pkg.description: BSP definition for %s.\n",
+		boardName(cfg))
+	fmt.Fprintf(buf, "pkg.keywords:\n")
+	fmt.Fprintf(buf, "    - %s\n", boardName(cfg))
+
+	if cfg.Mcu != nil {
+		fmt.Fprintf(buf, "\npkg.deps:\n")
+		fmt.Fprintf(buf, "    - \"%s\"\n", cfg.Mcu.FullName())
+	}
+
+	return buf.String()
+}
+
+func bspYml(cfg Config, areas []flashArea) string {
+	buf := &bytes.Buffer{}
+	board := boardName(cfg)
+
+	fmt.Fprintf(buf, "bsp.arch: %s\n", cfg.Arch)
+	fmt.Fprintf(buf, "bsp.compiler: \"%s\"\n", cfg.Compiler)
+
+	fmt.Fprintf(buf, "bsp.linkerscript:\n")
+	fmt.Fprintf(buf, "    - \"%s/%s.ld\"\n", cfg.Name, board)
+	for _, s := range cfg.McuLinkerScripts {
+		fmt.Fprintf(buf, "    - \"%s\"\n", s)
+	}
+	fmt.Fprintf(buf, "bsp.linkerscript.BOOT_LOADER.OVERWRITE:\n")
+	fmt.Fprintf(buf, "    - \"%s/boot-%s.ld\"\n", cfg.Name, board)
+	for _, s := range cfg.McuLinkerScripts {
+		fmt.Fprintf(buf, "    - \"%s\"\n", s)
+	}
+
+	fmt.Fprintf(buf, "bsp.downloadscript: \"%s/%s_download.sh\"\n",
+		cfg.Name, board)
+	fmt.Fprintf(buf, "bsp.debugscript: \"%s/%s_debug.sh\"\n",
+		cfg.Name, board)
+
+	fmt.Fprintf(buf, "\nbsp.flash_map:\n")
+	fmt.Fprintf(buf, "    areas:\n")
+	fmt.Fprintf(buf, "        # System areas.\n")
+	for _, a := range areas {
+		if a.userId == 0 {
+			fmt.Fprintf(buf, "\n        # User areas.\n")
+		}
+		fmt.Fprintf(buf, "        %s:\n", a.name)
+		if a.userId >= 0 {
+			fmt.Fprintf(buf, "            user_id: %d\n", a.userId)
+		}
+		fmt.Fprintf(buf, "            device: 0\n")
+		fmt.Fprintf(buf, "            offset: 0x%08x\n", a.offset)
+		fmt.Fprintf(buf, "            size: %dkB\n", a.size/1024)
+	}
+
+	return buf.String()
+}
+
+func syscfgYml(cfg Config) string {
+	buf := &bytes.Buffer{}
+	setting := "BSP_" + strings.ToUpper(
+		strings.Replace(boardName(cfg), "-", "_", -1))
+
+	fmt.Fprintf(buf, "syscfg.defs:\n")
+	fmt.Fprintf(buf, "    %s:\n", setting)
+	fmt.Fprintf(buf, "        description: 'Set to indicate that the BSP "+
+		"is %s.'\n", boardName(cfg))
+	fmt.Fprintf(buf, "        value: 1\n")
+
+	fmt.Fprintf(buf, "\nsyscfg.vals:\n")
+	fmt.Fprintf(buf, "    CONFIG_FCB_FLASH_AREA: FLASH_AREA_NFFS\n")
+	fmt.Fprintf(buf, "    REBOOT_LOG_FLASH_AREA: FLASH_AREA_REBOOT_LOG\n")
+	fmt.Fprintf(buf, "    NFFS_FLASH_AREA: FLASH_AREA_NFFS\n")
+	fmt.Fprintf(buf, "    COREDUMP_FLASH_AREA: FLASH_AREA_IMAGE_1\n")
+
+	return buf.String()
+}
+
+// Generates a linker script that defines the memory regions of an image
+// occupying the specified flash area.
+func linkerScript(cfg Config, area flashArea, hdrSize int) string {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "/* Memory regions for %s (%s). */\n",
+		boardName(cfg), area.name)
+	fmt.Fprintf(buf, "MEMORY\n")
+	fmt.Fprintf(buf, "{\n")
+	fmt.Fprintf(buf, "  FLASH (rx) : ORIGIN = 0x%08x, LENGTH = %dK\n",
+		area.offset, area.size/1024)
+	fmt.Fprintf(buf, "  RAM (rwx) : ORIGIN = 0x%08x, LENGTH = %dK\n",
+		cfg.RamBase, cfg.RamSize/1024)
+	fmt.Fprintf(buf, "}\n")
+	fmt.Fprintf(buf, "\n")
+	fmt.Fprintf(buf, "/* Size of the image header. */\n")
+	fmt.Fprintf(buf, "_imghdr_size = 0x%x;\n", hdrSize)
+
+	if len(cfg.McuLinkerScripts) == 0 {
+		fmt.Fprintf(buf, "\n")
+		fmt.Fprintf(buf, "/* TODO: No MCU linker script was found; define "+
+			"the image's SECTIONS here. */\n")
+	}
+
+	return buf.String()
+}
+
+const scriptHeader = `#!/bin/sh
+#
+# Licensed to the Apache Software Foundation (ASF) under one
+# or more contributor license agreements.  See the NOTICE file
+# distributed with this work for additional information
+# regarding copyright ownership.  The ASF licenses this file
+# to you under the Apache License, Version 2.0 (the
+# "License"); you may not use this file except in compliance
+# with the License.  You may obtain a copy of the License at
+#
+#  http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing,
+# software distributed under the License is distributed on an
+# "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+# KIND, either express or implied.  See the License for the
+# specific language governing permissions and limitations
+# under the License.
+#
+`
+
+// Returns the probe-specific variable assignment that identifies the MCU.
+func probeDeviceVar(cfg Config) string {
+	switch cfg.Probe {
+	case PROBE_OPENOCD:
+		return fmt.Sprintf("CFG=\"-f interface/cmsis-dap.cfg "+
+			"-f target/%s.cfg\"", cfg.Device)
+	case PROBE_PYOCD:
+		return fmt.Sprintf("TARGET=%s", cfg.Device)
+	default:
+		return fmt.Sprintf("JLINK_DEV=\"%s\"", cfg.Device)
+	}
+}
+
+func downloadScript(cfg Config) string {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "%s", scriptHeader)
+	fmt.Fprintf(buf, `
+# Called with following variables set:
+#  - CORE_PATH is absolute path to @apache-mynewt-core
+#  - BSP_PATH is absolute path to hw/bsp/bsp_name
+#  - BIN_BASENAME is the path to prefix to target binary,
+#    .elf appended to name is the ELF file
+#  - IMAGE_SLOT is the image slot to download to (for non-mfg-image, non-boot)
+#  - FEATURES holds the target features string
+#  - EXTRA_JTAG_CMD holds extra parameters to pass to jtag software
+#  - MFG_IMAGE is "1" if this is a manufacturing image
+#  - FLASH_OFFSET contains the flash offset to download to
+#  - BOOT_LOADER is set if downloading a bootloader
+
+. $CORE_PATH/hw/scripts/%s.sh
+
+if [ "$MFG_IMAGE" ]; then
+    FLASH_OFFSET=0x%x
+fi
+
+%s
+
+common_file_to_load
+%s_load
+`, cfg.Probe, cfg.FlashBase, probeDeviceVar(cfg), cfg.Probe)
+
+	return buf.String()
+}
+
+func debugScript(cfg Config) string {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "%s", scriptHeader)
+	fmt.Fprintf(buf, `
+# Called with following variables set:
+#  - CORE_PATH is absolute path to @apache-mynewt-core
+#  - BSP_PATH is absolute path to hw/bsp/bsp_name
+#  - BIN_BASENAME is the path to prefix to target binary,
+#    .elf appended to name is the ELF file
+#  - FEATURES holds the target features string
+#  - EXTRA_JTAG_CMD holds extra parameters to pass to jtag software
+#  - RESET set if target should be reset when attaching
+#  - NO_GDB set if we should not start gdb to debug
+
+. $CORE_PATH/hw/scripts/%s.sh
+
+FILE_NAME=$BIN_BASENAME.elf
+%s
+
+%s_debug
+`, cfg.Probe, probeDeviceVar(cfg), cfg.Probe)
+
+	return buf.String()
+}
+
+func bspHeader(cfg Config) string {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, `#ifndef H_BSP_H
+#define H_BSP_H
+
+#include <inttypes.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/* Define special stackos sections */
+#define sec_data_core   __attribute__((section(".data.core")))
+#define sec_bss_core    __attribute__((section(".bss.core")))
+#define sec_bss_nz_core __attribute__((section(".bss.core.nz")))
+
+/* More convenient section placement macros. */
+#define bssnz_t         sec_bss_nz_core
+
+extern uint8_t _ram_start;
+#define RAM_SIZE        0x%x
+
+/* TODO: Define the board's LEDs and buttons. */
+#define LED_BLINK_PIN   (-1)
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif  /* H_BSP_H */
+`, cfg.RamSize)
+
+	return buf.String()
+}
+
+const halBspSource = `#include <stddef.h>
+#include "hal/hal_bsp.h"
+#include "hal/hal_flash_int.h"
+#include "bsp/bsp.h"
+
+static const struct hal_bsp_mem_dump dump_cfg[] = {
+    [0] = {
+        .hbmd_start = &_ram_start,
+        .hbmd_size = RAM_SIZE
+    }
+};
+
+const struct hal_flash *
+hal_bsp_flash_dev(uint8_t id)
+{
+    /* TODO: Return the MCU's flash driver for device 0. */
+    return NULL;
+}
+
+const struct hal_bsp_mem_dump *
+hal_bsp_core_dump(int *area_cnt)
+{
+    *area_cnt = sizeof(dump_cfg) / sizeof(dump_cfg[0]);
+    return dump_cfg;
+}
+
+int
+hal_bsp_power_state(int state)
+{
+    return 0;
+}
+
+void
+hal_bsp_init(void)
+{
+    /* TODO: Initialize the board's peripherals. */
+}
+`
+
+// Writes a new BSP package to the project's local repo.
+func Write(proj *project.Project, cfg Config) error {
+	if cfg.Probe != PROBE_JLINK && cfg.Probe != PROBE_OPENOCD &&
+		cfg.Probe != PROBE_PYOCD {
+
+		return util.FmtNewtError("invalid debug probe \"%s\"; must be one "+
+			"of: %s", cfg.Probe, strings.Join(Probes, ", "))
+	}
+
+	areas, err := flashLayout(cfg.FlashBase, cfg.FlashSize)
+	if err != nil {
+		return err
+	}
+
+	dir := proj.Path() + "/" + path.Clean(cfg.Name)
+	if util.NodeExist(dir) {
+		return util.FmtNewtError("cannot create BSP in %s; path already "+
+			"exists", dir)
+	}
+
+	board := boardName(cfg)
+	files := []struct {
+		name     string
+		contents string
+		mode     os.FileMode
+	}{
+		{"pkg.yml", pkgYml(cfg), 0644},
+		{pkg.BSP_YAML_FILENAME, bspYml(cfg, areas), 0644},
+		{"syscfg.yml", syscfgYml(cfg), 0644},
+		{board + ".ld",
+			linkerScript(cfg, findArea(areas, "FLASH_AREA_IMAGE_0"), 0x20),
+			0644},
+		{"boot-" + board + ".ld",
+			linkerScript(cfg, findArea(areas, "FLASH_AREA_BOOTLOADER"), 0),
+			0644},
+		{board + "_download.sh", downloadScript(cfg), 0755},
+		{board + "_debug.sh", debugScript(cfg), 0755},
+		{"include/bsp/bsp.h", bspHeader(cfg), 0644},
+		{"src/hal_bsp.c", halBspSource, 0644},
+	}
+
+	for _, f := range files {
+		p := dir + "/" + f.name
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			return util.ChildNewtError(err)
+		}
+
+		util.StatusMessage(util.VERBOSITY_VERBOSE, "Writing %s\n", p)
+		if err := ioutil.WriteFile(p, []byte(f.contents), f.mode); err != nil {
+			return util.ChildNewtError(err)
+		}
+	}
+
+	return nil
+}
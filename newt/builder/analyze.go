@@ -0,0 +1,90 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/toolchain"
+)
+
+// A single C or C++ source file in a target build, along with the exact
+// command that compiles it.
+type SourceUnit struct {
+	Lpkg    *pkg.LocalPackage
+	File    string
+	Command []string
+}
+
+func (b *Builder) sourceUnits() ([]SourceUnit, error) {
+	var units []SourceUnit
+
+	for _, bpkg := range b.sortedBuildPackages() {
+		entries, err := b.collectCompileEntriesBpkg(bpkg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if e.CompilerType != toolchain.COMPILER_TYPE_C &&
+				e.CompilerType != toolchain.COMPILER_TYPE_CPP {
+
+				continue
+			}
+			if e.Compiler.ShouldIgnoreFile(e.Filename) {
+				continue
+			}
+
+			cmd, err := e.Compiler.CompileFileCmd(e.Filename, e.CompilerType)
+			if err != nil {
+				return nil, err
+			}
+
+			units = append(units, SourceUnit{
+				Lpkg:    bpkg.rpkg.Lpkg,
+				File:    e.Filename,
+				Command: cmd,
+			})
+		}
+	}
+
+	return units, nil
+}
+
+// SourceUnits returns every C and C++ source file that the target build
+// compiles, whether or not the file is out of date.  The target does not need
+// to have been built.
+func (t *TargetBuilder) SourceUnits() ([]SourceUnit, error) {
+	if err := t.PrepBuild(); err != nil {
+		return nil, err
+	}
+
+	var units []SourceUnit
+	for _, b := range []*Builder{t.LoaderBuilder, t.AppBuilder} {
+		if b != nil {
+			bu, err := b.sourceUnits()
+			if err != nil {
+				return nil, err
+			}
+			units = append(units, bu...)
+		}
+	}
+
+	return units, nil
+}
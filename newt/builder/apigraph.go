@@ -0,0 +1,147 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"mynewt.apache.org/newt/newt/resolve"
+	"mynewt.apache.org/newt/util"
+)
+
+func apiEndpointString(ep resolve.ApiEndpoint) string {
+	s := ep.Rpkg.Lpkg.FullName()
+	if ep.Expr != "" {
+		s += "(syscfg:" + ep.Expr + ")"
+	}
+
+	return s
+}
+
+func apiProviderName(topo resolve.ApiTopology) string {
+	if topo.Provider == nil {
+		return ""
+	}
+
+	return topo.Provider.Lpkg.FullName()
+}
+
+func ApiTopologyText(topos []resolve.ApiTopology) string {
+	buffer := bytes.NewBufferString("")
+
+	for _, topo := range topos {
+		provider := apiProviderName(topo)
+		if provider == "" {
+			provider = "<unsatisfied>"
+		}
+
+		fmt.Fprintf(buffer, "%s\n", topo.Name)
+		fmt.Fprintf(buffer, "    provider:   %s\n", provider)
+
+		fmt.Fprintf(buffer, "    candidates:")
+		for _, ep := range topo.Candidates {
+			fmt.Fprintf(buffer, " %s", apiEndpointString(ep))
+		}
+		fmt.Fprintf(buffer, "\n")
+
+		fmt.Fprintf(buffer, "    consumers: ")
+		for _, ep := range topo.Consumers {
+			fmt.Fprintf(buffer, " %s", apiEndpointString(ep))
+		}
+		fmt.Fprintf(buffer, "\n")
+	}
+
+	return buffer.String()
+}
+
+// ApiTopologyViz produces a DOT graph with a node per API.  Candidate
+// suppliers point to the APIs they can supply (solid if selected, dashed
+// otherwise); APIs point to the packages that require them.
+func ApiTopologyViz(topos []resolve.ApiTopology) string {
+	buffer := bytes.NewBufferString("")
+
+	fmt.Fprintf(buffer, "digraph apis {\n")
+	for _, topo := range topos {
+		fmt.Fprintf(buffer, "  \"api:%s\" [shape=box];\n", topo.Name)
+
+		provider := apiProviderName(topo)
+		for _, ep := range topo.Candidates {
+			style := "dashed"
+			if ep.Rpkg.Lpkg.FullName() == provider {
+				style = "solid"
+			}
+			fmt.Fprintf(buffer,
+				"  \"%s\" -> \"api:%s\" [label=\"%s\",style=%s];\n",
+				ep.Rpkg.Lpkg.FullName(), topo.Name, ep.Expr, style)
+		}
+
+		for _, ep := range topo.Consumers {
+			fmt.Fprintf(buffer, "  \"api:%s\" -> \"%s\" [label=\"%s\"];\n",
+				topo.Name, ep.Rpkg.Lpkg.FullName(), ep.Expr)
+		}
+	}
+	fmt.Fprintf(buffer, "}\n")
+
+	return buffer.String()
+}
+
+type apiEndpointJson struct {
+	Pkg  string `json:"package"`
+	Expr string `json:"syscfg,omitempty"`
+}
+
+type apiTopologyJson struct {
+	Name       string            `json:"name"`
+	Provider   string            `json:"provider,omitempty"`
+	Candidates []apiEndpointJson `json:"candidates"`
+	Consumers  []apiEndpointJson `json:"consumers"`
+}
+
+func ApiTopologyJson(topos []resolve.ApiTopology) (string, error) {
+	toJson := func(eps []resolve.ApiEndpoint) []apiEndpointJson {
+		js := make([]apiEndpointJson, len(eps))
+		for i, ep := range eps {
+			js[i] = apiEndpointJson{
+				Pkg:  ep.Rpkg.Lpkg.FullName(),
+				Expr: ep.Expr,
+			}
+		}
+		return js
+	}
+
+	jtopos := make([]apiTopologyJson, len(topos))
+	for i, topo := range topos {
+		jtopos[i] = apiTopologyJson{
+			Name:       topo.Name,
+			Provider:   apiProviderName(topo),
+			Candidates: toJson(topo.Candidates),
+			Consumers:  toJson(topo.Consumers),
+		}
+	}
+
+	data, err := json.MarshalIndent(jtopos, "", "    ")
+	if err != nil {
+		return "", util.ChildNewtError(err)
+	}
+
+	return string(data) + "\n", nil
+}
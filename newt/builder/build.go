@@ -20,16 +20,15 @@
 package builder
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sync/atomic"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
-	"mynewt.apache.org/newt/newt/interfaces"
 	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/pkg"
 	"mynewt.apache.org/newt/newt/repo"
@@ -58,6 +57,17 @@ type Builder struct {
 	linkElf          string
 	injectedSettings map[string]string
 	modifiedExtRepos []string
+	warnings         []string
+	timing           buildTiming
+
+	// Compilation database entries for every source file the builder
+	// compiles, whether or not the last build recompiled it.
+	compileCmds []toolchain.CompileCommand
+
+	// Builder that compiles the packages this builder shares with it.  The
+	// shared packages are linked from the other builder's output rather than
+	// compiled a second time.
+	sharedBuilder *Builder
 }
 
 func NewBuilder(
@@ -150,6 +160,13 @@ func (b *Builder) addPackage(rpkg *resolve.ResolvePackage) (
 	return bpkg, nil
 }
 
+// isShared indicates whether the specified package is compiled by this
+// builder's shared builder.
+func (b *Builder) isShared(bpkg *BuildPackage) bool {
+	return b.sharedBuilder != nil &&
+		b.sharedBuilder.PkgMap[bpkg.rpkg] != nil
+}
+
 func (b *Builder) GetAutogeneratedLinkerIncludeDir() (string, error) {
 	return b.targetBuilder.BspPkg().GetAutogeneratedLinkerIncludePath()
 }
@@ -301,6 +318,22 @@ func (b *Builder) newCompiler(bpkg *BuildPackage,
 
 	c.AddInfo(b.compilerInfo)
 
+	incDir := GeneratedIncludeDir(b.targetPkg.rpkg.Lpkg.FullName())
+	c.SetSyscfgSymbols(toolchain.SyscfgSymbols{
+		Header: incDir + "/" + syscfg.HEADER_PATH,
+		Dir:    incDir + "/" + syscfg.SYMBOL_DIR,
+		Common: syscfg.SYMBOL_COMMON,
+		Stamp:  syscfg.SYMBOL_STAMP,
+	})
+
+	if b.targetBuilder.stackUsage {
+		c.AddInfo(&toolchain.CompilerInfo{Cflags: []string{"-fstack-usage"}})
+	}
+
+	if ci := b.instrumentInfo(bpkg); ci != nil {
+		c.AddInfo(ci)
+	}
+
 	if bpkg != nil {
 		log.Debugf("Generating build flags for package %s",
 			bpkg.rpkg.Lpkg.FullName())
@@ -314,18 +347,10 @@ func (b *Builder) newCompiler(bpkg *BuildPackage,
 	return c, nil
 }
 
-func (b *Builder) collectCompileEntriesBpkg(bpkg *BuildPackage) (
-	[]toolchain.CompilerJob, error) {
-
-	c, err := b.newCompiler(bpkg, b.PkgBinDir(bpkg))
-	if err != nil {
-		return nil, err
-	}
-
-	var privateIncCi toolchain.CompilerInfo
-	privateIncCi.Includes = bpkg.privateIncludeDirs(b)
-	c.AddInfo(&privateIncCi)
-
+// Returns the directories containing the package's source files.  If the
+// package specifies neither source directories nor source files, this is its
+// "src" directory, if it has one.
+func (bpkg *BuildPackage) srcDirs() ([]string, error) {
 	srcDirs := []string{}
 
 	if len(bpkg.SourceDirectories) > 0 {
@@ -361,6 +386,29 @@ func (b *Builder) collectCompileEntriesBpkg(bpkg *BuildPackage) (
 		srcDirs = append(srcDirs, srcDir)
 	}
 
+	return srcDirs, nil
+}
+
+func (b *Builder) collectCompileEntriesBpkg(bpkg *BuildPackage) (
+	[]toolchain.CompilerJob, error) {
+
+	c, err := b.newCompiler(bpkg, b.PkgBinDir(bpkg))
+	if err != nil {
+		return nil, err
+	}
+
+	var privateIncCi toolchain.CompilerInfo
+	privateIncCi.Includes = bpkg.privateIncludeDirs(b)
+	c.AddInfo(&privateIncCi)
+
+	// Glob ignore rules match paths relative to the package directory.
+	c.SetPkgDir(bpkg.rpkg.Lpkg.BasePath())
+
+	srcDirs, err := bpkg.srcDirs()
+	if err != nil {
+		return nil, err
+	}
+
 	entries := []toolchain.CompilerJob{}
 	for _, dir := range srcDirs {
 		subEntries, err := collectCompileEntriesDir(dir, c,
@@ -397,9 +445,42 @@ func (b *Builder) collectCompileEntriesBpkg(bpkg *BuildPackage) (
 		}
 	}
 
+	if err := checkObjCollisions(bpkg, entries); err != nil {
+		return nil, err
+	}
+
+	warnUnmatchedIgnoreRules(bpkg, entries)
+
 	return entries, nil
 }
 
+// Verifies that no two of a package's source files compile to the same
+// object file (e.g., "foo.c" and "foo.S").  Otherwise, one object would
+// silently overwrite the other in the package's archive.
+func checkObjCollisions(bpkg *BuildPackage,
+	entries []toolchain.CompilerJob) error {
+
+	// [object-path] => source-path
+	objSrcMap := map[string]string{}
+
+	for _, e := range entries {
+		if e.CompilerType == toolchain.COMPILER_TYPE_ARCHIVE {
+			continue
+		}
+
+		objPath := e.Compiler.ObjPath(e.Filename)
+		if prev, ok := objSrcMap[objPath]; ok && prev != e.Filename {
+			return util.FmtNewtError(
+				"package %s: source files \"%s\" and \"%s\" both "+
+					"compile to the same object file (%s); rename one of them",
+				bpkg.rpkg.Lpkg.FullName(), prev, e.Filename, objPath)
+		}
+		objSrcMap[objPath] = e.Filename
+	}
+
+	return nil
+}
+
 func (b *Builder) CollectCompileEntriesBpkg(bpkg *BuildPackage) (
 	[]toolchain.CompilerJob, error) {
 	return b.collectCompileEntriesBpkg(bpkg)
@@ -446,15 +527,16 @@ func (b *Builder) ExtractSymbolInfo() (error, *symbol.SymbolMap) {
 func (b *Builder) link(elfName string, linkerScripts []string,
 	keepSymbols []string, extraADirs []string) error {
 
+	start := time.Now()
+	defer func() {
+		b.timing.link += time.Since(start)
+	}()
+
 	c, err := b.newCompiler(b.appPkg, b.FileBinDir(elfName))
 	if err != nil {
 		return err
 	}
 
-	// Calculate the list of directories containing source .a files.
-	var dirs []string
-	staticLibs := []util.StaticLib{}
-
 	for _, bpkg := range b.sortedBuildPackages() {
 
 		// Collect lflags from all constituent packages.  Discard everything
@@ -466,24 +548,25 @@ func (b *Builder) link(elfName string, linkerScripts []string,
 		}
 
 		c.AddInfo(&toolchain.CompilerInfo{Lflags: ci.Lflags})
-		fullANames, _ := filepath.Glob(b.PkgBinDir(bpkg) + "/*.a")
-		for _, archiveName := range fullANames {
-			s := util.NewStaticLib(archiveName, ci.WholeArch)
-			staticLibs = append(staticLibs, s)
-		}
 	}
-	dirs = append(dirs, extraADirs...)
 
-	// Find all .a files in the input directories.
-	for _, dir := range extraADirs {
-		fullANames, _ := filepath.Glob(dir + "/*.a")
-		for _, archiveName := range fullANames {
-			s := util.NewStaticLib(archiveName, false)
-			staticLibs = append(staticLibs, s)
-		}
+	if ci := b.instrumentLinkInfo(); ci != nil {
+		c.AddInfo(ci)
+	}
+
+	inputs, err := b.linkInputs(extraADirs)
+	if err != nil {
+		return err
+	}
+
+	staticLibs := []util.StaticLib{}
+	for _, in := range inputs {
+		s := util.NewStaticLib(in.File, in.WholeArch)
+		staticLibs = append(staticLibs, s)
 	}
 
 	c.LinkerScripts = linkerScripts
+	c.GcReport = b.targetBuilder.gcReport
 	c.AutogeneratedLinkerIncludeDir, err = b.GetAutogeneratedLinkerIncludeDir()
 	if err != nil {
 		return err
@@ -579,6 +662,11 @@ func (b *Builder) PrepBuild() error {
 	// Let multiplatform libraries know that a Mynewt binary is being build.
 	baseCi.Cflags = append(baseCi.Cflags, "-DMYNEWT=1")
 
+	// Ad-hoc definitions specified on the command line.
+	for _, def := range b.targetBuilder.defines {
+		baseCi.Cflags = append(baseCi.Cflags, "-D"+def)
+	}
+
 	// Note: The compiler package's flags get added at the end, after the flags
 	// for library package being built are calculated.
 	b.compilerInfo = baseCi
@@ -617,45 +705,6 @@ func (b *Builder) addUserPreBuildBpkg() (*BuildPackage, error) {
 		UserPreBuildDir(b.targetPkg.rpkg.Lpkg.FullName()))
 }
 
-// Runs build jobs while any remain.  On failure, signals the other workers to
-// stop via the stop channel.  On error, the error object is signaled via the
-// results channel.  On successful completion, nil is signaled via the results
-// channel.
-func buildWorker(
-	id int,
-	jobs <-chan toolchain.CompilerJob,
-	stop chan struct{},
-	results chan error) {
-
-	// Execute each job until failure or until a stop is signalled.
-	for {
-		select {
-		case s := <-stop:
-			// Re-enqueue the stop signal for the other routines.
-			stop <- s
-
-			// Terminate this go routine.
-			results <- nil
-			return
-
-		case j := <-jobs:
-			if err := toolchain.RunJob(j); err != nil {
-				// Stop the other routines.
-				stop <- struct{}{}
-
-				// Report the error back to the master thread and terminate.
-				results <- err
-				return
-			}
-
-		default:
-			// Terminate this go routine.
-			results <- nil
-			return
-		}
-	}
-}
-
 func (b *Builder) appendAppCflags(bpkgs []*BuildPackage) error {
 	for _, bpkg := range bpkgs {
 		settings := b.cfg.AllSettingsForLpkg(bpkg.rpkg.Lpkg)
@@ -677,104 +726,178 @@ func (b *Builder) appendAppCflags(bpkgs []*BuildPackage) error {
 	return nil
 }
 
-func (b *Builder) Build() error {
-	var err error
+// The jobs that compile the source files of one package.
+type pkgJobs struct {
+	bpkg *BuildPackage
+	jobs []toolchain.CompilerJob
+}
 
-	b.CleanArtifacts()
+// The compile jobs of one builder.
+type builderJobs struct {
+	b    *Builder
+	pkgs []pkgJobs
+}
 
-	// Build the packages alphabetically to ensure a consistent order.
-	bpkgs := b.sortedBuildPackages()
+// Returns the builder's packages in alphabetical order.  Packages shared with
+// another builder are omitted; they are compiled by it.
+func (b *Builder) ownBuildPackages() []*BuildPackage {
+	var bpkgs []*BuildPackage
+	for _, bpkg := range b.sortedBuildPackages() {
+		if !b.isShared(bpkg) {
+			bpkgs = append(bpkgs, bpkg)
+		}
+	}
 
-	err = b.appendAppCflags(bpkgs)
-	if err != nil {
-		return err
+	return bpkgs
+}
+
+// Collects the jobs that compile the builder's source files, without
+// compiling anything.  Each job represents a single file.
+func (b *Builder) compileJobs() ([]pkgJobs, error) {
+	if err := b.appendAppCflags(b.sortedBuildPackages()); err != nil {
+		return nil, err
 	}
 
-	// Calculate the list of jobs.  Each record represents a single file that
-	// needs to be compiled.
-	entries := []toolchain.CompilerJob{}
-	bpkgCompilerMap := map[*BuildPackage]*toolchain.Compiler{}
-	for _, bpkg := range bpkgs {
-		subEntries, err := b.collectCompileEntriesBpkg(bpkg)
+	// Build the packages alphabetically to ensure a consistent order.
+	var pjs []pkgJobs
+	for _, bpkg := range b.ownBuildPackages() {
+		jobs, err := b.collectCompileEntriesBpkg(bpkg)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		entries = append(entries, subEntries...)
+		pjs = append(pjs, pkgJobs{bpkg, jobs})
+	}
 
-		b.modifiedExtRepos = append(b.modifiedExtRepos, bpkg.getModifiedReposNames()...)
+	return pjs, nil
+}
 
-		if len(subEntries) > 0 {
-			bpkgCompilerMap[bpkg] = subEntries[0].Compiler
+// Executes the compile jobs of the specified builders.  The jobs of all
+// builders share one pool of workers, so the -j limit applies across all of
+// them.  The pool's compile time is recorded by the first builder.
+func runCompileJobs(bjs []builderJobs) error {
+	var entries []toolchain.CompilerJob
+	filePkgMap := map[string]string{}
+	for _, bj := range bjs {
+		for _, pj := range bj.pkgs {
+			entries = append(entries, pj.jobs...)
+			for _, j := range pj.jobs {
+				filePkgMap[j.Filename] = pj.bpkg.rpkg.Lpkg.FullName()
+			}
 		}
 	}
 
-	// Build each file in parallel.
-	jobs := make(chan toolchain.CompilerJob, len(entries))
-	defer close(jobs)
-
-	stop := make(chan struct{}, newtutil.NewtNumJobs)
-	defer close(stop)
+	// Report each compiled file as a progress event.
+	var numDone int32
+	jobDone := func(j toolchain.CompilerJob) {
+		n := atomic.AddInt32(&numDone, 1)
+		util.Progress(util.ProgressEvent{
+			Op:      "build",
+			Phase:   "compile",
+			Package: filePkgMap[j.Filename],
+			File:    j.Filename,
+			Percent: int(n) * 100 / len(entries),
+		})
+	}
 
-	errors := make(chan error, newtutil.NewtNumJobs)
-	defer close(errors)
+	compileStart := time.Now()
+	if err := toolchain.RunJobs(entries, newtutil.NewtNumJobs,
+		jobDone); err != nil {
 
-	for _, entry := range entries {
-		jobs <- entry
+		return err
 	}
-
-	for i := 0; i < newtutil.NewtNumJobs; i++ {
-		go buildWorker(i, jobs, stop, errors)
+	if len(bjs) > 0 {
+		bjs[0].b.timing.compile = time.Since(compileStart)
 	}
 
-	for i := 0; i < newtutil.NewtNumJobs; i++ {
-		subErr := <-errors
-		if err == nil && subErr != nil {
-			err = subErr
+	return nil
+}
+
+// Archives the builder's compiled packages and collects the warnings and
+// compilation database entries of its source files.  This is done once the
+// jobs returned by compileJobs() have been executed.
+func (b *Builder) finishCompile(pjs []pkgJobs) error {
+	var entries []toolchain.CompilerJob
+	bpkgCompilerMap := map[*BuildPackage]*toolchain.Compiler{}
+	for _, pj := range pjs {
+		entries = append(entries, pj.jobs...)
+
+		b.modifiedExtRepos = append(b.modifiedExtRepos,
+			pj.bpkg.getModifiedReposNames()...)
+
+		if len(pj.jobs) > 0 {
+			bpkgCompilerMap[pj.bpkg] = pj.jobs[0].Compiler
 		}
 	}
-	if err != nil {
-		return err
-	}
 
-	for _, bpkg := range bpkgs {
+	numCompiled := 0
+	for _, pj := range pjs {
+		bpkg := pj.bpkg
 		c := bpkgCompilerMap[bpkg]
 		if c != nil {
+			cacheResults := c.CacheResults()
+			for file, d := range c.CompileTimes() {
+				hit, lookedUp := cacheResults[file]
+				b.recordCompileTime(bpkg.rpkg.Lpkg.FullName(), file, d, hit)
+				if lookedUp {
+					if hit {
+						b.timing.cacheHits++
+					} else {
+						b.timing.cacheMisses++
+					}
+				}
+				numCompiled++
+			}
+
+			archiveStart := time.Now()
 			if err := b.createArchive(c, bpkg); err != nil {
 				return err
 			}
+			b.recordArchiveTime(bpkg.rpkg.Lpkg.FullName(),
+				time.Since(archiveStart))
 		}
 	}
+	b.timing.upToDate = len(entries) - numCompiled
 
-	var compileCommands []toolchain.CompileCommand
-
-	for _, bpkg := range bpkgs {
-		c := bpkgCompilerMap[bpkg]
+	b.warnings = nil
+	for _, pj := range pjs {
+		c := bpkgCompilerMap[pj.bpkg]
 		if c != nil {
-			compileCommands = append(compileCommands,
-				c.GetCompileCommands()...)
+			w, err := c.Warnings()
+			if err != nil {
+				return err
+			}
+			b.warnings = append(b.warnings, w...)
 		}
 	}
 
-	projectPath := interfaces.GetProject().Path() + "/"
-	for i := range compileCommands {
-		compileCommands[i].Directory = projectPath
+	var err error
+	b.compileCmds, err = compileCommandsFor(entries)
+	if err != nil {
+		return err
+	}
+
+	if err := writeCompileCommands(b.CompileCmdsPath(),
+		b.compileCmds); err != nil {
+
+		return err
 	}
 
-	cmdBytes, err := json.MarshalIndent(compileCommands, "", "    ")
+	return nil
+}
+
+func (b *Builder) Build() error {
+	b.CleanArtifacts()
+
+	pjs, err := b.compileJobs()
 	if err != nil {
-		log.Error("Unable to encode compilation commands as JSON")
-		return nil
+		return err
 	}
 
-	cmdPath := b.CompileCmdsPath()
-	errWrite := ioutil.WriteFile(cmdPath, cmdBytes, 0644)
-	if errWrite != nil {
-		return util.FmtNewtError(
-			"Unable to write compile_commands.json file; reason: %s",
-			errWrite.Error())
+	if err := runCompileJobs([]builderJobs{{b, pjs}}); err != nil {
+		return err
 	}
 
-	return nil
+	return b.finishCompile(pjs)
 }
 
 func (b *Builder) Link(linkerScripts []string, extraADirs []string) error {
@@ -898,6 +1021,15 @@ func (b *Builder) buildRomElf(common *symbol.SymbolMap) error {
 	common.Add(*symbol.NewElfSymbol("__vector_tbl_reloc__"))
 	common.Add(*symbol.NewElfSymbol("__isr_vector"))
 
+	/* symbols the target forces into the linker elf */
+	tgt := b.GetTarget()
+	for _, name := range tgt.KeepSymbols {
+		common.Add(*symbol.NewElfSymbol(name))
+	}
+	for _, name := range tgt.RenameSymbols {
+		common.Add(*symbol.NewElfSymbol(name))
+	}
+
 	err = b.CopySymbols(common)
 	if err != nil {
 		return err
@@ -914,6 +1046,9 @@ func (b *Builder) buildRomElf(common *symbol.SymbolMap) error {
 	tmp_sm.Add(*symbol.NewElfSymbol("__etext"))
 	tmp_sm.Add(*symbol.NewElfSymbol("__data_start__"))
 	tmp_sm.Add(*symbol.NewElfSymbol("__data_end__"))
+	for _, name := range tgt.RenameSymbols {
+		tmp_sm.Add(*symbol.NewElfSymbol(name))
+	}
 	err = c.RenameSymbols(tmp_sm, b.AppLinkerElfPath(), "_loader")
 
 	if err != nil {
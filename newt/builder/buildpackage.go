@@ -25,8 +25,10 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
+	"mynewt.apache.org/newt/newt/cfgv"
 	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/pkg"
 	"mynewt.apache.org/newt/newt/resolve"
@@ -91,6 +93,11 @@ func (bpkg *BuildPackage) collectDeps(b *Builder) ([]*BuildPackage, error) {
 		arr = append(arr, p)
 	}
 
+	// Sort the packages so that include paths are always in the same order.
+	sort.Slice(arr, func(i int, j int) bool {
+		return arr[i].rpkg.Lpkg.FullName() < arr[j].rpkg.Lpkg.FullName()
+	})
+
 	return arr, nil
 }
 
@@ -122,6 +129,52 @@ func expandFlags(flags []string) {
 	}
 }
 
+// Matches a setting reference, "$(NAME)", in a source or include path.
+var pathSettingRefRe = regexp.MustCompile(`\$\(([A-Za-z0-9_]+)\)`)
+
+// Replaces each "$(NAME)" in a path with the value of the NAME syscfg setting,
+// e.g., "ports/$(ARCH_NAME)" becomes "ports/cortex_m4".  The quotes around a
+// string setting's value are removed.  It is an error to refer to a setting
+// that does not exist.
+func expandPathSettings(path string, settings *cfgv.Settings) (string, error) {
+	var err error
+
+	expanded := pathSettingRefRe.ReplaceAllStringFunc(path,
+		func(m string) string {
+			name := pathSettingRefRe.FindStringSubmatch(m)[1]
+
+			val, ok := settings.GetOk(name)
+			if !ok {
+				if err == nil {
+					err = util.FmtNewtError(
+						"path \"%s\" refers to undefined setting %s",
+						path, name)
+				}
+				return ""
+			}
+
+			return strings.Trim(val, "\"")
+		})
+
+	return expanded, err
+}
+
+// Expands the setting references in each of a package's paths.
+func (bpkg *BuildPackage) expandPaths(key string, paths []string,
+	settings *cfgv.Settings) ([]string, error) {
+
+	for i, path := range paths {
+		expanded, err := expandPathSettings(path, settings)
+		if err != nil {
+			return nil, util.FmtNewtError("%s: %s: %s",
+				bpkg.rpkg.Lpkg.FullName(), key, err.Error())
+		}
+		paths[i] = expanded
+	}
+
+	return paths, nil
+}
+
 // Retrieves the build package's build profile override, as specified in its
 // `pkg.yml` file.  If the package does not override the build profile, "" is
 // returned.
@@ -182,7 +235,7 @@ func (bpkg *BuildPackage) CompilerInfo(
 		ci.Cflags = append(ci.Cflags, syscfg.FeatureToCflag(k))
 	}
 
-	ci.IgnoreFiles = []*regexp.Regexp{}
+	ci.IgnoreFiles = []*toolchain.IgnoreRule{}
 
 	ignPats, err := bpkg.rpkg.Lpkg.PkgY.GetValStringSlice(
 		"pkg.ignore_files", settings)
@@ -195,15 +248,15 @@ func (bpkg *BuildPackage) CompilerInfo(
 	}
 
 	for _, str := range ignPats {
-		re, err := regexp.Compile(str)
+		rule, err := toolchain.NewIgnoreRule(str)
 		if err != nil {
 			return nil, util.NewNewtError(
-				"Ignore files, unable to compile re: " + err.Error())
+				"Ignore files, unable to compile rule: " + err.Error())
 		}
-		ci.IgnoreFiles = append(ci.IgnoreFiles, re)
+		ci.IgnoreFiles = append(ci.IgnoreFiles, rule)
 	}
 
-	ci.IgnoreDirs = []*regexp.Regexp{}
+	ci.IgnoreDirs = []*toolchain.IgnoreRule{}
 
 	ignPats, err = bpkg.rpkg.Lpkg.PkgY.GetValStringSlice(
 		"pkg.ignore_dirs", settings)
@@ -216,28 +269,42 @@ func (bpkg *BuildPackage) CompilerInfo(
 	}
 
 	for _, str := range ignPats {
-		re, err := regexp.Compile(str)
+		rule, err := toolchain.NewIgnoreRule(str)
 		if err != nil {
 			return nil, util.NewNewtError(
-				"Ignore dirs, unable to compile re: " + err.Error())
+				"Ignore dirs, unable to compile rule: " + err.Error())
 		}
-		ci.IgnoreDirs = append(ci.IgnoreDirs, re)
+		ci.IgnoreDirs = append(ci.IgnoreDirs, rule)
 	}
 
+	srcDirsKey := "pkg.source_dirs"
 	bpkg.SourceDirectories, err = bpkg.rpkg.Lpkg.PkgY.GetValStringSlice(
-		"pkg.source_dirs", settings)
+		srcDirsKey, settings)
 	util.OneTimeWarningError(err)
 
 	if len(bpkg.SourceDirectories) == 0 {
+		srcDirsKey = "pkg.src_dirs"
 		bpkg.SourceDirectories, err = bpkg.rpkg.Lpkg.PkgY.GetValStringSlice(
-			"pkg.src_dirs", settings)
+			srcDirsKey, settings)
 		util.OneTimeWarningError(err)
 	}
 
+	bpkg.SourceDirectories, err = bpkg.expandPaths(srcDirsKey,
+		bpkg.SourceDirectories, settings)
+	if err != nil {
+		return nil, err
+	}
+
 	bpkg.SourceFiles, err = bpkg.rpkg.Lpkg.PkgY.GetValStringSlice(
 		"pkg.source_files", settings)
 	util.OneTimeWarningError(err)
 
+	bpkg.SourceFiles, err = bpkg.expandPaths("pkg.source_files",
+		bpkg.SourceFiles, settings)
+	if err != nil {
+		return nil, err
+	}
+
 	includePaths, err := bpkg.recursiveIncludePaths(b)
 	if err != nil {
 		return nil, err
@@ -305,6 +372,10 @@ func (bpkg *BuildPackage) publicIncludeDirs(b *Builder) []string {
 			"pkg.include_dirs", settings)
 		util.OneTimeWarningError(err)
 
+		inclDirs, err = bpkg.expandPaths("pkg.include_dirs", inclDirs,
+			settings)
+		util.OneTimeWarningError(err)
+
 		for _, dir := range inclDirs {
 			repo, path, err := newtutil.ParsePackageString(dir)
 
@@ -0,0 +1,265 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"mynewt.apache.org/newt/util"
+)
+
+const BUILD_PROFILE_FILENAME = "build_profile.json"
+
+// Timing information collected while a Builder builds.
+type buildTiming struct {
+	// Wall time of the compile phase (all packages, in parallel).
+	compile time.Duration
+
+	// Total time spent linking.
+	link time.Duration
+
+	// Time spent compiling each file, indexed by package name, then filename.
+	// This includes files restored from the object cache.
+	files map[string]map[string]time.Duration
+
+	// Files restored from the object cache, indexed by filename.
+	cached map[string]bool
+
+	// Number of object cache lookups that found and did not find the file.
+	cacheHits   int
+	cacheMisses int
+
+	// Time spent creating each package's archive.
+	archives map[string]time.Duration
+
+	// Number of source files that did not need to be recompiled.
+	upToDate int
+}
+
+type FileTime struct {
+	File    string  `json:"file"`
+	Package string  `json:"package"`
+	Build   string  `json:"build"`
+	Seconds float64 `json:"seconds"`
+	Cached  bool    `json:"cached,omitempty"`
+}
+
+type PkgTime struct {
+	Package        string  `json:"package"`
+	Build          string  `json:"build"`
+	FilesCompiled  int     `json:"files_compiled"`
+	FilesCached    int     `json:"files_cached"`
+	CompileSeconds float64 `json:"compile_seconds"`
+	ArchiveSeconds float64 `json:"archive_seconds"`
+}
+
+// A report of where the time of a target's build went.  Packages and files
+// are sorted by decreasing duration.  Compile durations are summed across
+// parallel jobs, so they can exceed the build's wall time.
+type BuildProfile struct {
+	Target         string     `json:"target"`
+	TotalSeconds   float64    `json:"total_seconds"`
+	CompileSeconds float64    `json:"compile_seconds"`
+	LinkSeconds    float64    `json:"link_seconds"`
+	FilesCompiled  int        `json:"files_compiled"`
+	FilesCached    int        `json:"files_cached"`
+	FilesUpToDate  int        `json:"files_up_to_date"`
+	CacheHits      int        `json:"cache_hits"`
+	CacheMisses    int        `json:"cache_misses"`
+	Packages       []PkgTime  `json:"packages"`
+	Files          []FileTime `json:"files"`
+}
+
+func BuildProfilePath(targetName string) string {
+	return TargetBinDir(targetName) + "/" + BUILD_PROFILE_FILENAME
+}
+
+func (b *Builder) recordCompileTime(pkgName string, file string,
+	d time.Duration, cached bool) {
+
+	if b.timing.files == nil {
+		b.timing.files = map[string]map[string]time.Duration{}
+	}
+	if b.timing.files[pkgName] == nil {
+		b.timing.files[pkgName] = map[string]time.Duration{}
+	}
+	b.timing.files[pkgName][file] = d
+
+	if cached {
+		if b.timing.cached == nil {
+			b.timing.cached = map[string]bool{}
+		}
+		b.timing.cached[file] = true
+	}
+}
+
+func (b *Builder) recordArchiveTime(pkgName string, d time.Duration) {
+	if b.timing.archives == nil {
+		b.timing.archives = map[string]time.Duration{}
+	}
+	b.timing.archives[pkgName] += d
+}
+
+// Adds a builder's timing information to a profile.
+func (p *BuildProfile) addBuilder(b *Builder) {
+	if b == nil {
+		return
+	}
+
+	p.CompileSeconds += b.timing.compile.Seconds()
+	p.LinkSeconds += b.timing.link.Seconds()
+	p.FilesUpToDate += b.timing.upToDate
+	p.CacheHits += b.timing.cacheHits
+	p.CacheMisses += b.timing.cacheMisses
+
+	pkgNames := map[string]struct{}{}
+	for name, _ := range b.timing.files {
+		pkgNames[name] = struct{}{}
+	}
+	for name, _ := range b.timing.archives {
+		pkgNames[name] = struct{}{}
+	}
+
+	for name, _ := range pkgNames {
+		pt := PkgTime{
+			Package:        name,
+			Build:          b.buildName,
+			ArchiveSeconds: b.timing.archives[name].Seconds(),
+		}
+
+		for file, d := range b.timing.files[name] {
+			cached := b.timing.cached[file]
+			if cached {
+				pt.FilesCached++
+			} else {
+				pt.FilesCompiled++
+			}
+
+			pt.CompileSeconds += d.Seconds()
+			p.Files = append(p.Files, FileTime{
+				File:    strings.TrimPrefix(file, ProjectRoot()+"/"),
+				Package: name,
+				Build:   b.buildName,
+				Seconds: d.Seconds(),
+				Cached:  cached,
+			})
+		}
+
+		p.FilesCompiled += pt.FilesCompiled
+		p.FilesCached += pt.FilesCached
+		p.Packages = append(p.Packages, pt)
+	}
+}
+
+// BuildProfile reports how long each part of the most recent build took.
+func (t *TargetBuilder) BuildProfile() BuildProfile {
+	p := BuildProfile{
+		Target:       t.target.FullName(),
+		TotalSeconds: t.buildTime.Seconds(),
+	}
+
+	p.addBuilder(t.AppBuilder)
+	p.addBuilder(t.LoaderBuilder)
+
+	sort.Slice(p.Packages, func(i int, j int) bool {
+		a := p.Packages[i]
+		b := p.Packages[j]
+		ta := a.CompileSeconds + a.ArchiveSeconds
+		tb := b.CompileSeconds + b.ArchiveSeconds
+		if ta != tb {
+			return ta > tb
+		}
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.Build < b.Build
+	})
+
+	sort.Slice(p.Files, func(i int, j int) bool {
+		a := p.Files[i]
+		b := p.Files[j]
+		if a.Seconds != b.Seconds {
+			return a.Seconds > b.Seconds
+		}
+		return a.File < b.File
+	})
+
+	return p
+}
+
+// Text produces a human readable report.  At most `maxFiles` files are
+// listed; 0 lists all of them.
+func (p *BuildProfile) Text(maxFiles int) string {
+	buffer := bytes.NewBufferString("")
+
+	fmt.Fprintf(buffer, "Build profile for %s:\n", p.Target)
+	fmt.Fprintf(buffer, "    Total:   %8.2fs\n", p.TotalSeconds)
+	fmt.Fprintf(buffer, "    Compile: %8.2fs (wall)\n", p.CompileSeconds)
+	fmt.Fprintf(buffer, "    Link:    %8.2fs\n", p.LinkSeconds)
+	fmt.Fprintf(buffer, "    Files:   %d compiled, %d restored from cache, "+
+		"%d up to date\n", p.FilesCompiled, p.FilesCached, p.FilesUpToDate)
+	fmt.Fprintf(buffer, "    Cache:   %d hit(s), %d miss(es)\n",
+		p.CacheHits, p.CacheMisses)
+
+	fmt.Fprintf(buffer, "\nPackages (compile + archive time):\n")
+	for _, pt := range p.Packages {
+		fmt.Fprintf(buffer, "    %8.2fs  %-6s %s (%d files)\n",
+			pt.CompileSeconds+pt.ArchiveSeconds, pt.Build, pt.Package,
+			pt.FilesCompiled+pt.FilesCached)
+	}
+
+	files := p.Files
+	if maxFiles > 0 && len(files) > maxFiles {
+		files = files[:maxFiles]
+	}
+	fmt.Fprintf(buffer, "\nSlowest files:\n")
+	for _, ft := range files {
+		suffix := ""
+		if ft.Cached {
+			suffix = " (cached)"
+		}
+		fmt.Fprintf(buffer, "    %8.2fs  %s%s\n", ft.Seconds, ft.File, suffix)
+	}
+	if len(files) < len(p.Files) {
+		fmt.Fprintf(buffer, "    ... %d more\n", len(p.Files)-len(files))
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// WriteJSON writes the profile to a JSON file.
+func (p *BuildProfile) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
@@ -85,7 +85,8 @@ func (b *Builder) sortedBuildPackages() []*BuildPackage {
 	}
 
 	for _, bpkg := range b.PkgMap {
-		if bpkg.rpkg.Lpkg.Type() == pkg.PACKAGE_TYPE_CONFIG || bpkg.rpkg.Lpkg.Type() == pkg.PACKAGE_TYPE_TRANSIENT {
+		if bpkg.rpkg.Lpkg.Type() == pkg.PACKAGE_TYPE_CONFIG || bpkg.rpkg.Lpkg.Type() == pkg.PACKAGE_TYPE_TRANSIENT ||
+			bpkg.rpkg.Lpkg.Type() == pkg.PACKAGE_TYPE_BUNDLE {
 			continue
 		}
 		sorter.bpkgs = append(sorter.bpkgs, bpkg)
@@ -169,13 +170,17 @@ func BasicEnvVars(binBase string, bspPkg *pkg.BspPackage) map[string]string {
 	newtPath, _ := osext.Executable()
 
 	m := map[string]string{
-		"CORE_PATH":           coreRepo.Path(),
 		"BSP_PATH":            bspPath,
 		"BIN_ROOT":            BinRoot(),
 		"MYNEWT_PROJECT_ROOT": ProjectRoot(),
 		"MYNEWT_NEWT_PATH":    newtPath,
 	}
 
+	// The core repo is absent from minimal projects (e.g., test fixtures).
+	if coreRepo != nil {
+		m["CORE_PATH"] = coreRepo.Path()
+	}
+
 	if binBase != "" {
 		m["BIN_BASENAME"] = binBase
 	}
@@ -338,5 +343,9 @@ func (b *Builder) EnvVars(imageSlot int) (map[string]string, error) {
 	sort.Strings(pkgNames)
 	env["MYNEWT_PACKAGES"] = strings.Join(pkgNames, ":")
 
+	for k, v := range b.targetBuilder.deviceEnv {
+		env[k] = v
+	}
+
 	return env, nil
 }
@@ -0,0 +1,162 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"mynewt.apache.org/newt/newt/toolchain"
+	"mynewt.apache.org/newt/util"
+)
+
+// Produces the compilation database entries for the specified compile jobs.
+func compileCommandsFor(
+	entries []toolchain.CompilerJob) ([]toolchain.CompileCommand, error) {
+
+	cmds := []toolchain.CompileCommand{}
+	for _, e := range entries {
+		if e.CompilerType == toolchain.COMPILER_TYPE_ARCHIVE {
+			continue
+		}
+
+		cc, err := e.Compiler.CompileCommand(e.Filename, e.CompilerType)
+		if err != nil {
+			return nil, err
+		}
+		if cc != nil {
+			cmds = append(cmds, *cc)
+		}
+	}
+
+	return cmds, nil
+}
+
+// Writes a compile_commands.json file.
+func writeCompileCommands(path string,
+	cmds []toolchain.CompileCommand) error {
+
+	if cmds == nil {
+		cmds = []toolchain.CompileCommand{}
+	}
+
+	data, err := json.MarshalIndent(cmds, "", "    ")
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return util.FmtNewtError(
+			"Unable to write compile_commands.json file; reason: %s",
+			err.Error())
+	}
+
+	return nil
+}
+
+// Collects the compilation database entries for every source file that the
+// builder compiles, without compiling anything.
+func (b *Builder) collectCompileCommands() error {
+	pjs, err := b.compileJobs()
+	if err != nil {
+		return err
+	}
+
+	var entries []toolchain.CompilerJob
+	for _, pj := range pjs {
+		entries = append(entries, pj.jobs...)
+	}
+
+	cmds, err := compileCommandsFor(entries)
+	if err != nil {
+		return err
+	}
+
+	b.compileCmds = cmds
+	return nil
+}
+
+// CompileCmdsPath is the path of the target's compilation database, which
+// covers every image that the target builds.
+func (t *TargetBuilder) CompileCmdsPath() string {
+	return TargetBinDir(t.target.Name()) + "/compile_commands.json"
+}
+
+// Writes the target's compilation database from the entries that its
+// builders collected.  A source file that several images compile (e.g., an
+// extra app) is listed once, with the flags of the first image that compiles
+// it: the app, then the loader, then the extra apps.
+func (t *TargetBuilder) writeCompileCommands() error {
+	builders := []*Builder{t.AppBuilder, t.LoaderBuilder}
+	builders = append(builders, t.ExtraAppBuilders...)
+
+	seen := map[string]struct{}{}
+	cmds := []toolchain.CompileCommand{}
+	for _, b := range builders {
+		if b == nil {
+			continue
+		}
+
+		for _, cc := range b.compileCmds {
+			if _, ok := seen[cc.File]; ok {
+				continue
+			}
+			seen[cc.File] = struct{}{}
+			cmds = append(cmds, cc)
+		}
+	}
+
+	return writeCompileCommands(t.CompileCmdsPath(), cmds)
+}
+
+// GenerateCompileCommands writes the target's compilation database
+// (bin/targets/<target>/compile_commands.json) without building the target.
+// The target's generated files are brought up to date first, as they would
+// be by a build.
+func (t *TargetBuilder) GenerateCompileCommands() error {
+	if err := t.PrepBuild(); err != nil {
+		return err
+	}
+
+	if err := t.bspPkg.Reload(t.AppBuilder.cfg.SettingValues()); err != nil {
+		return err
+	}
+
+	builders := []*Builder{t.LoaderBuilder, t.AppBuilder}
+	builders = append(builders, t.ExtraAppBuilders...)
+
+	for _, b := range builders {
+		if b == nil {
+			continue
+		}
+
+		if err := b.collectCompileCommands(); err != nil {
+			return err
+		}
+	}
+
+	return t.writeCompileCommands()
+}
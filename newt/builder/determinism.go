@@ -0,0 +1,149 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Suffixes of the linked outputs that a determinism check compares.  Object
+// files and archives are not compared; archives record timestamps.
+var determinismSuffixes = []string{
+	".elf",
+	".elf.bin",
+	".elf.map",
+}
+
+// Manifest fields that legitimately differ between builds.
+var determinismManifestIgnore = []string{
+	"build_time",
+}
+
+// Maps each compared artifact, relative to the target's bin directory, to the
+// SHA256 of its contents.
+type ArtifactSnapshot map[string][32]byte
+
+func isDeterminismArtifact(relPath string) bool {
+	if strings.HasPrefix(relPath, "generated/") {
+		return true
+	}
+	if filepath.Base(relPath) == "manifest.json" {
+		return true
+	}
+	for _, suffix := range determinismSuffixes {
+		if strings.HasSuffix(relPath, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Removes the fields that are expected to differ between builds from a
+// manifest.
+func normalizeManifest(data []byte) []byte {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return data
+	}
+
+	for _, field := range determinismManifestIgnore {
+		delete(m, field)
+	}
+
+	norm, err := json.Marshal(m)
+	if err != nil {
+		return data
+	}
+
+	return norm
+}
+
+// SnapshotArtifacts records the generated sources and headers, the linked
+// binaries, and the manifests in a target's bin directory.
+func SnapshotArtifacts(targetName string) (ArtifactSnapshot, error) {
+	snap := ArtifactSnapshot{}
+	dir := TargetBinDir(targetName)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo,
+		err error) error {
+
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if !isDeterminismArtifact(rel) {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if filepath.Base(rel) == "manifest.json" {
+			data = normalizeManifest(data)
+		}
+
+		snap[rel] = sha256.Sum256(data)
+		return nil
+	})
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	return snap, nil
+}
+
+// DiffArtifacts returns the sorted names of the artifacts that differ between
+// two snapshots, including artifacts present in only one of them.
+func DiffArtifacts(a ArtifactSnapshot, b ArtifactSnapshot) []string {
+	var diffs []string
+
+	for name, hash := range a {
+		if other, ok := b[name]; !ok || other != hash {
+			diffs = append(diffs, name)
+		}
+	}
+	for name, _ := range b {
+		if _, ok := a[name]; !ok {
+			diffs = append(diffs, name)
+		}
+	}
+
+	sort.Strings(diffs)
+	return diffs
+}
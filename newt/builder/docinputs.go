@@ -0,0 +1,101 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"strings"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/toolchain"
+)
+
+// The include paths and preprocessor defines that a package is compiled with
+// in a particular target.
+type DocInput struct {
+	Lpkg     *pkg.LocalPackage
+	Includes []string
+	Defines  []string
+}
+
+func (b *Builder) docInputs() ([]DocInput, error) {
+	var inputs []DocInput
+
+	for _, bpkg := range b.sortedBuildPackages() {
+		if bpkg.rpkg.Lpkg.Type() == pkg.PACKAGE_TYPE_GENERATED {
+			continue
+		}
+
+		c, err := b.newCompiler(bpkg, b.PkgBinDir(bpkg))
+		if err != nil {
+			return nil, err
+		}
+
+		ci := c.GetCompilerInfo()
+		lci := c.GetLocalCompilerInfo()
+
+		input := DocInput{
+			Lpkg: bpkg.rpkg.Lpkg,
+		}
+		for _, info := range []toolchain.CompilerInfo{ci, lci} {
+			input.Includes = append(input.Includes, info.Includes...)
+			for _, f := range info.Cflags {
+				if strings.HasPrefix(f, "-D") && len(f) > 2 {
+					input.Defines = append(input.Defines, f[2:])
+				}
+			}
+		}
+
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+// DocInputs returns the include paths and defines of each package in the
+// target, as the target would compile them.  Packages that appear in both the
+// loader and the app are reported once.
+func (t *TargetBuilder) DocInputs() ([]DocInput, error) {
+	if err := t.PrepBuild(); err != nil {
+		return nil, err
+	}
+
+	var inputs []DocInput
+	seen := map[*pkg.LocalPackage]struct{}{}
+
+	for _, b := range []*Builder{t.LoaderBuilder, t.AppBuilder} {
+		if b == nil {
+			continue
+		}
+
+		bi, err := b.docInputs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, input := range bi {
+			if _, ok := seen[input.Lpkg]; !ok {
+				seen[input.Lpkg] = struct{}{}
+				inputs = append(inputs, input)
+			}
+		}
+	}
+
+	return inputs, nil
+}
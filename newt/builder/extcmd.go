@@ -20,9 +20,13 @@
 package builder
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/kballard/go-shellquote"
 	log "github.com/sirupsen/logrus"
@@ -195,34 +199,92 @@ func getLinkTableEntry(name string) string {
 	return entry
 }
 
-func (t *TargetBuilder) generateLinkTables() {
+func (t *TargetBuilder) generateLinkTables() error {
 	var s []string
+	for _, pkg := range t.res.LpkgRpkgMap {
+		s = append(s, pkg.Lpkg.LinkTables()...)
+	}
+	sort.Strings(s)
+
+	buf := bytes.Buffer{}
+	for _, linkTable := range s {
+		buf.WriteString(getLinkTableEntry(linkTable))
+	}
 
 	dir := GeneratedBaseDir(t.target.FullName()) + "/link/include"
-	err := os.MkdirAll(dir, os.ModePerm)
-	if err != nil {
-		log.Error("Generate link tables error:\n", err)
-		return
+	return util.WriteGeneratedFile(dir+"/link_tables.ld.h", buf.Bytes())
+}
+
+// Symbol and section names are emitted verbatim into linker scripts, so they
+// must not contain anything that the linker would parse as script syntax.
+var linkKeepNameRe = regexp.MustCompile(`^[A-Za-z0-9_.$]+$`)
+
+// generateLinkKeep writes the linker script fragments that protect the
+// sections and symbols listed in each package's pkg.link_keep_sections and
+// pkg.link_keep_symbols from garbage collection.  link_keep.ld.h contains
+// KEEP statements and belongs inside an output section description;
+// link_keep_syms.ld.h contains EXTERN commands and belongs at the top level
+// of the BSP's linker script.  Both files are always written so that the BSP
+// can include them unconditionally.
+func (t *TargetBuilder) generateLinkKeep() error {
+	settings := t.res.Cfg.SettingValues()
+
+	sectMap := map[string]struct{}{}
+	symMap := map[string]struct{}{}
+
+	sortedNames := func(m map[string]struct{}) []string {
+		names := make([]string, 0, len(m))
+		for name, _ := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
 	}
 
-	linkHeader, err := os.Create(dir + "/link_tables.ld.h")
-	if err != nil {
-		log.Error("Generate link tables error:\n", err)
-		return
+	for _, rpkg := range t.res.LpkgRpkgMap {
+		lpkg := rpkg.Lpkg
+
+		for _, name := range lpkg.LinkKeepSections(settings) {
+			name = strings.TrimPrefix(name, ".")
+			if !linkKeepNameRe.MatchString(name) {
+				return util.FmtNewtError(
+					"package \"%s\" specifies invalid section name in "+
+						"pkg.link_keep_sections: \"%s\"", lpkg.FullName(), name)
+			}
+			sectMap[name] = struct{}{}
+		}
+
+		for _, name := range lpkg.LinkKeepSymbols(settings) {
+			if !linkKeepNameRe.MatchString(name) {
+				return util.FmtNewtError(
+					"package \"%s\" specifies invalid symbol name in "+
+						"pkg.link_keep_symbols: \"%s\"", lpkg.FullName(), name)
+			}
+			symMap[name] = struct{}{}
+		}
 	}
 
-	for _, pkg := range t.res.LpkgRpkgMap {
-		s = append(s, pkg.Lpkg.LinkTables()...)
+	indent := "        "
+
+	sectBuf := bytes.Buffer{}
+	for _, name := range sortedNames(sectMap) {
+		sectBuf.WriteString(indent + "KEEP(*(." + name + "))\n")
+		sectBuf.WriteString(indent + "KEEP(*(SORT(." + name + ".*)))\n")
 	}
 
-	if len(s) == 0 {
-		return
+	symBuf := bytes.Buffer{}
+	for _, name := range sortedNames(symMap) {
+		symBuf.WriteString("EXTERN(" + name + ")\n")
 	}
 
-	for _, linkTable := range s {
-		linkHeader.WriteString(getLinkTableEntry(linkTable))
+	dir := GeneratedBaseDir(t.target.FullName()) + "/link/include"
+	if err := util.WriteGeneratedFile(dir+"/link_keep.ld.h",
+		sectBuf.Bytes()); err != nil {
+
+		return err
 	}
 
+	return util.WriteGeneratedFile(dir+"/link_keep_syms.ld.h", symBuf.Bytes())
 }
 
 //link tables
@@ -0,0 +1,236 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/util"
+)
+
+const RESOLUTION_FILENAME = "resolution.json"
+
+// A snapshot of a target's resolved configuration.  A copy of the generated
+// file can be committed to the target directory ("freezing" the
+// configuration); `newt build --verify-frozen` fails if the current
+// resolution differs from it.
+type FrozenResolution struct {
+	// [repo-name] => commit.  The project's local repo is not included; its
+	// commit changes whenever the freeze file itself is committed.
+	Repos map[string]string `json:"repos"`
+
+	// Full names of all packages in the build, sorted.
+	Pkgs []string `json:"packages"`
+
+	// [setting-name] => value
+	Syscfg map[string]string `json:"syscfg"`
+
+	// [api-name] => full name of the providing package
+	Apis map[string]string `json:"apis"`
+}
+
+func ResolutionPath(targetName string) string {
+	return GeneratedBaseDir(targetName) + "/" + RESOLUTION_FILENAME
+}
+
+// FrozenResolutionPath returns the path of the target's committed freeze file.
+func (t *TargetBuilder) FrozenResolutionPath() string {
+	return t.target.Package().BasePath() + "/" + RESOLUTION_FILENAME
+}
+
+// FrozenResolution captures the target's current resolution.
+func (t *TargetBuilder) FrozenResolution() (FrozenResolution, error) {
+	fr := FrozenResolution{
+		Repos:  map[string]string{},
+		Syscfg: map[string]string{},
+		Apis:   map[string]string{},
+	}
+
+	if err := t.ensureResolved(); err != nil {
+		return fr, err
+	}
+
+	for _, rpkg := range t.res.MasterSet.Rpkgs {
+		lpkg := rpkg.Lpkg
+		fr.Pkgs = append(fr.Pkgs, lpkg.FullName())
+
+		r := lpkg.Repo()
+		if r.IsLocal() {
+			continue
+		}
+		if _, ok := fr.Repos[r.Name()]; ok {
+			continue
+		}
+
+		commit := "UNKNOWN"
+		if repo := project.GetProject().FindRepo(r.Name()); repo != nil {
+			hash, err := repo.CurrentHash()
+			if err != nil {
+				log.Debugf("Unable to determine commit hash for %s: %v",
+					r.Name(), err)
+			} else {
+				commit = hash
+			}
+		}
+		fr.Repos[r.Name()] = commit
+	}
+	sort.Strings(fr.Pkgs)
+
+	for name, entry := range t.res.Cfg.Settings {
+		fr.Syscfg[name] = entry.Value
+	}
+
+	for api, rpkg := range t.res.ApiMap {
+		fr.Apis[api] = rpkg.Lpkg.FullName()
+	}
+
+	return fr, nil
+}
+
+// Writes the target's resolution to its generated directory.  The file is only
+// rewritten if its contents change.
+func (t *TargetBuilder) writeResolution() error {
+	fr, err := t.FrozenResolution()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(fr, "", "    ")
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+	data = append(data, '\n')
+
+	path := ResolutionPath(t.target.FullName())
+	if old, err := ioutil.ReadFile(path); err == nil &&
+		bytes.Equal(old, data) {
+
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return util.ChildNewtError(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
+
+// Describes the differences between two string maps, one line per key.
+func diffStringMaps(what string, frozen map[string]string,
+	cur map[string]string) []string {
+
+	keys := map[string]struct{}{}
+	for k, _ := range frozen {
+		keys[k] = struct{}{}
+	}
+	for k, _ := range cur {
+		keys[k] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k, _ := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var lines []string
+	for _, k := range sorted {
+		fv, fok := frozen[k]
+		cv, cok := cur[k]
+
+		switch {
+		case !fok:
+			lines = append(lines,
+				fmt.Sprintf("%s %s added: %s", what, k, cv))
+		case !cok:
+			lines = append(lines,
+				fmt.Sprintf("%s %s removed (was %s)", what, k, fv))
+		case fv != cv:
+			lines = append(lines,
+				fmt.Sprintf("%s %s changed: %s -> %s", what, k, fv, cv))
+		}
+	}
+
+	return lines
+}
+
+// VerifyFrozen compares the target's current resolution against its committed
+// freeze file.  An error describing the differences is returned if they do
+// not match.
+func (t *TargetBuilder) VerifyFrozen() error {
+	path := t.FrozenResolutionPath()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return util.FmtNewtError(
+			"target %s has no freeze file: %s", t.target.FullName(),
+			err.Error())
+	}
+
+	frozen := FrozenResolution{}
+	if err := json.Unmarshal(data, &frozen); err != nil {
+		return util.FmtNewtError("invalid freeze file \"%s\": %s",
+			path, err.Error())
+	}
+
+	cur, err := t.FrozenResolution()
+	if err != nil {
+		return err
+	}
+
+	toMap := func(names []string) map[string]string {
+		m := make(map[string]string, len(names))
+		for _, n := range names {
+			m[n] = "present"
+		}
+		return m
+	}
+
+	var lines []string
+	lines = append(lines,
+		diffStringMaps("repo", frozen.Repos, cur.Repos)...)
+	lines = append(lines,
+		diffStringMaps("package", toMap(frozen.Pkgs), toMap(cur.Pkgs))...)
+	lines = append(lines,
+		diffStringMaps("setting", frozen.Syscfg, cur.Syscfg)...)
+	lines = append(lines,
+		diffStringMaps("api", frozen.Apis, cur.Apis)...)
+
+	if len(lines) > 0 {
+		return util.FmtNewtError(
+			"resolution of target %s differs from freeze file %s:\n    %s",
+			t.target.FullName(), path, strings.Join(lines, "\n    "))
+	}
+
+	return nil
+}
@@ -0,0 +1,339 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// A section that the linker discarded or retained.
+type GcSection struct {
+	// The input section name (e.g., ".text.foo").
+	Section string
+
+	// The object file containing the section.
+	File string
+
+	// The name of the package that owns the object file.
+	Pkg string
+}
+
+// Symbol returns the name of the function or variable contained in the
+// section.  This assumes the code was compiled with -ffunction-sections and
+// -fdata-sections.
+func (s *GcSection) Symbol() string {
+	for _, pfx := range []string{
+		".text.", ".rodata.", ".data.", ".bss.", ".sdata.", ".sbss."} {
+
+		if strings.HasPrefix(s.Section, pfx) {
+			return strings.TrimPrefix(s.Section, pfx)
+		}
+	}
+
+	return s.Section
+}
+
+// IsCode indicates whether the section contains code rather than data.
+func (s *GcSection) IsCode() bool {
+	return strings.HasPrefix(s.Section, ".text")
+}
+
+var gcRemovedRe = regexp.MustCompile(
+	`removing unused section '([^']+)' in file '([^']+)'`)
+
+// Matches an input section line in a map file's memory map:
+// " .text.foo  0x00001000  0x20 path/lib.a(obj.o)".
+var mapInputSectionRe = regexp.MustCompile(
+	`^\s+(\.\S+)?\s+0x[0-9a-fA-F]+\s+0x[0-9a-fA-F]+\s+(\S+)$`)
+
+// Matches a symbol line in a map file's memory map:
+// "                0x00001000                foo".
+var mapSymbolRe = regexp.MustCompile(`^\s+0x[0-9a-fA-F]+\s+([A-Za-z_]\S*)$`)
+
+var keepRe = regexp.MustCompile(`KEEP\s*\(`)
+var keepSectionRe = regexp.MustCompile(`\.[A-Za-z0-9_.*?\[\]]+`)
+
+// EnableGcReport causes the target to be linked with --gc-sections and
+// --print-gc-sections so that a report of discarded code can be generated.
+func (t *TargetBuilder) EnableGcReport() {
+	t.gcReport = true
+}
+
+// Parses the list of discarded sections that the linker emits when
+// --print-gc-sections is specified.
+func (b *Builder) parseGcOutput(fileName string) ([]GcSection, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, util.FmtNewtError(
+			"failed to read gc output: %s", err.Error())
+	}
+
+	var secs []GcSection
+	for _, line := range strings.Split(string(data), "\n") {
+		m := gcRemovedRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		ar, _ := splitMember(m[2])
+		secs = append(secs, GcSection{
+			Section: m[1],
+			File:    m[2],
+			Pkg:     b.FindPkgNameByArName(ar),
+		})
+	}
+
+	return secs, nil
+}
+
+// Extracts the input section patterns wrapped in KEEP() directives from the
+// specified linker scripts.
+func keepPatterns(scripts []string) ([]string, error) {
+	var pats []string
+
+	for _, script := range scripts {
+		data, err := ioutil.ReadFile(script)
+		if err != nil {
+			return nil, util.ChildNewtError(err)
+		}
+		text := string(data)
+
+		for _, loc := range keepRe.FindAllStringIndex(text, -1) {
+			// Find the matching close parenthesis.
+			depth := 1
+			end := loc[1]
+			for end < len(text) && depth > 0 {
+				switch text[end] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+				end++
+			}
+
+			pats = append(pats,
+				keepSectionRe.FindAllString(text[loc[1]:end], -1)...)
+		}
+	}
+
+	return pats, nil
+}
+
+func matchesAny(name string, pats []string) bool {
+	for _, p := range pats {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Finds the symbols that are only present in the image because a linker
+// script KEEP() directive retained the section containing them.  A symbol
+// qualifies if it lives in a KEEP() section and no other object references
+// it.
+func (b *Builder) keptOnlySymbols(mapFile string) ([]GcSection, error) {
+	scripts := append([]string{}, b.linkerScripts...)
+	if dir, err := b.GetAutogeneratedLinkerIncludeDir(); err == nil {
+		gen, _ := filepath.Glob(dir + "/*.ld")
+		scripts = append(scripts, gen...)
+	}
+
+	pats, err := keepPatterns(scripts)
+	if err != nil {
+		return nil, err
+	}
+	if len(pats) == 0 {
+		return nil, nil
+	}
+
+	xref, err := ParseMapFileXref(mapFile)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(mapFile)
+	if err != nil {
+		return nil, util.NewNewtError("Mapfile failed: " + err.Error())
+	}
+	defer file.Close()
+
+	var kept []GcSection
+
+	inMap := false
+	pendingSec := ""
+	var cur *GcSection
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, "Linker script and memory map") {
+			inMap = true
+			continue
+		}
+		if !inMap {
+			continue
+		}
+		if strings.HasPrefix(line, "Cross Reference Table") {
+			break
+		}
+
+		// Long section names are printed on a line of their own.
+		fields := strings.Fields(line)
+		if len(fields) == 1 && strings.HasPrefix(line, " .") {
+			pendingSec = fields[0]
+			continue
+		}
+
+		if m := mapInputSectionRe.FindStringSubmatch(line); m != nil {
+			sec := m[1]
+			if sec == "" {
+				sec = pendingSec
+			}
+			pendingSec = ""
+
+			cur = nil
+			if sec != "" && matchesAny(sec, pats) {
+				ar, _ := splitMember(m[2])
+				cur = &GcSection{
+					Section: sec,
+					File:    m[2],
+					Pkg:     b.FindPkgNameByArName(ar),
+				}
+			}
+			continue
+		}
+		pendingSec = ""
+
+		if m := mapSymbolRe.FindStringSubmatch(line); m != nil {
+			if cur != nil && len(xref.Symbols[m[1]]) == 1 {
+				kept = append(kept, GcSection{
+					Section: m[1],
+					File:    cur.File,
+					Pkg:     cur.Pkg,
+				})
+			}
+			continue
+		}
+
+		cur = nil
+	}
+
+	return kept, nil
+}
+
+func printGcSections(title string, secs []GcSection, symbols bool) {
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", title)
+	if len(secs) == 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "    (none)\n")
+		return
+	}
+
+	sort.SliceStable(secs, func(i int, j int) bool {
+		if secs[i].Pkg != secs[j].Pkg {
+			return secs[i].Pkg < secs[j].Pkg
+		}
+		return secs[i].Section < secs[j].Section
+	})
+
+	curPkg := ""
+	for i, _ := range secs {
+		s := &secs[i]
+		if i == 0 || s.Pkg != curPkg {
+			curPkg = s.Pkg
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "    %s\n", curPkg)
+		}
+
+		if symbols {
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "        %s\n",
+				s.Section)
+		} else {
+			kind := "data"
+			if s.IsCode() {
+				kind = "func"
+			}
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "        %s %s\n",
+				kind, s.Symbol())
+		}
+	}
+}
+
+// GcReport reports the functions and data that the linker discarded from the
+// image, grouped by package, along with the symbols that are only retained
+// via KEEP() directives in the linker scripts.
+func (b *Builder) GcReport() error {
+	elfPath := b.AppElfPath()
+
+	removed, err := b.parseGcOutput(elfPath + ".gc")
+	if err != nil {
+		return err
+	}
+	printGcSections("Discarded sections:", removed, false)
+
+	mapFile := elfPath + ".map"
+	if util.NodeNotExist(mapFile) {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"\nNo map file; enable compiler.ld.mapfile to report symbols "+
+				"retained by KEEP()\n")
+		return nil
+	}
+
+	kept, err := b.keptOnlySymbols(mapFile)
+	if err != nil {
+		return err
+	}
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "\n")
+	printGcSections("Symbols retained only by KEEP():", kept, true)
+
+	return nil
+}
+
+// GcReport reports the code and data discarded from each of the target's
+// images.  The target must have been built after a call to EnableGcReport().
+func (t *TargetBuilder) GcReport() error {
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Dead code report for application image: %s\n",
+		t.AppBuilder.buildName)
+	if err := t.AppBuilder.GcReport(); err != nil {
+		return err
+	}
+
+	if t.LoaderBuilder != nil {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"\nDead code report for loader image: %s\n",
+			t.LoaderBuilder.buildName)
+		if err := t.LoaderBuilder.GcReport(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
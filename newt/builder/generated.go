@@ -0,0 +1,65 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Removes the files in the target's generated source and include directories
+// that were not generated for the current build.  A file stops being
+// generated when the configuration changes, e.g., when a loader or a hardware
+// configuration is removed.  Without this, a stale source file would still be
+// compiled and a stale header could still be included.
+func (t *TargetBuilder) pruneGenerated() error {
+	dirs := []string{
+		GeneratedSrcDir(t.target.FullName()),
+		GeneratedIncludeDir(t.target.FullName()),
+	}
+
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo,
+			err error) error {
+
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() || util.IsGeneratedFile(path) {
+				return nil
+			}
+
+			log.Debugf("removing stale generated file %s", path)
+			return os.Remove(path)
+		})
+		if err != nil {
+			return util.ChildNewtError(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Matches the source file that implements a HAL module (e.g., hal_gpio.c).
+var halImplFileRe = regexp.MustCompile(`^hal_(\w+)\.(c|cpp|cc)$`)
+
+// Matches an include of a HAL module header (e.g., #include "hal/hal_gpio.h").
+var halIncludeRe = regexp.MustCompile(
+	`(?m)^\s*#\s*include\s*[<"]hal/hal_(\w+)\.h[>"]`)
+
+// A HAL module (hal_gpio, hal_spi, etc.) along with the target packages that
+// implement it and the packages that use it.
+type HalModule struct {
+	Name         string
+	Implementers []string
+	Users        []string
+}
+
+func (m HalModule) Unimplemented() bool {
+	return len(m.Users) > 0 && len(m.Implementers) == 0
+}
+
+func (m HalModule) Unused() bool {
+	return len(m.Implementers) > 0 && len(m.Users) == 0
+}
+
+// HalReport determines which HAL modules the target's packages implement and
+// use.  A package implements a module if it compiles a `hal_<module>.c` source
+// file (typically an MCU or BSP package); it uses a module if one of its
+// source files directly includes `hal/hal_<module>.h`.  A package is not
+// considered a user of a module that it implements.
+func (t *TargetBuilder) HalReport() ([]HalModule, error) {
+	units, err := t.SourceUnits()
+	if err != nil {
+		return nil, err
+	}
+
+	impls := map[string]map[string]struct{}{}
+	users := map[string]map[string]struct{}{}
+
+	add := func(m map[string]map[string]struct{}, mod string, pkgName string) {
+		if m[mod] == nil {
+			m[mod] = map[string]struct{}{}
+		}
+		m[mod][pkgName] = struct{}{}
+	}
+
+	for _, u := range units {
+		sm := halImplFileRe.FindStringSubmatch(filepath.Base(u.File))
+		if sm != nil {
+			add(impls, "hal_"+sm[1], u.Lpkg.FullName())
+		}
+	}
+
+	for _, u := range units {
+		data, err := ioutil.ReadFile(u.File)
+		if err != nil {
+			return nil, util.ChildNewtError(err)
+		}
+
+		for _, sm := range halIncludeRe.FindAllStringSubmatch(string(data), -1) {
+			mod := "hal_" + sm[1]
+			if _, ok := impls[mod][u.Lpkg.FullName()]; ok {
+				continue
+			}
+			add(users, mod, u.Lpkg.FullName())
+		}
+	}
+
+	sortedKeys := func(m map[string]struct{}) []string {
+		keys := make([]string, 0, len(m))
+		for k, _ := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	names := map[string]struct{}{}
+	for mod, _ := range impls {
+		names[mod] = struct{}{}
+	}
+	for mod, _ := range users {
+		names[mod] = struct{}{}
+	}
+
+	var mods []HalModule
+	for _, name := range sortedKeys(names) {
+		mods = append(mods, HalModule{
+			Name:         name,
+			Implementers: sortedKeys(impls[name]),
+			Users:        sortedKeys(users[name]),
+		})
+	}
+
+	return mods, nil
+}
+
+func HalReportText(mods []HalModule) string {
+	buffer := bytes.NewBufferString("")
+
+	list := func(names []string) string {
+		if len(names) == 0 {
+			return "-"
+		}
+		return strings.Join(names, " ")
+	}
+
+	for _, m := range mods {
+		flag := ""
+		if m.Unimplemented() {
+			flag = " [UNIMPLEMENTED]"
+		} else if m.Unused() {
+			flag = " [UNUSED]"
+		}
+
+		fmt.Fprintf(buffer, "%s%s\n", m.Name, flag)
+		fmt.Fprintf(buffer, "    implemented by: %s\n", list(m.Implementers))
+		fmt.Fprintf(buffer, "    used by:        %s\n", list(m.Users))
+	}
+
+	return buffer.String()
+}
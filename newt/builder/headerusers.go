@@ -0,0 +1,217 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/toolchain"
+	"mynewt.apache.org/newt/util"
+)
+
+// A source file whose translation unit includes a header, directly or
+// indirectly.
+type HeaderUser struct {
+	BuildName string
+	Pkg       string
+	File      string
+}
+
+// The users of one header file.
+type HeaderUsers struct {
+	// The header's path, relative to the project if it is inside it.
+	Header string
+	Users  []HeaderUser
+}
+
+// Converts a path to the form that the header report uses: slash-separated
+// and relative to the project, if it is inside the project.
+func reportPath(path string) string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	return strings.TrimPrefix(path, ProjectRoot()+"/")
+}
+
+// Indicates whether a header path matches the header specified by the user.
+// The user can specify the header by its path (absolute or relative to the
+// project) or by a path suffix, e.g., the name used in an #include directive
+// ("os/os.h").
+func headerMatches(header string, spec string) bool {
+	return header == spec || strings.HasSuffix(header, "/"+spec)
+}
+
+// Finds the builder's source files that include the specified header.  The
+// returned map is indexed by the header's report path; a suffix can match
+// several headers.  The second return value indicates whether any of the
+// builder's source files has a dependency file.
+func (b *Builder) headerUsers(spec string) (
+	map[string][]HeaderUser, bool, error) {
+
+	pjs, err := b.compileJobs()
+	if err != nil {
+		return nil, false, err
+	}
+
+	users := map[string][]HeaderUser{}
+	built := false
+
+	for _, pj := range pjs {
+		bpkg := pj.bpkg
+		for _, e := range pj.jobs {
+			if e.CompilerType == toolchain.COMPILER_TYPE_ARCHIVE {
+				continue
+			}
+
+			depsPath := e.Compiler.DepsPath(e.Filename)
+			if util.NodeNotExist(depsPath) {
+				continue
+			}
+			built = true
+
+			deps, err := toolchain.ParseDepsFile(depsPath)
+			if err != nil {
+				return nil, false, err
+			}
+
+			// A header can be listed more than once.
+			seen := map[string]struct{}{}
+			for _, dep := range deps {
+				if !filepath.IsAbs(dep) {
+					dep = filepath.Join(ProjectRoot(), dep)
+				}
+				dep = reportPath(dep)
+
+				if !headerMatches(dep, spec) {
+					continue
+				}
+				if _, ok := seen[dep]; ok {
+					continue
+				}
+				seen[dep] = struct{}{}
+
+				users[dep] = append(users[dep], HeaderUser{
+					BuildName: b.buildName,
+					Pkg:       bpkg.rpkg.Lpkg.FullName(),
+					File:      reportPath(e.Filename),
+				})
+			}
+		}
+	}
+
+	return users, built, nil
+}
+
+// HeaderUsers lists the source files in the target whose translation units
+// include the specified header, directly or indirectly, according to the
+// dependency (.d) files of the most recent build.  The header is specified by
+// its path or by a path suffix (e.g., "os/os.h").  The result contains one
+// entry for each header that matches.
+func (t *TargetBuilder) HeaderUsers(header string) ([]HeaderUsers, error) {
+	if err := t.PrepBuild(); err != nil {
+		return nil, err
+	}
+
+	spec := filepath.ToSlash(filepath.Clean(header))
+	if filepath.IsAbs(header) {
+		spec = reportPath(header)
+	}
+
+	builders := []*Builder{t.LoaderBuilder, t.AppBuilder}
+	builders = append(builders, t.ExtraAppBuilders...)
+
+	users := map[string][]HeaderUser{}
+	built := false
+	for _, b := range builders {
+		if b == nil {
+			continue
+		}
+
+		bu, bBuilt, err := b.headerUsers(spec)
+		if err != nil {
+			return nil, err
+		}
+		for h, u := range bu {
+			users[h] = append(users[h], u...)
+		}
+		built = built || bBuilt
+	}
+
+	if !built {
+		return nil, util.FmtNewtError(
+			"target %s has not been built; run \"newt build %s\" first",
+			t.target.FullName(), t.target.Name())
+	}
+
+	var hus []HeaderUsers
+	for h, u := range users {
+		sort.SliceStable(u, func(i int, j int) bool {
+			if u[i].Pkg != u[j].Pkg {
+				return u[i].Pkg < u[j].Pkg
+			}
+			return u[i].File < u[j].File
+		})
+		hus = append(hus, HeaderUsers{Header: h, Users: u})
+	}
+	sort.Slice(hus, func(i int, j int) bool {
+		return hus[i].Header < hus[j].Header
+	})
+
+	return hus, nil
+}
+
+// HeaderUsersText produces a human-readable report of a header's users,
+// grouped by package.
+func HeaderUsersText(hus []HeaderUsers) string {
+	buffer := bytes.Buffer{}
+
+	for i, hu := range hus {
+		if i != 0 {
+			buffer.WriteString("\n")
+		}
+
+		pkgs := map[string]struct{}{}
+		for _, u := range hu.Users {
+			pkgs[u.Pkg] = struct{}{}
+		}
+
+		fmt.Fprintf(&buffer,
+			"%s is included by %d source file(s) in %d package(s):\n",
+			hu.Header, len(hu.Users), len(pkgs))
+
+		pkg := ""
+		for _, u := range hu.Users {
+			if u.Pkg != pkg {
+				pkg = u.Pkg
+				fmt.Fprintf(&buffer, "    %s\n", pkg)
+			}
+
+			file := u.File
+			if u.BuildName != "app" {
+				file += " (" + u.BuildName + ")"
+			}
+			fmt.Fprintf(&buffer, "        %s\n", file)
+		}
+	}
+
+	return buffer.String()
+}
@@ -0,0 +1,271 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"debug/elf"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/toolchain"
+	"mynewt.apache.org/newt/util"
+)
+
+// Instrumentation modes for profiling builds.
+const (
+	// Calls __cyg_profile_func_enter() and __cyg_profile_func_exit() on
+	// every function entry and exit.
+	INSTRUMENT_FUNCTIONS = "functions"
+
+	// Records gcov arc counters.
+	INSTRUMENT_GCOV = "gcov"
+)
+
+var InstrumentModes = []string{INSTRUMENT_FUNCTIONS, INSTRUMENT_GCOV}
+
+var instrumentCflags = map[string][]string{
+	INSTRUMENT_FUNCTIONS: []string{"-finstrument-functions"},
+	INSTRUMENT_GCOV:      []string{"--coverage"},
+}
+
+// Flags that the link requires so that the instrumented code links against
+// the compiler's runtime support (e.g., libgcov).
+var instrumentLflags = map[string][]string{
+	INSTRUMENT_GCOV: []string{"--coverage"},
+}
+
+// SetInstrument causes the target to be built with compiler instrumentation
+// for on-target profiling.  Every package except the target's profiler
+// package (target.profiler) is instrumented, and the profiler package is
+// added to the build.  The NEWT_INSTRUMENT_<MODE> setting is injected so that
+// packages can configure themselves for the instrumented build.
+func (t *TargetBuilder) SetInstrument(mode string) error {
+	if instrumentCflags[mode] == nil {
+		return util.FmtNewtError(
+			"invalid instrumentation mode \"%s\"; must be one of: %s",
+			mode, strings.Join(InstrumentModes, ", "))
+	}
+
+	t.instrument = mode
+	t.InjectSetting("NEWT_INSTRUMENT_"+util.CIdentifier(
+		strings.ToUpper(mode)), "1")
+
+	if t.target.ProfilerName == "" {
+		util.StatusMessage(util.VERBOSITY_QUIET,
+			"* Warning: target %s does not specify a profiler package "+
+				"(target.profiler); the app must provide the "+
+				"instrumentation hooks\n", t.target.FullName())
+	}
+
+	// The target was resolved when the builder was created.  Resolve it again
+	// so that the injected setting and the profiler package take effect.
+	t.res = nil
+	if err := t.ensureResolved(); err != nil {
+		return err
+	}
+
+	return t.bspPkg.Reload(t.res.Cfg.SettingValues())
+}
+
+// Instrument returns the target's instrumentation mode, or "" if the target
+// is not instrumented.
+func (t *TargetBuilder) Instrument() string {
+	return t.instrument
+}
+
+// Returns the instrumentation flags for the specified package.  The profiler
+// package is never instrumented; its hooks would otherwise call themselves.
+func (b *Builder) instrumentInfo(bpkg *BuildPackage) *toolchain.CompilerInfo {
+	t := b.targetBuilder
+	if t.instrument == "" {
+		return nil
+	}
+
+	if bpkg != nil && t.target.ProfilerName != "" &&
+		bpkg.rpkg.Lpkg == t.target.Profiler() {
+
+		return nil
+	}
+
+	return &toolchain.CompilerInfo{Cflags: instrumentCflags[t.instrument]}
+}
+
+// Returns the instrumentation flags for the link, or nil if the target is not
+// instrumented or the mode requires none.
+func (b *Builder) instrumentLinkInfo() *toolchain.CompilerInfo {
+	lflags := instrumentLflags[b.targetBuilder.instrument]
+	if len(lflags) == 0 {
+		return nil
+	}
+
+	return &toolchain.CompilerInfo{Lflags: lflags}
+}
+
+// The number of profiler samples attributed to one function.
+type FuncSamples struct {
+	Name  string
+	Addr  uint64
+	Count uint64
+}
+
+// A function symbol in an ELF file.
+type elfFunc struct {
+	name string
+	addr uint64
+	size uint64
+}
+
+// Reads the function symbols of an ELF file, sorted by address.  For ARM
+// targets, the Thumb bit is cleared from each address.
+func readElfFuncs(elfPath string) ([]elfFunc, bool, error) {
+	f, err := elf.Open(elfPath)
+	if err != nil {
+		return nil, false, util.ChildNewtError(err)
+	}
+	defer f.Close()
+
+	thumb := f.Machine == elf.EM_ARM
+
+	syms, err := f.Symbols()
+	if err != nil {
+		return nil, false, util.FmtNewtError(
+			"failed to read symbols from %s: %s", elfPath, err.Error())
+	}
+
+	var funcs []elfFunc
+	for _, sym := range syms {
+		if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || sym.Name == "" ||
+			sym.Section == elf.SHN_UNDEF {
+
+			continue
+		}
+
+		addr := sym.Value
+		if thumb {
+			addr &^= 1
+		}
+		funcs = append(funcs, elfFunc{
+			name: sym.Name,
+			addr: addr,
+			size: sym.Size,
+		})
+	}
+
+	sort.Slice(funcs, func(i int, j int) bool {
+		return funcs[i].addr < funcs[j].addr
+	})
+
+	return funcs, thumb, nil
+}
+
+// Finds the function containing an address.  A function without a size is
+// assumed to extend to the next function.
+func findElfFunc(funcs []elfFunc, addr uint64) *elfFunc {
+	i := sort.Search(len(funcs), func(i int) bool {
+		return funcs[i].addr > addr
+	})
+	if i == 0 {
+		return nil
+	}
+
+	f := &funcs[i-1]
+	if f.size != 0 && addr >= f.addr+f.size {
+		return nil
+	}
+
+	return f
+}
+
+// DecodeProfile maps the addresses recorded by an on-target profiler back to
+// the functions in the target's ELF file.  Each line of the samples file
+// contains a hexadecimal address, optionally followed by a decimal count
+// (default 1).  Blank lines and lines beginning with '#' are ignored.  The
+// results are sorted by count, highest first.  The second return value is the
+// number of samples that could not be attributed to a function.
+func DecodeProfile(elfPath string, samplesPath string) (
+	[]FuncSamples, uint64, error) {
+
+	funcs, thumb, err := readElfFuncs(elfPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data, err := ioutil.ReadFile(samplesPath)
+	if err != nil {
+		return nil, 0, util.ChildNewtError(err)
+	}
+
+	counts := map[*elfFunc]uint64{}
+	var unknown uint64
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		addr, err := strconv.ParseUint(
+			strings.TrimPrefix(strings.ToLower(fields[0]), "0x"), 16, 64)
+		if err != nil {
+			return nil, 0, util.FmtNewtError(
+				"%s:%d: invalid address: %s", samplesPath, i+1, fields[0])
+		}
+
+		count := uint64(1)
+		if len(fields) > 1 {
+			count, err = strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return nil, 0, util.FmtNewtError(
+					"%s:%d: invalid count: %s", samplesPath, i+1, fields[1])
+			}
+		}
+
+		if thumb {
+			addr &^= 1
+		}
+
+		if f := findElfFunc(funcs, addr); f != nil {
+			counts[f] += count
+		} else {
+			unknown += count
+		}
+	}
+
+	samples := make([]FuncSamples, 0, len(counts))
+	for f, count := range counts {
+		samples = append(samples, FuncSamples{
+			Name:  f.name,
+			Addr:  f.addr,
+			Count: count,
+		})
+	}
+
+	sort.Slice(samples, func(i int, j int) bool {
+		if samples[i].Count != samples[j].Count {
+			return samples[i].Count > samples[j].Count
+		}
+		return samples[i].Name < samples[j].Name
+	})
+
+	return samples, unknown, nil
+}
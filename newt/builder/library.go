@@ -104,7 +104,7 @@ func parseObjectLine(line string, r *regexp.Regexp) (error, *symbol.SymbolInfo)
 }
 
 func getParseRexeg() (error, *regexp.Regexp) {
-	r, err := regexp.Compile("^([0-9A-Fa-f]+)[\t ]+([lgu! ][w ][C ][W ][Ii ][Dd ][FfO ])[\t ]+([^\t\n\f\r ]+)[\t ]+([0-9a-fA-F]+)[\t ]([^\t\n\f\r ]+)")
+	r, err := regexp.Compile("^([0-9A-Fa-f]+)[\t ]+([lgu! ][w ][C ][W ][Ii ][Dd ][FfO ])[\t ]+([^\t\n\f\r ]+)[\t ]+([0-9a-fA-F]+)[\t ]+([^\t\n\f\r ]+)")
 
 	if err != nil {
 		return err, nil
@@ -0,0 +1,416 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+const LINK_PIN_FIRST = "first"
+const LINK_PIN_LAST = "last"
+
+// An archive passed to the linker.
+type LinkInput struct {
+	// The package that produced the archive; empty for archives found in the
+	// target's extra archive directories.
+	Pkg       string
+	File      string
+	WholeArch bool
+
+	// LINK_PIN_FIRST or LINK_PIN_LAST if the package is listed in
+	// target.link_first or target.link_last; empty otherwise.
+	Pin string
+}
+
+// Calculates the order in which archives are passed to the linker.  Package
+// archives are ordered by package name, followed by the archives in the extra
+// directories.  Packages listed in target.link_first are moved to the start,
+// and those in target.link_last to the end, in the order they are listed.
+func (b *Builder) linkInputs(extraADirs []string) ([]LinkInput, error) {
+	tgt := b.GetTarget()
+
+	// [package-full-name] => position in its pin list.
+	pinIdx := map[string]int{}
+	pins := map[string]string{}
+	for _, p := range []struct {
+		pin   string
+		names []string
+	}{
+		{LINK_PIN_FIRST, tgt.LinkFirst},
+		{LINK_PIN_LAST, tgt.LinkLast},
+	} {
+		for i, name := range p.names {
+			if lpkg := tgt.ResolvePackageName(name); lpkg != nil {
+				pins[lpkg.FullName()] = p.pin
+				pinIdx[lpkg.FullName()] = i
+			}
+		}
+	}
+
+	var first []LinkInput
+	var middle []LinkInput
+	var last []LinkInput
+
+	// Some generated packages share a bin directory; each archive is only
+	// linked once.
+	seen := map[string]struct{}{}
+
+	for _, bpkg := range b.sortedBuildPackages() {
+		ci, err := bpkg.CompilerInfo(b)
+		if err != nil {
+			return nil, err
+		}
+
+		name := bpkg.rpkg.Lpkg.FullName()
+		fullANames, _ := filepath.Glob(b.PkgBinDir(bpkg) + "/*.a")
+		for _, archiveName := range fullANames {
+			if _, ok := seen[archiveName]; ok {
+				continue
+			}
+			seen[archiveName] = struct{}{}
+
+			in := LinkInput{
+				Pkg:       name,
+				File:      archiveName,
+				WholeArch: ci.WholeArch,
+				Pin:       pins[name],
+			}
+
+			switch in.Pin {
+			case LINK_PIN_FIRST:
+				first = append(first, in)
+			case LINK_PIN_LAST:
+				last = append(last, in)
+			default:
+				middle = append(middle, in)
+			}
+		}
+	}
+
+	for _, dir := range extraADirs {
+		fullANames, _ := filepath.Glob(dir + "/*.a")
+		for _, archiveName := range fullANames {
+			if _, ok := seen[archiveName]; ok {
+				continue
+			}
+			seen[archiveName] = struct{}{}
+
+			middle = append(middle, LinkInput{File: archiveName})
+		}
+	}
+
+	byPinIdx := func(ins []LinkInput) {
+		sort.SliceStable(ins, func(i int, j int) bool {
+			return pinIdx[ins[i].Pkg] < pinIdx[ins[j].Pkg]
+		})
+	}
+	byPinIdx(first)
+	byPinIdx(last)
+
+	inputs := append(first, middle...)
+	return append(inputs, last...), nil
+}
+
+// A global symbol with several definitions, at least one of them weak.
+type WeakSymbol struct {
+	Name string
+
+	// Each definition has the form "<package> (<object-file>)".
+	Weak   []string
+	Strong []string
+
+	// The symbol's binding in the linked ELF file: "weak" or "strong".  Empty
+	// if the ELF file has not been linked or does not define the symbol.
+	Linked string
+}
+
+// The link inputs of one builder, and the weak symbols that the inputs define.
+type LinkOrderInfo struct {
+	BuildName   string
+	Inputs      []LinkInput
+	WeakSymbols []WeakSymbol
+}
+
+// Matches the line that objdump prints before the symbols of each archive
+// member.
+var objdumpMemberRe = regexp.MustCompile(`^(\S+):\s+file format `)
+
+type symbolDef struct {
+	owner string
+	weak  bool
+}
+
+// Lists the global symbols defined in an archive or ELF file.  The returned
+// definitions are owned by "<pkg> (<object-file>)".
+func (b *Builder) globalSymbolDefs(file string,
+	pkgName string) (map[string][]symbolDef, error) {
+
+	c, err := b.targetBuilder.NewCompiler(b.AppElfPath(), "")
+	if err != nil {
+		return nil, err
+	}
+
+	err, out := c.ParseLibrary(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err, r := getParseRexeg()
+	if err != nil {
+		return nil, err
+	}
+
+	defs := map[string][]symbolDef{}
+	member := filepath.Base(file)
+
+	buffer := bytes.NewBuffer(out)
+	for {
+		line, err := buffer.ReadString('\n')
+		if err != nil {
+			break
+		}
+
+		if m := objdumpMemberRe.FindStringSubmatch(line); m != nil {
+			member = m[1]
+			continue
+		}
+
+		err, si := parseObjectLine(line, r)
+		if err != nil || si == nil {
+			continue
+		}
+
+		if si.IsLocal() || si.IsDebug() || si.IsFile() ||
+			si.IsSection("*UND*") || si.IsSection("*COM*") {
+
+			continue
+		}
+
+		defs[si.Name] = append(defs[si.Name], symbolDef{
+			owner: fmt.Sprintf("%s (%s)", pkgName, member),
+			weak:  si.IsWeak(),
+		})
+	}
+
+	return defs, nil
+}
+
+// Finds the global symbols that the link inputs define weakly and that are
+// also defined by a different package.
+func (b *Builder) weakSymbols(inputs []LinkInput) ([]WeakSymbol, error) {
+	// [symbol] => definitions in link order.
+	defs := map[string][]symbolDef{}
+	// [symbol] => set of defining packages.
+	defPkgs := map[string]map[string]struct{}{}
+
+	for _, in := range inputs {
+		pkgName := in.Pkg
+		if pkgName == "" {
+			pkgName = filepath.Base(in.File)
+		}
+
+		d, err := b.globalSymbolDefs(in.File, pkgName)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, ds := range d {
+			defs[name] = append(defs[name], ds...)
+			if defPkgs[name] == nil {
+				defPkgs[name] = map[string]struct{}{}
+			}
+			defPkgs[name][pkgName] = struct{}{}
+		}
+	}
+
+	var linked map[string][]symbolDef
+	if util.NodeExist(b.AppElfPath()) {
+		var err error
+		linked, err = b.globalSymbolDefs(b.AppElfPath(), "elf")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var syms []WeakSymbol
+	for name, ds := range defs {
+		if len(defPkgs[name]) < 2 {
+			continue
+		}
+
+		ws := WeakSymbol{Name: name}
+		for _, d := range ds {
+			if d.weak {
+				ws.Weak = append(ws.Weak, d.owner)
+			} else {
+				ws.Strong = append(ws.Strong, d.owner)
+			}
+		}
+		if len(ws.Weak) == 0 {
+			continue
+		}
+
+		if ld := linked[name]; len(ld) > 0 {
+			if ld[0].weak {
+				ws.Linked = "weak"
+			} else {
+				ws.Linked = "strong"
+			}
+		}
+
+		syms = append(syms, ws)
+	}
+
+	sort.Slice(syms, func(i int, j int) bool {
+		return syms[i].Name < syms[j].Name
+	})
+
+	return syms, nil
+}
+
+// LinkOrder determines the order in which each of the target's builders
+// passes archives to the linker, and finds weak symbols that one package
+// defines and another package overrides.  The target must have been built.
+func (t *TargetBuilder) LinkOrder() ([]LinkOrderInfo, error) {
+	if err := t.PrepBuild(); err != nil {
+		return nil, err
+	}
+
+	builders := []*Builder{t.LoaderBuilder, t.AppBuilder}
+	builders = append(builders, t.ExtraAppBuilders...)
+
+	var infos []LinkOrderInfo
+	for _, b := range builders {
+		if b == nil {
+			continue
+		}
+
+		inputs, err := b.linkInputs(t.extraADirs())
+		if err != nil {
+			return nil, err
+		}
+		if len(inputs) == 0 {
+			return nil, util.FmtNewtError(
+				"target %s has not been built; run \"newt build %s\" first",
+				t.target.FullName(), t.target.Name())
+		}
+
+		syms, err := b.weakSymbols(inputs)
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, LinkOrderInfo{
+			BuildName:   b.buildName,
+			Inputs:      inputs,
+			WeakSymbols: syms,
+		})
+	}
+
+	return infos, nil
+}
+
+// LinkOrderText produces a human-readable link order report.
+func LinkOrderText(infos []LinkOrderInfo) string {
+	buffer := bytes.Buffer{}
+
+	for i, info := range infos {
+		if i != 0 {
+			buffer.WriteString("\n")
+		}
+
+		fmt.Fprintf(&buffer, "%s link order:\n", info.BuildName)
+
+		width := 0
+		for _, in := range info.Inputs {
+			if len(in.Pkg) > width {
+				width = len(in.Pkg)
+			}
+		}
+
+		for j, in := range info.Inputs {
+			var notes []string
+			if in.Pin != "" {
+				notes = append(notes, "pinned "+in.Pin)
+			}
+			if in.WholeArch {
+				notes = append(notes, "whole archive")
+			}
+
+			file := strings.TrimPrefix(filepath.ToSlash(in.File),
+				ProjectRoot()+"/")
+			if in.Pkg != "" {
+				file = filepath.Base(in.File)
+			}
+
+			line := fmt.Sprintf("%4d  %-*s  %s", j+1, width, in.Pkg, file)
+			if len(notes) > 0 {
+				line += " [" + strings.Join(notes, ", ") + "]"
+			}
+			buffer.WriteString(line + "\n")
+		}
+
+		buffer.WriteString("\n")
+		if len(info.WeakSymbols) == 0 {
+			fmt.Fprintf(&buffer, "%s weak symbols: none overridden\n",
+				info.BuildName)
+			continue
+		}
+
+		fmt.Fprintf(&buffer, "%s weak symbols:\n", info.BuildName)
+		for _, ws := range info.WeakSymbols {
+			fmt.Fprintf(&buffer, "    %s\n", ws.Name)
+			for _, d := range ws.Weak {
+				fmt.Fprintf(&buffer, "        weak:   %s\n", d)
+			}
+			for _, d := range ws.Strong {
+				fmt.Fprintf(&buffer, "        strong: %s\n", d)
+			}
+
+			switch {
+			case ws.Linked == "":
+
+			case len(ws.Strong) > 0 && ws.Linked == "weak":
+				// The linker does not extract an archive member just to
+				// override a weak definition.
+				buffer.WriteString("        linked: weak (WARNING: the " +
+					"strong definition was not linked because nothing " +
+					"else pulled in its object file)\n")
+
+			case len(ws.Strong) == 0:
+				buffer.WriteString("        linked: weak (multiple weak " +
+					"definitions; the first one loaded by the linker " +
+					"is used)\n")
+
+			default:
+				fmt.Fprintf(&buffer, "        linked: %s\n", ws.Linked)
+			}
+		}
+	}
+
+	return buffer.String()
+}
@@ -23,11 +23,13 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 
 	"mynewt.apache.org/newt/newt/pkg"
 	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/newt/romload"
 	"mynewt.apache.org/newt/util"
 )
 
@@ -174,6 +176,78 @@ func Load(binBasePath string, bspPkg *pkg.BspPackage,
 	return nil
 }
 
+// Indicates whether a load should use the BSP's ROM serial bootloader rather
+// than its download script.  The ROM bootloader is used if it is requested
+// explicitly or if the BSP does not have a download script.
+func (t *TargetBuilder) useRomLoader() bool {
+	if t.bspPkg.RomLoader == "" {
+		return false
+	}
+
+	return t.romLoad || t.bspPkg.DownloadScript == ""
+}
+
+// RomLoad writes an image to a device through the BSP's ROM serial
+// bootloader.  The serial port and baud rate are taken from the device
+// selected with --device.  A bootloader is written as a raw binary after
+// erasing the chip; an app is written as a .img file to its slot.
+func RomLoad(binBasePath string, bspPkg *pkg.BspPackage,
+	env map[string]string) error {
+
+	if env["DEVICE_SERIAL_PORT"] == "" {
+		return util.FmtNewtError(
+			"BSP %s loads through its ROM bootloader (%s); specify a device "+
+				"with a serial port with --device",
+			bspPkg.FullName(), bspPkg.RomLoader)
+	}
+
+	p := romload.Params{
+		Port:     env["DEVICE_SERIAL_PORT"],
+		Args:     bspPkg.RomLoaderArgs,
+		PageSize: bspPkg.RomLoaderPageSize,
+	}
+
+	if _, ok := env["BOOT_LOADER"]; ok {
+		p.File = binBasePath + ".elf.bin"
+		p.EraseAll = true
+	} else {
+		p.File = binBasePath + ".img"
+	}
+
+	baud, err := strconv.Atoi(env["DEVICE_BAUD"])
+	if err != nil {
+		return util.FmtNewtError("invalid device baud rate: %s",
+			env["DEVICE_BAUD"])
+	}
+	p.Baud = baud
+
+	addr, err := strconv.ParseInt(env["FLASH_OFFSET"], 0, 64)
+	if err != nil {
+		return util.FmtNewtError("invalid flash offset: %s",
+			env["FLASH_OFFSET"])
+	}
+	p.Addr = int(addr)
+
+	size, err := strconv.ParseInt(env["FLASH_AREA_SIZE"], 0, 64)
+	if err != nil {
+		return util.FmtNewtError("invalid flash area size: %s",
+			env["FLASH_AREA_SIZE"])
+	}
+	p.AreaSize = int(size)
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Loading %s through the %s ROM bootloader on %s\n",
+		p.File, bspPkg.RomLoader, p.Port)
+
+	if err := romload.Load(bspPkg.RomLoader, p); err != nil {
+		return err
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Successfully loaded image.\n")
+
+	return nil
+}
+
 func (b *Builder) Load(imageSlot int, extraJtagCmd string, imgFilename string) error {
 	if b.appPkg == nil {
 		return util.NewNewtError("app package not specified")
@@ -209,6 +283,10 @@ func (b *Builder) Load(imageSlot int, extraJtagCmd string, imgFilename string) e
 	// Make sure the img override (if any) gets used.
 	env["BIN_BASENAME"] = binPath
 
+	if b.targetBuilder.useRomLoader() {
+		return RomLoad(binPath, b.targetBuilder.bspPkg, env)
+	}
+
 	if err := Load(binPath, b.targetBuilder.bspPkg, env); err != nil {
 		return err
 	}
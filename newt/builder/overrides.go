@@ -0,0 +1,106 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
+)
+
+// Returns the set of APIs that a package supplies unconditionally.
+func unconditionalApis(lpkg *pkg.LocalPackage) map[string]struct{} {
+	apis, err := lpkg.PkgY.GetValStringSlice("pkg.apis", nil)
+	util.OneTimeWarningError(err)
+
+	m := make(map[string]struct{}, len(apis))
+	for _, api := range apis {
+		m[api] = struct{}{}
+	}
+
+	return m
+}
+
+// Builds the package substitution map from the target's
+// `target.pkg_overrides` setting.  A substitute package must supply every API
+// that the package it replaces supplies unconditionally.
+func (t *TargetBuilder) pkgOverrides() (
+	map[*pkg.LocalPackage]*pkg.LocalPackage, error) {
+
+	overrides := t.target.PkgOverrides
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name, _ := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := make(map[*pkg.LocalPackage]*pkg.LocalPackage, len(overrides))
+	for _, name := range names {
+		subName := overrides[name]
+
+		orig := t.target.ResolvePackageName(name)
+		if orig == nil {
+			return nil, util.FmtNewtError(
+				"target.pkg_overrides: could not resolve package \"%s\"",
+				name)
+		}
+
+		sub := t.target.ResolvePackageName(subName)
+		if sub == nil {
+			return nil, util.FmtNewtError(
+				"target.pkg_overrides: could not resolve package \"%s\"",
+				subName)
+		}
+
+		if sub.Type() != orig.Type() {
+			return nil, util.FmtNewtError(
+				"target.pkg_overrides: cannot substitute %s for %s; "+
+					"package types differ (%s != %s)",
+				sub.FullName(), orig.FullName(),
+				pkg.PackageTypeNames[sub.Type()],
+				pkg.PackageTypeNames[orig.Type()])
+		}
+
+		subApis := unconditionalApis(sub)
+		var missing []string
+		for api, _ := range unconditionalApis(orig) {
+			if _, ok := subApis[api]; !ok {
+				missing = append(missing, api)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return nil, util.FmtNewtError(
+				"target.pkg_overrides: cannot substitute %s for %s; "+
+					"substitute does not supply API(s): %s",
+				sub.FullName(), orig.FullName(), strings.Join(missing, ", "))
+		}
+
+		m[orig] = sub
+	}
+
+	return m, nil
+}
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 
 	"mynewt.apache.org/newt/newt/interfaces"
+	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/pkg"
 	"mynewt.apache.org/newt/newt/project"
 	"mynewt.apache.org/newt/util"
@@ -35,7 +36,14 @@ func ProjectRoot() string {
 	return project.GetProject().Path()
 }
 
+// BinRoot returns the directory that all build artifacts are written to.  This
+// is the project's bin directory unless an output directory was specified
+// with --out or NEWT_OUT.
 func BinRoot() string {
+	if newtutil.NewtOutDir != "" {
+		return newtutil.NewtOutDir
+	}
+
 	return project.GetProject().Path() + "/bin"
 }
 
@@ -63,6 +71,18 @@ func SysinitArchivePath(targetName string) string {
 	return GeneratedBinDir(targetName) + "/sysinit.a"
 }
 
+func RomDir(targetName string) string {
+	return TargetBinDir(targetName) + "/rom"
+}
+
+func RomElfPath(targetName string, appName string) string {
+	return RomDir(targetName) + "/" + filepath.Base(appName) + ".rom.elf"
+}
+
+func RomSymsPath(targetName string, appName string) string {
+	return RomDir(targetName) + "/" + filepath.Base(appName) + ".rom.syms"
+}
+
 func UserBaseDir(targetName string) string {
 	return BinRoot() + "/" + targetName + "/user"
 }
@@ -166,15 +186,33 @@ func (b *Builder) FileBinDir(pkgName string) string {
 	return FileBinDir(b.targetPkg.rpkg.Lpkg.FullName(), b.buildName, pkgName)
 }
 
+// Returns the name of the build that compiles the specified package.
+func (b *Builder) pkgBuildName(bpkg *BuildPackage) string {
+	if b.isShared(bpkg) {
+		return b.sharedBuilder.buildName
+	}
+	return b.buildName
+}
+
 func (b *Builder) PkgBinDir(bpkg *BuildPackage) string {
-	return PkgBinDir(b.targetPkg.rpkg.Lpkg.FullName(), b.buildName,
+	tgtName := b.targetPkg.rpkg.Lpkg.FullName()
+
+	// An extra app compiles the generated code with its own flags, so its
+	// objects and archives are kept apart from the main app's.
+	if b.sharedBuilder != nil &&
+		bpkg.rpkg.Lpkg.Type() == pkg.PACKAGE_TYPE_GENERATED {
+
+		return GeneratedBinDir(tgtName) + "/" + b.buildName
+	}
+
+	return PkgBinDir(tgtName, b.pkgBuildName(bpkg),
 		bpkg.rpkg.Lpkg.FullName(), bpkg.rpkg.Lpkg.Type())
 }
 
 // Generates the path+filename of the specified package's .a file.
 func (b *Builder) ArchivePath(bpkg *BuildPackage) string {
-	return ArchivePath(b.targetPkg.rpkg.Lpkg.FullName(), b.buildName,
-		bpkg.rpkg.Lpkg.FullName(), bpkg.rpkg.Lpkg.Type())
+	return b.PkgBinDir(bpkg) + "/" +
+		util.FilenameFromPath(bpkg.rpkg.Lpkg.FullName()) + ".a"
 }
 
 func (b *Builder) AppTentativeElfPath() string {
@@ -210,6 +248,10 @@ func (b *Builder) AppBinPath() string {
 	return b.AppElfPath() + ".bin"
 }
 
+func (b *Builder) AppDefinesPath() string {
+	return b.AppElfPath() + ".defines"
+}
+
 func (b *Builder) AppPath() string {
 	return b.PkgBinDir(b.appPkg) + "/"
 }
@@ -0,0 +1,139 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"sort"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/repo"
+	"mynewt.apache.org/newt/util"
+)
+
+// Determines whether the specified package satisfies a version pin.  A pin is
+// either a fixed version (X.X.X) or a commit ("<commit>-commit").
+//
+// If the package declares its own version (`pkg.version`), a version pin is
+// compared against it.  Otherwise, the pin is compared against the state of
+// the package's repo.
+func pinSatisfied(lpkg *pkg.LocalPackage, pin newtutil.RepoVersion) (
+	bool, string, error) {
+
+	if pin.Commit == "" {
+		pkgVerStr, err := lpkg.PkgY.GetValString("pkg.version", nil)
+		util.OneTimeWarningError(err)
+
+		if pkgVerStr != "" {
+			pkgVer, err := newtutil.ParseRepoVersion(pkgVerStr)
+			if err != nil {
+				return false, "", util.FmtNewtError(
+					"package %s has invalid pkg.version: %s",
+					lpkg.FullName(), err.Error())
+			}
+
+			ok := pkgVer.IsNormalized() &&
+				newtutil.CompareRepoVersions(pkgVer, pin) == 0
+			return ok, pkgVer.String(), nil
+		}
+	}
+
+	r := lpkg.Repo().(*repo.Repo)
+	if r.IsLocal() {
+		return false, "", util.FmtNewtError(
+			"package %s cannot be pinned: it is in the local project repo "+
+				"and does not declare a pkg.version", lpkg.FullName())
+	}
+
+	cur, err := r.CurrentHash()
+	if err != nil {
+		return false, "", err
+	}
+
+	var want string
+	if pin.Commit != "" {
+		want, err = r.Downloader().HashFor(r.Path(), pin.Commit)
+	} else {
+		want, err = r.HashFromVer(pin)
+	}
+	if err != nil {
+		return false, cur, err
+	}
+
+	return want == cur, cur, nil
+}
+
+// Verifies that each package version pinned by the target
+// (`target.pkg_pins`) can be honored.  Pins are assertions about the packages
+// the resolver selected, not selectors: they are checked after dependency
+// resolution and never change which copy of a package is built.  Installing
+// the pinned version (e.g., with `newt upgrade`) is left to the user.
+func (t *TargetBuilder) checkPkgPins() error {
+	pins := t.target.PkgPins
+
+	names := make([]string, 0, len(pins))
+	for name, _ := range pins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pinStr := pins[name]
+
+		lpkg := t.target.ResolvePackageName(name)
+		if lpkg == nil {
+			return util.FmtNewtError(
+				"target.pkg_pins: could not resolve package \"%s\"", name)
+		}
+
+		if t.res.LpkgRpkgMap[lpkg] == nil {
+			util.OneTimeWarning(
+				"target.pkg_pins: package %s is not part of target %s",
+				lpkg.FullName(), t.target.FullName())
+			continue
+		}
+
+		pin, err := newtutil.ParseRepoVersion(pinStr)
+		if err != nil {
+			return util.FmtNewtError(
+				"target.pkg_pins: invalid pin for %s: %s",
+				lpkg.FullName(), err.Error())
+		}
+		if pin.Commit == "" && !pin.IsNormalized() {
+			return util.FmtNewtError(
+				"target.pkg_pins: pin for %s must be a fixed version "+
+					"(X.X.X) or a commit (<commit>-commit); have \"%s\"",
+				lpkg.FullName(), pinStr)
+		}
+
+		ok, cur, err := pinSatisfied(lpkg, pin)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return util.FmtNewtError(
+				"target %s pins package %s to %s, but the installed "+
+					"version is %s",
+				t.target.FullName(), lpkg.FullName(), pin.String(), cur)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/toolchain"
+	"mynewt.apache.org/newt/util"
+)
+
+// A candidate source file of a package, and whether it is compiled.
+type PkgFile struct {
+	// The file's path, relative to the package directory if it is inside it.
+	File     string
+	Compiled bool
+
+	// Why the file is not compiled; empty if it is.
+	Reason string
+}
+
+// The source files of a package in one of a target's builds.
+type PkgFiles struct {
+	BuildName string
+	Pkg       string
+	Files     []PkgFile
+
+	// The package's ignore rules that did not match any file or directory,
+	// e.g., "pkg.ignore_files: foo\.c".
+	UnmatchedRules []string
+}
+
+// Lists the package's ignore rules that have not matched anything.
+func unmatchedIgnoreRules(bpkg *BuildPackage) []string {
+	if bpkg.ci == nil {
+		return nil
+	}
+
+	var rules []string
+	for _, r := range bpkg.ci.IgnoreFiles {
+		if !r.Matched() {
+			rules = append(rules, "pkg.ignore_files: "+r.Text)
+		}
+	}
+	for _, r := range bpkg.ci.IgnoreDirs {
+		if !r.Matched() {
+			rules = append(rules, "pkg.ignore_dirs: "+r.Text)
+		}
+	}
+
+	return rules
+}
+
+// Warns about each of the package's ignore rules that does not match any of
+// its files or directories.  Such a rule is usually a typo or refers to a
+// file that has since been renamed.
+func warnUnmatchedIgnoreRules(bpkg *BuildPackage,
+	entries []toolchain.CompilerJob) {
+
+	// Directory rules are evaluated during collection; file rules are
+	// evaluated here.
+	for _, e := range entries {
+		e.Compiler.ShouldIgnoreFile(e.Filename)
+	}
+
+	for _, r := range unmatchedIgnoreRules(bpkg) {
+		util.OneTimeWarning("package %s: ignore rule \"%s\" does not match "+
+			"any file or directory", bpkg.rpkg.Lpkg.FullName(), r)
+	}
+}
+
+// Converts a source file path to the form used in the package file report.
+func pkgFilePath(bpkg *BuildPackage, path string) string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	base := filepath.ToSlash(filepath.Clean(bpkg.rpkg.Lpkg.BasePath()))
+	if strings.HasPrefix(path, base+"/") {
+		return strings.TrimPrefix(path, base+"/")
+	}
+
+	return reportPath(path)
+}
+
+// Explains why a file in one of the package's source directories was not
+// collected for compilation.
+func (b *Builder) explainUncollected(bpkg *BuildPackage,
+	c *toolchain.Compiler, srcDir string, path string) string {
+
+	arch := b.targetBuilder.bspPkg.Arch
+
+	rel, err := filepath.Rel(srcDir, path)
+	if err != nil {
+		return "not collected"
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	dirs := parts[:len(parts)-1]
+
+	for i := range dirs {
+		dir := filepath.Join(srcDir, filepath.Join(dirs[:i+1]...))
+		if r := c.IgnoreDirRule(dir); r != nil {
+			return fmt.Sprintf("directory %s matches pkg.ignore_dirs rule %s",
+				pkgFilePath(bpkg, dir), r.Text)
+		}
+	}
+
+	if len(dirs) > 0 && dirs[0] == "arch" {
+		if len(dirs) == 1 {
+			return "not in an architecture directory (arch/" + arch + ")"
+		}
+		if dirs[1] != arch {
+			return fmt.Sprintf("architecture %s; target architecture is %s",
+				dirs[1], arch)
+		}
+	} else {
+		for _, d := range dirs {
+			if d == "arch" {
+				return "nested \"arch\" directory"
+			}
+		}
+
+		ext := filepath.Ext(path)
+		if ext == ".s" || ext == ".S" {
+			return "assembly files are only compiled from arch/" + arch
+		}
+	}
+
+	return "not collected"
+}
+
+// Lists the package's candidate source files in this build: every C, C++,
+// assembly, and archive file in its source directories, plus the files
+// listed in pkg.source_files.  pj contains the package's compile jobs.
+func (b *Builder) pkgFiles(pj pkgJobs) (*PkgFiles, error) {
+	bpkg := pj.bpkg
+	entries := pj.jobs
+
+	pf := &PkgFiles{
+		BuildName: b.buildName,
+		Pkg:       bpkg.rpkg.Lpkg.FullName(),
+	}
+
+	seen := map[string]struct{}{}
+	for _, e := range entries {
+		file := filepath.ToSlash(filepath.Clean(e.Filename))
+		if _, ok := seen[file]; ok {
+			continue
+		}
+		seen[file] = struct{}{}
+
+		f := PkgFile{
+			File:     pkgFilePath(bpkg, file),
+			Compiled: true,
+		}
+		if r := e.Compiler.IgnoreFileRule(e.Filename); r != nil {
+			f.Compiled = false
+			f.Reason = "matches pkg.ignore_files rule " + r.Text
+		}
+		pf.Files = append(pf.Files, f)
+	}
+
+	srcDirs, err := bpkg.srcDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(srcDirs) > 0 {
+		c, err := b.newCompiler(bpkg, b.PkgBinDir(bpkg))
+		if err != nil {
+			return nil, err
+		}
+		c.SetPkgDir(bpkg.rpkg.Lpkg.BasePath())
+
+		for _, srcDir := range srcDirs {
+			err := filepath.Walk(srcDir,
+				func(path string, info os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+					if info.IsDir() {
+						return nil
+					}
+
+					file := filepath.ToSlash(filepath.Clean(path))
+					if _, ok := seen[file]; ok {
+						return nil
+					}
+					if _, err := c.CollectSingleEntry(file); err != nil {
+						// Not a source file.
+						return nil
+					}
+					seen[file] = struct{}{}
+
+					pf.Files = append(pf.Files, PkgFile{
+						File:   pkgFilePath(bpkg, file),
+						Reason: b.explainUncollected(bpkg, c, srcDir, path),
+					})
+					return nil
+				})
+			if err != nil {
+				return nil, util.ChildNewtError(err)
+			}
+		}
+	}
+
+	sort.Slice(pf.Files, func(i int, j int) bool {
+		return pf.Files[i].File < pf.Files[j].File
+	})
+
+	pf.UnmatchedRules = unmatchedIgnoreRules(bpkg)
+
+	return pf, nil
+}
+
+// PkgFiles lists the candidate source files of the specified package,
+// indicating which ones the target compiles and, for each of the others,
+// which rule excludes it.  The package is listed once for each build that
+// compiles it (e.g., the loader and the app of a split image).
+func (t *TargetBuilder) PkgFiles(pkgName string) ([]*PkgFiles, error) {
+	if err := t.PrepBuild(); err != nil {
+		return nil, err
+	}
+
+	builders := []*Builder{t.LoaderBuilder, t.AppBuilder}
+	builders = append(builders, t.ExtraAppBuilders...)
+
+	var pfs []*PkgFiles
+	for _, b := range builders {
+		if b == nil {
+			continue
+		}
+
+		pjs, err := b.compileJobs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pj := range pjs {
+			if pj.bpkg.rpkg.Lpkg.FullName() != pkgName {
+				continue
+			}
+
+			pf, err := b.pkgFiles(pj)
+			if err != nil {
+				return nil, err
+			}
+			pfs = append(pfs, pf)
+		}
+	}
+
+	if len(pfs) == 0 {
+		return nil, util.FmtNewtError(
+			"package %s is not part of target %s",
+			pkgName, t.target.FullName())
+	}
+
+	return pfs, nil
+}
+
+// PkgFilesText produces a human-readable package file report.
+func PkgFilesText(pfs []*PkgFiles) string {
+	buffer := bytes.Buffer{}
+
+	for i, pf := range pfs {
+		if i != 0 {
+			buffer.WriteString("\n")
+		}
+
+		name := pf.Pkg
+		if pf.BuildName != "app" {
+			name += " (" + pf.BuildName + ")"
+		}
+		fmt.Fprintf(&buffer, "%s:\n", name)
+
+		if len(pf.Files) == 0 {
+			buffer.WriteString("    (no source files)\n")
+		}
+		for _, f := range pf.Files {
+			if f.Compiled {
+				fmt.Fprintf(&buffer, "    compiled  %s\n", f.File)
+			} else {
+				fmt.Fprintf(&buffer, "    excluded  %s (%s)\n",
+					f.File, f.Reason)
+			}
+		}
+
+		if len(pf.UnmatchedRules) > 0 {
+			buffer.WriteString("Rules that match nothing:\n")
+			for _, r := range pf.UnmatchedRules {
+				fmt.Fprintf(&buffer, "    %s\n", r)
+			}
+		}
+	}
+
+	return buffer.String()
+}
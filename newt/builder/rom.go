@@ -0,0 +1,318 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/symbol"
+	"mynewt.apache.org/newt/util"
+)
+
+// The artifacts produced by `newt target rom`.
+type RomInfo struct {
+	ElfPath  string
+	SymsPath string
+
+	// Exported symbols, sorted by name.
+	Syms []symbol.SymbolInfo
+}
+
+// A symbol that is defined by both the ROM and a package linked into the app.
+type RomCollision struct {
+	Name string
+	Pkg  string
+}
+
+// Indicates whether a package's symbols belong to the image itself rather
+// than to a library that other images can link against: the app and target
+// packages and the generated code.
+func romPrivatePkg(bpkg *BuildPackage) bool {
+	switch bpkg.rpkg.Lpkg.Type() {
+	case pkg.PACKAGE_TYPE_APP, pkg.PACKAGE_TYPE_TARGET,
+		pkg.PACKAGE_TYPE_GENERATED:
+
+		return true
+	default:
+		return false
+	}
+}
+
+// Collects the global, non-weak symbols defined by a package's archive.
+func (b *Builder) pkgGlobalSyms(bpkg *BuildPackage) *symbol.SymbolMap {
+	syms := symbol.NewSymbolMap()
+
+	err, sm := b.ParseObjectLibrary(bpkg)
+	if err != nil {
+		// The package has no archive; it contains no source files.
+		return syms
+	}
+
+	for _, si := range *sm {
+		if !si.IsLocal() && !si.IsWeak() {
+			syms.Add(si)
+		}
+	}
+
+	return syms
+}
+
+// BuildRom builds the target and exports its libraries as a ROM that other
+// targets can link against (see `target.rom_elf`).  It produces a copy of the
+// app ELF stripped of all but the exported symbols, and a text file listing
+// each exported symbol.  The app package's own symbols and the generated code
+// are not exported.
+func (t *TargetBuilder) BuildRom() (*RomInfo, error) {
+	if t.appPkg == nil {
+		return nil, util.FmtNewtError(
+			"target %s does not specify an app", t.target.FullName())
+	}
+	if t.loaderPkg != nil {
+		return nil, util.FmtNewtError(
+			"cannot build a ROM from a split image target (%s)",
+			t.target.FullName())
+	}
+
+	if err := t.Build(); err != nil {
+		return nil, err
+	}
+
+	b := t.AppBuilder
+
+	err, elfSyms := b.ParseObjectElf(b.AppElfPath())
+	if err != nil {
+		return nil, err
+	}
+
+	exports := symbol.NewSymbolMap()
+	for _, bpkg := range b.sortedBuildPackages() {
+		if romPrivatePkg(bpkg) {
+			continue
+		}
+
+		for name, si := range *b.pkgGlobalSyms(bpkg) {
+			// Use the linked address; symbols the linker discarded are not
+			// in the ROM.
+			if esi, ok := (*elfSyms)[name]; ok && !esi.IsLocal() {
+				si.Loc = esi.Loc
+				exports.Add(si)
+			}
+		}
+	}
+
+	// Symbols listed in target.keep_symbols are exported even if no library
+	// defines them (e.g., linker script symbols).
+	if err := checkSymbolsDefined("target.keep_symbols",
+		t.target.KeepSymbols, elfSyms); err != nil {
+
+		return nil, err
+	}
+	for _, name := range t.target.KeepSymbols {
+		if _, ok := exports.Find(name); !ok {
+			exports.Add((*elfSyms)[name])
+		}
+	}
+
+	if len(*exports) == 0 {
+		return nil, util.FmtNewtError(
+			"target %s does not define any symbols to export",
+			t.target.FullName())
+	}
+
+	tgtName := t.target.FullName()
+	info := &RomInfo{
+		ElfPath:  RomElfPath(tgtName, t.appPkg.Name()),
+		SymsPath: RomSymsPath(tgtName, t.appPkg.Name()),
+	}
+
+	if err := os.MkdirAll(RomDir(tgtName), 0755); err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	c, err := t.NewCompiler(b.AppElfPath(), "")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.CopySymbols(b.AppElfPath(), info.ElfPath,
+		exports); err != nil {
+
+		return nil, err
+	}
+
+	for _, si := range *exports {
+		info.Syms = append(info.Syms, si)
+	}
+	sort.Slice(info.Syms, func(i int, j int) bool {
+		return info.Syms[i].Name < info.Syms[j].Name
+	})
+
+	if err := writeRomSyms(info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// Writes the list of exported symbols: one line per symbol containing its
+// address, size, kind ("F" for functions, "O" for objects), package, and
+// name.
+func writeRomSyms(info *RomInfo) error {
+	buf := bytes.Buffer{}
+
+	fmt.Fprintf(&buf, "# address\tsize\tkind\tpackage\tname\n")
+	for _, si := range info.Syms {
+		kind := "O"
+		if si.IsFunction() {
+			kind = "F"
+		}
+		fmt.Fprintf(&buf, "0x%08x\t%d\t%s\t%s\t%s\n",
+			si.Loc, si.Size, kind, si.Bpkg, si.Name)
+	}
+
+	if err := ioutil.WriteFile(info.SymsPath, buf.Bytes(), 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
+
+// Returns the path of the symbol list that accompanies a ROM ELF.
+func romSymsPathForElf(romElf string) string {
+	return strings.TrimSuffix(romElf, ".elf") + ".syms"
+}
+
+// Reads a ROM symbol list.  It returns a map of symbol name to the name of
+// the package that defines it.
+func readRomSyms(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, util.FmtNewtError(
+			"cannot read ROM symbol list: %s; the ROM must be produced "+
+				"with `newt target rom`", err.Error())
+	}
+
+	syms := map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			return nil, util.FmtNewtError(
+				"%s:%d: malformed ROM symbol entry", path, i+1)
+		}
+		syms[fields[4]] = fields[3]
+	}
+
+	return syms, nil
+}
+
+// linkAgainstRom prepares the app to link against the ROM ELF specified by
+// `target.rom_elf`.  Packages that are part of the ROM are dropped from the
+// link; their symbols resolve to the ROM's copies.  Any other package that
+// defines a symbol the ROM exports collides with the ROM; collisions are
+// reported as an error.
+func (t *TargetBuilder) linkAgainstRom() error {
+	romElf := t.target.RomElf
+	if util.NodeNotExist(romElf) {
+		return util.FmtNewtError("ROM ELF does not exist: %s", romElf)
+	}
+
+	romSyms, err := readRomSyms(romSymsPathForElf(romElf))
+	if err != nil {
+		return err
+	}
+
+	romPkgs := map[string]bool{}
+	for _, pkgName := range romSyms {
+		romPkgs[pkgName] = true
+	}
+
+	b := t.AppBuilder
+
+	inRom := map[string]bool{}
+	var collisions []RomCollision
+
+	for _, bpkg := range b.sortedBuildPackages() {
+		name := bpkg.rpkg.Lpkg.Name()
+		if !romPrivatePkg(bpkg) && romPkgs[name] {
+			inRom[name] = true
+			continue
+		}
+
+		for sym, _ := range *b.pkgGlobalSyms(bpkg) {
+			if _, ok := romSyms[sym]; ok {
+				collisions = append(collisions, RomCollision{
+					Name: sym,
+					Pkg:  bpkg.rpkg.Lpkg.FullName(),
+				})
+			}
+		}
+	}
+
+	if len(collisions) > 0 {
+		return util.NewNewtError(RomCollisionText(romElf, collisions))
+	}
+
+	names := make([]string, 0, len(inRom))
+	for name, _ := range inRom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	util.StatusMessage(util.VERBOSITY_VERBOSE,
+		"Packages provided by ROM %s: %s\n", romElf, strings.Join(names, ", "))
+
+	b.RemovePackages(inRom)
+	b.linkElf = romElf
+
+	return nil
+}
+
+// RomCollisionText describes symbols that are defined both in a ROM and in
+// the packages of an app that links against it.
+func RomCollisionText(romElf string, collisions []RomCollision) string {
+	sort.Slice(collisions, func(i int, j int) bool {
+		if collisions[i].Pkg != collisions[j].Pkg {
+			return collisions[i].Pkg < collisions[j].Pkg
+		}
+		return collisions[i].Name < collisions[j].Name
+	})
+
+	lines := []string{
+		fmt.Sprintf("%d symbol(s) defined both by ROM %s and by the app:",
+			len(collisions), romElf),
+	}
+	for _, c := range collisions {
+		lines = append(lines, fmt.Sprintf("    %s (%s)", c.Name, c.Pkg))
+	}
+	lines = append(lines,
+		"Rename the symbols, or remove the packages that define them "+
+			"from the app.")
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,482 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Stack usage of a single function, as reported by gcc's -fstack-usage.
+type frameUsage struct {
+	Size int
+
+	// True if the frame size depends on run-time values (e.g., alloca or
+	// variable length arrays) and is not known to be bounded.
+	Unbounded bool
+}
+
+// The worst-case stack depth of a task entry point.
+type StackDepth struct {
+	// The entry point function.
+	Func string
+
+	// The syscfg setting specifying the task's stack size, if any.
+	Setting string
+
+	// The task's stack size, in bytes; 0 if unknown.
+	Limit int
+
+	// The worst-case stack depth, in bytes.
+	Depth int
+
+	// The deepest call chain.
+	Path []string
+
+	// Indicates that the depth is a lower bound.  Reasons include recursion,
+	// unbounded dynamic frames, and functions without stack usage
+	// information (e.g., assembly or precompiled libraries).
+	Incomplete bool
+	Notes      []string
+}
+
+// Overflow indicates whether the entry point's worst-case stack depth exceeds
+// its configured stack size.
+func (sd *StackDepth) Overflow() bool {
+	return sd.Limit > 0 && sd.Depth > sd.Limit
+}
+
+// Matches a .su line: "file.c:12:6:func_name<tab>48<tab>static".  For C++,
+// the function name is a declaration, e.g., "int ns::foo(T) [with T = int]".
+var suLineRe = regexp.MustCompile(`^(.*):\d+:\d+:(.+)\t(\d+)\t(\S+)$`)
+
+// Matches a function label in a disassembly listing: "00001000 <func>:".
+// Demangled C++ names may contain angle brackets and spaces.
+var lstFuncRe = regexp.MustCompile(`^[0-9a-fA-F]+ <(.+)>:$`)
+
+// Matches a call instruction in a disassembly listing.  Direct branches
+// without a link are also matched so that tail calls are accounted for.
+var lstCallRe = regexp.MustCompile(
+	`^\s*[0-9a-fA-F]+:\s.*\s(bl|blx|b|b\.w|b\.n|call|callq|jal|j|jmp)\s+` +
+		`(?:\S+,\s*)?[0-9a-fA-F]+ <(.+)>\s*$`)
+
+// Matches the offset of a branch target within a function: "<func+0x1c>".
+var lstOffsetRe = regexp.MustCompile(`\+0x[0-9a-fA-F]+$`)
+
+// Reduces a function name, as it appears in a .su file or in a demangled
+// disassembly listing, to its qualified name without return type, parameters,
+// or template arguments (e.g., "int ns::foo<int>(int)" becomes "ns::foo").
+// This allows the two to be matched.  Overloads share a name, so their
+// frames are combined conservatively.  C names are returned unchanged.
+func stackFuncKey(name string) string {
+	if idx := strings.Index(name, " [with "); idx != -1 {
+		name = name[:idx]
+	}
+
+	var b strings.Builder
+	depth := 0
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '<' && !strings.HasSuffix(b.String(), "operator"):
+			depth++
+		case c == '>' && depth > 0:
+			depth--
+		case depth > 0:
+		case c == '(':
+			// The parameter list; everything after it is qualifiers.
+			i = len(name)
+		case c == ' ':
+			// Everything before a top-level space is the return type.
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	if b.Len() == 0 {
+		return name
+	}
+	return b.String()
+}
+
+// EnableStackUsage causes the target to be compiled with -fstack-usage so that
+// a stack usage report can be generated.
+func (t *TargetBuilder) EnableStackUsage() {
+	t.stackUsage = true
+}
+
+// Reads all the .su files produced by the build.
+func (b *Builder) readStackUsage() (map[string]frameUsage, error) {
+	frames := map[string]frameUsage{}
+
+	for _, bpkg := range b.sortedBuildPackages() {
+		err := filepath.Walk(b.PkgBinDir(bpkg),
+			func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					if os.IsNotExist(err) {
+						return nil
+					}
+					return err
+				}
+				if info.IsDir() || filepath.Ext(path) != ".su" {
+					return nil
+				}
+
+				data, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+
+				for _, line := range strings.Split(string(data), "\n") {
+					m := suLineRe.FindStringSubmatch(line)
+					if m == nil {
+						continue
+					}
+
+					name := stackFuncKey(m[2])
+					size, _ := strconv.Atoi(m[3])
+					unbounded := strings.Contains(m[4], "dynamic") &&
+						!strings.Contains(m[4], "bounded")
+
+					// Static functions in different files may share a name;
+					// be conservative.
+					prev := frames[name]
+					if size > prev.Size {
+						prev.Size = size
+					}
+					prev.Unbounded = prev.Unbounded || unbounded
+					frames[name] = prev
+				}
+
+				return nil
+			})
+		if err != nil {
+			return nil, util.ChildNewtError(err)
+		}
+	}
+
+	return frames, nil
+}
+
+// Disassembles the specified ELF file with demangled symbol names.
+func (b *Builder) disassemble(elfPath string) ([]byte, error) {
+	c, err := b.targetBuilder.NewCompiler("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := []string{c.GetObjdumpPath(), "-dwC", elfPath}
+	o, err := util.ShellCommandLimitDbgOutput(cmd, nil, true, 0)
+	if err != nil {
+		return nil, util.FmtNewtError(
+			"failed to disassemble %s: %s", elfPath, err.Error())
+	}
+
+	return o, nil
+}
+
+// Extracts a call graph from a disassembly listing.
+func parseCallGraph(listing []byte) (map[string][]string, error) {
+	graph := map[string]map[string]struct{}{}
+	cur := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(listing))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := lstFuncRe.FindStringSubmatch(line); m != nil {
+			cur = stackFuncKey(m[1])
+			if graph[cur] == nil {
+				graph[cur] = map[string]struct{}{}
+			}
+			continue
+		}
+
+		if cur == "" {
+			continue
+		}
+
+		// Branches within a function are not calls.
+		if m := lstCallRe.FindStringSubmatch(line); m != nil &&
+			!lstOffsetRe.MatchString(m[2]) {
+
+			callee := stackFuncKey(m[2])
+			if callee != cur {
+				graph[cur][callee] = struct{}{}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	calls := make(map[string][]string, len(graph))
+	for caller, callees := range graph {
+		for callee, _ := range callees {
+			calls[caller] = append(calls[caller], callee)
+		}
+		sort.Strings(calls[caller])
+	}
+
+	return calls, nil
+}
+
+type stackWalker struct {
+	frames map[string]frameUsage
+	calls  map[string][]string
+
+	// Memoized results: [func] => depth, path.
+	depths map[string]int
+	paths  map[string][]string
+
+	// The functions on the current call chain: [func] => position in chain.
+	active map[string]int
+	notes  map[string]struct{}
+}
+
+// Calculates the worst-case stack depth of a function.  The second return
+// value is the position in the current call chain of the outermost function
+// at which recursion was cut off while calculating the depth, or -1 if there
+// was none.  A depth that was cut off at a function further up the chain
+// depends on the path taken to reach it, so it is not memoized.
+func (w *stackWalker) depth(fn string) (int, int) {
+	if d, ok := w.depths[fn]; ok {
+		return d, -1
+	}
+
+	if pos, ok := w.active[fn]; ok {
+		w.notes["recursion through "+fn] = struct{}{}
+		return 0, pos
+	}
+	pos := len(w.active)
+	w.active[fn] = pos
+	defer delete(w.active, fn)
+
+	frame, ok := w.frames[fn]
+	if !ok {
+		w.notes["no stack usage info for "+fn] = struct{}{}
+	} else if frame.Unbounded {
+		w.notes["unbounded dynamic frame in "+fn] = struct{}{}
+	}
+
+	best := 0
+	var bestPath []string
+	cut := -1
+	for _, callee := range w.calls[fn] {
+		d, c := w.depth(callee)
+		if c != -1 && (cut == -1 || c < cut) {
+			cut = c
+		}
+		if d > best || bestPath == nil {
+			best = d
+			bestPath = w.paths[callee]
+		}
+	}
+
+	total := frame.Size + best
+	path := append([]string{fn}, bestPath...)
+
+	// Recursion back to this function is resolved here.
+	if cut >= pos {
+		cut = -1
+	}
+
+	if cut == -1 {
+		w.depths[fn] = total
+	}
+	w.paths[fn] = path
+
+	return total, cut
+}
+
+// Determines the size of the target's stack element (os_stack_t) from the
+// ELF class.
+func stackUnitSize(elfPath string) int {
+	f, err := elf.Open(elfPath)
+	if err != nil {
+		return 4
+	}
+	defer f.Close()
+
+	if f.Class == elf.ELFCLASS64 {
+		return 8
+	}
+	return 4
+}
+
+// A task entry point declared by a package.
+type stackEntry struct {
+	// The syscfg setting specifying the task's stack size.
+	Setting string
+
+	// The package that declares the entry point.
+	Pkg string
+}
+
+// Collects the task entry points declared by the packages in the build.
+// Packages declare entry points in their `pkg.yml` files, mapping each entry
+// function to the syscfg setting holding the task's stack size (in
+// os_stack_t units):
+//
+//	pkg.stack_entries:
+//	    main: OS_MAIN_STACK_SIZE
+func (b *Builder) stackEntries() map[string]stackEntry {
+	entries := map[string]stackEntry{}
+
+	for _, bpkg := range b.sortedBuildPackages() {
+		lpkg := bpkg.rpkg.Lpkg
+		settings := b.cfg.AllSettingsForLpkg(lpkg)
+
+		m, err := lpkg.PkgY.GetValStringMapString(
+			"pkg.stack_entries", settings)
+		util.OneTimeWarningError(err)
+
+		for fn, setting := range m {
+			entries[fn] = stackEntry{
+				Setting: setting,
+				Pkg:     lpkg.FullName(),
+			}
+		}
+	}
+
+	return entries
+}
+
+// StackReport computes the worst-case stack depth of each task entry point in
+// the image.  If no entry points are declared, the call graph roots (functions
+// that are not called by any other function) are reported instead.
+func (b *Builder) StackReport() ([]StackDepth, error) {
+	frames, err := b.readStackUsage()
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, util.FmtNewtError(
+			"no stack usage information found; build with -fstack-usage")
+	}
+
+	elfPath := b.AppElfPath()
+	listing, err := b.disassemble(elfPath)
+	if err != nil {
+		return nil, err
+	}
+	calls, err := parseCallGraph(listing)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := b.stackEntries()
+	for fn, e := range entries {
+		_, inFrames := frames[fn]
+		_, inCalls := calls[fn]
+		if !inFrames && !inCalls {
+			return nil, util.FmtNewtError(
+				"package %s declares unknown stack entry function \"%s\"; "+
+					"the function is not in the image", e.Pkg, fn)
+		}
+	}
+
+	if len(entries) == 0 {
+		called := map[string]struct{}{}
+		for _, callees := range calls {
+			for _, callee := range callees {
+				called[callee] = struct{}{}
+			}
+		}
+		for fn, _ := range frames {
+			if _, ok := called[fn]; !ok {
+				entries[fn] = stackEntry{}
+			}
+		}
+	}
+
+	unit := stackUnitSize(elfPath)
+
+	var report []StackDepth
+	for fn, e := range entries {
+		w := &stackWalker{
+			frames: frames,
+			calls:  calls,
+			depths: map[string]int{},
+			paths:  map[string][]string{},
+			active: map[string]int{},
+			notes:  map[string]struct{}{},
+		}
+
+		depth, _ := w.depth(fn)
+		setting := e.Setting
+		sd := StackDepth{
+			Func:    fn,
+			Setting: setting,
+			Depth:   depth,
+			Path:    w.paths[fn],
+		}
+
+		if setting != "" {
+			if entry, ok := b.cfg.Settings[setting]; ok {
+				words, err := strconv.Atoi(entry.Value)
+				if err == nil {
+					sd.Limit = words * unit
+				}
+			}
+			if sd.Limit == 0 {
+				sd.Notes = append(sd.Notes,
+					"cannot determine value of "+setting)
+			}
+		}
+
+		for note, _ := range w.notes {
+			sd.Notes = append(sd.Notes, note)
+		}
+		sort.Strings(sd.Notes)
+		sd.Incomplete = len(w.notes) > 0
+
+		report = append(report, sd)
+	}
+
+	sort.Slice(report, func(i int, j int) bool {
+		if report[i].Depth != report[j].Depth {
+			return report[i].Depth > report[j].Depth
+		}
+		return report[i].Func < report[j].Func
+	})
+
+	return report, nil
+}
+
+// StackReport computes the worst-case stack depth of each task entry point in
+// the target's application image.  The target must have been built after a
+// call to EnableStackUsage().
+func (t *TargetBuilder) StackReport() ([]StackDepth, error) {
+	return t.AppBuilder.StackReport()
+}
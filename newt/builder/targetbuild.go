@@ -20,20 +20,23 @@
 package builder
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"mynewt.apache.org/newt/newt/cfgv"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/apache/mynewt-artifact/flash"
 	"github.com/apache/mynewt-artifact/sec"
 	"mynewt.apache.org/newt/newt/flashmap"
+	"mynewt.apache.org/newt/newt/hwcfg"
 	"mynewt.apache.org/newt/newt/interfaces"
 	"mynewt.apache.org/newt/newt/pkg"
 	"mynewt.apache.org/newt/newt/project"
@@ -59,8 +62,28 @@ type TargetBuilder struct {
 	LoaderBuilder *Builder
 	LoaderList    interfaces.PackageList
 
+	// Builders for the target's extra apps, in the order of
+	// `target.ExtraApps`.
+	ExtraAppBuilders []*Builder
+
 	keyFile          string
 	injectedSettings *cfgv.Settings
+	defines          []string
+	gcReport         bool
+	stackUsage       bool
+
+	// Compiler instrumentation mode for profiling builds; "" if none.
+	instrument string
+
+	// Extra environment variables for the download and debug scripts that
+	// describe the device being accessed.
+	deviceEnv map[string]string
+
+	// Whether to load through the BSP's ROM serial bootloader.
+	romLoad bool
+
+	// Duration of the most recent build.
+	buildTime time.Duration
 
 	res *resolve.Resolution
 }
@@ -76,10 +99,7 @@ func NewTargetTester(target *target.Target,
 		return nil, err
 	}
 
-	compilerName := bspPkg.CompilerName
-
-	compilerPkg, err := project.GetProject().ResolvePackage(
-		bspPkg.Repo(), compilerName)
+	compilerPkg, err := toolchain.BspCompilerPkg(bspPkg)
 	if err != nil {
 		return nil, err
 	}
@@ -224,6 +244,24 @@ func (t *TargetBuilder) ensureResolved() error {
 		appSeeds = append(appSeeds, t.target.AppYml())
 	}
 
+	// Each extra app is resolved from the same seeds as the main app, so that
+	// the target's syscfg applies to all of them.
+	var extraSeeds map[string][]*pkg.LocalPackage
+	if t.testPkg == nil && len(t.target.ExtraApps) > 0 {
+		extraSeeds = make(map[string][]*pkg.LocalPackage,
+			len(t.target.ExtraApps))
+		for _, ea := range t.target.ExtraApps {
+			seeds := []*pkg.LocalPackage{
+				t.target.BspYml(),
+				t.compilerPkg,
+				t.target.Package(),
+			}
+			t.resolveTransientPkgs(seeds)
+
+			extraSeeds[ea.Name] = append(seeds, t.target.ExtraAppYml(ea))
+		}
+	}
+
 	if t.testPkg != nil {
 		// A few features are automatically supported when the test command is
 		// used:
@@ -236,13 +274,26 @@ func (t *TargetBuilder) ensureResolved() error {
 		appSeeds = append(appSeeds, t.testPkg)
 	}
 
-	var err error
+	if t.instrument != "" && t.target.ProfilerName != "" {
+		appSeeds = append(appSeeds, t.target.Profiler())
+	}
+
+	overrides, err := t.pkgOverrides()
+	if err != nil {
+		return err
+	}
+
 	t.res, err = resolve.ResolveFull(
-		loaderSeeds, appSeeds, t.injectedSettings, t.bspPkg.FlashMap)
+		loaderSeeds, appSeeds, extraSeeds, t.injectedSettings,
+		t.bspPkg.FlashMap, overrides)
 	if err != nil {
 		return err
 	}
 
+	if err := t.checkPkgPins(); err != nil {
+		return err
+	}
+
 	// Configure the basic set of environment variables in the current process.
 	env := BasicEnvVars("", t.bspPkg)
 	keys := make([]string, 0, len(env))
@@ -279,6 +330,8 @@ func (t *TargetBuilder) validateAndWriteCfg() error {
 		return err
 	}
 
+	util.ResetGeneratedFiles()
+
 	if errText := t.res.ErrorText(); errText != "" {
 		return util.NewNewtError(errText)
 	}
@@ -334,6 +387,21 @@ func (t *TargetBuilder) validateAndWriteCfg() error {
 		return err
 	}
 
+	// Generate extra app sysinit and sysdown.
+	for _, ea := range t.extraApps() {
+		lpkgs = resolve.RpkgSliceToLpkgSlice(t.res.ExtraSets[ea.Name].Rpkgs)
+		if err := t.res.SysinitCfg.EnsureWrittenExtraApp(lpkgs, srcDir,
+			pkg.ShortName(t.target.Package()), ea.Name); err != nil {
+
+			return err
+		}
+		if err := t.res.SysdownCfg.EnsureWrittenExtraApp(lpkgs, srcDir,
+			pkg.ShortName(t.target.Package()), ea.Name); err != nil {
+
+			return err
+		}
+	}
+
 	// Generate loader sysinit.
 	if t.res.LoaderSet != nil {
 		lpkgs = resolve.RpkgSliceToLpkgSlice(t.res.LoaderSet.Rpkgs)
@@ -364,6 +432,29 @@ func (t *TargetBuilder) validateAndWriteCfg() error {
 		return err
 	}
 
+	// Generate hardware configuration.
+	lpkgs = resolve.RpkgSliceToLpkgSlice(t.res.MasterSet.Rpkgs)
+	hc := hwcfg.Read(lpkgs, t.bspPkg, &t.res.Cfg)
+	if errText := hc.ErrorText(); errText != "" {
+		return util.NewNewtError(errText)
+	}
+
+	if hc.Present() {
+		env := BasicEnvVars("", t.bspPkg)
+		for k, v := range SettingsEnvVars(t.res.Cfg.SettingValues()) {
+			env[k] = v
+		}
+		if err := hc.EnsureWritten(incDir, srcDir,
+			pkg.ShortName(t.target.Package()), env); err != nil {
+
+			return err
+		}
+	}
+
+	if err := t.writeResolution(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -447,6 +538,36 @@ func (t *TargetBuilder) PrepBuild() error {
 
 	t.AppList = project.ResetDeps(nil)
 
+	t.ExtraAppBuilders = nil
+	for _, ea := range t.extraApps() {
+		if _, ok := t.bspPkg.FlashMap.Areas[ea.FlashArea]; !ok {
+			return util.FmtNewtError(
+				"target.extra_apps: app \"%s\" specifies unknown flash "+
+					"area \"%s\"", ea.Name, ea.FlashArea)
+		}
+
+		eb, err := NewBuilder(t, ea.Name, t.res.ExtraSets[ea.Name].Rpkgs,
+			t.res.ApiMap, t.res.Cfg)
+		if err != nil {
+			return err
+		}
+		// Packages that the extra app has in common with the main app are
+		// compiled once, by the main app's builder.  They see only the main
+		// app's flags: neither the extra app's `pkg.cflags` nor the
+		// EXTRA_APP macros below apply to them.
+		eb.sharedBuilder = t.AppBuilder
+		if err := eb.PrepBuild(); err != nil {
+			return err
+		}
+
+		extraFlags := toolchain.NewCompilerInfo()
+		extraFlags.Cflags = append(extraFlags.Cflags, "-DEXTRA_APP",
+			"-DEXTRA_APP_"+strings.ToUpper(util.CIdentifier(ea.Name)))
+		eb.AddCompilerInfo(extraFlags)
+
+		t.ExtraAppBuilders = append(t.ExtraAppBuilders, eb)
+	}
+
 	logDepInfo(t.res)
 
 	return nil
@@ -469,10 +590,6 @@ func (t *TargetBuilder) buildLoader() error {
 		return err
 	}
 
-	if err := t.LoaderBuilder.Build(); err != nil {
-		return err
-	}
-
 	/* Tentatively link the loader */
 	if err := t.LoaderBuilder.TentativeLink(t.bspPkg.LinkerScripts,
 		t.extraADirs()); err != nil {
@@ -514,6 +631,7 @@ func (t *TargetBuilder) buildLoader() error {
 /// To extract a PEM public key from the private key:
 ///   `openssl ec -in ec_pk.pem -pubout -out pubkey.pub`
 ///   `openssl rsa -in rsa_pk.pem -RSAPublicKey_out -out pubkey.pub`
+///   `openssl pkey -in ed25519_pk.pem -pubout -out pubkey.pub`
 func (t *TargetBuilder) autogenKeys() error {
 	keyBytes, err := ioutil.ReadFile(t.keyFile)
 	if err != nil {
@@ -538,8 +656,7 @@ func (t *TargetBuilder) autogenKeys() error {
 
 	srcDir := GeneratedSrcDir(t.target.FullName())
 
-	f, _ := os.Create(srcDir + "/pubkey-autogen.c")
-	w := bufio.NewWriter(f)
+	w := &bytes.Buffer{}
 
 	fmt.Fprintln(w, "/* Autogenerated, do not edit. */")
 	fmt.Fprintln(w, "#include <bootutil/sign_key.h>")
@@ -561,12 +678,90 @@ func (t *TargetBuilder) autogenKeys() error {
 	fmt.Fprintln(w, "    },")
 	fmt.Fprintln(w, "};")
 	fmt.Fprintln(w, "const int bootutil_key_cnt = 1;")
-	w.Flush()
+
+	return util.WriteGeneratedFile(srcDir+"/pubkey-autogen.c", w.Bytes())
+}
+
+// Emits a progress event for a stage of the target build.
+func (t *TargetBuilder) buildProgress(phase string, percent int) {
+	util.Progress(util.ProgressEvent{
+		Op:      "build",
+		Phase:   phase,
+		Package: t.target.FullName(),
+		Percent: percent,
+	})
+}
+
+// Compiles the source files of the app, the loader, and the extra apps.  The
+// jobs of all builders share one pool of workers, so that the -j limit applies
+// across the whole target rather than to each image in turn.
+func (t *TargetBuilder) compile() error {
+	var bjs []builderJobs
+	addBuilder := func(b *Builder) error {
+		b.CleanArtifacts()
+
+		pjs, err := b.compileJobs()
+		if err != nil {
+			return err
+		}
+
+		bjs = append(bjs, builderJobs{b, pjs})
+		return nil
+	}
+
+	if err := addBuilder(t.AppBuilder); err != nil {
+		return err
+	}
+
+	if t.LoaderBuilder != nil {
+		// The loader's architecture-specific sources are selected with the
+		// BSP settings of the loader.
+		err := t.bspPkg.Reload(t.LoaderBuilder.cfg.SettingValues())
+		if err != nil {
+			return err
+		}
+
+		if err := addBuilder(t.LoaderBuilder); err != nil {
+			return err
+		}
+
+		err = t.bspPkg.Reload(t.AppBuilder.cfg.SettingValues())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, eb := range t.ExtraAppBuilders {
+		if err := addBuilder(eb); err != nil {
+			return err
+		}
+	}
+
+	if err := runCompileJobs(bjs); err != nil {
+		return err
+	}
+
+	for _, bj := range bjs {
+		if err := bj.b.finishCompile(bj.pkgs); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
 func (t *TargetBuilder) Build() error {
+	start := time.Now()
+	defer func() {
+		t.buildTime = time.Since(start)
+	}()
+
+	// If the build is interrupted, stop the compiler processes and remove
+	// partially written outputs.
+	defer util.TrapInterrupts()()
+
+	t.buildProgress("prep", 0)
+
 	if err := t.PrepBuild(); err != nil {
 		return err
 	}
@@ -593,20 +788,38 @@ func (t *TargetBuilder) Build() error {
 		os.RemoveAll(workDir)
 	}()
 
-	t.generateLinkTables()
+	if err := t.generateLinkTables(); err != nil {
+		return err
+	}
+
+	if err := t.generateLinkKeep(); err != nil {
+		return err
+	}
+
+	// Remove generated files left over from a previous configuration so that
+	// they do not get compiled into this build.
+	if err := t.pruneGenerated(); err != nil {
+		return err
+	}
 
 	// Execute the set of pre-build user scripts.
 	if err := t.execPreBuildCmds(workDir); err != nil {
 		return err
 	}
 
-	if err := t.AppBuilder.Build(); err != nil {
+	if err := t.compile(); err != nil {
 		return err
 	}
 
 	var linkerScripts []string
 	if t.LoaderBuilder == nil {
 		linkerScripts = t.bspPkg.LinkerScripts
+
+		if t.target.RomElf != "" && t.testPkg == nil {
+			if err := t.linkAgainstRom(); err != nil {
+				return err
+			}
+		}
 	} else {
 		if err := t.buildLoader(); err != nil {
 			return err
@@ -620,15 +833,47 @@ func (t *TargetBuilder) Build() error {
 	}
 
 	/* Link the app. */
+	t.buildProgress("link", 100)
 	if err := t.AppBuilder.Link(linkerScripts, t.extraADirs()); err != nil {
 		return err
 	}
 
+	// Link the extra apps.  The libraries they share with the main app are
+	// taken from the main app's build.
+	for i, ea := range t.extraApps() {
+		eb := t.ExtraAppBuilders[i]
+		if err := eb.Link(ea.LinkerScripts, t.extraADirs()); err != nil {
+			return err
+		}
+	}
+
+	if err := t.writeCompileCommands(); err != nil {
+		return err
+	}
+
+	if t.AppBuilder.appPkg != nil {
+		if err := t.writeDefines(); err != nil {
+			return err
+		}
+	}
+
+	if err := toolchain.FinishObjCache(); err != nil {
+		return err
+	}
+
 	// Execute the set of post-build user scripts.
 	if err := t.execPostLinkCmds(workDir); err != nil {
 		return err
 	}
 
+	t.buildProgress("done", 100)
+
+	// Report deprecated packages after the build output so that they are not
+	// buried in it.
+	for _, line := range t.res.PkgDeprecatedWarning() {
+		log.Warn(line)
+	}
+
 	return nil
 }
 
@@ -672,6 +917,18 @@ func (t *TargetBuilder) RelinkLoader() (error, map[string]bool,
 		return err, nil, nil
 	}
 
+	/* symbols named in target.yml must be defined by the loader */
+	if err := checkSymbolsDefined("target.keep_symbols",
+		t.target.KeepSymbols, loaderLibSym, loaderElfSym); err != nil {
+
+		return err, nil, nil
+	}
+	if err := checkSymbolsDefined("target.rename_symbols",
+		t.target.RenameSymbols, loaderLibSym, loaderElfSym); err != nil {
+
+		return err, nil, nil
+	}
+
 	/* create the set of matching and non-matching symbols */
 	err, smMatch, smNomatch := symbol.IdenticalUnion(appLibSym,
 		loaderLibSym, true, false)
@@ -759,6 +1016,42 @@ func (t *TargetBuilder) RelinkLoader() (error, map[string]bool,
 	return err, commonPkgs, smMatch
 }
 
+// checkSymbolsDefined verifies that each symbol listed by a target setting is
+// defined in at least one of the specified symbol tables.
+func checkSymbolsDefined(setting string, names []string,
+	tables ...*symbol.SymbolMap) error {
+
+	var missing []string
+	for _, name := range names {
+		found := false
+		for _, sm := range tables {
+			if _, ok := sm.Find(name); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return util.FmtNewtError("%s: undefined symbol(s): %s",
+			setting, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// extraApps returns the target's extra apps, or nil if the target is being
+// built as a unit test.
+func (t *TargetBuilder) extraApps() []target.ExtraApp {
+	if t.testPkg != nil {
+		return nil
+	}
+	return t.target.ExtraApps
+}
+
 func (t *TargetBuilder) GetTarget() *target.Target {
 	return t.target
 }
@@ -771,9 +1064,88 @@ func (t *TargetBuilder) InjectSetting(key string, value string) {
 	t.injectedSettings.Set(key, value)
 }
 
-// Calculates the size of a single boot trailer.  This is the amount of flash
-// that must be reserved at the end of each image slot.
-func (t *TargetBuilder) bootTrailerSize() int {
+var defineNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SetDeviceEnv specifies environment variables that identify the device to
+// the BSP's download and debug scripts.
+func (t *TargetBuilder) SetDeviceEnv(env map[string]string) {
+	t.deviceEnv = env
+}
+
+// SetRomLoad specifies whether images are loaded through the BSP's ROM
+// serial bootloader instead of its download script.
+func (t *TargetBuilder) SetRomLoad(romLoad bool) {
+	t.romLoad = romLoad
+}
+
+// AddDefine adds a preprocessor definition to the command line of every
+// source file in the target.  `def` has the form "NAME" or "NAME=VALUE".
+// Definitions are part of each compile command, so changing them causes the
+// affected files to be rebuilt.
+func (t *TargetBuilder) AddDefine(def string) error {
+	name := strings.SplitN(def, "=", 2)[0]
+	if !defineNameRe.MatchString(name) {
+		return util.FmtNewtError(
+			"invalid preprocessor definition: \"%s\"", def)
+	}
+
+	t.defines = append(t.defines, def)
+	return nil
+}
+
+// Defines retrieves the ad-hoc preprocessor definitions added with
+// AddDefine().
+func (t *TargetBuilder) Defines() []string {
+	return t.defines
+}
+
+// Records the ad-hoc preprocessor definitions that the app was built with
+// beside its .elf file, so that commands that do not build the target (e.g.,
+// `newt create-image`) can determine them.  The file is removed if the build
+// does not use any definitions.
+func (t *TargetBuilder) writeDefines() error {
+	path := t.AppBuilder.AppDefinesPath()
+
+	if len(t.defines) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return util.ChildNewtError(err)
+		}
+		return nil
+	}
+
+	content := strings.Join(t.defines, "\n") + "\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
+
+// BuiltDefines retrieves the ad-hoc preprocessor definitions that the app was
+// most recently built with.  Unlike Defines(), the result does not depend on
+// the options of the current command.
+func (t *TargetBuilder) BuiltDefines() ([]string, error) {
+	content, err := ioutil.ReadFile(t.AppBuilder.AppDefinesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, util.ChildNewtError(err)
+	}
+
+	var defs []string
+	for _, def := range strings.Split(string(content), "\n") {
+		if def != "" {
+			defs = append(defs, def)
+		}
+	}
+
+	return defs, nil
+}
+
+// BootTrailerSize calculates the size of a single boot trailer.  This is the
+// amount of flash that must be reserved at the end of each image slot.
+func (t *TargetBuilder) BootTrailerSize() int {
 	var minWriteSz int
 
 	entry, ok := t.res.Cfg.Settings["MCU_FLASH_MIN_WRITE_SIZE"]
@@ -827,7 +1199,7 @@ func (t *TargetBuilder) bootTrailerSize() int {
 func (t *TargetBuilder) MaxImgSizes() []int {
 	sz0 := t.bspPkg.FlashMap.Areas[flash.FLASH_AREA_NAME_IMAGE_0].Size
 	sz1 := t.bspPkg.FlashMap.Areas[flash.FLASH_AREA_NAME_IMAGE_1].Size
-	trailerSz := t.bootTrailerSize()
+	trailerSz := t.BootTrailerSize()
 
 	return []int{
 		sz0 - trailerSz,
@@ -0,0 +1,149 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/target"
+	"mynewt.apache.org/newt/util"
+)
+
+// Name of the file, within a target package, containing the target's
+// compiler warning baseline.
+const WARN_BASELINE_FILENAME = "warnings.txt"
+
+func WarnBaselinePath(t *target.Target) string {
+	return t.Package().BasePath() + "/" + WARN_BASELINE_FILENAME
+}
+
+// Warnings returns the compiler warnings generated by the most recent build.
+func (b *Builder) Warnings() []string {
+	return b.warnings
+}
+
+// Warnings returns the sorted list of compiler warnings generated by the most
+// recent build of the app and, for split images, the loader.  A warning that
+// occurs more than once is listed once per occurrence.
+func (t *TargetBuilder) Warnings() []string {
+	var warnings []string
+
+	for _, b := range []*Builder{t.AppBuilder, t.LoaderBuilder} {
+		if b != nil {
+			warnings = append(warnings, b.Warnings()...)
+		}
+	}
+	sort.Strings(warnings)
+
+	return warnings
+}
+
+// Reads a warning baseline file.  The returned map contains the number of
+// times each warning occurs in the baseline.
+func readWarnBaseline(path string) (map[string]int, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline := map[string]int{}
+	for _, w := range strings.Split(string(content), "\n") {
+		if w != "" {
+			baseline[w]++
+		}
+	}
+
+	return baseline, nil
+}
+
+func writeWarnBaseline(path string, warnings []string) error {
+	content := strings.Join(warnings, "\n")
+	if len(warnings) > 0 {
+		content += "\n"
+	}
+
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
+
+// WarnRatchet compares the warnings generated by the most recent build against
+// the target's warning baseline.  If the target does not have a baseline yet,
+// or if `record` is true, the current set of warnings becomes the baseline.
+// Otherwise, the build fails if it produced any warnings that are not in the
+// baseline, or if it produced a warning more times than the baseline records.
+// Warnings that have been fixed are removed from the baseline so that they
+// cannot be reintroduced.
+func (t *TargetBuilder) WarnRatchet(record bool) error {
+	path := WarnBaselinePath(t.target)
+	cur := t.Warnings()
+
+	baseline, err := readWarnBaseline(path)
+	if err != nil && !os.IsNotExist(err) {
+		return util.ChildNewtError(err)
+	}
+
+	if baseline == nil || record {
+		if err := writeWarnBaseline(path, cur); err != nil {
+			return err
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Recorded %d warning(s) in baseline %s\n", len(cur), path)
+		return nil
+	}
+
+	// Each occurrence of a warning in the current build uses up one of its
+	// occurrences in the baseline.  Occurrences beyond those in the baseline
+	// are new; baseline occurrences left over have been fixed.
+	var added []string
+	for _, w := range cur {
+		if baseline[w] > 0 {
+			baseline[w]--
+		} else {
+			added = append(added, w)
+		}
+	}
+
+	if len(added) > 0 {
+		return util.FmtNewtError(
+			"Build introduced %d new warning(s) not present in baseline %s:"+
+				"\n    %s", len(added), path, strings.Join(added, "\n    "))
+	}
+
+	fixed := 0
+	for _, n := range baseline {
+		fixed += n
+	}
+
+	if fixed > 0 {
+		if err := writeWarnBaseline(path, cur); err != nil {
+			return err
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"%d warning(s) fixed; baseline %s updated\n", fixed, path)
+	}
+
+	return nil
+}
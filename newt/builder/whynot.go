@@ -0,0 +1,283 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Describes why an archive member was pulled into the link.
+type memberRef struct {
+	// The object that referenced the member.
+	File string
+
+	// The symbol that the object referenced.
+	Symbol string
+}
+
+// Linker cross-reference information extracted from a map file.
+type LinkXref struct {
+	// [archive-member] => reason for inclusion.
+	Members map[string]memberRef
+
+	// [symbol] => files that mention the symbol.  The first file is the one
+	// that defines it; the remainder reference it.
+	Symbols map[string][]string
+}
+
+// Matches "<file> (<symbol>)".
+var memberRefRe = regexp.MustCompile(`^(\S+)\s+\((.+)\)$`)
+
+// ParseMapFileXref reads the "Archive member included" section and the cross
+// reference table (emitted with `-Wl,--cref`) from a linker map file.
+func ParseMapFileXref(fileName string) (*LinkXref, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, util.NewNewtError("Mapfile failed: " + err.Error())
+	}
+	defer file.Close()
+
+	const (
+		stateNone = iota
+		stateMembers
+		stateCref
+	)
+
+	xref := &LinkXref{
+		Members: map[string]memberRef{},
+		Symbols: map[string][]string{},
+	}
+
+	state := stateNone
+	member := ""
+	symbol := ""
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		indented := line != "" && (line[0] == ' ' || line[0] == '\t')
+
+		switch {
+		case strings.HasPrefix(line, "Archive member included"):
+			state = stateMembers
+			continue
+		case strings.HasPrefix(line, "Cross Reference Table"):
+			state = stateCref
+			continue
+		case !indented && trimmed != "" &&
+			(strings.HasPrefix(line, "Discarded input sections") ||
+				strings.HasPrefix(line, "Allocating common symbols") ||
+				strings.HasPrefix(line, "Memory Configuration") ||
+				strings.HasPrefix(line, "Linker script and memory map")):
+
+			state = stateNone
+			continue
+		}
+
+		if trimmed == "" {
+			continue
+		}
+
+		switch state {
+		case stateMembers:
+			fields := strings.Fields(trimmed)
+			if !indented {
+				member = fields[0]
+				trimmed = strings.TrimSpace(
+					strings.TrimPrefix(trimmed, member))
+				if trimmed == "" {
+					continue
+				}
+			}
+			if m := memberRefRe.FindStringSubmatch(trimmed); m != nil {
+				xref.Members[member] = memberRef{File: m[1], Symbol: m[2]}
+			}
+
+		case stateCref:
+			fields := strings.Fields(trimmed)
+			if !indented {
+				if fields[0] == "Symbol" {
+					// Table header.
+					continue
+				}
+				symbol = fields[0]
+				fields = fields[1:]
+			}
+			for _, f := range fields {
+				xref.Symbols[symbol] = append(xref.Symbols[symbol], f)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	return xref, nil
+}
+
+// Splits "path/lib.a(obj.o)" into its archive and member components.  For a
+// plain object file, the archive component is empty.
+func splitMember(file string) (string, string) {
+	open := strings.LastIndex(file, "(")
+	if open == -1 || !strings.HasSuffix(file, ")") {
+		return "", file
+	}
+
+	return file[:open], file[open+1 : len(file)-1]
+}
+
+// Produces a human readable name for a link input, including the name of the
+// package it belongs to.
+func (b *Builder) describeLinkInput(file string) string {
+	ar, obj := splitMember(file)
+	if ar == "" {
+		return file
+	}
+
+	return fmt.Sprintf("%s (%s)", obj, b.FindPkgNameByArName(ar))
+}
+
+// Finds the link inputs that correspond to the specified object name.  The
+// name may be a full "lib.a(obj.o)" string or just the object filename.
+func (xref *LinkXref) findMembers(name string) []string {
+	var matches []string
+	for member, _ := range xref.Members {
+		_, obj := splitMember(member)
+		if member == name || obj == name ||
+			filepath.Base(member) == name {
+
+			matches = append(matches, member)
+		}
+	}
+
+	sort.Strings(matches)
+	return matches
+}
+
+// Prints the chain of references that caused an archive member to be linked
+// in, starting with the member itself.
+func (b *Builder) printMemberChain(xref *LinkXref, member string) {
+	seen := map[string]struct{}{}
+	indent := "    "
+
+	cur := member
+	for {
+		if _, ok := seen[cur]; ok {
+			break
+		}
+		seen[cur] = struct{}{}
+
+		ref, ok := xref.Members[cur]
+		if !ok {
+			// Not pulled from an archive; it is a direct link input.
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"%s%s is a direct link input\n", indent,
+				b.describeLinkInput(cur))
+			break
+		}
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"%s%s is included because %s references %s\n", indent,
+			b.describeLinkInput(cur), b.describeLinkInput(ref.File),
+			ref.Symbol)
+
+		cur = ref.File
+		indent += "  "
+	}
+}
+
+// WhyNot explains why the specified symbol or object file is part of the
+// linked image.
+func (b *Builder) WhyNot(name string) error {
+	mapFile := b.AppElfPath() + ".map"
+	if util.NodeNotExist(mapFile) {
+		return util.FmtNewtError(
+			"map file %s does not exist; build the target with "+
+				"compiler.ld.mapfile enabled", mapFile)
+	}
+
+	xref, err := ParseMapFileXref(mapFile)
+	if err != nil {
+		return err
+	}
+
+	found := false
+
+	if files := xref.Symbols[name]; len(files) > 0 {
+		found = true
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Symbol %s is defined in %s\n", name,
+			b.describeLinkInput(files[0]))
+		if len(files) == 1 {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"    no other objects reference it\n")
+		} else {
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "Referenced by:\n")
+			for _, f := range files[1:] {
+				util.StatusMessage(util.VERBOSITY_DEFAULT, "    %s\n",
+					b.describeLinkInput(f))
+			}
+		}
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "Inclusion chain:\n")
+		b.printMemberChain(xref, files[0])
+	} else if len(xref.Symbols) == 0 {
+		util.StatusMessage(util.VERBOSITY_QUIET,
+			"Warning: map file contains no cross reference table; "+
+				"relink with -Wl,--cref for symbol lookups\n")
+	}
+
+	for _, member := range xref.findMembers(name) {
+		found = true
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "Object %s:\n",
+			b.describeLinkInput(member))
+		b.printMemberChain(xref, member)
+	}
+
+	if !found {
+		return util.FmtNewtError(
+			"%s is not a symbol or object in the %s image",
+			name, b.buildName)
+	}
+
+	return nil
+}
+
+// WhyNot explains why the specified symbol or object file is part of the
+// target's application image.
+func (t *TargetBuilder) WhyNot(name string) error {
+	if err := t.PrepBuild(); err != nil {
+		return err
+	}
+
+	return t.AppBuilder.WhyNot(name)
+}
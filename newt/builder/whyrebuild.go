@@ -0,0 +1,205 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package builder
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/toolchain"
+	"mynewt.apache.org/newt/util"
+)
+
+// A source file that a builder compiles, and the reason that the next build
+// will recompile it.
+type RebuildInfo struct {
+	BuildName string
+	Pkg       string
+	File      string
+
+	// nil if the file is up to date.
+	Reason *toolchain.RebuildReason
+}
+
+// Determines which of the builder's source files the next build will
+// recompile, and why.
+func (b *Builder) rebuildInfo() ([]RebuildInfo, error) {
+	pjs, err := b.compileJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []RebuildInfo
+	for _, pj := range pjs {
+		bpkg := pj.bpkg
+		for _, e := range pj.jobs {
+			if e.CompilerType == toolchain.COMPILER_TYPE_ARCHIVE {
+				continue
+			}
+
+			r, err := e.Compiler.RebuildReason(e.Filename, e.CompilerType)
+			if err != nil {
+				return nil, err
+			}
+
+			relPath := strings.TrimPrefix(filepath.ToSlash(e.Filename),
+				ProjectRoot()+"/")
+
+			infos = append(infos, RebuildInfo{
+				BuildName: b.buildName,
+				Pkg:       bpkg.rpkg.Lpkg.FullName(),
+				File:      relPath,
+				Reason:    r,
+			})
+		}
+	}
+
+	return infos, nil
+}
+
+// RebuildInfo determines which of the target's source files the next build
+// will recompile, and why.  The target's generated files are brought up to
+// date first, as they would be by a build, but nothing is compiled.
+func (t *TargetBuilder) RebuildInfo() ([]RebuildInfo, error) {
+	if err := t.PrepBuild(); err != nil {
+		return nil, err
+	}
+
+	if err := t.bspPkg.Reload(t.AppBuilder.cfg.SettingValues()); err != nil {
+		return nil, err
+	}
+
+	builders := []*Builder{t.LoaderBuilder, t.AppBuilder}
+	builders = append(builders, t.ExtraAppBuilders...)
+
+	var infos []RebuildInfo
+	for _, b := range builders {
+		if b == nil {
+			continue
+		}
+
+		bi, err := b.rebuildInfo()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, bi...)
+	}
+
+	return infos, nil
+}
+
+func printRebuildReason(info RebuildInfo) {
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "    %s (%s): %s\n",
+		info.File, info.Pkg, info.Reason.String())
+
+	for _, arg := range info.Reason.Added {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "        + %s\n", arg)
+	}
+	for _, arg := range info.Reason.Removed {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "        - %s\n", arg)
+	}
+}
+
+// WhyRebuild explains, for each source file that the next build of the target
+// will recompile, which dependency triggered the recompile.  If a file is
+// specified, only that file is explained.  The file can be specified by its
+// path relative to the project or by a path suffix (e.g., "src/main.c").
+func (t *TargetBuilder) WhyRebuild(file string) error {
+	infos, err := t.RebuildInfo()
+	if err != nil {
+		return err
+	}
+
+	if file != "" {
+		file = filepath.ToSlash(filepath.Clean(file))
+
+		found := false
+		for _, info := range infos {
+			if info.File != file && !strings.HasSuffix(info.File, "/"+file) {
+				continue
+			}
+
+			found = true
+			if info.Reason == nil {
+				util.StatusMessage(util.VERBOSITY_DEFAULT,
+					"%s (%s): up to date\n", info.File, info.BuildName)
+			} else {
+				util.StatusMessage(util.VERBOSITY_DEFAULT, "%s:\n",
+					info.BuildName)
+				printRebuildReason(info)
+			}
+		}
+
+		if !found {
+			return util.FmtNewtError(
+				"target %s does not compile \"%s\"", t.target.FullName(), file)
+		}
+
+		return nil
+	}
+
+	// [reason] => number of files.
+	summary := map[string]int{}
+	numRebuild := 0
+	buildName := ""
+
+	for _, info := range infos {
+		if info.Reason == nil {
+			continue
+		}
+
+		if info.BuildName != buildName {
+			buildName = info.BuildName
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "%s:\n", buildName)
+		}
+		printRebuildReason(info)
+
+		summary[info.Reason.String()]++
+		numRebuild++
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"%d of %d source file(s) will be recompiled\n",
+		numRebuild, len(infos))
+
+	if numRebuild == 0 {
+		return nil
+	}
+
+	reasons := make([]string, 0, len(summary))
+	for r, _ := range summary {
+		reasons = append(reasons, r)
+	}
+	sort.Slice(reasons, func(i int, j int) bool {
+		if summary[reasons[i]] != summary[reasons[j]] {
+			return summary[reasons[i]] > summary[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "\nSummary:\n")
+	for _, r := range reasons {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%6d  %s\n",
+			summary[r], r)
+	}
+
+	return nil
+}
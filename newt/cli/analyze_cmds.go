@@ -0,0 +1,109 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/analyze"
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/util"
+)
+
+var analyzeTool string
+var analyzeFormat string
+var analyzeOutput string
+
+func analyzeRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target"))
+	}
+
+	proj := TryGetProject()
+
+	t := ResolveTarget(args[0])
+	if t == nil {
+		NewtUsage(cmd, util.NewNewtError("Invalid target name: "+args[0]))
+	}
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	units, err := b.SourceUnits()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	findings, err := analyze.Run(analyzeTool, units, proj.Path())
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	out, err := analyze.Format(analyzeFormat, analyzeTool, findings)
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	if analyzeOutput != "" {
+		if err := ioutil.WriteFile(analyzeOutput, out, 0644); err != nil {
+			NewtUsage(nil, util.ChildNewtError(err))
+		}
+	} else {
+		util.StatusMessage(util.VERBOSITY_QUIET, "%s", string(out))
+	}
+
+	if len(findings) > 0 {
+		NewtUsage(nil, util.FmtNewtError(
+			"%s reported %d finding(s)", analyzeTool, len(findings)))
+	}
+}
+
+func AddAnalyzeCommands(cmd *cobra.Command) {
+	analyzeHelpText := "Run a static analyzer over the C and C++ source " +
+		"files of the specified target.  Each file is analyzed with the " +
+		"include paths and definitions that the build uses for it.\n\n" +
+		"Findings can be suppressed per package by listing check names " +
+		"(globs permitted) under `pkg.analyze_suppress` in the package's " +
+		"pkg.yml.  The command fails if any unsuppressed findings remain."
+
+	analyzeCmd := &cobra.Command{
+		Use:   "analyze <target-name>",
+		Short: "Run a static analyzer over a target's source files",
+		Long:  analyzeHelpText,
+		Run:   analyzeRunCmd,
+	}
+
+	analyzeCmd.Flags().StringVarP(&analyzeTool, "tool", "t",
+		analyze.TOOL_CLANG_TIDY,
+		"Analyzer to run ("+strings.Join(analyze.Tools, ", ")+")")
+	analyzeCmd.Flags().StringVarP(&analyzeFormat, "format", "f",
+		analyze.FORMAT_TEXT,
+		"Output format ("+strings.Join(analyze.Formats, ", ")+")")
+	analyzeCmd.Flags().StringVarP(&analyzeOutput, "report", "", "",
+		"Write findings to the specified file instead of stdout")
+
+	cmd.AddCommand(analyzeCmd)
+	AddTabCompleteFn(analyzeCmd, targetList)
+}
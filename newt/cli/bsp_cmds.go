@@ -0,0 +1,205 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"path"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/bspgen"
+	"mynewt.apache.org/newt/newt/interfaces"
+	"mynewt.apache.org/newt/util"
+)
+
+const bspDfltArch = "cortex_m4"
+const bspDfltCompiler = "@apache-mynewt-core/compiler/arm-none-eabi-m4"
+
+// Returns the value of a `bsp new` flag if it was specified; otherwise, asks
+// the user.
+func bspAsk(cmd *cobra.Command, flag string, question string,
+	dflt string) string {
+
+	if cmd.Flags().Changed(flag) {
+		val, _ := cmd.Flags().GetString(flag)
+		return val
+	}
+
+	return PromptString(question, dflt)
+}
+
+// Parses a memory size or address.  Sizes may have a "kB" or "MB" suffix.
+func parseBspNumber(what string, s string) int {
+	lower := strings.ToLower(s)
+
+	mult := 1
+	for _, sfx := range []string{"kb", "mb"} {
+		if strings.HasSuffix(lower, sfx) {
+			lower = strings.TrimSuffix(lower, sfx)
+			if sfx == "kb" {
+				mult = 1024
+			} else {
+				mult = 1024 * 1024
+			}
+		}
+	}
+
+	n, err := util.AtoiNoOct(lower)
+	if err != nil {
+		NewtUsage(nil, util.FmtNewtError("invalid %s: \"%s\"", what, s))
+	}
+
+	return n * mult
+}
+
+func bspNewRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify a BSP package name"))
+	}
+
+	proj := TryGetProject()
+	interfaces.SetProject(proj)
+
+	cfg := bspgen.Config{
+		Name: path.Clean(args[0]),
+	}
+
+	var info bspgen.McuInfo
+	mcuStr := bspAsk(cmd, "mcu", "MCU family or package", "")
+	if mcuStr != "" {
+		mcus := bspgen.FindMcus(proj, mcuStr)
+		switch len(mcus) {
+		case 0:
+			util.OneTimeWarning("no MCU package matches \"%s\"; the BSP "+
+				"will not depend on an MCU package", mcuStr)
+
+		case 1:
+			info = bspgen.InspectMcu(proj, mcus[0])
+			cfg.Mcu = mcus[0]
+			cfg.McuLinkerScripts = info.LinkerScripts
+
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"Using MCU package %s\n", cfg.Mcu.FullName())
+			if info.Bsp != nil {
+				util.StatusMessage(util.VERBOSITY_DEFAULT,
+					"Using settings from BSP %s\n", info.Bsp.FullName())
+			}
+
+		default:
+			names := make([]string, len(mcus))
+			for i, m := range mcus {
+				names[i] = "    " + m.FullName()
+			}
+			NewtUsage(nil, util.FmtNewtError(
+				"\"%s\" matches several MCU packages:\n%s",
+				mcuStr, strings.Join(names, "\n")))
+		}
+	}
+
+	dfltArch := info.Arch
+	if dfltArch == "" {
+		dfltArch = bspDfltArch
+	}
+	dfltCompiler := info.Compiler
+	if dfltCompiler == "" {
+		dfltCompiler = bspDfltCompiler
+	}
+	dfltDevice := path.Base(cfg.Name)
+	if cfg.Mcu != nil {
+		dfltDevice = path.Base(cfg.Mcu.Name())
+	}
+
+	cfg.Arch = bspAsk(cmd, "arch", "Architecture", dfltArch)
+	cfg.Compiler = bspAsk(cmd, "compiler", "Compiler package", dfltCompiler)
+	cfg.FlashBase = parseBspNumber("flash address",
+		bspAsk(cmd, "flash-base", "Flash start address", "0x00000000"))
+	cfg.FlashSize = parseBspNumber("flash size",
+		bspAsk(cmd, "flash-size", "Flash size", "512kB"))
+	cfg.RamBase = parseBspNumber("RAM address",
+		bspAsk(cmd, "ram-base", "RAM start address", "0x20000000"))
+	cfg.RamSize = parseBspNumber("RAM size",
+		bspAsk(cmd, "ram-size", "RAM size", "64kB"))
+	cfg.Probe = bspAsk(cmd, "probe",
+		"Debug probe ("+strings.Join(bspgen.Probes, ", ")+")",
+		bspgen.PROBE_JLINK)
+	cfg.Device = bspAsk(cmd, "device", "Device name for the debug probe",
+		dfltDevice)
+
+	if err := bspgen.Write(proj, cfg); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"BSP %s successfully created.\n", cfg.Name)
+}
+
+func AddBspCommands(cmd *cobra.Command) {
+	bspHelpText := "Commands for creating BSP packages"
+	bspHelpEx := "  newt bsp new hw/bsp/myboard"
+
+	bspCmd := &cobra.Command{
+		Use:     "bsp",
+		Short:   "Create BSP packages",
+		Long:    bspHelpText,
+		Example: bspHelpEx,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(bspCmd)
+
+	newHelpText := "Create a BSP package in the project's local repo.  " +
+		"Newt asks for the MCU, memory layout, and debug probe; answers " +
+		"can also be given with flags, in which case the corresponding " +
+		"question is skipped.  If a BSP in the project already uses the " +
+		"selected MCU package, its architecture, compiler, and MCU linker " +
+		"scripts are offered as defaults.\n\n" +
+		"The package contains pkg.yml, bsp.yml with a flash map, " +
+		"syscfg.yml, linker scripts defining the memory regions, " +
+		"download and debug scripts, and a stub hal_bsp.c."
+	newHelpEx := "  newt bsp new hw/bsp/myboard\n"
+	newHelpEx += "  newt bsp new hw/bsp/myboard --mcu nrf52xxx " +
+		"--flash-size 512kB --ram-size 64kB --probe jlink"
+
+	newCmd := &cobra.Command{
+		Use:     "new <bsp-name>",
+		Short:   "Create a new BSP package",
+		Long:    newHelpText,
+		Example: newHelpEx,
+		Run:     bspNewRunCmd,
+	}
+
+	newCmd.Flags().String("mcu", "", "MCU family or package")
+	newCmd.Flags().String("arch", "", "Architecture (bsp.arch)")
+	newCmd.Flags().String("compiler", "", "Compiler package (bsp.compiler)")
+	newCmd.Flags().String("flash-base", "", "Flash start address")
+	newCmd.Flags().String("flash-size", "", "Flash size (e.g., 512kB)")
+	newCmd.Flags().String("ram-base", "", "RAM start address")
+	newCmd.Flags().String("ram-size", "", "RAM size (e.g., 64kB)")
+	newCmd.Flags().String("probe", "",
+		"Debug probe: "+strings.Join(bspgen.Probes, ", "))
+	newCmd.Flags().String("device", "",
+		"Device name for the debug probe (J-Link device, OpenOCD target, "+
+			"or pyOCD target)")
+
+	bspCmd.AddCommand(newCmd)
+}
@@ -32,6 +32,7 @@ import (
 	"mynewt.apache.org/newt/newt/pkg"
 	"mynewt.apache.org/newt/newt/project"
 	"mynewt.apache.org/newt/newt/target"
+	"mynewt.apache.org/newt/newt/toolchain"
 	"mynewt.apache.org/newt/util"
 )
 
@@ -106,6 +107,17 @@ var noGDB_flag bool
 var diffFriendly_flag bool
 var imgFileOverride string
 var elfFileOverride string
+var warnRatchet bool
+var warnBaseline bool
+var gcReport bool
+var buildDefines []string
+var verifyFrozen bool
+var buildContainer string
+var romLoad bool
+var checkDeterminism bool
+var profileBuild bool
+var syscfgProvenance bool
+var buildInstrument string
 
 func buildRunCmd(cmd *cobra.Command, args []string, printShellCmds bool, executeShell bool) {
 	if len(args) < 1 {
@@ -114,6 +126,9 @@ func buildRunCmd(cmd *cobra.Command, args []string, printShellCmds bool, execute
 
 	util.PrintShellCmds = printShellCmds
 	util.ExecuteShell = executeShell
+	if syscfgProvenance {
+		util.SyscfgProvenance = true
+	}
 
 	TryGetProject()
 
@@ -134,6 +149,13 @@ func buildRunCmd(cmd *cobra.Command, args []string, printShellCmds bool, execute
 		}
 	}
 
+	if buildContainer != "" {
+		if err := toolchain.StartContainer(buildContainer); err != nil {
+			NewtUsage(nil, err)
+		}
+		defer toolchain.StopContainer()
+	}
+
 	for i, _ := range targets {
 		// Reset the global state for the next build.
 		// XXX: It is not good that this is necessary.  This is certainly going
@@ -144,45 +166,146 @@ func buildRunCmd(cmd *cobra.Command, args []string, printShellCmds bool, execute
 			}
 		}
 
-		// Look up the target by name.  This has to be done a second time here
-		// now that the project has been reset.
-		t := ResolveTarget(targets[i].FullName())
-		if t == nil {
-			NewtUsage(nil, util.NewNewtError("Failed to resolve target: "+
-				targets[i].Name()))
+		// Both builds of a determinism check start from scratch.  Objects
+		// restored from the cache would be identical by definition, so
+		// everything is compiled.
+		if checkDeterminism {
+			cleanDir(builder.TargetBinDir(targets[i].FullName()))
+			toolchain.BypassObjCache()
 		}
 
-		util.StatusMessage(util.VERBOSITY_DEFAULT, "Building target %s\n",
-			t.FullName())
+		t := buildTarget(cmd, targets[i].FullName())
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Target successfully built: %s\n", t.Name())
+
+		if checkDeterminism {
+			checkBuildDeterminism(cmd, t)
+			toolchain.RestoreObjCache()
+		}
+	}
+}
 
-		b, err := builder.NewTargetBuilder(t)
-		if err != nil {
+// Builds a single target and produces its manifest.
+func buildTarget(cmd *cobra.Command, name string) *target.Target {
+	// Look up the target by name.  This has to be done a second time here
+	// now that the project has been reset.
+	t := ResolveTarget(name)
+	if t == nil {
+		NewtUsage(nil, util.NewNewtError("Failed to resolve target: "+name))
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Building target %s\n",
+		t.FullName())
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if gcReport {
+		b.EnableGcReport()
+	}
+
+	if buildInstrument != "" {
+		if err := b.SetInstrument(buildInstrument); err != nil {
+			NewtUsage(cmd, err)
+		}
+	}
+
+	for _, def := range buildDefines {
+		if err := b.AddDefine(def); err != nil {
+			NewtUsage(cmd, err)
+		}
+	}
+
+	if verifyFrozen {
+		if err := b.VerifyFrozen(); err != nil {
 			NewtUsage(nil, err)
 		}
+	}
 
-		if err := b.Build(); err != nil {
-			if b.AppBuilder != nil {
-				if b.AppBuilder.GetModifiedRepos() != nil {
-					util.ErrorMessage(util.VERBOSITY_DEFAULT,
-						"Warning: Following external repos are modified or missing, which might be causing build errors:\n%v\n",
-						b.AppBuilder.GetModifiedRepos())
-				}
+	if err := b.Build(); err != nil {
+		if b.AppBuilder != nil {
+			if b.AppBuilder.GetModifiedRepos() != nil {
+				util.ErrorMessage(util.VERBOSITY_DEFAULT,
+					"Warning: Following external repos are modified or missing, which might be causing build errors:\n%v\n",
+					b.AppBuilder.GetModifiedRepos())
 			}
+		}
+		NewtUsage(nil, err)
+	}
+
+	if profileBuild {
+		prof := b.BuildProfile()
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", prof.Text(20))
+
+		path := builder.BuildProfilePath(t.FullName())
+		if err := prof.WriteJSON(path); err != nil {
 			NewtUsage(nil, err)
 		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Build profile written to %s\n", path)
+	}
 
-		// Produce bare "imageless" manifest.
-		mopts, err := manifest.OptsForNonImage(b)
-		if err != nil {
+	if warnRatchet || warnBaseline {
+		if err := b.WarnRatchet(warnBaseline); err != nil {
 			NewtUsage(nil, err)
 		}
-		if err := imgprod.ProduceManifest(mopts); err != nil {
+	}
+
+	if gcReport {
+		if err := b.GcReport(); err != nil {
 			NewtUsage(nil, err)
 		}
+	}
 
-		util.StatusMessage(util.VERBOSITY_DEFAULT,
-			"Target successfully built: %s\n", t.Name())
+	// Produce bare "imageless" manifest.
+	mopts, err := manifest.OptsForNonImage(b)
+	if err != nil {
+		NewtUsage(nil, err)
 	}
+	if err := imgprod.ProduceManifest(mopts); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	return t
+}
+
+// Rebuilds a target from scratch and verifies that the generated files and
+// binaries are identical to those of the previous build.  The previous build
+// must also have started from a clean directory without the object cache.
+func checkBuildDeterminism(cmd *cobra.Command, t *target.Target) {
+	first, err := builder.SnapshotArtifacts(t.FullName())
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Rebuilding target %s to check determinism\n", t.FullName())
+
+	cleanDir(builder.TargetBinDir(t.FullName()))
+	if err := ResetGlobalState(); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	t = buildTarget(cmd, t.FullName())
+
+	second, err := builder.SnapshotArtifacts(t.FullName())
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	diffs := builder.DiffArtifacts(first, second)
+	if len(diffs) > 0 {
+		NewtUsage(nil, util.FmtNewtError(
+			"target %s does not build deterministically; the following "+
+				"artifacts differ between builds:\n    %s",
+			t.FullName(), strings.Join(diffs, "\n    ")))
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Target %s builds deterministically (%d artifacts compared)\n",
+		t.FullName(), len(first))
 }
 
 func cleanDir(path string) {
@@ -359,6 +482,9 @@ func loadRunCmd(cmd *cobra.Command, args []string) {
 		NewtUsage(nil, err)
 	}
 
+	applyDevice(b)
+	b.SetRomLoad(romLoad)
+
 	if err := b.Load(extraJtagCmd, imgFileOverride); err != nil {
 		NewtUsage(cmd, err)
 	}
@@ -381,12 +507,16 @@ func debugRunCmd(cmd *cobra.Command, args []string) {
 		NewtUsage(nil, err)
 	}
 
+	applyDevice(b)
+
 	if err := b.Debug(extraJtagCmd, false, noGDB_flag, elfFileOverride); err != nil {
 		NewtUsage(cmd, err)
 	}
 }
 
-func sizeRunCmd(cmd *cobra.Command, args []string, ram bool, flash bool, section string) {
+func sizeRunCmd(cmd *cobra.Command, args []string, ram bool, flash bool,
+	section string, whynot string) {
+
 	if len(args) < 1 {
 		NewtUsage(cmd, util.NewNewtError("Must specify target"))
 	}
@@ -403,6 +533,13 @@ func sizeRunCmd(cmd *cobra.Command, args []string, ram bool, flash bool, section
 		NewtUsage(nil, err)
 	}
 
+	if whynot != "" {
+		if err := b.WhyNot(whynot); err != nil {
+			NewtUsage(nil, err)
+		}
+		return
+	}
+
 	var sections []string
 
 	if ram {
@@ -432,6 +569,49 @@ func sizeRunCmd(cmd *cobra.Command, args []string, ram bool, flash bool, section
 	}
 }
 
+func whyRebuildRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target"))
+	}
+	if len(args) > 2 {
+		NewtUsage(cmd, util.NewNewtError("Too many arguments"))
+	}
+
+	TryGetProject()
+
+	t := ResolveTarget(args[0])
+	if t == nil {
+		NewtUsage(cmd, util.NewNewtError("Invalid target name: "+args[0]))
+	}
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	// Apply the options that change the compile commands, so that the
+	// commands match those of the build being diagnosed.
+	if buildInstrument != "" {
+		if err := b.SetInstrument(buildInstrument); err != nil {
+			NewtUsage(cmd, err)
+		}
+	}
+	for _, def := range buildDefines {
+		if err := b.AddDefine(def); err != nil {
+			NewtUsage(cmd, err)
+		}
+	}
+
+	file := ""
+	if len(args) > 1 {
+		file = args[1]
+	}
+
+	if err := b.WhyRebuild(file); err != nil {
+		NewtUsage(nil, err)
+	}
+}
+
 func AddBuildCommands(cmd *cobra.Command) {
 	var printShellCmds bool
 	var executeShell bool
@@ -447,12 +627,48 @@ func AddBuildCommands(cmd *cobra.Command) {
 	buildCmd.Flags().BoolVarP(&printShellCmds, "printCmds", "p", false,
 		"Print executed build commands")
 
+	buildCmd.Flags().StringArrayVarP(&buildDefines, "define", "D", nil,
+		"Add a preprocessor definition (NAME or NAME=VALUE) to every "+
+			"compile command; may be repeated")
+
 	buildCmd.Flags().StringVarP(&util.InjectSyscfg, "syscfg", "S", "",
 		"Injected syscfg settings, key=value pairs separated by colon")
 
+	buildCmd.Flags().BoolVar(&syscfgProvenance, "syscfg-provenance", false,
+		"Annotate each setting in the generated syscfg.h with the packages "+
+			"that set its value and the location of the final override")
+
+	buildCmd.Flags().BoolVar(&verifyFrozen, "verify-frozen", false,
+		"Fail if the target's resolution differs from the resolution.json "+
+			"freeze file in the target directory")
+
 	buildCmd.Flags().BoolVar(&executeShell, "executeShell", false,
 		"Execute build command using /bin/sh (Linux and MacOS only)")
 
+	buildCmd.Flags().StringVar(&buildContainer, "container", "",
+		"Execute the compile and link commands in a container created "+
+			"from the specified Docker or Podman image")
+
+	buildCmd.Flags().BoolVar(&warnRatchet, "warn-ratchet", false,
+		"Fail if the build produces compiler warnings that are not in the "+
+			"target's warning baseline")
+
+	buildCmd.Flags().BoolVar(&warnBaseline, "warn-baseline", false,
+		"Record the build's compiler warnings as the target's warning "+
+			"baseline")
+
+	buildCmd.Flags().BoolVar(&checkDeterminism, "check-determinism", false,
+		"Build each target a second time from scratch and verify that the "+
+			"generated files and binaries are identical")
+	buildCmd.Flags().BoolVar(&profileBuild, "profile-build", false,
+		"Report the time spent compiling each package and file, and linking")
+	buildCmd.Flags().BoolVar(&gcReport, "gc-report", false,
+		"Link with --gc-sections and report the functions and data "+
+			"discarded from each package")
+	buildCmd.Flags().StringVar(&buildInstrument, "instrument", "",
+		"Build with compiler instrumentation for on-target profiling ("+
+			strings.Join(builder.InstrumentModes, " or ")+")")
+
 	cmd.AddCommand(buildCmd)
 	AddTabCompleteFn(buildCmd, func() []string {
 		return append(targetList(), "all")
@@ -501,6 +717,11 @@ func AddBuildCommands(cmd *cobra.Command) {
 		"Extra commands to send to JTAG software")
 	loadCmd.PersistentFlags().StringVarP(&imgFileOverride, "imgfile", "", "",
 		"Path of .img file to load instead of target artifact")
+	loadCmd.PersistentFlags().StringVar(&deviceName, "device", "",
+		"Name of the device in devices.yml to load to")
+	loadCmd.PersistentFlags().BoolVar(&romLoad, "rom", false,
+		"Load through the BSP's ROM serial bootloader instead of its "+
+			"download script")
 
 	debugHelpText := "Open a debugger session for <target-name>"
 
@@ -517,6 +738,8 @@ func AddBuildCommands(cmd *cobra.Command) {
 		"Do not start GDB from command line")
 	debugCmd.PersistentFlags().StringVarP(&elfFileOverride, "elffile", "",
 		"", "Path of .elf file to debug instead of target artifact")
+	debugCmd.PersistentFlags().StringVar(&deviceName, "device", "",
+		"Name of the device in devices.yml to debug")
 
 	cmd.AddCommand(debugCmd)
 	AddTabCompleteFn(debugCmd, targetList)
@@ -526,12 +749,13 @@ func AddBuildCommands(cmd *cobra.Command) {
 
 	var ram, flash bool
 	var section string
+	var whynot string
 	sizeCmd := &cobra.Command{
 		Use:   "size <target-name>",
 		Short: "Size of target components",
 		Long:  sizeHelpText,
 		Run: func(cmd *cobra.Command, args []string) {
-			sizeRunCmd(cmd, args, ram, flash, section)
+			sizeRunCmd(cmd, args, ram, flash, section, whynot)
 		},
 	}
 
@@ -541,7 +765,36 @@ func AddBuildCommands(cmd *cobra.Command) {
 	sizeCmd.Flags().BoolVarP(&flash, "flash", "F", false,
 		"Print FLASH statistics")
 	sizeCmd.Flags().StringVarP(&section, "section", "S", "", "Print section statistics")
+	sizeCmd.Flags().StringVarP(&whynot, "whynot", "", "",
+		"Explain why the specified symbol or object file is linked in")
 
 	cmd.AddCommand(sizeCmd)
 	AddTabCompleteFn(sizeCmd, targetList)
+
+	whyRebuildHelpText := "Explain, for each source file that the next " +
+		"build of <target-name> will recompile, which dependency triggered " +
+		"the recompile: a missing object file, a changed command line, a " +
+		"changed source file, or a changed or deleted dependency.  If " +
+		"<file> is specified, only that file is explained.  Nothing is " +
+		"compiled.  Specify the same -D, -S, and --instrument options as " +
+		"the build; they change the command lines."
+
+	whyRebuildCmd := &cobra.Command{
+		Use:   "why-rebuild <target-name> [file]",
+		Short: "Explain why a build will recompile files",
+		Long:  whyRebuildHelpText,
+		Run:   whyRebuildRunCmd,
+	}
+
+	whyRebuildCmd.Flags().StringArrayVarP(&buildDefines, "define", "D", nil,
+		"Preprocessor definition passed to the build (NAME or NAME=VALUE); "+
+			"may be repeated")
+	whyRebuildCmd.Flags().StringVarP(&util.InjectSyscfg, "syscfg", "S", "",
+		"Injected syscfg settings, key=value pairs separated by colon")
+	whyRebuildCmd.Flags().StringVar(&buildInstrument, "instrument", "",
+		"Instrumentation mode passed to the build ("+
+			strings.Join(builder.InstrumentModes, " or ")+")")
+
+	cmd.AddCommand(whyRebuildCmd)
+	AddTabCompleteFn(whyRebuildCmd, targetList)
 }
@@ -0,0 +1,187 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/toolchain"
+	"mynewt.apache.org/newt/util"
+)
+
+var cachePruneMaxSize int
+var cachePruneAll bool
+
+// Formats a byte count in megabytes.
+func cacheSizeString(size int64) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+}
+
+func cacheInfoRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		NewtUsage(cmd, util.NewNewtError("Too many arguments"))
+	}
+
+	info, err := toolchain.ObjCacheStatus()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	status := "enabled"
+	if !info.Enabled {
+		status = "disabled"
+	}
+
+	hitRate := ""
+	if info.Hits+info.Misses > 0 {
+		hitRate = fmt.Sprintf(" (%.0f%% hit rate)",
+			float64(info.Hits)*100/float64(info.Hits+info.Misses))
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Directory: %s\n", info.Dir)
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Status:    %s\n", status)
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Objects:   %d\n", info.Entries)
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Size:      %s (max %s)\n",
+		cacheSizeString(info.Size), cacheSizeString(info.MaxSize))
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Hits:      %d%s\n",
+		info.Hits, hitRate)
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Misses:    %d\n", info.Misses)
+}
+
+func cachePruneRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		NewtUsage(cmd, util.NewNewtError("Too many arguments"))
+	}
+
+	if cachePruneAll && cmd.Flags().Changed("max-size") {
+		NewtUsage(cmd, util.NewNewtError(
+			"--all and --max-size cannot be specified together"))
+	}
+	if cachePruneMaxSize < 0 {
+		NewtUsage(cmd, util.NewNewtError("--max-size must not be negative"))
+	}
+
+	maxSize := toolchain.ObjCacheMaxSize()
+	if cachePruneAll {
+		maxSize = 0
+	} else if cmd.Flags().Changed("max-size") {
+		maxSize = int64(cachePruneMaxSize) * 1024 * 1024
+	}
+
+	numRemoved, freed, err := toolchain.PruneObjCache(maxSize)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Removed %d cached object(s), freeing %s\n",
+		numRemoved, cacheSizeString(freed))
+}
+
+func cacheSetEnabled(cmd *cobra.Command, args []string, enabled bool) {
+	if len(args) > 0 {
+		NewtUsage(cmd, util.NewNewtError("Too many arguments"))
+	}
+
+	if err := toolchain.SetObjCacheEnabled(enabled); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if enabled {
+		if !toolchain.ObjCacheEnabled() {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"Object cache is disabled by \"build_cache\" in "+
+					"newtrc.yml\n")
+			return
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "Object cache enabled\n")
+	} else {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "Object cache disabled\n")
+	}
+}
+
+func AddCacheCommands(cmd *cobra.Command) {
+	cacheHelpText := FormatHelp(`Manage the object cache.  When a source
+		file needs to be compiled, newt looks for an identical compilation
+		(the same preprocessed source, compiler, and options) in the cache
+		and restores the resulting object file instead of compiling it.
+		This avoids recompiling unchanged files after switching branches.
+		The cache is shared by all projects and is kept in
+		$HOME/.newt/cache unless build_cache_dir is set in newtrc.yml.`)
+
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the object cache",
+		Long:  cacheHelpText,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	cmd.AddCommand(cacheCmd)
+
+	infoCmd := &cobra.Command{
+		Use:   "info",
+		Short: "Display the object cache's location, size, and hit rate",
+		Run:   cacheInfoRunCmd,
+	}
+	cacheCmd.AddCommand(infoCmd)
+
+	pruneHelpText := FormatHelp(`Remove the least recently used objects
+		from the cache until it is no larger than the cache's size limit
+		(build_cache_max_size in newtrc.yml, in megabytes; default 2048).
+		Builds prune the cache to this limit automatically.`)
+
+	pruneHelpEx := "  newt cache prune\n"
+	pruneHelpEx += "  newt cache prune --max-size 500\n"
+	pruneHelpEx += "  newt cache prune --all"
+
+	pruneCmd := &cobra.Command{
+		Use:     "prune",
+		Short:   "Remove the least recently used objects from the cache",
+		Long:    pruneHelpText,
+		Example: pruneHelpEx,
+		Run:     cachePruneRunCmd,
+	}
+	pruneCmd.Flags().IntVar(&cachePruneMaxSize, "max-size", 0,
+		"Prune the cache to the specified size, in megabytes")
+	pruneCmd.Flags().BoolVar(&cachePruneAll, "all", false,
+		"Remove every object and reset the statistics")
+	cacheCmd.AddCommand(pruneCmd)
+
+	disableCmd := &cobra.Command{
+		Use:   "disable",
+		Short: "Stop using the object cache; its contents are kept",
+		Run: func(cmd *cobra.Command, args []string) {
+			cacheSetEnabled(cmd, args, false)
+		},
+	}
+	cacheCmd.AddCommand(disableCmd)
+
+	enableCmd := &cobra.Command{
+		Use:   "enable",
+		Short: "Resume using the object cache",
+		Run: func(cmd *cobra.Command, args []string) {
+			cacheSetEnabled(cmd, args, true)
+		},
+	}
+	cacheCmd.AddCommand(enableCmd)
+}
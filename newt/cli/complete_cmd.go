@@ -98,16 +98,22 @@ func testablePkgList() []string {
 	return names
 }
 
+// Returns the values of an element type listed by `newt vals`, or nothing if
+// they cannot be determined (e.g., outside of a project).
+func completeVals(elemType string) []string {
+	vals, err := VarValues(elemType)
+	if err != nil {
+		return nil
+	}
+	return vals
+}
+
 func unittestList() []string {
-	return pkgNameList(func(pack *pkg.LocalPackage) bool {
-		return pack.Type() == pkg.PACKAGE_TYPE_UNITTEST
-	})
+	return completeVals("unittest")
 }
 
 func mfgList() []string {
-	targetNames := pkgNameList(func(pack *pkg.LocalPackage) bool {
-		return pack.Type() == pkg.PACKAGE_TYPE_MFG
-	})
+	targetNames := completeVals("mfg")
 
 	// Remove "targets/" prefix.
 	for i, _ := range targetNames {
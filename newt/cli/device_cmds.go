@@ -0,0 +1,236 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/apache/mynewt-artifact/manifest"
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/devmgr"
+	"mynewt.apache.org/newt/util"
+)
+
+var deviceName string
+var deviceNewtmgrPath string
+var deviceReset bool
+var deviceConfirm bool
+var deviceTimeout int
+
+// Passes the device specified with --device, if any, to the target's download
+// and debug scripts.
+func applyDevice(b *builder.TargetBuilder) {
+	if deviceName == "" {
+		return
+	}
+
+	dev, err := devmgr.FindDevice(deviceName)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+	if dev == nil {
+		NewtUsage(nil, util.FmtNewtError(
+			"unknown device \"%s\"; devices are defined in %s",
+			deviceName, devmgr.DevicesPath()))
+	}
+
+	b.SetDeviceEnv(dev.EnvVars())
+}
+
+func deviceUpgradeRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target"))
+	}
+	if deviceName == "" {
+		NewtUsage(cmd, util.NewNewtError(
+			"Must specify a device or newtmgr connection profile with "+
+				"--device"))
+	}
+	if deviceConfirm && !deviceReset {
+		NewtUsage(cmd, util.NewNewtError("--confirm requires --reset"))
+	}
+
+	TryGetProject()
+
+	t := ResolveTarget(args[0])
+	if t == nil {
+		NewtUsage(cmd, util.NewNewtError("Invalid target name: "+args[0]))
+	}
+	if t.App() == nil {
+		NewtUsage(nil, util.FmtNewtError(
+			"target %s does not specify an app package", t.FullName()))
+	}
+
+	mpath := builder.ManifestPath(t.FullName(), builder.BUILD_NAME_APP,
+		t.App().FullName())
+	m, err := manifest.ReadManifest(mpath)
+	if err != nil {
+		NewtUsage(nil, util.FmtNewtError(
+			"failed to read manifest of target %s: %s; "+
+				"run `newt create-image` first", t.FullName(), err.Error()))
+	}
+	if m.ImageHash == "" {
+		NewtUsage(nil, util.FmtNewtError(
+			"target %s has no image; run `newt create-image` first",
+			t.FullName()))
+	}
+	if m.Loader != "" {
+		NewtUsage(nil, util.FmtNewtError(
+			"target %s produces a split image; split images cannot be "+
+				"upgraded with this command", t.FullName()))
+	}
+
+	opts := devmgr.UpgradeOpts{
+		ImgPath: builder.AppImgPath(t.FullName(), builder.BUILD_NAME_APP,
+			t.App().FullName()),
+		Hash:    m.ImageHash,
+		Version: m.Version,
+		Reset:   deviceReset,
+		Confirm: deviceConfirm,
+		Timeout: time.Duration(deviceTimeout) * time.Second,
+	}
+
+	// A device that is not in the inventory is assumed to be the name of a
+	// newtmgr connection profile.
+	nm := devmgr.NewNewtmgr(deviceNewtmgrPath, deviceName)
+	dev, err := devmgr.FindDevice(deviceName)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+	if dev != nil {
+		nm, err = dev.Newtmgr(deviceNewtmgrPath)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+	}
+
+	res, err := devmgr.Upgrade(nm, opts)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Slots:\n")
+	for _, s := range res.Slots {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"    image=%d slot=%d version=%s flags=[%s] hash=%s\n",
+			s.Image, s.Slot, s.Version, s.FlagsString(), s.Hash)
+	}
+
+	if !res.Running {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Image %s will be tested on the device's next reset\n",
+			m.Version)
+	}
+}
+
+func deviceListRunCmd(cmd *cobra.Command, args []string) {
+	TryGetProject()
+
+	devs, err := devmgr.ReadDevices()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	for _, name := range devmgr.DeviceNames() {
+		dev := devs[name]
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", name)
+		if dev.NewtmgrConn != "" {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"    newtmgr_conn: %s\n", dev.NewtmgrConn)
+		}
+		if dev.SerialPort != "" {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"    serial_port:  %s (%d baud)\n", dev.SerialPort, dev.Baud)
+		}
+		if dev.BleAddr != "" {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"    ble_addr:     %s\n", dev.BleAddr)
+		}
+		if dev.ProbeSerial != "" {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"    probe_serial: %s\n", dev.ProbeSerial)
+		}
+	}
+}
+
+func AddDeviceCommands(cmd *cobra.Command) {
+	deviceCmd := &cobra.Command{
+		Use:   "device",
+		Short: "Manage devices over SMP",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	cmd.AddCommand(deviceCmd)
+
+	upgradeHelpText := "Upgrade a device to the image most recently " +
+		"created for <target-name>.  The device is accessed with the " +
+		"newtmgr tool.  --device names either a device in the project's " +
+		"devices.yml file or a newtmgr connection profile (see " +
+		"`newtmgr conn add`).\n\n"
+	upgradeHelpText += "The image is uploaded unless the device already " +
+		"has an image with the hash recorded in the target's manifest.  " +
+		"The image is then marked for test.  With --reset, the device is " +
+		"reset and newt waits for it to boot the new image; with " +
+		"--confirm, the image is then made permanent.  The device's slot " +
+		"states are reported at the end."
+
+	upgradeHelpEx := "  newt device upgrade my_target1 --device serial1\n"
+	upgradeHelpEx += "  newt device upgrade my_target1 --device serial1 " +
+		"--reset --confirm\n"
+
+	upgradeCmd := &cobra.Command{
+		Use:     "upgrade <target-name>",
+		Short:   "Upload a target's image to a device and boot it",
+		Long:    upgradeHelpText,
+		Example: upgradeHelpEx,
+		Run:     deviceUpgradeRunCmd,
+	}
+	upgradeCmd.Flags().StringVarP(&deviceName, "device", "d", "",
+		"Name of the device in devices.yml, or a newtmgr connection profile")
+	upgradeCmd.Flags().StringVar(&deviceNewtmgrPath, "newtmgr",
+		devmgr.NEWTMGR_DFLT_PATH, "Path of the newtmgr executable")
+	upgradeCmd.Flags().BoolVar(&deviceReset, "reset", false,
+		"Reset the device after marking the image for test")
+	upgradeCmd.Flags().BoolVar(&deviceConfirm, "confirm", false,
+		"Confirm the image once the device runs it (requires --reset)")
+	upgradeCmd.Flags().IntVar(&deviceTimeout, "timeout", 30,
+		"Seconds to wait for the device to boot the new image")
+
+	deviceCmd.AddCommand(upgradeCmd)
+	AddTabCompleteFn(upgradeCmd, targetList)
+
+	listHelpText := "List the devices defined in the project's " +
+		"devices.yml file.  Commands that access a device (load, debug, " +
+		"run, device upgrade, and hiltest) accept the name of a device " +
+		"with --device."
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the devices defined in devices.yml",
+		Long:  listHelpText,
+		Run:   deviceListRunCmd,
+	}
+
+	deviceCmd.AddCommand(listCmd)
+}
@@ -21,17 +21,141 @@ package cli
 
 import (
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"mynewt.apache.org/newt/newt/builder"
 	"mynewt.apache.org/newt/newt/docs"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
 )
 
+var docsSyscfgFormat string
+var docsTargetName string
+var docsOutDir string
+
+// Runs doxygen on the packages of a resolved target.  If `--target` is
+// specified, the argument names a single package to document within that
+// target; otherwise it names the target (or unittest) itself.
+func docsBuildTarget(cmd *cobra.Command, arg string) {
+	targetName := arg
+	if docsTargetName != "" {
+		targetName = docsTargetName
+	}
+
+	b, err := TargetBuilderForTargetOrUnittest(targetName)
+	if err != nil {
+		if docsTargetName == "" {
+			err = util.FmtNewtError("%s; use --target to document a "+
+				"package in the context of a target", err.Error())
+		}
+		NewtUsage(cmd, err)
+	}
+
+	allPkgs, err := b.DocInputs()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	name := b.GetTarget().Name()
+	docPkgs := allPkgs
+	if docsTargetName != "" {
+		lpkgs, err := ResolvePackages([]string{arg})
+		if err != nil {
+			NewtUsage(cmd, err)
+		}
+
+		docPkgs = nil
+		for _, di := range allPkgs {
+			if di.Lpkg == lpkgs[0] {
+				docPkgs = append(docPkgs, di)
+			}
+		}
+		if len(docPkgs) == 0 {
+			NewtUsage(nil, util.FmtNewtError(
+				"package %s is not part of target %s",
+				lpkgs[0].FullName(), name))
+		}
+		name = lpkgs[0].FullName()
+	}
+
+	outdir := docsOutDir
+	if outdir == "" {
+		outdir = builder.TargetBinDir(b.GetTarget().FullName()) + "/docs"
+	}
+
+	if err := docs.RunDoxygen(name, docPkgs, allPkgs, outdir); err != nil {
+		NewtUsage(nil, err)
+	}
+}
+
 func docsBuildRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		TryGetProject()
+		docsBuildTarget(cmd, args[0])
+		return
+	}
+
 	wd, _ := os.Getwd()
 	db, _ := docs.NewDocsBuilder()
 	db.Build(wd + "/_build")
 }
 
+// Returns the packages in the specified repo, sorted by name.
+func repoPackages(repoName string) ([]*pkg.LocalPackage, error) {
+	proj := TryGetProject()
+
+	if proj.FindRepo(repoName) == nil {
+		return nil, util.FmtNewtError("unknown repo: \"%s\"", repoName)
+	}
+
+	var lpkgs []*pkg.LocalPackage
+	if pkgMap := proj.PackageList()[repoName]; pkgMap != nil {
+		for _, p := range *pkgMap {
+			lpkgs = append(lpkgs, p.(*pkg.LocalPackage))
+		}
+	}
+
+	sort.Slice(lpkgs, func(i int, j int) bool {
+		return lpkgs[i].FullName() < lpkgs[j].FullName()
+	})
+
+	return lpkgs, nil
+}
+
+func docsSyscfgRunCmd(cmd *cobra.Command, args []string) {
+	TryGetProject()
+
+	var lpkgs []*pkg.LocalPackage
+	if len(args) == 0 {
+		lpkgs = localRepoPackages()
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "@") && !strings.Contains(arg, "/") {
+			rpkgs, err := repoPackages(strings.TrimPrefix(arg, "@"))
+			if err != nil {
+				NewtUsage(cmd, err)
+			}
+			lpkgs = append(lpkgs, rpkgs...)
+		} else {
+			ppkgs, err := ResolvePackages([]string{arg})
+			if err != nil {
+				NewtUsage(cmd, err)
+			}
+			lpkgs = append(lpkgs, ppkgs...)
+		}
+	}
+
+	s, err := docs.SyscfgDoc(lpkgs, docsSyscfgFormat)
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_QUIET, "%s", s)
+}
+
 func AddDocsCommands(cmd *cobra.Command) {
 	docsCmdHelpText := ""
 	docsCmdHelpEx := ""
@@ -48,13 +172,49 @@ func AddDocsCommands(cmd *cobra.Command) {
 	cmd.AddCommand(docsCmd)
 
 	buildShortHelp := "Generate project documentation using Mynewt docs system."
+	buildLongHelp := buildShortHelp + "\n\nIf a target is specified, " +
+		"API documentation is generated with doxygen for the packages in " +
+		"the target, using the include paths and preprocessor defines " +
+		"that the target is built with.  To document a single package, " +
+		"specify the package and use --target to select the target that " +
+		"provides its configuration."
 
 	buildCmd := &cobra.Command{
-		Use:   "build [<outdir>]",
+		Use:   "build [<target> | <package> --target <target>]",
 		Short: buildShortHelp,
-		Long:  buildShortHelp,
+		Long:  buildLongHelp,
 		Run:   docsBuildRunCmd,
 	}
+	buildCmd.Flags().StringVarP(&docsTargetName, "target", "t", "",
+		"Target providing the configuration of the documented package")
+	buildCmd.Flags().StringVar(&docsOutDir, "outdir", "",
+		"Doxygen output directory (default bin/targets/<target>/docs)")
 
 	docsCmd.AddCommand(buildCmd)
+	AddTabCompleteFn(buildCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
+
+	syscfgHelpText := "Render the syscfg setting definitions of the " +
+		"specified packages as Markdown or reStructuredText.  Each " +
+		"argument is either a package name or a repo name (e.g., " +
+		"@apache-mynewt-core).  If no arguments are specified, the " +
+		"packages in the project's local repo are documented."
+
+	syscfgCmd := &cobra.Command{
+		Use:   "syscfg [@repo | <package>]...",
+		Short: "Generate documentation for syscfg settings",
+		Long:  syscfgHelpText,
+		Run:   docsSyscfgRunCmd,
+	}
+	syscfgCmd.Flags().StringVarP(&docsSyscfgFormat, "format", "f",
+		docs.SYSCFG_DOC_FORMAT_MD, "Output format ("+
+			strings.Join(docs.SyscfgDocFormats, ", ")+")")
+
+	docsCmd.AddCommand(syscfgCmd)
+	AddTabCompleteFn(syscfgCmd, func() []string {
+		return pkgNameList(func(pack *pkg.LocalPackage) bool {
+			return pack.Type() != pkg.PACKAGE_TYPE_TARGET
+		})
+	})
 }
@@ -0,0 +1,183 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/kardianos/osext"
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/util"
+)
+
+var envJSON bool
+var envShell string
+
+// Formats a single variable assignment for the specified shell.  Values are
+// single-quoted so that they are taken literally.
+func envAssignment(shell string, key string, val string) (string, error) {
+	switch shell {
+	case "sh":
+		return "export " + key + "='" +
+			strings.Replace(val, "'", `'\''`, -1) + "'", nil
+
+	case "csh":
+		return "setenv " + key + " '" +
+			strings.Replace(val, "'", `'\''`, -1) + "'", nil
+
+	case "fish":
+		val = strings.Replace(val, `\`, `\\`, -1)
+		val = strings.Replace(val, "'", `\'`, -1)
+		return "set -gx " + key + " '" + val + "'", nil
+
+	default:
+		return "", util.FmtNewtError(
+			"unknown shell \"%s\"; must be one of: sh, csh, fish", shell)
+	}
+}
+
+// Collects the project-wide variables: the project root, the newt
+// executable, and the location of each repo.
+func projectEnvVars() map[string]string {
+	proj := TryGetProject()
+
+	newtPath, _ := osext.Executable()
+
+	env := map[string]string{
+		"MYNEWT_PROJECT_ROOT": builder.ProjectRoot(),
+		"MYNEWT_NEWT_PATH":    newtPath,
+		"BIN_ROOT":            builder.BinRoot(),
+	}
+
+	for name, r := range proj.Repos() {
+		key := "MYNEWT_REPO_" + strings.ToUpper(util.CIdentifier(name)) +
+			"_PATH"
+		env[key] = r.Path()
+	}
+
+	return env
+}
+
+// Collects the variables specific to a target: its BSP, toolchain, and
+// generated directories.
+func targetEnvVars(targetName string) (map[string]string, error) {
+	t := ResolveTarget(targetName)
+	if t == nil {
+		return nil, util.FmtNewtError("invalid target name: %s", targetName)
+	}
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := b.NewCompiler("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	env := builder.BasicEnvVars("", b.BspPkg())
+	for k, v := range builder.ToolchainEnvVars(c) {
+		env[k] = v
+	}
+
+	name := t.FullName()
+	env["MYNEWT_TARGET"] = name
+	env["MYNEWT_BUILD_PROFILE"] = t.BuildProfile
+	env["MYNEWT_BIN_DIR"] = builder.TargetBinDir(name)
+	env["MYNEWT_BUILD_GENERATED_DIR"] = builder.GeneratedBaseDir(name)
+	env["MYNEWT_GENERATED_SRC_DIR"] = builder.GeneratedSrcDir(name)
+	env["MYNEWT_GENERATED_INCLUDE_DIR"] = builder.GeneratedIncludeDir(name)
+
+	return env, nil
+}
+
+func envRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		NewtUsage(cmd, util.NewNewtError("Too many arguments"))
+	}
+
+	env := projectEnvVars()
+
+	if len(args) == 1 {
+		tenv, err := targetEnvVars(args[0])
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+		for k, v := range tenv {
+			env[k] = v
+		}
+	}
+
+	if envJSON {
+		js, err := json.MarshalIndent(env, "", "    ")
+		if err != nil {
+			NewtUsage(nil, util.ChildNewtError(err))
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", js)
+		return
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		line, err := envAssignment(envShell, k, env[k])
+		if err != nil {
+			NewtUsage(cmd, err)
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", line)
+	}
+}
+
+func AddEnvCommands(cmd *cobra.Command) {
+	envHelpText := FormatHelp(`Display the project layout as environment
+		variables: the project root, the location of each repo, and, if a
+		target is specified, its BSP, toolchain paths, and generated source
+		and include directories.  The output can be evaluated by a shell or,
+		with --json, parsed by external tools.`)
+
+	envHelpEx := "  newt env\n"
+	envHelpEx += "  eval \"$(newt env my_target)\"\n"
+	envHelpEx += "  newt env --shell fish my_target | source\n"
+	envHelpEx += "  newt env --json my_target"
+
+	envCmd := &cobra.Command{
+		Use:     "env [target-name]",
+		Short:   "Display project paths as environment variables",
+		Long:    envHelpText,
+		Example: envHelpEx,
+		Run:     envRunCmd,
+	}
+	envCmd.Flags().BoolVar(&envJSON, "json", false,
+		"Output a JSON object instead of shell assignments")
+	envCmd.Flags().StringVar(&envShell, "shell", "sh",
+		"Shell syntax of the assignments (sh, csh, or fish)")
+
+	cmd.AddCommand(envCmd)
+	AddTabCompleteFn(envCmd, targetList)
+}
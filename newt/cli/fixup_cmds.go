@@ -0,0 +1,231 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/interfaces"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/newt/target"
+	"mynewt.apache.org/newt/util"
+)
+
+var fixupDryRun bool
+
+// Matches a YAML list item containing a single, optionally quoted, package
+// name.
+var fixupListItemRe = regexp.MustCompile(
+	`^(\s*-\s*)(["']?)([^"'\s#]+)(["']?)(\s*(#.*)?)$`)
+
+// Matches a target.yml entry that refers to a package.
+var fixupTargetVarRe = regexp.MustCompile(
+	`^((?:target\.app|target\.bsp|target\.loader)\s*:\s*)(["']?)` +
+		`([^"'\s#]+)(["']?)(\s*(#.*)?)$`)
+
+// Matches a top-level YAML key.
+var fixupTopKeyRe = regexp.MustCompile(`^([^\s#][^:]*):`)
+
+// Determines the name that a reference to a transient package should be
+// replaced with.  It returns "" if the reference does not need to be
+// rewritten.
+func fixupTransientName(parentRepo interfaces.RepoInterface,
+	name string) string {
+
+	dep, err := pkg.NewDependency(parentRepo, name)
+	if err != nil {
+		return ""
+	}
+
+	lpkg, ok := project.GetProject().ResolveDependency(dep).(*pkg.LocalPackage)
+	if !ok || lpkg == nil || lpkg.Type() != pkg.PACKAGE_TYPE_TRANSIENT {
+		return ""
+	}
+
+	linkDep, err := pkg.NewDependency(lpkg.Repo(), lpkg.LinkedName())
+	if err != nil {
+		return ""
+	}
+
+	linked, ok :=
+		project.GetProject().ResolveDependency(linkDep).(*pkg.LocalPackage)
+	if !ok || linked == nil {
+		util.StatusMessage(util.VERBOSITY_QUIET,
+			"Warning: transient package %s links to unknown package %s\n",
+			lpkg.FullName(), lpkg.LinkedName())
+		return ""
+	}
+
+	return linked.FullName()
+}
+
+// Rewrites the references to transient packages in one YAML file.  Package
+// lists are only rewritten under `pkg.deps` keys; `isTarget` enables
+// rewriting of the target.yml package variables.  It returns one description
+// per rewritten reference.
+func fixupTransientsFile(path string, parentRepo interfaces.RepoInterface,
+	isTarget bool) ([]string, error) {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	var changes []string
+	topKey := ""
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if m := fixupTopKeyRe.FindStringSubmatch(line); m != nil {
+			topKey = strings.TrimSpace(m[1])
+		}
+
+		var m []string
+		if isTarget {
+			m = fixupTargetVarRe.FindStringSubmatch(line)
+		}
+		if m == nil && strings.HasPrefix(topKey, "pkg.deps") {
+			m = fixupListItemRe.FindStringSubmatch(line)
+		}
+		if m == nil || m[2] != m[4] {
+			continue
+		}
+
+		newName := fixupTransientName(parentRepo, m[3])
+		if newName == "" || newName == m[3] {
+			continue
+		}
+
+		lines[i] = m[1] + m[2] + newName + m[4] + m[5]
+		changes = append(changes,
+			path+": "+m[3]+" --> "+newName)
+	}
+
+	if len(changes) > 0 && !fixupDryRun {
+		if err := ioutil.WriteFile(path,
+			[]byte(strings.Join(lines, "\n")), 0644); err != nil {
+
+			return nil, util.ChildNewtError(err)
+		}
+	}
+
+	return changes, nil
+}
+
+func fixupTransientsRunCmd(cmd *cobra.Command, args []string) {
+	proj := TryGetProject()
+
+	// Only the project's own packages are rewritten.
+	localPkgs := proj.PackageList()[proj.LocalRepo().Name()]
+	if localPkgs == nil {
+		return
+	}
+
+	var lpkgs []*pkg.LocalPackage
+	for _, p := range *localPkgs {
+		lpkgs = append(lpkgs, p.(*pkg.LocalPackage))
+	}
+	sort.Slice(lpkgs, func(i int, j int) bool {
+		return lpkgs[i].FullName() < lpkgs[j].FullName()
+	})
+
+	var changes []string
+	for _, lpkg := range lpkgs {
+		// A transient package's own link is not a reference to rewrite.
+		if lpkg.Type() == pkg.PACKAGE_TYPE_TRANSIENT {
+			continue
+		}
+
+		paths := []string{lpkg.BasePath() + "/" + pkg.PACKAGE_FILE_NAME}
+		if lpkg.Type() == pkg.PACKAGE_TYPE_TARGET {
+			paths = append(paths, lpkg.BasePath()+"/"+target.TARGET_FILENAME)
+		}
+
+		for _, path := range paths {
+			if util.NodeNotExist(path) {
+				continue
+			}
+
+			c, err := fixupTransientsFile(path, lpkg.Repo(),
+				strings.HasSuffix(path, target.TARGET_FILENAME))
+			if err != nil {
+				NewtUsage(nil, err)
+			}
+			changes = append(changes, c...)
+		}
+	}
+
+	if len(changes) == 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"No references to transient packages found\n")
+		return
+	}
+
+	for _, c := range changes {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", c)
+	}
+
+	if fixupDryRun {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"%d reference(s) would be rewritten (dry run)\n", len(changes))
+	} else {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"%d reference(s) rewritten\n", len(changes))
+	}
+}
+
+func AddFixupCommands(cmd *cobra.Command) {
+	fixupCmd := &cobra.Command{
+		Use:   "fixup",
+		Short: "Rewrite project files to remove deprecated constructs",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	cmd.AddCommand(fixupCmd)
+
+	transientsHelpText := "Rewrite references to transient packages (" +
+		"packages that specify pkg.link) in the project's own pkg.yml " +
+		"and target.yml files so that they refer to the linked packages " +
+		"directly.  Only pkg.deps lists and the target.app, target.bsp, " +
+		"and target.loader variables are rewritten; packages in external " +
+		"repos are not modified."
+	transientsHelpEx := "  newt fixup transients --dry-run\n"
+	transientsHelpEx += "  newt fixup transients"
+
+	transientsCmd := &cobra.Command{
+		Use:     "transients",
+		Short:   "Replace references to transient packages",
+		Long:    transientsHelpText,
+		Example: transientsHelpEx,
+		Run:     fixupTransientsRunCmd,
+	}
+	transientsCmd.Flags().BoolVarP(&fixupDryRun, "dry-run", "n", false,
+		"Report the references that would be rewritten without modifying "+
+			"any files")
+
+	fixupCmd.AddCommand(transientsCmd)
+}
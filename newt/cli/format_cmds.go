@@ -0,0 +1,115 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/format"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
+)
+
+var formatTool string
+var formatCheck bool
+
+// Returns all packages in the local repo, sorted by name.
+func localRepoPackages() []*pkg.LocalPackage {
+	proj := TryGetProject()
+
+	var lpkgs []*pkg.LocalPackage
+
+	pkgMap := proj.PackageList()[proj.LocalRepo().Name()]
+	if pkgMap != nil {
+		for _, p := range *pkgMap {
+			lpkgs = append(lpkgs, p.(*pkg.LocalPackage))
+		}
+	}
+
+	sort.Slice(lpkgs, func(i int, j int) bool {
+		return lpkgs[i].FullName() < lpkgs[j].FullName()
+	})
+
+	return lpkgs
+}
+
+func formatRunCmd(cmd *cobra.Command, args []string) {
+	proj := TryGetProject()
+
+	var lpkgs []*pkg.LocalPackage
+	if len(args) == 0 {
+		lpkgs = localRepoPackages()
+	} else {
+		var err error
+		lpkgs, err = ResolvePackages(args)
+		if err != nil {
+			NewtUsage(cmd, err)
+		}
+	}
+
+	bad, err := format.Run(formatTool, lpkgs, formatCheck)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if len(bad) > 0 {
+		for _, file := range bad {
+			util.StatusMessage(util.VERBOSITY_QUIET, "%s\n",
+				strings.TrimPrefix(file, proj.Path()+"/"))
+		}
+		NewtUsage(nil, util.FmtNewtError(
+			"%d file(s) not formatted correctly", len(bad)))
+	}
+}
+
+func AddFormatCommands(cmd *cobra.Command) {
+	formatHelpText := "Run a source code formatter over the C and C++ " +
+		"files of the specified packages.  If no packages are specified, " +
+		"all packages in the local repo are formatted.\n\n" +
+		"clang-format uses the `.clang-format` file nearest each source " +
+		"file; files not covered by one are left alone.  uncrustify uses " +
+		"the `uncrustify.cfg` file at the root of the package's repo.\n\n" +
+		"Directories listed under `pkg.format_exclude` in a package's " +
+		"pkg.yml are skipped."
+
+	formatCmd := &cobra.Command{
+		Use:   "format [pkg-name...]",
+		Short: "Format package source files",
+		Long:  formatHelpText,
+		Run:   formatRunCmd,
+	}
+
+	formatCmd.Flags().StringVarP(&formatTool, "tool", "t",
+		format.TOOL_CLANG_FORMAT,
+		"Formatter to run ("+strings.Join(format.Tools, ", ")+")")
+	formatCmd.Flags().BoolVarP(&formatCheck, "check", "c", false,
+		"Don't modify files; list incorrectly formatted files and fail if "+
+			"there are any")
+
+	cmd.AddCommand(formatCmd)
+	AddTabCompleteFn(formatCmd, func() []string {
+		return pkgNameList(func(pack *pkg.LocalPackage) bool {
+			return pack.Type() != pkg.PACKAGE_TYPE_TARGET
+		})
+	})
+}
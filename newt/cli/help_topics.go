@@ -0,0 +1,240 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// A concept page displayed by `newt help <topic>`.  Topics are registered as
+// cobra commands without a run function, so cobra lists them under
+// "Additional help topics" rather than as commands.
+type helpTopic struct {
+	name  string
+	short string
+	long  string
+}
+
+var helpTopics = []helpTopic{
+	{
+		name:  "syscfg",
+		short: "System configuration: settings, overrides, and restrictions",
+		long: `
+System configuration (syscfg) is the set of compile-time settings that
+control a Mynewt build.  Each setting is an integer, boolean, or string
+value that newt emits as a MYNEWT_VAL_<NAME> macro in the generated
+syscfg.h header.
+
+Defining settings
+    A package defines settings in the syscfg.defs section of its
+    syscfg.yml file:
+
+        syscfg.defs:
+            LOG_LEVEL:
+                description: 'Minimum level of log messages to keep.'
+                value: 1
+                choices: [0, 1, 2, 3, 4]
+                restrictions:
+                    - '!LOG_NONE'
+
+    Each setting may specify a description, a default value, a type
+    ("raw", "task_priority", "interrupt_priority", or "flash_owner"), a
+    list of valid choices, a range, and restrictions that must hold in
+    the final configuration.
+
+Overriding settings
+    Any package may override settings in the syscfg.vals section of its
+    syscfg.yml.  A package can only override a setting defined by a
+    package of lower priority.  From lowest to highest, the priorities
+    are: library, SDK, BSP, unittest, application, target.  Target
+    overrides therefore always win:
+
+        syscfg.vals:
+            LOG_LEVEL: 0
+
+    Overrides may be conditional on other settings by appending an
+    expression to the section name, e.g. "syscfg.vals.BLE_ROLE_CENTRAL".
+
+Derived settings
+    A BSP or MCU package can publish settings whose values newt computes
+    from other settings in the syscfg.derived section of its syscfg.yml:
+
+        syscfg.derived:
+            MCU_FAMILY_STM32F4: 1
+            MCU_HAS_FPU:
+                expr: 'MCU_CORTEX_M4 || MCU_CORTEX_M7'
+            MCU_RAM_SIZE:
+                evaluator: sum
+                args: [MCU_SRAM1_SIZE, MCU_SRAM2_SIZE]
+
+    An "expr" evaluates to 1 or 0.  The built-in evaluators are "sum",
+    "min", and "max"; their arguments are setting names or integers.
+    Derived settings are constants and cannot be overridden.
+
+Conditional source files
+    A package can select the files it compiles with conditional
+    pkg.source_dirs, pkg.source_files, and pkg.ignore_files entries.  The
+    entries whose expressions are true in the final configuration are
+    combined with the unconditional ones:
+
+        pkg.source_dirs:
+            - src
+        pkg.source_files.MYNEWT_VAL(LOG_CONSOLE):
+            - ports/console_port.c
+        pkg.ignore_files.'!MYNEWT_VAL(LOG_FCB)':
+            - 'log_fcb.*\.c'
+
+    A package that lists source files but no source directories only
+    compiles the listed files, so keep "src" in pkg.source_dirs when
+    adding files to it.  In any expression, MYNEWT_VAL(NAME) is
+    equivalent to NAME.
+
+    Source directories, source files, and SDK include directories can
+    refer to a setting's value as $(NAME).  This selects the files of
+    vendor SDKs that keep one directory per architecture or board:
+
+        pkg.source_dirs:
+            - src
+            - ports/$(ARCH_NAME)
+
+    The quotes around a string value are removed.  Newt defines ARCH_NAME
+    and BSP_NAME for every build, and APP_NAME when building an app.
+
+    pkg.ignore_files and pkg.ignore_dirs entries are regular expressions
+    that may match any part of a file's path or a directory's name, so
+    "test" also ignores "test_utils".  An entry beginning with "glob:"
+    is a glob pattern that must match the whole path relative to the
+    package directory instead; a pattern without a "/" matches a name at
+    any depth, and "**/" matches any number of directories:
+
+        pkg.ignore_dirs:
+            - 'glob:src/test'
+        pkg.ignore_files:
+            - 'glob:src/**/*_fcb.c'
+
+    Newt warns about entries that match nothing.  "newt pkg files
+    <target> <pkg>" shows which files are compiled and which entry
+    excludes each of the others.
+
+Inspecting settings
+    newt target config show <target>     Show the final value of each setting.
+    newt target config brief <target>    Show a one-line summary per setting.
+    newt target config set <target> K=V  Override a setting in the target.
+    newt build --syscfg-provenance       Annotate syscfg.h with the origin
+                                         of each value.
+
+Newt reports ambiguities (two packages of equal priority overriding the
+same setting), restriction violations, and overrides of undefined settings
+as errors before building.
+`,
+	},
+	{
+		name:  "flash-map",
+		short: "Flash maps: describing and allocating flash areas",
+		long: `
+A flash map divides the device's flash into named areas.  The BSP
+defines the flash map in the bsp.flash_map section of its bsp.yml:
+
+    bsp.flash_map:
+        areas:
+            FLASH_AREA_BOOTLOADER:
+                device: 0
+                offset: 0x00000000
+                size: 16kB
+            FLASH_AREA_IMAGE_0:
+                device: 0
+                offset: 0x00008000
+                size: 232kB
+            FLASH_AREA_IMAGE_1:
+                device: 0
+                offset: 0x00042000
+                size: 232kB
+            FLASH_AREA_IMAGE_SCRATCH:
+                device: 0
+                offset: 0x0007c000
+                size: 4kB
+
+Each area specifies the flash device number, its offset within that
+device, and its size.  Sizes may be given in bytes or with a kB or MB
+suffix.  The system areas FLASH_AREA_BOOTLOADER, FLASH_AREA_IMAGE_0,
+FLASH_AREA_IMAGE_1, and FLASH_AREA_IMAGE_SCRATCH hold the boot loader and
+the two image slots; newt uses them to decide where "newt load" writes
+each image.  Other areas, such as those used by the file system or by
+logs, are user defined.
+
+From the flash map, newt generates:
+    sysflash/sysflash.h     Area ID, device, offset, and size definitions
+                            (FLASH_AREA_<NAME>).
+    <target>-sysflash.c     The sysflash_map_dflt table used at runtime.
+
+Newt rejects flash maps with overlapping areas or duplicate area IDs.  A
+syscfg setting of type "flash_owner" claims a flash area for a single
+package; newt reports an error if two settings claim the same area.
+`,
+	},
+	{
+		name:  "split-images",
+		short: "Split images: running a loader and an application together",
+		long: `
+A split image divides firmware into two separately linked parts:
+
+    loader       A small, self-sufficient image (for example, the BLE
+                 stack plus the image management code) that lives in
+                 FLASH_AREA_IMAGE_0.
+    application  The remaining application code, which lives in
+                 FLASH_AREA_IMAGE_1 and calls into the loader.
+
+Because the application links against the loader, it can be upgraded
+independently while reusing the loader's code, which frees flash on
+devices too small to hold two full images.
+
+Configuring a split target
+    Set both the app and the loader of the target:
+
+        newt target set my_split app=apps/my_app loader=apps/bleprph
+
+    Packages required by both parts are placed in the loader; packages
+    required only by the application are placed in the application.
+
+Building and imaging
+    newt build my_split               Build the loader and the application.
+    newt create-image my_split 1.0.0  Create a pair of images; the
+                                      application image embeds the hash of
+                                      the loader it was linked against.
+    newt load my_split                Load both images onto the device.
+
+At boot, the loader runs first.  If a valid application image that
+matches the loader is present in the second slot, the loader transfers
+control to it; otherwise the loader keeps running on its own.
+`,
+	},
+}
+
+func AddHelpTopics(cmd *cobra.Command) {
+	for _, topic := range helpTopics {
+		cmd.AddCommand(&cobra.Command{
+			Use:   topic.name,
+			Short: topic.short,
+			Long:  strings.TrimSpace(topic.long),
+		})
+	}
+}
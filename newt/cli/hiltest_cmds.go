@@ -0,0 +1,209 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/devmgr"
+	"mynewt.apache.org/newt/newt/hiltest"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
+)
+
+var hilTestCollect string
+var hilTestTimeout int
+var hilTestJUnit string
+
+func hilTestRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		NewtUsage(cmd, util.NewNewtError(
+			"Must specify a target and at least one package"))
+	}
+	if deviceName == "" {
+		NewtUsage(cmd, util.NewNewtError("Must specify a device with --device"))
+	}
+
+	proj := TryGetProject()
+
+	t := ResolveTarget(args[0])
+	if t == nil {
+		NewtUsage(cmd, util.NewNewtError("Invalid target name: "+args[0]))
+	}
+
+	dev, err := devmgr.FindDevice(deviceName)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+	if dev == nil {
+		NewtUsage(nil, util.FmtNewtError(
+			"unknown device \"%s\"; devices are defined in %s",
+			deviceName, devmgr.DevicesPath()))
+	}
+
+	opts := hiltest.RunOpts{
+		Device:  dev,
+		Collect: hilTestCollect,
+		Timeout: time.Duration(hilTestTimeout) * time.Second,
+	}
+	switch hilTestCollect {
+	case hiltest.COLLECT_SERIAL:
+	case hiltest.COLLECT_SMP:
+		opts.Newtmgr, err = dev.Newtmgr(deviceNewtmgrPath)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+	default:
+		NewtUsage(cmd, util.FmtNewtError(
+			"--collect must be %s or %s", hiltest.COLLECT_SERIAL,
+			hiltest.COLLECT_SMP))
+	}
+
+	var packs []*pkg.LocalPackage
+	for _, pkgName := range args[1:] {
+		if pkgName == "all" {
+			packs = hiltest.TestPkgs(nil)
+			break
+		}
+
+		pack, err := proj.ResolvePackage(proj.LocalRepo(), pkgName)
+		if err != nil {
+			NewtUsage(cmd, err)
+		}
+
+		testPkgs := hiltest.TestPkgs(pack)
+		if len(testPkgs) == 0 {
+			NewtUsage(nil, util.FmtNewtError(
+				"Package %s contains no HIL tests", pack.FullName()))
+		}
+		packs = append(packs, testPkgs...)
+	}
+
+	if len(packs) == 0 {
+		NewtUsage(nil, util.NewNewtError("No HIL test packages found"))
+	}
+
+	var reports []*hiltest.Report
+	passedPkgs := []*pkg.LocalPackage{}
+	failedPkgs := []*pkg.LocalPackage{}
+	for _, pack := range packs {
+		// Reset the global state for the next test.
+		if err := ResetGlobalState(); err != nil {
+			NewtUsage(nil, err)
+		}
+
+		// The target must be resolved again after the reset.
+		t := ResolveTarget(args[0])
+		if t == nil {
+			NewtUsage(nil, util.NewNewtError("Invalid target name: "+args[0]))
+		}
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Testing package %s on device %s\n", pack.FullName(), dev.Name)
+
+		r := hiltest.Run(t, pack, opts)
+		reports = append(reports, r)
+
+		if r.Passed() {
+			passedPkgs = append(passedPkgs, pack)
+		} else {
+			failedPkgs = append(failedPkgs, pack)
+		}
+
+		for _, res := range r.Results {
+			if !res.Passed {
+				util.StatusMessage(util.VERBOSITY_QUIET,
+					"    FAILED: %s/%s: %s\n", res.Suite, res.Case, res.Msg)
+			}
+		}
+		if r.Err != nil {
+			util.StatusMessage(util.VERBOSITY_QUIET, "    Error: %s\n",
+				strings.TrimSpace(r.Err.Error()))
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"    %d test case(s), %d failed (%.1fs)\n",
+			len(r.Results), r.NumFailed(), r.Time.Seconds())
+	}
+
+	if hilTestJUnit != "" {
+		if err := hiltest.WriteJUnit(hilTestJUnit, reports); err != nil {
+			NewtUsage(nil, err)
+		}
+	}
+
+	passStr := fmt.Sprintf("Passed tests: [%s]", PackageNameList(passedPkgs))
+	failStr := fmt.Sprintf("Failed tests: [%s]", PackageNameList(failedPkgs))
+
+	if len(failedPkgs) > 0 {
+		NewtUsage(nil, util.FmtNewtError("Test failure(s):\n%s\n%s", passStr,
+			failStr))
+	} else {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", passStr)
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "All tests passed\n")
+	}
+}
+
+func AddHilTestCommands(cmd *cobra.Command) {
+	hilTestHelpText := "Run hardware-in-the-loop tests on a device.  Each " +
+		"specified package's HIL tests (app packages in its hil_test " +
+		"directory) are built for <target-name>'s BSP, flashed to the " +
+		"device with the BSP's download script, and run.  Specify \"all\" " +
+		"to run every HIL test in the project.\n\n"
+	hilTestHelpText += "Results are read from the device's console " +
+		"(--collect serial, the default) or from the device's test log " +
+		"over SMP (--collect smp).  A test app reports each test case on " +
+		"a line of the form \"[pass] <suite>/<case>\" or \"[FAIL] " +
+		"<suite>/<case>: <message>\", and marks the end of the run with " +
+		"\"[done]\".  With --junit, a JUnit XML report is written with one " +
+		"test suite per package."
+
+	hilTestHelpEx := "  newt hiltest nrf52dk hw/drivers/sensors " +
+		"--device devkit1\n"
+	hilTestHelpEx += "  newt hiltest nrf52dk all --device devkit1 " +
+		"--collect smp --junit hil.xml\n"
+
+	hilTestCmd := &cobra.Command{
+		Use: "hiltest <target-name> <package-name> [package-names...] " +
+			"| all",
+		Short:   "Run hardware-in-the-loop tests on a device",
+		Long:    hilTestHelpText,
+		Example: hilTestHelpEx,
+		Run:     hilTestRunCmd,
+	}
+	hilTestCmd.Flags().StringVarP(&deviceName, "device", "d", "",
+		"Name of the device in devices.yml")
+	hilTestCmd.Flags().StringVar(&hilTestCollect, "collect",
+		hiltest.COLLECT_SERIAL, "How results are collected (serial or smp)")
+	hilTestCmd.Flags().StringVar(&deviceNewtmgrPath, "newtmgr",
+		devmgr.NEWTMGR_DFLT_PATH, "Path of the newtmgr executable")
+	hilTestCmd.Flags().IntVar(&hilTestTimeout, "timeout", 60,
+		"Seconds to wait for each test package to finish")
+	hilTestCmd.Flags().StringVar(&hilTestJUnit, "junit", "",
+		"Write a JUnit XML report to this file")
+
+	cmd.AddCommand(hilTestCmd)
+	AddTabCompleteFn(hilTestCmd, func() []string {
+		return append(targetList(), "all")
+	})
+}
@@ -29,7 +29,9 @@ import (
 	"github.com/apache/mynewt-artifact/sec"
 	"mynewt.apache.org/newt/newt/builder"
 	"mynewt.apache.org/newt/newt/imgprod"
+	"mynewt.apache.org/newt/newt/manifest"
 	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/target"
 	"mynewt.apache.org/newt/util"
 )
 
@@ -41,27 +43,51 @@ var encKeyIndex int
 var hdrPad int
 var imagePad int
 var sections string
+var confirmImage bool
+var imageAlign int
+var imageFillSlot bool
+var imageDepStrs []string
+var signManifest bool
+var manifestKeyFilename string
+var imageSigKeyFilenames []string
+var imageVerifyKeys []string
+var imageVerifyTarget string
+var imageVerifyLoader string
+
+// Determines which image format to produce for a target.  The -1 and -2
+// flags override the target's `target.image_format` setting.
+func imageFormatName(t *target.Target) string {
+	if useV1 {
+		return imgprod.IMAGE_FORMAT_V1
+	}
+	if useV2 {
+		return imgprod.IMAGE_FORMAT_V2
+	}
 
+	return t.ImageFormat
+}
+
+// @param keyId                 Whether args[1] is a key ID (image format v1).
 // @return                      keys, key ID, error
-func parseKeyArgs(args []string) ([]sec.PrivSignKey, uint8, error) {
+func parseKeyArgs(args []string, keyId bool) ([]sec.PrivSignKey, uint8, error) {
 	if len(args) == 0 {
 		return nil, 0, nil
 	}
 
-	var keyId uint8
+	var id uint8
 	var keyFilenames []string
 
 	if len(args) == 1 {
 		keyFilenames = append(keyFilenames, args[0])
-	} else if useV1 {
+	} else if keyId {
 		keyIdUint, err := strconv.ParseUint(args[1], 10, 8)
 		if err != nil {
 			return nil, 0, util.NewNewtError("Key ID must be between 0-255")
 		}
-		keyId = uint8(keyIdUint)
+		id = uint8(keyIdUint)
 		keyFilenames = args[:1]
 	} else {
-		keyId = 0
+		id = 0
 		keyFilenames = args
 	}
 
@@ -70,7 +96,7 @@ func parseKeyArgs(args []string) ([]sec.PrivSignKey, uint8, error) {
 		return nil, 0, err
 	}
 
-	return keys, keyId, nil
+	return keys, id, nil
 }
 
 func createImageRunCmd(cmd *cobra.Command, args []string) {
@@ -86,8 +112,31 @@ func createImageRunCmd(cmd *cobra.Command, args []string) {
 		NewtUsage(cmd, util.NewNewtError("Either -1, or -2, but not both"))
 	}
 
-	if !useV1 {
-		useV2 = true
+	if imageFillSlot && confirmImage {
+		NewtUsage(cmd, util.NewNewtError(
+			"--pad-slot and --confirm cannot be used together; --confirm "+
+				"already fills the slot in the hex file"))
+	}
+	if imageAlign < 0 {
+		NewtUsage(cmd, util.NewNewtError("--align must not be negative"))
+	}
+
+	var keyArgs []string
+	keyArgs = append(keyArgs, imageSigKeyFilenames...)
+	keyArgs = append(keyArgs, args[2:]...)
+
+	if signManifest && len(keyArgs) == 0 && manifestKeyFilename == "" {
+		NewtUsage(cmd, util.NewNewtError(
+			"--sign-manifest requires a signing key or --manifest-key"))
+	}
+
+	var deps []imgprod.ImageDep
+	for _, s := range imageDepStrs {
+		dep, err := imgprod.ParseImageDep(s)
+		if err != nil {
+			NewtUsage(cmd, err)
+		}
+		deps = append(deps, dep)
 	}
 
 	TryGetProject()
@@ -113,7 +162,27 @@ func createImageRunCmd(cmd *cobra.Command, args []string) {
 		NewtUsage(nil, err)
 	}
 
-	keys, _, err := parseKeyArgs(args[2:])
+	fmtName := imageFormatName(t)
+	imgFmt, err := imgprod.LookupImageFormat(fmtName)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	useKeyId := fmtName == imgprod.IMAGE_FORMAT_V1
+	if useKeyId && len(imageSigKeyFilenames) > 1 {
+		NewtUsage(cmd, util.NewNewtError(
+			"image format v1 supports a single signing key"))
+	}
+
+	// A v1 key ID follows the key in the positional arguments; it cannot be
+	// combined with a key specified with -k.
+	if useKeyId && len(imageSigKeyFilenames) > 0 && len(args) > 2 {
+		NewtUsage(cmd, util.NewNewtError(
+			"image format v1: specify the signing key either with -k or "+
+				"as a positional argument, not both"))
+	}
+
+	keys, _, err := parseKeyArgs(keyArgs, useKeyId)
 	if err != nil {
 		NewtUsage(cmd, err)
 	}
@@ -135,16 +204,130 @@ func createImageRunCmd(cmd *cobra.Command, args []string) {
 			stat.ModTime().Minute()*100 + stat.ModTime().Second())
 	}
 
-	if useV1 {
-		err = imgprod.ProduceAllV1(b, ver, keys, encKeyFilename, encKeyIndex,
-			hdrPad, imagePad, sections, useLegacyTLV)
+	err = imgFmt.Produce(b, imgprod.ImageFormatOpts{
+		Version:        ver,
+		SigKeys:        keys,
+		EncKeyFilename: encKeyFilename,
+		EncKeyIndex:    encKeyIndex,
+		HdrPad:         hdrPad,
+		ImagePad:       imagePad,
+		Sections:       sections,
+		UseLegacyTLV:   useLegacyTLV,
+		Deps:           deps,
+	})
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	padOpts := imgprod.ImagePadOpts{
+		Align:    imageAlign,
+		FillSlot: imageFillSlot,
+	}
+	if err := imgprod.PadImages(b, padOpts); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if confirmImage {
+		if err := imgprod.ProduceConfirmedHex(b); err != nil {
+			NewtUsage(nil, err)
+		}
+	}
+
+	if signManifest || manifestKeyFilename != "" {
+		mkeys := keys
+		if manifestKeyFilename != "" {
+			mkeys, err = sec.ReadPrivSignKeys([]string{manifestKeyFilename})
+			if err != nil {
+				NewtUsage(nil, err)
+			}
+		}
+
+		if err := manifest.SignFile(b.AppBuilder.ManifestPath(),
+			mkeys); err != nil {
+
+			NewtUsage(nil, err)
+		}
+	}
+}
+
+func imageVerifyRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify image file"))
+	}
+
+	imgPath := args[0]
+
+	var keys []sec.PubSignKey
+	if len(imageVerifyKeys) > 0 {
+		var err error
+		keys, err = sec.ReadPubSignKeys(imageVerifyKeys)
+		if err != nil {
+			NewtUsage(nil, util.ChildNewtError(err))
+		}
+	}
+
+	res, err := imgprod.VerifyImageFile(imgPath, imageVerifyLoader, keys)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s: version %s, %d bytes\n",
+		imgPath, res.Version.String(), res.Size)
+
+	if res.HashVerified {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "    hash: OK\n")
+	} else if res.Encrypted {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"    hash: not checked (image is encrypted)\n")
+	} else {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"    hash: not checked (split app image; specify its "+
+				"loader with --loader)\n")
+	}
+
+	if res.SigVerified {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "    signature: OK\n")
+	} else if res.NumSigs == 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "    signature: none\n")
 	} else {
-		err = imgprod.ProduceAll(b, ver, keys, encKeyFilename, encKeyIndex,
-			hdrPad, imagePad, sections, useLegacyTLV)
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"    signature: %d present, not checked (no key specified)\n",
+			res.NumSigs)
+	}
+
+	if imageVerifyTarget == "" {
+		return
+	}
+
+	TryGetProject()
+
+	t := ResolveTarget(imageVerifyTarget)
+	if t == nil {
+		NewtUsage(cmd, util.NewNewtError(
+			"Invalid target name: "+imageVerifyTarget))
 	}
+
+	b, err := builder.NewTargetBuilder(t)
 	if err != nil {
 		NewtUsage(nil, err)
 	}
+
+	fit, err := imgprod.CheckSlotFit(b, res.Size)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if !fit.Fits {
+		NewtUsage(nil, util.FmtNewtError(
+			"image does not fit in %s of target %s: %d > %d bytes "+
+				"(%d-byte slot less the boot trailer)",
+			fit.AreaName, t.FullName(), res.Size, fit.MaxSize,
+			fit.SlotSize))
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"    slot: OK (%d of %d bytes in %s of target %s)\n",
+		res.Size, fit.MaxSize, fit.AreaName, t.FullName())
 }
 
 func AddImageCommands(cmd *cobra.Command) {
@@ -161,18 +344,51 @@ func AddImageCommands(cmd *cobra.Command) {
 	createImageHelpText += "To sign version 2 of the image format give private " +
 		"key as <signing-key> (no key-id needed).\n\n"
 
-	createImageHelpText += "Default image format is version 1.\n"
+	createImageHelpText += "Signing keys can also be given with -k, which may " +
+		"be repeated.  Each key adds a KEYHASH and signature TLV pair to " +
+		"a version 2 image, so devices provisioned with any of the keys " +
+		"can verify it.  Signing keys are RSA (2048 or 3072 bits), ECDSA " +
+		"(P-224 or P-256), or Ed25519 private keys in PEM format.  Ed25519 " +
+		"keys require version 2 of the image format.\n\n"
+
+	createImageHelpText += "Default image format is version 2, unless the " +
+		"target selects a format with the target.image_format setting.\n"
 
 	createImageHelpText += "To encrypt the image, specify -e passing it a public" +
 		"key\n\n"
 
+	createImageHelpText += "To express a dependency on another image in a " +
+		"multi-image setup, specify --depends <image-id>:<min-version>.  " +
+		"The dependency is stored in a protected TLV, covered by the " +
+		"image hash and signature.\n\n"
+
+	createImageHelpText += "To pad the image file to a multiple of the flash " +
+		"erase sector size (e.g., for XIP from external flash), specify " +
+		"--align.  To pad it to the full size of its slot, specify " +
+		"--pad-slot.  Padding is appended after the image trailer and " +
+		"does not affect the image hash.\n\n"
+
+	createImageHelpText += "To sign the generated manifest with the image " +
+		"signing key(s), specify --sign-manifest.  To sign it with a " +
+		"separate key, specify --manifest-key.  The signature is written " +
+		"to manifest.json.sig and can be checked with `newt manifest " +
+		"verify`.\n"
+
 	createImageHelpEx := "  newt create-image my_target1 1.3.0\n"
 	createImageHelpEx += "  newt create-image my_target1 1.3.0.3\n"
 	createImageHelpEx += "  newt create-image my_target1 1.3.0.3 private.pem\n"
+	createImageHelpEx += "  newt create-image my_target1 1.3.0.3 -k ed25519.pem\n"
+	createImageHelpEx += "  newt create-image my_target1 1.3.0.3 -k root-a.pem " +
+		"-k root-b.pem\n"
 	createImageHelpEx +=
 		"  newt create-image -2 my_target1 1.3.0.3 private-1.pem private-2.pem\n"
 	createImageHelpEx += "  newt create-image my_target1 1.3.0.3 -H 3 -e " +
-		"aes_key\n\n"
+		"aes_key\n"
+	createImageHelpEx += "  newt create-image my_target1 1.3.0 --align 4096\n"
+	createImageHelpEx += "  newt create-image my_target1 1.3.0 private.pem " +
+		"--depends 1:1.2.0\n"
+	createImageHelpEx += "  newt create-image my_target1 1.3.0 private.pem " +
+		"--sign-manifest\n\n"
 
 	createImageCmd := &cobra.Command{
 		Use: "create-image <target-name> <version> [signing-key-1] " +
@@ -206,6 +422,31 @@ func AddImageCommands(cmd *cobra.Command) {
 	createImageCmd.PersistentFlags().StringVarP(&sections,
 		"sections", "S", "", "Section names for TLVs, comma delimited")
 
+	createImageCmd.PersistentFlags().StringArrayVarP(&imageDepStrs,
+		"depends", "d", nil, "Add a protected dependency TLV requiring "+
+			"<image-id>:<min-version> (e.g., 1:1.2.0); may be repeated")
+	createImageCmd.PersistentFlags().IntVar(&imageAlign,
+		"align", 0, "Pad the image file with the flash erase value to a "+
+			"multiple of this many bytes (e.g., the erase sector size)")
+	createImageCmd.PersistentFlags().BoolVar(&imageFillSlot,
+		"pad-slot", false, "Pad the image file with the flash erase value "+
+			"to the full size of its slot")
+
+	createImageCmd.PersistentFlags().StringArrayVarP(&imageSigKeyFilenames,
+		"key", "k", nil, "Sign the image with this private key (RSA, "+
+			"ECDSA, or Ed25519); may be repeated")
+
+	createImageCmd.PersistentFlags().BoolVar(&signManifest,
+		"sign-manifest", false, "Write a detached signature of the "+
+			"manifest, generated with the image signing key(s)")
+	createImageCmd.PersistentFlags().StringVar(&manifestKeyFilename,
+		"manifest-key", "", "Sign the manifest with this private key "+
+			"instead of the image signing key(s)")
+
+	createImageCmd.PersistentFlags().BoolVar(&confirmImage,
+		"confirm", false, "Initialize the boot trailer in the generated "+
+			"hex file so that the image boots from slot 0 as confirmed")
+
 	createImageCmd.PersistentFlags().BoolVarP(&useLegacyTLV,
 		"legacy-tlvs", "L", false, "Use legacy TLV values for NONCE and SECRET_ID")
 
@@ -228,4 +469,44 @@ func AddImageCommands(cmd *cobra.Command) {
 	}
 
 	cmd.AddCommand(resignImageCmd)
+
+	imageCmd := &cobra.Command{
+		Use:   "image",
+		Short: "Image file commands",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	cmd.AddCommand(imageCmd)
+
+	imageVerifyHelpText := "Verify an image file.  The image's hash is " +
+		"recomputed and compared against its SHA256 TLV, and all of its " +
+		"TLVs are validated.\n\n"
+	imageVerifyHelpText += "If one or more public keys are specified with " +
+		"-k, the image must be signed by one of the corresponding private " +
+		"keys.  If a target is specified with -t, the image must fit in the " +
+		"slot that the target's app runs from.\n\n"
+	imageVerifyHelpText += "The hash of a split app image is seeded with " +
+		"the hash of its loader; it is only verified if the loader image " +
+		"is specified with --loader."
+
+	imageVerifyHelpEx := "  newt image verify my_app.img\n"
+	imageVerifyHelpEx += "  newt image verify my_app.img -k pub-key.pem " +
+		"-t my_target1\n"
+
+	imageVerifyCmd := &cobra.Command{
+		Use:     "verify <image-file>",
+		Short:   "Verify an image's hash, TLVs, signature, and size",
+		Long:    imageVerifyHelpText,
+		Example: imageVerifyHelpEx,
+		Run:     imageVerifyRunCmd,
+	}
+	imageVerifyCmd.Flags().StringArrayVarP(&imageVerifyKeys, "key", "k", nil,
+		"Public key to verify the image signature with; may be repeated")
+	imageVerifyCmd.Flags().StringVarP(&imageVerifyTarget, "target", "t", "",
+		"Check that the image fits in this target's slot")
+	imageVerifyCmd.Flags().StringVar(&imageVerifyLoader, "loader", "",
+		"Loader image of a split app image, for verifying its hash")
+
+	imageCmd.AddCommand(imageVerifyCmd)
 }
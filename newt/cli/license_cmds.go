@@ -0,0 +1,177 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/license"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
+)
+
+var licenseStrict bool
+
+func licenseCheckRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target"))
+	}
+
+	proj := TryGetProject()
+
+	t := ResolveTarget(args[0])
+	if t == nil {
+		NewtUsage(cmd, util.NewNewtError("Invalid target name: "+args[0]))
+	}
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	res, err := b.Resolve()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	lpkgs := make([]*pkg.LocalPackage, 0, len(res.MasterSet.Rpkgs))
+	for _, rpkg := range res.MasterSet.Rpkgs {
+		lpkgs = append(lpkgs, rpkg.Lpkg)
+	}
+	sort.Slice(lpkgs, func(i int, j int) bool {
+		return lpkgs[i].FullName() < lpkgs[j].FullName()
+	})
+
+	// [repo-name] => set of licenses found in the repo.
+	repoLics := map[string]map[string]struct{}{}
+
+	numConflicts := 0
+	numUnlicensed := 0
+
+	for _, lpkg := range lpkgs {
+		report, err := license.AuditPkg(lpkg)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+
+		lics := report.Licenses()
+		if len(lics) == 0 {
+			continue
+		}
+
+		declared := report.Declared
+		if declared == "" {
+			declared = "undeclared"
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s (%s)\n",
+			lpkg.FullName(), declared)
+
+		rname := lpkg.Repo().Name()
+		if repoLics[rname] == nil {
+			repoLics[rname] = map[string]struct{}{}
+		}
+
+		for _, lic := range lics {
+			repoLics[rname][lic] = struct{}{}
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"    %-16s %d file(s)\n", lic, len(report.Files[lic]))
+		}
+
+		for _, file := range report.Conflicts {
+			util.StatusMessage(util.VERBOSITY_QUIET,
+				"    conflict: %s\n",
+				strings.TrimPrefix(file, proj.Path()+"/"))
+		}
+		numConflicts += len(report.Conflicts)
+
+		unlicensed := report.Files[license.LICENSE_NONE]
+		for _, file := range unlicensed {
+			util.StatusMessage(util.VERBOSITY_VERBOSE,
+				"    no header: %s\n",
+				strings.TrimPrefix(file, proj.Path()+"/"))
+		}
+		numUnlicensed += len(unlicensed)
+	}
+
+	rnames := make([]string, 0, len(repoLics))
+	for rname, _ := range repoLics {
+		rnames = append(rnames, rname)
+	}
+	sort.Strings(rnames)
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "\nLicenses by repo:\n")
+	for _, rname := range rnames {
+		lics := make([]string, 0, len(repoLics[rname]))
+		for lic, _ := range repoLics[rname] {
+			lics = append(lics, lic)
+		}
+		sort.Strings(lics)
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "    @%s: %s\n",
+			rname, strings.Join(lics, ", "))
+	}
+
+	if numConflicts > 0 {
+		NewtUsage(nil, util.FmtNewtError(
+			"%d source file(s) carry a license incompatible with their "+
+				"package's declared license", numConflicts))
+	}
+	if licenseStrict && numUnlicensed > 0 {
+		NewtUsage(nil, util.FmtNewtError(
+			"%d source file(s) lack a license header", numUnlicensed))
+	}
+}
+
+func AddLicenseCommands(cmd *cobra.Command) {
+	licenseCmd := &cobra.Command{
+		Use:   "license",
+		Short: "Audit package licenses",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+
+	cmd.AddCommand(licenseCmd)
+
+	checkHelpText := "Scan the source files of every package in the " +
+		"specified target for license headers.  Files are identified by " +
+		"their SPDX-License-Identifier tag or by well-known license text.\n\n" +
+		"The command fails if a file carries a license that is incompatible " +
+		"with the license declared in its package's pkg.yml file " +
+		"(`pkg.license`).  Permissive licenses are compatible with any " +
+		"declared license."
+
+	checkCmd := &cobra.Command{
+		Use:   "check <target-name>",
+		Short: "Check license headers of a target's source files",
+		Long:  checkHelpText,
+		Run:   licenseCheckRunCmd,
+	}
+
+	checkCmd.Flags().BoolVarP(&licenseStrict, "strict", "", false,
+		"Fail if any source file lacks a license header")
+
+	licenseCmd.AddCommand(checkCmd)
+	AddTabCompleteFn(checkCmd, targetList)
+}
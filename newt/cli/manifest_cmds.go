@@ -0,0 +1,125 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/apache/mynewt-artifact/sec"
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/manifest"
+	"mynewt.apache.org/newt/util"
+)
+
+var manifestVerifyKeys []string
+
+// Determines the manifest path from a command line argument.  The argument is
+// either the path of a manifest file or the name of a target.
+func resolveManifestPath(arg string) (string, error) {
+	if util.NodeExist(arg) {
+		return arg, nil
+	}
+
+	TryGetProject()
+
+	t := ResolveTarget(arg)
+	if t == nil {
+		return "", util.FmtNewtError(
+			"\"%s\" is neither a manifest file nor a target", arg)
+	}
+	if t.App() == nil {
+		return "", util.FmtNewtError(
+			"target %s does not specify an app package", t.FullName())
+	}
+
+	return builder.ManifestPath(t.FullName(), builder.BUILD_NAME_APP,
+		t.App().FullName()), nil
+}
+
+func manifestVerifyRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target or manifest"))
+	}
+
+	mpath, err := resolveManifestPath(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	sigChecked := false
+	if len(manifestVerifyKeys) > 0 {
+		keys, err := sec.ReadPubSignKeys(manifestVerifyKeys)
+		if err != nil {
+			NewtUsage(nil, util.ChildNewtError(err))
+		}
+
+		if err := manifest.VerifySig(mpath, keys); err != nil {
+			NewtUsage(nil, err)
+		}
+		sigChecked = true
+	}
+
+	numImgs, err := manifest.VerifyImageHashes(mpath)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n",
+		manifest.VerifyDesc(mpath, numImgs, sigChecked))
+}
+
+func AddManifestCommands(cmd *cobra.Command) {
+	manifestCmd := &cobra.Command{
+		Use:   "manifest",
+		Short: "Manifest commands",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	cmd.AddCommand(manifestCmd)
+
+	verifyHelpText := "Verify a target's manifest.  The image files that " +
+		"the manifest refers to must exist and match the hashes recorded in " +
+		"the manifest.\n\n"
+	verifyHelpText += "If one or more public keys are specified with -k, " +
+		"the manifest's detached signature (manifest.json.sig) must also " +
+		"have been generated by one of the corresponding private keys.  " +
+		"Without -k, the signature is not checked."
+
+	verifyHelpEx := "  newt manifest verify my_target1\n"
+	verifyHelpEx += "  newt manifest verify my_target1 -k pub-key.pem\n"
+	verifyHelpEx += "  newt manifest verify path/to/manifest.json " +
+		"-k pub-key.pem\n"
+
+	verifyCmd := &cobra.Command{
+		Use:     "verify <target-name | manifest-path>",
+		Short:   "Verify a manifest's signature and image hashes",
+		Long:    verifyHelpText,
+		Example: verifyHelpEx,
+		Run:     manifestVerifyRunCmd,
+	}
+	verifyCmd.Flags().StringArrayVarP(&manifestVerifyKeys, "key", "k", nil,
+		"Public key to verify the manifest signature with; may be repeated")
+
+	manifestCmd.AddCommand(verifyCmd)
+	AddTabCompleteFn(verifyCmd, targetList)
+
+	addManifestOtaCommand(manifestCmd)
+}
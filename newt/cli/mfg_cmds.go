@@ -26,12 +26,20 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/apache/mynewt-artifact/image"
+	"github.com/apache/mynewt-artifact/sec"
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/imgprod"
+	"mynewt.apache.org/newt/newt/manifest"
 	"mynewt.apache.org/newt/newt/mfg"
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/parse"
 	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/syscfg"
 	"mynewt.apache.org/newt/util"
 )
 
 var baseAddress int
+var mfgSkipBuild bool
 
 func ResolveMfgPkg(pkgName string) (*pkg.LocalPackage, error) {
 	proj := TryGetProject()
@@ -55,6 +63,113 @@ func ResolveMfgPkg(pkgName string) (*pkg.LocalPackage, error) {
 	return lpkg, nil
 }
 
+// Rebuilds a single mfg target with the specified syscfg overrides injected.
+// The target is built in a separate output directory so that its regular
+// artifacts are left untouched.  Boot loaders only get a bare manifest;
+// images are regenerated in the target's image format with the given version
+// and signed with the given keys.
+func mfgRebuildTarget(rb mfg.TargetRebuild, ver image.ImageVersion,
+	keys []sec.PrivSignKey) {
+
+	newtutil.NewtOutDir = rb.BinRoot
+	if err := ResetGlobalState(); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	t := ResolveTarget(rb.Name)
+	if t == nil {
+		NewtUsage(nil, util.FmtNewtError(
+			"mfg references undefined target \"%s\"", rb.Name))
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Building target %s in %s with mfg syscfg overrides: %s\n",
+		t.FullName(), rb.BinRoot, util.InjectSyscfg)
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if err := b.Build(); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	res, err := b.Resolve()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if parse.ValueIsTrue(res.Cfg.SettingValues().Get("BOOT_LOADER")) {
+		mopts, err := manifest.OptsForNonImage(b)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+		if err := imgprod.ProduceManifest(mopts); err != nil {
+			NewtUsage(nil, err)
+		}
+	} else {
+		imgFmt, err := imgprod.LookupImageFormat(t.ImageFormat)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+
+		err = imgFmt.Produce(b, imgprod.ImageFormatOpts{
+			Version:     ver,
+			SigKeys:     keys,
+			EncKeyIndex: -1,
+		})
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+	}
+}
+
+// Rebuilds every target in the specified mfg package that specifies syscfg
+// overrides, signing their images with the specified keys.  Because
+// rebuilding resets the global project state, the mfg package is resolved
+// again and returned.  If the user specified `--skip-build`, nothing is
+// rebuilt; the existing target artifacts are verified instead.
+func mfgRebuildTargets(pkgName string, lpkg *pkg.LocalPackage,
+	ver image.ImageVersion, keys []sec.PrivSignKey) *pkg.LocalPackage {
+
+	if mfgSkipBuild {
+		if err := mfg.VerifyTargetArtifacts(lpkg); err != nil {
+			NewtUsage(nil, err)
+		}
+		return lpkg
+	}
+
+	rebuilds, err := mfg.LoadTargetRebuilds(lpkg)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if len(rebuilds) == 0 {
+		return lpkg
+	}
+
+	origSyscfg := util.InjectSyscfg
+	origOutDir := newtutil.NewtOutDir
+	for _, rb := range rebuilds {
+		util.InjectSyscfg = syscfg.KeyValueToStr(rb.Syscfg)
+		mfgRebuildTarget(rb, ver, keys)
+	}
+	util.InjectSyscfg = origSyscfg
+	newtutil.NewtOutDir = origOutDir
+
+	if err := ResetGlobalState(); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	lpkg, err = ResolveMfgPkg(pkgName)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	return lpkg
+}
+
 func mfgCreate(me mfg.MfgEmitter) {
 	srcPaths, dstPaths, err := me.Emit()
 	if err != nil {
@@ -111,11 +226,13 @@ func mfgCreateRunCmd(cmd *cobra.Command, args []string) {
 		NewtUsage(cmd, err)
 	}
 
-	keys, _, err := parseKeyArgs(args[2:])
+	keys, _, err := parseKeyArgs(args[2:], false)
 	if err != nil {
 		NewtUsage(nil, err)
 	}
 
+	lpkg = mfgRebuildTargets(pkgName, lpkg, ver, keys)
+
 	me, err := mfg.LoadMfgEmitter(lpkg, ver, keys, baseAddress)
 	if err != nil {
 		NewtUsage(nil, err)
@@ -158,6 +275,8 @@ func mfgDeployRunCmd(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	lpkg = mfgRebuildTargets(pkgName, lpkg, ver, nil)
+
 	me, err := mfg.LoadMfgEmitter(lpkg, ver, nil, baseAddress)
 	if err != nil {
 		NewtUsage(nil, err)
@@ -191,6 +310,9 @@ func AddMfgCommands(cmd *cobra.Command) {
 		Short: "Create a manufacturing flash image",
 		Run:   mfgCreateRunCmd,
 	}
+	mfgCreateCmd.Flags().BoolVar(&mfgSkipBuild, "skip-build", false,
+		"Package previously built target artifacts without rebuilding; "+
+			"images are verified against their manifests")
 	mfgCmd.AddCommand(mfgCreateCmd)
 	AddTabCompleteFn(mfgCreateCmd, mfgList)
 
@@ -207,6 +329,9 @@ func AddMfgCommands(cmd *cobra.Command) {
 		Short: "Build and upload a manufacturing image (create + load)",
 		Run:   mfgDeployRunCmd,
 	}
+	mfgDeployCmd.Flags().BoolVar(&mfgSkipBuild, "skip-build", false,
+		"Package previously built target artifacts without rebuilding; "+
+			"images are verified against their manifests")
 	mfgCmd.AddCommand(mfgDeployCmd)
 	AddTabCompleteFn(mfgDeployCmd, mfgList)
 }
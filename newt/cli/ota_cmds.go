@@ -0,0 +1,154 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/apache/mynewt-artifact/manifest"
+	"mynewt.apache.org/newt/newt/ota"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
+)
+
+var otaFormat string
+var otaMinVersion string
+var otaOutput string
+
+// Reads the hardware IDs (bsp.hw_ids) of the BSP that the manifest's image
+// was built for.  If the manifest's target still exists, its BSP overrides
+// are honored; otherwise the BSP is taken from the manifest's target
+// variables.
+func otaHwIds(m manifest.Manifest) ([]string, error) {
+	proj := TryGetProject()
+
+	var bspPkg *pkg.LocalPackage
+	var yov *pkg.BspYCfgOverride
+
+	if t := ResolveTarget(m.Name); t != nil && t.Bsp() != nil {
+		bspPkg = t.Bsp()
+		yov = t.GetBspYCfgOverride()
+	} else {
+		for _, kv := range m.TgtVars {
+			if strings.HasPrefix(kv, "target.bsp=") {
+				name := strings.TrimPrefix(kv, "target.bsp=")
+				lpkg, err := proj.ResolvePackage(proj.LocalRepo(), name)
+				if err != nil {
+					return nil, err
+				}
+				bspPkg = lpkg
+			}
+		}
+	}
+
+	if bspPkg == nil {
+		return nil, util.FmtNewtError(
+			"cannot determine the BSP of target %s", m.Name)
+	}
+
+	bsp, err := pkg.NewBspPackage(bspPkg, yov)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bsp.HwIds) == 0 {
+		util.StatusMessage(util.VERBOSITY_QUIET,
+			"* Warning: BSP %s does not specify any hardware IDs "+
+				"(bsp.hw_ids)\n", bsp.FullName())
+	}
+
+	return bsp.HwIds, nil
+}
+
+func manifestOtaRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target or manifest"))
+	}
+
+	mpath, err := resolveManifestPath(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	m, err := manifest.ReadManifest(mpath)
+	if err != nil {
+		NewtUsage(nil, util.ChildNewtError(err))
+	}
+
+	hwIds, err := otaHwIds(m)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	meta, err := ota.NewMeta(m, hwIds, otaMinVersion)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	out, err := ota.Emit(otaFormat, meta)
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	if otaOutput != "" {
+		if err := ioutil.WriteFile(otaOutput, out, 0644); err != nil {
+			NewtUsage(nil, util.ChildNewtError(err))
+		}
+	} else {
+		util.StatusMessage(util.VERBOSITY_QUIET, "%s", string(out))
+	}
+}
+
+func addManifestOtaCommand(manifestCmd *cobra.Command) {
+	otaHelpText := FormatHelp(`Generate the metadata that an update server
+		needs to roll out a target's image: the image version, the hardware
+		IDs it runs on (bsp.hw_ids in the BSP's bsp.yml), the image's size,
+		hash, and signatures, and optionally the oldest version a device may
+		update from.  The metadata is read from the manifest and the image
+		it refers to, so the image must have been created with
+		"newt create-image".`)
+	otaHelpText += "\n\nSupported formats: " +
+		strings.Join(ota.FormatNames(), ", ")
+
+	otaHelpEx := "  newt manifest ota my_target1\n"
+	otaHelpEx += "  newt manifest ota my_target1 --format hawkbit " +
+		"--min-version 1.2.0 --output ota.json\n"
+	otaHelpEx += "  newt manifest ota path/to/manifest.json --format golioth"
+
+	otaCmd := &cobra.Command{
+		Use:     "ota <target-name | manifest-path>",
+		Short:   "Generate update server metadata for an image",
+		Long:    otaHelpText,
+		Example: otaHelpEx,
+		Run:     manifestOtaRunCmd,
+	}
+	otaCmd.Flags().StringVarP(&otaFormat, "format", "f", "json",
+		"Output format ("+strings.Join(ota.FormatNames(), ", ")+")")
+	otaCmd.Flags().StringVar(&otaMinVersion, "min-version", "",
+		"Oldest version that a device may update from")
+	otaCmd.Flags().StringVar(&otaOutput, "output", "",
+		"Write the metadata to a file instead of stdout")
+
+	manifestCmd.AddCommand(otaCmd)
+	AddTabCompleteFn(otaCmd, targetList)
+}
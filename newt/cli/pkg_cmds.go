@@ -27,6 +27,7 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
+	"mynewt.apache.org/newt/newt/builder"
 	"mynewt.apache.org/newt/newt/interfaces"
 	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/pkg"
@@ -211,6 +212,33 @@ func pkgRemoveCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+func pkgFilesCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		NewtUsage(cmd, util.NewNewtError(
+			"Must specify a target name and a package name"))
+	}
+
+	TryGetProject()
+
+	b, err := TargetBuilderForTargetOrUnittest(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	lpkgs, err := ResolvePackages(args[1:])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	pfs, err := b.PkgFiles(lpkgs[0].FullName())
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s",
+		builder.PkgFilesText(pfs))
+}
+
 func AddPackageCommands(cmd *cobra.Command) {
 	/* Add the base package command, on top of which other commands are
 	 * keyed
@@ -285,4 +313,25 @@ func AddPackageCommands(cmd *cobra.Command) {
 	}
 
 	pkgCmd.AddCommand(removeCmd)
+
+	filesCmdHelpText := "List the source files of <package-name> as " +
+		"built by <target-name>.  Each file is shown as compiled or " +
+		"excluded; for an excluded file, the pkg.ignore_files or " +
+		"pkg.ignore_dirs rule, or the architecture directory, that " +
+		"excludes it is shown.  Ignore rules that match no file or " +
+		"directory are listed at the end."
+	filesCmdHelpEx := "  newt pkg files my_target1 sys/log/full"
+
+	filesCmd := &cobra.Command{
+		Use:     "files <target-name> <package-name>",
+		Short:   "List the files of a package that a target compiles",
+		Long:    filesCmdHelpText,
+		Example: filesCmdHelpEx,
+		Run:     pkgFilesCmd,
+	}
+
+	pkgCmd.AddCommand(filesCmd)
+	AddTabCompleteFn(filesCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
 }
@@ -0,0 +1,131 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/util"
+)
+
+var profileElf string
+var profileLimit int
+
+func profileDecodeRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		NewtUsage(cmd, util.NewNewtError(
+			"Must specify target and samples file"))
+	}
+
+	TryGetProject()
+
+	elfPath := profileElf
+	if elfPath == "" {
+		t := ResolveTarget(args[0])
+		if t == nil {
+			NewtUsage(cmd, util.NewNewtError("Invalid target name: "+args[0]))
+		}
+		if t.App() == nil {
+			NewtUsage(nil, util.FmtNewtError(
+				"target %s does not specify an app package", t.FullName()))
+		}
+
+		elfPath = builder.AppElfPath(t.FullName(), builder.BUILD_NAME_APP,
+			t.App().FullName())
+		if util.NodeNotExist(elfPath) {
+			NewtUsage(nil, util.FmtNewtError(
+				"target %s has not been built (%s is missing); run "+
+					"`newt build --instrument functions %s` first",
+				t.FullName(), elfPath, t.Name()))
+		}
+	}
+
+	samples, unknown, err := builder.DecodeProfile(elfPath, args[1])
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	total := unknown
+	for _, s := range samples {
+		total += s.Count
+	}
+	if total == 0 {
+		NewtUsage(nil, util.FmtNewtError("%s contains no samples", args[1]))
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%10s %7s  %-10s  %s\n",
+		"Count", "Percent", "Address", "Function")
+
+	for i, s := range samples {
+		if profileLimit > 0 && i >= profileLimit {
+			break
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"%10d %6.2f%%  0x%08x  %s\n", s.Count,
+			float64(s.Count)*100/float64(total), s.Addr, s.Name)
+	}
+
+	if unknown > 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"%10d %6.2f%%  %-10s  (unknown)\n", unknown,
+			float64(unknown)*100/float64(total), "")
+	}
+}
+
+func AddProfileCommands(cmd *cobra.Command) {
+	profileCmd := &cobra.Command{
+		Use:   "profile",
+		Short: "On-target profiling commands",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	cmd.AddCommand(profileCmd)
+
+	decodeHelpText := "Map the addresses recorded by an on-target " +
+		"profiler back to the functions of <target-name>'s app, using the " +
+		"ELF file of the target's most recent build.  The target should " +
+		"have been built with `newt build --instrument`.\n\n"
+	decodeHelpText += "Each line of the samples file contains a " +
+		"hexadecimal address, optionally followed by a decimal count " +
+		"(default 1).  Blank lines and lines beginning with '#' are " +
+		"ignored.  Samples are summed per function and listed from most " +
+		"to least frequent."
+
+	decodeHelpEx := "  newt profile decode my_target1 samples.txt\n"
+	decodeHelpEx += "  newt profile decode my_target1 samples.txt " +
+		"--elf release/app.elf --limit 20\n"
+
+	decodeCmd := &cobra.Command{
+		Use:     "decode <target-name> <samples-file>",
+		Short:   "Map profiler samples to functions",
+		Long:    decodeHelpText,
+		Example: decodeHelpEx,
+		Run:     profileDecodeRunCmd,
+	}
+	decodeCmd.Flags().StringVar(&profileElf, "elf", "",
+		"ELF file to use instead of the target's most recent build")
+	decodeCmd.Flags().IntVar(&profileLimit, "limit", 0,
+		"Only list the specified number of functions")
+
+	profileCmd.AddCommand(decodeCmd)
+	AddTabCompleteFn(decodeCmd, targetList)
+}
@@ -20,46 +20,41 @@
 package cli
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"mynewt.apache.org/newt/newt/builder"
 	"mynewt.apache.org/newt/newt/downloader"
+	"mynewt.apache.org/newt/newt/install"
 	"mynewt.apache.org/newt/newt/interfaces"
 	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/project"
 	"mynewt.apache.org/newt/newt/repo"
+	"mynewt.apache.org/newt/newt/target"
 	"mynewt.apache.org/newt/util"
 )
 
 var infoRemote bool
+var upgradeChangelogFile string
+var newTemplate string
+var newAppName string
 
-func newRunCmd(cmd *cobra.Command, args []string) {
-	if len(args) < 1 {
-		NewtUsage(cmd, util.NewNewtError("Must specify "+
-			"a project directory to newt new"))
-	}
-
-	newDir := args[0]
-
-	if util.NodeExist(newDir) {
-		NewtUsage(cmd, util.NewNewtError("Cannot create new project, "+
-			"directory already exists"))
-	}
-
+// Downloads the blinky project skeleton to the specified directory.  It
+// returns the commit that was checked out.
+func downloadBlinkySkeleton(dstDir string) (string, error) {
 	util.StatusMessage(util.VERBOSITY_DEFAULT, "Downloading "+
 		"project skeleton from apache/mynewt-blinky...\n")
 	dl := downloader.NewGithubDownloader()
 	dl.User = "apache"
 	dl.Repo = "mynewt-blinky"
 
-	tmpdir, err := newtutil.MakeTempRepoDir()
-	if err != nil {
-		NewtUsage(nil, err)
-	}
-	defer os.RemoveAll(tmpdir)
-
 	/* For new command don't use shallow copy by default
 	 * as release tag may not be present on tip of master
 	 * branch.
@@ -68,34 +63,182 @@ func newRunCmd(cmd *cobra.Command, args []string) {
 		util.ShallowCloneDepth = 0
 	}
 
-	if err := dl.Clone("master", tmpdir); err != nil {
-		NewtUsage(nil, err)
+	if err := dl.Clone("master", dstDir); err != nil {
+		return "", err
 	}
 
 	commit := newtutil.NewtBlinkyTag
-	err = dl.Checkout(tmpdir, commit)
+	err := dl.Checkout(dstDir, commit)
 
 	/* If checkout with final tag fails, try to find latest rc tag */
 	if err != nil {
-		commit, err = dl.LatestRc(tmpdir, newtutil.NewtBlinkyTag)
+		commit, err = dl.LatestRc(dstDir, newtutil.NewtBlinkyTag)
 		if err != nil {
-			NewtUsage(nil, err)
+			return "", err
+		}
+
+		err = dl.Checkout(dstDir, commit)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return commit, nil
+}
+
+// Copies a project template to the specified directory.  The template is
+// either a local directory or a git URL, optionally followed by
+// "#<branch-or-tag>".  It returns a description of the template's origin.
+func downloadTemplate(src string, dstDir string) (string, error) {
+	if util.NodeExist(src) {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Copying project template from %s...\n", src)
+		if err := util.CopyDir(src, dstDir); err != nil {
+			return "", err
+		}
+		return src, nil
+	}
+
+	url := src
+	branch := ""
+	if i := strings.LastIndex(src, "#"); i >= 0 {
+		url = src[:i]
+		branch = src[i+1:]
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Downloading project template from %s...\n", url)
+	dl := downloader.NewGitDownloader()
+	dl.Url = url
+	dl.Branch = branch
+
+	if err := dl.Clone(dl.MainBranch(), dstDir); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s, commit: %s", url, dl.MainBranch()), nil
+}
+
+// Replaces the template placeholders in the names and contents of all files
+// in the specified directory.  Files that contain NUL bytes are assumed to be
+// binary and their contents are left alone.
+func expandTemplate(dir string, vars map[string]string) error {
+	var oldnew []string
+	for k, v := range vars {
+		oldnew = append(oldnew, "{{"+k+"}}", v)
+	}
+	repl := strings.NewReplacer(oldnew...)
+
+	// Collect the paths first so that renames don't disrupt the walk.
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo,
+		err error) error {
+
+		if err != nil {
+			return err
+		}
+		if path != dir {
+			paths = append(paths, path)
 		}
+		return nil
+	})
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
 
-		err = dl.Checkout(tmpdir, commit)
+	// Process the deepest paths first so that a directory is renamed after
+	// its contents.
+	for i := len(paths) - 1; i >= 0; i-- {
+		path := paths[i]
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			return util.ChildNewtError(err)
+		}
+
+		if info.Mode().IsRegular() {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return util.ChildNewtError(err)
+			}
+			if !bytes.Contains(data, []byte{0}) {
+				expanded := repl.Replace(string(data))
+				if expanded != string(data) {
+					err := ioutil.WriteFile(path, []byte(expanded),
+						info.Mode().Perm())
+					if err != nil {
+						return util.ChildNewtError(err)
+					}
+				}
+			}
+		}
+
+		base := filepath.Base(path)
+		if newBase := repl.Replace(base); newBase != base {
+			newPath := filepath.Join(filepath.Dir(path), newBase)
+			if err := os.Rename(path, newPath); err != nil {
+				return util.ChildNewtError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func newRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify "+
+			"a project directory to newt new"))
+	}
+
+	newDir := args[0]
+
+	if util.NodeExist(newDir) {
+		NewtUsage(cmd, util.NewNewtError("Cannot create new project, "+
+			"directory already exists"))
+	}
+
+	tmpdir, err := newtutil.MakeTempRepoDir()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	var origin string
+	if newTemplate == "" {
+		commit, err := downloadBlinkySkeleton(tmpdir)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+		origin = fmt.Sprintf("commit: %s", commit)
+	} else {
+		origin, err = downloadTemplate(newTemplate, tmpdir)
 		if err != nil {
 			NewtUsage(nil, err)
 		}
 	}
 
-	util.StatusMessage(util.VERBOSITY_DEFAULT, "Installing "+
-		"skeleton in %s (commit: %s)\n", newDir, commit)
+	if err := os.RemoveAll(tmpdir + "/.git"); err != nil {
+		NewtUsage(nil, util.ChildNewtError(err))
+	}
 
-	if err := util.CopyDir(tmpdir, newDir); err != nil {
-		NewtUsage(cmd, err)
+	projName := filepath.Base(filepath.Clean(newDir))
+	appName := newAppName
+	if appName == "" {
+		appName = projName
 	}
+	err = expandTemplate(tmpdir, map[string]string{
+		"project_name": projName,
+		"app_name":     appName,
+	})
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Installing "+
+		"skeleton in %s (%s)\n", newDir, origin)
 
-	if err := os.RemoveAll(newDir + "/" + "/.git/"); err != nil {
+	if err := util.CopyDir(tmpdir, newDir); err != nil {
 		NewtUsage(cmd, err)
 	}
 
@@ -126,10 +269,75 @@ func makeRepoPredicate(repoNames []string) func(r *repo.Repo) bool {
 	}
 }
 
+// Collects the packages that the project's targets use, as a map of package
+// directory to package name.  Targets that cannot be resolved are skipped.
+func targetPkgDirs() map[string]string {
+	dirs := map[string]string{}
+
+	for _, t := range target.GetTargets() {
+		b, err := builder.NewTargetBuilder(t)
+		if err != nil {
+			log.Debugf("changelog: skipping target %s: %s",
+				t.FullName(), err.Error())
+			continue
+		}
+
+		res, err := b.Resolve()
+		if err != nil {
+			log.Debugf("changelog: skipping target %s: %s",
+				t.FullName(), err.Error())
+			continue
+		}
+
+		for _, rpkg := range res.MasterSet.Rpkgs {
+			dirs[rpkg.Lpkg.BasePath()] = rpkg.Lpkg.FullName()
+		}
+	}
+
+	return dirs
+}
+
+// Prints the commits that the upgrade brought into each repo, and writes them
+// to the file specified with --changelog.
+func reportUpgradeChangelogs(proj *project.Project) {
+	rcs := proj.UpgradeChangelogs()
+	if len(rcs) == 0 {
+		return
+	}
+
+	install.FlagUsedPkgs(rcs, targetPkgDirs())
+	text := install.ChangelogText(rcs)
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "\nChanges:\n%s", text)
+
+	if upgradeChangelogFile != "" {
+		err := ioutil.WriteFile(upgradeChangelogFile, []byte(text), 0644)
+		if err != nil {
+			NewtUsage(nil, util.FmtNewtError(
+				"Unable to write changelog file; reason: %s", err.Error()))
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Changelog written to %s\n", upgradeChangelogFile)
+	}
+}
+
 func upgradeRunCmd(cmd *cobra.Command, args []string) {
-	proj := TryGetOrDownloadProject()
+	// If the user specified repos, don't touch the others while the project
+	// is being loaded.
+	var loadPred func(r *repo.Repo) bool
+	if len(args) > 0 {
+		loadPred = makeRepoPredicate(args)
+	}
+
+	proj := TryGetOrDownloadProjectIf(loadPred)
 	interfaces.SetProject(proj)
 
+	for _, arg := range args {
+		if proj.FindRepo(strings.TrimPrefix(arg, "@")) == nil {
+			NewtUsage(cmd, util.FmtNewtError("unknown repo: %s", arg))
+		}
+	}
+
 	proj.GetPkgRepos()
 	proj.SetGitEnvVariables()
 
@@ -139,6 +347,8 @@ func upgradeRunCmd(cmd *cobra.Command, args []string) {
 
 		NewtUsage(nil, err)
 	}
+
+	reportUpgradeChangelogs(proj)
 }
 
 func infoRunCmd(cmd *cobra.Command, args []string) {
@@ -196,12 +406,39 @@ func infoRunCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+func outdatedRunCmd(cmd *cobra.Command, args []string) {
+	proj := TryGetProject()
+	interfaces.SetProject(proj)
+
+	for _, arg := range args {
+		if proj.FindRepo(strings.TrimPrefix(arg, "@")) == nil {
+			NewtUsage(cmd, util.FmtNewtError("unknown repo: %s", arg))
+		}
+	}
+
+	if err := proj.OutdatedIf(makeRepoPredicate(args)); err != nil {
+		NewtUsage(nil, err)
+	}
+}
+
 func AddProjectCommands(cmd *cobra.Command) {
-	upgradeHelpText := ""
+	upgradeHelpText := "Upgrade the repos specified in project.yml to the " +
+		"versions that project.yml requires.  If repo names are specified, " +
+		"only those repos and the repos they depend on are upgraded.  Repos " +
+		"named with --exclude are left at their installed versions.\n\n" +
+		"After upgrading, newt lists the commits (or, for merged pull " +
+		"requests, the pull request titles) that each repo moved past.  " +
+		"Commits that change packages used by the project's targets are " +
+		"marked with a \"*\" and followed by the names of those packages."
 	upgradeHelpEx := "  newt upgrade\n"
 	upgradeHelpEx += "    Upgrades all repositories specified in project.yml.\n\n"
 	upgradeHelpEx += "  newt upgrade apache-mynewt-core\n"
-	upgradeHelpEx += "    Upgrades the apache-mynewt-core repository."
+	upgradeHelpEx += "    Upgrades the apache-mynewt-core repository.\n\n"
+	upgradeHelpEx += "  newt upgrade --exclude apache-mynewt-nimble\n"
+	upgradeHelpEx += "    Upgrades all repositories except apache-mynewt-nimble.\n\n"
+	upgradeHelpEx += "  newt upgrade --changelog upgrade.txt\n"
+	upgradeHelpEx += "    Upgrades all repositories and writes the list of new " +
+		"commits to upgrade.txt."
 	upgradeCmd := &cobra.Command{
 		Use:     "upgrade [repo-1] [repo-2] [...]",
 		Short:   "Upgrade project dependencies",
@@ -216,11 +453,25 @@ func AddProjectCommands(cmd *cobra.Command) {
 		"ask", "a", false, "Prompt user before upgrading any repos")
 	upgradeCmd.PersistentFlags().StringSliceVarP(&newtutil.NewtIgnore, "ignore", "i", []string{},
 		"Names of repositories to skip, separated by a comma or by using multiple flags")
+	upgradeCmd.PersistentFlags().StringSliceVarP(&newtutil.NewtExclude,
+		"exclude", "e", []string{},
+		"Names of repositories to leave at their installed versions, "+
+			"separated by a comma or by using multiple flags")
+	upgradeCmd.PersistentFlags().StringVar(&upgradeChangelogFile,
+		"changelog", "", "Also write the list of new commits to this file")
 
 	cmd.AddCommand(upgradeCmd)
 
-	newHelpText := ""
-	newHelpEx := ""
+	newHelpText := "Create a new project in the specified directory.  By " +
+		"default, the project is a copy of the apache/mynewt-blinky " +
+		"skeleton.  With --template, the project is instead copied from " +
+		"a local directory or a git repo (\"<url>[#<branch-or-tag>]\").  " +
+		"In the names and contents of the template's files, " +
+		"{{project_name}} is replaced with the name of the project " +
+		"directory and {{app_name}} with the value of --app."
+	newHelpEx := "  newt new my_proj\n"
+	newHelpEx += "  newt new my_proj -t https://git.example.com/tmpl.git#v2 " +
+		"--app sensor_hub"
 	newCmd := &cobra.Command{
 		Use:     "new <project-dir>",
 		Short:   "Create a new project",
@@ -228,6 +479,10 @@ func AddProjectCommands(cmd *cobra.Command) {
 		Example: newHelpEx,
 		Run:     newRunCmd,
 	}
+	newCmd.Flags().StringVarP(&newTemplate, "template", "t", "",
+		"Project template; a local directory or a git URL")
+	newCmd.Flags().StringVarP(&newAppName, "app", "", "",
+		"Value of the {{app_name}} placeholder (default: project name)")
 
 	cmd.AddCommand(newCmd)
 
@@ -246,4 +501,24 @@ func AddProjectCommands(cmd *cobra.Command) {
 		"Fetch latest repos to determine if upgrades are required")
 
 	cmd.AddCommand(infoCmd)
+
+	outdatedHelpText := "Fetch the latest repository.yml file of each repo " +
+		"and report the repos that have releases newer than the versions " +
+		"specified in project.yml.  For each such repo, the newest release " +
+		"with the same major version number and the newest release overall " +
+		"are displayed.  Nothing is installed or upgraded."
+	outdatedHelpEx := "  newt outdated\n"
+	outdatedHelpEx += "    Checks all repositories specified in project.yml.\n\n"
+	outdatedHelpEx += "  newt outdated apache-mynewt-core\n"
+	outdatedHelpEx += "    Checks the apache-mynewt-core repository."
+
+	outdatedCmd := &cobra.Command{
+		Use:     "outdated [repo-1] [repo-2] [...]",
+		Short:   "Show repos with newer versions available",
+		Long:    outdatedHelpText,
+		Example: outdatedHelpEx,
+		Run:     outdatedRunCmd,
+	}
+
+	cmd.AddCommand(outdatedCmd)
 }
@@ -0,0 +1,109 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/publish"
+	"mynewt.apache.org/newt/util"
+)
+
+var publishDest string
+var publishDryRun bool
+
+func publishRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify at least one target"))
+	}
+
+	proj := TryGetProject()
+
+	destName := publishDest
+	if destName == "" {
+		names := proj.PublishDestNames()
+		if len(names) != 1 {
+			NewtUsage(cmd, util.NewNewtError(
+				"Must specify a destination with --dest"))
+		}
+		destName = names[0]
+	}
+
+	dest, err := proj.PublishDest(destName)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	targets, err := ResolveTargets(args...)
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	for _, t := range targets {
+		rec, err := publish.Publish(t, dest, publishDryRun)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+
+		for _, a := range rec.Artifacts {
+			util.StatusMessage(util.VERBOSITY_VERBOSE, "    %s  %s\n",
+				a.Sha256, a.Name)
+		}
+
+		if publishDryRun {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"Would publish %d file(s) from %s to %s\n",
+				len(rec.Artifacts), t.FullName(), rec.Url)
+		} else {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"Published %d file(s) from %s to %s\n",
+				len(rec.Artifacts), t.FullName(), rec.Url)
+		}
+	}
+}
+
+func AddPublishCommands(cmd *cobra.Command) {
+	publishHelpText := FormatHelp(`Upload the artifacts of each target's
+		most recent build (image, hex, ELF, binary, map, and manifest files)
+		to a destination defined in project.yml (project.publish).  The files
+		are uploaded to <url>/<target>/<version>, along with a publish.json
+		file that lists each file and its sha256 digest.  The destination
+		URL's scheme selects the transport: s3 (aws CLI), gs (gsutil), http
+		or https (PUT), or file.  --dest may be omitted if project.yml
+		defines a single destination.`)
+
+	publishHelpEx := "  newt publish my_target --dest release\n"
+	publishHelpEx += "  newt publish @release --dest release --dry-run"
+
+	publishCmd := &cobra.Command{
+		Use:     "publish <target-name> [target-name...]",
+		Short:   "Upload build artifacts to a configured destination",
+		Long:    publishHelpText,
+		Example: publishHelpEx,
+		Run:     publishRunCmd,
+	}
+	publishCmd.Flags().StringVar(&publishDest, "dest", "",
+		"Name of the destination in project.yml")
+	publishCmd.Flags().BoolVar(&publishDryRun, "dry-run", false,
+		"List the files that would be published without uploading them")
+
+	cmd.AddCommand(publishCmd)
+	AddTabCompleteFn(publishCmd, targetList)
+}
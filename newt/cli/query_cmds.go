@@ -0,0 +1,196 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/query"
+	"mynewt.apache.org/newt/util"
+)
+
+var queryTargets []string
+var queryRaw bool
+
+func queryJSON(v interface{}) string {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "    ")
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		NewtUsage(nil, util.ChildNewtError(err))
+	}
+
+	return buf.String()
+}
+
+// Prints a single query result.  In raw mode, strings are printed without
+// quotes.
+func printQueryResult(v interface{}) {
+	if s, ok := v.(string); ok && queryRaw {
+		util.StatusMessage(util.VERBOSITY_QUIET, "%s\n", s)
+	} else {
+		util.StatusMessage(util.VERBOSITY_QUIET, "%s", queryJSON(v))
+	}
+}
+
+func queryRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify a query expression"))
+	}
+
+	path, err := query.ParsePath(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	proj := TryGetProject()
+
+	var tbs []*builder.TargetBuilder
+	for _, name := range queryTargets {
+		b, err := TargetBuilderForTargetOrUnittest(name)
+		if err != nil {
+			NewtUsage(cmd, err)
+		}
+		tbs = append(tbs, b)
+	}
+
+	doc, err := query.NewDoc(proj, tbs)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	itf, err := doc.Generic()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	results := path.Eval(itf)
+
+	if !path.IsMulti() {
+		if len(results) == 0 {
+			NewtUsage(nil, util.FmtNewtError(
+				"query \"%s\" did not match anything", path.Text))
+		}
+		printQueryResult(results[0])
+		return
+	}
+
+	if queryRaw {
+		for _, r := range results {
+			printQueryResult(r)
+		}
+	} else {
+		if results == nil {
+			results = []interface{}{}
+		}
+		printQueryResult(results)
+	}
+}
+
+func queryDepsRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		NewtUsage(cmd, util.NewNewtError(
+			"Must specify a target name and a header"))
+	}
+
+	TryGetProject()
+
+	b, err := TargetBuilderForTargetOrUnittest(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	hus, err := b.HeaderUsers(args[1])
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if len(hus) == 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"No source file in target %s includes %s\n",
+			b.GetTarget().FullName(), args[1])
+		return
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s",
+		builder.HeaderUsersText(hus))
+}
+
+func AddQueryCommands(cmd *cobra.Command) {
+	queryHelpText := "Evaluate a path expression against a JSON document " +
+		"describing the project.  The document contains the project's " +
+		"repos, packages, and targets.  The full resolution of each " +
+		"target specified with --target (syscfg, dependency graph, " +
+		"sysinit, APIs, etc.) is available under " +
+		"resolution[\"<target>\"].\n\n" +
+		"Expressions are a subset of JSONPath: `.name`, `[\"name\"]`, " +
+		"`[N]`, `[*]`, and `[?name==value]`.  Use `$` to view the whole " +
+		"document."
+
+	queryHelpEx := "  newt query 'targets[*].bsp'\n" +
+		"  newt query -r 'packages[?type==bsp].name'\n" +
+		"  newt query -t my_blinky_sim " +
+		"'resolution[\"targets/my_blinky_sim\"].syscfg.settings.OS_MAIN_STACK_SIZE'"
+
+	queryCmd := &cobra.Command{
+		Use:     "query <expression>",
+		Short:   "Query project information",
+		Long:    queryHelpText,
+		Example: queryHelpEx,
+		Run:     queryRunCmd,
+	}
+
+	queryCmd.Flags().StringSliceVarP(&queryTargets, "target", "t", nil,
+		"Resolve the specified target and include it in the document")
+	queryCmd.Flags().BoolVarP(&queryRaw, "raw", "r", false,
+		"Print string results without quotes, one per line")
+
+	cmd.AddCommand(queryCmd)
+
+	queryDepsHelpText := "List the source files in the target specified " +
+		"by <target-name> that include <header>, directly or " +
+		"indirectly, grouped by package.  The header is specified by its " +
+		"path or by a path suffix, such as the name used in an #include " +
+		"directive (e.g., os/os.h).  The lookup uses the dependency " +
+		"files of the most recent build, so the target must have been " +
+		"built."
+	queryDepsHelpEx := "  newt query-deps my_target1 os/os.h\n" +
+		"  newt query-deps my_target1 " +
+		"repos/apache-mynewt-core/kernel/os/include/os/os_mutex.h"
+
+	queryDepsCmd := &cobra.Command{
+		Use:     "query-deps <target-name> <header>",
+		Short:   "List the source files that include a header",
+		Long:    queryDepsHelpText,
+		Example: queryDepsHelpEx,
+		Run:     queryDepsRunCmd,
+	}
+
+	cmd.AddCommand(queryDepsCmd)
+	AddTabCompleteFn(queryDepsCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
+}
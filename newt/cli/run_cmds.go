@@ -40,9 +40,6 @@ func runRunCmd(cmd *cobra.Command, args []string) {
 	if useV1 && useV2 {
 		NewtUsage(cmd, util.NewNewtError("Either -1, or -2, but not both"))
 	}
-	if !useV1 {
-		useV2 = true
-	}
 
 	TryGetProject()
 
@@ -51,6 +48,8 @@ func runRunCmd(cmd *cobra.Command, args []string) {
 		NewtUsage(cmd, err)
 	}
 
+	applyDevice(b)
+
 	testPkg := b.GetTestPkg()
 	if testPkg != nil {
 		b.InjectSetting("TESTUTIL_SYSTEM_ASSERT", "1")
@@ -95,22 +94,32 @@ func runRunCmd(cmd *cobra.Command, args []string) {
 				NewtUsage(cmd, err)
 			}
 
+			fmtName := imageFormatName(b.GetTarget())
+			imgFmt, err := imgprod.LookupImageFormat(fmtName)
+			if err != nil {
+				NewtUsage(nil, err)
+			}
+
 			var keys []sec.PrivSignKey
 
 			if len(args) > 2 {
-				keys, _, err = parseKeyArgs(args[2:])
+				keys, _, err = parseKeyArgs(args[2:],
+					fmtName == imgprod.IMAGE_FORMAT_V1)
 				if err != nil {
 					NewtUsage(cmd, err)
 				}
 			}
 
-			if useV1 {
-				err = imgprod.ProduceAllV1(b, ver, keys, encKeyFilename, encKeyIndex,
-					hdrPad, imagePad, sections, useLegacyTLV)
-			} else {
-				err = imgprod.ProduceAll(b, ver, keys, encKeyFilename, encKeyIndex,
-					hdrPad, imagePad, sections, useLegacyTLV)
-			}
+			err = imgFmt.Produce(b, imgprod.ImageFormatOpts{
+				Version:        ver,
+				SigKeys:        keys,
+				EncKeyFilename: encKeyFilename,
+				EncKeyIndex:    encKeyIndex,
+				HdrPad:         hdrPad,
+				ImagePad:       imagePad,
+				Sections:       sections,
+				UseLegacyTLV:   useLegacyTLV,
+			})
 			if err != nil {
 				NewtUsage(nil, err)
 			}
@@ -149,6 +158,8 @@ func AddRunCommands(cmd *cobra.Command) {
 		"Extra commands to send to JTAG software")
 	runCmd.PersistentFlags().BoolVarP(&noGDB_flag, "noGDB", "n", false,
 		"Do not start GDB from command line")
+	runCmd.PersistentFlags().StringVar(&deviceName, "device", "",
+		"Name of the device in devices.yml to load to and debug")
 	runCmd.PersistentFlags().BoolVarP(&newtutil.NewtForce,
 		"force", "f", false,
 		"Ignore flash overflow errors during image creation")
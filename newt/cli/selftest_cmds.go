@@ -0,0 +1,105 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/selftest"
+	"mynewt.apache.org/newt/util"
+)
+
+var selftestUpdate bool
+
+func selftestResolveRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify fixture directory"))
+	}
+
+	results, err := selftest.Resolve(args[0], selftestUpdate)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	numFail := 0
+	for _, r := range results {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%-7s %s/%s\n",
+			r.Status.String(), r.Fixture, r.Target)
+
+		if r.Diff != "" {
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "    %s\n",
+				strings.Replace(r.Diff, "\n", "\n    ", -1))
+		}
+
+		if r.Status == selftest.RESOLVE_STATUS_FAIL ||
+			r.Status == selftest.RESOLVE_STATUS_MISSING {
+
+			numFail++
+		}
+	}
+
+	if numFail > 0 {
+		util.ErrorMessage(util.VERBOSITY_QUIET,
+			"%d of %d resolutions do not match their golden files; rerun "+
+				"with --update to accept the changes\n",
+			numFail, len(results))
+		os.Exit(1)
+	}
+}
+
+func AddSelftestCommands(cmd *cobra.Command) {
+	selftestCmd := &cobra.Command{
+		Use:   "selftest",
+		Short: "Check newt against test fixtures",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	cmd.AddCommand(selftestCmd)
+
+	resolveHelpText := "Resolve every target in the fixture projects in " +
+		"<fixture-dir> and compare each resolution (packages, syscfg, " +
+		"sysinit order, etc.) against the target's golden file.  " +
+		"<fixture-dir> is either a project or a directory of projects.  " +
+		"The golden file of target `targets/<name>` is " +
+		"`golden/<name>.json` in the fixture project; it contains the " +
+		"output of `newt target dump`, or the error if the target fails to " +
+		"resolve.\n\n"
+	resolveHelpText += "With --update, the golden files are rewritten " +
+		"from the current resolutions."
+
+	resolveHelpEx := "  newt selftest resolve newt/selftest/testdata/resolve\n"
+	resolveHelpEx += "  newt selftest resolve --update my_fixtures\n"
+
+	resolveCmd := &cobra.Command{
+		Use:     "resolve <fixture-dir>",
+		Short:   "Compare target resolutions against golden files",
+		Long:    resolveHelpText,
+		Example: resolveHelpEx,
+		Run:     selftestResolveRunCmd,
+	}
+	resolveCmd.Flags().BoolVar(&selftestUpdate, "update", false,
+		"Rewrite the golden files instead of comparing against them")
+
+	selftestCmd.AddCommand(resolveCmd)
+}
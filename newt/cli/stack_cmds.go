@@ -0,0 +1,123 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/util"
+)
+
+func stackRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target"))
+	}
+
+	TryGetProject()
+
+	t := ResolveTarget(args[0])
+	if t == nil {
+		NewtUsage(cmd, util.NewNewtError("Invalid target name: "+args[0]))
+	}
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	b.EnableStackUsage()
+	if err := b.Build(); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	report, err := b.StackReport()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "\n%-32s %8s %8s  %s\n",
+		"Entry point", "Depth", "Limit", "Status")
+
+	overflows := 0
+	for _, sd := range report {
+		limit := "-"
+		if sd.Limit > 0 {
+			limit = fmt.Sprintf("%d", sd.Limit)
+		}
+
+		status := "ok"
+		if sd.Overflow() {
+			status = "OVERFLOW"
+			overflows++
+		} else if sd.Limit == 0 {
+			status = "unknown"
+		}
+
+		depth := fmt.Sprintf("%d", sd.Depth)
+		if sd.Incomplete {
+			depth = ">=" + depth
+		}
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%-32s %8s %8s  %s\n",
+			sd.Func, depth, limit, status)
+
+		util.StatusMessage(util.VERBOSITY_VERBOSE, "    path: %s\n",
+			strings.Join(sd.Path, " -> "))
+		for _, note := range sd.Notes {
+			util.StatusMessage(util.VERBOSITY_VERBOSE, "    note: %s\n",
+				note)
+		}
+	}
+
+	if overflows > 0 {
+		NewtUsage(nil, util.FmtNewtError(
+			"%d task(s) may overflow their stacks", overflows))
+	}
+}
+
+func AddStackCommands(cmd *cobra.Command) {
+	stackHelpText := "Build the specified target with -fstack-usage and " +
+		"report the worst-case stack depth of each task entry point.  " +
+		"Depths are computed by combining the per-function stack usage " +
+		"reported by the compiler with the call graph extracted from the " +
+		"linked image, and are compared against the task stack sizes " +
+		"configured in syscfg.\n\n" +
+		"Packages declare task entry points under `pkg.stack_entries` in " +
+		"their pkg.yml files, mapping each entry function to the syscfg " +
+		"setting containing its stack size.  If no entry points are " +
+		"declared, all call graph roots are reported.\n\n" +
+		"Calls through function pointers are not visible in the call " +
+		"graph.  Run with -v to see each entry point's deepest call chain " +
+		"and the reasons a depth may be underestimated."
+
+	stackCmd := &cobra.Command{
+		Use:   "stack <target-name>",
+		Short: "Report worst-case stack usage of a target's tasks",
+		Long:  stackHelpText,
+		Run:   stackRunCmd,
+	}
+
+	cmd.AddCommand(stackCmd)
+	AddTabCompleteFn(stackCmd, targetList)
+}
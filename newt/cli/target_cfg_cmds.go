@@ -34,6 +34,7 @@ import (
 
 	"mynewt.apache.org/newt/newt/builder"
 	"mynewt.apache.org/newt/newt/dump"
+	"mynewt.apache.org/newt/newt/irqcfg"
 	"mynewt.apache.org/newt/newt/logcfg"
 	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/pkg"
@@ -42,10 +43,58 @@ import (
 	"mynewt.apache.org/newt/newt/syscfg"
 	"mynewt.apache.org/newt/newt/sysdown"
 	"mynewt.apache.org/newt/newt/sysinit"
+	"mynewt.apache.org/newt/newt/target"
 	"mynewt.apache.org/newt/newt/val"
+	"mynewt.apache.org/newt/newt/ycfg"
 	"mynewt.apache.org/newt/util"
 )
 
+// If non-empty, only settings in this group (or one of its subgroups) are
+// displayed by `config show` and `config brief`.
+var configGroup string
+
+// Returns the attributes of a setting that are worth calling out in config
+// output.
+func settingFlags(entry syscfg.CfgEntry) []string {
+	var flags []string
+
+	if entry.State != syscfg.CFG_SETTING_STATE_GOOD {
+		flags = append(flags, entry.State.String())
+	}
+	if entry.Advanced {
+		flags = append(flags, "advanced")
+	}
+
+	return flags
+}
+
+// Indicates whether a setting belongs to the group specified with `--group`.
+func settingInGroup(entry syscfg.CfgEntry) bool {
+	if configGroup == "" {
+		return true
+	}
+
+	return entry.Group == configGroup ||
+		strings.HasPrefix(entry.Group, configGroup+"/")
+}
+
+// Groups a target's settings by defining package, omitting settings that
+// are not in the group specified with `--group`.
+func groupEntriesByPkg(cfg syscfg.Cfg) map[string][]syscfg.CfgEntry {
+	pkgNameEntryMap := map[string][]syscfg.CfgEntry{}
+
+	for pkgName, entries := range syscfg.EntriesByPkg(cfg) {
+		for _, entry := range entries {
+			if settingInGroup(entry) {
+				pkgNameEntryMap[pkgName] =
+					append(pkgNameEntryMap[pkgName], entry)
+			}
+		}
+	}
+
+	return pkgNameEntryMap
+}
+
 func printSetting(entry syscfg.CfgEntry) {
 	util.StatusMessage(util.VERBOSITY_DEFAULT,
 		"  * Setting: %s\n", entry.Name)
@@ -58,6 +107,19 @@ func printSetting(entry syscfg.CfgEntry) {
 
 	util.StatusMessage(util.VERBOSITY_DEFAULT, "\n")
 
+	if entry.Group != "" {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"    * Group: %s\n", entry.Group)
+	}
+	if entry.Choice != "" {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"    * Choice: %s\n", entry.Choice)
+	}
+	if flags := settingFlags(entry); len(flags) > 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"    * Flags: %s\n", strings.Join(flags, ", "))
+	}
+
 	if len(entry.History) > 1 {
 		util.StatusMessage(util.VERBOSITY_DEFAULT,
 			"    * Overridden: ")
@@ -89,6 +151,14 @@ func printBriefSetting(entry syscfg.CfgEntry) {
 
 	var extras []string
 
+	if entry.Group != "" {
+		extras = append(extras, "group "+entry.Group)
+	}
+	if entry.Choice != "" {
+		extras = append(extras, "choice "+entry.Choice)
+	}
+	extras = append(extras, settingFlags(entry)...)
+
 	if len(entry.History) > 1 {
 		var fullName string
 
@@ -135,7 +205,7 @@ func printCfg(targetName string, cfg syscfg.Cfg) {
 	}
 
 	util.StatusMessage(util.VERBOSITY_DEFAULT, "Syscfg for %s:\n", targetName)
-	pkgNameEntryMap := syscfg.EntriesByPkg(cfg)
+	pkgNameEntryMap := groupEntriesByPkg(cfg)
 
 	pkgNames := make([]string, 0, len(pkgNameEntryMap))
 	for pkgName, _ := range pkgNameEntryMap {
@@ -172,7 +242,7 @@ func printBriefCfg(targetName string, cfg syscfg.Cfg) {
 
 	util.StatusMessage(util.VERBOSITY_DEFAULT,
 		"Brief syscfg for %s:\n", targetName)
-	pkgNameEntryMap := syscfg.EntriesByPkg(cfg)
+	pkgNameEntryMap := groupEntriesByPkg(cfg)
 
 	pkgNames := make([]string, 0, len(pkgNameEntryMap))
 	for pkgName, _ := range pkgNameEntryMap {
@@ -741,6 +811,90 @@ func targetSysdownBriefCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+func printIrqCfgBriefOne(vector string, handler string, prio val.ValSetting,
+	colWidth int) {
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%*s | %-24s | %s\n",
+		colWidth, vector, handler, valSettingString(prio))
+}
+
+func printIrqCfg(targetName string, icfg irqcfg.ICfg) {
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Interrupt config for %s",
+		targetName)
+	if icfg.PrioBits > 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			" (%d priority bits; max=%d)", icfg.PrioBits, icfg.MaxPrio())
+	}
+	util.StatusMessage(util.VERBOSITY_DEFAULT, ":\n")
+
+	// Keep track of the longest vector name.  This allows the output to be
+	// properly aligned.
+	longest := 6
+	for vector, _ := range icfg.Irqs {
+		if len(vector) > longest {
+			longest = len(vector)
+		}
+	}
+
+	colWidth := longest + 4
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"%*s | %-24s | PRIORITY\n", colWidth, "VECTOR", "HANDLER")
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"%s-+--------------------------+--------------\n",
+		strings.Repeat("-", colWidth))
+	for _, irq := range icfg.SortedIrqs() {
+		printIrqCfgBriefOne(irq.Vector, irq.Handler, irq.Priority, colWidth)
+	}
+
+	unusedNames := make([]string, 0, len(icfg.Unused))
+	for name, _ := range icfg.Unused {
+		unusedNames = append(unusedNames, name)
+	}
+	sort.Strings(unusedNames)
+
+	for _, name := range unusedNames {
+		printIrqCfgBriefOne("-", "-", icfg.Unused[name], colWidth)
+	}
+
+	if errText := icfg.ErrorText(); errText != "" {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "\n!!! %s", errText)
+	}
+}
+
+func targetIrqCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd,
+			util.NewNewtError("Must specify target or unittest name"))
+	}
+
+	TryGetProject()
+
+	failed := false
+	for i, arg := range args {
+		b, err := TargetBuilderForTargetOrUnittest(arg)
+		if err != nil {
+			NewtUsage(cmd, err)
+		}
+
+		res := targetBuilderConfigResolve(b)
+		lpkgs := resolve.RpkgSliceToLpkgSlice(res.MasterSet.Rpkgs)
+		icfg := irqcfg.Read(lpkgs, b.BspPkg(), &res.Cfg)
+		printIrqCfg(b.GetTarget().Name(), icfg)
+
+		if icfg.ErrorText() != "" {
+			failed = true
+		}
+
+		if i < len(args)-1 {
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "\n")
+		}
+	}
+
+	if failed {
+		NewtUsage(nil, util.NewNewtError("interrupt audit failed"))
+	}
+}
+
 func targetConfigInitCmd(cmd *cobra.Command, args []string) {
 	if len(args) < 1 {
 		NewtUsage(cmd,
@@ -809,6 +963,165 @@ func targetConfigInitCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+// Reports an unknown setting name, suggesting a similarly named setting if
+// there is one.
+func unknownSettingError(cfg *syscfg.Cfg, name string) error {
+	msg := fmt.Sprintf("unknown setting \"%s\"", name)
+	if sugg := cfg.SuggestSetting(name); sugg != "" {
+		msg += fmt.Sprintf("; did you mean \"%s\"?", sugg)
+	}
+
+	return util.NewNewtError(msg)
+}
+
+// Applies a change to a target's syscfg.vals and saves the target, unless the
+// change introduces a restriction violation.  The modify function is passed
+// the target's current values and the resolved configuration.
+func targetConfigModify(t *target.Target,
+	modify func(vals map[string]string, cfg *syscfg.Cfg) error) error {
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		return err
+	}
+	res, err := b.Resolve()
+	if err != nil {
+		return err
+	}
+	before := res.Cfg.ViolationTexts()
+
+	vals, err := t.Package().SyscfgY.GetValStringMapString("syscfg.vals", nil)
+	if err != nil {
+		return err
+	}
+	if vals == nil {
+		vals = map[string]string{}
+	}
+
+	if err := modify(vals, &res.Cfg); err != nil {
+		return err
+	}
+
+	t.Package().SyscfgY.Replace("syscfg.vals",
+		util.StringMapStringToItfMapItf(vals))
+
+	// Resolve the modified configuration to detect any new violations.
+	b, err = builder.NewTargetBuilder(t)
+	if err != nil {
+		return err
+	}
+	res, err = b.Resolve()
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]struct{}{}
+	for _, line := range before {
+		existing[line] = struct{}{}
+	}
+
+	var introduced []string
+	for _, line := range res.Cfg.ViolationTexts() {
+		if _, ok := existing[line]; !ok {
+			introduced = append(introduced, line)
+		}
+	}
+	if len(introduced) > 0 {
+		return util.FmtNewtError("change rejected; it violates syscfg "+
+			"restrictions:\n    %s", strings.Join(introduced, "\n    "))
+	}
+
+	return t.Save()
+}
+
+func targetConfigSetCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		NewtUsage(cmd, util.NewNewtError(
+			"Must specify a target and at least one KEY=VAL pair"))
+	}
+
+	TryGetProject()
+
+	t, err := resolveExistingTargetArg(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	// A setting without a value is set to 1, as in `newt target amend`.
+	newVals := map[string]string{}
+	names := []string{}
+	for _, arg := range args[1:] {
+		kv := strings.SplitN(arg, "=", 2)
+		if kv[0] == "" {
+			NewtUsage(cmd, util.FmtNewtError("invalid setting \"%s\"", arg))
+		}
+		if len(kv) == 1 {
+			kv = append(kv, "1")
+		}
+		newVals[kv[0]] = kv[1]
+		names = append(names, kv[0])
+	}
+
+	err = targetConfigModify(t,
+		func(vals map[string]string, cfg *syscfg.Cfg) error {
+			for _, name := range names {
+				if _, ok := cfg.Settings[name]; !ok {
+					return unknownSettingError(cfg, name)
+				}
+				vals[name] = newVals[name]
+			}
+			return nil
+		})
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	for _, name := range names {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Target %s successfully set syscfg %s=%s\n",
+			t.FullName(), name, newVals[name])
+	}
+}
+
+func targetConfigUnsetCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		NewtUsage(cmd, util.NewNewtError(
+			"Must specify a target and at least one setting name"))
+	}
+
+	TryGetProject()
+
+	t, err := resolveExistingTargetArg(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	names := args[1:]
+	err = targetConfigModify(t,
+		func(vals map[string]string, cfg *syscfg.Cfg) error {
+			for _, name := range names {
+				if _, ok := vals[name]; ok {
+					delete(vals, name)
+				} else if _, ok := cfg.Settings[name]; ok {
+					return util.FmtNewtError(
+						"target %s does not override setting %s",
+						t.FullName(), name)
+				} else {
+					return unknownSettingError(cfg, name)
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	for _, name := range names {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Target %s successfully unset syscfg %s\n", t.FullName(), name)
+	}
+}
+
 func targetDumpCmd(cmd *cobra.Command, args []string) {
 	if len(args) < 1 {
 		NewtUsage(cmd,
@@ -835,6 +1148,84 @@ func targetDumpCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+func condEvalString(ce ycfg.CondEval) string {
+	s := fmt.Sprintf("%s.%s: ", ce.Key, ce.Expr)
+	if ce.Err != nil {
+		return s + "error: " + ce.Err.Error()
+	}
+
+	s += fmt.Sprintf("%t", ce.Value)
+	if len(ce.Unknown) > 0 {
+		s += fmt.Sprintf(" (unknown settings: %s)",
+			strings.Join(ce.Unknown, ", "))
+	}
+
+	return s
+}
+
+// Prints how each conditional key in a package's pkg.yml and syscfg.yml
+// files evaluates for a target's configuration.
+func printPkgExprs(lpkg *pkg.LocalPackage, cfg syscfg.Cfg) {
+	settings := cfg.SettingValues()
+
+	var evals []ycfg.CondEval
+	evals = append(evals, lpkg.PkgY.EvalConds(settings)...)
+	evals = append(evals, lpkg.SyscfgY.EvalConds(settings)...)
+
+	if len(evals) == 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"%s: no conditional keys\n", lpkg.FullName())
+		return
+	}
+
+	// Group the results by the file that specifies each conditional.
+	byFile := map[string][]ycfg.CondEval{}
+	var files []string
+	for _, ce := range evals {
+		file := lpkg.FullName()
+		if ce.FileInfo != nil {
+			file = newtutil.ProjRelPath(ce.FileInfo.Path)
+		}
+		if byFile[file] == nil {
+			files = append(files, file)
+		}
+		byFile[file] = append(byFile[file], ce)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s:\n", file)
+		for _, ce := range byFile[file] {
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "    %s\n",
+				condEvalString(ce))
+		}
+	}
+}
+
+func targetExprsCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		NewtUsage(cmd,
+			util.NewNewtError("Must specify target and package names"))
+	}
+
+	TryGetProject()
+
+	b, err := TargetBuilderForTargetOrUnittest(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	lpkgs, err := ResolvePackages(args[1:])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	res := targetBuilderConfigResolve(b)
+	for _, lpkg := range lpkgs {
+		printPkgExprs(lpkg, res.Cfg)
+	}
+}
+
 func targetCfgCmdAll() []*cobra.Command {
 	cmds := []*cobra.Command{}
 
@@ -860,6 +1251,8 @@ func targetCfgCmdAll() []*cobra.Command {
 
 	configShowCmd.Flags().StringVarP(&util.InjectSyscfg, "syscfg", "S", "",
 		"Injected syscfg settings, key=value pairs separated by colon")
+	configShowCmd.Flags().StringVarP(&configGroup, "group", "g", "",
+		"Only show settings in the specified group")
 
 	configCmd.AddCommand(configShowCmd)
 	AddTabCompleteFn(configShowCmd, func() []string {
@@ -875,6 +1268,8 @@ func targetCfgCmdAll() []*cobra.Command {
 
 	configBriefCmd.Flags().StringVarP(&util.InjectSyscfg, "syscfg", "S", "",
 		"Injected syscfg settings, key=value pairs separated by colon")
+	configBriefCmd.Flags().StringVarP(&configGroup, "group", "g", "",
+		"Only show settings in the specified group")
 
 	configCmd.AddCommand(configBriefCmd)
 	AddTabCompleteFn(configBriefCmd, func() []string {
@@ -912,6 +1307,42 @@ func targetCfgCmdAll() []*cobra.Command {
 		return append(targetList(), unittestList()...)
 	})
 
+	configSetHelpText := "Override system configuration settings in a " +
+		"target's syscfg.yml file.  Each setting must be defined by a " +
+		"package that the target includes, and the new values must not " +
+		"violate any syscfg restrictions; otherwise the target is left " +
+		"unchanged.  A setting specified without a value is set to 1."
+	configSetHelpEx := "  newt target config set my_target1 " +
+		"LOG_LEVEL=1 SHELL_TASK"
+
+	configSetCmd := &cobra.Command{
+		Use:     "set <target> KEY[=VAL] [KEY[=VAL]...]",
+		Short:   "Validate and set a target's system configuration settings",
+		Long:    configSetHelpText,
+		Example: configSetHelpEx,
+		Run:     targetConfigSetCmd,
+	}
+
+	configCmd.AddCommand(configSetCmd)
+	AddTabCompleteFn(configSetCmd, targetList)
+
+	configUnsetHelpText := "Remove system configuration overrides from a " +
+		"target's syscfg.yml file, restoring the values that the target's " +
+		"other packages specify.  The target is left unchanged if removing " +
+		"an override would violate a syscfg restriction."
+	configUnsetHelpEx := "  newt target config unset my_target1 LOG_LEVEL"
+
+	configUnsetCmd := &cobra.Command{
+		Use:     "unset <target> KEY [KEY...]",
+		Short:   "Remove a target's system configuration overrides",
+		Long:    configUnsetHelpText,
+		Example: configUnsetHelpEx,
+		Run:     targetConfigUnsetCmd,
+	}
+
+	configCmd.AddCommand(configUnsetCmd)
+	AddTabCompleteFn(configUnsetCmd, targetList)
+
 	logHelpText := "View a target's log configuration"
 
 	logCmd := &cobra.Command{
@@ -1035,6 +1466,24 @@ func targetCfgCmdAll() []*cobra.Command {
 		return append(targetList(), unittestList()...)
 	})
 
+	irqHelpText := "Audit a target's interrupt vectors and priorities.  " +
+		"Each vector defined in a package's syscfg.interrupts map is " +
+		"listed with its handler and priority.  Priorities that exceed " +
+		"the range of the BSP's interrupt controller (bsp.nvic_prio_bits) " +
+		"and vectors sharing a priority are flagged."
+
+	irqCmd := &cobra.Command{
+		Use:   "irq <target> [target...]",
+		Short: "Audit a target's interrupt priorities",
+		Long:  irqHelpText,
+		Run:   targetIrqCmd,
+	}
+
+	cmds = append(cmds, irqCmd)
+	AddTabCompleteFn(irqCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
+
 	dumpCmd := &cobra.Command{
 		Use:   "dump <target> [target...]",
 		Short: "Dump a target's intermediate form in JSON",
@@ -1046,5 +1495,22 @@ func targetCfgCmdAll() []*cobra.Command {
 		return append(targetList(), unittestList()...)
 	})
 
+	exprsHelpText := "Show how each conditional key in the specified " +
+		"packages' pkg.yml and syscfg.yml files evaluates for a target.  " +
+		"Conditionals whose expression cannot be parsed or evaluated, or " +
+		"that reference undefined settings, are flagged."
+
+	exprsCmd := &cobra.Command{
+		Use:   "exprs <target> <pkg> [pkg...]",
+		Short: "Show how a package's conditional keys evaluate",
+		Long:  exprsHelpText,
+		Run:   targetExprsCmd,
+	}
+
+	cmds = append(cmds, exprsCmd)
+	AddTabCompleteFn(exprsCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
+
 	return cmds
 }
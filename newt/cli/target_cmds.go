@@ -21,15 +21,20 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"mynewt.apache.org/newt/newt/ycfg"
 	"os"
+	"path"
 	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/apache/mynewt-artifact/flash"
+	"github.com/apache/mynewt-artifact/image"
 	"mynewt.apache.org/newt/newt/builder"
 	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/pkg"
@@ -37,17 +42,32 @@ import (
 	"mynewt.apache.org/newt/newt/syscfg"
 	"mynewt.apache.org/newt/newt/target"
 	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newt/yaml"
 )
 
 var amendDelete bool = false
+var setReplace bool = false
 var showAll bool = false
 var listAll bool = false
+var apisFormat string = "text"
+var targetDelPurge bool = false
+var targetDelKeepArtifacts bool = false
+var targetTrashEmpty bool = false
+var targetFilters []string
+var targetColumns string
+var targetShowJSON bool
+var targetShowYAML bool
+
+// Shorthand names accepted by the --filter and --columns options.
+var targetVarAliases = map[string]string{
+	"profile": "build_profile",
+}
 
 // target variables that can have values amended with the amend command.
 var amendVars = []string{"aflags", "cflags", "cxxflags", "lflags", "syscfg"}
 
 var setVars = []string{"aflags", "app", "build_profile", "bsp", "cflags",
-	"cxxflags", "lflags", "loader", "syscfg"}
+	"cxxflags", "lflags", "loader", "rom_elf", "syscfg"}
 
 func resolveExistingTargetArg(arg string) (*target.Target, error) {
 	t := ResolveTarget(arg)
@@ -177,28 +197,311 @@ func amendBuildFlags(kv []string, t *target.Target) error {
 	return nil
 }
 
-func targetShowCmd(cmd *cobra.Command, args []string) {
-	TryGetProject()
-	targetNames := []string{}
-	if len(args) == 0 {
-		for name, t := range target.GetTargets() {
-			keep := func() bool {
-				// Don't display the special unittest target; this is used
-				// internally by newt, so the user doesn't need to know about
-				// it.
-				if strings.HasSuffix(name, "/unittest") {
-					return false
-				}
+// Indicates whether a target is listed when no targets are specified.
+func targetListed(name string, t *target.Target, all bool) bool {
+	// Don't display the special unittest target; this is used internally by
+	// newt, so the user doesn't need to know about it.
+	if strings.HasSuffix(name, "/unittest") {
+		return false
+	}
 
-				// Don't show foreign targets without the `-a` option.
-				if !showAll && !t.Package().Repo().IsLocal() {
-					return false
-				}
+	// Don't show foreign targets without the `-a` option.
+	if !all && !t.Package().Repo().IsLocal() {
+		return false
+	}
+
+	return true
+}
+
+// Collects the variables of a target, keyed by their `newt target set`
+// names.  The "name" variable holds the target's full name.
+func targetVars(t *target.Target) map[string]string {
+	kvPairs := map[string]string{}
+
+	settings := t.TargetY.AllSettingsAsStrings()
+	for k, v := range settings {
+		kvPairs[strings.TrimPrefix(k, "target.")] = v
+	}
+
+	// A few variables come from the base package rather than the target.
+	scfg, err := t.Package().SyscfgY.GetValStringMapString(
+		"syscfg.vals", nil)
+	util.OneTimeWarningError(err)
+	kvPairs["syscfg"] = syscfg.KeyValueToStr(scfg)
+
+	kvPairs["cflags"] = pkgVarSliceString(t.Package(), "pkg.cflags")
+	kvPairs["cxxflags"] = pkgVarSliceString(t.Package(), "pkg.cxxflags")
+	kvPairs["lflags"] = pkgVarSliceString(t.Package(), "pkg.lflags")
+	kvPairs["aflags"] = pkgVarSliceString(t.Package(), "pkg.aflags")
+
+	kvPairs["name"] = t.FullName()
+
+	return kvPairs
+}
+
+// The machine-readable description of a target printed by
+// `newt target show --json` and `newt target show --yaml`.
+type targetShowInfo struct {
+	// Target variables (e.g., "app", "bsp", "build_profile").
+	Vars map[string]string `json:"vars"`
 
-				return true
+	// The target's syscfg overrides.
+	Syscfg map[string]string `json:"syscfg"`
+
+	Cflags   []string `json:"cflags"`
+	Cxxflags []string `json:"cxxflags"`
+	Lflags   []string `json:"lflags"`
+	Aflags   []string `json:"aflags"`
+}
+
+func newTargetShowInfo(t *target.Target) targetShowInfo {
+	ti := targetShowInfo{
+		Vars: map[string]string{},
+	}
+
+	settings := t.TargetY.AllSettingsAsStrings()
+	for k, v := range settings {
+		ti.Vars[strings.TrimPrefix(k, "target.")] = v
+	}
+
+	var err error
+	ti.Syscfg, err = t.Package().SyscfgY.GetValStringMapString(
+		"syscfg.vals", nil)
+	util.OneTimeWarningError(err)
+	if ti.Syscfg == nil {
+		ti.Syscfg = map[string]string{}
+	}
+
+	// Unlike the text output, flags are kept in their original order.
+	flags := func(key string) []string {
+		vals, err := t.Package().PkgY.GetValStringSlice(key, nil)
+		util.OneTimeWarningError(err)
+		if vals == nil {
+			vals = []string{}
+		}
+		return vals
+	}
+	ti.Cflags = flags("pkg.cflags")
+	ti.Cxxflags = flags("pkg.cxxflags")
+	ti.Lflags = flags("pkg.lflags")
+	ti.Aflags = flags("pkg.aflags")
+
+	return ti
+}
+
+// Formats a scalar for the YAML output of `target show`.  Empty strings are
+// quoted so that they are not read back as null.
+func targetShowYamlScalar(s string) string {
+	if s == "" {
+		return "\"\""
+	}
+	return yaml.EscapeString(s)
+}
+
+// Writes the target description as the YAML mapping of the target with the
+// specified name.  Empty lists and maps are written in flow style so that
+// they are not read back as null.
+func (ti targetShowInfo) writeYaml(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s:\n", targetShowYamlScalar(name))
+
+	writeMap := func(key string, m map[string]string) {
+		if len(m) == 0 {
+			fmt.Fprintf(w, "    %s: {}\n", key)
+			return
+		}
+
+		fmt.Fprintf(w, "    %s:\n", key)
+		keys := make([]string, 0, len(m))
+		for k, _ := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "        %s: %s\n", targetShowYamlScalar(k),
+				targetShowYamlScalar(m[k]))
+		}
+	}
+
+	writeList := func(key string, ss []string) {
+		if len(ss) == 0 {
+			fmt.Fprintf(w, "    %s: []\n", key)
+			return
+		}
+
+		fmt.Fprintf(w, "    %s:\n", key)
+		for _, s := range ss {
+			fmt.Fprintf(w, "        - %s\n", targetShowYamlScalar(s))
+		}
+	}
+
+	writeMap("vars", ti.Vars)
+	writeMap("syscfg", ti.Syscfg)
+	writeList("cflags", ti.Cflags)
+	writeList("cxxflags", ti.Cxxflags)
+	writeList("lflags", ti.Lflags)
+	writeList("aflags", ti.Aflags)
+}
+
+// Prints the specified targets as a JSON or YAML object keyed by target name.
+func printTargetsMachine(names []string) {
+	targets := target.GetTargets()
+
+	if targetShowJSON {
+		m := map[string]targetShowInfo{}
+		for _, name := range names {
+			m[name] = newTargetShowInfo(targets[name])
+		}
+
+		js, err := json.MarshalIndent(m, "", "    ")
+		if err != nil {
+			NewtUsage(nil, util.ChildNewtError(err))
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", js)
+		return
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	buf := bytes.Buffer{}
+	for _, name := range sorted {
+		newTargetShowInfo(targets[name]).writeYaml(&buf, name)
+	}
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s", buf.String())
+}
+
+// Translates a --filter or --columns variable name into a target variable.
+func targetVarName(name string) string {
+	if alias, ok := targetVarAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+type targetFilter struct {
+	varName string
+	pattern string
+}
+
+// Parses the --filter arguments.  Each filter has the form <var>=<pattern>.
+func parseTargetFilters(args []string) ([]targetFilter, error) {
+	var filters []targetFilter
+
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, util.FmtNewtError(
+				"invalid filter \"%s\"; expected <variable>=<pattern>", arg)
+		}
+
+		if _, err := path.Match(parts[1], ""); err != nil {
+			return nil, util.FmtNewtError(
+				"invalid filter pattern \"%s\": %s", parts[1], err.Error())
+		}
+
+		filters = append(filters, targetFilter{
+			varName: targetVarName(parts[0]),
+			pattern: parts[1],
+		})
+	}
+
+	return filters, nil
+}
+
+// Indicates whether a target's variables satisfy every filter.  A pattern
+// matches if it matches either the whole value or the value's last path
+// component, so that "bsp=nordic*" matches
+// "@apache-mynewt-core/hw/bsp/nordic_pca10056".
+func targetMatchesFilters(vars map[string]string,
+	filters []targetFilter) bool {
+
+	for _, f := range filters {
+		val := vars[f.varName]
+		full, _ := path.Match(f.pattern, val)
+		base, _ := path.Match(f.pattern, path.Base(val))
+		if !full && !(val != "" && base) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Parses the --columns argument.
+func parseTargetColumns(arg string) ([]string, error) {
+	var cols []string
+	for _, c := range strings.Split(arg, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			return nil, util.FmtNewtError("invalid column list \"%s\"", arg)
+		}
+		cols = append(cols, c)
+	}
+
+	return cols, nil
+}
+
+// Prints one row per target, with the specified variables aligned in columns.
+func printTargetTable(names []string, vars []map[string]string,
+	cols []string) {
+
+	widths := make([]int, len(cols))
+	for i, c := range cols {
+		widths[i] = len(c)
+		for _, v := range vars {
+			if n := len(v[targetVarName(c)]); n > widths[i] {
+				widths[i] = n
 			}
+		}
+	}
+
+	row := func(cells []string, header bool) {
+		var fields []string
+		for i, c := range cells {
+			cell := c
+			if i < len(cells)-1 {
+				cell = fmt.Sprintf("%-*s", widths[i], c)
+			}
+			if header {
+				cell = util.ColorText(util.COLOR_BOLD, cell)
+			}
+			fields = append(fields, cell)
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n",
+			strings.TrimRight(strings.Join(fields, "  "), " "))
+	}
+
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = strings.ToUpper(c)
+	}
+	row(header, true)
+
+	for _, v := range vars {
+		cells := make([]string, len(cols))
+		for i, c := range cols {
+			cells[i] = v[targetVarName(c)]
+		}
+		row(cells, false)
+	}
+}
+
+// Selects the targets to show or list: the specified targets, or all listed
+// targets if none are specified.  The selection is narrowed by the --filter
+// arguments.  The returned names are sorted; the second return value holds
+// each target's variables.
+func selectTargets(cmd *cobra.Command, args []string,
+	all bool) ([]string, []map[string]string) {
 
-			if keep() {
+	filters, err := parseTargetFilters(targetFilters)
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	targetNames := []string{}
+	if len(args) == 0 {
+		for name, t := range target.GetTargets() {
+			if targetListed(name, t, all) {
 				targetNames = append(targetNames, name)
 			}
 		}
@@ -215,27 +518,53 @@ func targetShowCmd(cmd *cobra.Command, args []string) {
 
 	sort.Strings(targetNames)
 
+	names := []string{}
+	vars := []map[string]string{}
 	for _, name := range targetNames {
-		kvPairs := map[string]string{}
+		v := targetVars(target.GetTargets()[name])
+		if targetMatchesFilters(v, filters) {
+			names = append(names, name)
+			vars = append(vars, v)
+		}
+	}
 
-		util.StatusMessage(util.VERBOSITY_DEFAULT, name+"\n")
+	return names, vars
+}
+
+func targetShowCmd(cmd *cobra.Command, args []string) {
+	TryGetProject()
+
+	if targetShowJSON && targetShowYAML {
+		NewtUsage(cmd, util.NewNewtError(
+			"--json and --yaml are mutually exclusive"))
+	}
+	if (targetShowJSON || targetShowYAML) && targetColumns != "" {
+		NewtUsage(cmd, util.NewNewtError(
+			"--columns cannot be combined with --json or --yaml"))
+	}
+
+	targetNames, targetVarMaps := selectTargets(cmd, args, showAll)
 
-		target := target.GetTargets()[name]
-		settings := target.TargetY.AllSettingsAsStrings()
-		for k, v := range settings {
-			kvPairs[strings.TrimPrefix(k, "target.")] = v
+	if targetShowJSON || targetShowYAML {
+		printTargetsMachine(targetNames)
+		return
+	}
+
+	if targetColumns != "" {
+		cols, err := parseTargetColumns(targetColumns)
+		if err != nil {
+			NewtUsage(cmd, err)
 		}
+		printTargetTable(targetNames, targetVarMaps, cols)
+		return
+	}
 
-		// A few variables come from the base package rather than the target.
-		scfg, err := target.Package().SyscfgY.GetValStringMapString(
-			"syscfg.vals", nil)
-		util.OneTimeWarningError(err)
-		kvPairs["syscfg"] = syscfg.KeyValueToStr(scfg)
+	for i, name := range targetNames {
+		kvPairs := targetVarMaps[i]
+		delete(kvPairs, "name")
 
-		kvPairs["cflags"] = pkgVarSliceString(target.Package(), "pkg.cflags")
-		kvPairs["cxxflags"] = pkgVarSliceString(target.Package(), "pkg.cxxflags")
-		kvPairs["lflags"] = pkgVarSliceString(target.Package(), "pkg.lflags")
-		kvPairs["aflags"] = pkgVarSliceString(target.Package(), "pkg.aflags")
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n",
+			util.ColorText(util.COLOR_BOLD, name))
 
 		keys := []string{}
 		for k, _ := range kvPairs {
@@ -246,7 +575,7 @@ func targetShowCmd(cmd *cobra.Command, args []string) {
 			val := kvPairs[k]
 			if len(val) > 0 {
 				util.StatusMessage(util.VERBOSITY_DEFAULT, "    %s=%s\n",
-					k, kvPairs[k])
+					util.ColorText(util.COLOR_CYAN, k), kvPairs[k])
 			}
 		}
 	}
@@ -296,33 +625,159 @@ func targetInfoCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
-func targetListCmd(cmd *cobra.Command, args []string) {
+func targetSlotsCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd,
+			util.NewNewtError("Must specify target name"))
+	}
+
 	TryGetProject()
-	targetNames := []string{}
 
-	for name, t := range target.GetTargets() {
-		keep := func() bool {
-			// Don't display the special unittest target; this is used
-			// internally by newt, so the user doesn't need to know about
-			// it.
-			if strings.HasSuffix(name, "/unittest") {
-				return false
-			}
+	t, err := resolveExistingTargetArg(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+	if t.App() == nil {
+		NewtUsage(nil, util.FmtNewtError(
+			"Target \"%s\" does not specify an app", t.FullName()))
+	}
 
-			// Don't show foreign targets without the `-a` option.
-			if !listAll && !t.Package().Repo().IsLocal() {
-				return false
-			}
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	fm := b.BspPkg().FlashMap
+	slotNames := []string{
+		flash.FLASH_AREA_NAME_IMAGE_0,
+		flash.FLASH_AREA_NAME_IMAGE_1,
+	}
 
-			return true
+	trailerSz := b.BootTrailerSize()
+	hdrSz := image.IMAGE_HEADER_SIZE
+	if b.BspPkg().ImageOffset > hdrSz {
+		hdrSz = b.BspPkg().ImageOffset
+	}
+
+	// Derived from the trailer size rather than with b.MaxImgSizes() so that
+	// warnings about the trailer size are only reported once.
+	maxSizes := make([]int, len(slotNames))
+	for i, name := range slotNames {
+		maxSizes[i] = fm.Areas[name].Size - trailerSz
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Boot slots for target %s:\n",
+		t.FullName())
+	for i, name := range slotNames {
+		area, ok := fm.Areas[name]
+		if !ok {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"    %s: not defined\n", name)
+			continue
 		}
 
-		if keep() {
-			targetNames = append(targetNames, name)
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"    %s: device=%d offset=0x%x size=%d max_image=%d "+
+				"max_body=%d\n", name, area.Device, area.Offset, area.Size,
+			maxSizes[i], maxSizes[i]-hdrSz)
+	}
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Image header size: %d\nBoot trailer size: %d\n", hdrSz, trailerSz)
+
+	imgPath := builder.AppImgPath(t.Name(), builder.BUILD_NAME_APP,
+		t.App().FullName())
+	st, err := os.Stat(imgPath)
+	if err != nil {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"No image has been created for this target\n")
+		return
+	}
+
+	imgSz := int(st.Size())
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Most recent image: %s (%d bytes)\n", imgPath, imgSz)
+
+	free := -1
+	for i, name := range slotNames {
+		if _, ok := fm.Areas[name]; !ok {
+			continue
+		}
+		if imgSz > maxSizes[i] {
+			NewtUsage(nil, util.FmtNewtError(
+				"Image does not fit in %s; image=%d max=%d overflow=%d",
+				name, imgSz, maxSizes[i], imgSz-maxSizes[i]))
+		}
+		if free < 0 || maxSizes[i]-imgSz < free {
+			free = maxSizes[i] - imgSz
 		}
 	}
 
-	sort.Strings(targetNames)
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Image fits in all slots (%d bytes free in smallest slot)\n", free)
+}
+
+func targetAddBundleCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		NewtUsage(cmd, util.NewNewtError(
+			"Must specify a target and a bundle"))
+	}
+
+	TryGetProject()
+
+	t, err := resolveExistingTargetArg(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	// Trim trailing slash from name.  This is necessary when tab
+	// completion is used to fill in the name.
+	bundleName := strings.TrimSuffix(args[1], "/")
+
+	bundle := t.ResolvePackageName(bundleName)
+	if bundle == nil {
+		NewtUsage(cmd, util.FmtNewtError(
+			"Could not resolve bundle package: %s", bundleName))
+	}
+	if bundle.Type() != pkg.PACKAGE_TYPE_BUNDLE {
+		NewtUsage(cmd, util.FmtNewtError(
+			"Package %s is not a bundle; type is %s",
+			bundle.FullName(), pkg.PackageTypeNames[bundle.Type()]))
+	}
+
+	deps, err := t.Package().PkgY.GetValStringSlice("pkg.deps", nil)
+	util.OneTimeWarningError(err)
+
+	for _, dep := range deps {
+		if t.ResolvePackageName(dep) == bundle {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"Target %s already includes bundle %s\n",
+				t.FullName(), bundle.FullName())
+			return
+		}
+	}
+
+	t.Package().PkgY.Replace("pkg.deps", append(deps, bundle.FullName()))
+	if err := t.Save(); err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Added bundle %s to target %s\n", bundle.FullName(), t.FullName())
+}
+
+func targetListCmd(cmd *cobra.Command, args []string) {
+	TryGetProject()
+
+	targetNames, targetVarMaps := selectTargets(cmd, nil, listAll)
+
+	if targetColumns != "" {
+		cols, err := parseTargetColumns(targetColumns)
+		if err != nil {
+			NewtUsage(cmd, err)
+		}
+		printTargetTable(targetNames, targetVarMaps, cols)
+		return
+	}
 
 	for _, name := range targetNames {
 		util.StatusMessage(util.VERBOSITY_DEFAULT, name+"\n")
@@ -406,6 +861,22 @@ func targetSetCmd(cmd *cobra.Command, args []string) {
 		// A few variables are special cases; they get set in the base package
 		// instead of the target.
 		if kv[0] == "target.syscfg" {
+			if !setReplace {
+				// Merging nothing into the existing settings would leave
+				// them unchanged; clearing them requires --replace.
+				if kv[1] == "" {
+					NewtUsage(cmd, util.NewNewtError(
+						"syscfg= does not clear a target's syscfg settings "+
+							"unless --replace is specified"))
+				}
+
+				// Merge the specified settings with the existing ones.
+				if err := amendSysCfg(kv[1], t); err != nil {
+					NewtUsage(cmd, err)
+				}
+				continue
+			}
+
 			t.Package().SyscfgY.Clear()
 			kv, err := syscfg.KeyValueFromStr(kv[1])
 			if err != nil {
@@ -453,6 +924,26 @@ func targetSetCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+// Applies a set of amendments to a single target and saves it.
+func amendOneTarget(t *target.Target, vars [][]string) error {
+	for _, kv := range vars {
+		if kv[0] == "syscfg" {
+			if err := amendSysCfg(kv[1], t); err != nil {
+				return err
+			}
+		} else if kv[0] == "cflags" ||
+			kv[0] == "cxxflags" ||
+			kv[0] == "lflags" ||
+			kv[0] == "aflags" {
+			if err := amendBuildFlags(kv, t); err != nil {
+				return err
+			}
+		}
+	}
+
+	return t.Save()
+}
+
 func targetAmendCmd(cmd *cobra.Command, args []string) {
 	if len(args) < 2 {
 		NewtUsage(cmd,
@@ -462,8 +953,8 @@ func targetAmendCmd(cmd *cobra.Command, args []string) {
 
 	TryGetProject()
 
-	// Parse target name.
-	t, err := resolveExistingTargetArg(args[0])
+	// Parse target name or pattern.
+	targets, err := ResolveTargetPattern(args[0])
 	if err != nil {
 		NewtUsage(cmd, err)
 	}
@@ -502,31 +993,209 @@ func targetAmendCmd(cmd *cobra.Command, args []string) {
 		kv[1] = strings.TrimSuffix(kv[1], "/")
 		vars = append(vars, kv)
 	}
-	for _, kv := range vars {
-		if kv[0] == "syscfg" {
-			err = amendSysCfg(kv[1], t)
-			if err != nil {
-				NewtUsage(cmd, err)
-			}
-		} else if kv[0] == "cflags" ||
-			kv[0] == "cxxflags" ||
-			kv[0] == "lflags" ||
-			kv[0] == "aflags" {
-			err = amendBuildFlags(kv, t)
-			if err != nil {
-				NewtUsage(cmd, err)
-			}
+
+	// Amend each target independently; a failure does not prevent the
+	// remaining targets from being amended.
+	numFailed := 0
+	for _, t := range targets {
+		if err := amendOneTarget(t, vars); err != nil {
+			util.StatusMessage(util.VERBOSITY_QUIET,
+				"Failed to amend target %s: %s\n", t.FullName(),
+				strings.TrimSpace(err.Error()))
+			numFailed++
+			continue
+		}
+
+		for _, kv := range vars {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"Amended %s for Target %s successfully\n",
+				kv[0], t.FullName())
+		}
+	}
+
+	if numFailed > 0 {
+		NewtUsage(nil, util.FmtNewtError("Failed to amend %d of %d targets",
+			numFailed, len(targets)))
+	}
+}
+
+func targetUndoCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify a target name"))
+	}
+
+	TryGetProject()
+
+	t, err := resolveExistingTargetArg(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	entry, err := t.Undo()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Target %s successfully reverted; undid: %s\n",
+		t.FullName(), entry.Command)
+}
+
+func targetHistoryCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify a target name"))
+	}
+
+	TryGetProject()
+
+	t, err := resolveExistingTargetArg(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	entries, err := t.History()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if len(entries) == 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Target %s has no recorded changes\n", t.FullName())
+		return
+	}
+
+	// Most recent first; this is the order in which changes are undone.
+	for i := len(entries) - 1; i >= 0; i-- {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s    %s\n",
+			entries[i].Time.Format("2006-01-02 15:04:05"),
+			entries[i].Command)
+	}
+}
+
+// The parts of a target's resolution that `newt target rdiff` compares.
+type targetRdiffInfo struct {
+	pkgs   map[string]struct{}
+	syscfg map[string]string
+	cflags map[string]struct{}
+}
+
+func targetRdiffCollect(name string) (targetRdiffInfo, error) {
+	info := targetRdiffInfo{
+		pkgs:   map[string]struct{}{},
+		cflags: map[string]struct{}{},
+	}
+
+	t := ResolveTarget(name)
+	if t == nil {
+		return info, util.NewNewtError("Invalid target name: " + name)
+	}
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		return info, err
+	}
+
+	if err := b.PrepBuild(); err != nil {
+		return info, err
+	}
+
+	fr, err := b.FrozenResolution()
+	if err != nil {
+		return info, err
+	}
+
+	for _, p := range fr.Pkgs {
+		info.pkgs[p] = struct{}{}
+	}
+	info.syscfg = fr.Syscfg
+
+	for _, f := range b.AppBuilder.GetCompilerInfo().Cflags {
+		info.cflags[f] = struct{}{}
+	}
+
+	return info, nil
+}
+
+// Prints the sorted members of `a` that are not in `b`.
+func targetRdiffPrintOnly(heading string, a map[string]struct{},
+	b map[string]struct{}) {
+
+	var only []string
+	for k, _ := range a {
+		if _, ok := b[k]; !ok {
+			only = append(only, k)
+		}
+	}
+	if len(only) == 0 {
+		return
+	}
+
+	sort.Strings(only)
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s:\n", heading)
+	for _, k := range only {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "    %s\n", k)
+	}
+}
+
+func targetRdiffCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		NewtUsage(cmd, util.NewNewtError("Must specify two target names"))
+	}
+
+	TryGetProject()
+
+	infoA, err := targetRdiffCollect(args[0])
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	// Resolving a target modifies global state.
+	if err := ResetGlobalState(); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	infoB, err := targetRdiffCollect(args[1])
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	nameA := args[0]
+	nameB := args[1]
+
+	targetRdiffPrintOnly("Packages only in "+nameA, infoA.pkgs, infoB.pkgs)
+	targetRdiffPrintOnly("Packages only in "+nameB, infoB.pkgs, infoA.pkgs)
+
+	var settings []string
+	for k, va := range infoA.syscfg {
+		if vb, ok := infoB.syscfg[k]; !ok || va != vb {
+			settings = append(settings, k)
 		}
 	}
-	if err := t.Save(); err != nil {
-		NewtUsage(cmd, err)
+	for k, _ := range infoB.syscfg {
+		if _, ok := infoA.syscfg[k]; !ok {
+			settings = append(settings, k)
+		}
 	}
-
-	for _, kv := range vars {
+	if len(settings) > 0 {
+		sort.Strings(settings)
 		util.StatusMessage(util.VERBOSITY_DEFAULT,
-			"Amended %s for Target %s successfully\n",
-			kv[0], t.FullName())
+			"Syscfg differences (%s | %s):\n", nameA, nameB)
+		for _, k := range settings {
+			va, ok := infoA.syscfg[k]
+			if !ok {
+				va = "(undefined)"
+			}
+			vb, ok := infoB.syscfg[k]
+			if !ok {
+				vb = "(undefined)"
+			}
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"    %s: %s | %s\n", k, va, vb)
+		}
 	}
+
+	targetRdiffPrintOnly("Cflags only in "+nameA, infoA.cflags, infoB.cflags)
+	targetRdiffPrintOnly("Cflags only in "+nameB, infoB.cflags, infoA.cflags)
 }
 
 func targetCreateCmd(cmd *cobra.Command, args []string) {
@@ -557,7 +1226,7 @@ func targetCreateCmd(cmd *cobra.Command, args []string) {
 }
 
 func targetDelOne(t *target.Target) error {
-	if !newtutil.NewtForce {
+	if targetDelPurge && !newtutil.NewtForce {
 		// Determine if the target directory contains extra user files.  If it
 		// does, a prompt (or force) is required to delete it.
 		userFiles, err := targetContainsUserFiles(t)
@@ -575,12 +1244,35 @@ func targetDelOne(t *target.Target) error {
 		}
 	}
 
-	if err := os.RemoveAll(t.Package().BasePath()); err != nil {
-		return util.NewNewtError(err.Error())
+	binDir := ""
+	if !targetDelKeepArtifacts {
+		binDir = builder.TargetBinDir(t.FullName())
 	}
 
-	util.StatusMessage(util.VERBOSITY_DEFAULT,
-		"Target %s successfully deleted.\n", t.FullName())
+	if targetDelPurge {
+		if err := os.RemoveAll(t.Package().BasePath()); err != nil {
+			return util.NewNewtError(err.Error())
+		}
+		if binDir != "" {
+			if err := os.RemoveAll(binDir); err != nil {
+				return util.ChildNewtError(err)
+			}
+		}
+	} else {
+		// The build artifacts go to the trash along with the target.
+		if _, err := t.MoveToTrash(binDir); err != nil {
+			return err
+		}
+	}
+
+	if targetDelPurge {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Target %s successfully deleted.\n", t.FullName())
+	} else {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Target %s moved to the trash; restore it with "+
+				"\"newt target restore %s\".\n", t.FullName(), t.ShortName())
+	}
 
 	return nil
 }
@@ -605,6 +1297,120 @@ func targetDelCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+func targetRestoreCmd(cmd *cobra.Command, args []string) {
+	if len(args) < 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify at least one "+
+			"target to restore"))
+	}
+
+	TryGetProject()
+
+	for _, arg := range args {
+		name, err := ResolveNewTargetName(arg)
+		if err != nil {
+			NewtUsage(cmd, err)
+		}
+
+		entry, err := target.RestoreTarget(name)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Target %s successfully restored (deleted %s).\n",
+			entry.Name, entry.Time.Format("2006-01-02 15:04:05"))
+	}
+}
+
+func targetGroupsCmd(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		NewtUsage(cmd, util.NewNewtError("Too many arguments"))
+	}
+
+	proj := TryGetProject()
+
+	groups, err := proj.TargetGroups()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+	aliases := proj.TargetAliases()
+
+	if len(groups) == 0 && len(aliases) == 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"No target groups or aliases defined\n")
+		return
+	}
+
+	groupNames := make([]string, 0, len(groups))
+	for name, _ := range groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+
+	for _, name := range groupNames {
+		targets, err := ResolveTargets("@" + name)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+
+		names := make([]string, len(targets))
+		for i, t := range targets {
+			names[i] = t.FullName()
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "@%s: %s\n",
+			name, strings.Join(names, " "))
+	}
+
+	aliasNames := make([]string, 0, len(aliases))
+	for name, _ := range aliases {
+		aliasNames = append(aliasNames, name)
+	}
+	sort.Strings(aliasNames)
+
+	for _, name := range aliasNames {
+		t := ResolveTarget(name)
+		if t == nil {
+			NewtUsage(nil, util.FmtNewtError(
+				"target alias \"%s\" refers to unknown target: %s",
+				name, aliases[name]))
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s -> %s\n",
+			name, t.FullName())
+	}
+}
+
+func targetTrashCmd(cmd *cobra.Command, args []string) {
+	TryGetProject()
+
+	if targetTrashEmpty {
+		n, err := target.EmptyTrash()
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Permanently deleted %d target(s) from the trash.\n", n)
+		return
+	}
+
+	entries, err := target.TrashEntries()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if len(entries) == 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "The trash is empty\n")
+		return
+	}
+
+	// Most recent first; this is the order in which targets are restored.
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s  %s\n",
+			e.Time.Format("2006-01-02 15:04:05"), e.Name)
+	}
+}
+
 func targetCopyCmd(cmd *cobra.Command, args []string) {
 	if len(args) != 2 {
 		NewtUsage(cmd, util.NewNewtError("Must specify exactly one "+
@@ -770,6 +1576,223 @@ func targetRevdepvizCmd(cmd *cobra.Command, args []string) {
 	}
 }
 
+func targetApisCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target name"))
+	}
+
+	TryGetProject()
+
+	b, err := TargetBuilderForTargetOrUnittest(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	res, err := b.Resolve()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	topos := res.ApiTopology()
+
+	switch apisFormat {
+	case "text":
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s",
+			builder.ApiTopologyText(topos))
+
+	case "dot":
+		fmt.Print(builder.ApiTopologyViz(topos))
+
+	case "json":
+		s, err := builder.ApiTopologyJson(topos)
+		if err != nil {
+			NewtUsage(nil, err)
+		}
+		fmt.Print(s)
+
+	default:
+		NewtUsage(cmd, util.FmtNewtError(
+			"invalid format \"%s\"; must be one of: text, dot, json",
+			apisFormat))
+	}
+}
+
+func targetLintCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target name"))
+	}
+
+	TryGetProject()
+
+	b, err := TargetBuilderForTargetOrUnittest(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	res, err := b.Resolve()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	sections := []struct {
+		title string
+		lines []string
+	}{
+		{"Deprecated packages", res.PkgDeprecatedWarning()},
+		{"Experimental packages", res.PkgExperimentalWarning()},
+		{"Deprecated settings", res.DeprecatedWarning()},
+		{"Experimental settings", res.CfgExperimentalWarning()},
+	}
+
+	found := false
+	for _, sec := range sections {
+		if len(sec.lines) == 0 {
+			continue
+		}
+
+		if found {
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "\n")
+		}
+		found = true
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s:\n", sec.title)
+		for _, line := range sec.lines {
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "    %s\n", line)
+		}
+	}
+
+	if !found {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Target %s: no deprecated or experimental packages or settings\n",
+			b.GetTarget().FullName())
+	}
+}
+
+func targetHalReportCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target name"))
+	}
+
+	TryGetProject()
+
+	b, err := TargetBuilderForTargetOrUnittest(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	mods, err := b.HalReport()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s",
+		builder.HalReportText(mods))
+
+	numUnimpl := 0
+	numUnused := 0
+	for _, m := range mods {
+		if m.Unimplemented() {
+			numUnimpl++
+		} else if m.Unused() {
+			numUnused++
+		}
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"\n%d HAL module(s): %d used but unimplemented, "+
+			"%d implemented but unused\n", len(mods), numUnimpl, numUnused)
+}
+
+func targetLinkOrderCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target name"))
+	}
+
+	TryGetProject()
+
+	b, err := TargetBuilderForTargetOrUnittest(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	infos, err := b.LinkOrder()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s",
+		builder.LinkOrderText(infos))
+}
+
+func targetCompileCommandsCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target name"))
+	}
+
+	TryGetProject()
+
+	b, err := TargetBuilderForTargetOrUnittest(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	if err := b.GenerateCompileCommands(); err != nil {
+		NewtUsage(nil, err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Wrote %s\n",
+		b.CompileCmdsPath())
+}
+
+func targetRomCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify target name"))
+	}
+
+	TryGetProject()
+
+	t, err := resolveExistingTargetArg(args[0])
+	if err != nil {
+		NewtUsage(cmd, err)
+	}
+
+	b, err := builder.NewTargetBuilder(t)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	info, err := b.BuildRom()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	pkgs := map[string]struct{}{}
+	for _, si := range info.Syms {
+		pkgs[si.Bpkg] = struct{}{}
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"ROM ELF: %s\nSymbol list: %s\n%d symbol(s) exported from "+
+			"%d package(s)\n",
+		info.ElfPath, info.SymsPath, len(info.Syms), len(pkgs))
+}
+
+const targetFilterHelpText = "" +
+	"--filter <var>=<pattern> only includes targets whose variable matches " +
+	"a glob pattern, e.g., bsp=nordic*.  The pattern may match either the " +
+	"whole value or its last path component.  Multiple filters must all " +
+	"match.\n\n" +
+	"--columns <var>,... prints a table with one row per target and one " +
+	"column per variable.  \"name\" is the target's name and \"profile\" " +
+	"is short for build_profile."
+
+func addTargetTableFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&targetFilters, "filter", nil,
+		"Only include targets matching <var>=<pattern> (repeatable)")
+	cmd.Flags().StringVar(&targetColumns, "columns", "",
+		"Print a table with the specified comma-separated variables")
+}
+
 func AddTargetCommands(cmd *cobra.Command) {
 	targetHelpText := ""
 	targetHelpEx := ""
@@ -786,9 +1809,13 @@ func AddTargetCommands(cmd *cobra.Command) {
 	cmd.AddCommand(targetCmd)
 
 	showHelpText := "Show all the variables for the target specified " +
-		"by <target-name>."
+		"by <target-name>.  If no target is specified, all targets are " +
+		"shown.\n\n" + targetFilterHelpText
 	showHelpEx := "  newt target show <target-name>\n"
-	showHelpEx += "  newt target show my_target1"
+	showHelpEx += "  newt target show my_target1\n"
+	showHelpEx += "  newt target show --filter bsp=nordic* " +
+		"--columns name,bsp,app,profile\n"
+	showHelpEx += "  newt target show --json my_target1"
 
 	showCmd := &cobra.Command{
 		Use:     "show",
@@ -799,11 +1826,18 @@ func AddTargetCommands(cmd *cobra.Command) {
 	}
 	showCmd.Flags().BoolVarP(&showAll, "all", "a", false,
 		"Show all targets (including from other repos)")
+	showCmd.Flags().BoolVar(&targetShowJSON, "json", false,
+		"Print the targets as a JSON object keyed by target name")
+	showCmd.Flags().BoolVar(&targetShowYAML, "yaml", false,
+		"Print the targets as a YAML mapping keyed by target name")
+	addTargetTableFlags(showCmd)
 	targetCmd.AddCommand(showCmd)
 	AddTabCompleteFn(showCmd, targetList)
 
-	listHelpText := "List all available targets."
-	listHelpEx := "  newt target list"
+	listHelpText := "List all available targets.\n\n" + targetFilterHelpText
+	listHelpEx := "  newt target list\n"
+	listHelpEx += "  newt target list --filter app=*blinky " +
+		"--columns name,bsp,profile"
 
 	listCmd := &cobra.Command{
 		Use:     "list",
@@ -814,6 +1848,7 @@ func AddTargetCommands(cmd *cobra.Command) {
 	}
 	listCmd.Flags().BoolVarP(&listAll, "all", "a", false,
 		"List all targets (including from other repos)")
+	addTargetTableFlags(listCmd)
 	targetCmd.AddCommand(listCmd)
 
 	cmakeHelpText := "Generate CMakeLists.txt for target specified " +
@@ -835,15 +1870,18 @@ func AddTargetCommands(cmd *cobra.Command) {
 	setHelpText += "<target-name> to value <value>.\n"
 	setHelpText += "Variables that can be set are:\n"
 	setHelpText += strings.Join(setVars, "\n") + "\n\n"
-	setHelpText += "Warning: When setting the syscfg variable, a new syscfg.yml file\n"
-	setHelpText += "is created and the current settings are deleted. Only the settings\n"
-	setHelpText += "specified in the command are saved in the syscfg.yml file."
-	setHelpText += "\nIf you want to change or add a new syscfg value and keep the other\n"
-	setHelpText += "syscfg values, use the newt target amend command.\n"
+	setHelpText += "The settings specified with the syscfg variable are merged with\n"
+	setHelpText += "the target's existing syscfg values, as with the newt target amend\n"
+	setHelpText += "command.  With --replace, a new syscfg.yml file is created and the\n"
+	setHelpText += "current settings are deleted; only the settings specified in the\n"
+	setHelpText += "command are saved in the syscfg.yml file.  To remove all of the\n"
+	setHelpText += "target's syscfg settings, specify --replace syscfg=; an empty syscfg\n"
+	setHelpText += "value without --replace is an error.\n"
 	setHelpEx := "  newt target set my_target1 build_profile=optimized "
 	setHelpEx += "cflags=\"-DNDEBUG\"\n"
 	setHelpEx += "  newt target set my_target1 "
 	setHelpEx += "syscfg=LOG_NEWTMGR=1:CONFIG_NEWTMGR=0\n"
+	setHelpEx += "  newt target set --replace my_target1 syscfg=LOG_LEVEL=0\n"
 
 	setCmd := &cobra.Command{
 		Use: "set <target-name> <var-name>=<value> " +
@@ -853,13 +1891,18 @@ func AddTargetCommands(cmd *cobra.Command) {
 		Example: setHelpEx,
 		Run:     targetSetCmd,
 	}
+	setCmd.Flags().BoolVarP(&setReplace, "replace", "r", false,
+		"Replace the target's syscfg settings rather than merging with them")
 	targetCmd.AddCommand(setCmd)
 	AddTabCompleteFn(setCmd, targetList)
 
 	amendHelpText := "Add, change, or delete values for multi-value target variables\n\n"
 	amendHelpText += "Variables that can have values amended are:\n"
 	amendHelpText += strings.Join(amendVars, "\n") + "\n\n"
-	amendHelpText += "To change the value for a single value variable, such as bsp, use the\nnewt target set command.\n"
+	amendHelpText += "To change the value for a single value variable, such as bsp, use the\nnewt target set command.\n\n"
+	amendHelpText += "<target-name> may contain the wildcards *, ?, and [...] to amend every\n"
+	amendHelpText += "matching target.  Each target is amended independently and the result is\n"
+	amendHelpText += "reported for each one.\n"
 
 	amendHelpEx := "  newt target amend my_target cflags=\"-DNDEBUG -DTEST\"\n"
 	amendHelpEx += "    Adds -DDEBUG and -DTEST to cflags\n\n"
@@ -868,7 +1911,9 @@ func AddTargetCommands(cmd *cobra.Command) {
 	amendHelpEx += "    Adds -Lmylib to lflags and syscfg variables LOG_LEVEL=1 and CONFIG_NEWTMGR=0\n\n"
 	amendHelpEx += "  newt target amend my_target -d syscfg=CONFIG_NEWTMGR "
 	amendHelpEx += "cflags=\"-DNDEBUG\"\n"
-	amendHelpEx += "    Deletes syscfg variable CONFIG_NEWTMGR and -DNDEBUG from cflags\n"
+	amendHelpEx += "    Deletes syscfg variable CONFIG_NEWTMGR and -DNDEBUG from cflags\n\n"
+	amendHelpEx += "  newt target amend '*_dbg' syscfg=LOG_LEVEL=0\n"
+	amendHelpEx += "    Sets LOG_LEVEL to 0 in every target whose name ends in _dbg\n"
 
 	amendCmd := &cobra.Command{
 		Use: "amend <target-name> <var-name>=<value>" +
@@ -883,6 +1928,53 @@ func AddTargetCommands(cmd *cobra.Command) {
 	targetCmd.AddCommand(amendCmd)
 	AddTabCompleteFn(amendCmd, targetList)
 
+	undoHelpText := "Revert the most recent change that a newt command made " +
+		"to the target's target.yml, pkg.yml, or syscfg.yml file.  Newt " +
+		"remembers the last " + fmt.Sprintf("%d", target.HISTORY_MAX) +
+		" changes to each target in the project's " + target.HISTORY_DIR +
+		" directory; repeating the command reverts earlier changes."
+	undoHelpEx := "  newt target undo my_target1"
+
+	undoCmd := &cobra.Command{
+		Use:     "undo <target-name>",
+		Short:   "Revert the last change to a target",
+		Long:    undoHelpText,
+		Example: undoHelpEx,
+		Run:     targetUndoCmd,
+	}
+	targetCmd.AddCommand(undoCmd)
+	AddTabCompleteFn(undoCmd, targetList)
+
+	historyHelpText := "List the recorded changes to a target, most recent " +
+		"first.  Each change can be reverted with newt target undo."
+	historyHelpEx := "  newt target history my_target1"
+
+	historyCmd := &cobra.Command{
+		Use:     "history <target-name>",
+		Short:   "List the recorded changes to a target",
+		Long:    historyHelpText,
+		Example: historyHelpEx,
+		Run:     targetHistoryCmd,
+	}
+	targetCmd.AddCommand(historyCmd)
+	AddTabCompleteFn(historyCmd, targetList)
+
+	rdiffHelpText := "Compare the resolutions of two targets.  Reports " +
+		"the packages that are present in only one of the targets, the " +
+		"syscfg settings whose values differ, and the global cflags that " +
+		"are present in only one of the targets."
+	rdiffHelpEx := "  newt target rdiff my_target1 my_target2"
+
+	rdiffCmd := &cobra.Command{
+		Use:     "rdiff <target-name-a> <target-name-b>",
+		Short:   "Compare the resolutions of two targets",
+		Long:    rdiffHelpText,
+		Example: rdiffHelpEx,
+		Run:     targetRdiffCmd,
+	}
+	targetCmd.AddCommand(rdiffCmd)
+	AddTabCompleteFn(rdiffCmd, targetList)
+
 	createHelpText := "Create a target specified by <target-name>."
 	createHelpEx := "  newt target create <target-name>\n"
 	createHelpEx += "  newt target create my_target1"
@@ -897,9 +1989,15 @@ func AddTargetCommands(cmd *cobra.Command) {
 
 	targetCmd.AddCommand(createCmd)
 
-	delHelpText := "Delete the target specified by <target-name>."
+	delHelpText := "Delete the target specified by <target-name>.  The " +
+		"target's directory is moved to the project's " + target.TRASH_DIR +
+		" directory, from which it can be restored with newt target " +
+		"restore; use --purge to delete it permanently.  The target's " +
+		"build artifacts in bin/ are moved to the trash (or deleted, with " +
+		"--purge) along with it, unless --keep-artifacts is specified."
 	delHelpEx := "  newt target delete <target-name>\n"
-	delHelpEx += "  newt target delete my_target1"
+	delHelpEx += "  newt target delete my_target1\n"
+	delHelpEx += "  newt target delete --purge --keep-artifacts my_target1"
 
 	delCmd := &cobra.Command{
 		Use:     "delete",
@@ -911,9 +2009,60 @@ func AddTargetCommands(cmd *cobra.Command) {
 	delCmd.PersistentFlags().BoolVarP(&newtutil.NewtForce,
 		"force", "f", false,
 		"Force delete of targets with user files without prompt")
+	delCmd.Flags().BoolVar(&targetDelPurge, "purge", false,
+		"Delete permanently instead of moving to the trash")
+	delCmd.Flags().BoolVar(&targetDelKeepArtifacts, "keep-artifacts", false,
+		"Keep the target's build artifacts in bin/")
 
 	targetCmd.AddCommand(delCmd)
 
+	restoreHelpText := "Restore a target that was moved to the trash by " +
+		"newt target delete.  If the target was deleted more than once, " +
+		"the most recently deleted version is restored."
+	restoreHelpEx := "  newt target restore my_target1"
+
+	restoreCmd := &cobra.Command{
+		Use:     "restore <target-name> [target-name...]",
+		Short:   "Restore a deleted target from the trash",
+		Long:    restoreHelpText,
+		Example: restoreHelpEx,
+		Run:     targetRestoreCmd,
+	}
+	targetCmd.AddCommand(restoreCmd)
+
+	trashHelpText := "List the deleted targets in the project's " +
+		target.TRASH_DIR + " directory, most recent first."
+	trashHelpEx := "  newt target trash\n"
+	trashHelpEx += "  newt target trash --empty"
+
+	trashCmd := &cobra.Command{
+		Use:     "trash",
+		Short:   "List deleted targets that can be restored",
+		Long:    trashHelpText,
+		Example: trashHelpEx,
+		Run:     targetTrashCmd,
+	}
+	trashCmd.Flags().BoolVar(&targetTrashEmpty, "empty", false,
+		"Permanently delete all targets in the trash")
+	targetCmd.AddCommand(trashCmd)
+
+	groupsHelpText := FormatHelp(`List the target groups and aliases
+		defined in project.yml (project.target_groups and
+		project.target_aliases), along with the targets they refer to.  A
+		group is specified as "@<group>" wherever a list of targets is
+		accepted; an alias can be used in place of a target name.`)
+	groupsHelpEx := "  newt target groups\n"
+	groupsHelpEx += "  newt build @release"
+
+	groupsCmd := &cobra.Command{
+		Use:     "groups",
+		Short:   "List target groups and aliases",
+		Long:    groupsHelpText,
+		Example: groupsHelpEx,
+		Run:     targetGroupsCmd,
+	}
+	targetCmd.AddCommand(groupsCmd)
+
 	copyHelpText := "Create a new target <dst-target> by cloning <src-target>"
 	copyHelpEx := "  newt target copy blinky_sim my_target"
 
@@ -984,6 +2133,180 @@ func AddTargetCommands(cmd *cobra.Command) {
 		return append(targetList(), unittestList()...)
 	})
 
+	apisHelpText := "List every API in the target specified by " +
+		"<target-name>, along with the package selected to supply it, all " +
+		"packages that can supply it, and all packages that require it.  " +
+		"Each supplier and consumer is shown with the syscfg expression " +
+		"that enables it, if any."
+	apisHelpEx := "  newt target apis my_target1\n"
+	apisHelpEx += "  newt target apis my_target1 --format dot | dot -Tsvg " +
+		"> apis.svg"
+
+	apisCmd := &cobra.Command{
+		Use:     "apis <target-name>",
+		Short:   "View the suppliers and consumers of a target's APIs",
+		Long:    apisHelpText,
+		Example: apisHelpEx,
+		Run:     targetApisCmd,
+	}
+	apisCmd.Flags().StringVar(&apisFormat, "format", "text",
+		"Output format: text, dot, or json")
+
+	targetCmd.AddCommand(apisCmd)
+	AddTabCompleteFn(apisCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
+
+	lintHelpText := "List the deprecated and experimental packages and " +
+		"settings used by the target specified by <target-name>.  A " +
+		"package is deprecated if its pkg.yml specifies " +
+		"`pkg.deprecated: 1`; the package's `pkg.replacement` value, if " +
+		"any, is suggested in its place."
+	lintHelpEx := "  newt target lint my_target1"
+
+	lintCmd := &cobra.Command{
+		Use:     "lint <target-name>",
+		Short:   "List a target's deprecated and experimental packages and settings",
+		Long:    lintHelpText,
+		Example: lintHelpEx,
+		Run:     targetLintCmd,
+	}
+
+	targetCmd.AddCommand(lintCmd)
+	AddTabCompleteFn(lintCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
+
+	halReportHelpText := "Report the HAL modules (hal_gpio, hal_spi, etc.) " +
+		"that the packages of the target specified by <target-name> use, " +
+		"and the packages that implement them.  A package implements a " +
+		"module if it compiles a hal_<module>.c source file; it uses a " +
+		"module if one of its source files includes hal/hal_<module>.h.  " +
+		"Modules that are used but not implemented are flagged " +
+		"UNIMPLEMENTED; modules that are implemented but not used are " +
+		"flagged UNUSED."
+	halReportHelpEx := "  newt target hal-report my_target1"
+
+	halReportCmd := &cobra.Command{
+		Use:     "hal-report <target-name>",
+		Short:   "Report the HAL modules a target uses and implements",
+		Long:    halReportHelpText,
+		Example: halReportHelpEx,
+		Run:     targetHalReportCmd,
+	}
+
+	targetCmd.AddCommand(halReportCmd)
+	AddTabCompleteFn(halReportCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
+
+	linkOrderHelpText := "Show the order in which the archives of the " +
+		"target specified by <target-name> are passed to the linker, and " +
+		"the global symbols that one package defines weakly and another " +
+		"package also defines.  For each such symbol, the report shows " +
+		"whether the linked ELF uses the weak or the strong definition; a " +
+		"strong definition is not linked unless something else pulls in " +
+		"its object file.  The target must have been built.  Packages " +
+		"can be moved to the start or end of the link order with " +
+		"target.link_first and target.link_last in target.yml."
+	linkOrderHelpEx := "  newt target linkorder my_target1"
+
+	linkOrderCmd := &cobra.Command{
+		Use:     "linkorder <target-name>",
+		Short:   "Show a target's link order and overridden weak symbols",
+		Long:    linkOrderHelpText,
+		Example: linkOrderHelpEx,
+		Run:     targetLinkOrderCmd,
+	}
+
+	targetCmd.AddCommand(linkOrderCmd)
+	AddTabCompleteFn(linkOrderCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
+
+	compileCommandsHelpText := "Write a clang compilation database " +
+		"(compile_commands.json) for the target specified by " +
+		"<target-name> to bin/targets/<target-name>/, without building " +
+		"the target.  The database lists the command that compiles each " +
+		"of the target's source files, and is used by clangd and other " +
+		"language servers.  `newt build` also writes it."
+	compileCommandsHelpEx := "  newt target compile-commands my_target1"
+
+	compileCommandsCmd := &cobra.Command{
+		Use:     "compile-commands <target-name>",
+		Short:   "Write a target's compile_commands.json",
+		Long:    compileCommandsHelpText,
+		Example: compileCommandsHelpEx,
+		Run:     targetCompileCommandsCmd,
+	}
+
+	targetCmd.AddCommand(compileCommandsCmd)
+	AddTabCompleteFn(compileCommandsCmd, func() []string {
+		return append(targetList(), unittestList()...)
+	})
+
+	romHelpText := "Build the target specified by <target-name> and " +
+		"export its libraries as a ROM that other targets can link " +
+		"against.  Newt writes a copy of the app ELF stripped of all but " +
+		"the exported symbols, and a list of the exported symbols, to " +
+		"bin/targets/<target-name>/rom/.  A target links against the " +
+		"ROM by setting target.rom_elf to the ROM ELF; packages that are " +
+		"part of the ROM are then omitted from its link, and any other " +
+		"package that defines a symbol exported by the ROM is reported " +
+		"as a collision."
+	romHelpEx := "  newt target rom my_rom_target\n"
+	romHelpEx += "  newt target set my_app_target " +
+		"rom_elf=bin/targets/my_rom_target/rom/my_rom.rom.elf"
+
+	romCmd := &cobra.Command{
+		Use:     "rom <target-name>",
+		Short:   "Build a ROM image and symbol list for other targets",
+		Long:    romHelpText,
+		Example: romHelpEx,
+		Run:     targetRomCmd,
+	}
+	targetCmd.AddCommand(romCmd)
+	AddTabCompleteFn(romCmd, targetList)
+
+	slotsHelpText := "Show the boot slot layout for the target specified " +
+		"by <target-name>, including the maximum image size of each slot, " +
+		"and verify that the most recently created image fits."
+	slotsHelpEx := "  newt target slots <target-name>\n"
+	slotsHelpEx += "  newt target slots my_target1"
+
+	slotsCmd := &cobra.Command{
+		Use:     "slots",
+		Short:   "Show boot slot layout and image size limits",
+		Long:    slotsHelpText,
+		Example: slotsHelpEx,
+		Run:     targetSlotsCmd,
+	}
+	targetCmd.AddCommand(slotsCmd)
+	AddTabCompleteFn(slotsCmd, targetList)
+
+	addBundleHelpText := "Add the bundle package <bundle-name> to the " +
+		"dependencies of the target specified by <target-name>.  A bundle " +
+		"aggregates a set of dependencies and syscfg settings under a " +
+		"single name."
+	addBundleHelpEx := "  newt target add-bundle <target-name> <bundle-name>\n"
+	addBundleHelpEx += "  newt target add-bundle my_target1 " +
+		"@apache-mynewt-core/bundles/ble-peripheral"
+
+	addBundleCmd := &cobra.Command{
+		Use:     "add-bundle",
+		Short:   "Add a bundle package to a target",
+		Long:    addBundleHelpText,
+		Example: addBundleHelpEx,
+		Run:     targetAddBundleCmd,
+	}
+	targetCmd.AddCommand(addBundleCmd)
+	AddTabCompleteFn(addBundleCmd, func() []string {
+		return append(targetList(),
+			pkgNameList(func(pack *pkg.LocalPackage) bool {
+				return pack.Type() == pkg.PACKAGE_TYPE_BUNDLE
+			})...)
+	})
+
 	infoHelpText := "Shows which packages contain app cflags in the target specified " +
 		"by <target-name>."
 	infoHelpEx := "  newt target info <target-name>\n"
@@ -0,0 +1,137 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package cli
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/toolchain"
+	"mynewt.apache.org/newt/util"
+)
+
+var toolchainsArch string
+
+func toolchainsRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		NewtUsage(cmd, util.NewNewtError("Too many arguments"))
+	}
+
+	TryGetProject()
+
+	infos, err := toolchain.CompilerPkgs()
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	for _, ci := range infos {
+		if toolchainsArch != "" && !ci.Supports(toolchainsArch) {
+			continue
+		}
+
+		archs := "(no compiler.archs)"
+		if len(ci.Archs) > 0 {
+			archs = strings.Join(ci.Archs, ", ")
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n    %s\n",
+			ci.Pkg.FullName(), archs)
+	}
+}
+
+func detectToolchainRunCmd(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		NewtUsage(cmd, util.NewNewtError("Must specify a BSP package name"))
+	}
+
+	proj := TryGetProject()
+
+	lpkg, err := proj.ResolvePackage(proj.LocalRepo(), args[0])
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+	if lpkg.Type() != pkg.PACKAGE_TYPE_BSP {
+		NewtUsage(nil, util.FmtNewtError(
+			"package \"%s\" is not a BSP", lpkg.FullName()))
+	}
+
+	bsp, err := pkg.NewBspPackage(lpkg, nil)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	compilerPkg, err := toolchain.BspCompilerPkg(bsp)
+	if err != nil {
+		NewtUsage(nil, err)
+	}
+
+	if bsp.CompilerName != "" {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"%s (specified by bsp.compiler)\n", compilerPkg.FullName())
+	} else {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"%s (detected for architecture \"%s\")\n",
+			compilerPkg.FullName(), bsp.CompilerArch)
+	}
+}
+
+func AddToolchainCommands(cmd *cobra.Command) {
+	toolchainsHelpText := FormatHelp(`List the compiler packages in the
+		project and the architectures each one builds for (compiler.archs
+		in compiler.yml).`)
+
+	toolchainsHelpEx := "  newt toolchains\n"
+	toolchainsHelpEx += "  newt toolchains --arch cortex_m4"
+
+	toolchainsCmd := &cobra.Command{
+		Use:     "toolchains",
+		Short:   "List compiler packages and their architectures",
+		Long:    toolchainsHelpText,
+		Example: toolchainsHelpEx,
+		Run:     toolchainsRunCmd,
+	}
+	toolchainsCmd.Flags().StringVar(&toolchainsArch, "arch", "",
+		"Only list compilers that build for this architecture")
+
+	cmd.AddCommand(toolchainsCmd)
+
+	detectHelpText := FormatHelp(`Display the compiler package used to
+		build for a BSP.  A BSP either names its compiler with bsp.compiler
+		or declares the architecture it requires with bsp.compiler_arch, in
+		which case newt selects the compiler package whose compiler.archs
+		includes that architecture.  If several compilers match, one in the
+		BSP's repo is preferred.`)
+
+	detectHelpEx := "  newt detect-toolchain hw/bsp/nordic_pca10040"
+
+	detectCmd := &cobra.Command{
+		Use:     "detect-toolchain <bsp-name>",
+		Short:   "Display the compiler package selected for a BSP",
+		Long:    detectHelpText,
+		Example: detectHelpEx,
+		Run:     detectToolchainRunCmd,
+	}
+
+	cmd.AddCommand(detectCmd)
+	AddTabCompleteFn(detectCmd, func() []string {
+		return completeVals("bsp")
+	})
+}
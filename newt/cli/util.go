@@ -24,7 +24,9 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path"
 	"regexp"
+	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -35,6 +37,7 @@ import (
 	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/pkg"
 	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/newt/repo"
 	"mynewt.apache.org/newt/newt/resolve"
 	"mynewt.apache.org/newt/newt/target"
 	"mynewt.apache.org/newt/util"
@@ -81,6 +84,22 @@ func FormatHelp(text string) string {
 	return fmtText
 }
 
+func lookupTarget(targetMap map[string]*target.Target,
+	name string) *target.Target {
+
+	// Check for fully-qualified name.
+	if t := targetMap[name]; t != nil {
+		return t
+	}
+
+	// Check the local "targets" directory.
+	if t := targetMap[TARGET_DEFAULT_DIR+"/"+name]; t != nil {
+		return t
+	}
+
+	return nil
+}
+
 func ResolveTarget(name string) *target.Target {
 	// Trim trailing slash from name.  This is necessary when tab
 	// completion is used to specify the name.
@@ -88,28 +107,138 @@ func ResolveTarget(name string) *target.Target {
 
 	targetMap := target.GetTargets()
 
-	// Check for fully-qualified name.
-	if t := targetMap[name]; t != nil {
+	if t := lookupTarget(targetMap, name); t != nil {
 		return t
 	}
 
-	// Check the local "targets" directory.
-	if t := targetMap[TARGET_DEFAULT_DIR+"/"+name]; t != nil {
-		return t
+	// Check the aliases defined in project.yml.
+	if proj := project.GetProject(); proj != nil {
+		if alias, ok := proj.TargetAliases()[name]; ok {
+			return lookupTarget(targetMap, alias)
+		}
 	}
 
 	return nil
 }
 
+// Replaces each "@<group>" in a list of target names with the members of the
+// group, as defined by `project.target_groups` in project.yml.  A group may
+// include other groups.  Names that do not refer to a group are returned
+// unchanged, as are repo-qualified names (e.g., "@myrepo/targets/foo").
+func ExpandTargetGroups(names []string) ([]string, error) {
+	proj := project.GetProject()
+	if proj == nil {
+		return names, nil
+	}
+
+	groups, err := proj.TargetGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	var expanded []string
+	var expand func(name string, stack []string) error
+	expand = func(name string, stack []string) error {
+		if !strings.HasPrefix(name, "@") || strings.Contains(name, "/") {
+			expanded = append(expanded, name)
+			return nil
+		}
+
+		group := strings.TrimPrefix(name, "@")
+		members, ok := groups[group]
+		if !ok {
+			return util.FmtNewtError("Unknown target group: %s", group)
+		}
+
+		for _, g := range stack {
+			if g == group {
+				return util.FmtNewtError("Target group cycle: %s",
+					strings.Join(append(stack, group), " -> "))
+			}
+		}
+
+		for _, m := range members {
+			if err := expand(m, append(stack, group)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := expand(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return expanded, nil
+}
+
+// Resolves a target name that may contain glob wildcards (`*`, `?`, `[...]`).
+// A pattern is matched against each target's full name and, for targets in the
+// local "targets" directory, against its short name.  The matching targets are
+// returned sorted by name.  A target group ("@<group>") resolves to the
+// group's members.
+func ResolveTargetPattern(pattern string) ([]*target.Target, error) {
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	if strings.HasPrefix(pattern, "@") && !strings.Contains(pattern, "/") {
+		return ResolveTargets(pattern)
+	}
+
+	if !strings.ContainsAny(pattern, "*?[") {
+		t := ResolveTarget(pattern)
+		if t == nil {
+			return nil, util.NewNewtError("Unknown target: " + pattern)
+		}
+		return []*target.Target{t}, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, util.FmtNewtError("Invalid target pattern: %s", pattern)
+	}
+
+	targetMap := target.GetTargets()
+	names := make([]string, 0, len(targetMap))
+	for name, _ := range targetMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	targets := []*target.Target{}
+	for _, name := range names {
+		short := strings.TrimPrefix(name, TARGET_DEFAULT_DIR+"/")
+		m1, _ := path.Match(pattern, name)
+		m2, _ := path.Match(pattern, short)
+		if m1 || m2 {
+			targets = append(targets, targetMap[name])
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, util.FmtNewtError("No targets match: %s", pattern)
+	}
+
+	return targets, nil
+}
+
 // Resolves a list of target names and checks for the optional "all" keyword
 // among them.  Regardless of whether "all" is specified, all target names must
-// be valid, or an error is reported.
+// be valid, or an error is reported.  Target groups ("@<group>") are expanded
+// to their members; a target specified more than once is only returned once.
 //
 // @return                      targets, all (t/f), err
 func ResolveTargetsOrAll(names ...string) ([]*target.Target, bool, error) {
 	targets := []*target.Target{}
 	all := false
 
+	names, err := ExpandTargetGroups(names)
+	if err != nil {
+		return nil, false, err
+	}
+
+	seen := map[*target.Target]bool{}
 	for _, name := range names {
 		if name == "all" {
 			all = true
@@ -120,7 +249,10 @@ func ResolveTargetsOrAll(names ...string) ([]*target.Target, bool, error) {
 					util.NewNewtError("Could not resolve target name: " + name)
 			}
 
-			targets = append(targets, t)
+			if !seen[t] {
+				seen[t] = true
+				targets = append(targets, t)
+			}
 		}
 	}
 
@@ -209,10 +341,16 @@ func TryGetProject() *project.Project {
 }
 
 func TryGetOrDownloadProject() *project.Project {
+	return TryGetOrDownloadProjectIf(nil)
+}
+
+// Like TryGetOrDownloadProject(), but only installs or upgrades the repos
+// matching the specified predicate.
+func TryGetOrDownloadProjectIf(pred func(r *repo.Repo) bool) *project.Project {
 	var p *project.Project
 	var err error
 
-	if p, err = project.TryGetOrDownloadProject(); err != nil {
+	if p, err = project.TryGetOrDownloadProjectIf(pred); err != nil {
 		NewtUsage(nil, err)
 	}
 
@@ -358,3 +496,26 @@ func PromptYesNo(dflt bool) bool {
 
 	return dflt
 }
+
+var promptReader = bufio.NewReader(os.Stdin)
+
+// Asks the user a question and returns the answer.  An empty answer, or the
+// end of standard input, selects the default.
+func PromptString(question string, dflt string) string {
+	if dflt != "" {
+		fmt.Printf("%s [%s]: ", question, dflt)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+
+	line, err := promptReader.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if line == "" {
+		if err != nil {
+			fmt.Printf("\n")
+		}
+		return dflt
+	}
+
+	return line
+}
@@ -20,6 +20,7 @@
 package cli
 
 import (
+	"encoding/json"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -27,6 +28,8 @@ import (
 	"mynewt.apache.org/newt/util"
 )
 
+var valsJSON bool
+
 func valsRunCmd(cmd *cobra.Command, args []string) {
 	if len(args) == 0 {
 		NewtUsage(cmd, nil)
@@ -42,6 +45,20 @@ func valsRunCmd(cmd *cobra.Command, args []string) {
 		allVals = append(allVals, vals)
 	}
 
+	if valsJSON {
+		m := map[string][]string{}
+		for i, vals := range allVals {
+			m[args[i]] = vals
+		}
+
+		js, err := json.MarshalIndent(m, "", "    ")
+		if err != nil {
+			NewtUsage(nil, util.ChildNewtError(err))
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", js)
+		return
+	}
+
 	for i, vals := range allVals {
 		if i != 0 {
 			util.StatusMessage(util.VERBOSITY_DEFAULT, "\n")
@@ -60,12 +77,22 @@ func AddValsCommands(cmd *cobra.Command) {
 	valsLongHelp := valsShortHelp + ".\n\nElement types:\n    " +
 		strings.Join(VarTypes(), "\n    ")
 
+	valsHelpEx := "  newt vals bsp app\n"
+	valsHelpEx += "  newt vals --json target unittest mfg\n"
+	valsHelpEx += "  newt vals build_profile --compiler compiler/sim"
+
 	valsCmd := &cobra.Command{
-		Use:   "vals <element-type> [element-types...]",
-		Short: valsShortHelp,
-		Long:  valsLongHelp,
-		Run:   valsRunCmd,
+		Use:     "vals <element-type> [element-types...]",
+		Short:   valsShortHelp,
+		Long:    valsLongHelp,
+		Example: valsHelpEx,
+		Run:     valsRunCmd,
 	}
+	valsCmd.Flags().BoolVar(&valsJSON, "json", false,
+		"Output a JSON object mapping each element type to its values")
+	valsCmd.Flags().StringVar(&valsCompiler, "compiler", "",
+		"Only list the build profiles that the specified compiler supports")
 
 	cmd.AddCommand(valsCmd)
+	AddTabCompleteFn(valsCmd, VarTypes)
 }
@@ -76,10 +76,29 @@ func settingValues(settingName string) ([]string, error) {
 	return values, nil
 }
 
+// If non-empty, only the build profiles supported by this compiler package
+// are listed.
+var valsCompiler string
+
 func buildProfileValues() ([]string, error) {
 	profileMap := map[string]struct{}{}
 
-	packs := project.GetProject().PackagesOfType(pkg.PACKAGE_TYPE_COMPILER)
+	var packs []interfaces.PackageInterface
+	if valsCompiler != "" {
+		pack, err := project.GetProject().ResolvePackage(
+			project.GetProject().LocalRepo(), valsCompiler)
+		if err != nil {
+			return nil, err
+		}
+		if pack.Type() != pkg.PACKAGE_TYPE_COMPILER {
+			return nil, util.FmtNewtError(
+				"package \"%s\" is not a compiler", pack.FullName())
+		}
+		packs = append(packs, pack)
+	} else {
+		packs = project.GetProject().PackagesOfType(pkg.PACKAGE_TYPE_COMPILER)
+	}
+
 	for _, pack := range packs {
 		v, err := config.ReadFile(
 			pack.BasePath() + "/" + toolchain.COMPILER_FILENAME)
@@ -116,18 +135,27 @@ var varsMap = map[string]func() ([]string, error){
 	"bsp": func() ([]string, error) {
 		return varsFromPackageType(pkg.PACKAGE_TYPE_BSP, true)
 	},
+	"bundle": func() ([]string, error) {
+		return varsFromPackageType(pkg.PACKAGE_TYPE_BUNDLE, true)
+	},
 	"compiler": func() ([]string, error) {
 		return varsFromPackageType(pkg.PACKAGE_TYPE_COMPILER, true)
 	},
 	"lib": func() ([]string, error) {
 		return varsFromPackageType(pkg.PACKAGE_TYPE_LIB, true)
 	},
+	"mfg": func() ([]string, error) {
+		return varsFromPackageType(pkg.PACKAGE_TYPE_MFG, true)
+	},
 	"sdk": func() ([]string, error) {
 		return varsFromPackageType(pkg.PACKAGE_TYPE_SDK, true)
 	},
 	"target": func() ([]string, error) {
 		return varsFromPackageType(pkg.PACKAGE_TYPE_TARGET, true)
 	},
+	"unittest": func() ([]string, error) {
+		return varsFromPackageType(pkg.PACKAGE_TYPE_UNITTEST, true)
+	},
 
 	// Package settings.
 	"api": func() ([]string, error) {
@@ -17,7 +17,9 @@
  * under the License.
  */
 
-// The config package handles reading of newt YAML files.
+// The config package handles reading of newt YAML files.  Config files may
+// alternatively be expressed in TOML or JSON; the format is detected by the
+// file's extension.
 package config
 
 import (
@@ -33,7 +35,6 @@ import (
 	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/ycfg"
 	"mynewt.apache.org/newt/util"
-	"mynewt.apache.org/newt/yaml"
 )
 
 const (
@@ -59,8 +60,8 @@ func readSettings(path string) (map[string]interface{}, error) {
 		return nil, util.ChildNewtError(err)
 	}
 
-	settings := map[string]interface{}{}
-	if err := yaml.Unmarshal(file, &settings); err != nil {
+	settings, err := decode(path, file)
+	if err != nil {
 		return nil, util.FmtNewtError("Failure parsing \"%s\": %s",
 			path, err.Error())
 	}
@@ -88,20 +89,27 @@ func readFileEntry(path string, parent *util.FileInfo) (FileEntry, error) {
 	}, nil
 }
 
-func extractImports(settings map[string]interface{}) ([]string, error) {
-	itf := settings[KEYWORD_IMPORT]
-	if itf == nil {
-		return nil, nil
-	}
+// extractImports returns the files listed by each of the specified import
+// keys, in order.
+func extractImports(settings map[string]interface{},
+	importKeys []string) ([]string, error) {
 
-	strs, err := cast.ToStringSliceE(itf)
-	if err != nil {
-		return nil, util.FmtNewtError(
-			"invalid %s section; must contain sequence of strings",
-			KEYWORD_IMPORT)
+	var imports []string
+	for _, key := range importKeys {
+		itf := settings[key]
+		if itf == nil {
+			continue
+		}
+
+		strs, err := cast.ToStringSliceE(itf)
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"invalid %s section; must contain sequence of strings", key)
+		}
+		imports = append(imports, strs...)
 	}
 
-	return strs, nil
+	return imports, nil
 }
 
 func (fe *FileEntry) warnUnrecognizedKeywords() {
@@ -140,7 +148,7 @@ func (fe *FileEntry) warnUnrecognizedKeywords() {
 // readLineage reads a configuration file and all files it imports (directly
 // or indirectly).  The resulting []FileEntry is sorted in the order the
 // corresponding files were read.
-func readLineage(path string) ([]FileEntry, error) {
+func readLineage(path string, importKeys []string) ([]FileEntry, error) {
 	entries := []FileEntry{}
 	seen := map[string]struct{}{}
 
@@ -174,7 +182,7 @@ func readLineage(path string) ([]FileEntry, error) {
 			return parent.ErrTree(err)
 		}
 
-		imports, err := extractImports(entry.Settings)
+		imports, err := extractImports(entry.Settings, importKeys)
 		if err != nil {
 			return err
 		}
@@ -217,9 +225,21 @@ func readLineage(path string) ([]FileEntry, error) {
 // ReadFile reads a YAML file, processes all its `$import` directives, and
 // returns a populated YCfg tree.
 func ReadFile(path string) (ycfg.YCfg, error) {
+	return ReadFileIncludes(path, "")
+}
+
+// ReadFileIncludes is like ReadFile, but the specified setting (e.g.,
+// "project.include") also lists files to import.  Settings in the including
+// file override those in the files it includes.
+func ReadFileIncludes(path string, includeKey string) (ycfg.YCfg, error) {
 	yc := ycfg.NewYCfg(path)
 
-	entries, err := readLineage(path)
+	importKeys := []string{KEYWORD_IMPORT}
+	if includeKey != "" {
+		importKeys = append(importKeys, includeKey)
+	}
+
+	entries, err := readLineage(path, importKeys)
 	if err != nil {
 		return yc, err
 	}
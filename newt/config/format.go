@@ -0,0 +1,176 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"mynewt.apache.org/newt/util"
+	"mynewt.apache.org/newt/yaml"
+)
+
+// A decoder parses the contents of a config file into a map of top-level
+// settings.
+type decoder func(data []byte) (map[string]interface{}, error)
+
+// Supported config file formats, keyed by file extension.  Files with an
+// unrecognized extension are parsed as YAML.
+var decoders = map[string]decoder{
+	".yml":  decodeYAML,
+	".yaml": decodeYAML,
+	".json": decodeJSON,
+	".toml": decodeTOML,
+}
+
+// Extensions that may be substituted for ".yml" in a config filename, in
+// order of preference.
+var altExts = []string{".toml", ".json"}
+
+func decodeYAML(data []byte) (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func decodeJSON(data []byte) (map[string]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	settings := map[string]interface{}{}
+	if err := dec.Decode(&settings); err != nil {
+		return nil, err
+	}
+
+	return normalizeMap(settings), nil
+}
+
+// Newt setting names contain periods (e.g., "pkg.name"), which TOML treats as
+// key separators.  Such names must be quoted:
+//
+//	"pkg.name" = "apps/blinky"
+//
+//	["syscfg.vals"]
+//	LOG_LEVEL = 0
+func decodeTOML(data []byte) (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	if err := toml.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+
+	return normalizeMap(settings), nil
+}
+
+func decode(path string, data []byte) (map[string]interface{}, error) {
+	dec := decoders[strings.ToLower(filepath.Ext(path))]
+	if dec == nil {
+		dec = decodeYAML
+	}
+
+	return dec(data)
+}
+
+// normalizeMap converts the values of a decoded JSON or TOML document to the
+// types the YAML decoder produces, so that ycfg treats all formats alike:
+// nested maps become map[interface{}]interface{} and integers become int.
+func normalizeMap(m map[string]interface{}) map[string]interface{} {
+	for k, v := range m {
+		m[k] = normalizeValue(v)
+	}
+
+	return m
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		im := make(map[interface{}]interface{}, len(t))
+		for k, sub := range t {
+			im[k] = normalizeValue(sub)
+		}
+		return im
+
+	case []interface{}:
+		for i, sub := range t {
+			t[i] = normalizeValue(sub)
+		}
+		return t
+
+	case []map[string]interface{}:
+		// A TOML array of tables.
+		l := make([]interface{}, len(t))
+		for i, sub := range t {
+			l[i] = normalizeValue(sub)
+		}
+		return l
+
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return int(i)
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+		return t.String()
+
+	case int64:
+		return int(t)
+
+	default:
+		return v
+	}
+}
+
+// IsYAMLFile indicates whether the specified config file is expressed in
+// YAML.  Newt only writes config files in YAML.
+func IsYAMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".toml":
+		return false
+	default:
+		return true
+	}
+}
+
+// FindFile returns the path of the config file that newt reads in place of
+// the specified ".yml" file.  If the YAML file does not exist, but a TOML or
+// JSON file with the same base name does, the path of the alternative file
+// is returned.  Otherwise, the specified path is returned unchanged.
+func FindFile(path string) string {
+	if filepath.Ext(path) != ".yml" || util.NodeExist(path) {
+		return path
+	}
+
+	base := strings.TrimSuffix(path, ".yml")
+	for _, ext := range altExts {
+		if util.NodeExist(base + ext) {
+			return base + ext
+		}
+	}
+
+	return path
+}
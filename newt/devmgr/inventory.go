@@ -0,0 +1,192 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package devmgr
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cast"
+
+	"mynewt.apache.org/newt/newt/config"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/util"
+)
+
+// The project-level device inventory.  It maps device names to connection
+// details so that commands can refer to a device by name:
+//
+//	devices:
+//	    devkit1:
+//	        serial_port: /dev/ttyACM0
+//	        baud: 115200
+//	        probe_serial: 000683012345
+//	    sensor2:
+//	        ble_addr: "c0:11:22:33:44:55"
+//	    lab3:
+//	        newtmgr_conn: lab3
+const DEVICES_FILENAME = "devices.yml"
+
+const DEVICE_DFLT_BAUD = 115200
+
+// A named device connection.
+type Device struct {
+	Name string
+
+	// newtmgr connection profile (`newtmgr conn add`).  If specified, it takes
+	// precedence over the serial port and BLE address for SMP commands.
+	NewtmgrConn string
+
+	SerialPort  string
+	Baud        int
+	BleAddr     string
+	ProbeSerial string
+}
+
+func DevicesPath() string {
+	return project.GetProject().Path() + "/" + DEVICES_FILENAME
+}
+
+func readDevice(name string, itf interface{}) (*Device, error) {
+	fields, err := cast.ToStringMapStringE(itf)
+	if err != nil {
+		return nil, util.FmtNewtError("device \"%s\" in %s is not a map",
+			name, DEVICES_FILENAME)
+	}
+
+	dev := &Device{
+		Name:        name,
+		NewtmgrConn: fields["newtmgr_conn"],
+		SerialPort:  fields["serial_port"],
+		BleAddr:     fields["ble_addr"],
+		ProbeSerial: fields["probe_serial"],
+		Baud:        DEVICE_DFLT_BAUD,
+	}
+
+	if s := fields["baud"]; s != "" {
+		dev.Baud, err = strconv.Atoi(s)
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"device \"%s\" in %s has invalid baud rate: %s",
+				name, DEVICES_FILENAME, s)
+		}
+	}
+
+	return dev, nil
+}
+
+// ReadDevices reads the project's device inventory.  An empty map is returned
+// if the project does not have a devices.yml file.
+func ReadDevices() (map[string]*Device, error) {
+	devs := map[string]*Device{}
+
+	path := DevicesPath()
+	if util.NodeNotExist(path) {
+		return devs, nil
+	}
+
+	yc, err := config.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := yc.GetValStringMap("devices", nil)
+	util.OneTimeWarningError(err)
+
+	for name, itf := range m {
+		dev, err := readDevice(name, itf)
+		if err != nil {
+			return nil, err
+		}
+		devs[name] = dev
+	}
+
+	return devs, nil
+}
+
+// FindDevice looks up a device in the project's inventory.  nil is returned
+// if there is no device with the specified name.
+func FindDevice(name string) (*Device, error) {
+	devs, err := ReadDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	return devs[name], nil
+}
+
+// DeviceNames returns the sorted names of the devices in the project's
+// inventory.
+func DeviceNames() []string {
+	devs, err := ReadDevices()
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(devs))
+	for name, _ := range devs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// EnvVars returns the environment variables that describe the device to the
+// BSP's download and debug scripts.  Only the specified fields are included.
+func (d *Device) EnvVars() map[string]string {
+	env := map[string]string{
+		"DEVICE_NAME": d.Name,
+	}
+
+	if d.SerialPort != "" {
+		env["DEVICE_SERIAL_PORT"] = d.SerialPort
+		env["DEVICE_BAUD"] = strconv.Itoa(d.Baud)
+	}
+	if d.BleAddr != "" {
+		env["DEVICE_BLE_ADDR"] = d.BleAddr
+	}
+	if d.ProbeSerial != "" {
+		env["DEVICE_PROBE_SERIAL"] = d.ProbeSerial
+	}
+
+	return env
+}
+
+// Newtmgr creates a newtmgr connection to the device.
+func (d *Device) Newtmgr(path string) (*Newtmgr, error) {
+	nm := NewNewtmgr(path, d.NewtmgrConn)
+
+	switch {
+	case d.NewtmgrConn != "":
+	case d.SerialPort != "":
+		nm.ConnType = "serial"
+		nm.ConnString = "dev=" + d.SerialPort + ",baud=" + strconv.Itoa(d.Baud)
+	case d.BleAddr != "":
+		nm.ConnType = "ble"
+		nm.ConnString = "peer_id=" + d.BleAddr
+	default:
+		return nil, util.FmtNewtError(
+			"device \"%s\" does not specify a newtmgr_conn, serial_port, "+
+				"or ble_addr", d.Name)
+	}
+
+	return nm, nil
+}
@@ -0,0 +1,223 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package devmgr manages devices over SMP (the Simple Management Protocol) by
+// driving the newtmgr command line tool.  newtmgr handles the transports
+// (serial, BLE, UDP) and the connection profiles; newt only needs to know the
+// name of the profile.
+package devmgr
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+const NEWTMGR_DFLT_PATH = "newtmgr"
+
+// The state of one image slot, as reported by the device.
+type ImageSlot struct {
+	Image     int
+	Slot      int
+	Version   string
+	Hash      string
+	Bootable  bool
+	Pending   bool
+	Confirmed bool
+	Active    bool
+	Permanent bool
+}
+
+// A connection to a device through newtmgr.
+type Newtmgr struct {
+	// Path of the newtmgr executable.
+	Path string
+
+	// Name of the newtmgr connection profile (`newtmgr conn add ...`).
+	Conn string
+
+	// Used instead of a connection profile if Conn is empty.
+	ConnType   string
+	ConnString string
+}
+
+var imgListSlotRe = regexp.MustCompile(`^\s*image=(\d+)\s+slot=(\d+)\s*$`)
+var imgListFieldRe = regexp.MustCompile(`^\s+(\w+):\s*(.*)$`)
+
+func NewNewtmgr(path string, conn string) *Newtmgr {
+	if path == "" {
+		path = NEWTMGR_DFLT_PATH
+	}
+
+	return &Newtmgr{
+		Path: path,
+		Conn: conn,
+	}
+}
+
+func (nm *Newtmgr) run(args ...string) (string, error) {
+	cmd := []string{nm.Path}
+	if nm.Conn != "" {
+		cmd = append(cmd, "-c", nm.Conn)
+	} else {
+		cmd = append(cmd, "--conntype", nm.ConnType,
+			"--connstring", nm.ConnString)
+	}
+	cmd = append(cmd, args...)
+
+	o, err := util.ShellCommand(cmd, nil)
+	if err != nil {
+		return "", util.FmtNewtError("newtmgr %s failed: %s",
+			strings.Join(args, " "), err.Error())
+	}
+
+	return string(o), nil
+}
+
+// Parses the output of `newtmgr image list`:
+//
+//	Images:
+//	 image=0 slot=0
+//	    version: 1.0.0
+//	    bootable: true
+//	    flags: active confirmed
+//	    hash: 6d3c...
+//	Split status: N/A (0)
+func parseImageList(out string) ([]ImageSlot, error) {
+	var slots []ImageSlot
+	var cur *ImageSlot
+
+	for _, line := range strings.Split(out, "\n") {
+		if m := imgListSlotRe.FindStringSubmatch(line); m != nil {
+			image, _ := strconv.Atoi(m[1])
+			slot, _ := strconv.Atoi(m[2])
+			slots = append(slots, ImageSlot{Image: image, Slot: slot})
+			cur = &slots[len(slots)-1]
+			continue
+		}
+
+		m := imgListFieldRe.FindStringSubmatch(line)
+		if m == nil || cur == nil {
+			continue
+		}
+
+		val := strings.TrimSpace(m[2])
+		switch m[1] {
+		case "version":
+			cur.Version = val
+		case "hash":
+			cur.Hash = strings.ToLower(val)
+		case "bootable":
+			cur.Bootable = val == "true"
+		case "flags":
+			for _, flag := range strings.Fields(val) {
+				switch flag {
+				case "pending":
+					cur.Pending = true
+				case "confirmed":
+					cur.Confirmed = true
+				case "active":
+					cur.Active = true
+				case "permanent":
+					cur.Permanent = true
+				}
+			}
+		}
+	}
+
+	if len(slots) == 0 {
+		return nil, util.FmtNewtError(
+			"failed to parse newtmgr image list output:\n%s", out)
+	}
+
+	return slots, nil
+}
+
+// ImageList retrieves the state of the device's image slots.
+func (nm *Newtmgr) ImageList() ([]ImageSlot, error) {
+	out, err := nm.run("image", "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseImageList(out)
+}
+
+// ImageUpload uploads an image file to the device's secondary slot.
+func (nm *Newtmgr) ImageUpload(path string) error {
+	_, err := nm.run("image", "upload", "-e", path)
+	return err
+}
+
+// ImageTest marks the image with the specified hash for a test boot.
+func (nm *Newtmgr) ImageTest(hash string) error {
+	_, err := nm.run("image", "test", hash)
+	return err
+}
+
+// ImageConfirm makes the running image permanent.
+func (nm *Newtmgr) ImageConfirm() error {
+	_, err := nm.run("image", "confirm")
+	return err
+}
+
+// Reset reboots the device.
+func (nm *Newtmgr) Reset() error {
+	_, err := nm.run("reset")
+	return err
+}
+
+// RunTest starts the specified on-device test suite, or all suites if `name`
+// is "all".  The suites run asynchronously; their results are written to the
+// device's test log.
+func (nm *Newtmgr) RunTest(name string) error {
+	_, err := nm.run("run", "test", name)
+	return err
+}
+
+// LogClear erases the entries of all the device's logs.
+func (nm *Newtmgr) LogClear() error {
+	_, err := nm.run("log", "clear")
+	return err
+}
+
+// LogShow retrieves the entries of the specified device log.
+func (nm *Newtmgr) LogShow(name string) (string, error) {
+	return nm.run("log", "show", name)
+}
+
+func (s *ImageSlot) FlagsString() string {
+	var flags []string
+	if s.Active {
+		flags = append(flags, "active")
+	}
+	if s.Confirmed {
+		flags = append(flags, "confirmed")
+	}
+	if s.Pending {
+		flags = append(flags, "pending")
+	}
+	if s.Permanent {
+		flags = append(flags, "permanent")
+	}
+
+	return strings.Join(flags, " ")
+}
@@ -0,0 +1,187 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package devmgr
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// How often the device is polled while waiting for it to reboot.
+const upgradePollInterval = time.Second
+
+type UpgradeOpts struct {
+	// Image file to upload.
+	ImgPath string
+
+	// Hash and version of the image, as recorded in the build manifest.
+	Hash    string
+	Version string
+
+	// Whether to reset the device after marking the image for test.
+	Reset bool
+
+	// Whether to confirm the image once the device runs it.  Only applies if
+	// the device is reset.
+	Confirm bool
+
+	// How long to wait for the device to come back after a reset.
+	Timeout time.Duration
+}
+
+type UpgradeResult struct {
+	// Whether the image was uploaded; false if the device already had it.
+	Uploaded bool
+
+	// Whether the device is running the new image.
+	Running bool
+
+	// The device's slot states after the upgrade.
+	Slots []ImageSlot
+}
+
+func findSlotByHash(slots []ImageSlot, hash string) *ImageSlot {
+	for i, _ := range slots {
+		if slots[i].Hash == hash {
+			return &slots[i]
+		}
+	}
+
+	return nil
+}
+
+func findActiveSlot(slots []ImageSlot) *ImageSlot {
+	for i, _ := range slots {
+		if slots[i].Active {
+			return &slots[i]
+		}
+	}
+
+	return nil
+}
+
+// Polls the device until it is running the image with the specified hash.  An
+// error is returned if the device boots a different image (i.e., the test boot
+// failed and the bootloader reverted) or does not respond in time.
+func waitForImage(nm *Newtmgr, hash string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		time.Sleep(upgradePollInterval)
+
+		slots, err := nm.ImageList()
+		if err == nil {
+			active := findActiveSlot(slots)
+			if active != nil && active.Hash == hash {
+				return nil
+			}
+			if active != nil {
+				return util.FmtNewtError(
+					"device booted image %s instead of the new image; the "+
+						"bootloader may have reverted the upgrade",
+					active.Version)
+			}
+		} else {
+			log.Debugf("device not ready: %s", err.Error())
+		}
+
+		if time.Now().After(deadline) {
+			return util.FmtNewtError(
+				"timed out waiting for the device to boot the new image")
+		}
+	}
+}
+
+// Upgrade brings a device up to date with a built image.  The image is only
+// uploaded if the device does not already have an image with the same hash.
+// The image is then marked for test and, if requested, the device is reset
+// and the image confirmed once the device runs it.
+func Upgrade(nm *Newtmgr, opts UpgradeOpts) (UpgradeResult, error) {
+	res := UpgradeResult{}
+	hash := strings.ToLower(opts.Hash)
+
+	slots, err := nm.ImageList()
+	if err != nil {
+		return res, err
+	}
+
+	cur := findSlotByHash(slots, hash)
+	if cur != nil && cur.Active {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Device is already running image %s\n", opts.Version)
+
+		res.Running = true
+		if opts.Confirm && !cur.Confirmed {
+			if err := nm.ImageConfirm(); err != nil {
+				return res, err
+			}
+		}
+	} else {
+		if cur == nil {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"Uploading image %s (%s)\n", opts.Version, opts.ImgPath)
+			if err := nm.ImageUpload(opts.ImgPath); err != nil {
+				return res, err
+			}
+			res.Uploaded = true
+		} else {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"Device already has image %s in slot %d; not uploading\n",
+				opts.Version, cur.Slot)
+		}
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Marking image %s for test\n", opts.Version)
+		if err := nm.ImageTest(hash); err != nil {
+			return res, err
+		}
+
+		if opts.Reset {
+			util.StatusMessage(util.VERBOSITY_DEFAULT, "Resetting device\n")
+			if err := nm.Reset(); err != nil {
+				return res, err
+			}
+
+			if err := waitForImage(nm, hash, opts.Timeout); err != nil {
+				return res, err
+			}
+			res.Running = true
+
+			if opts.Confirm {
+				util.StatusMessage(util.VERBOSITY_DEFAULT,
+					"Confirming image %s\n", opts.Version)
+				if err := nm.ImageConfirm(); err != nil {
+					return res, err
+				}
+			}
+		}
+	}
+
+	res.Slots, err = nm.ImageList()
+	if err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
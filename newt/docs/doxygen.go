@@ -0,0 +1,141 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/util"
+)
+
+const DOXYFILE_NAME = "Doxyfile"
+
+// Quotes a Doxyfile value if it contains whitespace or quotes.
+func doxyQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\"") {
+		return s
+	}
+
+	return "\"" + strings.Replace(s, "\"", "\\\"", -1) + "\""
+}
+
+// Writes a multi-valued Doxyfile tag, one value per line.
+func writeDoxyList(buf *bytes.Buffer, tag string, vals []string) {
+	fmt.Fprintf(buf, "%-22s =", tag)
+	for _, v := range vals {
+		fmt.Fprintf(buf, " \\\n    %s", doxyQuote(v))
+	}
+	fmt.Fprintf(buf, "\n")
+}
+
+// Removes duplicates from a slice while preserving order.
+func uniqStrings(ss []string) []string {
+	seen := make(map[string]struct{}, len(ss))
+	out := make([]string, 0, len(ss))
+
+	for _, s := range ss {
+		if _, ok := seen[s]; !ok {
+			seen[s] = struct{}{}
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// Generates the contents of a Doxyfile that documents the sources of the
+// specified packages.  Include paths and predefined macros are taken from
+// all inputs, so that conditional declarations are resolved the same way
+// as in the target build.
+func GenDoxyfile(name string, docPkgs []builder.DocInput,
+	allPkgs []builder.DocInput, outdir string) []byte {
+
+	var inputDirs []string
+	for _, di := range docPkgs {
+		inputDirs = append(inputDirs, di.Lpkg.BasePath())
+	}
+
+	var includes []string
+	var defines []string
+	for _, di := range allPkgs {
+		includes = append(includes, di.Includes...)
+		defines = append(defines, di.Defines...)
+	}
+
+	buf := bytes.Buffer{}
+
+	fmt.Fprintf(&buf, "# Generated by newt; do not edit.\n\n")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "PROJECT_NAME", doxyQuote(name))
+	fmt.Fprintf(&buf, "%-22s = %s\n", "OUTPUT_DIRECTORY", doxyQuote(outdir))
+	writeDoxyList(&buf, "INPUT", uniqStrings(inputDirs))
+	fmt.Fprintf(&buf, "%-22s = %s\n", "RECURSIVE", "YES")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "FILE_PATTERNS", "*.h *.c *.cpp *.hpp")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "EXCLUDE_PATTERNS", "*/test/*")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "ENABLE_PREPROCESSING", "YES")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "MACRO_EXPANSION", "YES")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "SEARCH_INCLUDES", "YES")
+	writeDoxyList(&buf, "INCLUDE_PATH", uniqStrings(includes))
+	writeDoxyList(&buf, "PREDEFINED", uniqStrings(defines))
+	fmt.Fprintf(&buf, "%-22s = %s\n", "EXTRACT_ALL", "YES")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "GENERATE_HTML", "YES")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "GENERATE_XML", "YES")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "GENERATE_LATEX", "NO")
+	fmt.Fprintf(&buf, "%-22s = %s\n", "QUIET", "YES")
+
+	return buf.Bytes()
+}
+
+// Writes a Doxyfile for the specified packages to the output directory and
+// runs doxygen on it.
+func RunDoxygen(name string, docPkgs []builder.DocInput,
+	allPkgs []builder.DocInput, outdir string) error {
+
+	outdir, err := filepath.Abs(outdir)
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	if err := os.MkdirAll(outdir, 0755); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	doxyfile := outdir + "/" + DOXYFILE_NAME
+	contents := GenDoxyfile(name, docPkgs, allPkgs, outdir)
+	if err := ioutil.WriteFile(doxyfile, contents, 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Running doxygen for %s; output in %s\n", name, outdir)
+
+	if _, err := util.ShellCommand([]string{"doxygen", doxyfile},
+		nil); err != nil {
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/syscfg"
+	"mynewt.apache.org/newt/util"
+)
+
+const (
+	SYSCFG_DOC_FORMAT_MD  = "md"
+	SYSCFG_DOC_FORMAT_RST = "rst"
+)
+
+var SyscfgDocFormats = []string{
+	SYSCFG_DOC_FORMAT_MD,
+	SYSCFG_DOC_FORMAT_RST,
+}
+
+// Produces a one-line, human readable description of a setting restriction.
+func restrictionText(entry syscfg.CfgEntry, r syscfg.CfgRestriction) string {
+	switch r.Code {
+	case syscfg.CFG_RESTRICTION_CODE_NOTNULL:
+		return "must not be null"
+	case syscfg.CFG_RESTRICTION_CODE_CHOICE:
+		return "one of: " + strings.Join(entry.ValidChoices, ", ")
+	case syscfg.CFG_RESTRICTION_CODE_RANGE:
+		return "range: " + r.Expr
+	case syscfg.CFG_RESTRICTION_CODE_REQUIRES:
+		return "requires package " + r.Expr
+	default:
+		return "requires: " + r.Expr
+	}
+}
+
+// Returns the values of the table columns for a single setting.
+func settingColumns(entry syscfg.CfgEntry) []string {
+	var restrictions []string
+	for _, r := range entry.Restrictions {
+		restrictions = append(restrictions, restrictionText(entry, r))
+	}
+
+	typ := entry.SettingType.String()
+	if entry.State != syscfg.CFG_SETTING_STATE_GOOD {
+		typ += " (" + entry.State.String() + ")"
+	}
+
+	return []string{
+		entry.Name,
+		typ,
+		entry.Value,
+		entry.Description,
+		strings.Join(restrictions, "; "),
+	}
+}
+
+var settingHeadings = []string{
+	"Setting", "Type", "Default", "Description", "Restrictions",
+}
+
+// Collapses whitespace so that a value fits in a single table cell.
+func cellText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func writePkgMarkdown(lpkg *pkg.LocalPackage, entries []syscfg.CfgEntry,
+	w io.Writer) {
+
+	fmt.Fprintf(w, "## %s\n\n", lpkg.FullName())
+	fmt.Fprintf(w, "| %s |\n", strings.Join(settingHeadings, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat("---|", len(settingHeadings)))
+
+	for _, entry := range entries {
+		cols := settingColumns(entry)
+		for i, c := range cols {
+			c = strings.Replace(cellText(c), "|", "\\|", -1)
+			if i < 3 && c != "" {
+				c = "`" + c + "`"
+			}
+			cols[i] = c
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(cols, " | "))
+	}
+}
+
+func writePkgRst(lpkg *pkg.LocalPackage, entries []syscfg.CfgEntry,
+	w io.Writer) {
+
+	title := lpkg.FullName()
+	fmt.Fprintf(w, "%s\n%s\n\n", title, strings.Repeat("-", len(title)))
+	fmt.Fprintf(w, ".. list-table::\n")
+	fmt.Fprintf(w, "   :header-rows: 1\n\n")
+
+	writeRow := func(cols []string) {
+		for i, c := range cols {
+			prefix := "     "
+			if i == 0 {
+				prefix = "   * "
+			}
+			fmt.Fprintf(w, "%s- %s\n", prefix, c)
+		}
+	}
+
+	writeRow(settingHeadings)
+	for _, entry := range entries {
+		cols := settingColumns(entry)
+		for i, c := range cols {
+			c = cellText(c)
+			if i < 3 && c != "" {
+				c = "``" + c + "``"
+			}
+			cols[i] = c
+		}
+		writeRow(cols)
+	}
+}
+
+// Renders the syscfg definitions of the specified packages as a Markdown or
+// reStructuredText document.  Packages that do not define any settings are
+// omitted.
+func SyscfgDoc(lpkgs []*pkg.LocalPackage, format string) (string, error) {
+	var writePkg func(*pkg.LocalPackage, []syscfg.CfgEntry, io.Writer)
+
+	buf := bytes.Buffer{}
+
+	switch format {
+	case SYSCFG_DOC_FORMAT_MD:
+		writePkg = writePkgMarkdown
+		fmt.Fprintf(&buf, "# System Configuration Settings\n")
+	case SYSCFG_DOC_FORMAT_RST:
+		writePkg = writePkgRst
+		title := "System Configuration Settings"
+		fmt.Fprintf(&buf, "%s\n%s\n", title, strings.Repeat("=", len(title)))
+	default:
+		return "", util.FmtNewtError(
+			"invalid syscfg doc format \"%s\"; must be one of: %s",
+			format, strings.Join(SyscfgDocFormats, ", "))
+	}
+
+	for _, lpkg := range lpkgs {
+		entries, err := syscfg.ReadPkgDefs(lpkg)
+		if err != nil {
+			return "", err
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&buf, "\n")
+		writePkg(lpkg, entries, &buf)
+	}
+
+	return buf.String(), nil
+}
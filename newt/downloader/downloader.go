@@ -30,12 +30,12 @@ import (
 	"strconv"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
-
 	"mynewt.apache.org/newt/newt/settings"
 	"mynewt.apache.org/newt/util"
 )
 
+var log = util.SubsysLogger(util.LOG_SUBSYS_DOWNLOADER)
+
 type DownloaderCommitType int
 
 const (
@@ -94,6 +94,11 @@ type Downloader interface {
 	// Retrieves full SHA for given commit
 	CommitSha(path string, commit string) (string, error)
 
+	// Lists the commits that are reachable from `to` but not from `from`,
+	// newest first.  Only the first parent of each merge commit is followed,
+	// so a merged pull request appears as a single commit.
+	Log(path string, from string, to string) ([]LogCommit, error)
+
 	// LatestRc finds the commit of the latest release candidate.  It looks
 	// for commits with names matching the base commit string, but with with
 	// "_rc#" inserted.  This is useful when a release candidate is being
@@ -116,6 +121,21 @@ type Downloader interface {
 
 	// Returns if repository was already fetched
 	IsFetched() bool
+
+	// Restricts the checked out files to the specified directories using git
+	// sparse-checkout.  An empty list checks out the full repo.
+	SetSparsePaths(paths []string)
+}
+
+// A commit listed by Downloader.Log.
+type LogCommit struct {
+	Hash    string
+	Subject string
+	Body    string
+
+	// The files that the commit changed, relative to the repo root.  For a
+	// merge commit, these are the changes relative to its first parent.
+	Files []string
 }
 
 type Commit struct {
@@ -133,10 +153,17 @@ type GenericDownloader struct {
 
 	// Whether 'origin' has been fetched during this run.
 	fetched bool
+
+	// Directories to materialize with sparse-checkout; empty means all.
+	sparsePaths []string
 }
 
+// Downloads repos from a hosted git service ("forge"): GitHub, GitLab, or
+// Bitbucket.  The forge determines the default server and the form of token
+// authentication.
 type GithubDownloader struct {
 	GenericDownloader
+	Forge  string
 	Server string
 	User   string
 	Repo   string
@@ -151,6 +178,34 @@ type GithubDownloader struct {
 	// Name of environment variable containing the password for private repos.
 	// Only used if the Password field is empty.
 	PasswordEnv string
+
+	// API / access token for private repos.  Only used if the Login field is
+	// empty.
+	Token string
+
+	// Name of environment variable containing the access token.  Only used if
+	// the Token field is empty.
+	TokenEnv string
+}
+
+const (
+	FORGE_GITHUB    = "github"
+	FORGE_GITLAB    = "gitlab"
+	FORGE_BITBUCKET = "bitbucket"
+)
+
+// [forge] => default server
+var forgeServers = map[string]string{
+	FORGE_GITHUB:    "github.com",
+	FORGE_GITLAB:    "gitlab.com",
+	FORGE_BITBUCKET: "bitbucket.org",
+}
+
+// [forge] => user name that accompanies an access token in a URL
+var forgeTokenUsers = map[string]string{
+	FORGE_GITHUB:    "x-access-token",
+	FORGE_GITLAB:    "oauth2",
+	FORGE_BITBUCKET: "x-token-auth",
 }
 
 type GitDownloader struct {
@@ -176,24 +231,20 @@ func gitPath() (string, error) {
 	return filepath.ToSlash(gitPath), nil
 }
 
+// Executes a git command in the specified directory.  The directory is
+// passed to git with `-C` rather than by changing newt's working directory,
+// so that commands for several repos can run concurrently.
 func executeGitCommand(dir string, cmd []string, logCmd bool) ([]byte, error) {
-	wd, err := os.Getwd()
-	if err != nil {
-		return nil, util.NewNewtError(err.Error())
-	}
-
 	gp, err := gitPath()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := os.Chdir(dir); err != nil {
-		return nil, util.ChildNewtError(err)
+	if util.NodeNotExist(dir) {
+		return nil, util.FmtNewtError("directory does not exist: %s", dir)
 	}
 
-	defer os.Chdir(wd)
-
-	gitCmd := []string{gp}
+	gitCmd := []string{gp, "-C", dir}
 	gitCmd = append(gitCmd, cmd...)
 	output, err := util.ShellCommandLimitDbgOutput(gitCmd, nil, logCmd, -1)
 	if err != nil {
@@ -448,6 +499,42 @@ func untrackedFilesFromCheckoutErr(err error) []string {
 	return files
 }
 
+func (gd *GenericDownloader) SetSparsePaths(paths []string) {
+	gd.sparsePaths = paths
+}
+
+// Configures sparse-checkout in the specified repo so that only the
+// directories in `sparsePaths` (and files at the top level) are checked out.
+// No-op if no paths are configured.
+func (gd *GenericDownloader) applySparsePaths(repoDir string) error {
+	if len(gd.sparsePaths) == 0 {
+		return nil
+	}
+
+	cmd := []string{"sparse-checkout", "init", "--cone"}
+	if _, err := executeGitCommand(repoDir, cmd, true); err != nil {
+		return err
+	}
+
+	cmd = append([]string{"sparse-checkout", "set"}, gd.sparsePaths...)
+	if _, err := executeGitCommand(repoDir, cmd, true); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Returns the extra `git clone` arguments needed for a sparse checkout.
+func (gd *GenericDownloader) sparseCloneArgs() []string {
+	if len(gd.sparsePaths) == 0 {
+		return nil
+	}
+
+	// Don't download the contents of files outside the sparse paths, and
+	// don't populate the working tree until the sparse paths are configured.
+	return []string{"--filter=blob:none", "--no-checkout"}
+}
+
 func (gd *GenericDownloader) Checkout(repoDir string, commit string) error {
 	// Get the hash corresponding to the commit in case the caller specified a
 	// branch or tag.  We always want to check out a hash and end up in a
@@ -457,6 +544,12 @@ func (gd *GenericDownloader) Checkout(repoDir string, commit string) error {
 		return err
 	}
 
+	// Apply the sparse paths on every checkout in case they were changed
+	// since the repo was cloned.
+	if err := gd.applySparsePaths(repoDir); err != nil {
+		return err
+	}
+
 	util.StatusMessage(util.VERBOSITY_VERBOSE, "Will checkout %s\n", hash)
 	cmd := []string{
 		"checkout",
@@ -697,6 +790,45 @@ func (gd *GenericDownloader) CommitSha(path string, commit string) (string, erro
 	return strings.TrimSpace(string(o)), nil
 }
 
+func (gd *GenericDownloader) Log(path string, from string,
+	to string) ([]LogCommit, error) {
+
+	cmd := []string{
+		"log",
+		"--first-parent",
+		"-m",
+		"--name-only",
+		"--format=%x1e%H%x1f%s%x1f%b%x1f",
+		from + ".." + to,
+	}
+	o, err := executeGitCommand(path, cmd, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []LogCommit
+	for _, rec := range strings.Split(string(o), "\x1e") {
+		fields := strings.SplitN(rec, "\x1f", 4)
+		if len(fields) != 4 {
+			continue
+		}
+
+		c := LogCommit{
+			Hash:    strings.TrimSpace(fields[0]),
+			Subject: strings.TrimSpace(fields[1]),
+			Body:    strings.TrimSpace(fields[2]),
+		}
+		for _, f := range strings.Split(fields[3], "\n") {
+			if f = strings.TrimSpace(f); f != "" {
+				c.Files = append(c.Files, f)
+			}
+		}
+		commits = append(commits, c)
+	}
+
+	return commits, nil
+}
+
 // Fetches the downloader's origin remote if it hasn't been fetched yet during
 // this run.
 func (gd *GenericDownloader) cachedFetch(fn func() error) error {
@@ -853,6 +985,23 @@ func (gd *GithubDownloader) password() string {
 	}
 }
 
+func (gd *GithubDownloader) token() string {
+	if gd.Token != "" {
+		return gd.Token
+	} else if gd.TokenEnv != "" {
+		return os.Getenv(gd.TokenEnv)
+	} else {
+		return ""
+	}
+}
+
+func (gd *GithubDownloader) forge() string {
+	if gd.Forge == "" {
+		return FORGE_GITHUB
+	}
+	return gd.Forge
+}
+
 func (gd *GithubDownloader) authenticatedCommand(path string,
 	args []string) ([]byte, error) {
 
@@ -879,7 +1028,7 @@ func (gd *GithubDownloader) FetchFile(
 }
 
 func (gd *GithubDownloader) remoteUrls() (string, string) {
-	server := "github.com"
+	server := forgeServers[gd.forge()]
 
 	if gd.Server != "" {
 		server = gd.Server
@@ -889,6 +1038,8 @@ func (gd *GithubDownloader) remoteUrls() (string, string) {
 	if gd.Login != "" {
 		pw := gd.password()
 		auth = fmt.Sprintf("%s:%s@", gd.Login, pw)
+	} else if tok := gd.token(); tok != "" {
+		auth = fmt.Sprintf("%s:%s@", forgeTokenUsers[gd.forge()], tok)
 	}
 
 	url := fmt.Sprintf("https://%s%s/%s/%s.git", auth, server, gd.User,
@@ -899,12 +1050,15 @@ func (gd *GithubDownloader) remoteUrls() (string, string) {
 }
 
 func (gd *GithubDownloader) setOriginUrl(path string, url string) error {
-	// Hide password in logged command.
+	// Hide password and token in logged command.
 	safeUrl := url
 	pw := gd.password()
 	if pw != "" {
 		safeUrl = strings.Replace(safeUrl, pw, "<password-hidden>", -1)
 	}
+	if tok := gd.token(); tok != "" {
+		safeUrl = strings.Replace(safeUrl, tok, "<token-hidden>", -1)
+	}
 	util.LogShellCmd(setRemoteUrlCmd("origin", safeUrl), nil)
 
 	return setRemoteUrl(path, "origin", url, false)
@@ -950,6 +1104,7 @@ func (gd *GithubDownloader) Clone(commit string, dstPath string) error {
 		cmd = append(cmd, "--depth", strconv.Itoa(util.ShallowCloneDepth), "--no-single-branch")
 	}
 
+	cmd = append(cmd, gd.sparseCloneArgs()...)
 	cmd = append(cmd, url, dstPath)
 
 	if util.Verbosity >= util.VERBOSITY_VERBOSE {
@@ -1055,6 +1210,7 @@ func (gd *GitDownloader) Clone(commit string, dstPath string) error {
 		cmd = append(cmd, "--depth", strconv.Itoa(util.ShallowCloneDepth), "--no-single-branch")
 	}
 
+	cmd = append(cmd, gd.sparseCloneArgs()...)
 	cmd = append(cmd, gd.Url, dstPath)
 
 	if util.Verbosity >= util.VERBOSITY_VERBOSE {
@@ -1171,9 +1327,10 @@ func LoadDownloader(repoName string, repoVars map[string]string) (
 	Downloader, error) {
 
 	switch repoVars["type"] {
-	case "github":
+	case FORGE_GITHUB, FORGE_GITLAB, FORGE_BITBUCKET:
 		gd := NewGithubDownloader()
 
+		gd.Forge = repoVars["type"]
 		gd.Server = repoVars["server"]
 		gd.User = repoVars["user"]
 		gd.Repo = repoVars["repo"]
@@ -1185,6 +1342,8 @@ func LoadDownloader(repoName string, repoVars map[string]string) (
 		gd.Login = repoVars["login"]
 		gd.Password = repoVars["password"]
 		gd.PasswordEnv = repoVars["password_env"]
+		gd.Token = repoVars["token"]
+		gd.TokenEnv = repoVars["token_env"]
 
 		// Alternatively, the user can put security material in
 		// $HOME/.newt/repos.yml.
@@ -1201,6 +1360,12 @@ func LoadDownloader(repoName string, repoVars map[string]string) (
 			if gd.PasswordEnv == "" {
 				gd.PasswordEnv = privRepo["password_env"]
 			}
+			if gd.Token == "" {
+				gd.Token = privRepo["token"]
+			}
+			if gd.TokenEnv == "" {
+				gd.TokenEnv = privRepo["token_env"]
+			}
 		}
 		return gd, nil
 
@@ -41,6 +41,9 @@ type SyscfgEntry struct {
 	RefName      string                 `json:"ref_name,omitempty"`
 	Restrictions []SyscfgRestriction    `json:"restrictions,omitempty"`
 	State        syscfg.CfgSettingState `json:"state"`
+	Group        string                 `json:"group,omitempty"`
+	Advanced     bool                   `json:"advanced,omitempty"`
+	Choice       string                 `json:"choice,omitempty"`
 }
 
 type SyscfgPriority struct {
@@ -183,6 +186,9 @@ func newSyscfg(cfg syscfg.Cfg) Syscfg {
 			RefName:      ce.ValueRefName,
 			Restrictions: restrictions,
 			State:        ce.State,
+			Group:        ce.Group,
+			Advanced:     ce.Advanced,
+			Choice:       ce.Choice,
 		}
 	}
 
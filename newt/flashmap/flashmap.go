@@ -23,12 +23,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cast"
 
 	"github.com/apache/mynewt-artifact/flash"
@@ -299,29 +295,6 @@ func writeFlashMapSrc(w io.Writer, fm FlashMap) {
 	fmt.Fprintf(w, "};\n")
 }
 
-func ensureFlashMapWrittenGen(path string, contents []byte) error {
-	writeReqd, err := util.FileContentsChanged(path, contents)
-	if err != nil {
-		return err
-	}
-	if !writeReqd {
-		log.Debugf("flash map unchanged; not writing file (%s).", path)
-		return nil
-	}
-
-	log.Debugf("flash map changed; writing file (%s).", path)
-
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return util.NewNewtError(err.Error())
-	}
-
-	if err := ioutil.WriteFile(path, contents, 0644); err != nil {
-		return util.NewNewtError(err.Error())
-	}
-
-	return nil
-}
-
 func EnsureFlashMapWritten(
 	fm FlashMap,
 	srcDir string,
@@ -330,7 +303,7 @@ func EnsureFlashMapWritten(
 
 	buf := bytes.Buffer{}
 	writeFlashMapSrc(&buf, fm)
-	if err := ensureFlashMapWrittenGen(
+	if err := util.WriteGeneratedFile(
 		fmt.Sprintf("%s/%s-sysflash.c", srcDir, targetName),
 		buf.Bytes()); err != nil {
 
@@ -339,7 +312,7 @@ func EnsureFlashMapWritten(
 
 	buf = bytes.Buffer{}
 	writeFlashMapHeader(&buf, fm)
-	if err := ensureFlashMapWrittenGen(
+	if err := util.WriteGeneratedFile(
 		includeDir+"/"+HEADER_PATH, buf.Bytes()); err != nil {
 		return err
 	}
@@ -0,0 +1,203 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// format - Runs a source code formatter over package source files.
+
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
+)
+
+const TOOL_CLANG_FORMAT = "clang-format"
+const TOOL_UNCRUSTIFY = "uncrustify"
+
+var Tools = []string{TOOL_CLANG_FORMAT, TOOL_UNCRUSTIFY}
+
+// The name of the uncrustify configuration file, located at the root of each
+// repo.  clang-format locates its configuration (`.clang-format`) on its own.
+const UNCRUSTIFY_CFG_FILENAME = "uncrustify.cfg"
+
+var srcExts = map[string]struct{}{
+	".c":   struct{}{},
+	".h":   struct{}{},
+	".cc":  struct{}{},
+	".cpp": struct{}{},
+	".cxx": struct{}{},
+	".hh":  struct{}{},
+	".hpp": struct{}{},
+}
+
+// Reads the list of directories that a package excludes from formatting.
+// These are typically vendored third-party sources.  Each entry is a path
+// relative to the package directory; globs are permitted:
+//
+//	pkg.format_exclude:
+//	    - src/ext
+//	    - include/vendor/*
+func excludes(lpkg *pkg.LocalPackage) []string {
+	excl, err := lpkg.PkgY.GetValStringSlice("pkg.format_exclude", nil)
+	util.OneTimeWarningError(err)
+
+	return excl
+}
+
+func isExcluded(relPath string, excl []string) bool {
+	for _, e := range excl {
+		e = strings.TrimSuffix(filepath.ToSlash(e), "/")
+		if relPath == e || strings.HasPrefix(relPath, e+"/") {
+			return true
+		}
+		if ok, _ := filepath.Match(e, relPath); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SourceFiles returns the C and C++ source files belonging to the specified
+// package.  Nested packages and directories excluded by the package's
+// `pkg.format_exclude` setting are skipped.
+func SourceFiles(lpkg *pkg.LocalPackage) ([]string, error) {
+	basePath := lpkg.BasePath()
+	excl := excludes(lpkg)
+
+	var files []string
+	err := filepath.Walk(basePath,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			relPath, err := filepath.Rel(basePath, path)
+			if err != nil {
+				return err
+			}
+			relPath = filepath.ToSlash(relPath)
+
+			if info.IsDir() {
+				if path == basePath {
+					return nil
+				}
+				if isExcluded(relPath, excl) {
+					log.Debugf("format: skipping excluded dir %s", path)
+					return filepath.SkipDir
+				}
+				if util.NodeExist(path + "/" + pkg.PACKAGE_FILE_NAME) {
+					// Nested package; formatted separately.
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if _, ok := srcExts[strings.ToLower(filepath.Ext(path))]; !ok {
+				return nil
+			}
+			if !isExcluded(relPath, excl) {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func toolCmd(tool string, lpkg *pkg.LocalPackage, file string,
+	check bool) ([]string, error) {
+
+	switch tool {
+	case TOOL_CLANG_FORMAT:
+		// Only format files covered by a `.clang-format` file.
+		cmd := []string{tool, "--style=file", "--fallback-style=none"}
+		if check {
+			cmd = append(cmd, "--dry-run", "--Werror")
+		} else {
+			cmd = append(cmd, "-i")
+		}
+		return append(cmd, file), nil
+
+	case TOOL_UNCRUSTIFY:
+		cfgPath := lpkg.Repo().Path() + "/" + UNCRUSTIFY_CFG_FILENAME
+		if !util.NodeExist(cfgPath) {
+			return nil, util.FmtNewtError(
+				"repo \"%s\" does not contain an uncrustify config (%s)",
+				lpkg.Repo().Name(), UNCRUSTIFY_CFG_FILENAME)
+		}
+
+		cmd := []string{tool, "-q", "-c", cfgPath}
+		if check {
+			cmd = append(cmd, "--check")
+		} else {
+			cmd = append(cmd, "--no-backup", "--replace")
+		}
+		return append(cmd, file), nil
+
+	default:
+		return nil, util.FmtNewtError(
+			"unsupported format tool \"%s\"; must be one of: %s",
+			tool, strings.Join(Tools, ", "))
+	}
+}
+
+// Run formats the source files of each specified package in place.  If check
+// is true, files are left untouched; the function instead returns the paths
+// of the files that are not correctly formatted.
+func Run(tool string, lpkgs []*pkg.LocalPackage,
+	check bool) ([]string, error) {
+
+	var bad []string
+
+	for _, lpkg := range lpkgs {
+		files, err := SourceFiles(lpkg)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			cmd, err := toolCmd(tool, lpkg, file, check)
+			if err != nil {
+				return nil, err
+			}
+
+			_, err = util.ShellCommand(cmd, nil)
+			if err != nil {
+				if !check || !util.IsExit(err) {
+					return nil, err
+				}
+				bad = append(bad, file)
+			}
+		}
+	}
+
+	return bad, nil
+}
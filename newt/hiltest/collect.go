@@ -0,0 +1,244 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package hiltest
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/newt/devmgr"
+	"mynewt.apache.org/newt/util"
+)
+
+const (
+	COLLECT_SERIAL = "serial"
+	COLLECT_SMP    = "smp"
+)
+
+// The device log that on-device test suites write their results to.
+const SMP_TEST_LOG = "testlog"
+
+// How often the device is polled for results when collecting over SMP.
+const smpPollInterval = time.Second
+
+// Parses a complete block of test output.  The second return value indicates
+// whether the output contains the end-of-run marker.
+func parseOutput(out string) ([]Result, bool) {
+	var results []Result
+	for _, line := range strings.Split(out, "\n") {
+		if res, ok := ParseLine(line); ok {
+			results = append(results, res)
+		}
+		if IsDone(line) {
+			return results, true
+		}
+	}
+
+	return results, false
+}
+
+func timeoutError(results []Result) error {
+	return util.FmtNewtError(
+		"timed out waiting for the test run to finish (%d result(s) "+
+			"received)", len(results))
+}
+
+// A line received from a device console, and when it was received.
+type consoleLine struct {
+	text string
+	time time.Time
+}
+
+// A device console on a serial port.  Lines are read in the background from
+// the moment the port is opened, so that output produced while the device is
+// being flashed is not lost.
+type serialConsole struct {
+	f     *os.File
+	lines chan consoleLine
+
+	// Closed when the console is closed; stops the reader.
+	done chan struct{}
+}
+
+func openSerial(port string, baud int) (*serialConsole, error) {
+	if runtime.GOOS == "windows" {
+		return nil, util.FmtNewtError(
+			"collecting results over a serial port is not supported on " +
+				"Windows; use --collect smp")
+	}
+
+	flag := "-F"
+	if runtime.GOOS == "darwin" {
+		flag = "-f"
+	}
+
+	if _, err := util.ShellCommand([]string{
+		"stty", flag, port, strconv.Itoa(baud), "raw", "-echo",
+	}, nil); err != nil {
+		return nil, util.FmtNewtError(
+			"failed to configure serial port %s: %s", port, err.Error())
+	}
+
+	f, err := os.Open(port)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	c := &serialConsole{
+		f:     f,
+		lines: make(chan consoleLine, 1024),
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(c.lines)
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := consoleLine{
+				text: scanner.Text(),
+				time: time.Now(),
+			}
+
+			// Wait for the collector to catch up rather than drop output,
+			// unless the console is closed.
+			select {
+			case c.lines <- line:
+			case <-c.done:
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// Discards the lines that have been received so far.
+func (c *serialConsole) discard() {
+	for {
+		select {
+		case _, ok := <-c.lines:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Reads results from the console until the end-of-run marker is received or
+// the timeout expires.  `flashed` is the time that the device finished being
+// flashed.  Output received before then may come from the previously running
+// app, so an end-of-run marker received before then discards the results
+// that preceded it rather than ending the run.
+func (c *serialConsole) collect(flashed time.Time,
+	timeout time.Duration) ([]Result, error) {
+
+	var results []Result
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case line, ok := <-c.lines:
+			if !ok {
+				return results, util.FmtNewtError(
+					"serial port closed before the test run finished")
+			}
+
+			log.Debugf("console: %s", line.text)
+			if res, ok := ParseLine(line.text); ok {
+				util.StatusMessage(util.VERBOSITY_VERBOSE, "    %s\n",
+					strings.TrimSpace(line.text))
+				results = append(results, res)
+			}
+			if IsDone(line.text) {
+				if line.time.Before(flashed) {
+					results = nil
+					continue
+				}
+				return results, nil
+			}
+
+		case <-timer.C:
+			return results, timeoutError(results)
+		}
+	}
+}
+
+func (c *serialConsole) close() {
+	close(c.done)
+	c.f.Close()
+}
+
+// Clears the device's logs, starts the device's test suites over SMP, and polls the device's test log
+// until the end-of-run marker appears or the timeout expires.  The device may
+// still be booting when this is called, so failures to start the tests are
+// retried until the timeout.
+func collectSmp(nm *devmgr.Newtmgr, timeout time.Duration) ([]Result, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		// Clear the test log first so that results from a previous run are
+		// not counted.
+		err := nm.LogClear()
+		if err == nil {
+			err = nm.RunTest("all")
+		}
+		if err == nil {
+			break
+		}
+
+		log.Debugf("device not ready: %s", err.Error())
+		if time.Now().After(deadline) {
+			return nil, util.FmtNewtError(
+				"failed to start the device's test suites: %s", err.Error())
+		}
+		time.Sleep(smpPollInterval)
+	}
+
+	var results []Result
+	for {
+		time.Sleep(smpPollInterval)
+
+		out, err := nm.LogShow(SMP_TEST_LOG)
+		if err != nil {
+			log.Debugf("failed to read test log: %s", err.Error())
+		} else {
+			var done bool
+			results, done = parseOutput(out)
+			if done {
+				return results, nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return results, timeoutError(results)
+		}
+	}
+}
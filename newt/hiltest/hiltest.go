@@ -0,0 +1,152 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package hiltest runs tests on real hardware.  A hardware-in-the-loop test
+// is an app package named `hil_test` (or nested under a `hil_test`
+// directory) within the package it tests.  Each test app is built for a
+// target's BSP, flashed to a device, and its results are collected from the
+// device's console or over SMP.
+//
+// A test app reports each test case on its own line, followed by a line that
+// marks the end of the run:
+//
+//	[pass] <suite>/<case>
+//	[FAIL] <suite>/<case>: <message>
+//	[done]
+//
+// Any text that precedes the bracketed tag (e.g., a console timestamp) is
+// ignored.
+package hiltest
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/project"
+)
+
+// The name of the directory containing a package's HIL test apps.
+const HIL_TEST_DIR = "hil_test"
+
+// The outcome of a single test case.
+type Result struct {
+	Suite  string
+	Case   string
+	Passed bool
+	Msg    string
+}
+
+// The outcome of running one HIL test package on a device.
+type Report struct {
+	// Full name of the test package.
+	Pkg string
+
+	// The target that the test package was built for.
+	Target string
+
+	Results []Result
+	Time    time.Duration
+
+	// Set if the test package could not be built, flashed, or run to
+	// completion.  Results that were collected before the error are kept.
+	Err error
+}
+
+var resultRe = regexp.MustCompile(
+	`\[(pass|FAIL)\]\s+([^/\s]+)/(\S+?):?(?:\s+(.*))?$`)
+var doneRe = regexp.MustCompile(`\[done\]\s*$`)
+
+// ParseLine extracts a test result from a line of test output.  The second
+// return value is false if the line is not a result.
+func ParseLine(line string) (Result, bool) {
+	m := resultRe.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+	if m == nil {
+		return Result{}, false
+	}
+
+	return Result{
+		Suite:  m[2],
+		Case:   m[3],
+		Passed: m[1] == "pass",
+		Msg:    strings.TrimSpace(m[4]),
+	}, true
+}
+
+// IsDone reports whether a line of test output marks the end of the run.
+func IsDone(line string) bool {
+	return doneRe.MatchString(strings.TrimRight(line, "\r\n"))
+}
+
+// NumFailed returns the number of failed test cases.
+func (r *Report) NumFailed() int {
+	n := 0
+	for _, res := range r.Results {
+		if !res.Passed {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Passed reports whether the test package ran to completion without any
+// failures.
+func (r *Report) Passed() bool {
+	return r.Err == nil && r.NumFailed() == 0
+}
+
+// IsTestPkg reports whether a package is a HIL test app.
+func IsTestPkg(lpkg *pkg.LocalPackage) bool {
+	if lpkg.Type() != pkg.PACKAGE_TYPE_APP {
+		return false
+	}
+
+	name := lpkg.Name()
+	return strings.HasSuffix(name, "/"+HIL_TEST_DIR) ||
+		strings.Contains(name, "/"+HIL_TEST_DIR+"/")
+}
+
+// TestPkgs returns the HIL test apps of the specified package, or of every
+// package in the project if `parent` is nil.  If `parent` is itself a HIL
+// test app, it is the only package returned.
+func TestPkgs(parent *pkg.LocalPackage) []*pkg.LocalPackage {
+	if parent != nil && IsTestPkg(parent) {
+		return []*pkg.LocalPackage{parent}
+	}
+
+	var lpkgs []*pkg.LocalPackage
+	for _, p := range project.GetProject().PackagesOfType(
+		pkg.PACKAGE_TYPE_APP) {
+
+		lpkg := p.(*pkg.LocalPackage)
+		if !IsTestPkg(lpkg) {
+			continue
+		}
+		if parent != nil &&
+			!strings.HasPrefix(lpkg.FullName(), parent.FullName()+"/") {
+
+			continue
+		}
+		lpkgs = append(lpkgs, lpkg)
+	}
+
+	return pkg.SortLclPkgs(lpkgs)
+}
@@ -0,0 +1,112 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package hiltest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+
+	"mynewt.apache.org/newt/util"
+)
+
+type junitMsg struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitTestCase struct {
+	Classname string    `xml:"classname,attr"`
+	Name      string    `xml:"name,attr"`
+	Failure   *junitMsg `xml:"failure,omitempty"`
+	Error     *junitMsg `xml:"error,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Hostname string          `xml:"hostname,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// Converts a report to a JUnit test suite.  A report's error, if any, is
+// represented by an extra test case named after the test package.
+func junitSuite(r *Report) junitTestSuite {
+	s := junitTestSuite{
+		Name:     r.Pkg,
+		Hostname: r.Target,
+		Time:     fmt.Sprintf("%.3f", r.Time.Seconds()),
+	}
+
+	for _, res := range r.Results {
+		tc := junitTestCase{
+			Classname: res.Suite,
+			Name:      res.Case,
+		}
+		if !res.Passed {
+			tc.Failure = &junitMsg{Message: res.Msg}
+			s.Failures++
+		}
+		s.Cases = append(s.Cases, tc)
+	}
+
+	if r.Err != nil {
+		s.Cases = append(s.Cases, junitTestCase{
+			Classname: r.Pkg,
+			Name:      r.Pkg,
+			Error:     &junitMsg{Message: r.Err.Error()},
+		})
+		s.Errors++
+	}
+
+	s.Tests = len(s.Cases)
+
+	return s
+}
+
+// WriteJUnit writes a JUnit XML report containing one test suite per test
+// package.
+func WriteJUnit(path string, reports []*Report) error {
+	var doc junitTestSuites
+	for _, r := range reports {
+		doc.Suites = append(doc.Suites, junitSuite(r))
+	}
+
+	x, err := xml.MarshalIndent(doc, "", "    ")
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	b := append([]byte(xml.Header), x...)
+	b = append(b, '\n')
+
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
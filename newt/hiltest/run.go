@@ -0,0 +1,147 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package hiltest
+
+import (
+	"time"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/devmgr"
+	"mynewt.apache.org/newt/newt/imgprod"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/newt/target"
+	"mynewt.apache.org/newt/util"
+)
+
+type RunOpts struct {
+	// The device to run the tests on.
+	Device *devmgr.Device
+
+	// How results are collected: COLLECT_SERIAL (the device's console) or
+	// COLLECT_SMP (the device's test log).
+	Collect string
+
+	// Used to access the device when collecting over SMP.
+	Newtmgr *devmgr.Newtmgr
+
+	// How long to wait for a test run to finish once the device is flashed.
+	Timeout time.Duration
+}
+
+// TestTarget returns the target that a HIL test package is built with: a copy
+// of the specified target with the test package as its app.  Each test
+// package gets its own target so that generated files do not collide.
+func TestTarget(t *target.Target, testPkg *pkg.LocalPackage) *target.Target {
+	name := t.Name() + "/hil/" + builder.TestTargetName(testPkg.Name())
+
+	tt := target.GetTargets()[name]
+	if tt == nil {
+		tt = t.Clone(project.GetProject().LocalRepo(), name)
+	}
+
+	tt.AppName = testPkg.FullName()
+	tt.LoaderName = ""
+
+	return tt
+}
+
+// Builds a test target, produces an unsigned image, and flashes it to the
+// device.
+func flash(tt *target.Target, dev *devmgr.Device) error {
+	b, err := builder.NewTargetBuilder(tt)
+	if err != nil {
+		return err
+	}
+	b.SetDeviceEnv(dev.EnvVars())
+
+	if err := b.Build(); err != nil {
+		return err
+	}
+
+	imgFmt, err := imgprod.LookupImageFormat(tt.ImageFormat)
+	if err != nil {
+		return err
+	}
+	if err := imgFmt.Produce(b, imgprod.ImageFormatOpts{}); err != nil {
+		return err
+	}
+
+	return b.Load("", "")
+}
+
+// Run builds a HIL test package for a target, flashes it to the device, and
+// collects its results.  Errors are recorded in the returned report rather
+// than returned, so that a failure in one test package does not prevent the
+// others from running.
+func Run(t *target.Target, testPkg *pkg.LocalPackage, opts RunOpts) *Report {
+	start := time.Now()
+
+	tt := TestTarget(t, testPkg)
+	r := &Report{
+		Pkg:    testPkg.FullName(),
+		Target: t.FullName(),
+	}
+	defer func() {
+		r.Time = time.Since(start)
+	}()
+
+	switch opts.Collect {
+	case COLLECT_SERIAL:
+		if opts.Device.SerialPort == "" {
+			r.Err = util.FmtNewtError(
+				"device \"%s\" does not specify a serial_port",
+				opts.Device.Name)
+			return r
+		}
+
+		// Open the console before flashing so that no output is missed.
+		// Anything the port received before flashing started is stale.
+		c, err := openSerial(opts.Device.SerialPort, opts.Device.Baud)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+		defer c.close()
+		c.discard()
+
+		if err := flash(tt, opts.Device); err != nil {
+			r.Err = err
+			return r
+		}
+
+		r.Results, r.Err = c.collect(time.Now(), opts.Timeout)
+
+	case COLLECT_SMP:
+		if err := flash(tt, opts.Device); err != nil {
+			r.Err = err
+			return r
+		}
+
+		r.Results, r.Err = collectSmp(opts.Newtmgr, opts.Timeout)
+
+	default:
+		r.Err = util.FmtNewtError(
+			"invalid collection method \"%s\"; must be %s or %s",
+			opts.Collect, COLLECT_SERIAL, COLLECT_SMP)
+	}
+
+	return r
+}
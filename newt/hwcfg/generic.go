@@ -0,0 +1,290 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package hwcfg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cast"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/val"
+	"mynewt.apache.org/newt/util"
+)
+
+const HEADER_PATH = "hwcfg/hwcfg.h"
+
+// The generic generator supports any MCU with a HAL GPIO driver.  It reads
+// the following from the configuration file:
+//
+//	hwcfg.pins:
+//	    <name>:
+//	        pin: <pin-number-or-setting>
+//	        dir: <out|in>
+//	        value: <initial-level>       # out only; default 0
+//	        pull: <none|up|down>         # in only; default none
+//
+//	hwcfg.regs:
+//	    - name: <description>
+//	      addr: <address>
+//	      value: <value>
+//	      mask: <mask>                   # optional; read-modify-write
+type genericGenerator struct{}
+
+type genericPin struct {
+	Name  string
+	Pin   val.ValSetting
+	Dir   string
+	Value int
+	Pull  string
+}
+
+type genericReg struct {
+	Name  string
+	Addr  int
+	Value int
+	Mask  int // 0 if the whole register is written.
+}
+
+var pullNames = map[string]string{
+	"":     "HAL_GPIO_PULL_NONE",
+	"none": "HAL_GPIO_PULL_NONE",
+	"up":   "HAL_GPIO_PULL_UP",
+	"down": "HAL_GPIO_PULL_DOWN",
+}
+
+func (hc *HwCfg) readPins() ([]genericPin, error) {
+	settings := hc.Cfg.AllSettingsForLpkg(hc.Bsp.LocalPackage)
+	pinMap, err := hc.Yc.GetValStringMap("hwcfg.pins", settings)
+	util.OneTimeWarningError(err)
+
+	var pins []genericPin
+	for name, itf := range pinMap {
+		fields := cast.ToStringMapString(itf)
+
+		pin := genericPin{
+			Name: name,
+			Dir:  fields["dir"],
+			Pull: fields["pull"],
+		}
+
+		if fields["pin"] == "" {
+			return nil, util.FmtNewtError(
+				"pin \"%s\" missing required field \"pin\"", name)
+		}
+		pin.Pin, err = val.ResolveValSetting(fields["pin"], hc.Cfg)
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"pin \"%s\" contains invalid \"pin\": %s", name, err.Error())
+		}
+		if _, err := pin.Pin.IntVal(); err != nil {
+			return nil, util.FmtNewtError(
+				"pin \"%s\" contains invalid \"pin\": %s", name, err.Error())
+		}
+
+		switch pin.Dir {
+		case "out":
+			if fields["value"] != "" {
+				pin.Value, err = util.AtoiNoOct(fields["value"])
+				if err != nil {
+					return nil, util.FmtNewtError(
+						"pin \"%s\" contains invalid \"value\": %s",
+						name, fields["value"])
+				}
+			}
+		case "in":
+			if _, ok := pullNames[pin.Pull]; !ok {
+				return nil, util.FmtNewtError(
+					"pin \"%s\" contains invalid \"pull\": %s",
+					name, pin.Pull)
+			}
+		default:
+			return nil, util.FmtNewtError(
+				"pin \"%s\" contains invalid \"dir\": \"%s\"; "+
+					"must be \"out\" or \"in\"", name, pin.Dir)
+		}
+
+		pins = append(pins, pin)
+	}
+
+	sort.Slice(pins, func(i int, j int) bool {
+		return pins[i].Name < pins[j].Name
+	})
+
+	return pins, nil
+}
+
+// Registers are written in the order they are listed.
+func (hc *HwCfg) readRegs() ([]genericReg, error) {
+	settings := hc.Cfg.AllSettingsForLpkg(hc.Bsp.LocalPackage)
+	itfs, err := hc.Yc.GetValSlice("hwcfg.regs", settings)
+	util.OneTimeWarningError(err)
+
+	var regs []genericReg
+	for i, itf := range itfs {
+		fields := cast.ToStringMapString(itf)
+
+		reg := genericReg{
+			Name: fields["name"],
+		}
+		if reg.Name == "" {
+			reg.Name = fmt.Sprintf("#%d", i)
+		}
+
+		nums := []struct {
+			field    string
+			dst      *int
+			required bool
+		}{
+			{"addr", &reg.Addr, true},
+			{"value", &reg.Value, true},
+			{"mask", &reg.Mask, false},
+		}
+		for _, n := range nums {
+			s := fields[n.field]
+			if s == "" {
+				if n.required {
+					return nil, util.FmtNewtError(
+						"register %s missing required field \"%s\"",
+						reg.Name, n.field)
+				}
+				continue
+			}
+
+			*n.dst, err = util.AtoiNoOct(s)
+			if err != nil {
+				return nil, util.FmtNewtError(
+					"register %s contains invalid \"%s\": %s",
+					reg.Name, n.field, s)
+			}
+		}
+
+		regs = append(regs, reg)
+	}
+
+	return regs, nil
+}
+
+func writeGenericHeader(pins []genericPin, w io.Writer) {
+	fmt.Fprint(w, newtutil.GeneratedPreamble())
+
+	fmt.Fprintf(w, "#ifndef H_MYNEWT_HWCFG_\n")
+	fmt.Fprintf(w, "#define H_MYNEWT_HWCFG_\n\n")
+
+	fmt.Fprintf(w, "#ifdef __cplusplus\n")
+	fmt.Fprintf(w, "extern \"C\" {\n")
+	fmt.Fprintf(w, "#endif\n\n")
+
+	for _, p := range pins {
+		fmt.Fprintf(w, "#define HWCFG_PIN_%s (%s)\n", p.Name, p.Pin.Value)
+	}
+	if len(pins) > 0 {
+		fmt.Fprintf(w, "\n")
+	}
+
+	fmt.Fprintf(w, "void hwcfg_init(void);\n\n")
+
+	fmt.Fprintf(w, "#ifdef __cplusplus\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "#endif\n\n")
+
+	fmt.Fprintf(w, "#endif\n")
+}
+
+func writeGenericSource(pins []genericPin, regs []genericReg, w io.Writer) {
+	fmt.Fprint(w, newtutil.GeneratedPreamble())
+
+	fmt.Fprintf(w, "#include <inttypes.h>\n")
+	fmt.Fprintf(w, "#include \"hal/hal_gpio.h\"\n")
+	fmt.Fprintf(w, "#include \"%s\"\n\n", HEADER_PATH)
+
+	fmt.Fprintf(w, "void\n")
+	fmt.Fprintf(w, "hwcfg_init(void)\n")
+	fmt.Fprintf(w, "{\n")
+
+	for _, r := range regs {
+		fmt.Fprintf(w, "    /* %s */\n", r.Name)
+		if r.Mask == 0 {
+			fmt.Fprintf(w, "    *(volatile uint32_t *)0x%08x = 0x%08x;\n",
+				r.Addr, r.Value)
+		} else {
+			fmt.Fprintf(w, "    *(volatile uint32_t *)0x%08x =\n", r.Addr)
+			fmt.Fprintf(w, "        (*(volatile uint32_t *)0x%08x & "+
+				"~0x%08xUL) | 0x%08x;\n", r.Addr, r.Mask, r.Value&r.Mask)
+		}
+	}
+	if len(regs) > 0 && len(pins) > 0 {
+		fmt.Fprintf(w, "\n")
+	}
+
+	for _, p := range pins {
+		if p.Dir == "out" {
+			fmt.Fprintf(w, "    hal_gpio_init_out(HWCFG_PIN_%s, %d);\n",
+				p.Name, p.Value)
+		} else {
+			fmt.Fprintf(w, "    hal_gpio_init_in(HWCFG_PIN_%s, %s);\n",
+				p.Name, pullNames[p.Pull])
+		}
+	}
+
+	fmt.Fprintf(w, "}\n")
+}
+
+func (g genericGenerator) Generate(hc *HwCfg, dir string,
+	env map[string]string) error {
+
+	pins, err := hc.readPins()
+	if err != nil {
+		return err
+	}
+
+	regs, err := hc.readRegs()
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		path string
+		buf  bytes.Buffer
+	}{
+		{path: dir + "/include/" + HEADER_PATH},
+		{path: dir + "/src/hwcfg.c"},
+	}
+	writeGenericHeader(pins, &files[0].buf)
+	writeGenericSource(pins, regs, &files[1].buf)
+
+	for i, _ := range files {
+		f := &files[i]
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			return util.ChildNewtError(err)
+		}
+		if err := ioutil.WriteFile(f.path, f.buf.Bytes(), 0644); err != nil {
+			return util.ChildNewtError(err)
+		}
+	}
+
+	return nil
+}
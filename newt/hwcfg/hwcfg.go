@@ -0,0 +1,289 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package hwcfg converts a BSP's hardware configuration (pin muxing, clocks,
+// and other register settings) into C initialization code.  The BSP
+// specifies a YAML file with the `bsp.hwcfg` setting; a generator, selected
+// per MCU family, turns the file into source and header files in the
+// target's generated directories.
+package hwcfg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/newt/config"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/syscfg"
+	"mynewt.apache.org/newt/newt/ycfg"
+	"mynewt.apache.org/newt/util"
+)
+
+const GENERATOR_GENERIC = "generic"
+
+// A Generator converts a hardware configuration into C code.
+type Generator interface {
+	// Writes the generated code to the `src` and `include` subdirectories
+	// of `dir`.  `env` contains the environment variables that newt passes
+	// to external scripts.
+	Generate(hc *HwCfg, dir string, env map[string]string) error
+}
+
+// [generator-name] => generator
+var generators = map[string]Generator{
+	GENERATOR_GENERIC: genericGenerator{},
+}
+
+// Makes a generator available to MCU packages and BSPs under the specified
+// name.
+func RegisterGenerator(name string, g Generator) {
+	generators[name] = g
+}
+
+type HwCfg struct {
+	// The BSP that specifies the configuration.
+	Bsp *pkg.BspPackage
+
+	// Path of the configuration file; "" if the BSP does not specify one.
+	Path string
+
+	// The contents of the configuration file.
+	Yc ycfg.YCfg
+
+	// The syscfg of the target being built.
+	Cfg *syscfg.Cfg
+
+	// The name of the selected generator and the package that selected it.
+	GeneratorName string
+	GeneratorPkg  *pkg.LocalPackage
+
+	// Strings describing errors encountered while reading the hardware
+	// config.
+	InvalidSettings []string
+}
+
+// Reads the generator setting (`pkg.hwcfg_generator` or
+// `bsp.hwcfg_generator`) of each package.  The BSP's setting takes
+// precedence over those of other packages (e.g., the MCU package).
+func (hc *HwCfg) readGenerator(lpkgs []*pkg.LocalPackage) {
+	bspSettings := hc.Cfg.AllSettingsForLpkg(hc.Bsp.LocalPackage)
+	name, err := hc.Bsp.BspV.GetValString("bsp.hwcfg_generator", bspSettings)
+	util.OneTimeWarningError(err)
+	if name != "" {
+		hc.GeneratorName = name
+		hc.GeneratorPkg = hc.Bsp.LocalPackage
+		return
+	}
+
+	sorted := make([]*pkg.LocalPackage, len(lpkgs))
+	copy(sorted, lpkgs)
+	sort.Slice(sorted, func(i int, j int) bool {
+		return sorted[i].FullName() < sorted[j].FullName()
+	})
+
+	for _, lpkg := range sorted {
+		name, err := lpkg.PkgY.GetValString("pkg.hwcfg_generator",
+			hc.Cfg.AllSettingsForLpkg(lpkg))
+		util.OneTimeWarningError(err)
+		if name == "" {
+			continue
+		}
+
+		if hc.GeneratorPkg != nil && name != hc.GeneratorName {
+			hc.InvalidSettings = append(hc.InvalidSettings,
+				fmt.Sprintf("conflicting hwcfg generators: %s (%s), %s (%s)",
+					hc.GeneratorName, hc.GeneratorPkg.FullName(),
+					name, lpkg.FullName()))
+			continue
+		}
+
+		hc.GeneratorName = name
+		hc.GeneratorPkg = lpkg
+	}
+
+	if hc.GeneratorName == "" {
+		hc.GeneratorName = GENERATOR_GENERIC
+	}
+}
+
+// Retrieves the selected generator.  A name that is not registered is the
+// path of an external command, relative to the package that specifies it.
+func (hc *HwCfg) generator() Generator {
+	if g := generators[hc.GeneratorName]; g != nil {
+		return g
+	}
+
+	return &cmdGenerator{
+		Path: hc.GeneratorPkg.BasePath() + "/" + hc.GeneratorName,
+	}
+}
+
+// Reads the hardware configuration of a target.  `lpkgs` is the full set of
+// packages in the target.
+func Read(lpkgs []*pkg.LocalPackage, bsp *pkg.BspPackage,
+	cfg *syscfg.Cfg) HwCfg {
+
+	hc := HwCfg{
+		Bsp: bsp,
+		Cfg: cfg,
+	}
+
+	if bsp == nil {
+		return hc
+	}
+
+	settings := cfg.AllSettingsForLpkg(bsp.LocalPackage)
+	relPath, err := bsp.BspV.GetValString("bsp.hwcfg", settings)
+	util.OneTimeWarningError(err)
+	if relPath == "" {
+		return hc
+	}
+
+	hc.Path = bsp.BasePath() + "/" + relPath
+	hc.Yc, err = config.ReadFile(hc.Path)
+	if err != nil {
+		hc.InvalidSettings = append(hc.InvalidSettings,
+			strings.TrimSpace(err.Error()))
+		return hc
+	}
+
+	hc.readGenerator(lpkgs)
+
+	return hc
+}
+
+// Indicates whether the BSP specifies a hardware configuration.
+func (hc *HwCfg) Present() bool {
+	return hc.Path != ""
+}
+
+// If any problems were detected in the hardware configuration, this function
+// returns a string describing them.  Otherwise, "" is returned.
+func (hc *HwCfg) ErrorText() string {
+	if len(hc.InvalidSettings) == 0 {
+		return ""
+	}
+
+	str := "Invalid hardware configuration detected:"
+	for _, e := range hc.InvalidSettings {
+		str += "\n    " + e
+	}
+
+	return str + "\n"
+}
+
+// Copies the generated files in `srcDir` to `dstDir`.  Files are only
+// written if their contents have changed so that unchanged code does not get
+// rebuilt.
+func copyChanged(srcDir string, dstDir string, prefix string) error {
+	infos, err := ioutil.ReadDir(srcDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return util.ChildNewtError(err)
+	}
+
+	for _, info := range infos {
+		if info.IsDir() {
+			if err := copyChanged(srcDir+"/"+info.Name(),
+				dstDir+"/"+info.Name(), ""); err != nil {
+
+				return err
+			}
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(srcDir + "/" + info.Name())
+		if err != nil {
+			return util.ChildNewtError(err)
+		}
+
+		path := dstDir + "/" + prefix + info.Name()
+		if err := util.WriteGeneratedFile(path, contents); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Runs the generator and writes its output to the target's generated
+// directories.  Source files are prefixed with the target name.
+func (hc *HwCfg) EnsureWritten(includeDir string, srcDir string,
+	targetName string, env map[string]string) error {
+
+	if !hc.Present() {
+		return nil
+	}
+
+	tmpDir, err := ioutil.TempDir("", "newt-hwcfg")
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	log.Debugf("generating hwcfg with generator \"%s\"", hc.GeneratorName)
+	if err := hc.generator().Generate(hc, tmpDir, env); err != nil {
+		return err
+	}
+
+	if err := copyChanged(tmpDir+"/src", srcDir, targetName+"-"); err != nil {
+		return err
+	}
+	if err := copyChanged(tmpDir+"/include", includeDir, ""); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Runs an external command to generate the hardware configuration code.  The
+// command is passed the path of the configuration file and the output
+// directory in the MYNEWT_HWCFG_FILE and MYNEWT_HWCFG_OUT_DIR environment
+// variables.
+type cmdGenerator struct {
+	Path string
+}
+
+func (g *cmdGenerator) Generate(hc *HwCfg, dir string,
+	env map[string]string) error {
+
+	if !util.NodeExist(g.Path) {
+		return util.FmtNewtError("hwcfg generator not found: %s", g.Path)
+	}
+
+	cmdEnv := map[string]string{}
+	for k, v := range env {
+		cmdEnv[k] = v
+	}
+	cmdEnv["MYNEWT_HWCFG_FILE"] = hc.Path
+	cmdEnv["MYNEWT_HWCFG_OUT_DIR"] = dir
+
+	if _, err := util.ShellCommand([]string{g.Path}, cmdEnv); err != nil {
+		return err
+	}
+
+	return nil
+}
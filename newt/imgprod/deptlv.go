@@ -0,0 +1,156 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package imgprod
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/apache/mynewt-artifact/image"
+	"github.com/apache/mynewt-artifact/sec"
+	"mynewt.apache.org/newt/util"
+)
+
+// MCUboot TLV type indicating that an image depends on another image.
+const IMAGE_TLV_DEPENDENCY = 0x40
+
+// Size of the value of a dependency TLV (struct image_dependency):
+//
+//	uint8_t  image_id;
+//	uint8_t  _pad1;
+//	uint16_t _pad2;
+//	struct image_version image_min_version;
+const IMAGE_DEP_TLV_LEN = 12
+
+// An image dependency: the image being created requires the specified image
+// to be present with at least the specified version.
+type ImageDep struct {
+	ImageId    int
+	MinVersion image.ImageVersion
+}
+
+// ParseImageDep parses an image dependency string of the form
+// "<image-id>:<min-version>" (e.g., "1:1.2.0").
+func ParseImageDep(s string) (ImageDep, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return ImageDep{}, util.FmtNewtError(
+			"invalid image dependency \"%s\"; "+
+				"expected <image-id>:<min-version>", s)
+	}
+
+	id, err := strconv.ParseUint(parts[0], 10, 8)
+	if err != nil {
+		return ImageDep{}, util.FmtNewtError(
+			"invalid image dependency \"%s\"; image ID must be between 0-255",
+			s)
+	}
+
+	ver, err := image.ParseVersion(parts[1])
+	if err != nil {
+		return ImageDep{}, util.FmtNewtError(
+			"invalid image dependency \"%s\": %s", s, err.Error())
+	}
+
+	return ImageDep{
+		ImageId:    int(id),
+		MinVersion: ver,
+	}, nil
+}
+
+func depTlv(dep ImageDep) image.ImageTlv {
+	data := make([]byte, IMAGE_DEP_TLV_LEN)
+	data[0] = uint8(dep.ImageId)
+	data[4] = dep.MinVersion.Major
+	data[5] = dep.MinVersion.Minor
+	binary.LittleEndian.PutUint16(data[6:], dep.MinVersion.Rev)
+	binary.LittleEndian.PutUint32(data[8:], dep.MinVersion.BuildNum)
+
+	return image.ImageTlv{
+		Header: image.ImageTlvHdr{
+			Type: IMAGE_TLV_DEPENDENCY,
+			Len:  uint16(len(data)),
+		},
+		Data: data,
+	}
+}
+
+// Types of the unprotected TLVs that are derived from the image hash.
+var hashDerivedTlvTypes = map[uint8]struct{}{
+	image.IMAGE_TLV_SHA256:   struct{}{},
+	image.IMAGE_TLV_KEYHASH:  struct{}{},
+	image.IMAGE_TLV_RSA2048:  struct{}{},
+	image.IMAGE_TLV_RSA3072:  struct{}{},
+	image.IMAGE_TLV_ECDSA224: struct{}{},
+	image.IMAGE_TLV_ECDSA256: struct{}{},
+	image.IMAGE_TLV_ED25519:  struct{}{},
+}
+
+// Adds dependency TLVs to the protected area of an image.  Protected TLVs are
+// covered by the image hash, so the hash and signature TLVs are regenerated.
+// `initialHash` is the loader hash for split images; nil otherwise.
+func addDepTlvs(ri *image.Image, deps []ImageDep, initialHash []byte,
+	keys []sec.PrivSignKey) error {
+
+	if len(deps) == 0 {
+		return nil
+	}
+
+	// The hash of an encrypted image is calculated over the plain body,
+	// which is no longer available.
+	if ri.IsEncrypted() {
+		return util.NewNewtError(
+			"image dependencies cannot be added to an encrypted image")
+	}
+
+	for _, dep := range deps {
+		ri.ProtTlvs = append(ri.ProtTlvs, depTlv(dep))
+	}
+	ri.Header.ProtSz = ri.ProtTrailer().TlvTotLen
+
+	hash, err := ri.CalcHash(initialHash)
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	ri.RemoveTlvsIf(func(tlv image.ImageTlv) bool {
+		_, ok := hashDerivedTlvTypes[tlv.Header.Type]
+		return ok
+	})
+
+	sigTlvs, err := image.BuildSigTlvs(keys, hash)
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	hashTlv := image.ImageTlv{
+		Header: image.ImageTlvHdr{
+			Type: image.IMAGE_TLV_SHA256,
+			Len:  uint16(len(hash)),
+		},
+		Data: hash,
+	}
+
+	tlvs := append([]image.ImageTlv{hashTlv}, sigTlvs...)
+	ri.Tlvs = append(tlvs, ri.Tlvs...)
+
+	return nil
+}
@@ -0,0 +1,126 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package imgprod
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/apache/mynewt-artifact/image"
+	"github.com/apache/mynewt-artifact/sec"
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/util"
+)
+
+const IMAGE_FORMAT_V1 = "v1"
+const IMAGE_FORMAT_V2 = "v2"
+
+// The format used when neither the command line nor the target
+// (`target.image_format`) specifies one.
+const IMAGE_FORMAT_DFLT = IMAGE_FORMAT_V2
+
+// Options common to all image formats.  A format rejects options it does not
+// support.
+type ImageFormatOpts struct {
+	Version        image.ImageVersion
+	SigKeys        []sec.PrivSignKey
+	EncKeyFilename string
+	EncKeyIndex    int
+	HdrPad         int
+	ImagePad       int
+	Sections       string
+	UseLegacyTLV   bool
+	Deps           []ImageDep
+}
+
+// An ImageFormat converts a target's built binaries into image files and
+// writes the target's manifest.
+type ImageFormat interface {
+	// Produce generates the image files for an already-built target.
+	Produce(t *builder.TargetBuilder, opts ImageFormatOpts) error
+}
+
+// [format-name] => format
+var imageFormats = map[string]ImageFormat{
+	IMAGE_FORMAT_V1: v1Format{},
+	IMAGE_FORMAT_V2: v2Format{},
+}
+
+// RegisterImageFormat makes an image format available to targets under the
+// specified name.  Out-of-tree formats register themselves with this
+// function.
+func RegisterImageFormat(name string, f ImageFormat) {
+	imageFormats[name] = f
+}
+
+// ImageFormatNames retrieves the sorted names of all registered formats.
+func ImageFormatNames() []string {
+	names := make([]string, 0, len(imageFormats))
+	for name, _ := range imageFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// LookupImageFormat retrieves the format with the specified name.  An empty
+// name selects the default format.
+func LookupImageFormat(name string) (ImageFormat, error) {
+	if name == "" {
+		name = IMAGE_FORMAT_DFLT
+	}
+
+	f := imageFormats[name]
+	if f == nil {
+		return nil, util.FmtNewtError(
+			"unknown image format \"%s\"; valid formats are: %s",
+			name, strings.Join(ImageFormatNames(), ", "))
+	}
+
+	return f, nil
+}
+
+// The original Mynewt image format.
+type v1Format struct{}
+
+func (v1Format) Produce(t *builder.TargetBuilder,
+	opts ImageFormatOpts) error {
+
+	if len(opts.Deps) > 0 {
+		return util.NewNewtError(
+			"image dependencies require version 2 of the image format")
+	}
+
+	return ProduceAllV1(t, opts.Version, opts.SigKeys, opts.EncKeyFilename,
+		opts.EncKeyIndex, opts.HdrPad, opts.ImagePad, opts.Sections,
+		opts.UseLegacyTLV)
+}
+
+// The MCUboot image format.
+type v2Format struct{}
+
+func (v2Format) Produce(t *builder.TargetBuilder,
+	opts ImageFormatOpts) error {
+
+	return ProduceAll(t, opts.Version, opts.SigKeys, opts.EncKeyFilename,
+		opts.EncKeyIndex, opts.HdrPad, opts.ImagePad, opts.Sections,
+		opts.UseLegacyTLV, opts.Deps)
+}
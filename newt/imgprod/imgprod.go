@@ -53,8 +53,10 @@ type ImageProdOpts struct {
 	BaseAddr          int
 	HdrPad            int
 	ImagePad          int
+	EraseVal          byte
 	DummyC            *toolchain.Compiler
 	UseLegacyTLV      bool
+	Deps              []ImageDep
 }
 
 type ProducedImage struct {
@@ -75,6 +77,10 @@ type ProducedImageSet struct {
 func writeImageFiles(ri image.Image, imgFilename string, hexFilename string,
 	baseAddr int, c *toolchain.Compiler) error {
 
+	defer util.TrapInterrupts()()
+	untrack := util.TrackPartialOutput(imgFilename)
+	defer untrack()
+
 	imgFile, err := os.OpenFile(imgFilename,
 		os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
 	if err != nil {
@@ -85,6 +91,7 @@ func writeImageFiles(ri image.Image, imgFilename string, hexFilename string,
 	_, err = ri.Write(imgFile)
 	imgFile.Close()
 	if err != nil {
+		os.Remove(imgFilename)
 		return err
 	}
 
@@ -144,8 +151,21 @@ func produceLoader(opts ImageProdOpts) (ProducedImage, error) {
 func produceApp(opts ImageProdOpts, loaderHash []byte) (ProducedImage, error) {
 	pi := ProducedImage{}
 
+	// The image library pads the body with 0xff; pad it with the flash erase
+	// value instead.
+	srcFilename := opts.AppSrcFilename
+	if opts.ImagePad > 0 {
+		padded, err := padImageBody(srcFilename, opts.ImagePad, opts.EraseVal)
+		if err != nil {
+			return pi, err
+		}
+		defer os.Remove(padded)
+
+		srcFilename = padded
+	}
+
 	igo := image.ImageCreateOpts{
-		SrcBinFilename:    opts.AppSrcFilename,
+		SrcBinFilename:    srcFilename,
 		Sections:          opts.Sections,
 		SrcEncKeyFilename: opts.EncKeyFilename,
 		SrcEncKeyIndex:    opts.EncKeyIndex,
@@ -153,7 +173,6 @@ func produceApp(opts ImageProdOpts, loaderHash []byte) (ProducedImage, error) {
 		SigKeys:           opts.SigKeys,
 		LoaderHash:        loaderHash,
 		HdrPad:            opts.HdrPad,
-		ImagePad:          opts.ImagePad,
 		UseLegacyTLV:      opts.UseLegacyTLV,
 	}
 
@@ -162,6 +181,10 @@ func produceApp(opts ImageProdOpts, loaderHash []byte) (ProducedImage, error) {
 		return pi, err
 	}
 
+	if err := addDepTlvs(&ri, opts.Deps, loaderHash, opts.SigKeys); err != nil {
+		return pi, err
+	}
+
 	hash, err := ri.Hash()
 	if err != nil {
 		return pi, err
@@ -306,6 +329,7 @@ func OptsFromTgtBldr(b *builder.TargetBuilder, ver image.ImageVersion,
 		BaseAddr:       baseAddr,
 		HdrPad:         hdrPad,
 		ImagePad:       imagePad,
+		EraseVal:       b.BspPkg().FlashEraseVal,
 		Sections:       sections,
 		UseLegacyTLV:   useLegacyTLV,
 	}
@@ -321,7 +345,8 @@ func OptsFromTgtBldr(b *builder.TargetBuilder, ver image.ImageVersion,
 
 func ProduceAll(t *builder.TargetBuilder, ver image.ImageVersion,
 	sigKeys []sec.PrivSignKey, encKeyFilename string, encKeyIndex int,
-	hdrPad int, imagePad int, sectionString string, useLegacyTLV bool) error {
+	hdrPad int, imagePad int, sectionString string, useLegacyTLV bool,
+	deps []ImageDep) error {
 
 	elfPath := t.AppBuilder.AppElfPath()
 
@@ -373,6 +398,7 @@ func ProduceAll(t *builder.TargetBuilder, ver image.ImageVersion,
 	if err != nil {
 		return err
 	}
+	popts.Deps = deps
 
 	pset, err := ProduceImages(popts)
 	if err != nil {
@@ -397,5 +423,49 @@ func ProduceAll(t *builder.TargetBuilder, ver image.ImageVersion,
 		return err
 	}
 
+	if err := produceExtraApps(t, popts); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// produceExtraApps creates an image for each of the target's extra apps.  An
+// extra app's image is based at the start of its flash area and must fit
+// within it.
+func produceExtraApps(t *builder.TargetBuilder, opts ImageProdOpts) error {
+	for i, ea := range t.GetTarget().ExtraApps {
+		b := t.ExtraAppBuilders[i]
+		area := t.BspPkg().FlashMap.Areas[ea.FlashArea]
+
+		eopts := opts
+		eopts.AppSrcFilename = b.AppBinPath()
+		eopts.AppDstFilename = b.AppImgPath()
+		eopts.AppHexFilename = b.AppHexPath()
+		eopts.BaseAddr = area.Offset
+
+		// The sections and dependencies describe the main app's image.
+		eopts.Sections = nil
+		eopts.Deps = nil
+
+		pi, err := produceApp(eopts, nil)
+		if err != nil {
+			return err
+		}
+
+		// The end of the area is reserved for the boot trailer.
+		maxSize := area.Size - t.BootTrailerSize()
+		if overflow := pi.FileSize - maxSize; overflow > 0 {
+			msg := fmt.Sprintf("%s overflows %s by %d bytes "+
+				"(image=%d max=%d)", ea.Name, ea.FlashArea, overflow,
+				pi.FileSize, maxSize)
+			if !newtutil.NewtForce {
+				return util.NewNewtError(msg)
+			}
+			util.StatusMessage(util.VERBOSITY_QUIET,
+				"* Warning: %s (ignoring due to force flag)\n", msg)
+		}
+	}
+
 	return nil
 }
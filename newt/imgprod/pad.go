@@ -0,0 +1,183 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package imgprod
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/mynewt-artifact/flash"
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/util"
+)
+
+// Specifies how image files get padded after they are generated.  Padding is
+// appended after the image's TLVs, so it is not covered by the image hash or
+// signature.
+type ImagePadOpts struct {
+	// Pad each image file to a multiple of this many bytes (e.g., the flash
+	// erase sector size).  0 means no alignment.
+	Align int
+
+	// Pad each image file to the full size of its slot.
+	FillSlot bool
+}
+
+// Pads an image file with the flash erase value according to the specified
+// options.  The file cannot grow beyond `slotSize` bytes.
+func padImageFile(path string, opts ImagePadOpts, slotSize int,
+	eraseVal byte) (bool, error) {
+
+	img, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, util.ChildNewtError(err)
+	}
+
+	size := len(img)
+	if opts.Align > 0 && size%opts.Align != 0 {
+		size += opts.Align - size%opts.Align
+	}
+	if opts.FillSlot {
+		size = slotSize
+	}
+
+	if size > slotSize {
+		return false, util.FmtNewtError(
+			"cannot pad image %s to %d bytes; slot size is %d",
+			path, size, slotSize)
+	}
+	if size <= len(img) {
+		return false, nil
+	}
+
+	padded := make([]byte, size)
+	for i := len(img); i < size; i++ {
+		padded[i] = eraseVal
+	}
+	copy(padded, img)
+
+	if err := ioutil.WriteFile(path, padded, 0644); err != nil {
+		return false, util.ChildNewtError(err)
+	}
+
+	return true, nil
+}
+
+// Writes a copy of a binary with its body padded for `bsp.image_pad`: the
+// flash erase value is appended until the size is a multiple of `imagePad`
+// bytes, as the image library does with 0xff.  The padding is part of the
+// image body, so it is covered by the image hash.  It returns the path of
+// the copy, which the caller must remove.
+func padImageBody(binPath string, imagePad int, eraseVal byte) (
+	string, error) {
+
+	body, err := ioutil.ReadFile(binPath)
+	if err != nil {
+		return "", util.ChildNewtError(err)
+	}
+
+	tailPad := imagePad - len(body)%imagePad
+	body = append(body, bytes.Repeat([]byte{eraseVal}, tailPad)...)
+
+	f, err := ioutil.TempFile(filepath.Dir(binPath), "pad-*.bin")
+	if err != nil {
+		return "", util.ChildNewtError(err)
+	}
+	_, err = f.Write(body)
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", util.ChildNewtError(err)
+	}
+
+	return f.Name(), nil
+}
+
+// PadImages pads a target's already-generated image files and regenerates the
+// corresponding hex files.  The loader (if any) is padded to the size of slot
+// 0 and the app to the size of the slot it runs from.
+func PadImages(t *builder.TargetBuilder, opts ImagePadOpts) error {
+	if opts.Align <= 0 && !opts.FillSlot {
+		return nil
+	}
+
+	bsp := t.BspPkg()
+
+	type padImg struct {
+		imgPath  string
+		hexPath  string
+		areaName string
+	}
+
+	var imgs []padImg
+	appArea := flash.FLASH_AREA_NAME_IMAGE_0
+	if t.LoaderBuilder != nil {
+		imgs = append(imgs, padImg{
+			imgPath:  t.LoaderBuilder.AppImgPath(),
+			hexPath:  t.LoaderBuilder.AppHexPath(),
+			areaName: flash.FLASH_AREA_NAME_IMAGE_0,
+		})
+		appArea = flash.FLASH_AREA_NAME_IMAGE_1
+	}
+	imgs = append(imgs, padImg{
+		imgPath:  t.AppBuilder.AppImgPath(),
+		hexPath:  t.AppBuilder.AppHexPath(),
+		areaName: appArea,
+	})
+
+	// Hex files are based at the start of slot 0; see OptsFromTgtBldr().
+	img0Area := bsp.FlashMap.Areas[flash.FLASH_AREA_NAME_IMAGE_0]
+
+	c, err := t.NewCompiler("", "")
+	if err != nil {
+		return err
+	}
+
+	for _, img := range imgs {
+		area, ok := bsp.FlashMap.Areas[img.areaName]
+		if !ok {
+			return util.FmtNewtError(
+				"cannot pad image: BSP does not define flash area \"%s\"",
+				img.areaName)
+		}
+
+		padded, err := padImageFile(img.imgPath, opts, area.Size,
+			bsp.FlashEraseVal)
+		if err != nil {
+			return err
+		}
+		if !padded {
+			continue
+		}
+
+		if err := c.ConvertBinToHex(img.imgPath, img.hexPath,
+			img0Area.Offset); err != nil {
+
+			return err
+		}
+
+		util.StatusMessage(util.VERBOSITY_VERBOSE,
+			"Padded image %s\n", img.imgPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package imgprod
+
+import (
+	"io/ioutil"
+
+	"github.com/apache/mynewt-artifact/flash"
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/util"
+)
+
+// Alignment of the MCUboot trailer flag fields.
+const BOOT_MAX_ALIGN = 8
+
+// Value of a set MCUboot trailer flag (e.g., image_ok).
+const BOOT_FLAG_SET = 0x01
+
+// Magic value that terminates an initialized MCUboot trailer.
+var BootTrailerMagic = []byte{
+	0x77, 0xc2, 0x95, 0xf3,
+	0x60, 0xd2, 0xef, 0x7f,
+	0x35, 0x52, 0x50, 0x0f,
+	0x2c, 0xb6, 0x79, 0x80,
+}
+
+// BootTrailerTail returns the bytes that occupy the very end of an image slot
+// whose MCUboot trailer has been initialized as "confirmed":
+//
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|   Image OK    |  erase-val padding (BOOT_MAX_ALIGN - 1)       |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	~                       MAGIC (16 octets)                       ~
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// The rest of the trailer (swap status, copy done, etc.) is left erased.
+func BootTrailerTail(eraseVal byte) []byte {
+	tail := make([]byte, BOOT_MAX_ALIGN, BOOT_MAX_ALIGN+len(BootTrailerMagic))
+	for i := range tail {
+		tail[i] = eraseVal
+	}
+	tail[0] = BOOT_FLAG_SET
+
+	return append(tail, BootTrailerMagic...)
+}
+
+// ProduceConfirmedHex rewrites a target's app hex file such that it spans the
+// entire slot-0 flash area and ends with an initialized boot trailer.  A
+// device programmed with the resulting hex file boots the image as confirmed,
+// without performing a test swap first.  The `.img` file is not modified.
+// Split images are not supported: their app runs from slot 1, not from the
+// slot whose trailer MCUboot checks.
+func ProduceConfirmedHex(t *builder.TargetBuilder) error {
+	if t.GetTarget().Loader() != nil {
+		return util.FmtNewtError(
+			"cannot initialize boot trailer: target \"%s\" builds a split "+
+				"image", t.GetTarget().FullName())
+	}
+
+	bsp := t.BspPkg()
+
+	area, ok := bsp.FlashMap.Areas[flash.FLASH_AREA_NAME_IMAGE_0]
+	if !ok {
+		return util.FmtNewtError(
+			"cannot initialize boot trailer: BSP does not define flash "+
+				"area \"%s\"", flash.FLASH_AREA_NAME_IMAGE_0)
+	}
+
+	img, err := ioutil.ReadFile(t.AppBuilder.AppImgPath())
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	// The image must leave room for the entire boot trailer, not just the
+	// part that is initialized here; MCUboot writes the rest during a swap.
+	trailerSz := t.BootTrailerSize()
+	if len(img)+trailerSz > area.Size {
+		return util.FmtNewtError(
+			"cannot initialize boot trailer: image too large for slot; "+
+				"image=%d trailer=%d slot=%d", len(img), trailerSz, area.Size)
+	}
+
+	tail := BootTrailerTail(bsp.FlashEraseVal)
+
+	slot := make([]byte, area.Size)
+	for i := range slot {
+		slot[i] = bsp.FlashEraseVal
+	}
+	copy(slot, img)
+	copy(slot[area.Size-len(tail):], tail)
+
+	slotPath := t.AppBuilder.AppBinBasePath() + "_slot0.bin"
+	if err := ioutil.WriteFile(slotPath, slot, 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	c, err := t.NewCompiler("", "")
+	if err != nil {
+		return err
+	}
+
+	if err := c.ConvertBinToHex(slotPath, t.AppBuilder.AppHexPath(),
+		area.Offset); err != nil {
+
+		return err
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Confirmed slot-0 hex file generated: %s\n",
+		t.AppBuilder.AppHexPath())
+
+	return nil
+}
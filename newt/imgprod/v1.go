@@ -184,9 +184,27 @@ func verifyImgSizesV1(pset ProducedImageSetV1, maxSizes []int) error {
 	return nil
 }
 
+// Image format v1 predates Ed25519 support; its signature types only cover
+// RSA and ECDSA keys.
+func checkSigKeysV1(keys []sec.PrivSignKey) error {
+	for _, key := range keys {
+		if key.Ed25519 != nil {
+			return util.NewNewtError(
+				"image format v1 does not support Ed25519 signing keys; " +
+					"use image format v2 (-2)")
+		}
+	}
+
+	return nil
+}
+
 func ProduceImagesV1(opts ImageProdOpts) (ProducedImageSetV1, error) {
 	pset := ProducedImageSetV1{}
 
+	if err := checkSigKeysV1(opts.SigKeys); err != nil {
+		return pset, err
+	}
+
 	var loaderHash []byte
 	if opts.LoaderSrcFilename != "" {
 		pi, err := produceLoaderV1(opts)
@@ -0,0 +1,210 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package imgprod
+
+import (
+	"github.com/apache/mynewt-artifact/flash"
+	"github.com/apache/mynewt-artifact/image"
+	"github.com/apache/mynewt-artifact/sec"
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/util"
+)
+
+// The outcome of verifying an image file.
+type ImageVerifyResult struct {
+	Version image.ImageVersion
+	Size    int
+
+	// Encrypted images are hashed before encryption, so their hash cannot
+	// be verified without the decryption key.
+	Encrypted bool
+
+	// The hash of a split app image is seeded with the hash of its loader,
+	// so it can only be verified if the loader image is specified.
+	Split bool
+
+	HashVerified bool
+
+	NumSigs     int
+	SigVerified bool
+}
+
+// The outcome of checking whether an image fits in a target's slot.
+type ImageSlotFit struct {
+	AreaName string
+	SlotSize int
+
+	// The largest image the slot can hold: its size less the boot trailer.
+	MaxSize int
+	Fits    bool
+}
+
+func verifyProtTlvs(img image.Image) error {
+	for _, tlv := range img.ProtTlvs {
+		if tlv.Header.Type != IMAGE_TLV_DEPENDENCY &&
+			!image.ImageTlvTypeIsValid(tlv.Header.Type) {
+
+			return util.FmtNewtError(
+				"image contains protected TLV with invalid `type` field: %d",
+				tlv.Header.Type)
+		}
+	}
+
+	if len(img.ProtTlvs) > 0 {
+		protSz := int(img.ProtTrailer().TlvTotLen)
+		if int(img.Header.ProtSz) != protSz {
+			return util.FmtNewtError(
+				"image header protected TLV size incorrect: have=%d want=%d",
+				img.Header.ProtSz, protSz)
+		}
+	}
+
+	return nil
+}
+
+// Reads a split app's loader image and returns its hash after verifying it.
+func readLoaderHash(loaderPath string) ([]byte, error) {
+	loader, err := image.ReadImage(loaderPath)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	hash, _, err := newtutil.VerifyImageHash(loader, nil)
+	if err != nil {
+		return nil, util.FmtNewtError("loader image %s: %s",
+			loaderPath, err.Error())
+	}
+
+	return hash, nil
+}
+
+// VerifyImageFile checks an image file's structure and TLVs and recomputes its
+// hash.  If keys are specified, the image must be signed by one of them.  The
+// hash of a split app image (one without the bootable flag) is only verified
+// if its loader image is specified with loaderPath; it is not checked
+// otherwise.
+func VerifyImageFile(path string, loaderPath string,
+	keys []sec.PubSignKey) (ImageVerifyResult, error) {
+
+	res := ImageVerifyResult{}
+
+	img, err := image.ReadImage(path)
+	if err != nil {
+		return res, util.ChildNewtError(err)
+	}
+
+	res.Version = img.Header.Vers
+
+	size, err := img.TotalSize()
+	if err != nil {
+		return res, util.ChildNewtError(err)
+	}
+	res.Size = size
+
+	if err := img.VerifyStructure(); err != nil {
+		return res, util.ChildNewtError(err)
+	}
+	if err := verifyProtTlvs(img); err != nil {
+		return res, err
+	}
+
+	res.Encrypted = img.IsEncrypted()
+	res.Split = img.Header.Flags&image.IMAGE_F_NON_BOOTABLE != 0
+	if !res.Split || loaderPath != "" {
+		var loaderHash []byte
+		if res.Split {
+			loaderHash, err = readLoaderHash(loaderPath)
+			if err != nil {
+				return res, err
+			}
+		}
+
+		_, res.HashVerified, err = newtutil.VerifyImageHash(img, loaderHash)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	sigs, err := img.CollectSigs()
+	if err != nil {
+		return res, util.ChildNewtError(err)
+	}
+	res.NumSigs = len(sigs)
+
+	if len(keys) > 0 {
+		if len(sigs) == 0 {
+			return res, util.FmtNewtError("image %s is not signed", path)
+		}
+
+		hash, err := img.Hash()
+		if err != nil {
+			return res, util.ChildNewtError(err)
+		}
+
+		for _, key := range keys {
+			ok, err := newtutil.KeySignedHash(key, sigs, hash)
+			if err != nil {
+				return res, err
+			}
+			if ok {
+				res.SigVerified = true
+				break
+			}
+		}
+
+		if !res.SigVerified {
+			return res, util.FmtNewtError(
+				"image %s is not signed by any of the specified keys", path)
+		}
+	}
+
+	return res, nil
+}
+
+// CheckSlotFit determines whether an image of the specified size fits in the
+// slot that the target's app runs from, leaving room for the MCUboot trailer
+// at the end of the slot.  The app of a split image runs from slot 1;
+// otherwise it runs from slot 0.
+func CheckSlotFit(t *builder.TargetBuilder, size int) (ImageSlotFit, error) {
+	slot := 0
+	if t.GetTarget().Loader() != nil {
+		slot = 1
+	}
+
+	fit := ImageSlotFit{
+		AreaName: flash.FLASH_AREA_NAME_IMAGE_0,
+	}
+	if slot == 1 {
+		fit.AreaName = flash.FLASH_AREA_NAME_IMAGE_1
+	}
+
+	area, ok := t.BspPkg().FlashMap.Areas[fit.AreaName]
+	if !ok {
+		return fit, util.FmtNewtError(
+			"BSP does not define flash area \"%s\"", fit.AreaName)
+	}
+
+	fit.SlotSize = area.Size
+	fit.MaxSize = t.MaxImgSizes()[slot]
+	fit.Fits = size <= fit.MaxSize
+
+	return fit, nil
+}
@@ -0,0 +1,207 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/downloader"
+	"mynewt.apache.org/newt/newt/repo"
+	"mynewt.apache.org/newt/util"
+)
+
+// The subject of the merge commits that GitHub creates for pull requests.
+var mergePrRe = regexp.MustCompile(`^Merge pull request (#\d+) from `)
+
+// A commit that an upgrade brought into a repo.
+type ChangelogCommit struct {
+	Hash  string
+	Title string
+
+	// The repo-relative files that the commit changed.
+	Files []string
+
+	// The packages used by the project's targets that the commit changed.
+	// Populated by FlagUsedPkgs.
+	UsedPkgs []string
+}
+
+// The commits that an upgrade brought into a repo.
+type RepoChangelog struct {
+	RepoName string
+	RepoPath string
+	OldVer   string
+	NewVer   string
+	OldHash  string
+	NewHash  string
+	Commits  []ChangelogCommit
+}
+
+// Changelogs returns the commits that the upgrade brought into each repo.
+func (inst *Installer) Changelogs() []*RepoChangelog {
+	return inst.changelogs
+}
+
+// Returns the title of a commit: the subject, or, for a pull request merge
+// commit, the pull request number and title.
+func commitTitle(c downloader.LogCommit) string {
+	m := mergePrRe.FindStringSubmatch(c.Subject)
+	if m == nil || c.Body == "" {
+		return c.Subject
+	}
+
+	return m[1] + ": " + strings.SplitN(c.Body, "\n", 2)[0]
+}
+
+// Collects the commits between a repo's old and new commits.
+func newRepoChangelog(r *repo.Repo, oldVer string, oldHash string,
+	newVer string, newHash string) (*RepoChangelog, error) {
+
+	lcs, err := r.Log(oldHash, newHash)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &RepoChangelog{
+		RepoName: r.Name(),
+		RepoPath: r.Path(),
+		OldVer:   oldVer,
+		NewVer:   newVer,
+		OldHash:  oldHash,
+		NewHash:  newHash,
+	}
+
+	for _, lc := range lcs {
+		rc.Commits = append(rc.Commits, ChangelogCommit{
+			Hash:  lc.Hash,
+			Title: commitTitle(lc),
+			Files: lc.Files,
+		})
+	}
+
+	return rc, nil
+}
+
+// Determines which package contains the specified repo file.  It returns the
+// package's name if it is one of the specified packages, or "" if it is not.
+func usedPkgFor(repoPath string, file string,
+	pkgDirs map[string]string) string {
+
+	root := filepath.Clean(repoPath)
+	dir := filepath.Dir(filepath.Join(root, file))
+
+	for dir != root && strings.HasPrefix(dir, root) {
+		if name, ok := pkgDirs[dir]; ok {
+			return name
+		}
+
+		// The file belongs to some other package.
+		if util.NodeExist(filepath.Join(dir, "pkg.yml")) {
+			return ""
+		}
+
+		dir = filepath.Dir(dir)
+	}
+
+	return ""
+}
+
+// FlagUsedPkgs records which of the specified packages each commit changed.
+// The packages are specified as a map of package directory to package name;
+// typically, these are the packages that the project's targets use.
+func FlagUsedPkgs(rcs []*RepoChangelog, pkgDirs map[string]string) {
+	cleanDirs := map[string]string{}
+	for dir, name := range pkgDirs {
+		cleanDirs[filepath.Clean(dir)] = name
+	}
+
+	for _, rc := range rcs {
+		for i, _ := range rc.Commits {
+			c := &rc.Commits[i]
+
+			pkgs := map[string]struct{}{}
+			for _, f := range c.Files {
+				if name := usedPkgFor(rc.RepoPath, f, cleanDirs); name != "" {
+					pkgs[name] = struct{}{}
+				}
+			}
+
+			c.UsedPkgs = nil
+			for name, _ := range pkgs {
+				c.UsedPkgs = append(c.UsedPkgs, name)
+			}
+			sort.Strings(c.UsedPkgs)
+		}
+	}
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 10 {
+		return hash[:10]
+	}
+	return hash
+}
+
+// ChangelogText produces a human-readable changelog.  Commits that change a
+// flagged package (see FlagUsedPkgs) are marked with a "*", followed by the
+// names of the packages they change.
+func ChangelogText(rcs []*RepoChangelog) string {
+	buffer := bytes.Buffer{}
+
+	for i, rc := range rcs {
+		if i != 0 {
+			buffer.WriteString("\n")
+		}
+
+		numUsed := 0
+		for _, c := range rc.Commits {
+			if len(c.UsedPkgs) > 0 {
+				numUsed++
+			}
+		}
+
+		fmt.Fprintf(&buffer, "%s %s -> %s (%s..%s): %d commit(s)",
+			rc.RepoName, rc.OldVer, rc.NewVer,
+			shortHash(rc.OldHash), shortHash(rc.NewHash), len(rc.Commits))
+		if numUsed > 0 {
+			fmt.Fprintf(&buffer, ", %d affecting packages used by targets",
+				numUsed)
+		}
+		buffer.WriteString("\n")
+
+		for _, c := range rc.Commits {
+			mark := " "
+			if len(c.UsedPkgs) > 0 {
+				mark = "*"
+			}
+			fmt.Fprintf(&buffer, "  %s %s %s\n", mark, shortHash(c.Hash),
+				c.Title)
+			if len(c.UsedPkgs) > 0 {
+				fmt.Fprintf(&buffer, "               [%s]\n",
+					strings.Join(c.UsedPkgs, ", "))
+			}
+		}
+	}
+
+	return buffer.String()
+}
@@ -125,6 +125,9 @@ type Installer struct {
 
 	// Required versions of installed repos, as read from `project.yml`.
 	reqs deprepo.RequirementMap
+
+	// The commits that the upgrade brought into each repo.
+	changelogs []*RepoChangelog
 }
 
 func NewInstaller(repos deprepo.RepoMap,
@@ -432,7 +435,7 @@ func (inst *Installer) calcVersionMap(candidates []*repo.Repo) (
 
 	for _, r := range repoList {
 		for commit, _ := range r.CommitDepMap() {
-			commit, err := r.Downloader().LatestRc(r.Path(), commit)
+			commit, err := r.PolicyCommit(commit)
 			if err != nil {
 				return nil, err
 			}
@@ -515,6 +518,28 @@ func verifyNewtCompat(repos []*repo.Repo, vm deprepo.VersionMap) error {
 	return nil
 }
 
+// Removes the repos specified with `--exclude` from a version map.  An error
+// is returned if an excluded repo does not exist.
+func (inst *Installer) filterExcluded(
+	vm deprepo.VersionMap) (deprepo.VersionMap, error) {
+
+	for _, name := range newtutil.NewtExclude {
+		name = strings.TrimPrefix(name, "@")
+		if inst.repos[name] == nil {
+			return nil, util.FmtNewtError(
+				"cannot exclude unknown repo: %s", name)
+		}
+
+		if _, ok := vm[name]; ok {
+			util.StatusMessage(util.VERBOSITY_DEFAULT,
+				"Skipping %s, excluded\n", name)
+			delete(vm, name)
+		}
+	}
+
+	return vm, nil
+}
+
 // Installs or upgrades the specified set of repos.
 func (inst *Installer) Upgrade(candidates []*repo.Repo, force bool,
 	ask bool) error {
@@ -530,6 +555,12 @@ func (inst *Installer) Upgrade(candidates []*repo.Repo, force bool,
 		return err
 	}
 
+	// Leave excluded repos at their installed versions.
+	vm, err = inst.filterExcluded(vm)
+	if err != nil {
+		return err
+	}
+
 	// Notify the user of what install operations are about to happen, and
 	// prompt if the `-a` (ask) option was specified.
 	proceed, err := inst.installPrompt(vm, INSTALL_OP_UPGRADE, false, ask)
@@ -549,9 +580,30 @@ func (inst *Installer) Upgrade(candidates []*repo.Repo, force bool,
 		return err
 	}
 
+	// Clone and fetch the repos concurrently.  The checkouts below are quick
+	// and are performed one at a time.
+	err = newtutil.ForEachParallel(len(repos), func(i int) error {
+		return repos[i].Prefetch()
+	})
+	if err != nil {
+		return err
+	}
+
 	// Upgrade each repo in the version map.
-	for _, r := range repos {
+	for i, r := range repos {
 		destVer := vm[r.Name()]
+
+		progOp := "upgrade"
+		if inst.installedVer(r.Name()) == nil {
+			progOp = "install"
+		}
+		util.Progress(util.ProgressEvent{
+			Op:      progOp,
+			Phase:   "repo",
+			Package: r.Name(),
+			Percent: i * 100 / len(repos),
+		})
+
 		dirtyState, err := r.DirtyState()
 		if err != nil {
 			return err
@@ -568,12 +620,34 @@ func (inst *Installer) Upgrade(candidates []*repo.Repo, force bool,
 			}
 		}
 
+		// Remember the old commit so that the changes can be listed.
+		oldVer := inst.installedVer(r.Name())
+		var oldHash string
+		if oldVer != nil {
+			oldHash, _ = r.Downloader().CommitSha(r.Path(), "HEAD")
+		}
+
 		util.StatusMessage(util.VERBOSITY_DEFAULT,
 			"Upgrading %s to version %s\n", r.Name(), destVer.String())
 
 		if err := r.Upgrade(destVer); err != nil {
 			return err
 		}
+
+		if oldHash != "" {
+			newHash, err := r.Downloader().CommitSha(r.Path(), "HEAD")
+			if err == nil && newHash != oldHash {
+				rc, err := newRepoChangelog(r, oldVer.String(), oldHash,
+					destVer.String(), newHash)
+				if err != nil {
+					util.OneTimeWarning(
+						"unable to list the changes to repo %s: %s",
+						r.Name(), err.Error())
+				} else {
+					inst.changelogs = append(inst.changelogs, rc)
+				}
+			}
+		}
 	}
 
 	for _, r := range candidates {
@@ -586,6 +660,12 @@ func (inst *Installer) Upgrade(candidates []*repo.Repo, force bool,
 		}
 	}
 
+	util.Progress(util.ProgressEvent{
+		Op:      "upgrade",
+		Phase:   "done",
+		Percent: 100,
+	})
+
 	return nil
 }
 
@@ -0,0 +1,146 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// ----------------------------------------------------------------------------
+// install: Handles project upgrades.
+package install
+
+import (
+	"fmt"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/repo"
+	"mynewt.apache.org/newt/util"
+)
+
+// Describes the releases of a repo that are newer than the version that
+// `project.yml` pins it to.
+type outdatedInfo struct {
+	// The version that project.yml requires, in x.x.x form.
+	pinned newtutil.RepoVersion
+
+	// nil if not installed.
+	installed *newtutil.RepoVersion
+
+	// The newest release with the same major number as the pinned version;
+	// nil if the pinned version is the newest such release.
+	compatible *newtutil.RepoVersion
+
+	// The newest release; nil if the pinned version is the newest release.
+	latest *newtutil.RepoVersion
+}
+
+// Indicates whether a version is an official release, as opposed to a commit
+// or the "latest develop" version (0.0.0).
+func isRelease(ver newtutil.RepoVersion) bool {
+	return ver.Commit == "" &&
+		!(ver.Major == 0 && ver.Minor == 0 && ver.Revision == 0)
+}
+
+// Determines whether the specified repo has releases newer than its
+// `project.yml` version.  It returns nil if the repo is up to date, or if the
+// repo is pinned to a commit or to the latest develop version.
+func (inst *Installer) gatherOutdated(r *repo.Repo) (*outdatedInfo, error) {
+	req, ok := inst.reqs[r.Name()]
+	if !ok || req.Commit != "" {
+		return nil, nil
+	}
+
+	pinned, err := r.NormalizeVersion(req)
+	if err != nil {
+		return nil, err
+	}
+	if !isRelease(pinned) {
+		return nil, nil
+	}
+
+	vers, err := r.NormalizedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	oi := &outdatedInfo{
+		pinned:    pinned,
+		installed: inst.installedVer(r.Name()),
+	}
+
+	for _, v := range newtutil.SortedVersionsDesc(vers) {
+		v := v
+		if !isRelease(v) || newtutil.CompareRepoVersions(v, pinned) <= 0 {
+			continue
+		}
+
+		if oi.latest == nil {
+			oi.latest = &v
+		}
+		if oi.compatible == nil && v.Major == pinned.Major {
+			oi.compatible = &v
+		}
+	}
+
+	if oi.latest == nil {
+		return nil, nil
+	}
+
+	return oi, nil
+}
+
+// Reports which of the specified repos have releases newer than the versions
+// that `project.yml` pins them to.  The caller must have downloaded the latest
+// `repository.yml` file for each repo.  Nothing is modified.
+func (inst *Installer) Outdated(repos []*repo.Repo) error {
+	var lines []string
+
+	for _, r := range repos {
+		if r.IsLocal() || r.IsExternal(r.Path()) {
+			continue
+		}
+
+		oi, err := inst.gatherOutdated(r)
+		if err != nil {
+			return err
+		}
+		if oi == nil {
+			continue
+		}
+
+		s := fmt.Sprintf("    * %s: pinned %s", r.Name(), oi.pinned.String())
+		if oi.installed == nil {
+			s += " (not installed)"
+		} else {
+			s += fmt.Sprintf(" (installed %s)", oi.installed.String())
+		}
+		if oi.compatible != nil {
+			s += fmt.Sprintf("; newer compatible: %s", oi.compatible.String())
+		}
+		s += fmt.Sprintf("; latest: %s", oi.latest.String())
+
+		lines = append(lines, s)
+	}
+
+	if len(lines) == 0 {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "All repos are up to date\n")
+		return nil
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Outdated repos:\n")
+	for _, s := range lines {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s\n", s)
+	}
+
+	return nil
+}
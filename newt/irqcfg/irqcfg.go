@@ -0,0 +1,355 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package irqcfg cross-references a target's interrupt vector definitions
+// with its interrupt priority settings.
+package irqcfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/syscfg"
+	"mynewt.apache.org/newt/newt/val"
+	"mynewt.apache.org/newt/util"
+)
+
+type Irq struct {
+	// Vector name; equal to the name of the YAML map that defines the
+	// interrupt.
+	Vector string
+
+	// The package that defines the interrupt.
+	Source *pkg.LocalPackage
+
+	// Name of the function that services the interrupt.
+	Handler string
+
+	// The priority assigned to this interrupt.
+	Priority val.ValSetting
+}
+
+// The interrupt configuration of the target.
+type ICfg struct {
+	// [vector-name] => interrupt
+	Irqs map[string]Irq
+
+	// Interrupt priority settings that are not referenced by any vector.
+	//     [setting-name] => priority
+	Unused map[string]val.ValSetting
+
+	// Number of priority bits implemented by the MCU's interrupt controller.
+	// 0 if the BSP does not specify this.
+	PrioBits int
+
+	// Strings describing errors encountered while parsing the interrupt
+	// config.
+	InvalidSettings []string
+
+	// Interrupts whose priority cannot be represented by the interrupt
+	// controller.
+	OutOfRange []Irq
+
+	// Contains sets of interrupts with identical priorities.
+	//     [priority] => <slice-of-irqs-with-priority>
+	PrioConflicts map[int][]Irq
+}
+
+func NewICfg() ICfg {
+	return ICfg{
+		Irqs:          map[string]Irq{},
+		Unused:        map[string]val.ValSetting{},
+		PrioConflicts: map[int][]Irq{},
+	}
+}
+
+// Parses a single interrupt definition from a YAML map.  The `irqMapItf`
+// parameter should be a map with the following elements:
+//
+//	"handler": <handler-function-name>
+//	"priority": <priority-string>
+func parseOneIrq(vector string, lpkg *pkg.LocalPackage, irqMapItf interface{},
+	cfg *syscfg.Cfg) (Irq, error) {
+
+	irq := Irq{
+		Vector: vector,
+		Source: lpkg,
+	}
+
+	irqMap := cast.ToStringMapString(irqMapItf)
+	if irqMap == nil {
+		return irq, util.FmtNewtError(
+			"\"%s\" missing required field \"priority\"", vector)
+	}
+
+	irq.Handler = irqMap["handler"]
+	if irq.Handler == "" {
+		return irq, util.FmtNewtError(
+			"\"%s\" missing required field \"handler\"", vector)
+	}
+
+	prioStr := irqMap["priority"]
+	if prioStr == "" {
+		return irq, util.FmtNewtError(
+			"\"%s\" missing required field \"priority\"", vector)
+	}
+	prio, err := val.ResolveValSetting(prioStr, cfg)
+	if err != nil {
+		return irq, util.FmtNewtError(
+			"\"%s\" contains invalid \"priority\": %s",
+			vector, err.Error())
+	}
+	if _, err := prio.IntVal(); err != nil {
+		return irq, util.FmtNewtError(
+			"\"%s\" contains invalid \"priority\": %s", vector, err.Error())
+	}
+	if prio.RefName != "" {
+		entry := cfg.Settings[prio.RefName]
+		if entry.SettingType != syscfg.CFG_SETTING_TYPE_INTERRUPT_PRIO {
+			return irq, util.FmtNewtError(
+				"\"%s\" priority references setting %s which is not of "+
+					"type interrupt_priority", vector, prio.RefName)
+		}
+	}
+
+	irq.Priority = prio
+
+	return irq, nil
+}
+
+// Reads all the interrupts defined by the specified package.  The interrupt
+// definitions are read from the `syscfg.interrupts` map in the package's
+// `syscfg.yml` file.
+func (icfg *ICfg) readOnePkg(lpkg *pkg.LocalPackage, cfg *syscfg.Cfg) {
+	lsettings := cfg.AllSettingsForLpkg(lpkg)
+	irqMaps, err := lpkg.SyscfgY.GetValStringMap("syscfg.interrupts",
+		lsettings)
+	util.OneTimeWarningError(err)
+
+	for vector, irqMapItf := range irqMaps {
+		irq, err := parseOneIrq(vector, lpkg, irqMapItf, cfg)
+		if err != nil {
+			icfg.InvalidSettings =
+				append(icfg.InvalidSettings, strings.TrimSpace(err.Error()))
+		} else if other, ok := icfg.Irqs[vector]; ok {
+			icfg.InvalidSettings = append(icfg.InvalidSettings,
+				fmt.Sprintf("\"%s\" defined by multiple packages: %s, %s",
+					vector, other.Source.FullName(), lpkg.FullName()))
+		} else {
+			icfg.Irqs[vector] = irq
+		}
+	}
+}
+
+// Reads the number of implemented priority bits from the BSP's
+// `bsp.nvic_prio_bits` setting.
+func (icfg *ICfg) readPrioBits(bsp *pkg.BspPackage, cfg *syscfg.Cfg) {
+	if bsp == nil {
+		return
+	}
+
+	bitsStr, err := bsp.BspV.GetValString("bsp.nvic_prio_bits",
+		cfg.AllSettingsForLpkg(bsp.LocalPackage))
+	util.OneTimeWarningError(err)
+	if bitsStr == "" {
+		return
+	}
+
+	bits, err := val.ResolveValSetting(bitsStr, cfg)
+	if err != nil {
+		icfg.InvalidSettings = append(icfg.InvalidSettings,
+			fmt.Sprintf("bsp.nvic_prio_bits: %s", err.Error()))
+		return
+	}
+
+	n, err := bits.IntVal()
+	if err != nil || n <= 0 || n > 8 {
+		icfg.InvalidSettings = append(icfg.InvalidSettings,
+			fmt.Sprintf("bsp.nvic_prio_bits: invalid value \"%s\"",
+				bits.Value))
+		return
+	}
+
+	icfg.PrioBits = n
+}
+
+// Collects interrupt priority settings that no vector refers to.
+func (icfg *ICfg) detectUnused(cfg *syscfg.Cfg) {
+	used := map[string]struct{}{}
+	for _, irq := range icfg.Irqs {
+		if irq.Priority.RefName != "" {
+			used[irq.Priority.RefName] = struct{}{}
+		}
+	}
+
+	for name, entry := range cfg.Settings {
+		if entry.SettingType != syscfg.CFG_SETTING_TYPE_INTERRUPT_PRIO {
+			continue
+		}
+		if _, ok := used[name]; ok {
+			continue
+		}
+
+		prio := val.ValSetting{
+			Text:    name,
+			RefName: name,
+			Value:   entry.Value,
+		}
+		if _, err := prio.IntVal(); err != nil {
+			icfg.InvalidSettings = append(icfg.InvalidSettings,
+				fmt.Sprintf("invalid priority value: setting=%s value=%s",
+					name, entry.Value))
+			continue
+		}
+
+		if !icfg.inRange(prio) {
+			icfg.InvalidSettings = append(icfg.InvalidSettings,
+				fmt.Sprintf("priority value out of range: setting=%s "+
+					"value=%s max=%d", name, entry.Value, icfg.MaxPrio()))
+		}
+
+		icfg.Unused[name] = prio
+	}
+}
+
+// Indicates whether the interrupt controller can represent the specified
+// priority.  If the number of priority bits is unknown, only negative
+// priorities are rejected.
+func (icfg *ICfg) inRange(prio val.ValSetting) bool {
+	p, _ := prio.IntVal()
+	if p < 0 {
+		return false
+	}
+
+	return icfg.PrioBits == 0 || p <= icfg.MaxPrio()
+}
+
+// Searches the interrupt configuration for priorities that exceed the range
+// of the interrupt controller and for interrupts with identical priorities.
+func (icfg *ICfg) detectProblems() {
+	m := map[int][]Irq{}
+
+	for _, irq := range icfg.SortedIrqs() {
+		prio, _ := irq.Priority.IntVal()
+
+		if !icfg.inRange(irq.Priority) {
+			icfg.OutOfRange = append(icfg.OutOfRange, irq)
+		}
+
+		m[prio] = append(m[prio], irq)
+	}
+
+	for prio, irqs := range m {
+		if len(irqs) > 1 {
+			icfg.PrioConflicts[prio] = irqs
+		}
+	}
+}
+
+// Reads all interrupt definitions for each of the specified packages and
+// checks them against the priority range of the BSP's interrupt controller.
+func Read(lpkgs []*pkg.LocalPackage, bsp *pkg.BspPackage,
+	cfg *syscfg.Cfg) ICfg {
+
+	icfg := NewICfg()
+
+	for _, lpkg := range lpkgs {
+		icfg.readOnePkg(lpkg, cfg)
+	}
+
+	icfg.readPrioBits(bsp, cfg)
+	icfg.detectUnused(cfg)
+	icfg.detectProblems()
+
+	return icfg
+}
+
+// Returns the largest priority value that the interrupt controller can
+// represent, or -1 if this is unknown.
+func (icfg *ICfg) MaxPrio() int {
+	if icfg.PrioBits == 0 {
+		return -1
+	}
+
+	return 1<<uint(icfg.PrioBits) - 1
+}
+
+// Retrieves a slice of interrupts sorted by priority, then by vector name.
+func (icfg *ICfg) SortedIrqs() []Irq {
+	irqs := make([]Irq, 0, len(icfg.Irqs))
+	for _, irq := range icfg.Irqs {
+		irqs = append(irqs, irq)
+	}
+
+	sort.Slice(irqs, func(i int, j int) bool {
+		pi, _ := irqs[i].Priority.IntVal()
+		pj, _ := irqs[j].Priority.IntVal()
+		if pi != pj {
+			return pi < pj
+		}
+		return irqs[i].Vector < irqs[j].Vector
+	})
+
+	return irqs
+}
+
+// If any problems were detected in the interrupt configuration, this
+// function returns a string describing them.  Otherwise, "" is returned.
+func (icfg *ICfg) ErrorText() string {
+	str := ""
+
+	if len(icfg.InvalidSettings) > 0 {
+		str += "Invalid interrupt definitions detected:"
+		for _, e := range icfg.InvalidSettings {
+			str += "\n    " + e
+		}
+		str += "\n"
+	}
+
+	if len(icfg.OutOfRange) > 0 {
+		str += fmt.Sprintf("Interrupt priorities out of range "+
+			"(%d priority bits; max=%d):\n", icfg.PrioBits, icfg.MaxPrio())
+		for _, irq := range icfg.OutOfRange {
+			str += fmt.Sprintf("    Priority=%s Vector=%s Package=%s\n",
+				irq.Priority.Value, irq.Vector, irq.Source.FullName())
+		}
+	}
+
+	if len(icfg.PrioConflicts) > 0 {
+		prios := make([]int, 0, len(icfg.PrioConflicts))
+		for prio, _ := range icfg.PrioConflicts {
+			prios = append(prios, prio)
+		}
+		sort.Ints(prios)
+
+		str += "Interrupt priority conflicts detected:\n"
+		for _, prio := range prios {
+			for _, irq := range icfg.PrioConflicts[prio] {
+				str += fmt.Sprintf("    Priority=%d Vector=%s Package=%s\n",
+					prio, irq.Vector, irq.Source.FullName())
+			}
+		}
+	}
+
+	return str
+}
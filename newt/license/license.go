@@ -0,0 +1,253 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// license - Audits the license headers of package source files.
+
+package license
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
+)
+
+// Identifier reported for source files without a recognizable license header.
+const LICENSE_NONE = "NONE"
+
+// Only the start of each file is searched for a license header.
+const headerMaxLines = 60
+
+var spdxRe = regexp.MustCompile(`SPDX-License-Identifier:\s*([^*/]+)`)
+
+// Well-known license texts, for files that lack an SPDX identifier.  Order
+// matters: the first match wins.
+var licenseTexts = []struct {
+	id   string
+	text string
+}{
+	{"Apache-2.0", "Licensed to the Apache Software Foundation"},
+	{"Apache-2.0", "Apache License, Version 2.0"},
+	{"LGPL-2.1", "GNU Lesser General Public License"},
+	{"GPL-2.0", "GNU General Public License"},
+	{"MIT", "Permission is hereby granted, free of charge"},
+	{"BSD-3-Clause", "Neither the name of"},
+	{"BSD-2-Clause", "Redistribution and use in source and binary forms"},
+	{"ISC", "Permission to use, copy, modify, and/or distribute this software"},
+	{"Zlib", "This software is provided 'as-is', without any express"},
+}
+
+// Permissive licenses may appear in files of any package, regardless of the
+// license the package declares, except where listed in `incompatible`.
+var permissive = map[string]bool{
+	"0BSD":         true,
+	"Apache-2.0":   true,
+	"BSD-2-Clause": true,
+	"BSD-3-Clause": true,
+	"ISC":          true,
+	"MIT":          true,
+	"Zlib":         true,
+}
+
+// Licenses that cannot be combined: [file-license] => declared licenses that a
+// file with that license cannot be part of.  The Apache License 2.0 is
+// incompatible with version 2 (and 1) of the GPL; the "or later" variants are
+// compatible through GPL version 3.
+var incompatible = map[string]map[string]bool{
+	"Apache-2.0": {
+		"GPL-1.0":      true,
+		"GPL-1.0-only": true,
+		"GPL-2.0":      true,
+		"GPL-2.0-only": true,
+	},
+}
+
+var srcExts = map[string]struct{}{
+	".c":   struct{}{},
+	".h":   struct{}{},
+	".cc":  struct{}{},
+	".cpp": struct{}{},
+	".hpp": struct{}{},
+	".s":   struct{}{},
+	".ld":  struct{}{},
+}
+
+// The license findings for a single package.
+type PkgReport struct {
+	Lpkg *pkg.LocalPackage
+
+	// The license expression declared in the package's pkg.yml file.
+	Declared string
+
+	// [license-id] => source files carrying that license.
+	Files map[string][]string
+
+	// Files whose license is incompatible with the declared license.
+	Conflicts []string
+}
+
+// DetectFile reads the header of the specified source file and returns the
+// identifier of its license, or LICENSE_NONE if no license is recognized.
+func DetectFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", util.ChildNewtError(err)
+	}
+	defer f.Close()
+
+	header := ""
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < headerMaxLines && scanner.Scan(); i++ {
+		line := scanner.Text()
+		if m := spdxRe.FindStringSubmatch(line); m != nil {
+			return strings.TrimSpace(m[1]), nil
+		}
+		header += " " + strings.TrimSpace(strings.TrimLeft(
+			strings.TrimSpace(line), "/*#;"))
+	}
+
+	for _, lt := range licenseTexts {
+		if strings.Contains(header, lt.text) {
+			return lt.id, nil
+		}
+	}
+
+	return LICENSE_NONE, nil
+}
+
+// Splits an SPDX license expression into its component identifiers.
+func exprIds(expr string) []string {
+	var ids []string
+
+	expr = strings.NewReplacer("(", " ", ")", " ").Replace(expr)
+	for _, tok := range strings.Fields(expr) {
+		switch strings.ToUpper(tok) {
+		case "AND", "OR", "WITH":
+		default:
+			ids = append(ids, tok)
+		}
+	}
+
+	return ids
+}
+
+// Indicates whether a file carrying the specified license can be part of a
+// package that declares the specified license expression.
+func compatible(fileLicense string, declared string) bool {
+	declIds := map[string]bool{}
+	for _, id := range exprIds(declared) {
+		declIds[id] = true
+	}
+
+	for _, id := range exprIds(fileLicense) {
+		if !declIds[id] && !permissive[id] {
+			return false
+		}
+
+		for declId, _ := range declIds {
+			if incompatible[id][declId] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func sourceFiles(lpkg *pkg.LocalPackage) ([]string, error) {
+	basePath := lpkg.BasePath()
+
+	var files []string
+	err := filepath.Walk(basePath,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() {
+				// Nested packages are audited separately.
+				if path != basePath &&
+					util.NodeExist(path+"/"+pkg.PACKAGE_FILE_NAME) {
+
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			ext := strings.ToLower(filepath.Ext(path))
+			if _, ok := srcExts[ext]; ok {
+				files = append(files, path)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// AuditPkg inspects every source file in the specified package.
+func AuditPkg(lpkg *pkg.LocalPackage) (PkgReport, error) {
+	report := PkgReport{
+		Lpkg:     lpkg,
+		Declared: lpkg.Desc().License,
+		Files:    map[string][]string{},
+	}
+
+	files, err := sourceFiles(lpkg)
+	if err != nil {
+		return report, err
+	}
+
+	for _, file := range files {
+		lic, err := DetectFile(file)
+		if err != nil {
+			return report, err
+		}
+
+		report.Files[lic] = append(report.Files[lic], file)
+
+		if lic != LICENSE_NONE && report.Declared != "" &&
+			!compatible(lic, report.Declared) {
+
+			report.Conflicts = append(report.Conflicts, file)
+		}
+	}
+
+	return report, nil
+}
+
+// Licenses returns the sorted set of license identifiers found in the
+// package's source files.
+func (r *PkgReport) Licenses() []string {
+	lics := make([]string, 0, len(r.Files))
+	for lic, _ := range r.Files {
+		lics = append(lics, lic)
+	}
+	sort.Strings(lics)
+
+	return lics
+}
@@ -23,13 +23,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cast"
 
 	"mynewt.apache.org/newt/newt/newtutil"
@@ -355,36 +351,10 @@ func (lcfg *LCfg) EnsureWritten(includeDir string, srcDir string, targetName str
 	lcfg.writeSource(&srcBuf)
 
 	path := includeDir + "/" + HEADER_PATH
-
-	writeReqd, err := util.FileContentsChanged(path, buf.Bytes())
-	if err != nil {
+	if err := util.WriteGeneratedFile(path, buf.Bytes()); err != nil {
 		return err
 	}
-	if writeReqd {
-		log.Debugf("logcfg changed; writing header file (%s).", path)
-
-		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-			return util.NewNewtError(err.Error())
-		}
-
-		if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
-			return util.NewNewtError(err.Error())
-		}
-	} else {
-		log.Debugf("logcfg unchanged; not writing header file (%s).", path)
-	}
 
 	path = fmt.Sprintf("%s/%s-logcfg.c", srcDir, targetName)
-	writeReqd, err = util.FileContentsChanged(path, srcBuf.Bytes())
-
-	if writeReqd {
-		log.Debugf("logcfg changed; writing source file (%s).", path)
-		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-			return util.NewNewtError(err.Error())
-		}
-		if err := os.WriteFile(path, srcBuf.Bytes(), 0644); err != nil {
-			return util.NewNewtError(err.Error())
-		}
-	}
-	return nil
+	return util.WriteGeneratedFile(path, srcBuf.Bytes())
 }
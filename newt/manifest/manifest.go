@@ -227,8 +227,15 @@ func ManifestPkgSizes(b *builder.Builder) (ManifestSizeCollector, error) {
 		}
 		sort.Sort(symbols)
 		for _, sym := range symbols {
-			for area, areaSz := range sym.Sizes {
-				if areaSz != 0 {
+			// Order areas by name.
+			areas := make([]string, 0, len(sym.Sizes))
+			for area, _ := range sym.Sizes {
+				areas = append(areas, area)
+			}
+			sort.Strings(areas)
+
+			for _, area := range areas {
+				if areaSz := sym.Sizes[area]; areaSz != 0 {
 					AddSymbol(p, sym.ObjName, sym.Name, area, areaSz)
 				}
 			}
@@ -307,6 +314,22 @@ func CreateManifest(opts ManifestCreateOpts) (manifest.Manifest, error) {
 		m.TgtVars = append(m.TgtVars, tgtSyscfg)
 	}
 
+	// The definitions are read from the build output rather than from the
+	// current command, which may not have been given them (e.g.,
+	// `newt create-image`).
+	defs, err := t.BuiltDefines()
+	if err != nil {
+		return m, err
+	}
+	if len(defs) > 0 {
+		m.TgtVars = append(m.TgtVars,
+			"build.defines="+strings.Join(defs, " "))
+	}
+
+	if mode := t.Instrument(); mode != "" {
+		m.TgtVars = append(m.TgtVars, "build.instrument="+mode)
+	}
+
 	c, err := ManifestPkgSizes(t.AppBuilder)
 	if err == nil {
 		m.PkgSizes = c.Pkgs
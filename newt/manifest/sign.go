@@ -0,0 +1,290 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/apache/mynewt-artifact/image"
+	"github.com/apache/mynewt-artifact/manifest"
+	"github.com/apache/mynewt-artifact/sec"
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/util"
+)
+
+// A manifest's detached signature is written to a file with the manifest's
+// name plus this suffix (e.g., "manifest.json.sig").
+const SIG_FILE_SUFFIX = ".sig"
+
+// One signature in a detached signature file.
+type ManifestSig struct {
+	Type    string `json:"type"`
+	KeyHash string `json:"key_hash"`
+	Sig     string `json:"sig"`
+}
+
+// The contents of a detached signature file.  Each signature covers the
+// SHA256 of the manifest file's exact contents.
+type ManifestSigFile struct {
+	Sigs []ManifestSig `json:"signatures"`
+}
+
+func SigPath(manifestPath string) string {
+	return manifestPath + SIG_FILE_SUFFIX
+}
+
+func hashFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// SignFile signs a manifest file with each of the specified keys and writes
+// the signatures to the detached signature file.
+func SignFile(manifestPath string, keys []sec.PrivSignKey) error {
+	hash, err := hashFile(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	sf := ManifestSigFile{}
+	for _, key := range keys {
+		sig, err := image.GenerateSig(key, hash)
+		if err != nil {
+			return util.ChildNewtError(err)
+		}
+
+		sf.Sigs = append(sf.Sigs, ManifestSig{
+			Type:    sec.SigTypeString(sig.Type),
+			KeyHash: hex.EncodeToString(sig.KeyHash),
+			Sig:     base64.StdEncoding.EncodeToString(sig.Data),
+		})
+	}
+
+	data, err := json.MarshalIndent(sf, "", "  ")
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	if err := ioutil.WriteFile(SigPath(manifestPath), data,
+		0644); err != nil {
+
+		return util.ChildNewtError(err)
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Manifest signature successfully generated: %s\n",
+		SigPath(manifestPath))
+
+	return nil
+}
+
+func readSigFile(manifestPath string) ([]sec.Sig, error) {
+	path := SigPath(manifestPath)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, util.FmtNewtError(
+			"failed to read manifest signature: %s", err.Error())
+	}
+
+	sf := ManifestSigFile{}
+	if err := json.Unmarshal(data, &sf); err != nil {
+		return nil, util.FmtNewtError(
+			"invalid manifest signature file \"%s\": %s", path, err.Error())
+	}
+
+	var sigs []sec.Sig
+	for _, ms := range sf.Sigs {
+		typ, err := sec.SigStringType(ms.Type)
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"invalid manifest signature file \"%s\": %s",
+				path, err.Error())
+		}
+
+		keyHash, err := hex.DecodeString(ms.KeyHash)
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"invalid manifest signature file \"%s\": bad key hash",
+				path)
+		}
+
+		sigData, err := base64.StdEncoding.DecodeString(ms.Sig)
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"invalid manifest signature file \"%s\": bad signature",
+				path)
+		}
+
+		sigs = append(sigs, sec.Sig{
+			Type:    typ,
+			KeyHash: keyHash,
+			Data:    sigData,
+		})
+	}
+
+	return sigs, nil
+}
+
+// VerifySig verifies a manifest's detached signature.  Verification succeeds
+// if the manifest was signed by at least one of the specified keys.
+func VerifySig(manifestPath string, keys []sec.PubSignKey) error {
+	sigs, err := readSigFile(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	hash, err := hashFile(manifestPath)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		ok, err := newtutil.KeySignedHash(key, sigs, hash)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return util.FmtNewtError(
+		"manifest %s is not signed by any of the specified keys", manifestPath)
+}
+
+// Verifies that the image file that a manifest refers to has the hash that the
+// manifest records.  The hash is recalculated from the image contents and
+// compared against both the manifest and the image's own hash TLV.  The hash
+// of a split app image is seeded with the hash of its loader (initialHash);
+// initialHash is nil for other images.  The hash of an encrypted image cannot
+// be recalculated; only its hash TLV is compared against the manifest.
+// Relative image paths are resolved against the manifest's directory if the
+// path does not exist as given.
+func verifyImageHash(manifestPath string, what string, imgPath string,
+	wantHash string, initialHash []byte) error {
+
+	if util.NodeNotExist(imgPath) && !filepath.IsAbs(imgPath) {
+		alt := filepath.Join(filepath.Dir(manifestPath),
+			filepath.Base(imgPath))
+		if util.NodeExist(alt) {
+			imgPath = alt
+		}
+	}
+
+	img, err := image.ReadImage(imgPath)
+	if err != nil {
+		return util.FmtNewtError("failed to read %s image: %s",
+			what, err.Error())
+	}
+
+	hash, _, err := newtutil.VerifyImageHash(img, initialHash)
+	if err != nil {
+		return util.FmtNewtError("%s image %s: %s",
+			what, imgPath, err.Error())
+	}
+
+	if hex.EncodeToString(hash) != wantHash {
+		return util.FmtNewtError(
+			"%s image hash mismatch: manifest=%s file(%s)=%x",
+			what, wantHash, imgPath, hash)
+	}
+
+	return nil
+}
+
+// VerifyImageHashes verifies that the image files that a manifest refers to
+// are present and match the hashes recorded in the manifest.  It returns the
+// number of images checked.
+func VerifyImageHashes(manifestPath string) (int, error) {
+	m, err := manifest.ReadManifest(manifestPath)
+	if err != nil {
+		return 0, util.ChildNewtError(err)
+	}
+
+	// An "imageless" manifest (produced by `newt build`) records an empty
+	// hash.
+	type imgRef struct {
+		what        string
+		path        string
+		hash        string
+		initialHash []byte
+	}
+
+	// The hash of a split app image is seeded with the loader's hash.
+	var loaderHash []byte
+	if m.LoaderHash != "" {
+		loaderHash, err = hex.DecodeString(m.LoaderHash)
+		if err != nil {
+			return 0, util.FmtNewtError(
+				"manifest %s contains invalid loader hash: %s",
+				manifestPath, m.LoaderHash)
+		}
+	}
+
+	refs := []imgRef{
+		{"app", m.Image, m.ImageHash, loaderHash},
+		{"loader", m.Loader, m.LoaderHash, nil},
+	}
+
+	count := 0
+	for _, r := range refs {
+		if r.path == "" || r.hash == "" {
+			continue
+		}
+
+		if err := verifyImageHash(manifestPath, r.what, r.path,
+			r.hash, r.initialHash); err != nil {
+
+			return count, err
+		}
+		count++
+	}
+
+	if count == 0 {
+		return 0, util.FmtNewtError(
+			"manifest %s does not refer to any images", manifestPath)
+	}
+
+	return count, nil
+}
+
+// Describes a manifest verification result.
+func VerifyDesc(manifestPath string, numImgs int, sigChecked bool) string {
+	s := fmt.Sprintf("%s: %d image hash(es) OK", manifestPath, numImgs)
+	if sigChecked {
+		s += "; signature OK"
+	} else {
+		s += "; signature not checked"
+	}
+
+	return s
+}
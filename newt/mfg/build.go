@@ -33,8 +33,8 @@ import (
 	"github.com/apache/mynewt-artifact/image"
 	"github.com/apache/mynewt-artifact/manifest"
 	"github.com/apache/mynewt-artifact/mfg"
-	"mynewt.apache.org/newt/newt/builder"
 	"mynewt.apache.org/newt/newt/flashmap"
+	"mynewt.apache.org/newt/newt/imgprod"
 	"mynewt.apache.org/newt/newt/parse"
 	"mynewt.apache.org/newt/newt/pkg"
 	"mynewt.apache.org/newt/newt/project"
@@ -48,8 +48,12 @@ type MfgBuildTarget struct {
 	Offset        int
 	Size          int
 	IsBoot        bool
+	Confirm       bool
 	BinPath       string
 	ExtraManifest map[string]interface{}
+
+	// The output directory the target was built in; empty for the default.
+	BinRoot string
 }
 
 type MfgBuildRaw struct {
@@ -207,8 +211,28 @@ func (mt *MfgBuildTarget) ToPart(baseAddress int) (Part, error) {
 	}, nil
 }
 
-func newMfgBuildTarget(dt DecodedTarget,
-	fm flashmap.FlashMap) (MfgBuildTarget, error) {
+// Creates a part containing an initialized boot trailer at the end of the
+// target's flash area.  This causes the boot loader to treat the target's
+// image as confirmed.
+func (mt *MfgBuildTarget) TrailerPart(eraseVal byte,
+	baseAddress int) (Part, error) {
+
+	tail := imgprod.BootTrailerTail(eraseVal)
+
+	off, err := normalizeOffset(OFFSET_END, len(tail), mt.Area, baseAddress)
+	if err != nil {
+		return Part{}, err
+	}
+
+	return Part{
+		Name:   fmt.Sprintf("%s (boot trailer)", mt.Area.Name),
+		Offset: off,
+		Data:   tail,
+	}, nil
+}
+
+func newMfgBuildTarget(dt DecodedTarget, fm flashmap.FlashMap,
+	binRoot string) (MfgBuildTarget, error) {
 
 	t, err := lookUpTarget(dt)
 	if err != nil {
@@ -220,15 +244,19 @@ func newMfgBuildTarget(dt DecodedTarget,
 		return MfgBuildTarget{}, err
 	}
 
-	mpath := builder.ManifestPath(dt.Name, builder.BUILD_NAME_APP, t.App().FullName())
-	man, err := manifest.ReadManifest(mpath)
+	man, err := manifest.ReadManifest(targetSrcManifestPath(t, binRoot))
 	if err != nil {
 		return MfgBuildTarget{}, util.FmtNewtError("%s", err.Error())
 	}
 
 	isBoot := parse.ValueIsTrue(man.Syscfg["BOOT_LOADER"])
+	if isBoot && dt.Confirm {
+		return MfgBuildTarget{}, util.FmtNewtError(
+			"target entry \"%s\" specifies \"confirm\", but target is a "+
+				"boot loader", dt.Name)
+	}
 
-	binPath := targetSrcBinPath(t, isBoot)
+	binPath := targetSrcBinPath(t, isBoot, binRoot)
 
 	st, err := os.Stat(binPath)
 	if err != nil {
@@ -242,8 +270,10 @@ func newMfgBuildTarget(dt DecodedTarget,
 		Offset:        dt.Offset,
 		Size:          int(st.Size()),
 		IsBoot:        isBoot,
+		Confirm:       dt.Confirm,
 		BinPath:       binPath,
 		ExtraManifest: dt.ExtraManifest,
+		BinRoot:       binRoot,
 	}, nil
 }
 
@@ -341,6 +371,14 @@ func (mb *MfgBuilder) parts() ([]Part, error) {
 			return nil, err
 		}
 		parts = append(parts, part)
+
+		if t.Confirm {
+			part, err := t.TrailerPart(mb.Bsp.FlashEraseVal, mb.BaseAddress)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		}
 	}
 
 	// Sort by offset.
@@ -436,7 +474,8 @@ func newMfgBuilder(basePkg *pkg.LocalPackage, dm DecodedMfg,
 	mb.Bsp = bsp
 
 	for _, dt := range dm.Targets {
-		mbt, err := newMfgBuildTarget(dt, bsp.FlashMap)
+		mbt, err := newMfgBuildTarget(dt, bsp.FlashMap,
+			targetBinRoot(basePkg.Name(), dm, dt))
 		if err != nil {
 			return mb, err
 		}
@@ -583,7 +622,7 @@ func (mb *MfgBuilder) Build() (mfg.Mfg, error) {
 		return mfg.Mfg{}, err
 	}
 
-	bin, err := PartsBytes(parts)
+	bin, err := PartsBytes(parts, mb.Bsp.FlashEraseVal)
 	if err != nil {
 		return mfg.Mfg{}, err
 	}
@@ -36,6 +36,8 @@ type DecodedTarget struct {
 	Name          string
 	Area          string
 	Offset        int
+	Syscfg        map[string]string
+	Confirm       bool
 	ExtraManifest map[string]interface{}
 }
 
@@ -63,6 +65,9 @@ type DecodedMfg struct {
 	Raws    []DecodedRaw
 	Meta    *DecodedMeta
 
+	// Syscfg overrides applied to every target in the mfg image.
+	Syscfg map[string]string
+
 	// Only required if no targets present.
 	Bsp string
 }
@@ -113,6 +118,24 @@ func decodeBoolDflt(kv map[string]interface{}, key string,
 	}
 }
 
+func decodeSyscfg(kv map[string]interface{},
+	key string) (map[string]string, error) {
+
+	val := kv[key]
+	if val == nil {
+		return nil, nil
+	}
+
+	sc, err := cast.ToStringMapStringE(val)
+	if err != nil {
+		return nil, util.FmtNewtError(
+			"invalid \"%s\" field: must be a map of setting names to values",
+			key)
+	}
+
+	return sc, nil
+}
+
 func decodeExtra(kv map[string]interface{},
 	key string) (map[string]interface{}, error) {
 
@@ -183,6 +206,20 @@ func decodeTarget(yamlTarget interface{}) (DecodedTarget, error) {
 	}
 	dt.Offset = offsetInt
 
+	sc, err := decodeSyscfg(kv, "syscfg")
+	if err != nil {
+		return dt, util.FmtNewtError(
+			"in target entry %s: %s", dt.Name, err.Error())
+	}
+	dt.Syscfg = sc
+
+	confirm, err := decodeBoolDflt(kv, "confirm", false)
+	if err != nil {
+		return dt, util.FmtNewtError(
+			"in target entry %s: %s", dt.Name, err.Error())
+	}
+	dt.Confirm = confirm
+
 	extra, err := decodeExtra(kv, "extra_manifest")
 	if err != nil {
 		return dt, util.FmtNewtError(
@@ -343,6 +380,9 @@ func decodeMfg(yc ycfg.YCfg) (DecodedMfg, error) {
 	dm.Bsp, err = yc.GetValString("mfg.bsp", nil)
 	util.OneTimeWarningError(err)
 
+	dm.Syscfg, err = yc.GetValStringMapString("mfg.syscfg", nil)
+	util.OneTimeWarningError(err)
+
 	if len(dm.Targets) == 0 && dm.Bsp == "" {
 		return dm, util.FmtNewtError(
 			"\"mfg.bsp\" field required for mfg images without any targets")
@@ -90,44 +90,46 @@ type MfgEmitter struct {
 
 	Mfg      mfg.Mfg
 	Device   int
+	EraseVal byte
 	FlashMap flashmap.FlashMap
 	BspName  string
 	Compiler *toolchain.Compiler
 }
 
 // Calculates the source path of a target's binary.  Boot loader targets use
-// `.bin` files; image targets use `.img`.
-func targetSrcBinPath(t *target.Target, isBoot bool) string {
+// `.bin` files; image targets use `.img`.  binRoot is the output directory the
+// target was built in; empty for the default.
+func targetSrcBinPath(t *target.Target, isBoot bool, binRoot string) string {
 	if isBoot {
-		return builder.AppBinPath(t.Name(), builder.BUILD_NAME_APP,
-			t.App().FullName())
+		return rebasePath(builder.AppBinPath(t.Name(), builder.BUILD_NAME_APP,
+			t.App().FullName()), binRoot)
 	} else {
-		return builder.AppImgPath(t.Name(), builder.BUILD_NAME_APP,
-			t.App().FullName())
+		return rebasePath(builder.AppImgPath(t.Name(), builder.BUILD_NAME_APP,
+			t.App().FullName()), binRoot)
 	}
 }
 
 // Calculates the source path of a target's `.elf` file.
-func targetSrcElfPath(t *target.Target) string {
-	return builder.AppElfPath(t.Name(), builder.BUILD_NAME_APP, t.App().FullName())
+func targetSrcElfPath(t *target.Target, binRoot string) string {
+	return rebasePath(builder.AppElfPath(t.Name(), builder.BUILD_NAME_APP,
+		t.App().FullName()), binRoot)
 }
 
 // Calculates the source path of a target's manifest file.
-func targetSrcManifestPath(t *target.Target) string {
-	return builder.ManifestPath(t.Name(), builder.BUILD_NAME_APP,
-		t.App().FullName())
+func targetSrcManifestPath(t *target.Target, binRoot string) string {
+	return rebasePath(builder.ManifestPath(t.Name(), builder.BUILD_NAME_APP,
+		t.App().FullName()), binRoot)
 }
 
 func newMfgEmitTarget(bt MfgBuildTarget) (MfgEmitTarget, error) {
 	return MfgEmitTarget{
-		Name:    bt.Target.FullName(),
-		Offset:  bt.Area.Offset + bt.Offset,
-		Size:    bt.Size,
-		IsBoot:  bt.IsBoot,
-		BinPath: targetSrcBinPath(bt.Target, bt.IsBoot),
-		ElfPath: targetSrcElfPath(bt.Target),
-		ManifestPath: builder.ManifestPath(bt.Target.Name(),
-			builder.BUILD_NAME_APP, bt.Target.App().FullName()),
+		Name:          bt.Target.FullName(),
+		Offset:        bt.Area.Offset + bt.Offset,
+		Size:          bt.Size,
+		IsBoot:        bt.IsBoot,
+		BinPath:       targetSrcBinPath(bt.Target, bt.IsBoot, bt.BinRoot),
+		ElfPath:       targetSrcElfPath(bt.Target, bt.BinRoot),
+		ManifestPath:  targetSrcManifestPath(bt.Target, bt.BinRoot),
 		ExtraManifest: bt.ExtraManifest,
 	}, nil
 }
@@ -160,8 +162,7 @@ func newMfgEmitMeta(bm MfgBuildMeta, metaOff int) MfgEmitMeta {
 }
 
 func getCompilerFromBsp(bsp *pkg.BspPackage) (*toolchain.Compiler, error) {
-	proj := project.GetProject()
-	compilerPkg, err := proj.ResolvePackage(bsp.Repo(), bsp.CompilerName)
+	compilerPkg, err := toolchain.BspCompilerPkg(bsp)
 	if err != nil {
 		return nil, err
 	}
@@ -183,6 +184,7 @@ func NewMfgEmitter(mb MfgBuilder, name string, ver image.ImageVersion,
 		Name:     name,
 		Ver:      ver,
 		Device:   device,
+		EraseVal: mb.Bsp.FlashEraseVal,
 		Keys:     keys,
 		FlashMap: mb.Bsp.FlashMap,
 		BspName:  mb.Bsp.FullName(),
@@ -309,7 +311,7 @@ func copyBinFiles(entries []CpEntry) error {
 }
 
 func (me *MfgEmitter) createSigs() ([]manifest.MfgManifestSig, error) {
-	hashBytes, err := me.Mfg.Hash(0xff)
+	hashBytes, err := me.Mfg.Hash(me.EraseVal)
 	if err != nil {
 		return nil, err
 	}
@@ -358,7 +360,7 @@ func (me *MfgEmitter) convertHexImages() ([]string, error) {
 
 // emitManifest generates an mfg manifest.
 func (me *MfgEmitter) emitManifest() ([]byte, error) {
-	hashBytes, err := me.Mfg.Hash(0xff)
+	hashBytes, err := me.Mfg.Hash(me.EraseVal)
 	if err != nil {
 		return nil, err
 	}
@@ -380,7 +382,7 @@ func (me *MfgEmitter) emitManifest() ([]byte, error) {
 		Signatures: sigs,
 		FlashAreas: me.FlashMap.SortedAreas(),
 		Bsp:        me.BspName,
-		EraseVal:   0xff,
+		EraseVal:   me.EraseVal,
 	}
 
 	for i, t := range me.Targets {
@@ -441,11 +443,11 @@ func (me *MfgEmitter) emitManifest() ([]byte, error) {
 
 // @return                      [source-paths], [dest-paths], error
 func (me *MfgEmitter) Emit() ([]string, []string, error) {
-	if err := me.Mfg.RefillHash(0xff); err != nil {
+	if err := me.Mfg.RefillHash(me.EraseVal); err != nil {
 		return nil, nil, err
 	}
 
-	mbin, err := me.Mfg.Bytes(0xff)
+	mbin, err := me.Mfg.Bytes(me.EraseVal)
 	if err != nil {
 		return nil, nil, err
 	}
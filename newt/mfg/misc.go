@@ -43,6 +43,67 @@ func loadDecodedMfg(basePath string) (DecodedMfg, error) {
 	return dm, nil
 }
 
+// Describes a target that must be rebuilt with injected syscfg overrides
+// before it can be included in a manufacturing image.
+type TargetRebuild struct {
+	Name   string
+	Syscfg map[string]string
+
+	// The output directory to build the target in.
+	BinRoot string
+}
+
+// Combines the mfg-wide syscfg overrides with those of the specified target
+// entry; the latter take precedence.
+func targetSyscfg(dm DecodedMfg, dt DecodedTarget) map[string]string {
+	sc := map[string]string{}
+	for k, v := range dm.Syscfg {
+		sc[k] = v
+	}
+	for k, v := range dt.Syscfg {
+		sc[k] = v
+	}
+
+	return sc
+}
+
+// Determines the output directory of the specified target entry's artifacts.
+// Targets with syscfg overrides are rebuilt in the mfg package's build
+// directory; the others are taken from the default output directory ("").
+func targetBinRoot(mfgPkgName string, dm DecodedMfg,
+	dt DecodedTarget) string {
+
+	if len(targetSyscfg(dm, dt)) == 0 {
+		return ""
+	}
+
+	return MfgBuildDir(mfgPkgName)
+}
+
+// LoadTargetRebuilds reads the specified mfg package's `mfg.yml` file and
+// returns the set of targets that specify syscfg overrides.  A target's
+// overrides consist of the mfg-wide `mfg.syscfg` settings combined with the
+// target entry's own `syscfg` settings; the latter take precedence.
+func LoadTargetRebuilds(basePkg *pkg.LocalPackage) ([]TargetRebuild, error) {
+	dm, err := loadDecodedMfg(basePkg.BasePath())
+	if err != nil {
+		return nil, err
+	}
+
+	var rebuilds []TargetRebuild
+	for _, dt := range dm.Targets {
+		if sc := targetSyscfg(dm, dt); len(sc) > 0 {
+			rebuilds = append(rebuilds, TargetRebuild{
+				Name:    dt.Name,
+				Syscfg:  sc,
+				BinRoot: targetBinRoot(basePkg.Name(), dm, dt),
+			})
+		}
+	}
+
+	return rebuilds, nil
+}
+
 func LoadMfgEmitter(basePkg *pkg.LocalPackage,
 	ver image.ImageVersion, keys []sec.PrivSignKey, baseAddress int) (MfgEmitter, error) {
 
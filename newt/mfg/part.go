@@ -87,9 +87,9 @@ func WriteParts(parts []Part, w io.Writer, eraseVal byte) (int, error) {
 	return off, nil
 }
 
-func PartsBytes(parts []Part) ([]byte, error) {
+func PartsBytes(parts []Part, eraseVal byte) ([]byte, error) {
 	b := &bytes.Buffer{}
-	if _, err := WriteParts(parts, b, 0xff); err != nil {
+	if _, err := WriteParts(parts, b, eraseVal); err != nil {
 		return nil, err
 	}
 	return b.Bytes(), nil
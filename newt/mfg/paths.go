@@ -21,6 +21,7 @@ package mfg
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/apache/mynewt-artifact/mfg"
 	"mynewt.apache.org/newt/newt/builder"
@@ -33,6 +34,23 @@ func MfgBinDir(mfgPkgName string) string {
 	return builder.BinRoot() + "/" + mfgPkgName
 }
 
+// MfgBuildDir is the output directory of the targets that are rebuilt with
+// mfg syscfg overrides.  It keeps the rebuilt artifacts separate from the ones
+// in the targets' regular bin directories.
+func MfgBuildDir(mfgPkgName string) string {
+	return MfgBinDir(mfgPkgName) + "/build"
+}
+
+// Converts a path under the default output directory to the corresponding path
+// under the specified one.
+func rebasePath(path string, binRoot string) string {
+	if binRoot == "" {
+		return path
+	}
+
+	return binRoot + strings.TrimPrefix(path, builder.BinRoot())
+}
+
 func MfgBinPath(mfgPkgName string) string {
 	return MfgBinDir(mfgPkgName) + "/" + mfg.MFG_BIN_IMG_FILENAME
 }
@@ -0,0 +1,143 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// This file contains functionality for verifying previously built target
+// artifacts before they are packaged into a manufacturing image.
+
+package mfg
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/apache/mynewt-artifact/image"
+	"github.com/apache/mynewt-artifact/manifest"
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/parse"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
+)
+
+// Ensures an image file's hash matches the one recorded in its manifest.  The
+// hash is recalculated from the image's header, body, and protected TLVs, so
+// an image whose contents were modified after it was created is rejected.
+// The hash of a split app image is seeded with its loader's hash.  The hash
+// of an encrypted image cannot be recalculated; only its hash TLV is compared
+// against the manifest.
+func verifyImageHash(imgPath string, man manifest.Manifest) error {
+	if man.ImageHash == "" {
+		return util.FmtNewtError(
+			"manifest for target \"%s\" does not contain an image hash; "+
+				"was the image created with `newt create-image`?", man.Name)
+	}
+
+	img, err := image.ReadImage(imgPath)
+	if err != nil {
+		return util.FmtNewtError(
+			"failed to read image \"%s\": %s", imgPath, err.Error())
+	}
+
+	var loaderHash []byte
+	if man.LoaderHash != "" {
+		loaderHash, err = hex.DecodeString(man.LoaderHash)
+		if err != nil {
+			return util.FmtNewtError(
+				"manifest for target \"%s\" contains invalid "+
+					"loader hash: %s", man.Name, man.LoaderHash)
+		}
+	}
+
+	tlvHash, _, err := newtutil.VerifyImageHash(img, loaderHash)
+	if err != nil {
+		return util.FmtNewtError(
+			"image \"%s\" is corrupt: %s", imgPath, err.Error())
+	}
+
+	if !strings.EqualFold(hex.EncodeToString(tlvHash), man.ImageHash) {
+		return util.FmtNewtError(
+			"image \"%s\" does not match its manifest; "+
+				"image_hash=%x manifest_hash=%s",
+			imgPath, tlvHash, man.ImageHash)
+	}
+
+	return nil
+}
+
+// Ensures a target was built with each of the specified syscfg overrides.
+func verifySyscfg(man manifest.Manifest, sc map[string]string) error {
+	for k, v := range sc {
+		if man.Syscfg[k] != v {
+			return util.FmtNewtError(
+				"target \"%s\" was not built with mfg syscfg override "+
+					"%s=%s (built value: \"%s\")", man.Name, k, v,
+				man.Syscfg[k])
+		}
+	}
+
+	return nil
+}
+
+// VerifyTargetArtifacts checks that every target in the specified mfg package
+// has already been built and that its artifacts are consistent with its
+// manifest.  Images are validated against the hash recorded in their
+// manifest.  Targets with syscfg overrides must have been built with those
+// overrides applied.
+func VerifyTargetArtifacts(basePkg *pkg.LocalPackage) error {
+	dm, err := loadDecodedMfg(basePkg.BasePath())
+	if err != nil {
+		return err
+	}
+
+	rebuilds, err := LoadTargetRebuilds(basePkg)
+	if err != nil {
+		return err
+	}
+	overrides := map[string]map[string]string{}
+	for _, rb := range rebuilds {
+		overrides[rb.Name] = rb.Syscfg
+	}
+
+	for _, dt := range dm.Targets {
+		t, err := lookUpTarget(dt)
+		if err != nil {
+			return err
+		}
+
+		binRoot := targetBinRoot(basePkg.Name(), dm, dt)
+		man, err := manifest.ReadManifest(targetSrcManifestPath(t, binRoot))
+		if err != nil {
+			return util.FmtNewtError(
+				"target \"%s\" has not been built: %s", dt.Name, err.Error())
+		}
+
+		if !parse.ValueIsTrue(man.Syscfg["BOOT_LOADER"]) {
+			if err := verifyImageHash(targetSrcBinPath(t, false, binRoot),
+				man); err != nil {
+
+				return err
+			}
+		}
+
+		if err := verifySyscfg(man, overrides[dt.Name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -22,7 +22,9 @@ package main
 import (
 	"mynewt.apache.org/newt/newt/settings"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/shirou/gopsutil/cpu"
 	log "github.com/sirupsen/logrus"
@@ -37,7 +39,10 @@ var newtSilent bool
 var newtQuiet bool
 var newtVerbose bool
 var newtLogFile string
+var newtProgressJSON string
+var newtLogSubsys string
 var newtNumJobs int
+var newtOutDir string
 var newtHelp bool
 
 func newtDfltNumJobs() int {
@@ -100,7 +105,26 @@ func newtCmd() *cobra.Command {
 				cli.NewtUsage(nil, err)
 			}
 
+			if err := util.InitSubsysLog(newtLogSubsys); err != nil {
+				cli.NewtUsage(nil, err)
+			}
+
+			if err := util.InitProgress(newtProgressJSON); err != nil {
+				cli.NewtUsage(nil, err)
+			}
+
 			newtutil.NewtNumJobs = newtNumJobs
+
+			if newtOutDir == "" {
+				newtOutDir = os.Getenv("NEWT_OUT")
+			}
+			if newtOutDir != "" {
+				outDir, err := filepath.Abs(newtOutDir)
+				if err != nil {
+					cli.NewtUsage(nil, util.ChildNewtError(err))
+				}
+				newtutil.NewtOutDir = filepath.ToSlash(outDir)
+			}
 		},
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Help()
@@ -115,16 +139,25 @@ func newtCmd() *cobra.Command {
 		"Be silent; don't output anything")
 	newtCmd.PersistentFlags().StringVarP(&logLevelStr, "loglevel", "l",
 		"WARN", "Log level")
+	newtCmd.PersistentFlags().StringVarP(&newtLogSubsys, "log", "", "",
+		"Per-subsystem log levels (e.g., resolver=debug,downloader=warn); "+
+			"subsystems: "+strings.Join(util.LogSubsystems, ", "))
 	newtCmd.PersistentFlags().StringVarP(&newtLogFile, "outfile", "o",
 		"", "Filename to tee output to")
 	newtCmd.PersistentFlags().IntVarP(&newtNumJobs, "jobs", "j",
 		newtDfltNumJobs(), "Number of concurrent build jobs")
+	newtCmd.PersistentFlags().StringVarP(&newtOutDir, "out", "", "",
+		"Directory to write build artifacts to instead of the project's "+
+			"bin directory (default $NEWT_OUT)")
 	newtCmd.PersistentFlags().BoolVarP(&newtHelp, "help", "h",
 		false, "Help for newt commands")
 	newtCmd.PersistentFlags().BoolVarP(&util.EscapeShellCmds, "escape", "",
 		util.EscapeShellCmds, "Apply Windows escapes to shell commands")
 	newtCmd.PersistentFlags().IntVarP(&util.ShallowCloneDepth, "shallow", "",
 		util.ShallowCloneDepth, "Use shallow clone for git repositories up to specified number of commits")
+	newtCmd.PersistentFlags().StringVarP(&newtProgressJSON, "progress-json",
+		"", "", "Write progress events as JSON lines to the specified "+
+			"file (\"-\" for stdout)")
 
 	versHelpText := cli.FormatHelp(`Display the Newt version number`)
 	versHelpEx := "  newt version"
@@ -151,17 +184,34 @@ func main() {
 
 	cmd := newtCmd()
 
+	cli.AddAnalyzeCommands(cmd)
+	cli.AddBspCommands(cmd)
 	cli.AddBuildCommands(cmd)
+	cli.AddCacheCommands(cmd)
 	cli.AddCompleteCommands(cmd)
+	cli.AddDeviceCommands(cmd)
+	cli.AddHilTestCommands(cmd)
 	cli.AddImageCommands(cmd)
 	cli.AddPackageCommands(cmd)
+	cli.AddProfileCommands(cmd)
 	cli.AddProjectCommands(cmd)
+	cli.AddPublishCommands(cmd)
+	cli.AddQueryCommands(cmd)
 	cli.AddRunCommands(cmd)
+	cli.AddSelftestCommands(cmd)
+	cli.AddStackCommands(cmd)
 	cli.AddTargetCommands(cmd)
+	cli.AddToolchainCommands(cmd)
 	cli.AddValsCommands(cmd)
+	cli.AddLicenseCommands(cmd)
+	cli.AddManifestCommands(cmd)
 	cli.AddMfgCommands(cmd)
+	cli.AddFixupCommands(cmd)
+	cli.AddFormatCommands(cmd)
 	cli.AddDocsCommands(cmd)
+	cli.AddEnvCommands(cmd)
 	cli.AddManCommands(cmd)
+	cli.AddHelpTopics(cmd)
 
 	/* only pass the first two args to check for complete command */
 	if len(os.Args) > 2 {
@@ -0,0 +1,61 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package newtutil
+
+import (
+	"bytes"
+
+	"github.com/apache/mynewt-artifact/image"
+	"mynewt.apache.org/newt/util"
+)
+
+// VerifyImageHash recomputes an image's hash from its header, body, and
+// protected TLVs, and checks it against the image's SHA256 TLV.  The hash of
+// a split app image is seeded with the hash of its loader (initialHash);
+// initialHash is nil for other images.
+//
+// The hash of an encrypted image is calculated over the plaintext, so it
+// cannot be recomputed from the stored body.  Such images are not checked;
+// the second return value indicates whether the hash was recomputed.  The
+// image's hash, as recorded in its TLV, is returned in either case.
+func VerifyImageHash(img image.Image, initialHash []byte) (
+	[]byte, bool, error) {
+
+	tlvHash, err := img.Hash()
+	if err != nil {
+		return nil, false, util.ChildNewtError(err)
+	}
+
+	if img.IsEncrypted() {
+		return tlvHash, false, nil
+	}
+
+	hash, err := img.CalcHash(initialHash)
+	if err != nil {
+		return nil, false, util.ChildNewtError(err)
+	}
+
+	if !bytes.Equal(hash, tlvHash) {
+		return nil, false, util.FmtNewtError(
+			"image hash mismatch: contents=%x hash tlv=%x", hash, tlvHash)
+	}
+
+	return tlvHash, true, nil
+}
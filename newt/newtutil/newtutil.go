@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
 	"mynewt.apache.org/newt/newt/interfaces"
 	"mynewt.apache.org/newt/util"
@@ -40,6 +41,11 @@ var NewtNumJobs int
 var NewtForce bool
 var NewtAsk bool
 var NewtIgnore []string
+var NewtExclude []string
+
+// The absolute path of the directory that build artifacts are written to, or
+// "" to use the project's bin directory.
+var NewtOutDir string
 
 const CORE_REPO_NAME string = "apache-mynewt-core"
 const ARDUINO_ZERO_REPO_NAME string = "mynewt_arduino_zero"
@@ -197,3 +203,51 @@ func PrintNewtVersion() {
 	util.StatusMessage(util.VERBOSITY_DEFAULT, "Apache Newt %s / %s / %s\n",
 		NewtVersionStr, NewtGitHash, NewtDate)
 }
+
+// Calls `fn` for each index in [0, count), running up to NewtNumJobs calls
+// concurrently.  If any call fails, no further calls are started and the
+// first error is returned.
+func ForEachParallel(count int, fn func(i int) error) error {
+	numJobs := NewtNumJobs
+	if numJobs < 1 {
+		numJobs = 1
+	}
+
+	idxs := make(chan int, count)
+	for i := 0; i < count; i++ {
+		idxs <- i
+	}
+	close(idxs)
+
+	var mtx sync.Mutex
+	var firstErr error
+
+	var wg sync.WaitGroup
+	for j := 0; j < numJobs && j < count; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range idxs {
+				mtx.Lock()
+				failed := firstErr != nil
+				mtx.Unlock()
+				if failed {
+					return
+				}
+
+				if err := fn(i); err != nil {
+					mtx.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mtx.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,70 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package newtutil
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"math/big"
+
+	"github.com/apache/mynewt-artifact/sec"
+	"mynewt.apache.org/newt/util"
+)
+
+// KeySignedHash indicates whether one of the signatures is a signature of
+// `hash` generated by the specified key.
+func KeySignedHash(key sec.PubSignKey, sigs []sec.Sig,
+	hash []byte) (bool, error) {
+
+	// The artifact library does not verify ECDSA signatures.
+	if key.Ec != nil {
+		keyHash, err := key.Hash()
+		if err != nil {
+			return false, util.ChildNewtError(err)
+		}
+
+		for _, sig := range sigs {
+			if !bytes.Equal(keyHash, sig.KeyHash) {
+				continue
+			}
+
+			var rs struct {
+				R *big.Int
+				S *big.Int
+			}
+			if _, err := asn1.Unmarshal(sig.Data, &rs); err != nil {
+				continue
+			}
+			if ecdsa.Verify(key.Ec, hash, rs.R, rs.S) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	idx, err := sec.VerifySigs(key, sigs, hash)
+	if err != nil {
+		return false, util.ChildNewtError(err)
+	}
+
+	return idx >= 0, nil
+}
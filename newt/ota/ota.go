@@ -0,0 +1,316 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package ota generates the metadata that an update server needs to roll out
+// an image: its version, the hardware it runs on, and its hash and
+// signatures.  The metadata is derived from a build's manifest and image, and
+// is emitted in one of several formats.
+package ota
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apache/mynewt-artifact/image"
+	"github.com/apache/mynewt-artifact/manifest"
+	"github.com/apache/mynewt-artifact/sec"
+
+	"mynewt.apache.org/newt/util"
+)
+
+type Sig struct {
+	Type    string `json:"type"`
+	KeyHash string `json:"key_hash"`
+
+	// Base64-encoded signature.
+	Sig string `json:"sig"`
+}
+
+// Describes an image to an update server.
+type Meta struct {
+	Target     string   `json:"target"`
+	Version    string   `json:"version"`
+	MinVersion string   `json:"min_version,omitempty"`
+	BuildID    string   `json:"build_id"`
+	BuildTime  string   `json:"build_time"`
+	HwIds      []string `json:"hw_ids"`
+	ImageName  string   `json:"image"`
+	ImageSize  int64    `json:"image_size"`
+	ImageHash  string   `json:"image_hash"`
+	Sigs       []Sig    `json:"signatures"`
+}
+
+// Converts metadata to a specific update server's format.
+type Emitter func(m *Meta) ([]byte, error)
+
+var emitters = map[string]Emitter{
+	"json":    emitJson,
+	"hawkbit": emitHawkbit,
+	"golioth": emitGolioth,
+}
+
+// RegisterFormat adds an output format.
+func RegisterFormat(name string, e Emitter) {
+	emitters[name] = e
+}
+
+// FormatNames returns the names of the supported output formats, sorted.
+func FormatNames() []string {
+	names := make([]string, 0, len(emitters))
+	for name, _ := range emitters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Emit converts metadata to the specified format.
+func Emit(format string, m *Meta) ([]byte, error) {
+	e := emitters[format]
+	if e == nil {
+		return nil, util.FmtNewtError(
+			"unknown OTA metadata format \"%s\"; must be one of: %s",
+			format, strings.Join(FormatNames(), ", "))
+	}
+
+	return e(m)
+}
+
+func compareVersions(a image.ImageVersion, b image.ImageVersion) int {
+	cmp := func(x uint64, y uint64) int {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	if c := cmp(uint64(a.Major), uint64(b.Major)); c != 0 {
+		return c
+	}
+	if c := cmp(uint64(a.Minor), uint64(b.Minor)); c != 0 {
+		return c
+	}
+	if c := cmp(uint64(a.Rev), uint64(b.Rev)); c != 0 {
+		return c
+	}
+	return cmp(uint64(a.BuildNum), uint64(b.BuildNum))
+}
+
+// NewMeta collects metadata from a manifest and the image it describes.
+// `minVersion` is the oldest version a device may update from, or "" if
+// there is no restriction.
+func NewMeta(m manifest.Manifest, hwIds []string,
+	minVersion string) (*Meta, error) {
+
+	if util.NodeNotExist(m.Image) {
+		return nil, util.FmtNewtError(
+			"image %s does not exist; create it with `newt create-image`",
+			m.Image)
+	}
+
+	img, err := image.ReadImage(m.Image)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	if minVersion != "" {
+		minVer, err := image.ParseVersion(minVersion)
+		if err != nil {
+			return nil, util.ChildNewtError(err)
+		}
+		if compareVersions(minVer, img.Header.Vers) > 0 {
+			return nil, util.FmtNewtError(
+				"minimum previous version (%s) is newer than the image "+
+					"version (%s)", minVer.String(), img.Header.Vers.String())
+		}
+		minVersion = minVer.String()
+	}
+
+	hash, err := img.Hash()
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	info, err := os.Stat(m.Image)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	meta := &Meta{
+		Target:     m.Name,
+		Version:    img.Header.Vers.String(),
+		MinVersion: minVersion,
+		BuildID:    m.BuildID,
+		BuildTime:  m.Date,
+		HwIds:      hwIds,
+		ImageName:  filepath.Base(m.Image),
+		ImageSize:  info.Size(),
+		ImageHash:  hex.EncodeToString(hash),
+		Sigs:       []Sig{},
+	}
+	if meta.HwIds == nil {
+		meta.HwIds = []string{}
+	}
+
+	sigs, err := img.CollectSigs()
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+	for _, s := range sigs {
+		meta.Sigs = append(meta.Sigs, Sig{
+			Type:    sec.SigTypeString(s.Type),
+			KeyHash: hex.EncodeToString(s.KeyHash),
+			Sig:     base64.StdEncoding.EncodeToString(s.Data),
+		})
+	}
+
+	return meta, nil
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	js, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	return append(js, '\n'), nil
+}
+
+// Newt's own format: the metadata as is.
+func emitJson(m *Meta) ([]byte, error) {
+	return marshal(m)
+}
+
+type hawkbitKV struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type hawkbitArtifact struct {
+	Filename string            `json:"filename"`
+	Size     int64             `json:"size"`
+	Hashes   map[string]string `json:"hashes"`
+}
+
+type hawkbitModule struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Type      string            `json:"type"`
+	Artifacts []hawkbitArtifact `json:"artifacts"`
+	Metadata  []hawkbitKV       `json:"metadata"`
+}
+
+type hawkbitDistSet struct {
+	Name     string          `json:"name"`
+	Version  string          `json:"version"`
+	Type     string          `json:"type"`
+	Modules  []hawkbitModule `json:"modules"`
+	Metadata []hawkbitKV     `json:"metadata"`
+}
+
+// An Eclipse hawkBit distribution set containing a single firmware software
+// module.  The hardware IDs, minimum version, and signatures are attached as
+// metadata, which rollout filters and devices can query.
+func emitHawkbit(m *Meta) ([]byte, error) {
+	module := hawkbitModule{
+		Name:    m.Target,
+		Version: m.Version,
+		Type:    "os",
+		Artifacts: []hawkbitArtifact{{
+			Filename: m.ImageName,
+			Size:     m.ImageSize,
+			Hashes:   map[string]string{"sha256": m.ImageHash},
+		}},
+		Metadata: []hawkbitKV{
+			{Key: "build_id", Value: m.BuildID},
+		},
+	}
+	for i, s := range m.Sigs {
+		module.Metadata = append(module.Metadata, hawkbitKV{
+			Key:   "signature." + s.Type + "." + strconv.Itoa(i),
+			Value: s.Sig,
+		})
+	}
+
+	ds := hawkbitDistSet{
+		Name:    m.Target,
+		Version: m.Version,
+		Type:    "os",
+		Modules: []hawkbitModule{module},
+		Metadata: []hawkbitKV{
+			{Key: "hw_ids", Value: strings.Join(m.HwIds, ",")},
+		},
+	}
+	if m.MinVersion != "" {
+		ds.Metadata = append(ds.Metadata,
+			hawkbitKV{Key: "min_version", Value: m.MinVersion})
+	}
+
+	return marshal(ds)
+}
+
+type goliothArtifact struct {
+	Package    string   `json:"package"`
+	Version    string   `json:"version"`
+	Blueprints []string `json:"blueprints"`
+	Filename   string   `json:"filename"`
+	Size       int64    `json:"size"`
+	Hash       string   `json:"hash"`
+}
+
+type goliothRelease struct {
+	Artifacts []goliothArtifact `json:"artifacts"`
+	Tags      []string          `json:"tags"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+// A Golioth release containing the image as its "main" package.  Each
+// hardware ID is used as a blueprint name.
+func emitGolioth(m *Meta) ([]byte, error) {
+	rel := goliothRelease{
+		Artifacts: []goliothArtifact{{
+			Package:    "main",
+			Version:    m.Version,
+			Blueprints: m.HwIds,
+			Filename:   m.ImageName,
+			Size:       m.ImageSize,
+			Hash:       m.ImageHash,
+		}},
+		Tags: []string{m.Target},
+		Metadata: map[string]string{
+			"build_id": m.BuildID,
+		},
+	}
+	if m.MinVersion != "" {
+		rel.Metadata["min_version"] = m.MinVersion
+	}
+
+	return marshal(rel)
+}
@@ -106,6 +106,29 @@ func lexIdent(s string) (string, int, error) {
 	}
 }
 
+// Lexes a setting reference in C notation, "MYNEWT_VAL(<name>)".  The
+// reference is equivalent to the bare setting name.
+func lexSettingRef(s string) (string, int, error) {
+	const prefix = "MYNEWT_VAL("
+
+	if !strings.HasPrefix(s, prefix) {
+		return "", 0, nil
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end == -1 {
+		return "", 0, fmt.Errorf("unterminated setting reference: %s", s)
+	}
+
+	name := strings.TrimSpace(s[len(prefix):end])
+	if name == "" || strings.ContainsAny(name, delimChars) {
+		return "", 0, fmt.Errorf("invalid setting reference: %s",
+			s[:end+1])
+	}
+
+	return name, end + 1, nil
+}
+
 type lexEntry struct {
 	code TokenCode
 	fn   LexFn
@@ -126,6 +149,7 @@ var lexEntries = []lexEntry{
 	{TOKEN_RPAREN, lexStringFn(")")},
 	{TOKEN_STRING, lexLitString},
 	{TOKEN_NUMBER, lexLitNumber},
+	{TOKEN_IDENT, lexSettingRef},
 	{TOKEN_IDENT, lexIdent},
 }
 
@@ -599,7 +599,8 @@ func Eval(expr *Node, settings *cfgv.Settings) (bool, error) {
 func LexAndParse(expr string) (*Node, error) {
 	tokens, err := Lex(expr)
 	if err != nil {
-		return nil, err
+		return nil, util.FmtNewtError("error parsing [%s]: %s",
+			expr, err.Error())
 	}
 
 	n, err := Parse(tokens)
@@ -624,7 +625,12 @@ func ParseAndEval(expr string, settings *cfgv.Settings) (bool, error) {
 	}
 
 	v, err := Eval(n, settings)
-	return v, err
+	if err != nil {
+		return false, util.FmtNewtError("error evaluating [%s]: %s",
+			expr, err.Error())
+	}
+
+	return v, nil
 }
 
 // Parses an expression and converts it to its normalized text form.
@@ -641,6 +647,32 @@ func NormalizeExpr(expr string) (string, error) {
 	return n.String(), nil
 }
 
+// Returns the sorted names of all settings that the expression references.
+func (n *Node) Idents() []string {
+	m := map[string]struct{}{}
+
+	var iter func(n *Node)
+	iter = func(n *Node) {
+		if n == nil {
+			return
+		}
+		if n.Code == PARSE_IDENT {
+			m[n.Data] = struct{}{}
+		}
+		iter(n.Left)
+		iter(n.Right)
+	}
+	iter(n)
+
+	idents := make([]string, 0, len(m))
+	for ident := range m {
+		idents = append(idents, ident)
+	}
+	sort.Strings(idents)
+
+	return idents
+}
+
 // Evaluates the truthfulness of a text expression.
 func ValueIsTrue(val string) bool {
 	if val == "" {
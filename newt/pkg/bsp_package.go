@@ -28,6 +28,7 @@ import (
 	"mynewt.apache.org/newt/newt/config"
 	"mynewt.apache.org/newt/newt/flashmap"
 	"mynewt.apache.org/newt/newt/interfaces"
+	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/ycfg"
 	"mynewt.apache.org/newt/util"
 )
@@ -44,14 +45,20 @@ type BspPackage struct {
 	yov                *BspYCfgOverride
 	CompilerName       string
 	CompilerNamePkg    *LocalPackage /* package which defines compiler name */
+	CompilerArch       string        /* arch for compiler selection */
 	Arch               string
 	LinkerScripts      []string
 	Part2LinkerScripts []string /* scripts to link app to second partition */
 	DownloadScript     string
 	DebugScript        string
 	OptChkScript       string
+	RomLoader          string   /* ROM serial bootloader (bsp.rom_loader) */
+	RomLoaderArgs      []string /* extra arguments for the ROM loader tool */
+	RomLoaderPageSize  int      /* flash page size for ROM loader erases */
+	HwIds              []string /* hardware IDs for OTA updates (bsp.hw_ids) */
 	ImageOffset        int
 	ImagePad           int
+	FlashEraseVal      byte /* value of an erased flash byte */
 	FlashMap           flashmap.FlashMap
 	BspV               ycfg.YCfg
 }
@@ -82,24 +89,27 @@ func (bsp *BspPackage) resolvePathSetting(
 	return path, nil
 }
 
-func (bsp *BspPackage) getAutogeneratedLinkerScriptPath() (string, error) {
-	defaultLinkerScriptPath := "bin/" + bsp.yov.Pkg.FullName() + "/generated/link/mynewt.ld"
+// Returns the path of a file in the target's generated link directory.
+func (bsp *BspPackage) autogeneratedLinkPath(name string) (string, error) {
+	relPath := bsp.yov.Pkg.FullName() + "/generated/link/" + name
+	if newtutil.NewtOutDir != "" {
+		return newtutil.NewtOutDir + "/" + relPath, nil
+	}
+
 	proj := interfaces.GetProject()
-	path, err := proj.ResolvePath(proj.Path(), defaultLinkerScriptPath)
+	path, err := proj.ResolvePath(proj.Path(), "bin/"+relPath)
 	if err != nil {
 		return "", err
 	}
 	return path, nil
 }
 
+func (bsp *BspPackage) getAutogeneratedLinkerScriptPath() (string, error) {
+	return bsp.autogeneratedLinkPath("mynewt.ld")
+}
+
 func (bsp *BspPackage) GetAutogeneratedLinkerIncludePath() (string, error) {
-	defaultLinkerScriptPath := "bin/" + bsp.yov.Pkg.FullName() + "/generated/link/include"
-	proj := interfaces.GetProject()
-	path, err := proj.ResolvePath(proj.Path(), defaultLinkerScriptPath)
-	if err != nil {
-		return "", err
-	}
-	return path, nil
+	return bsp.autogeneratedLinkPath("include")
 }
 
 // Interprets a setting as either a single linker script or a list of linker
@@ -192,6 +202,10 @@ func (bsp *BspPackage) Reload(settings *cfgv.Settings) error {
 	bsp.CompilerName, err = ycfg.GetValString("bsp.compiler", settings)
 	util.OneTimeWarningError(err)
 
+	_, ycfg = bsp.selectKey("bsp.compiler_arch")
+	bsp.CompilerArch, err = ycfg.GetValString("bsp.compiler_arch", settings)
+	util.OneTimeWarningError(err)
+
 	bsp.Arch, err = bsp.BspV.GetValString("bsp.arch", settings)
 	util.OneTimeWarningError(err)
 
@@ -203,6 +217,16 @@ func (bsp *BspPackage) Reload(settings *cfgv.Settings) error {
 	bsp.ImagePad, err = ycfg.GetValInt("bsp.image_pad", settings)
 	util.OneTimeWarningError(err)
 
+	_, ycfg = bsp.selectKey("bsp.flash_erase_val")
+	eraseVal, err := ycfg.GetValIntDflt("bsp.flash_erase_val", settings, 0xff)
+	util.OneTimeWarningError(err)
+	if eraseVal < 0 || eraseVal > 0xff {
+		return util.FmtNewtError(
+			"BSP specifies invalid flash erase value (bsp.flash_erase_val): "+
+				"%d; must be between 0x00 and 0xff", eraseVal)
+	}
+	bsp.FlashEraseVal = byte(eraseVal)
+
 	bsp.LinkerScripts, err = bsp.resolveLinkerScriptSetting(settings, "bsp.linkerscript")
 	if err != nil {
 		return err
@@ -227,9 +251,27 @@ func (bsp *BspPackage) Reload(settings *cfgv.Settings) error {
 	bsp.OptChkScript, err = bsp.resolvePathSetting(
 		settings, "bsp.optionalcheckscript")
 
-	if bsp.CompilerName == "" {
+	_, ycfg = bsp.selectKey("bsp.rom_loader")
+	bsp.RomLoader, err = ycfg.GetValString("bsp.rom_loader", settings)
+	util.OneTimeWarningError(err)
+
+	_, ycfg = bsp.selectKey("bsp.rom_loader_args")
+	bsp.RomLoaderArgs, err = ycfg.GetValStringSlice(
+		"bsp.rom_loader_args", settings)
+	util.OneTimeWarningError(err)
+
+	_, ycfg = bsp.selectKey("bsp.rom_loader_page_size")
+	bsp.RomLoaderPageSize, err = ycfg.GetValInt(
+		"bsp.rom_loader_page_size", settings)
+	util.OneTimeWarningError(err)
+
+	_, ycfg = bsp.selectKey("bsp.hw_ids")
+	bsp.HwIds, err = ycfg.GetValStringSlice("bsp.hw_ids", settings)
+	util.OneTimeWarningError(err)
+
+	if bsp.CompilerName == "" && bsp.CompilerArch == "" {
 		return util.NewNewtError("BSP does not specify a compiler " +
-			"(bsp.compiler)")
+			"(bsp.compiler) or a compiler architecture (bsp.compiler_arch)")
 	}
 	if bsp.Arch == "" {
 		return util.NewNewtError("BSP does not specify an architecture " +
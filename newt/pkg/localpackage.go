@@ -123,12 +123,30 @@ func (pkg *LocalPackage) RelativePath() string {
 	return strings.TrimPrefix(pkg.BasePath(), proj.Path())
 }
 
+// Returns the path of the package's pkg.yml file, or of its pkg.toml or
+// pkg.json equivalent if the package uses one of those formats instead.
 func (pkg *LocalPackage) PkgYamlPath() string {
-	return fmt.Sprintf("%s/%s", pkg.BasePath(), PACKAGE_FILE_NAME)
+	return config.FindFile(
+		fmt.Sprintf("%s/%s", pkg.BasePath(), PACKAGE_FILE_NAME))
 }
 
+// Returns the path of the package's syscfg.yml file, or of its syscfg.toml
+// or syscfg.json equivalent if the package uses one of those formats
+// instead.
 func (pkg *LocalPackage) SyscfgYamlPath() string {
-	return fmt.Sprintf("%s/%s", pkg.BasePath(), SYSCFG_YAML_FILENAME)
+	return config.FindFile(
+		fmt.Sprintf("%s/%s", pkg.BasePath(), SYSCFG_YAML_FILENAME))
+}
+
+// Fails if newt cannot rewrite the specified package file; newt only writes
+// YAML.
+func ensureWritable(path string) error {
+	if !config.IsYAMLFile(path) {
+		return util.FmtNewtError(
+			"cannot modify %s: newt can only write YAML config files", path)
+	}
+
+	return nil
 }
 
 func (pkg *LocalPackage) Type() interfaces.PackageType {
@@ -200,6 +218,9 @@ func (pkg *LocalPackage) readDesc(yc ycfg.YCfg) (*PackageDesc, error) {
 	pdesc.Keywords, err = yc.GetValStringSlice("pkg.keywords", nil)
 	util.OneTimeWarningError(err)
 
+	pdesc.License, err = yc.GetValString("pkg.license", nil)
+	util.OneTimeWarningError(err)
+
 	return pdesc, nil
 }
 
@@ -234,6 +255,10 @@ func (lpkg *LocalPackage) SaveSyscfg() error {
 		return util.NewNewtError(err.Error())
 	}
 
+	if err := ensureWritable(lpkg.SyscfgYamlPath()); err != nil {
+		return err
+	}
+
 	file, err := os.Create(lpkg.SyscfgYamlPath())
 	if err != nil {
 		return util.NewNewtError(err.Error())
@@ -255,6 +280,10 @@ func (pkg *LocalPackage) Save() error {
 		return util.NewNewtError(err.Error())
 	}
 
+	if err := ensureWritable(pkg.PkgYamlPath()); err != nil {
+		return err
+	}
+
 	file, err := os.Create(pkg.PkgYamlPath())
 	if err != nil {
 		return util.NewNewtError(err.Error())
@@ -272,6 +301,10 @@ func (pkg *LocalPackage) Save() error {
 		yaml.EscapeString(pkg.Desc().Author) + "\n")
 	file.WriteString("pkg.homepage: " +
 		yaml.EscapeString(pkg.Desc().Homepage) + "\n")
+	if pkg.Desc().License != "" {
+		file.WriteString("pkg.license: " +
+			yaml.EscapeString(pkg.Desc().License) + "\n")
+	}
 
 	file.WriteString("\n")
 
@@ -407,6 +440,22 @@ func (pkg *LocalPackage) DownFuncs(
 	return vals
 }
 
+// LinkKeepSections retrieves the names of the linker input sections that must
+// survive linker garbage collection (pkg.link_keep_sections).
+func (pkg *LocalPackage) LinkKeepSections(settings *cfgv.Settings) []string {
+	vals, err := pkg.PkgY.GetValStringSlice("pkg.link_keep_sections", settings)
+	util.OneTimeWarningError(err)
+	return vals
+}
+
+// LinkKeepSymbols retrieves the names of the symbols that must survive linker
+// garbage collection (pkg.link_keep_symbols).
+func (pkg *LocalPackage) LinkKeepSymbols(settings *cfgv.Settings) []string {
+	vals, err := pkg.PkgY.GetValStringSlice("pkg.link_keep_symbols", settings)
+	util.OneTimeWarningError(err)
+	return vals
+}
+
 func (pkg *LocalPackage) PreBuildCmds(
 	settings *cfgv.Settings) map[string]string {
 
@@ -496,7 +545,9 @@ func ReadLocalPackageRecursive(repo *repo.Repo,
 		}
 	}
 
-	if util.NodeNotExist(filepath.Join(basePath, pkgName, PACKAGE_FILE_NAME)) {
+	pkgFile := config.FindFile(
+		filepath.Join(basePath, pkgName, PACKAGE_FILE_NAME))
+	if util.NodeNotExist(pkgFile) {
 		return warnings, nil
 	}
 
@@ -39,6 +39,7 @@ const (
 	PACKAGE_TYPE_LIB
 	PACKAGE_TYPE_TRANSIENT
 	PACKAGE_TYPE_BSP
+	PACKAGE_TYPE_BUNDLE
 	PACKAGE_TYPE_UNITTEST
 	PACKAGE_TYPE_APP
 	PACKAGE_TYPE_CONFIG
@@ -55,6 +56,7 @@ var PackageTypeNames = map[interfaces.PackageType]string{
 	PACKAGE_TYPE_LIB:       "lib",
 	PACKAGE_TYPE_TRANSIENT: "transient",
 	PACKAGE_TYPE_BSP:       "bsp",
+	PACKAGE_TYPE_BUNDLE:    "bundle",
 	PACKAGE_TYPE_UNITTEST:  "unittest",
 	PACKAGE_TYPE_APP:       "app",
 	PACKAGE_TYPE_CONFIG:    "config",
@@ -97,4 +99,6 @@ type PackageDesc struct {
 	Homepage    string
 	Description string
 	Keywords    []string
+	// SPDX license expression covering the package's sources
+	License string
 }
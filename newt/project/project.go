@@ -25,9 +25,11 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
 
 	"mynewt.apache.org/newt/newt/compat"
 	"mynewt.apache.org/newt/newt/config"
@@ -45,6 +47,7 @@ import (
 var globalProject *Project = nil
 
 const PROJECT_FILE_NAME = "project.yml"
+const PROJECT_LOCAL_FILE_NAME = "project.local.yml"
 const PATCHES_DIR = "patches"
 
 var ignoreSearchDirs []string = []string{
@@ -92,10 +95,19 @@ type Project struct {
 	// duplicate warnings.
 	unknownRepoVers map[string]struct{}
 
+	// The commits that upgrades performed during this run brought into each
+	// repo.
+	changelogs []*install.RepoChangelog
+
 	yc ycfg.YCfg
 }
 
-func initProject(dir string, download bool) error {
+// Loads the project in the specified directory.  If `download` is true,
+// missing repos are downloaded and the repos matching `pred` are installed or
+// upgraded.  A nil predicate selects all repos.
+func initProject(dir string, download bool,
+	pred func(r *repo.Repo) bool) error {
+
 	var err error
 
 	globalProject, err = LoadProject(dir, download)
@@ -105,7 +117,9 @@ func initProject(dir string, download bool) error {
 
 	if download {
 		err = globalProject.UpgradeIf(newtutil.NewtForce, newtutil.NewtAsk,
-			func(r *repo.Repo) bool { return !r.IsExternal(r.Path()) })
+			func(r *repo.Repo) bool {
+				return !r.IsExternal(r.Path()) && (pred == nil || pred(r))
+			})
 		if err != nil {
 			return err
 		}
@@ -118,14 +132,14 @@ func initProject(dir string, download bool) error {
 	return nil
 }
 
-func initialize(download bool) error {
+func initialize(download bool, pred func(r *repo.Repo) bool) error {
 	if globalProject == nil {
 		wd, err := os.Getwd()
 		wd = filepath.ToSlash(wd)
 		if err != nil {
 			return util.NewNewtError(err.Error())
 		}
-		if err := initProject(wd, download); err != nil {
+		if err := initProject(wd, download, pred); err != nil {
 			return err
 		}
 	}
@@ -133,14 +147,22 @@ func initialize(download bool) error {
 }
 
 func TryGetProject() (*Project, error) {
-	if err := initialize(false); err != nil {
+	if err := initialize(false, nil); err != nil {
 		return nil, err
 	}
 	return globalProject, nil
 }
 
 func TryGetOrDownloadProject() (*Project, error) {
-	if err := initialize(true); err != nil {
+	return TryGetOrDownloadProjectIf(nil)
+}
+
+// Like TryGetOrDownloadProject(), but only installs or upgrades the repos
+// matching the specified predicate.
+func TryGetOrDownloadProjectIf(
+	pred func(r *repo.Repo) bool) (*Project, error) {
+
+	if err := initialize(true, pred); err != nil {
 		return nil, err
 	}
 	return globalProject, nil
@@ -373,6 +395,119 @@ func (proj *Project) Warnings() []string {
 	return proj.warnings
 }
 
+// CompilerVersionReq returns the version constraint that every compiler used by
+// the project must satisfy (`project.compiler_version`), or "" if there is
+// none.
+func (proj *Project) CompilerVersionReq() string {
+	req, err := proj.yc.GetValString("project.compiler_version", nil)
+	util.OneTimeWarningError(err)
+
+	return req
+}
+
+// TargetGroups returns the named groups of targets defined in
+// `project.target_groups`.  Commands that accept a list of targets expand
+// "@<group>" to the group's members.
+func (proj *Project) TargetGroups() (map[string][]string, error) {
+	m, err := proj.yc.GetValStringMap("project.target_groups", nil)
+	util.OneTimeWarningError(err)
+
+	groups := make(map[string][]string, len(m))
+	for name, v := range m {
+		members, err := cast.ToStringSliceE(v)
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"project.target_groups: group \"%s\" is not a list of "+
+					"targets", name)
+		}
+		groups[name] = members
+	}
+
+	return groups, nil
+}
+
+// TargetAliases returns the alternate target names defined in
+// `project.target_aliases`, mapped to the names of the targets they refer to.
+func (proj *Project) TargetAliases() map[string]string {
+	aliases, err := proj.yc.GetValStringMapString(
+		"project.target_aliases", nil)
+	util.OneTimeWarningError(err)
+
+	return aliases
+}
+
+// A destination that `newt publish` uploads build artifacts to, as defined in
+// `project.publish`:
+//
+//	project.publish:
+//	    release:
+//	        url: "s3://fw-releases/myproject"
+//	    nightly:
+//	        url: "https://artifacts.example.com/upload"
+//	        headers:
+//	            Authorization: "Bearer ${ARTIFACT_TOKEN}"
+//
+// `${VAR}` references in the URL and header values are replaced with the
+// values of the corresponding environment variables.
+type PublishDest struct {
+	Name    string
+	Url     string
+	Headers map[string]string
+}
+
+// PublishDestNames returns the names of the destinations in
+// `project.publish`, sorted.
+func (proj *Project) PublishDestNames() []string {
+	m, err := proj.yc.GetValStringMap("project.publish", nil)
+	util.OneTimeWarningError(err)
+
+	names := make([]string, 0, len(m))
+	for name, _ := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// PublishDest returns the `project.publish` destination with the specified
+// name.
+func (proj *Project) PublishDest(name string) (*PublishDest, error) {
+	m, err := proj.yc.GetValStringMap("project.publish", nil)
+	util.OneTimeWarningError(err)
+
+	v, ok := m[name]
+	if !ok {
+		return nil, util.FmtNewtError(
+			"unknown publish destination \"%s\"; project.yml defines: %s",
+			name, strings.Join(proj.PublishDestNames(), ", "))
+	}
+
+	fields, err := cast.ToStringMapE(v)
+	if err != nil {
+		return nil, util.FmtNewtError(
+			"project.publish: destination \"%s\" is not a map", name)
+	}
+
+	what := "publish destination \"" + name + "\""
+	dest := &PublishDest{
+		Name:    name,
+		Url:     expandEnvField(what, "url", cast.ToString(fields["url"])),
+		Headers: map[string]string{},
+	}
+	if dest.Url == "" {
+		return nil, util.FmtNewtError(
+			"project.publish: destination \"%s\" does not specify a url",
+			name)
+	}
+
+	for k, hv := range cast.ToStringMapString(fields["headers"]) {
+		dest.Headers[k] = expandEnvField(what, "headers."+k, hv)
+	}
+
+	return dest, nil
+}
+
 // Selects repositories from the global state that satisfy the specified
 // predicate.
 func (proj *Project) SelectRepos(pred func(r *repo.Repo) bool) []*repo.Repo {
@@ -408,7 +543,34 @@ func (proj *Project) UpgradeIf(
 		return err
 	}
 
-	return inst.Upgrade(specifiedRepoList, force, ask)
+	err = inst.Upgrade(specifiedRepoList, force, ask)
+	proj.changelogs = append(proj.changelogs, inst.Changelogs()...)
+
+	return err
+}
+
+// UpgradeChangelogs returns the commits that the upgrades performed during
+// this run brought into each repo.
+func (proj *Project) UpgradeChangelogs() []*install.RepoChangelog {
+	return proj.changelogs
+}
+
+// Reports which of the repos matching the specified predicate have releases
+// newer than the versions that `project.yml` pins them to.
+func (proj *Project) OutdatedIf(predicate func(r *repo.Repo) bool) error {
+	// Make sure we have an up to date copy of all `repository.yml` files.
+	if err := proj.downloadRepositoryYmlFiles(); err != nil {
+		return err
+	}
+
+	repoList := proj.SelectRepos(predicate)
+
+	inst, err := install.NewInstaller(proj.repos, proj.rootRepoReqs)
+	if err != nil {
+		return err
+	}
+
+	return inst.Outdated(repoList)
 }
 
 func (proj *Project) InfoIf(predicate func(r *repo.Repo) bool,
@@ -462,6 +624,10 @@ func (proj *Project) loadRepo(name string, fields map[string]string) (
 		return nil, err
 	}
 
+	if err := r.SetVersionPolicy(fields["version_policy"]); err != nil {
+		return nil, err
+	}
+
 	for _, ignDir := range ignoreSearchDirs {
 		r.AddIgnoreDir(ignDir)
 	}
@@ -515,6 +681,10 @@ func (proj *Project) checkNewtVer() error {
 func (proj *Project) loadRepoDeps(download bool) error {
 	seen := map[string]struct{}{}
 
+	// Repos loaded during the current pass that have not been added to the
+	// project yet.
+	var pending map[string]*repo.Repo
+
 	loadDeps := func(r *repo.Repo) ([]*repo.Repo, error) {
 		var newRepos []*repo.Repo
 
@@ -525,6 +695,9 @@ func (proj *Project) loadRepoDeps(download bool) error {
 					seen[r.Name()] = struct{}{}
 
 					depRepo := proj.repos[dep.Name]
+					if depRepo == nil {
+						depRepo = pending[dep.Name]
+					}
 					if depRepo == nil {
 						var err error
 
@@ -543,17 +716,9 @@ func (proj *Project) loadRepoDeps(download bool) error {
 						if depRepo == nil {
 							continue
 						}
-						if err := proj.addRepo(depRepo, download); err != nil {
-							return nil, err
-						}
+						pending[dep.Name] = depRepo
 					}
 					newRepos = append(newRepos, depRepo)
-
-					if download {
-						if _, err := depRepo.UpdateDesc(); err != nil {
-							return nil, err
-						}
-					}
 				}
 			}
 		}
@@ -564,6 +729,7 @@ func (proj *Project) loadRepoDeps(download bool) error {
 	curRepos := proj.repos.Sorted()
 	for len(curRepos) > 0 {
 		var nextRepos []*repo.Repo
+		pending = map[string]*repo.Repo{}
 
 		for _, r := range curRepos {
 			depRepos, err := loadDeps(r)
@@ -574,15 +740,67 @@ func (proj *Project) loadRepoDeps(download bool) error {
 			nextRepos = append(nextRepos, depRepos...)
 		}
 
+		// Clone the newly discovered repos and download their
+		// `repository.yml` files concurrently.
+		if err := proj.addRepos(sortedRepos(pending), download); err != nil {
+			return err
+		}
+		if download {
+			if err := updateRepoDescs(uniqueRepos(nextRepos)); err != nil {
+				return err
+			}
+		}
+
 		curRepos = nextRepos
 	}
 
 	return nil
 }
 
+// Returns the repos in the specified map, sorted by name.
+func sortedRepos(m map[string]*repo.Repo) []*repo.Repo {
+	names := make([]string, 0, len(m))
+	for name, _ := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	repos := make([]*repo.Repo, len(names))
+	for i, name := range names {
+		repos[i] = m[name]
+	}
+
+	return repos
+}
+
+// Removes duplicate entries from a slice of repos, preserving order.
+func uniqueRepos(repos []*repo.Repo) []*repo.Repo {
+	seen := map[*repo.Repo]struct{}{}
+
+	var unique []*repo.Repo
+	for _, r := range repos {
+		if _, ok := seen[r]; !ok {
+			seen[r] = struct{}{}
+			unique = append(unique, r)
+		}
+	}
+
+	return unique
+}
+
+// Downloads the `repository.yml` file of each specified repo.  Up to
+// `newtutil.NewtNumJobs` repos are updated concurrently.
+func updateRepoDescs(repos []*repo.Repo) error {
+	return newtutil.ForEachParallel(len(repos), func(i int) error {
+		_, err := repos[i].UpdateDesc()
+		return err
+	})
+}
+
 func (proj *Project) downloadRepositoryYmlFiles() error {
 	// Download the `repository.yml` file for each root-level repo (those
 	// specified in the `project.yml` file).
+	var toUpdate []*repo.Repo
 	for _, r := range proj.repos.Sorted() {
 		if r.IsUpdated() {
 			continue
@@ -609,9 +827,11 @@ func (proj *Project) downloadRepositoryYmlFiles() error {
 			}
 		}
 
-		if _, err := r.UpdateDesc(); err != nil {
-			return err
-		}
+		toUpdate = append(toUpdate, r)
+	}
+
+	if err := updateRepoDescs(toUpdate); err != nil {
+		return err
 	}
 
 	// Download the `repository.yml` file for each depended-on repo.
@@ -681,6 +901,87 @@ func (proj *Project) addRepo(r *repo.Repo, download bool) error {
 	return nil
 }
 
+// Adds the specified repos to the project.  If `download` is true, repos that
+// are not installed yet are cloned, up to `newtutil.NewtNumJobs` at a time.
+func (proj *Project) addRepos(repos []*repo.Repo, download bool) error {
+	if download {
+		err := newtutil.ForEachParallel(len(repos), func(i int) error {
+			return repos[i].EnsureExists()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, r := range repos {
+		if err := proj.addRepo(r, download); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Merges the developer's `project.local.yml` file, if present, on top of the
+// project configuration.  Maps (e.g., repo descriptors) are merged key by key
+// and lists are appended to; all other values are replaced.
+func mergeLocalConfig(yc *ycfg.YCfg, path string) error {
+	if util.NodeNotExist(path) {
+		return nil
+	}
+
+	local, err := config.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fi := util.FileInfo{
+		Path:   path,
+		Parent: nil,
+	}
+	for k, v := range local.AllSettings() {
+		switch v.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			err = yc.MergeFromFile(k, v, &fi)
+		default:
+			err = yc.ReplaceFromFile(k, v, &fi)
+		}
+		if err != nil {
+			return util.FmtNewtError("Failure merging \"%s\": %s",
+				path, err.Error())
+		}
+	}
+
+	util.StatusMessage(util.VERBOSITY_VERBOSE,
+		"Applied local project overrides from %s\n", path)
+
+	return nil
+}
+
+var envVarRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Replaces each `${VAR}` in a repo field with the value of the corresponding
+// environment variable.  Undefined variables expand to "" with a warning.
+func expandRepoField(repoName string, field string, val string) string {
+	return expandEnvField("repo \""+repoName+"\"", field, val)
+}
+
+// Replaces each `${VAR}` in a configuration field with the value of the
+// corresponding environment variable.  `what` names the object containing
+// the field in the warning for undefined variables.
+func expandEnvField(what string, field string, val string) string {
+	return envVarRe.ReplaceAllStringFunc(val, func(ref string) string {
+		name := envVarRe.FindStringSubmatch(ref)[1]
+		envVal, ok := os.LookupEnv(name)
+		if !ok {
+			util.OneTimeWarning(
+				"%s: field \"%s\" references undefined "+
+					"environment variable \"%s\"", what, field, name)
+		}
+		return envVal
+	})
+}
+
 func (proj *Project) createRegexpPatterns(patterns []string) ([]*regexp.Regexp, error) {
 	var ret []*regexp.Regexp
 	var errLines []string
@@ -712,10 +1013,17 @@ func (proj *Project) createRegexpPatterns(patterns []string) ([]*regexp.Regexp,
 }
 
 func (proj *Project) loadConfig(download bool) error {
-	yc, err := config.ReadFile(proj.BasePath + "/" + PROJECT_FILE_NAME)
+	yc, err := config.ReadFileIncludes(proj.BasePath+"/"+PROJECT_FILE_NAME,
+		"project.include")
 	if err != nil {
 		return util.NewNewtError(err.Error())
 	}
+
+	if err := mergeLocalConfig(&yc,
+		proj.BasePath+"/"+PROJECT_LOCAL_FILE_NAME); err != nil {
+
+		return err
+	}
 	// Store configuration object for access to future values,
 	// this avoids keeping every string around as a project variable when
 	// we need to process it later.
@@ -754,8 +1062,20 @@ func (proj *Project) loadConfig(download bool) error {
 		r.AddIgnoreDir(ignDir)
 	}
 
+	// Don't search an output directory that is inside the project.
+	if newtutil.NewtOutDir != "" {
+		rel, err := filepath.Rel(proj.BasePath, newtutil.NewtOutDir)
+		if err == nil && rel != ".." &&
+			!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+
+			r.AddIgnoreDir(rel)
+		}
+	}
+
 	// Assume every item starting with "repository." is a repository descriptor
 	// and try to load it.
+	var rootRepos []*repo.Repo
+	rootReqs := deprepo.RequirementMap{}
 	for k, _ := range yc.AllSettings() {
 		repoName := strings.TrimPrefix(k, "repository.")
 		if repoName != k {
@@ -766,6 +1086,10 @@ func (proj *Project) loadConfig(download bool) error {
 				continue
 			}
 
+			for name, val := range fields {
+				fields[name] = expandRepoField(repoName, name, val)
+			}
+
 			r, err := proj.loadRepo(repoName, fields)
 			if err != nil {
 				// if `repository.yml` does not exist, it is not an error; we
@@ -778,6 +1102,11 @@ func (proj *Project) loadConfig(download bool) error {
 				continue
 			}
 
+			// Only check out the specified directories of the repo.
+			paths, err := yc.GetValStringSlice(k+".paths", nil)
+			util.OneTimeWarningError(err)
+			r.Downloader().SetSparsePaths(paths)
+
 			verReq, err := newtutil.ParseRepoVersion(fields["vers"])
 			if err != nil {
 				return util.FmtNewtError(
@@ -786,13 +1115,18 @@ func (proj *Project) loadConfig(download bool) error {
 					repoName, fields["vers"], err.Error())
 			}
 
-			if err := proj.addRepo(r, download); err != nil {
-				return err
-			}
-			proj.rootRepoReqs[repoName] = verReq
+			rootRepos = append(rootRepos, r)
+			rootReqs[repoName] = verReq
 		}
 	}
 
+	if err := proj.addRepos(rootRepos, download); err != nil {
+		return err
+	}
+	for name, verReq := range rootReqs {
+		proj.rootRepoReqs[name] = verReq
+	}
+
 	// Read `repository.yml` files belonging to dependee repos from disk.
 	// These repos might not be specified in the `project.yml` file, but they
 	// are still part of the project.
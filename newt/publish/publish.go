@@ -0,0 +1,313 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package publish uploads a target's build artifacts to a destination
+// configured in project.yml (`project.publish`).
+package publish
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apache/mynewt-artifact/manifest"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/newt/target"
+	"mynewt.apache.org/newt/util"
+)
+
+// The name of the file that records what was published.  It is written to
+// the target's bin directory and uploaded alongside the artifacts.
+const RECORD_FILENAME = "publish.json"
+
+// A file that is uploaded.
+type Artifact struct {
+	// Local path of the file.
+	Path string `json:"-"`
+
+	// Path of the file relative to the destination directory.
+	Name string `json:"name"`
+
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Describes a published build.
+type Record struct {
+	Target    string     `json:"target"`
+	Version   string     `json:"version"`
+	BuildID   string     `json:"build_id"`
+	Dest      string     `json:"dest"`
+	Url       string     `json:"url"`
+	Time      string     `json:"time"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// The files that are published from each build, if they exist.
+var artifactExts = []string{".img", ".hex", ".elf", ".elf.bin", ".elf.map"}
+
+func hashFile(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, util.ChildNewtError(err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, util.ChildNewtError(err)
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), n, nil
+}
+
+func newArtifact(path string, name string) (Artifact, error) {
+	sum, size, err := hashFile(path)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	return Artifact{
+		Path:   path,
+		Name:   name,
+		Size:   size,
+		Sha256: sum,
+	}, nil
+}
+
+// Collects the artifacts of one build (app or loader).  `prefix` is prepended
+// to the name of each artifact.
+func buildArtifacts(t *target.Target, buildName string, appName string,
+	prefix string) ([]Artifact, error) {
+
+	elfPath := builder.AppElfPath(t.FullName(), buildName, appName)
+	if util.NodeNotExist(elfPath) {
+		return nil, util.FmtNewtError(
+			"target %s has not been built (%s is missing); run "+
+				"`newt build %s` first", t.FullName(), elfPath, t.Name())
+	}
+
+	base := strings.TrimSuffix(elfPath, ".elf")
+
+	var artifacts []Artifact
+	for _, ext := range artifactExts {
+		path := base + ext
+		if util.NodeNotExist(path) {
+			continue
+		}
+
+		a, err := newArtifact(path, prefix+filepath.Base(path))
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, a)
+	}
+
+	return artifacts, nil
+}
+
+// Collects the artifacts of a target's most recent build: the app's (and
+// loader's) image, hex, ELF, binary, and map files, and the manifest.
+func collect(t *target.Target) (manifest.Manifest, []Artifact, error) {
+	if t.App() == nil {
+		return manifest.Manifest{}, nil, util.FmtNewtError(
+			"target %s does not specify an app package", t.FullName())
+	}
+
+	artifacts, err := buildArtifacts(t, builder.BUILD_NAME_APP,
+		t.App().FullName(), "")
+	if err != nil {
+		return manifest.Manifest{}, nil, err
+	}
+
+	if t.Loader() != nil {
+		la, err := buildArtifacts(t, builder.BUILD_NAME_LOADER,
+			t.Loader().FullName(), "loader/")
+		if err != nil {
+			return manifest.Manifest{}, nil, err
+		}
+		artifacts = append(artifacts, la...)
+	}
+
+	mpath := builder.ManifestPath(t.FullName(), builder.BUILD_NAME_APP,
+		t.App().FullName())
+	m, err := manifest.ReadManifest(mpath)
+	if err != nil {
+		return manifest.Manifest{}, nil, util.ChildNewtError(err)
+	}
+
+	a, err := newArtifact(mpath, filepath.Base(mpath))
+	if err != nil {
+		return manifest.Manifest{}, nil, err
+	}
+	artifacts = append(artifacts, a)
+
+	return m, artifacts, nil
+}
+
+// DestDir returns the location that a target's artifacts are uploaded to:
+// <url>/<target>/<version>.
+func DestDir(dest *project.PublishDest, t *target.Target,
+	version string) string {
+
+	return strings.TrimSuffix(dest.Url, "/") + "/" + t.ShortName() + "/" +
+		version
+}
+
+func uploadHttp(dest *project.PublishDest, src string, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, dst, f)
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+	req.ContentLength = info.Size()
+	for k, v := range dest.Headers {
+		req.Header.Set(k, v)
+	}
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return util.FmtNewtError("error uploading %s: %s", dst, err.Error())
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return util.FmtNewtError("error uploading %s: status %s",
+			dst, rsp.Status)
+	}
+
+	return nil
+}
+
+func uploadFile(src string, dstPath string) error {
+	if err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return util.CopyFile(src, dstPath)
+}
+
+// Uploads a single file.  The transport is selected by the URL's scheme:
+// "s3" and "gs" use the `aws s3 cp` and `gsutil cp` commands, "http" and
+// "https" use a PUT request with the destination's headers, and "file" copies
+// to a local or mounted directory.
+func upload(dest *project.PublishDest, src string, dst string) error {
+	u, err := url.Parse(dst)
+	if err != nil {
+		return util.FmtNewtError("invalid publish url: %s", dst)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		_, err = util.ShellCommand(
+			[]string{"aws", "s3", "cp", "--only-show-errors", src, dst}, nil)
+		return err
+
+	case "gs":
+		_, err = util.ShellCommand([]string{"gsutil", "-q", "cp", src, dst},
+			nil)
+		return err
+
+	case "http", "https":
+		return uploadHttp(dest, src, dst)
+
+	case "file":
+		return uploadFile(src, u.Path)
+
+	default:
+		return util.FmtNewtError(
+			"publish destination \"%s\" has unsupported url scheme \"%s\"; "+
+				"must be one of: s3, gs, http, https, file",
+			dest.Name, u.Scheme)
+	}
+}
+
+// Publish uploads the artifacts of a target's most recent build to the
+// specified destination, followed by a record listing each artifact and its
+// sha256 digest.  The record is also written to the target's bin directory.
+// If `dryRun` is true, nothing is uploaded or written.
+func Publish(t *target.Target, dest *project.PublishDest,
+	dryRun bool) (*Record, error) {
+
+	m, artifacts, err := collect(t)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := DestDir(dest, t, m.Version)
+	rec := &Record{
+		Target:    t.FullName(),
+		Version:   m.Version,
+		BuildID:   m.BuildID,
+		Dest:      dest.Name,
+		Url:       dir,
+		Time:      time.Now().Format(time.RFC3339),
+		Artifacts: artifacts,
+	}
+
+	if dryRun {
+		return rec, nil
+	}
+
+	for _, a := range artifacts {
+		util.StatusMessage(util.VERBOSITY_VERBOSE, "Uploading %s to %s\n",
+			a.Path, dir+"/"+a.Name)
+
+		if err := upload(dest, a.Path, dir+"/"+a.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	js, err := json.MarshalIndent(rec, "", "    ")
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	recPath := builder.TargetBinDir(t.FullName()) + "/" + RECORD_FILENAME
+	if err := ioutil.WriteFile(recPath, append(js, '\n'), 0644); err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	if err := upload(dest, recPath, dir+"/"+RECORD_FILENAME); err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
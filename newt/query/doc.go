@@ -0,0 +1,197 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package query implements `newt query`: it describes the project as a JSON
+// document and evaluates path expressions against it.
+package query
+
+import (
+	"encoding/json"
+	"sort"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/dump"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/newt/target"
+	"mynewt.apache.org/newt/util"
+)
+
+type Repo struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Local   bool   `json:"local"`
+	Version string `json:"version,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+type Package struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Repo        string   `json:"repo"`
+	Path        string   `json:"path"`
+	Description string   `json:"description,omitempty"`
+	License     string   `json:"license,omitempty"`
+	Deps        []string `json:"deps,omitempty"`
+}
+
+type Target struct {
+	Name         string            `json:"name"`
+	App          string            `json:"app,omitempty"`
+	Bsp          string            `json:"bsp,omitempty"`
+	Loader       string            `json:"loader,omitempty"`
+	BuildProfile string            `json:"build_profile,omitempty"`
+	Syscfg       map[string]string `json:"syscfg,omitempty"`
+}
+
+type Doc struct {
+	Project  string    `json:"project"`
+	Path     string    `json:"path"`
+	Repos    []Repo    `json:"repos"`
+	Packages []Package `json:"packages"`
+	Targets  []Target  `json:"targets"`
+
+	// Full resolution results; only present for targets that were
+	// explicitly requested, as resolving a target is expensive.
+	// [target-name] => report
+	Resolution map[string]dump.Report `json:"resolution,omitempty"`
+}
+
+func newRepo(proj *project.Project, rname string) Repo {
+	r := proj.FindRepo(rname)
+
+	qr := Repo{
+		Name:  r.Name(),
+		Path:  r.Path(),
+		Local: r.IsLocal(),
+	}
+
+	if !r.IsLocal() {
+		if ver, err := r.InstalledVersion(); err == nil && ver != nil {
+			qr.Version = ver.String()
+		}
+		if hash, err := r.CurrentHash(); err == nil {
+			qr.Hash = hash
+		}
+	}
+
+	return qr
+}
+
+func newPackage(lpkg *pkg.LocalPackage) Package {
+	deps, err := lpkg.PkgY.GetValStringSlice("pkg.deps", nil)
+	util.OneTimeWarningError(err)
+
+	return Package{
+		Name:        lpkg.FullName(),
+		Type:        pkg.PackageTypeNames[lpkg.Type()],
+		Repo:        lpkg.Repo().Name(),
+		Path:        lpkg.BasePath(),
+		Description: lpkg.Desc().Description,
+		License:     lpkg.Desc().License,
+		Deps:        deps,
+	}
+}
+
+func newTarget(t *target.Target) Target {
+	syscfg, err := t.Package().SyscfgY.GetValStringMapString(
+		"syscfg.vals", nil)
+	util.OneTimeWarningError(err)
+
+	return Target{
+		Name:         t.FullName(),
+		App:          t.AppName,
+		Bsp:          t.BspName,
+		Loader:       t.LoaderName,
+		BuildProfile: t.BuildProfile,
+		Syscfg:       syscfg,
+	}
+}
+
+// Builds a document describing the project's repos, packages, and targets.
+// Each target builder in `tbs` is resolved and its full report is included
+// under "resolution".
+func NewDoc(proj *project.Project,
+	tbs []*builder.TargetBuilder) (Doc, error) {
+
+	doc := Doc{
+		Project: proj.Name(),
+		Path:    proj.Path(),
+	}
+
+	rnames := make([]string, 0, len(proj.Repos()))
+	for rname, _ := range proj.Repos() {
+		rnames = append(rnames, rname)
+	}
+	sort.Strings(rnames)
+	for _, rname := range rnames {
+		doc.Repos = append(doc.Repos, newRepo(proj, rname))
+	}
+
+	var lpkgs []*pkg.LocalPackage
+	for _, p := range proj.PackagesOfType(-1) {
+		lpkgs = append(lpkgs, p.(*pkg.LocalPackage))
+	}
+	sort.Slice(lpkgs, func(i int, j int) bool {
+		return lpkgs[i].FullName() < lpkgs[j].FullName()
+	})
+	for _, lpkg := range lpkgs {
+		doc.Packages = append(doc.Packages, newPackage(lpkg))
+	}
+
+	tmap := target.GetTargets()
+	tnames := make([]string, 0, len(tmap))
+	for name, _ := range tmap {
+		tnames = append(tnames, name)
+	}
+	sort.Strings(tnames)
+	for _, name := range tnames {
+		doc.Targets = append(doc.Targets, newTarget(tmap[name]))
+	}
+
+	for _, tb := range tbs {
+		rpt, err := dump.NewReport(tb)
+		if err != nil {
+			return doc, err
+		}
+
+		if doc.Resolution == nil {
+			doc.Resolution = map[string]dump.Report{}
+		}
+		doc.Resolution[tb.GetTarget().FullName()] = rpt
+	}
+
+	return doc, nil
+}
+
+// Converts the document to generic JSON values (maps, slices, strings,
+// numbers, and booleans) so that it can be traversed by a path expression.
+func (doc *Doc) Generic() (interface{}, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	var itf interface{}
+	if err := json.Unmarshal(b, &itf); err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	return itf, nil
+}
@@ -0,0 +1,286 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Path expressions are a subset of JSONPath:
+//
+//     [$] step...
+//
+// where each step is one of:
+//
+//     .name           Member of an object
+//     ["name"]        Member of an object (name may contain any character)
+//     [N]             Element of an array; negative indices count from the end
+//     .* or [*]       All members of an object or all elements of an array
+//     [?name==value]  Elements whose member `name` equals `value`
+//     [?name!=value]  Elements whose member `name` does not equal `value`
+//
+// The leading `.` may be omitted from the first step (e.g., `targets[*].bsp`).
+
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+type stepCode int
+
+const (
+	STEP_MEMBER stepCode = iota
+	STEP_INDEX
+	STEP_WILDCARD
+	STEP_FILTER
+)
+
+type step struct {
+	Code  stepCode
+	Name  string
+	Index int
+
+	// Only used by STEP_FILTER.
+	Value  string
+	Negate bool
+}
+
+type Path struct {
+	Text  string
+	steps []step
+}
+
+func parseErr(expr string, off int, msg string) error {
+	return util.FmtNewtError("invalid query \"%s\" at offset %d: %s",
+		expr, off, msg)
+}
+
+// Parses the contents of a bracketed step (excluding the brackets).
+func parseBracket(expr string, off int, body string) (step, error) {
+	body = strings.TrimSpace(body)
+
+	switch {
+	case body == "*":
+		return step{Code: STEP_WILDCARD}, nil
+
+	case strings.HasPrefix(body, "?"):
+		cond := body[1:]
+		s := step{Code: STEP_FILTER}
+
+		op := "=="
+		i := strings.Index(cond, "==")
+		if j := strings.Index(cond, "!="); j >= 0 && (i < 0 || j < i) {
+			i = j
+			op = "!="
+			s.Negate = true
+		}
+		if i < 0 {
+			return s, parseErr(expr, off, "filter requires == or !=")
+		}
+
+		s.Name = strings.TrimSpace(cond[:i])
+		s.Value = unquote(strings.TrimSpace(cond[i+len(op):]))
+		if s.Name == "" {
+			return s, parseErr(expr, off, "filter missing member name")
+		}
+		return s, nil
+
+	case len(body) >= 2 && (body[0] == '"' || body[0] == '\''):
+		if body[len(body)-1] != body[0] {
+			return step{}, parseErr(expr, off, "unterminated string")
+		}
+		return step{Code: STEP_MEMBER, Name: body[1 : len(body)-1]}, nil
+
+	default:
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return step{}, parseErr(expr, off,
+				fmt.Sprintf("invalid index \"%s\"", body))
+		}
+		return step{Code: STEP_INDEX, Index: n}, nil
+	}
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Parses a path expression.
+func ParsePath(expr string) (Path, error) {
+	p := Path{Text: expr}
+
+	s := strings.TrimSpace(expr)
+	s = strings.TrimPrefix(s, "$")
+
+	off := 0
+	first := true
+	for off < len(s) {
+		c := s[off]
+
+		switch {
+		case c == '[':
+			end := strings.IndexByte(s[off:], ']')
+			if end < 0 {
+				return p, parseErr(expr, off, "unterminated '['")
+			}
+			st, err := parseBracket(expr, off, s[off+1:off+end])
+			if err != nil {
+				return p, err
+			}
+			p.steps = append(p.steps, st)
+			off += end + 1
+
+		case c == '.' || first:
+			if c == '.' {
+				off++
+			}
+			end := strings.IndexAny(s[off:], ".[")
+			if end < 0 {
+				end = len(s) - off
+			}
+			name := s[off : off+end]
+			if name == "" {
+				return p, parseErr(expr, off, "empty member name")
+			}
+			if name == "*" {
+				p.steps = append(p.steps, step{Code: STEP_WILDCARD})
+			} else {
+				p.steps = append(p.steps, step{Code: STEP_MEMBER, Name: name})
+			}
+			off += end
+
+		default:
+			return p, parseErr(expr, off,
+				fmt.Sprintf("unexpected character '%c'", c))
+		}
+
+		first = false
+	}
+
+	return p, nil
+}
+
+// Indicates whether the path can match more than one value.
+func (p *Path) IsMulti() bool {
+	for _, st := range p.steps {
+		if st.Code == STEP_WILDCARD || st.Code == STEP_FILTER {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the values of an object, sorted by key, or the elements of an
+// array.  Other values have no children.
+func children(node interface{}) []interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(n))
+		for k, _ := range n {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		vals := make([]interface{}, len(keys))
+		for i, k := range keys {
+			vals[i] = n[k]
+		}
+		return vals
+
+	case []interface{}:
+		return n
+
+	default:
+		return nil
+	}
+}
+
+func scalarString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return x
+	default:
+		return fmt.Sprintf("%v", x)
+	}
+}
+
+func applyStep(st step, node interface{}) []interface{} {
+	switch st.Code {
+	case STEP_MEMBER:
+		if m, ok := node.(map[string]interface{}); ok {
+			if v, ok := m[st.Name]; ok {
+				return []interface{}{v}
+			}
+		}
+
+	case STEP_INDEX:
+		if a, ok := node.([]interface{}); ok {
+			i := st.Index
+			if i < 0 {
+				i += len(a)
+			}
+			if i >= 0 && i < len(a) {
+				return []interface{}{a[i]}
+			}
+		}
+
+	case STEP_WILDCARD:
+		return children(node)
+
+	case STEP_FILTER:
+		var out []interface{}
+		for _, c := range children(node) {
+			m, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			v, ok := m[st.Name]
+			match := ok && scalarString(v) == st.Value
+			if match != st.Negate {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+
+	return nil
+}
+
+// Evaluates the path against a generic JSON document and returns all
+// matching values.
+func (p *Path) Eval(doc interface{}) []interface{} {
+	nodes := []interface{}{doc}
+
+	for _, st := range p.steps {
+		var next []interface{}
+		for _, n := range nodes {
+			next = append(next, applyStep(st, n)...)
+		}
+		nodes = next
+	}
+
+	return nodes
+}
@@ -42,6 +42,20 @@ import (
 const REPO_NAME_LOCAL = "local"
 const REPO_DEFAULT_PERMS = 0755
 
+// Determines which commit is checked out when a repo is installed or upgraded
+// to a particular version.
+const (
+	// Use the version's release tag.  If the tag does not exist yet, use its
+	// latest release candidate (e.g., "mynewt_1_7_0_rc2_tag").
+	VERSION_POLICY_RC = "rc"
+
+	// Only use the version's release tag, never a release candidate.
+	VERSION_POLICY_STABLE = "stable"
+
+	// Ignore the version and use the tip of the repo's main branch.
+	VERSION_POLICY_LATEST = "latest"
+)
+
 const REPO_FILE_NAME = "repository.yml"
 const REPOS_DIR = "repos"
 const PATCHES_DIR = "patches"
@@ -65,6 +79,10 @@ type Repo struct {
 	// True if this repo was cloned during this invocation of newt.
 	newlyCloned bool
 
+	// One of the VERSION_POLICY_[...] constants; "" means
+	// VERSION_POLICY_RC.
+	verPolicy string
+
 	// commit => [dependencies]
 	deps map[string][]*RepoDependency
 
@@ -282,7 +300,7 @@ func (r *Repo) downloadRepo(commit string) error {
 
 	if r.hasSubmodules {
 		if len(r.submodules) == 0 {
-			util.StatusMessage(util.VERBOSITY_VERBOSE, "Skipping submodule updates\n")
+			util.StatusMessage(util.VERBOSITY_VERBOSE, "Skipping submodule updates for %s\n", r.Name())
 		} else {
 			for _, submodule := range r.submodules {
 				if err := dl.UpdateSubmodule(tmpdir, submodule); err != nil {
@@ -314,6 +332,53 @@ func (r *Repo) CheckExists() bool {
 	return util.NodeExist(r.Path())
 }
 
+func (r *Repo) VersionPolicy() string {
+	if r.verPolicy == "" {
+		return VERSION_POLICY_RC
+	}
+	return r.verPolicy
+}
+
+func (r *Repo) SetVersionPolicy(policy string) error {
+	switch policy {
+	case "", VERSION_POLICY_RC, VERSION_POLICY_STABLE, VERSION_POLICY_LATEST:
+		r.verPolicy = policy
+		return nil
+
+	default:
+		return util.FmtNewtError(
+			"Repo \"%s\" specifies invalid version_policy \"%s\"; "+
+				"must be one of: %s, %s, %s", r.Name(), policy,
+			VERSION_POLICY_STABLE, VERSION_POLICY_RC, VERSION_POLICY_LATEST)
+	}
+}
+
+// Applies the repo's version policy to the commit string that a version maps
+// to.  It returns the commit string that should be checked out instead; under
+// the "latest" policy, this is the commit hash of the main branch's tip.  The
+// repo must have been fetched.
+func (r *Repo) PolicyCommit(commit string) (string, error) {
+	switch r.VersionPolicy() {
+	case VERSION_POLICY_LATEST:
+		// Resolve the branch to the hash of its tip so that the repo is left
+		// in a known state.
+		return r.downloader.HashFor(r.Path(), r.downloader.MainBranch())
+
+	case VERSION_POLICY_STABLE:
+		return commit, nil
+
+	default:
+		// If the specified commit doesn't exist, try inserting "_rc#" into
+		// the string.  This is useful when a release candidate is being
+		// tested.  In this case, the "rc" tags exist, but the official
+		// release tag has not been created yet.
+		if _, err := r.downloader.CommitType(r.Path(), commit); err == nil {
+			return commit, nil
+		}
+		return r.downloader.LatestRc(r.Path(), commit)
+	}
+}
+
 func (r *Repo) updateRepo(commit string) error {
 	// Clone the repo if it doesn't exist.
 	if err := r.EnsureExists(); err != nil {
@@ -326,24 +391,18 @@ func (r *Repo) updateRepo(commit string) error {
 			"Error updating \"%s\": %s", r.Name(), err.Error())
 	}
 
-	// If the specified commit doesn't exist, try inserting "_rc#" into the
-	// string.  This is useful when a release candidate is being tested.  In
-	// this case, the "rc" tags exist, but the official release tag has not
-	// been created yet.
-	if _, err := r.downloader.CommitType(r.Path(), commit); err != nil {
-		newCommit, err := r.downloader.LatestRc(r.Path(), commit)
-		if err != nil {
-			return util.FmtNewtError(
-				"Error updating \"%s\": %s", r.Name(), err.Error())
-		}
+	newCommit, err := r.PolicyCommit(commit)
+	if err != nil {
+		return util.FmtNewtError(
+			"Error updating \"%s\": %s", r.Name(), err.Error())
+	}
 
-		if newCommit != commit {
-			util.StatusMessage(util.VERBOSITY_DEFAULT,
-				"in repo \"%s\": commit \"%s\" does not exist; "+
-					"using \"%s\" instead\n",
-				r.Name(), commit, newCommit)
-			commit = newCommit
-		}
+	if newCommit != commit {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"in repo \"%s\": using \"%s\" instead of \"%s\" "+
+				"(version_policy: %s)\n",
+			r.Name(), newCommit, commit, r.VersionPolicy())
+		commit = newCommit
 	}
 
 	if err := r.downloader.Checkout(r.Path(), commit); err != nil {
@@ -376,6 +435,11 @@ func (r *Repo) Upgrade(ver newtutil.RepoVersion) error {
 	return nil
 }
 
+// Lists the commits between two of the repo's commits; see Downloader.Log.
+func (r *Repo) Log(from string, to string) ([]downloader.LogCommit, error) {
+	return r.downloader.Log(r.Path(), from, to)
+}
+
 // Fetches all remotes and downloads an up to date copy of `repository.yml`
 // from master.  The repo object is then populated with the contents of the
 // downladed file.  If this repo has already had its descriptor updated, this
@@ -429,6 +493,15 @@ func (r *Repo) IsCheckedForUpgrade() bool {
 	return r.upgradeChecked
 }
 
+// Clones the repo if it doesn't exist and fetches all remotes.
+func (r *Repo) Prefetch() error {
+	if err := r.EnsureExists(); err != nil {
+		return err
+	}
+
+	return r.downloader.Fetch(r.Path())
+}
+
 func (r *Repo) EnsureExists() error {
 	// Clone the repo if it doesn't exist.
 	if !r.CheckExists() {
@@ -478,7 +551,7 @@ func (r *Repo) downloadRepositoryYml() error {
 // Downloads the repository description, i.e., `repository.yml`.
 func (r *Repo) DownloadDesc() error {
 	util.StatusMessage(util.VERBOSITY_VERBOSE, "Downloading "+
-		"repository description\n")
+		"repository description for %s\n", r.Name())
 
 	// Remember if the directory already exists.  If it doesn't, we'll create
 	// it.  If downloading fails, only remove the directory if we just created
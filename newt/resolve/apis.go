@@ -0,0 +1,104 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package resolve
+
+import (
+	"sort"
+)
+
+// A package that supplies or requires an API.
+type ApiEndpoint struct {
+	Rpkg *ResolvePackage
+
+	// The syscfg expression that enables the relationship; empty if
+	// unconditional.
+	Expr string
+}
+
+// Describes the packages that supply and require a single API.
+type ApiTopology struct {
+	Name string
+
+	// The package selected to supply the API; nil if the API is unsatisfied.
+	Provider *ResolvePackage
+
+	// All packages in the build that can supply the API.
+	Candidates []ApiEndpoint
+
+	// All packages in the build that require the API.
+	Consumers []ApiEndpoint
+}
+
+func sortApiEndpoints(eps []ApiEndpoint) {
+	sort.Slice(eps, func(i int, j int) bool {
+		return eps[i].Rpkg.Lpkg.FullName() < eps[j].Rpkg.Lpkg.FullName()
+	})
+}
+
+// ApiTopology describes every API that is supplied or required by a package
+// in the resolution.  The result is sorted by API name.
+func (res *Resolution) ApiTopology() []ApiTopology {
+	topoMap := map[string]*ApiTopology{}
+	get := func(name string) *ApiTopology {
+		topo := topoMap[name]
+		if topo == nil {
+			topo = &ApiTopology{
+				Name:     name,
+				Provider: res.ApiMap[name],
+			}
+			topoMap[name] = topo
+		}
+		return topo
+	}
+
+	for _, rpkg := range res.MasterSet.Rpkgs {
+		for name, es := range rpkg.Apis {
+			topo := get(name)
+			topo.Candidates = append(topo.Candidates, ApiEndpoint{
+				Rpkg: rpkg,
+				Expr: es.Disjunction().String(),
+			})
+		}
+
+		for name, reqApi := range rpkg.reqApiMap {
+			topo := get(name)
+			topo.Consumers = append(topo.Consumers, ApiEndpoint{
+				Rpkg: rpkg,
+				Expr: reqApi.exprs.Disjunction().String(),
+			})
+		}
+	}
+
+	names := make([]string, 0, len(topoMap))
+	for name, _ := range topoMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	topos := make([]ApiTopology, len(names))
+	for i, name := range names {
+		topo := topoMap[name]
+		sortApiEndpoints(topo.Candidates)
+		sortApiEndpoints(topo.Consumers)
+		topos[i] = *topo
+	}
+
+	return topos
+}
@@ -0,0 +1,252 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package resolve
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Maximum number of times the syscfg is recalculated before resolution is
+// considered to have failed.  Real configurations converge in a handful of
+// iterations.
+const maxCfgIterations = 100
+
+// Number of trailing iterations to report when resolution fails to converge
+// without revisiting an earlier state.
+const cfgIterationsReported = 8
+
+// A snapshot of the resolver's state during syscfg and dependency
+// resolution.
+type resolveState struct {
+	// Final value of each setting.
+	settings map[string]string
+
+	// Number of definitions and overrides of each setting.
+	points map[string]int
+
+	// Packages that define or override each setting.
+	sources map[string][]string
+
+	// Full names of the resolved packages.
+	pkgs map[string]struct{}
+
+	// Whether the snapshot was taken after dependencies were resolved
+	// (i.e., at the end of an iteration) rather than just after the syscfg
+	// was recalculated.
+	settled bool
+}
+
+// convergeTracker detects when the syscfg / dependency resolution loop fails
+// to converge.  Each iteration is deterministic given the resolver's state at
+// the end of the previous one, so revisiting such a state means the loop
+// would repeat forever.
+type convergeTracker struct {
+	states []resolveState
+	iters  int
+}
+
+func (r *Resolver) snapshot(settled bool) resolveState {
+	st := resolveState{
+		settings: make(map[string]string, len(r.cfg.Settings)),
+		points:   make(map[string]int, len(r.cfg.Settings)),
+		sources:  make(map[string][]string, len(r.cfg.Settings)),
+		pkgs:     make(map[string]struct{}, len(r.pkgMap)),
+		settled:  settled,
+	}
+
+	for name, entry := range r.cfg.Settings {
+		st.settings[name] = entry.Value
+		st.points[name] = len(entry.History)
+		for _, p := range entry.History {
+			if p.Source != nil {
+				st.sources[name] = append(st.sources[name],
+					p.Source.FullName())
+			}
+		}
+	}
+	for lpkg, _ := range r.pkgMap {
+		st.pkgs[lpkg.FullName()] = struct{}{}
+	}
+
+	return st
+}
+
+func (st *resolveState) equals(other *resolveState) bool {
+	if len(st.settings) != len(other.settings) ||
+		len(st.pkgs) != len(other.pkgs) {
+
+		return false
+	}
+
+	for name, val := range st.settings {
+		oval, ok := other.settings[name]
+		if !ok || oval != val || other.points[name] != st.points[name] {
+			return false
+		}
+	}
+
+	for name, _ := range st.pkgs {
+		if _, ok := other.pkgs[name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// note adds the resolver's state just after the syscfg was recalculated.
+// These intermediate states are only used for diagnostics.
+func (ct *convergeTracker) note(r *Resolver) {
+	ct.states = append(ct.states, r.snapshot(false))
+}
+
+// record adds the resolver's state at the end of an iteration.  It returns an
+// error describing the oscillation if the state was seen before, or if the
+// iteration cap has been reached.
+func (ct *convergeTracker) record(r *Resolver) error {
+	st := r.snapshot(true)
+	ct.iters++
+
+	for i := len(ct.states) - 1; i >= 0; i-- {
+		if ct.states[i].settled && st.equals(&ct.states[i]) {
+			cycle := append(append([]resolveState{}, ct.states[i:]...), st)
+			return r.oscillationError(cycle, fmt.Sprintf(
+				"the configuration repeats every %d iteration(s)",
+				ct.iters-ct.iterOf(i)))
+		}
+	}
+
+	ct.states = append(ct.states, st)
+
+	if ct.iters >= maxCfgIterations {
+		first := len(ct.states) - 2*cfgIterationsReported
+		if first < 0 {
+			first = 0
+		}
+		return r.oscillationError(ct.states[first:], fmt.Sprintf(
+			"no stable configuration after %d iterations", maxCfgIterations))
+	}
+
+	return nil
+}
+
+// iterOf returns the number of the iteration that produced the specified
+// state.
+func (ct *convergeTracker) iterOf(idx int) int {
+	n := 0
+	for i := 0; i <= idx; i++ {
+		if ct.states[i].settled {
+			n++
+		}
+	}
+
+	return n
+}
+
+// oscillationError builds an error listing the settings and packages that
+// change over the specified sequence of states.
+func (r *Resolver) oscillationError(states []resolveState,
+	summary string) error {
+
+	// Settings whose values differ between states.
+	names := map[string]struct{}{}
+	for _, st := range states {
+		for name, val := range st.settings {
+			for _, other := range states {
+				if oval, ok := other.settings[name]; !ok || oval != val {
+					names[name] = struct{}{}
+				}
+			}
+		}
+	}
+
+	// Packages that are absent from at least one state.
+	pkgNames := map[string]struct{}{}
+	for _, st := range states {
+		for name, _ := range st.pkgs {
+			for _, other := range states {
+				if _, ok := other.pkgs[name]; !ok {
+					pkgNames[name] = struct{}{}
+				}
+			}
+		}
+	}
+
+	lines := []string{
+		"syscfg resolution does not converge; " + summary,
+	}
+
+	if len(names) > 0 {
+		lines = append(lines, "    Changing settings:")
+		for _, name := range sortedKeys(names) {
+			vals := make([]string, len(states))
+			for i, st := range states {
+				if v, ok := st.settings[name]; ok {
+					vals[i] = fmt.Sprintf("\"%s\"", v)
+				} else {
+					vals[i] = "(undefined)"
+				}
+			}
+
+			line := fmt.Sprintf("        %s: %s", name,
+				strings.Join(vals, " -> "))
+
+			var srcs []string
+			for _, st := range states {
+				if len(st.sources[name]) > 0 {
+					srcs = st.sources[name]
+				}
+			}
+			if len(srcs) > 0 {
+				line += " (set by " + strings.Join(srcs, ", ") + ")"
+			}
+
+			lines = append(lines, line)
+		}
+	}
+
+	if len(pkgNames) > 0 {
+		lines = append(lines, "    Packages added and removed:")
+		for _, name := range sortedKeys(pkgNames) {
+			lines = append(lines, "        "+name)
+		}
+	}
+
+	lines = append(lines,
+		"    Check the conditional dependencies (pkg.deps.<expr>) and "+
+			"conditional overrides (syscfg.vals.<expr>) that involve "+
+			"these settings.")
+
+	return util.NewNewtError(strings.Join(lines, "\n"))
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k, _ := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
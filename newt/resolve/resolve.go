@@ -25,8 +25,6 @@ import (
 	"sort"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
-
 	"mynewt.apache.org/newt/newt/extcmd"
 	"mynewt.apache.org/newt/newt/flashmap"
 	"mynewt.apache.org/newt/newt/logcfg"
@@ -40,6 +38,8 @@ import (
 	"mynewt.apache.org/newt/util"
 )
 
+var log = util.SubsysLogger(util.LOG_SUBSYS_RESOLVER)
+
 // Represents a supplied API.
 type resolveApi struct {
 	// The package which supplies the API.
@@ -73,6 +73,9 @@ type Resolver struct {
 	preLinkCmdCfg    extcmd.ExtCmdCfg
 	postLinkCmdCfg   extcmd.ExtCmdCfg
 
+	// [original-package] => substitute package.
+	overrides map[*pkg.LocalPackage]*pkg.LocalPackage
+
 	// [api-name][api-supplier]
 	apiConflicts map[string]map[*ResolvePackage]struct{}
 
@@ -141,12 +144,17 @@ type Resolution struct {
 
 	LoaderSet *ResolveSet
 	AppSet    *ResolveSet
+
+	// Packages of each extra app, keyed by the app's name in
+	// `target.extra_apps`.
+	ExtraSets map[string]*ResolveSet
 }
 
 func newResolver(
 	seedPkgs []*pkg.LocalPackage,
 	injectedSettings *cfgv.Settings,
-	flashMap flashmap.FlashMap) *Resolver {
+	flashMap flashmap.FlashMap,
+	overrides map[*pkg.LocalPackage]*pkg.LocalPackage) *Resolver {
 
 	r := &Resolver{
 		apis:             map[string]resolveApi{},
@@ -154,6 +162,7 @@ func newResolver(
 		seedPkgs:         seedPkgs,
 		injectedSettings: injectedSettings,
 		flashMap:         flashMap,
+		overrides:        overrides,
 		cfg:              syscfg.NewCfg(),
 		apiConflicts:     map[string]map[*ResolvePackage]struct{}{},
 		parseWarnings:    map[*ResolvePackage][]string{},
@@ -222,6 +231,12 @@ func (r *Resolver) resolveDep(dep *pkg.Dependency,
 	}
 	lpkg := proj.ResolveDependency(dep).(*pkg.LocalPackage)
 
+	if sub := r.overrides[lpkg]; sub != nil {
+		log.Debugf("substituting package %s for %s; depender: %s",
+			sub.FullName(), lpkg.FullName(), depender)
+		lpkg = sub
+	}
+
 	return lpkg, nil
 }
 
@@ -643,8 +658,10 @@ func (rpkg *ResolvePackage) traceToSeed(
 		}
 		seen[cur] = struct{}{}
 
-		// A type greater than "library" is a seed package.
-		if cur.Lpkg.Type() > pkg.PACKAGE_TYPE_LIB {
+		// A type greater than "library" is a seed package.  Bundles are the
+		// exception; they only aggregate dependencies and settings.
+		if cur.Lpkg.Type() > pkg.PACKAGE_TYPE_LIB &&
+			cur.Lpkg.Type() != pkg.PACKAGE_TYPE_BUNDLE {
 			return true, nil
 		}
 
@@ -1031,12 +1048,15 @@ func (r *Resolver) resolveDepsAndCfg() error {
 		return err
 	}
 
+	var tracker convergeTracker
 	for {
 		cfgChanged, err := r.reloadCfg()
 		if err != nil {
 			return err
 		}
 		if cfgChanged {
+			tracker.note(r)
+
 			// A new supported feature was discovered.  It is impossible
 			// to determine what new dependency and API requirements are
 			// generated as a result.  All packages need to be
@@ -1053,6 +1073,10 @@ func (r *Resolver) resolveDepsAndCfg() error {
 		if !cfgChanged {
 			break
 		}
+
+		if err := tracker.record(r); err != nil {
+			return err
+		}
 	}
 
 	// Now that the final set of packages is known, determine which ones
@@ -1167,8 +1191,10 @@ func (r *Resolver) apiResolution() (
 func ResolveFull(
 	loaderSeeds []*pkg.LocalPackage,
 	appSeeds []*pkg.LocalPackage,
+	extraSeeds map[string][]*pkg.LocalPackage,
 	injectedSettings *cfgv.Settings,
-	flashMap flashmap.FlashMap) (*Resolution, error) {
+	flashMap flashmap.FlashMap,
+	overrides map[*pkg.LocalPackage]*pkg.LocalPackage) (*Resolution, error) {
 
 	// First, calculate syscfg and determine which package provides each
 	// required API.  Syscfg and APIs are project-wide; that is, they are
@@ -1177,7 +1203,10 @@ func ResolveFull(
 	// calculated here as a byproduct.
 
 	allSeeds := append(loaderSeeds, appSeeds...)
-	r := newResolver(allSeeds, injectedSettings, flashMap)
+	for _, name := range sortedExtraNames(extraSeeds) {
+		allSeeds = append(allSeeds, extraSeeds[name]...)
+	}
+	r := newResolver(allSeeds, injectedSettings, flashMap, overrides)
 
 	if err := r.resolveDepsAndCfg(); err != nil {
 		return nil, err
@@ -1236,7 +1265,7 @@ func ResolveFull(
 	// If there is no loader, then the set of all packages is just the app
 	// packages.  We already resolved the necessary dependency information when
 	// syscfg was calculated above.
-	if loaderSeeds == nil {
+	if loaderSeeds == nil && len(extraSeeds) == 0 {
 		res.AppSet.Rpkgs = r.rpkgSlice()
 		res.LoaderSet = nil
 		res.Cfg.DetectErrors(flashMap)
@@ -1244,11 +1273,12 @@ func ResolveFull(
 	}
 
 	// Otherwise, we need to resolve dependencies separately for:
-	// 1. The set of loader packages, and
-	// 2. The set of app packages.
+	// 1. The set of loader packages (if any),
+	// 2. The set of app packages, and
+	// 3. The set of packages for each extra app (if any).
 	//
 	// These need to be resolved separately so that it is possible later to
-	// determine which packages need to be shared between loader and app.
+	// determine which packages need to be shared between the images.
 
 	// It is OK if the app requires an API that is supplied by the loader.
 	// Ensure each set of packages has access to the API-providers.
@@ -1257,42 +1287,92 @@ func ResolveFull(
 		appSeeds = append(appSeeds, rpkg.Lpkg)
 	}
 
-	// Resolve loader dependencies.
-	r = newResolver(loaderSeeds, injectedSettings, flashMap)
-	r.cfg = res.Cfg
-
 	var err error
 
-	res.LoaderSet.Rpkgs, err = r.resolveDeps()
+	if loaderSeeds != nil {
+		// Resolve loader dependencies.
+		res.LoaderSet.Rpkgs, err = res.resolveSubset(loaderSeeds,
+			injectedSettings, flashMap, overrides)
+		if err != nil {
+			return nil, err
+		}
+
+		// The app automtically gets all the packages from the loader except
+		// for the loader-app-package.
+		for _, rpkg := range res.LoaderSet.Rpkgs {
+			if rpkg.Lpkg.Type() != pkg.PACKAGE_TYPE_APP {
+				appSeeds = append(appSeeds, rpkg.Lpkg)
+			}
+		}
+	} else {
+		res.LoaderSet = nil
+	}
+
+	// Resolve app dependencies.
+	res.AppSet.Rpkgs, err = res.resolveSubset(appSeeds,
+		injectedSettings, flashMap, overrides)
 	if err != nil {
 		return nil, err
 	}
-	if err := res.LoaderSet.useMasterPkgs(); err != nil {
-		return nil, err
+
+	// Resolve the dependencies of each extra app.  Extra apps share the
+	// target's syscfg, but each one only gets the packages it depends on.
+	if len(extraSeeds) > 0 {
+		res.ExtraSets = make(map[string]*ResolveSet, len(extraSeeds))
 	}
+	for _, name := range sortedExtraNames(extraSeeds) {
+		seeds := extraSeeds[name]
+		for _, rpkg := range res.ApiMap {
+			seeds = append(seeds, rpkg.Lpkg)
+		}
 
-	// Resolve app dependencies.  The app automtically gets all the packages
-	// from the loader except for the loader-app-package.
-	for _, rpkg := range res.LoaderSet.Rpkgs {
-		if rpkg.Lpkg.Type() != pkg.PACKAGE_TYPE_APP {
-			appSeeds = append(appSeeds, rpkg.Lpkg)
+		rs := &ResolveSet{Res: res}
+		rs.Rpkgs, err = res.resolveSubset(seeds,
+			injectedSettings, flashMap, overrides)
+		if err != nil {
+			return nil, err
 		}
+
+		res.ExtraSets[name] = rs
 	}
 
-	r = newResolver(appSeeds, injectedSettings, flashMap)
+	res.Cfg.DetectErrors(flashMap)
+
+	return res, nil
+}
+
+// resolveSubset resolves the dependencies of a subset of the target's
+// packages using the already-calculated syscfg.  The returned packages are
+// the master set's copies.
+func (res *Resolution) resolveSubset(seeds []*pkg.LocalPackage,
+	injectedSettings *cfgv.Settings, flashMap flashmap.FlashMap,
+	overrides map[*pkg.LocalPackage]*pkg.LocalPackage) (
+	[]*ResolvePackage, error) {
+
+	r := newResolver(seeds, injectedSettings, flashMap, overrides)
 	r.cfg = res.Cfg
 
-	res.AppSet.Rpkgs, err = r.resolveDeps()
+	rpkgs, err := r.resolveDeps()
 	if err != nil {
 		return nil, err
 	}
-	if err := res.AppSet.useMasterPkgs(); err != nil {
+
+	rs := &ResolveSet{Res: res, Rpkgs: rpkgs}
+	if err := rs.useMasterPkgs(); err != nil {
 		return nil, err
 	}
 
-	res.Cfg.DetectErrors(flashMap)
+	return rs.Rpkgs, nil
+}
 
-	return res, nil
+func sortedExtraNames(extraSeeds map[string][]*pkg.LocalPackage) []string {
+	names := make([]string, 0, len(extraSeeds))
+	for name, _ := range extraSeeds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
 }
 
 func (res *Resolution) ErrorText() string {
@@ -1362,6 +1442,47 @@ func (res *Resolution) CfgExperimentalWarning() []string {
 	return res.Cfg.ExperimentalWarning()
 }
 
+// PkgDeprecatedWarning returns one warning per deprecated package in the
+// resolution (`pkg.deprecated: 1`), sorted.  Each warning names the packages
+// that depend on the deprecated package and its replacement, if the package
+// specifies one (`pkg.replacement`).
+func (res *Resolution) PkgDeprecatedWarning() []string {
+	lines := []string{}
+
+	for lpkg, rpkg := range res.LpkgRpkgMap {
+		deprecated, err := lpkg.PkgY.GetValBool("pkg.deprecated", nil)
+		if err != nil {
+			log.Errorf("Internal error; Could not read package %s yml file",
+				lpkg.Name())
+		}
+		if !deprecated {
+			continue
+		}
+
+		line := fmt.Sprintf("Use of deprecated package %s", lpkg.FullName())
+
+		var dependers []string
+		for depender, _ := range rpkg.revDeps {
+			dependers = append(dependers, depender.Lpkg.FullName())
+		}
+		if len(dependers) > 0 {
+			sort.Strings(dependers)
+			line += " (required by " + strings.Join(dependers, ", ") + ")"
+		}
+
+		replacement, err := lpkg.PkgY.GetValString("pkg.replacement", nil)
+		util.OneTimeWarningError(err)
+		if replacement != "" {
+			line += "; use " + replacement + " instead"
+		}
+
+		lines = append(lines, line)
+	}
+
+	sort.Strings(lines)
+	return lines
+}
+
 func (res *Resolution) PkgExperimentalWarning() []string {
 	lines := []string{}
 
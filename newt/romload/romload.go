@@ -0,0 +1,93 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package romload programs devices through the serial bootloaders built into
+// their ROM.  This allows a blank chip to be loaded without a debug probe.  A
+// BSP selects its chip's ROM bootloader with the `bsp.rom_loader` setting.
+package romload
+
+import (
+	"sort"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Describes a single load operation.
+type Params struct {
+	// Binary file to write.
+	File string
+
+	// Absolute flash address to write the file to.
+	Addr int
+
+	// Size of the flash area being written.
+	AreaSize int
+
+	// Whether the whole chip should be erased before writing.  Set when
+	// loading the bootloader, i.e., when programming a blank chip.
+	EraseAll bool
+
+	// Size of a flash page, for loaders that erase individual pages
+	// (`bsp.rom_loader_page_size`).
+	PageSize int
+
+	// Serial port and baud rate of the device.
+	Port string
+	Baud int
+
+	// Extra arguments for the external loader tool (`bsp.rom_loader_args`).
+	Args []string
+}
+
+type loadFn func(p Params) error
+
+var loaders = map[string]loadFn{
+	"stm32_uart": loadStm32Uart,
+	"nrfutil":    loadNrfutil,
+	"blhost":     loadBlhost,
+}
+
+// Names returns the sorted names of the supported ROM bootloaders.
+func Names() []string {
+	names := make([]string, 0, len(loaders))
+	for name, _ := range loaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Load writes a file to a device using the named ROM bootloader.
+func Load(loader string, p Params) error {
+	fn := loaders[loader]
+	if fn == nil {
+		return util.FmtNewtError(
+			"unsupported ROM bootloader \"%s\"; must be one of %v",
+			loader, Names())
+	}
+
+	if p.Port == "" {
+		return util.FmtNewtError(
+			"loading through the %s ROM bootloader requires a serial port",
+			loader)
+	}
+
+	return fn(p)
+}
@@ -0,0 +1,411 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package romload
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// STM32 system memory bootloader (USART) protocol; see ST application note
+// AN3155.
+const (
+	STM32_SYNC = 0x7f
+	STM32_ACK  = 0x79
+	STM32_NACK = 0x1f
+
+	STM32_CMD_GET       = 0x00
+	STM32_CMD_WRITE_MEM = 0x31
+	STM32_CMD_ERASE     = 0x43
+	STM32_CMD_EXT_ERASE = 0x44
+)
+
+// Maximum payload of a single Write Memory command.
+const stm32WriteChunkSz = 256
+
+// Address of the start of the STM32 main flash memory.  Page numbers are
+// relative to this address.
+const STM32_FLASH_BASE = 0x08000000
+
+// Maximum number of pages erased by a single erase command.
+const stm32ErasePagesPerCmd = 64
+
+// A mass erase can take tens of seconds on large parts.
+const stm32EraseTimeout = 60 * time.Second
+const stm32AckTimeout = 2 * time.Second
+
+type stm32Conn struct {
+	f *os.File
+}
+
+// Configures a serial port for the STM32 bootloader: 8 data bits, even
+// parity, one stop bit, raw mode.  Reads time out after 100ms so that the
+// caller can implement its own deadlines.
+func sttyConfigure(port string, baud int) error {
+	flag := "-F"
+	if runtime.GOOS == "darwin" {
+		flag = "-f"
+	}
+
+	cmd := []string{"stty", flag, port, strconv.Itoa(baud),
+		"cs8", "parenb", "-parodd", "-cstopb", "raw", "-echo",
+		"-crtscts", "min", "0", "time", "1"}
+	if _, err := util.ShellCommand(cmd, nil); err != nil {
+		return util.FmtNewtError("failed to configure serial port %s: %s",
+			port, err.Error())
+	}
+
+	return nil
+}
+
+func openStm32(port string, baud int) (*stm32Conn, error) {
+	if err := sttyConfigure(port, baud); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(port, os.O_RDWR, 0)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	return &stm32Conn{f: f}, nil
+}
+
+func (c *stm32Conn) Close() {
+	c.f.Close()
+}
+
+func (c *stm32Conn) write(b []byte) error {
+	if _, err := c.f.Write(b); err != nil {
+		return util.ChildNewtError(err)
+	}
+	return nil
+}
+
+// Reads a single byte, giving up after the specified timeout.
+func (c *stm32Conn) readByte(timeout time.Duration) (byte, error) {
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 1)
+
+	for {
+		n, _ := c.f.Read(buf)
+		if n == 1 {
+			return buf[0], nil
+		}
+
+		if time.Now().After(deadline) {
+			return 0, util.FmtNewtError(
+				"timed out waiting for STM32 bootloader response")
+		}
+	}
+}
+
+func (c *stm32Conn) waitAck(timeout time.Duration, what string) error {
+	b, err := c.readByte(timeout)
+	if err != nil {
+		return util.FmtNewtError("%s: %s", what, err.Error())
+	}
+
+	switch b {
+	case STM32_ACK:
+		return nil
+	case STM32_NACK:
+		return util.FmtNewtError("%s: STM32 bootloader replied NACK", what)
+	default:
+		return util.FmtNewtError(
+			"%s: unexpected STM32 bootloader response 0x%02x", what, b)
+	}
+}
+
+func xorSum(b []byte) byte {
+	var sum byte
+	for _, v := range b {
+		sum ^= v
+	}
+	return sum
+}
+
+func (c *stm32Conn) sendCmd(cmd byte) error {
+	if err := c.write([]byte{cmd, ^cmd}); err != nil {
+		return err
+	}
+
+	return c.waitAck(stm32AckTimeout,
+		"STM32 bootloader command 0x"+strconv.FormatInt(int64(cmd), 16))
+}
+
+// Establishes communication with the bootloader, which detects the baud rate
+// from the sync byte.  A NACK means the bootloader was already synchronized by
+// an earlier session.
+func (c *stm32Conn) sync() error {
+	if err := c.write([]byte{STM32_SYNC}); err != nil {
+		return err
+	}
+
+	b, err := c.readByte(stm32AckTimeout)
+	if err != nil {
+		return util.FmtNewtError(
+			"no response from the STM32 bootloader; make sure the device " +
+				"was reset into its system bootloader (BOOT0 high)")
+	}
+	if b != STM32_ACK && b != STM32_NACK {
+		return util.FmtNewtError(
+			"unexpected STM32 bootloader sync response 0x%02x", b)
+	}
+
+	return nil
+}
+
+// Retrieves the list of commands the bootloader supports.
+func (c *stm32Conn) getCmds() (map[byte]bool, error) {
+	if err := c.sendCmd(STM32_CMD_GET); err != nil {
+		return nil, err
+	}
+
+	n, err := c.readByte(stm32AckTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// The count excludes itself and includes the version byte.
+	cmds := map[byte]bool{}
+	for i := 0; i < int(n)+1; i++ {
+		b, err := c.readByte(stm32AckTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			log.Debugf("STM32 bootloader version 0x%02x", b)
+		} else {
+			cmds[b] = true
+		}
+	}
+
+	if err := c.waitAck(stm32AckTimeout, "STM32 bootloader get"); err != nil {
+		return nil, err
+	}
+
+	return cmds, nil
+}
+
+// Erases the specified flash pages, using the extended erase command if the
+// bootloader supports it.
+func (c *stm32Conn) erasePages(cmds map[byte]bool, pages []int) error {
+	for len(pages) > 0 {
+		n := len(pages)
+		if n > stm32ErasePagesPerCmd {
+			n = stm32ErasePagesPerCmd
+		}
+
+		var cmd byte
+		var payload []byte
+
+		if cmds[STM32_CMD_EXT_ERASE] {
+			cmd = STM32_CMD_EXT_ERASE
+			payload = []byte{byte((n - 1) >> 8), byte(n - 1)}
+			for _, page := range pages[:n] {
+				payload = append(payload, byte(page>>8), byte(page))
+			}
+		} else if cmds[STM32_CMD_ERASE] {
+			cmd = STM32_CMD_ERASE
+			payload = []byte{byte(n - 1)}
+			for _, page := range pages[:n] {
+				if page > 0xff {
+					return util.FmtNewtError(
+						"STM32 bootloader cannot erase page %d; its erase "+
+							"command only supports pages 0-255", page)
+				}
+				payload = append(payload, byte(page))
+			}
+		} else {
+			return util.FmtNewtError(
+				"STM32 bootloader does not support erase")
+		}
+		payload = append(payload, xorSum(payload))
+
+		if err := c.sendCmd(cmd); err != nil {
+			return err
+		}
+		if err := c.write(payload); err != nil {
+			return err
+		}
+		if err := c.waitAck(stm32EraseTimeout,
+			"STM32 page erase"); err != nil {
+
+			return err
+		}
+
+		pages = pages[n:]
+	}
+
+	return nil
+}
+
+// Calculates the numbers of the flash pages that the specified address range
+// covers.  The address may be absolute or relative to the start of flash.
+func stm32Pages(addr int, size int, pageSize int) []int {
+	if addr >= STM32_FLASH_BASE {
+		addr -= STM32_FLASH_BASE
+	}
+
+	var pages []int
+	for page := addr / pageSize; page*pageSize < addr+size; page++ {
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+// Erases the whole flash, using the extended erase command if the bootloader
+// supports it.
+func (c *stm32Conn) massErase(cmds map[byte]bool) error {
+	var cmd byte
+	var payload []byte
+
+	if cmds[STM32_CMD_EXT_ERASE] {
+		cmd = STM32_CMD_EXT_ERASE
+		payload = []byte{0xff, 0xff, 0x00}
+	} else if cmds[STM32_CMD_ERASE] {
+		cmd = STM32_CMD_ERASE
+		payload = []byte{0xff, 0x00}
+	} else {
+		return util.FmtNewtError("STM32 bootloader does not support erase")
+	}
+
+	if err := c.sendCmd(cmd); err != nil {
+		return err
+	}
+	if err := c.write(payload); err != nil {
+		return err
+	}
+
+	return c.waitAck(stm32EraseTimeout, "STM32 mass erase")
+}
+
+func (c *stm32Conn) writeMem(addr int, data []byte) error {
+	if err := c.sendCmd(STM32_CMD_WRITE_MEM); err != nil {
+		return err
+	}
+
+	a := []byte{
+		byte(addr >> 24), byte(addr >> 16), byte(addr >> 8), byte(addr),
+	}
+	if err := c.write(append(a, xorSum(a))); err != nil {
+		return err
+	}
+	if err := c.waitAck(stm32AckTimeout, "STM32 write address"); err != nil {
+		return err
+	}
+
+	buf := []byte{byte(len(data) - 1)}
+	buf = append(buf, data...)
+	buf = append(buf, xorSum(buf))
+	if err := c.write(buf); err != nil {
+		return err
+	}
+
+	return c.waitAck(stm32AckTimeout, "STM32 write data")
+}
+
+// Loads a file through the STM32 system memory bootloader over a UART.  The
+// device must already be running its bootloader.  The whole flash is erased
+// when loading the bootloader; otherwise, only the pages that the file covers
+// are erased.
+func loadStm32Uart(p Params) error {
+	if runtime.GOOS == "windows" {
+		return util.FmtNewtError(
+			"the stm32_uart ROM loader is not supported on Windows")
+	}
+
+	if !p.EraseAll && p.PageSize <= 0 {
+		return util.FmtNewtError(
+			"the stm32_uart ROM loader requires the BSP to specify its " +
+				"flash page size (bsp.rom_loader_page_size)")
+	}
+
+	data, err := ioutil.ReadFile(p.File)
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+	if len(data) == 0 {
+		return util.FmtNewtError("%s is empty", p.File)
+	}
+	if p.AreaSize > 0 && len(data) > p.AreaSize {
+		return util.FmtNewtError(
+			"%s (%d bytes) does not fit in the flash area (%d bytes)",
+			p.File, len(data), p.AreaSize)
+	}
+
+	// The bootloader writes whole words.
+	for len(data)%4 != 0 {
+		data = append(data, 0xff)
+	}
+
+	c, err := openStm32(p.Port, p.Baud)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.sync(); err != nil {
+		return err
+	}
+
+	cmds, err := c.getCmds()
+	if err != nil {
+		return err
+	}
+
+	if p.EraseAll {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "Erasing flash\n")
+		if err := c.massErase(cmds); err != nil {
+			return err
+		}
+	} else {
+		pages := stm32Pages(p.Addr, len(data), p.PageSize)
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Erasing flash pages %d-%d\n", pages[0], pages[len(pages)-1])
+		if err := c.erasePages(cmds, pages); err != nil {
+			return err
+		}
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Writing %d bytes at 0x%08x\n", len(data), p.Addr)
+	for off := 0; off < len(data); off += stm32WriteChunkSz {
+		end := off + stm32WriteChunkSz
+		if end > len(data) {
+			end = len(data)
+		}
+
+		if err := c.writeMem(p.Addr+off, data[off:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
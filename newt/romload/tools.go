@@ -0,0 +1,105 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package romload
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Default `nrfutil pkg generate` arguments; replaced by bsp.rom_loader_args.
+var nrfutilDfltPkgArgs = []string{"--hw-version", "52", "--sd-req", "0x00"}
+
+func runTool(cmd []string) error {
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Load command: %s\n",
+		strings.Join(cmd, " "))
+
+	return util.ShellCommandStreamOutput(cmd, nil, true,
+		!util.HideLoadCmdOutput)
+}
+
+// Loads a file through the Nordic serial DFU bootloader.  nrfutil packages
+// the file as an application update and sends it to the device.  The DFU
+// bootloader writes an application to its own application start address; it
+// cannot place the file at an arbitrary address or replace the bootloader.
+func loadNrfutil(p Params) error {
+	if p.EraseAll {
+		return util.FmtNewtError(
+			"the nrfutil DFU bootloader cannot load a boot loader")
+	}
+
+	util.OneTimeWarning("the nrfutil DFU bootloader writes %s to its "+
+		"application start address, not to the flash area at 0x%x; make "+
+		"sure they match", p.File, p.Addr)
+
+	dir, err := ioutil.TempDir("", "newt-nrfutil")
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pkgPath := dir + "/dfu.zip"
+
+	args := p.Args
+	if len(args) == 0 {
+		args = nrfutilDfltPkgArgs
+	}
+
+	cmd := []string{"nrfutil", "pkg", "generate"}
+	cmd = append(cmd, args...)
+	cmd = append(cmd, "--application", p.File, pkgPath)
+	if err := runTool(cmd); err != nil {
+		return err
+	}
+
+	return runTool([]string{
+		"nrfutil", "dfu", "serial",
+		"-pkg", pkgPath,
+		"-p", p.Port,
+		"-b", strconv.Itoa(p.Baud),
+	})
+}
+
+// Loads a file through the NXP ROM bootloader with blhost.  The flash area is
+// erased before it is written.
+func loadBlhost(p Params) error {
+	base := []string{"blhost", "-p", fmt.Sprintf("%s,%d", p.Port, p.Baud)}
+	base = append(base, p.Args...)
+
+	var cmd []string
+	if p.EraseAll {
+		cmd = append(base, "--", "flash-erase-all")
+	} else {
+		cmd = append(base, "--", "flash-erase-region",
+			fmt.Sprintf("0x%x", p.Addr), strconv.Itoa(p.AreaSize))
+	}
+	if err := runTool(cmd); err != nil {
+		return err
+	}
+
+	cmd = append(base, "--", "write-memory", fmt.Sprintf("0x%x", p.Addr),
+		p.File)
+	return runTool(cmd)
+}
@@ -0,0 +1,292 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package selftest checks newt against checked-in fixtures.  The resolver
+// test resolves every target in a set of miniature projects and compares the
+// results against golden files, so that the resolver can be refactored
+// safely.
+package selftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/builder"
+	"mynewt.apache.org/newt/newt/dump"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/newt/target"
+	"mynewt.apache.org/newt/util"
+)
+
+// Directory, within a fixture project, that holds the golden files.  Each
+// target has a golden file named after the target, e.g.,
+// `golden/blinky.json` for `targets/blinky`.
+const GOLDEN_DIR = "golden"
+
+// Placeholder for the fixture's path in golden files, so that they do not
+// depend on where the fixture is checked out.
+const FIXTURE_PATH_PLACEHOLDER = "<fixture>"
+
+// Maximum number of differing lines reported for a failed comparison.
+const maxDiffLines = 40
+
+type ResolveStatus int
+
+const (
+	RESOLVE_STATUS_PASS ResolveStatus = iota
+	RESOLVE_STATUS_FAIL
+	RESOLVE_STATUS_MISSING
+	RESOLVE_STATUS_UPDATED
+)
+
+var resolveStatusNames = map[ResolveStatus]string{
+	RESOLVE_STATUS_PASS:    "PASS",
+	RESOLVE_STATUS_FAIL:    "FAIL",
+	RESOLVE_STATUS_MISSING: "MISSING",
+	RESOLVE_STATUS_UPDATED: "UPDATED",
+}
+
+func (s ResolveStatus) String() string {
+	return resolveStatusNames[s]
+}
+
+// The outcome of comparing one target's resolution against its golden file.
+type ResolveResult struct {
+	Fixture string
+	Target  string
+	Status  ResolveStatus
+
+	// Describes the differences for a failed comparison.
+	Diff string
+}
+
+// Written to the golden file of a target that fails to resolve, so that
+// fixtures can also capture expected resolution errors.
+type resolveError struct {
+	Error string `json:"error"`
+}
+
+// FindFixtures returns the fixture projects in a directory.  If the directory
+// is itself a project, it is the only fixture; otherwise, each subdirectory
+// that contains a project is a fixture.
+func FindFixtures(dir string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+	dir = filepath.ToSlash(dir)
+
+	if util.NodeExist(dir + "/" + project.PROJECT_FILE_NAME) {
+		return []string{dir}, nil
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	var fixtures []string
+	for _, info := range infos {
+		path := dir + "/" + info.Name()
+		if info.IsDir() &&
+			util.NodeExist(path+"/"+project.PROJECT_FILE_NAME) {
+
+			fixtures = append(fixtures, path)
+		}
+	}
+
+	if len(fixtures) == 0 {
+		return nil, util.FmtNewtError(
+			"no fixture projects in %s; a fixture is a directory containing "+
+				"a %s file", dir, project.PROJECT_FILE_NAME)
+	}
+
+	return fixtures, nil
+}
+
+// Produces the text that is compared against a target's golden file: the
+// target's `newt target dump` report, or the resolution error.
+func resolveTargetText(t *target.Target, fixtureDir string) (string, error) {
+	var text string
+
+	b, err := builder.NewTargetBuilder(t)
+	if err == nil {
+		var rpt dump.Report
+		rpt, err = dump.NewReport(b)
+		if err == nil {
+			text, err = rpt.JSON()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err != nil {
+		js, jerr := json.MarshalIndent(resolveError{err.Error()}, "", "    ")
+		if jerr != nil {
+			return "", util.ChildNewtError(jerr)
+		}
+		text = string(js) + "\n"
+	}
+
+	return strings.Replace(text, fixtureDir, FIXTURE_PATH_PLACEHOLDER, -1),
+		nil
+}
+
+// Describes how two texts differ.  The common leading and trailing lines are
+// skipped and the remaining lines of each text are listed.
+func diffText(expected string, actual string) string {
+	exp := strings.Split(expected, "\n")
+	act := strings.Split(actual, "\n")
+
+	start := 0
+	for start < len(exp) && start < len(act) && exp[start] == act[start] {
+		start++
+	}
+
+	endExp := len(exp)
+	endAct := len(act)
+	for endExp > start && endAct > start && exp[endExp-1] == act[endAct-1] {
+		endExp--
+		endAct--
+	}
+
+	lines := []string{fmt.Sprintf("@@ line %d @@", start+1)}
+	add := func(prefix string, src []string) {
+		for _, line := range src {
+			if len(lines) > maxDiffLines {
+				lines = append(lines, "...")
+				return
+			}
+			lines = append(lines, prefix+line)
+		}
+	}
+	add("-", exp[start:endExp])
+	add("+", act[start:endAct])
+
+	return strings.Join(lines, "\n")
+}
+
+func fixtureTargets() []*target.Target {
+	lr := project.GetProject().LocalRepo()
+
+	var targets []*target.Target
+	for _, t := range target.GetTargets() {
+		if t.Package().Repo() == lr {
+			targets = append(targets, t)
+		}
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].FullName() < targets[j].FullName()
+	})
+
+	return targets
+}
+
+func resolveFixture(dir string, update bool) ([]ResolveResult, error) {
+	if err := os.Chdir(dir); err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	target.ResetTargets()
+	project.ResetProject()
+
+	if _, err := project.TryGetProject(); err != nil {
+		return nil, util.PreNewtError(err, "failed to load fixture %s", dir)
+	}
+
+	var results []ResolveResult
+	for _, t := range fixtureTargets() {
+		res := ResolveResult{
+			Fixture: filepath.Base(dir),
+			Target:  t.ShortName(),
+		}
+
+		actual, err := resolveTargetText(t, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		goldenPath := dir + "/" + GOLDEN_DIR + "/" + t.ShortName() + ".json"
+		expected, err := ioutil.ReadFile(goldenPath)
+		switch {
+		case update:
+			if err := os.MkdirAll(filepath.Dir(goldenPath), 0755); err != nil {
+				return nil, util.ChildNewtError(err)
+			}
+			err := ioutil.WriteFile(goldenPath, []byte(actual), 0644)
+			if err != nil {
+				return nil, util.ChildNewtError(err)
+			}
+			res.Status = RESOLVE_STATUS_UPDATED
+
+		case err != nil:
+			res.Status = RESOLVE_STATUS_MISSING
+
+		case string(expected) == actual:
+			res.Status = RESOLVE_STATUS_PASS
+
+		default:
+			res.Status = RESOLVE_STATUS_FAIL
+			res.Diff = diffText(string(expected), actual)
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// Resolve resolves every target in each fixture project under `dir` and
+// compares the results against the fixtures' golden files.  If `update` is
+// true, the golden files are rewritten instead.  The current project is
+// unloaded; the caller must reload it if it needs it afterwards.
+func Resolve(dir string, update bool) ([]ResolveResult, error) {
+	fixtures, err := FindFixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+	defer func() {
+		os.Chdir(wd)
+		target.ResetTargets()
+		project.ResetProject()
+	}()
+
+	var results []ResolveResult
+	for _, f := range fixtures {
+		res, err := resolveFixture(f, update)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res...)
+	}
+
+	return results, nil
+}
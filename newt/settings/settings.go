@@ -59,6 +59,7 @@ func processNewtrc(yc ycfg.YCfg) {
 
 	util.SkipNewtCompat, _ = yc.GetValBoolDflt("skip_newt_compat", nil, false)
 	util.SkipSyscfgRepoHash, _ = yc.GetValBoolDflt("skip_syscfg_repo_hash", nil, false)
+	util.SyscfgProvenance, _ = yc.GetValBoolDflt("syscfg_provenance", nil, false)
 	util.HideLoadCmdOutput, _ = yc.GetValBoolDflt("hide_load_output", nil, false)
 }
 
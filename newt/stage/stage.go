@@ -27,9 +27,6 @@ package stage
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
@@ -135,7 +132,9 @@ func SortStageFuncs(sfs []StageFunc, entryType string) {
 		log.Warnf("Warning: Identical %s entries detected: %s",
 			entryType, a.Name)
 
-		return true
+		// 3: Sort by package name so that the result does not depend on the
+		// order in which the entries were read.
+		return a.Pkg.FullName() < b.Pkg.FullName()
 	})
 }
 
@@ -241,25 +240,5 @@ func WriteArr(sortedFuncs []StageFunc, w io.Writer) {
 // EnsureWritten writes the specified file if its contents differ from those of
 // the supplied byte slice.
 func EnsureWritten(path string, contents []byte) error {
-	unchanged, err := util.FileContains(contents, path)
-	if err != nil {
-		return err
-	}
-
-	if unchanged {
-		log.Debugf("file unchanged; not writing src file (%s).", path)
-		return nil
-	}
-
-	log.Debugf("file changed; writing src file (%s).", path)
-
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return util.NewNewtError(err.Error())
-	}
-
-	if err := ioutil.WriteFile(path, contents, 0644); err != nil {
-		return util.NewNewtError(err.Error())
-	}
-
-	return nil
+	return util.WriteGeneratedFile(path, contents)
 }
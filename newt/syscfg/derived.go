@@ -0,0 +1,358 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package syscfg
+
+// Derived settings are settings whose values newt computes from other
+// settings on behalf of a package.  They let a BSP or MCU package publish
+// facts about the hardware (e.g., MCU family flags or the total RAM size)
+// without every BSP copying the same definitions.  A package declares them in
+// the `syscfg.derived` section of its syscfg.yml:
+//
+//     syscfg.derived:
+//         # Constant.
+//         MCU_FAMILY_STM32F4: 1
+//
+//         # Boolean expression; the value is 1 or 0.
+//         MCU_HAS_FPU:
+//             expr: 'MCU_CORTEX_M4 || MCU_CORTEX_M7'
+//
+//         # Go-side evaluator applied to settings or integer literals.
+//         MCU_RAM_SIZE:
+//             description: 'Total RAM, in bytes.'
+//             evaluator: sum
+//             args: [MCU_SRAM1_SIZE, MCU_SRAM2_SIZE]
+//
+// Derived settings are constants: other packages may not override them.
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+
+	"mynewt.apache.org/newt/newt/cfgv"
+	"mynewt.apache.org/newt/newt/parse"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/util"
+)
+
+// A DerivedEvaluator computes the value of a derived setting from its
+// arguments and the current setting values.
+type DerivedEvaluator func(args []string, settings *cfgv.Settings) (
+	string, error)
+
+var derivedEvaluators = map[string]DerivedEvaluator{}
+
+// RegisterDerivedEvaluator makes an evaluator available to the
+// `syscfg.derived` sections of all packages under the specified name.
+func RegisterDerivedEvaluator(name string, ev DerivedEvaluator) {
+	derivedEvaluators[name] = ev
+}
+
+func init() {
+	RegisterDerivedEvaluator("expr", evalDerivedExpr)
+	RegisterDerivedEvaluator("sum", derivedIntFold(
+		func(acc int, v int) int { return acc + v }))
+	RegisterDerivedEvaluator("min", derivedIntFold(
+		func(acc int, v int) int {
+			if v < acc {
+				return v
+			}
+			return acc
+		}))
+	RegisterDerivedEvaluator("max", derivedIntFold(
+		func(acc int, v int) int {
+			if v > acc {
+				return v
+			}
+			return acc
+		}))
+}
+
+// Evaluates a single boolean expression; the result is "1" or "0".
+func evalDerivedExpr(args []string, settings *cfgv.Settings) (string, error) {
+	if len(args) != 1 {
+		return "", util.FmtNewtError(
+			"evaluator \"expr\" requires exactly one argument; have %d",
+			len(args))
+	}
+
+	val, err := parse.ParseAndEval(args[0], settings)
+	if err != nil {
+		return "", err
+	}
+
+	if val {
+		return "1", nil
+	}
+	return "0", nil
+}
+
+// Returns an evaluator that folds its integer arguments with the specified
+// function.  Each argument is either the name of a setting or an integer.
+func derivedIntFold(fn func(acc int, v int) int) DerivedEvaluator {
+	return func(args []string, settings *cfgv.Settings) (string, error) {
+		if len(args) == 0 {
+			return "", util.FmtNewtError("no arguments")
+		}
+
+		var acc int
+		for i, arg := range args {
+			s := arg
+			if settings.Exists(arg) {
+				s = settings.Get(arg)
+			}
+
+			v, err := util.AtoiNoOct(s)
+			if err != nil {
+				return "", util.FmtNewtError(
+					"argument \"%s\" is not an integer or integer setting",
+					arg)
+			}
+
+			if i == 0 {
+				acc = v
+			} else {
+				acc = fn(acc, v)
+			}
+		}
+
+		return strconv.Itoa(acc), nil
+	}
+}
+
+// A single derived setting declared by a package.
+type derivedRule struct {
+	name        string
+	lpkg        *pkg.LocalPackage
+	description string
+
+	// Set for constants.
+	value string
+
+	// Set for computed settings.
+	evaluator string
+	args      []string
+}
+
+func (rule *derivedRule) eval(settings *cfgv.Settings) (string, error) {
+	if rule.evaluator == "" {
+		return rule.value, nil
+	}
+
+	ev := derivedEvaluators[rule.evaluator]
+	if ev == nil {
+		return "", util.FmtNewtError("unknown evaluator \"%s\"",
+			rule.evaluator)
+	}
+
+	return ev(rule.args, settings)
+}
+
+func readDerivedRule(name string, lpkg *pkg.LocalPackage,
+	v interface{}) (derivedRule, error) {
+
+	rule := derivedRule{
+		name: name,
+		lpkg: lpkg,
+	}
+
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		rule.value = stringValue(v)
+		return rule, nil
+	}
+
+	rule.description = stringValue(m["description"])
+
+	if expr := m["expr"]; expr != nil {
+		rule.evaluator = "expr"
+		rule.args = []string{stringValue(expr)}
+		return rule, nil
+	}
+
+	rule.evaluator = stringValue(m["evaluator"])
+	if rule.evaluator == "" {
+		return rule, util.FmtNewtError(
+			"must specify \"expr\" or \"evaluator\"")
+	}
+
+	if m["args"] != nil {
+		args, err := cast.ToStringSliceE(m["args"])
+		if err != nil {
+			return rule, util.FmtNewtError(
+				"\"args\" must be a sequence of strings")
+		}
+		rule.args = args
+	}
+
+	return rule, nil
+}
+
+func (cfg *Cfg) readDerivedRules(lpkgs []*pkg.LocalPackage,
+	settings *cfgv.Settings) ([]derivedRule, error) {
+
+	var rules []derivedRule
+	for _, lpkg := range lpkgs {
+		lsettings := cfg.settingsForLpkg(lpkg, settings)
+
+		derived, err := lpkg.SyscfgY.GetValStringMap(
+			"syscfg.derived", lsettings)
+		util.OneTimeWarningError(err)
+
+		for name, v := range derived {
+			rule, err := readDerivedRule(name, lpkg, v)
+			if err != nil {
+				return nil, util.FmtNewtError(
+					"Config for package %s: derived setting \"%s\": %s",
+					lpkg.FullName(), name, err.Error())
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	sort.Slice(rules, func(i int, j int) bool {
+		if rules[i].name != rules[j].name {
+			return rules[i].name < rules[j].name
+		}
+		return rules[i].lpkg.FullName() < rules[j].lpkg.FullName()
+	})
+
+	return rules, nil
+}
+
+// readDerived adds the derived settings declared by the specified packages.
+// Derived settings may refer to each other, so evaluation repeats until the
+// values stop changing.
+func (cfg *Cfg) readDerived(lpkgs []*pkg.LocalPackage,
+	settings *cfgv.Settings) error {
+
+	rules, err := cfg.readDerivedRules(lpkgs, settings)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	// Only the first package to declare a setting defines it; the rest are
+	// redefinitions.
+	defined := map[string]*derivedRule{}
+	for i, _ := range rules {
+		rule := &rules[i]
+		if first := defined[rule.name]; first != nil {
+			cfg.addRedefine(rule.name, first.lpkg, rule.lpkg)
+			continue
+		}
+
+		if entry, ok := cfg.Settings[rule.name]; ok &&
+			!mostRecentPoint(entry).IsInjected() {
+
+			cfg.addRedefine(rule.name, entry.PackageDef, rule.lpkg)
+			continue
+		}
+
+		defined[rule.name] = rule
+	}
+
+	names := make([]string, 0, len(defined))
+	for name, _ := range defined {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for pass := 0; pass <= len(names); pass++ {
+		values := cfg.SettingValues()
+		changed := false
+
+		// A rule may refer to a derived setting that has not been evaluated
+		// yet.  Errors are only reported once no more progress is possible.
+		var evalErr error
+
+		for _, name := range names {
+			rule := defined[name]
+
+			val, err := rule.eval(values)
+			if err != nil {
+				if evalErr == nil {
+					evalErr = util.FmtNewtError(
+						"Config for package %s: derived setting \"%s\": %s",
+						rule.lpkg.FullName(), name, err.Error())
+				}
+				continue
+			}
+
+			if entry, ok := cfg.Settings[name]; ok &&
+				entry.PackageDef == rule.lpkg && entry.Value == val {
+
+				continue
+			}
+
+			desc := rule.description
+			if desc == "" {
+				desc = "Derived by " + rule.lpkg.FullName()
+			}
+
+			cfg.Settings[name] = CfgEntry{
+				Name:        name,
+				Description: desc,
+				PackageDef:  rule.lpkg,
+				State:       CFG_SETTING_STATE_CONST,
+				Value:       val,
+				History: []CfgPoint{{
+					Value:  val,
+					Source: rule.lpkg,
+				}},
+			}
+			changed = true
+		}
+
+		if !changed {
+			if evalErr != nil {
+				return evalErr
+			}
+			cfg.flagDerivedOverrides(names)
+			return nil
+		}
+	}
+
+	return util.FmtNewtError(
+		"derived settings do not converge: %s", strings.Join(names, ", "))
+}
+
+// flagDerivedOverrides reports attempts to override derived settings.  Such
+// overrides are read before the derived settings are defined, so they are
+// initially recorded as orphans.
+func (cfg *Cfg) flagDerivedOverrides(names []string) {
+	for _, name := range names {
+		points := cfg.Orphans[name]
+		if len(points) == 0 {
+			continue
+		}
+
+		entry := cfg.Settings[name]
+		entry.History = append(entry.History, points...)
+		cfg.Settings[name] = entry
+
+		cfg.Consts[name] = struct{}{}
+		delete(cfg.Orphans, name)
+	}
+}
@@ -26,13 +26,15 @@ import (
 )
 
 var cfgSettingNameTypeMap = map[string]CfgSettingType{
-	"raw":           CFG_SETTING_TYPE_RAW,
-	"task_priority": CFG_SETTING_TYPE_TASK_PRIO,
-	"flash_owner":   CFG_SETTING_TYPE_FLASH_OWNER,
+	"raw":                CFG_SETTING_TYPE_RAW,
+	"task_priority":      CFG_SETTING_TYPE_TASK_PRIO,
+	"interrupt_priority": CFG_SETTING_TYPE_INTERRUPT_PRIO,
+	"flash_owner":        CFG_SETTING_TYPE_FLASH_OWNER,
 }
 
 var cfgSettingNameStateMap = map[string]CfgSettingState{
 	"good":         CFG_SETTING_STATE_GOOD,
+	"const":        CFG_SETTING_STATE_CONST,
 	"deprecated":   CFG_SETTING_STATE_DEPRECATED,
 	"defunct":      CFG_SETTING_STATE_DEFUNCT,
 	"experimental": CFG_SETTING_STATE_EXPERIMENTAL,
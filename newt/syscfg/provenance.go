@@ -0,0 +1,125 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package syscfg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/project"
+)
+
+// Finds the line of a package's syscfg.yml file that sets a setting's value.
+// The search is textual: it returns the first line in a "syscfg.vals" block
+// that starts with the setting's name.  It returns 0 if no such line exists.
+func settingValueLine(path string, name string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	keyRe := regexp.MustCompile(`^\s+["']?` + regexp.QuoteMeta(name) +
+		`["']?\s*:`)
+
+	inVals := false
+	lineNum := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+
+		// A top-level key starts a new block.
+		if line != "" && line[0] != ' ' && line[0] != '\t' &&
+			line[0] != '#' {
+
+			inVals = strings.HasPrefix(line, "syscfg.vals")
+			continue
+		}
+
+		if inVals && keyRe.MatchString(line) {
+			return lineNum
+		}
+	}
+
+	return 0
+}
+
+// Describes where a value in a setting's history came from: the package's
+// syscfg.yml file and line, relative to the project base.
+func pointLocation(name string, point CfgPoint) string {
+	if point.Source == nil {
+		return ""
+	}
+
+	path := point.Source.SyscfgYamlPath()
+	line := settingValueLine(path, name)
+	if line == 0 {
+		return ""
+	}
+
+	path = strings.TrimPrefix(path, project.GetProject().Path()+"/")
+	return fmt.Sprintf("%s:%d", path, line)
+}
+
+// Prepares a setting value for inclusion in a C block comment.  A "*/" in the
+// value would end the comment early, so it is broken up.
+func commentValue(val string) string {
+	return strings.Replace(val, "*/", "* /", -1)
+}
+
+// Writes a comment listing every package that set a setting's value, in
+// override order, along with the location of the final override.
+func writeProvenanceComment(entry CfgEntry, w io.Writer) {
+	if len(entry.History) == 0 {
+		return
+	}
+
+	def := entry.History[0]
+	fmt.Fprintf(w, "/* %s: defined by %s = %s\n", entry.Name, def.Name(),
+		commentValue(def.Value))
+
+	for _, point := range entry.History[1:] {
+		if point.IsInjected() {
+			fmt.Fprintf(w, " *   overridden on the command line = %s\n",
+				commentValue(point.Value))
+		} else {
+			fmt.Fprintf(w, " *   overridden by %s = %s\n", point.Name(),
+				commentValue(point.Value))
+		}
+	}
+
+	if len(entry.History) > 1 {
+		last := mostRecentPoint(entry)
+		if loc := pointLocation(entry.Name, last); loc != "" {
+			fmt.Fprintf(w, " *   final value set at %s\n", loc)
+		}
+	}
+
+	if len(entry.ValueRefName) > 1 {
+		fmt.Fprintf(w, " *   value copied from %s\n", entry.ValueRefName)
+	}
+
+	fmt.Fprintf(w, " */\n")
+}
@@ -17,12 +17,22 @@
  * under the License.
  */
 
-// Currently, two forms of restrictions are supported:
+// Currently, four forms of restrictions are supported:
 // 1. "$notnull"
-// 2. expression
+// 2. RANGE(<min>,<max>)
+// 3. REQUIRES(<package>)
+// 4. expression
 //
 // The "$notnull" string indicates that the setting must be set to something
-// other than the empty string.
+// other than the empty string.  A quoted empty string (`""`) is considered
+// null as well.
+//
+// RANGE(<min>,<max>) indicates that the setting's value must lie within the
+// specified inclusive bounds.  Each bound may be an expression.
+//
+// REQUIRES(<package>) indicates that the specified package must be present
+// in the build whenever the setting is enabled.  When used in a
+// `syscfg.restrictions` list, the package is required unconditionally.
 //
 // An expression string can take two forms:
 // 1. Full expression
@@ -51,6 +61,14 @@
 //     # (full expression)
 //     pkg.restrictions:
 //         - '(LOG_FCB && CONSOLE_UART == "uart0") || !MYSETTING
+//
+//     # Value must be between 1 and 16.
+//     pkg.restrictions:
+//         - RANGE(1,16)
+//
+//     # Can't enable this setting unless the sys/log/full package is present.
+//     pkg.restrictions:
+//         - REQUIRES(@apache-mynewt-core/sys/log/full)
 
 package syscfg
 
@@ -58,10 +76,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"mynewt.apache.org/newt/newt/cfgv"
+	"regexp"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
-
 	"mynewt.apache.org/newt/newt/parse"
 	"mynewt.apache.org/newt/util"
 )
@@ -73,6 +90,7 @@ const (
 	CFG_RESTRICTION_CODE_EXPR
 	CFG_RESTRICTION_CODE_CHOICE
 	CFG_RESTRICTION_CODE_RANGE
+	CFG_RESTRICTION_CODE_REQUIRES
 )
 
 var cfgRestrictionNameCodeMap = map[string]CfgRestrictionCode{
@@ -80,8 +98,12 @@ var cfgRestrictionNameCodeMap = map[string]CfgRestrictionCode{
 	"expr":     CFG_RESTRICTION_CODE_EXPR,
 	"choice":   CFG_RESTRICTION_CODE_CHOICE,
 	"range":    CFG_RESTRICTION_CODE_RANGE,
+	"requires": CFG_RESTRICTION_CODE_REQUIRES,
 }
 
+var cfgRestrictionRangeRe = regexp.MustCompile(`^RANGE\((.*)\)$`)
+var cfgRestrictionRequiresRe = regexp.MustCompile(`^REQUIRES\((.*)\)$`)
+
 type CfgRestrictionRange struct {
 	LExpr string
 	RExpr string
@@ -91,7 +113,8 @@ type CfgRestriction struct {
 	BaseSetting string
 	Code        CfgRestrictionCode
 
-	// Only used if Code is either CFG_RESTRICTION_CODE_EXPR or CFG_RESTRICTION_CODE_RANGE
+	// Only used if Code is CFG_RESTRICTION_CODE_EXPR, CFG_RESTRICTION_CODE_RANGE
+	// or CFG_RESTRICTION_CODE_REQUIRES (in which case it is a package name)
 	Expr string
 
 	// Only used if Code is CFG_RESTRICTION_CODE_RANGE
@@ -140,6 +163,41 @@ func readRestriction(baseSetting string, text string) (CfgRestriction, error) {
 		BaseSetting: baseSetting,
 	}
 
+	text = strings.TrimSpace(text)
+
+	if m := cfgRestrictionRangeRe.FindStringSubmatch(text); m != nil {
+		if baseSetting == "" {
+			return r, util.FmtNewtError(
+				"RANGE restriction requires a setting: %s", text)
+		}
+
+		bounds := strings.Split(m[1], ",")
+		if len(bounds) != 2 ||
+			strings.TrimSpace(bounds[0]) == "" ||
+			strings.TrimSpace(bounds[1]) == "" {
+
+			return r, util.FmtNewtError(
+				"invalid RANGE restriction \"%s\"; "+
+					"expected RANGE(<min>,<max>)", text)
+		}
+
+		return createRangeRestriction(baseSetting,
+			strings.TrimSpace(bounds[0])+".."+strings.TrimSpace(bounds[1]))
+	}
+
+	if m := cfgRestrictionRequiresRe.FindStringSubmatch(text); m != nil {
+		pkgName := strings.TrimSpace(m[1])
+		if pkgName == "" {
+			return r, util.FmtNewtError(
+				"invalid REQUIRES restriction \"%s\"; "+
+					"expected REQUIRES(<package>)", text)
+		}
+
+		r.Code = CFG_RESTRICTION_CODE_REQUIRES
+		r.Expr = pkgName
+		return r, nil
+	}
+
 	var ok bool
 	if r.Code, ok = cfgRestrictionNameCodeMap[text]; !ok {
 		// If the restriction text isn't a defined string, parse it as an
@@ -242,12 +300,20 @@ func (cfg *Cfg) settingViolationText(entry CfgEntry, r CfgRestriction) string {
 		return prefix + "must be one of defined choices (see definition)"
 	} else if r.Code == CFG_RESTRICTION_CODE_RANGE {
 		return prefix + "must be in range: " + r.Expr
+	} else if r.Code == CFG_RESTRICTION_CODE_REQUIRES {
+		return fmt.Sprintf("%srequires package %s, which is not present; "+
+			"setting enabled by %s", prefix, r.Expr,
+			mostRecentPoint(entry).Name())
 	} else {
 		return prefix + "requires: " + r.Expr
 	}
 }
 
 func (cfg *Cfg) packageViolationText(pkgName string, r CfgRestriction) string {
+	if r.Code == CFG_RESTRICTION_CODE_REQUIRES {
+		return fmt.Sprintf("Package %s requires package %s, "+
+			"which is not present", pkgName, r.Expr)
+	}
 	return fmt.Sprintf("Package %s requires: %s", pkgName, r.Expr)
 }
 
@@ -284,7 +350,16 @@ func (cfg *Cfg) restrictionMet(
 
 	switch r.Code {
 	case CFG_RESTRICTION_CODE_NOTNULL:
-		return baseEntry.Value != ""
+		val := strings.TrimSpace(baseEntry.Value)
+		return val != "" && val != `""`
+
+	case CFG_RESTRICTION_CODE_REQUIRES:
+		if r.BaseSetting != "" && !baseEntry.IsTrue() {
+			// Requirement only applies when the setting is enabled.
+			return true
+		}
+		_, ok := cfg.pkgNames[r.Expr]
+		return ok
 
 	case CFG_RESTRICTION_CODE_CHOICE:
 		if baseEntry.Value == "" {
@@ -312,7 +387,7 @@ func (cfg *Cfg) restrictionMet(
 		if err != nil {
 			util.OneTimeWarning(
 				"Ignoring illegal range expression for setting \"%s\": "+
-					"`%s`\n", r.BaseSetting, r.Expr)
+					"`%s` %s\n", r.BaseSetting, r.Expr, err.Error())
 			return true
 		}
 
@@ -0,0 +1,114 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package syscfg
+
+import (
+	"sort"
+	"strings"
+)
+
+// Computes the Levenshtein distance between two strings.
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+// SuggestSetting returns the defined setting whose name most closely
+// resembles the specified (unknown) name, or "" if none is close.
+func (cfg *Cfg) SuggestSetting(name string) string {
+	upper := strings.ToUpper(name)
+
+	names := make([]string, 0, len(cfg.Settings))
+	for n, _ := range cfg.Settings {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	// Allow roughly one typo per four characters.
+	best := ""
+	bestDist := len(name)/4 + 1
+	for _, n := range names {
+		if n == upper {
+			return n
+		}
+
+		d := editDistance(upper, n)
+		if d < bestDist {
+			best = n
+			bestDist = d
+		}
+	}
+
+	return best
+}
+
+// ViolationTexts describes each restriction that the configuration violates,
+// one line per violation, sorted.  Unlike ErrorText(), choice violations list
+// the valid choices.
+func (cfg *Cfg) ViolationTexts() []string {
+	var lines []string
+
+	for settingName, rslice := range cfg.SettingViolations {
+		entry := cfg.Settings[settingName]
+		for _, r := range rslice {
+			line := cfg.settingViolationText(entry, r)
+			if r.Code == CFG_RESTRICTION_CODE_CHOICE {
+				line = "Setting " + entry.Name + "(" + entry.Value +
+					") must be one of: " +
+					strings.Join(entry.ValidChoices, ", ")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	for pkgName, rslice := range cfg.PackageViolations {
+		for _, r := range rslice {
+			lines = append(lines, cfg.packageViolationText(pkgName, r))
+		}
+	}
+
+	sort.Strings(lines)
+	return lines
+}
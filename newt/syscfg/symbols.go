@@ -0,0 +1,111 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package syscfg
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Directory, relative to the generated include directory, that holds one file
+// per macro defined in syscfg.h.  A macro's file is only rewritten when the
+// macro's definition changes, so the builder can tell which settings changed
+// since a source file was last compiled.  This allows a source file to be
+// rebuilt only when a setting it refers to changes rather than whenever
+// syscfg.h changes.
+const SYMBOL_DIR = "syscfg/symbols"
+
+// Holds the definitions that are not specific to one setting, package, or
+// API: the function-like macros (e.g., MYNEWT_VAL()) and the include guard.
+const SYMBOL_COMMON = "_common"
+
+// Created along with the symbol directory and never modified.  An object file
+// older than this file was built before the symbol files existed.
+const SYMBOL_STAMP = "_stamp"
+
+// Contents of the file of a macro that is no longer defined.
+const SYMBOL_UNDEFINED = "undefined\n"
+
+var symbolDefineRe = regexp.MustCompile(`^#\s*(?:define|undef)\s+(\w+)(\(?)`)
+
+// Splits the contents of syscfg.h into the definition of each macro.  Lines
+// other than `#define` and `#undef` (comments, `#ifndef` guards) are
+// ignored.
+func splitSymbols(header []byte) map[string][]byte {
+	symbols := map[string][]byte{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(header))
+	for scanner.Scan() {
+		line := scanner.Text()
+		m := symbolDefineRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		name := m[1]
+		if m[2] != "" || name == "H_MYNEWT_SYSCFG_" {
+			name = SYMBOL_COMMON
+		}
+
+		symbols[name] = append(symbols[name], []byte(line+"\n")...)
+	}
+
+	return symbols
+}
+
+// Writes the file of each macro defined in the specified syscfg.h contents.
+// Files of macros that are no longer defined are marked as undefined rather
+// than removed, so that the removal is also seen as a change.
+func writeSymbolFiles(header []byte, dir string) error {
+	symbols := splitSymbols(header)
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return util.ChildNewtError(err)
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if _, ok := symbols[name]; !ok && name != SYMBOL_STAMP {
+			symbols[name] = []byte(SYMBOL_UNDEFINED)
+		}
+	}
+
+	names := make([]string, 0, len(symbols))
+	for name, _ := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err := util.WriteGeneratedFile(dir+"/"+name, symbols[name])
+		if err != nil {
+			return err
+		}
+	}
+
+	// The stamp's contents never change, so it keeps its creation time.
+	return util.WriteGeneratedFile(dir+"/"+SYMBOL_STAMP, nil)
+}
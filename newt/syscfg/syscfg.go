@@ -23,16 +23,12 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"mynewt.apache.org/newt/newt/cfgv"
-	"os"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cast"
 
 	"mynewt.apache.org/newt/newt/flashmap"
@@ -44,6 +40,8 @@ import (
 	"mynewt.apache.org/newt/util"
 )
 
+var log = util.SubsysLogger(util.LOG_SUBSYS_SYSCFG)
+
 const HEADER_PATH = "syscfg/syscfg.h"
 
 const SYSCFG_PREFIX_SETTING = "MYNEWT_VAL_"
@@ -105,6 +103,16 @@ type CfgEntry struct {
 	PackageDef   *pkg.LocalPackage
 	History      []CfgPoint
 	State        CfgSettingState
+
+	// Optional UI metadata.  Group is the name of the group the setting
+	// belongs to (e.g., "Bluetooth/Host"); Advanced indicates that the
+	// setting is rarely changed and can be hidden by default.
+	Group    string
+	Advanced bool
+
+	// Name of the choice group this setting belongs to, or "".  Exactly one
+	// setting in each choice group must be enabled.
+	Choice string
 }
 
 type CfgPriority struct {
@@ -124,6 +132,10 @@ type Cfg struct {
 	// Restrictions at the package level (i.e. "syscfg.restrictions").
 	PackageRestrictions map[string][]CfgRestriction
 
+	// Names of all packages in the build, with and without the repo prefix.
+	// Used to evaluate REQUIRES restrictions.
+	pkgNames map[string]struct{}
+
 	//// Errors
 	// Overrides of undefined settings ([setting-name] => ...).
 	Orphans map[string][]CfgPoint
@@ -144,6 +156,10 @@ type Cfg struct {
 	// Two or more flash areas overlap.
 	FlashConflicts []CfgFlashConflict
 
+	// Choice groups without exactly one enabled setting.
+	// [choice-name] => <slice-of-enabled-setting-names>
+	ChoiceViolations map[string][]string
+
 	// Multiple packages defining the same setting.
 	// [setting-name][defining-package][{}]
 	Redefines map[string]map[*pkg.LocalPackage]struct{}
@@ -168,12 +184,14 @@ func NewCfg() Cfg {
 	return Cfg{
 		Settings:            map[string]CfgEntry{},
 		PackageRestrictions: map[string][]CfgRestriction{},
+		pkgNames:            map[string]struct{}{},
 		Orphans:             map[string][]CfgPoint{},
 		Ambiguities:         map[string][]CfgPoint{},
 		SettingViolations:   map[string][]CfgRestriction{},
 		PackageViolations:   map[string][]CfgRestriction{},
 		PriorityViolations:  []CfgPriority{},
 		FlashConflicts:      []CfgFlashConflict{},
+		ChoiceViolations:    map[string][]string{},
 		Redefines:           map[string]map[*pkg.LocalPackage]struct{}{},
 		Deprecated:          map[string]struct{}{},
 		Defunct:             map[string]struct{}{},
@@ -450,6 +468,9 @@ func readSetting(name string, lpkg *pkg.LocalPackage,
 	entry.Name = name
 	entry.PackageDef = lpkg
 	entry.Description = stringValue(vals["description"])
+	entry.Group = stringValue(vals["group"])
+	entry.Advanced = boolValue(vals["advanced"])
+	entry.Choice = stringValue(vals["choice"])
 
 	if boolValue(vals["defunct"]) {
 		entry.State = CFG_SETTING_STATE_DEFUNCT
@@ -670,6 +691,26 @@ func (cfg *Cfg) readDefsOnce(lpkg *pkg.LocalPackage,
 	return nil
 }
 
+// Reads the setting definitions in a single package's `syscfg.yml` file,
+// without resolving a target.  Definitions that are conditional on other
+// settings are not included.  The returned entries are sorted by name.
+func ReadPkgDefs(lpkg *pkg.LocalPackage) ([]CfgEntry, error) {
+	cfg := NewCfg()
+	if err := cfg.readDefsOnce(lpkg, cfgv.NewSettings(nil)); err != nil {
+		return nil, err
+	}
+
+	entries := make([]CfgEntry, 0, len(cfg.Settings))
+	for _, entry := range cfg.Settings {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i int, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
+
 // Records an orphan override error (override of undefined setting).
 func (cfg *Cfg) addOrphan(settingName string, value string,
 	lpkg *pkg.LocalPackage) {
@@ -876,6 +917,42 @@ func (cfg *Cfg) detectPriorityViolations() {
 	}
 }
 
+// Returns a map of choice group name to the sorted names of its member
+// settings.
+func (cfg *Cfg) ChoiceGroups() map[string][]string {
+	groups := map[string][]string{}
+
+	for name, entry := range cfg.Settings {
+		if entry.Choice != "" {
+			groups[entry.Choice] = append(groups[entry.Choice], name)
+		}
+	}
+
+	for _, names := range groups {
+		sort.Strings(names)
+	}
+
+	return groups
+}
+
+// Detects choice groups with zero or multiple enabled settings and records
+// them internally.
+func (cfg *Cfg) detectChoiceViolations() {
+	for choice, names := range cfg.ChoiceGroups() {
+		enabled := []string{}
+		for _, name := range names {
+			entry := cfg.Settings[name]
+			if entry.IsTrue() {
+				enabled = append(enabled, name)
+			}
+		}
+
+		if len(enabled) != 1 {
+			cfg.ChoiceViolations[choice] = enabled
+		}
+	}
+}
+
 // Detects all flash conflict errors in the syscfg and records them internally.
 func (cfg *Cfg) detectFlashConflicts(flashMap flashmap.FlashMap) {
 	entries := cfg.settingsOfType(CFG_SETTING_TYPE_FLASH_OWNER)
@@ -1063,6 +1140,33 @@ func (cfg *Cfg) ErrorText() string {
 		}
 	}
 
+	// Choice errors.
+	if len(cfg.ChoiceViolations) > 0 {
+		groups := cfg.ChoiceGroups()
+
+		choices := make([]string, 0, len(cfg.ChoiceViolations))
+		for c, _ := range cfg.ChoiceViolations {
+			choices = append(choices, c)
+		}
+		sort.Strings(choices)
+
+		str += "Syscfg choice violations detected:\n"
+		for _, c := range choices {
+			for _, name := range groups[c] {
+				historyMap[name] = cfg.Settings[name].History
+			}
+
+			enabled := cfg.ChoiceViolations[c]
+			if len(enabled) == 0 {
+				str += fmt.Sprintf("    Choice %s: no setting enabled; "+
+					"enable one of: %s\n", c, strings.Join(groups[c], ", "))
+			} else {
+				str += fmt.Sprintf("    Choice %s: multiple settings "+
+					"enabled: %s\n", c, strings.Join(enabled, ", "))
+			}
+		}
+	}
+
 	// Overrides of defunct settings.
 	if len(cfg.Defunct) > 0 {
 		str += "Override of defunct settings detected:\n"
@@ -1188,6 +1292,8 @@ func normalizePkgType(typ interfaces.PackageType) interfaces.PackageType {
 		return pkg.PACKAGE_TYPE_APP
 	case pkg.PACKAGE_TYPE_UNITTEST:
 		return pkg.PACKAGE_TYPE_UNITTEST
+	case pkg.PACKAGE_TYPE_BUNDLE:
+		return pkg.PACKAGE_TYPE_BUNDLE
 	case pkg.PACKAGE_TYPE_BSP:
 		return pkg.PACKAGE_TYPE_BSP
 	case pkg.PACKAGE_TYPE_CONFIG:
@@ -1205,6 +1311,7 @@ func categorizePkgs(
 		pkg.PACKAGE_TYPE_CONFIG:   []*pkg.LocalPackage{},
 		pkg.PACKAGE_TYPE_APP:      []*pkg.LocalPackage{},
 		pkg.PACKAGE_TYPE_UNITTEST: []*pkg.LocalPackage{},
+		pkg.PACKAGE_TYPE_BUNDLE:   []*pkg.LocalPackage{},
 		pkg.PACKAGE_TYPE_BSP:      []*pkg.LocalPackage{},
 		pkg.PACKAGE_TYPE_LIB:      []*pkg.LocalPackage{},
 	}
@@ -1257,6 +1364,7 @@ func (cfg *Cfg) DetectErrors(flashMap flashmap.FlashMap) {
 	cfg.detectAmbiguities()
 	cfg.detectViolations()
 	cfg.detectPriorityViolations()
+	cfg.detectChoiceViolations()
 	cfg.detectFlashConflicts(flashMap)
 }
 
@@ -1285,14 +1393,21 @@ func Read(lpkgs []*pkg.LocalPackage, apis []string,
 	//     * target
 	//     * app (if present)
 	//     * unittest (if no app)
+	//     * bundle
 	//     * bsp
 	//     * everything else (lib, sdk, compiler)
 
+	for _, lpkg := range lpkgs {
+		cfg.pkgNames[lpkg.FullName()] = struct{}{}
+		cfg.pkgNames[lpkg.Name()] = struct{}{}
+	}
+
 	lpkgMap := categorizePkgs(lpkgs)
 
 	for _, ptype := range []interfaces.PackageType{
 		pkg.PACKAGE_TYPE_LIB,
 		pkg.PACKAGE_TYPE_BSP,
+		pkg.PACKAGE_TYPE_BUNDLE,
 		pkg.PACKAGE_TYPE_UNITTEST,
 		pkg.PACKAGE_TYPE_APP,
 		pkg.PACKAGE_TYPE_CONFIG,
@@ -1306,6 +1421,7 @@ func Read(lpkgs []*pkg.LocalPackage, apis []string,
 	for _, ptype := range []interfaces.PackageType{
 		pkg.PACKAGE_TYPE_LIB,
 		pkg.PACKAGE_TYPE_BSP,
+		pkg.PACKAGE_TYPE_BUNDLE,
 		pkg.PACKAGE_TYPE_UNITTEST,
 		pkg.PACKAGE_TYPE_APP,
 		pkg.PACKAGE_TYPE_CONFIG,
@@ -1316,6 +1432,10 @@ func Read(lpkgs []*pkg.LocalPackage, apis []string,
 		}
 	}
 
+	if err := cfg.readDerived(lpkgs, settings); err != nil {
+		return cfg, err
+	}
+
 	for _, lpkg := range lpkgs {
 		if err := cfg.readRestrictions(lpkg, settings); err != nil {
 			return cfg, err
@@ -1424,6 +1544,11 @@ func writeCheckMacros(w io.Writer) {
 }
 
 func writeComment(entry CfgEntry, w io.Writer) {
+	if util.SyscfgProvenance {
+		writeProvenanceComment(entry, w)
+		return
+	}
+
 	if len(entry.History) > 1 {
 		fmt.Fprintf(w, "/* Overridden by %s (defined by %s) */\n",
 			mostRecentPoint(entry).Name(),
@@ -1651,27 +1776,11 @@ func EnsureWritten(cfg Cfg, includeDir string, lpkgs []*pkg.LocalPackage, apis [
 	write(cfg, lpkgs, apis, &buf)
 
 	path := includeDir + "/" + HEADER_PATH
-
-	writeReqd, err := util.FileContentsChanged(path, buf.Bytes())
-	if err != nil {
+	if err := util.WriteGeneratedFile(path, buf.Bytes()); err != nil {
 		return err
 	}
-	if !writeReqd {
-		log.Debugf("syscfg unchanged; not writing header file (%s).", path)
-		return nil
-	}
-
-	log.Debugf("syscfg changed; writing header file (%s).", path)
-
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return util.NewNewtError(err.Error())
-	}
 
-	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
-		return util.NewNewtError(err.Error())
-	}
-
-	return nil
+	return writeSymbolFiles(buf.Bytes(), includeDir+"/"+SYMBOL_DIR)
 }
 
 func KeyValueFromStr(str string) (map[string]string, error) {
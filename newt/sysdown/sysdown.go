@@ -23,11 +23,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
 
 	"mynewt.apache.org/newt/newt/newtutil"
 	"mynewt.apache.org/newt/newt/pkg"
 	"mynewt.apache.org/newt/newt/stage"
 	"mynewt.apache.org/newt/newt/syscfg"
+	"mynewt.apache.org/newt/util"
 )
 
 type SysdownCfg struct {
@@ -132,7 +134,7 @@ func (scfg *SysdownCfg) ErrorText() string {
 	return str
 }
 
-func (scfg *SysdownCfg) write(lpkgs []*pkg.LocalPackage, isLoader bool,
+func (scfg *SysdownCfg) write(lpkgs []*pkg.LocalPackage, guard string,
 	w io.Writer) error {
 
 	var sfs []stage.StageFunc
@@ -144,11 +146,7 @@ func (scfg *SysdownCfg) write(lpkgs []*pkg.LocalPackage, isLoader bool,
 
 	fmt.Fprintf(w, newtutil.GeneratedPreamble())
 
-	if isLoader {
-		fmt.Fprintf(w, "#if SPLIT_LOADER\n\n")
-	} else {
-		fmt.Fprintf(w, "#if !SPLIT_LOADER\n\n")
-	}
+	fmt.Fprintf(w, "#if %s\n\n", guard)
 
 	stage.WritePrototypes(sfs, w)
 
@@ -170,16 +168,34 @@ func (scfg *SysdownCfg) EnsureWritten(lpkgs []*pkg.LocalPackage, srcDir string,
 	targetName string, isLoader bool) error {
 
 	buf := bytes.Buffer{}
-	if err := scfg.write(lpkgs, isLoader, &buf); err != nil {
-		return err
-	}
 
 	var path string
+	var err error
 	if isLoader {
+		err = scfg.write(lpkgs, "SPLIT_LOADER", &buf)
 		path = fmt.Sprintf("%s/%s-sysdown-loader.c", srcDir, targetName)
 	} else {
+		err = scfg.write(lpkgs, "!SPLIT_LOADER && !EXTRA_APP", &buf)
 		path = fmt.Sprintf("%s/%s-sysdown-app.c", srcDir, targetName)
 	}
+	if err != nil {
+		return err
+	}
+
+	return stage.EnsureWritten(path, buf.Bytes())
+}
+
+// EnsureWrittenExtraApp writes the sysdown callback table for one of the
+// target's extra apps.
+func (scfg *SysdownCfg) EnsureWrittenExtraApp(lpkgs []*pkg.LocalPackage,
+	srcDir string, targetName string, appName string) error {
+
+	buf := bytes.Buffer{}
+	guard := "EXTRA_APP_" + strings.ToUpper(util.CIdentifier(appName))
+	if err := scfg.write(lpkgs, guard, &buf); err != nil {
+		return err
+	}
 
+	path := fmt.Sprintf("%s/%s-sysdown-%s.c", srcDir, targetName, appName)
 	return stage.EnsureWritten(path, buf.Bytes())
 }
@@ -306,8 +306,8 @@ func (scfg *SysinitCfg) ErrorText() string {
 	return str
 }
 
-func (scfg *SysinitCfg) write(lpkgs []*pkg.LocalPackage, isLoader bool,
-	w io.Writer) error {
+func (scfg *SysinitCfg) write(lpkgs []*pkg.LocalPackage, guard string,
+	fnName string, w io.Writer) error {
 
 	var sfs []stage.StageFunc
 	if lpkgs == nil {
@@ -318,21 +318,10 @@ func (scfg *SysinitCfg) write(lpkgs []*pkg.LocalPackage, isLoader bool,
 
 	fmt.Fprintf(w, newtutil.GeneratedPreamble())
 
-	if isLoader {
-		fmt.Fprintf(w, "#if SPLIT_LOADER\n\n")
-	} else {
-		fmt.Fprintf(w, "#if !SPLIT_LOADER\n\n")
-	}
+	fmt.Fprintf(w, "#if %s\n\n", guard)
 
 	stage.WritePrototypes(sfs, w)
 
-	var fnName string
-	if isLoader {
-		fnName = "sysinit_loader"
-	} else {
-		fnName = "sysinit_app"
-	}
-
 	fmt.Fprintf(w, "\n")
 	fmt.Fprintf(w, "void\n%s(void)\n{\n", fnName)
 
@@ -348,16 +337,35 @@ func (scfg *SysinitCfg) EnsureWritten(lpkgs []*pkg.LocalPackage, srcDir string,
 	targetName string, isLoader bool) error {
 
 	buf := bytes.Buffer{}
-	if err := scfg.write(lpkgs, isLoader, &buf); err != nil {
-		return err
-	}
 
 	var path string
+	var err error
 	if isLoader {
+		err = scfg.write(lpkgs, "SPLIT_LOADER", "sysinit_loader", &buf)
 		path = fmt.Sprintf("%s/%s-sysinit-loader.c", srcDir, targetName)
 	} else {
+		err = scfg.write(lpkgs, "!SPLIT_LOADER && !EXTRA_APP", "sysinit_app",
+			&buf)
 		path = fmt.Sprintf("%s/%s-sysinit-app.c", srcDir, targetName)
 	}
+	if err != nil {
+		return err
+	}
+
+	return stage.EnsureWritten(path, buf.Bytes())
+}
+
+// EnsureWrittenExtraApp writes the sysinit function for one of the target's
+// extra apps.  The function is only compiled into the extra app's image.
+func (scfg *SysinitCfg) EnsureWrittenExtraApp(lpkgs []*pkg.LocalPackage,
+	srcDir string, targetName string, appName string) error {
+
+	buf := bytes.Buffer{}
+	guard := "EXTRA_APP_" + strings.ToUpper(util.CIdentifier(appName))
+	if err := scfg.write(lpkgs, guard, "sysinit_app", &buf); err != nil {
+		return err
+	}
 
+	path := fmt.Sprintf("%s/%s-sysinit-%s.c", srcDir, targetName, appName)
 	return stage.EnsureWritten(path, buf.Bytes())
 }
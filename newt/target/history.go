@@ -0,0 +1,188 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package target
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/util"
+)
+
+// Directory, relative to the project base, where the history of each target
+// is kept.
+const HISTORY_DIR = ".newt/history"
+
+// Maximum number of changes remembered per target.
+const HISTORY_MAX = 20
+
+const historyCmdFilename = "command"
+
+// The target files that are saved before each change.
+var historyFiles = []string{
+	TARGET_FILENAME,
+	pkg.PACKAGE_FILE_NAME,
+	pkg.SYSCFG_YAML_FILENAME,
+}
+
+// Describes the state of a target before one change.
+type HistoryEntry struct {
+	Seq     int
+	Path    string
+	Command string
+	Time    time.Time
+}
+
+func (t *Target) historyDir() string {
+	return project.GetProject().Path() + "/" + HISTORY_DIR + "/" +
+		t.FullName()
+}
+
+// Retrieves the target's recorded changes, oldest first.
+func (t *Target) History() ([]HistoryEntry, error) {
+	dir := t.historyDir()
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, util.ChildNewtError(err)
+	}
+
+	var entries []HistoryEntry
+	for _, info := range infos {
+		seq, err := strconv.Atoi(info.Name())
+		if err != nil || !info.IsDir() {
+			continue
+		}
+
+		entry := HistoryEntry{
+			Seq:  seq,
+			Path: dir + "/" + info.Name(),
+			Time: info.ModTime(),
+		}
+
+		cmd, err := ioutil.ReadFile(entry.Path + "/" + historyCmdFilename)
+		if err == nil {
+			entry.Command = strings.TrimSpace(string(cmd))
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i int, j int) bool {
+		return entries[i].Seq < entries[j].Seq
+	})
+
+	return entries, nil
+}
+
+// Saves a copy of the target's files, along with the command that is about to
+// modify them.  Nothing is recorded for a target that is being created.
+func (t *Target) recordHistory() error {
+	if util.NodeNotExist(t.TargetYamlPath()) {
+		return nil
+	}
+
+	entries, err := t.History()
+	if err != nil {
+		return err
+	}
+
+	seq := 1
+	if len(entries) > 0 {
+		seq = entries[len(entries)-1].Seq + 1
+	}
+
+	path := fmt.Sprintf("%s/%06d", t.historyDir(), seq)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	for _, name := range historyFiles {
+		src := t.basePkg.BasePath() + "/" + name
+		if util.NodeExist(src) {
+			if err := util.CopyFile(src, path+"/"+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	cmd := strings.Join(os.Args, " ") + "\n"
+	if err := ioutil.WriteFile(path+"/"+historyCmdFilename, []byte(cmd),
+		0644); err != nil {
+
+		return util.ChildNewtError(err)
+	}
+
+	// Discard the oldest changes.
+	for len(entries)+1 > HISTORY_MAX {
+		if err := os.RemoveAll(entries[0].Path); err != nil {
+			return util.ChildNewtError(err)
+		}
+		entries = entries[1:]
+	}
+
+	return nil
+}
+
+// Reverts the most recent recorded change to the target and removes it from
+// the history.  It returns the reverted change.
+func (t *Target) Undo() (HistoryEntry, error) {
+	entries, err := t.History()
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+	if len(entries) == 0 {
+		return HistoryEntry{}, util.FmtNewtError(
+			"target %s has no recorded changes", t.FullName())
+	}
+
+	entry := entries[len(entries)-1]
+	for _, name := range historyFiles {
+		src := entry.Path + "/" + name
+		dst := t.basePkg.BasePath() + "/" + name
+
+		if util.NodeExist(src) {
+			if err := util.CopyFile(src, dst); err != nil {
+				return entry, err
+			}
+		} else if util.NodeExist(dst) {
+			// The file did not exist before the change.
+			if err := os.Remove(dst); err != nil {
+				return entry, util.ChildNewtError(err)
+			}
+		}
+	}
+
+	if err := os.RemoveAll(entry.Path); err != nil {
+		return entry, util.ChildNewtError(err)
+	}
+
+	return entry, nil
+}
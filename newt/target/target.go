@@ -23,7 +23,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
 
 	"mynewt.apache.org/newt/newt/config"
 	"mynewt.apache.org/newt/newt/interfaces"
@@ -40,6 +44,24 @@ const DEFAULT_HEADER_SIZE uint32 = 0x20
 
 var globalTargetMap map[string]*Target
 
+// Build names that are reserved for newt's own use within a target's binary
+// directory.  An extra app may not use any of these as its name.
+var reservedExtraAppNames = []string{
+	"app", "loader", "generated", "user",
+}
+
+// An additional app that is built alongside the target's main app and linked
+// into a separate flash area (e.g., a factory test app).
+type ExtraApp struct {
+	// The app's key in the `target.extra_apps` map; also the name of its
+	// build directory.
+	Name string
+
+	AppName       string
+	FlashArea     string
+	LinkerScripts []string
+}
+
 type Target struct {
 	basePkg *pkg.LocalPackage
 
@@ -49,7 +71,27 @@ type Target struct {
 	BuildProfile string
 	HeaderSize   uint32
 	KeyFile      string
+	RomElf       string
+	ImageFormat  string
 	PkgProfiles  map[string]string
+	PkgPins      map[string]string
+	PkgOverrides map[string]string
+	ExtraApps    []ExtraApp
+
+	// Symbols to preserve in the split loader's linker ELF and in ROM
+	// exports (target.keep_symbols), and loader symbols to rename with a
+	// "_loader" suffix (target.rename_symbols).
+	KeepSymbols   []string
+	RenameSymbols []string
+
+	// Packages whose archives are moved to the start (target.link_first) or
+	// end (target.link_last) of the link order.
+	LinkFirst []string
+	LinkLast  []string
+
+	// The on-target profiler package that is added to instrumented builds
+	// (target.profiler).
+	ProfilerName string
 
 	// target.yml configuration structure
 	TargetY ycfg.YCfg
@@ -130,10 +172,52 @@ func (target *Target) Load(basePkg *pkg.LocalPackage) error {
 		}
 	}
 
+	target.RomElf, err = yc.GetValString("target.rom_elf", nil)
+	util.OneTimeWarningError(err)
+
+	if target.RomElf != "" {
+		proj := interfaces.GetProject()
+		path, err := proj.ResolvePath(proj.Path(), target.RomElf)
+		if err == nil {
+			target.RomElf = path
+		}
+	}
+
+	target.ImageFormat, err = yc.GetValString("target.image_format", nil)
+	util.OneTimeWarningError(err)
+
+	target.KeepSymbols, err = yc.GetValStringSlice("target.keep_symbols", nil)
+	util.OneTimeWarningError(err)
+
+	target.RenameSymbols, err = yc.GetValStringSlice(
+		"target.rename_symbols", nil)
+	util.OneTimeWarningError(err)
+
+	target.LinkFirst, err = yc.GetValStringSlice("target.link_first", nil)
+	util.OneTimeWarningError(err)
+
+	target.LinkLast, err = yc.GetValStringSlice("target.link_last", nil)
+	util.OneTimeWarningError(err)
+
+	target.ProfilerName, err = yc.GetValString("target.profiler", nil)
+	util.OneTimeWarningError(err)
+
 	target.PkgProfiles, err = yc.GetValStringMapString(
 		"target.package_profiles", nil)
 	util.OneTimeWarningError(err)
 
+	target.PkgPins, err = yc.GetValStringMapString("target.pkg_pins", nil)
+	util.OneTimeWarningError(err)
+
+	target.PkgOverrides, err = yc.GetValStringMapString(
+		"target.pkg_overrides", nil)
+	util.OneTimeWarningError(err)
+
+	target.ExtraApps, err = readExtraApps(yc)
+	if err != nil {
+		return err
+	}
+
 	// Note: App not required in the case of unit tests.
 
 	// Remember the name of the configuration file so that it can be specified
@@ -143,6 +227,106 @@ func (target *Target) Load(basePkg *pkg.LocalPackage) error {
 	return nil
 }
 
+// readExtraApps parses the `target.extra_apps` map.  The apps are sorted by
+// name so that they are always built in the same order.
+func readExtraApps(yc ycfg.YCfg) ([]ExtraApp, error) {
+	m, err := yc.GetValStringMap("target.extra_apps", nil)
+	util.OneTimeWarningError(err)
+
+	proj := interfaces.GetProject()
+
+	var eas []ExtraApp
+	for name, v := range m {
+		fields := cast.ToStringMap(v)
+
+		ea := ExtraApp{
+			Name:      name,
+			AppName:   cast.ToString(fields["app"]),
+			FlashArea: cast.ToString(fields["flash_area"]),
+		}
+
+		// The linker script setting is either a single script or a list.
+		scripts := cast.ToStringSlice(fields["linkerscript"])
+		if len(scripts) == 0 {
+			if s := cast.ToString(fields["linkerscript"]); s != "" {
+				scripts = []string{s}
+			}
+		}
+		for _, s := range scripts {
+			path, err := proj.ResolvePath(proj.Path(), s)
+			if err != nil {
+				return nil, util.PreNewtError(err,
+					"target.extra_apps: app \"%s\" specifies invalid "+
+						"linker script", name)
+			}
+			ea.LinkerScripts = append(ea.LinkerScripts, path)
+		}
+
+		eas = append(eas, ea)
+	}
+
+	sort.Slice(eas, func(i int, j int) bool {
+		return eas[i].Name < eas[j].Name
+	})
+
+	return eas, nil
+}
+
+func (target *Target) validateExtraApps() error {
+	if len(target.ExtraApps) > 0 && target.LoaderName != "" {
+		return util.NewNewtError(
+			"target.extra_apps cannot be used with a split image " +
+				"(target.loader)")
+	}
+
+	for _, ea := range target.ExtraApps {
+		if ea.Name != util.CIdentifier(ea.Name) ||
+			util.SliceContains(reservedExtraAppNames, ea.Name) {
+
+			return util.FmtNewtError(
+				"target.extra_apps: invalid app name \"%s\"; must be a C "+
+					"identifier other than %s", ea.Name,
+				strings.Join(reservedExtraAppNames, ", "))
+		}
+
+		if ea.AppName == "" {
+			return util.FmtNewtError(
+				"target.extra_apps: app \"%s\" does not specify a package "+
+					"(app)", ea.Name)
+		}
+		app := target.ResolvePackageName(ea.AppName)
+		if app == nil {
+			return util.FmtNewtError(
+				"target.extra_apps: could not resolve app package: %s",
+				ea.AppName)
+		}
+		if app.Type() != pkg.PACKAGE_TYPE_APP {
+			return util.FmtNewtError(
+				"target.extra_apps: package (%s) is not of type app; "+
+					"type is: %s", app.Name(),
+				pkg.PackageTypeNames[app.Type()])
+		}
+		if main := target.App(); main != nil && app == main {
+			return util.FmtNewtError(
+				"target.extra_apps: app \"%s\" is the target's main app",
+				ea.Name)
+		}
+
+		if ea.FlashArea == "" {
+			return util.FmtNewtError(
+				"target.extra_apps: app \"%s\" does not specify a flash "+
+					"area (flash_area)", ea.Name)
+		}
+		if len(ea.LinkerScripts) == 0 {
+			return util.FmtNewtError(
+				"target.extra_apps: app \"%s\" does not specify a linker "+
+					"script (linkerscript)", ea.Name)
+		}
+	}
+
+	return nil
+}
+
 func (target *Target) Validate(appRequired bool) error {
 	if target.BspName == "" {
 		return util.NewNewtError("Target does not specify a BSP package " +
@@ -191,6 +375,64 @@ func (target *Target) Validate(appRequired bool) error {
 					pkg.PackageTypeNames[loader.Type()])
 			}
 		}
+
+		if target.RomElf != "" && target.LoaderName != "" {
+			return util.NewNewtError(
+				"target.rom_elf cannot be used with a split image " +
+					"(target.loader)")
+		}
+
+		if len(target.RenameSymbols) > 0 && target.LoaderName == "" {
+			return util.NewNewtError(
+				"target.rename_symbols requires a split image " +
+					"(target.loader)")
+		}
+
+		if err := target.validateExtraApps(); err != nil {
+			return err
+		}
+	}
+
+	if err := target.validateLinkPins(); err != nil {
+		return err
+	}
+
+	if target.ProfilerName != "" {
+		if target.ResolvePackageName(target.ProfilerName) == nil {
+			return util.FmtNewtError(
+				"Could not resolve profiler package: %s",
+				target.ProfilerName)
+		}
+	}
+
+	return nil
+}
+
+// Ensures that every package in target.link_first and target.link_last
+// exists and that no package is pinned to both ends of the link order.
+func (target *Target) validateLinkPins() error {
+	pinned := map[string]string{}
+	for _, pin := range []struct {
+		setting string
+		names   []string
+	}{
+		{"target.link_first", target.LinkFirst},
+		{"target.link_last", target.LinkLast},
+	} {
+		for _, name := range pin.names {
+			lpkg := target.ResolvePackageName(name)
+			if lpkg == nil {
+				return util.FmtNewtError(
+					"Could not resolve %s package: %s", pin.setting, name)
+			}
+
+			if prev, ok := pinned[lpkg.FullName()]; ok {
+				return util.FmtNewtError(
+					"package %s appears in both %s and %s",
+					lpkg.FullName(), prev, pin.setting)
+			}
+			pinned[lpkg.FullName()] = pin.setting
+		}
 	}
 
 	return nil
@@ -270,6 +512,14 @@ func (target *Target) Bsp() *pkg.LocalPackage {
 	return target.ResolvePackageName(target.BspName)
 }
 
+func (target *Target) Profiler() *pkg.LocalPackage {
+	return target.ResolvePackageName(target.ProfilerName)
+}
+
+func (target *Target) ExtraAppPkg(ea ExtraApp) *pkg.LocalPackage {
+	return target.ResolvePackageName(ea.AppName)
+}
+
 // Methods below resolve package by name as stated in YML file (so do not follow links)
 // e.g. to use as seed for dependencies calculation
 
@@ -285,8 +535,18 @@ func (target *Target) BspYml() *pkg.LocalPackage {
 	return target.ResolvePackageYmlName(target.BspName)
 }
 
+func (target *Target) ExtraAppYml(ea ExtraApp) *pkg.LocalPackage {
+	return target.ResolvePackageYmlName(ea.AppName)
+}
+
 // Save the target's configuration elements
 func (t *Target) Save() error {
+	// Remember the previous state so that the change can be undone.
+	if err := t.recordHistory(); err != nil {
+		util.OneTimeWarning("failed to record history of target %s: %s",
+			t.FullName(), strings.TrimSpace(err.Error()))
+	}
+
 	if err := t.basePkg.Save(); err != nil {
 		return err
 	}
@@ -0,0 +1,239 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package target
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/util"
+)
+
+// Directory, relative to the project base, where deleted targets are kept
+// until they are restored or the trash is emptied.
+const TRASH_DIR = ".newt/trash"
+
+const trashNameFilename = "name"
+const trashTargetDir = "target"
+const trashArtifactsDir = "artifacts"
+
+// Holds the path that the target's build artifacts were moved from.
+const trashArtifactsFilename = "artifacts_path"
+
+// Describes one deleted target in the trash.
+type TrashEntry struct {
+	Seq  int
+	Name string
+	Path string
+	Time time.Time
+}
+
+func trashDir() string {
+	return project.GetProject().Path() + "/" + TRASH_DIR
+}
+
+// Retrieves the deleted targets in the trash, oldest first.
+func TrashEntries() ([]TrashEntry, error) {
+	dir := trashDir()
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, util.ChildNewtError(err)
+	}
+
+	var entries []TrashEntry
+	for _, info := range infos {
+		seq, err := strconv.Atoi(info.Name())
+		if err != nil || !info.IsDir() {
+			continue
+		}
+
+		entry := TrashEntry{
+			Seq:  seq,
+			Path: dir + "/" + info.Name(),
+			Time: info.ModTime(),
+		}
+
+		name, err := ioutil.ReadFile(entry.Path + "/" + trashNameFilename)
+		if err != nil {
+			continue
+		}
+		entry.Name = strings.TrimSpace(string(name))
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i int, j int) bool {
+		return entries[i].Seq < entries[j].Seq
+	})
+
+	return entries, nil
+}
+
+// Moves the target's directory into the project's trash, from which it can
+// be restored with RestoreTarget().  If artifactsDir is not empty, the
+// target's build artifacts in that directory are moved into the trash as
+// well, and are restored along with the target.
+func (t *Target) MoveToTrash(artifactsDir string) (TrashEntry, error) {
+	entries, err := TrashEntries()
+	if err != nil {
+		return TrashEntry{}, err
+	}
+
+	entry := TrashEntry{
+		Seq:  1,
+		Name: t.FullName(),
+		Time: time.Now(),
+	}
+	if len(entries) > 0 {
+		entry.Seq = entries[len(entries)-1].Seq + 1
+	}
+	entry.Path = fmt.Sprintf("%s/%06d", trashDir(), entry.Seq)
+
+	if err := os.MkdirAll(entry.Path, 0755); err != nil {
+		return entry, util.ChildNewtError(err)
+	}
+
+	if err := ioutil.WriteFile(entry.Path+"/"+trashNameFilename,
+		[]byte(entry.Name+"\n"), 0644); err != nil {
+
+		return entry, util.ChildNewtError(err)
+	}
+
+	if artifactsDir != "" && util.NodeExist(artifactsDir) {
+		if err := ioutil.WriteFile(entry.Path+"/"+trashArtifactsFilename,
+			[]byte(artifactsDir+"\n"), 0644); err != nil {
+
+			os.RemoveAll(entry.Path)
+			return entry, util.ChildNewtError(err)
+		}
+
+		err := util.MoveDir(artifactsDir, entry.Path+"/"+trashArtifactsDir)
+		if err != nil {
+			os.RemoveAll(entry.Path)
+			return entry, err
+		}
+	}
+
+	err = util.MoveDir(t.basePkg.BasePath(), entry.Path+"/"+trashTargetDir)
+	if err != nil {
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// Moves the build artifacts of a trash entry back to where they were, unless
+// the target has been built again since it was deleted.
+func restoreArtifacts(entry TrashEntry) error {
+	src := entry.Path + "/" + trashArtifactsDir
+	if util.NodeNotExist(src) {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(entry.Path + "/" + trashArtifactsFilename)
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	dst := strings.TrimSpace(string(data))
+	if util.NodeExist(dst) {
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"* Warning: not restoring build artifacts of %s; %s already "+
+				"exists\n", entry.Name, dst)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return util.MoveDir(src, dst)
+}
+
+// Moves the most recently deleted target with the specified full name (e.g.,
+// "targets/blinky") out of the trash and back into the local repo.  It
+// returns the restored entry.
+func RestoreTarget(name string) (TrashEntry, error) {
+	entries, err := TrashEntries()
+	if err != nil {
+		return TrashEntry{}, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Name != name {
+			continue
+		}
+
+		dst := project.GetProject().LocalRepo().Path() + "/" + name
+		if util.NodeExist(dst) {
+			return entry, util.FmtNewtError(
+				"cannot restore target %s; %s already exists", name, dst)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return entry, util.ChildNewtError(err)
+		}
+
+		err := util.MoveDir(entry.Path+"/"+trashTargetDir, dst)
+		if err != nil {
+			return entry, err
+		}
+
+		if err := restoreArtifacts(entry); err != nil {
+			return entry, err
+		}
+
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return entry, util.ChildNewtError(err)
+		}
+
+		return entry, nil
+	}
+
+	return TrashEntry{}, util.FmtNewtError(
+		"target %s is not in the trash", name)
+}
+
+// Permanently deletes every target in the trash.  It returns the number of
+// targets that were deleted.
+func EmptyTrash() (int, error) {
+	entries, err := TrashEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.RemoveAll(trashDir()); err != nil {
+		return 0, util.ChildNewtError(err)
+	}
+
+	return len(entries), nil
+}
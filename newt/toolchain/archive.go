@@ -0,0 +1,141 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+const arThinMagic = "!<thin>\n"
+
+// Size of an archive member header, in bytes.
+const arHdrSize = 60
+
+// IsThinArchive indicates whether the specified file is a thin archive.  A
+// thin archive contains a symbol table and the paths of its members, but not
+// the members themselves.
+func IsThinArchive(filename string) (bool, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, util.ChildNewtError(err)
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(arThinMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, util.ChildNewtError(err)
+	}
+
+	return string(magic) == arThinMagic, nil
+}
+
+// ThinArchiveMembers returns the paths of the object files that a thin
+// archive references.  The archive stores the paths relative to its own
+// directory; the returned paths are relative to the working directory (or
+// absolute, if the archive stores absolute paths).
+func ThinArchiveMembers(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, len(arThinMagic))
+	if _, err := io.ReadFull(r, magic); err != nil ||
+		string(magic) != arThinMagic {
+
+		return nil, util.FmtNewtError("%s is not a thin archive", filename)
+	}
+
+	badArchive := func() error {
+		return util.FmtNewtError("malformed thin archive: %s", filename)
+	}
+
+	var nameTable []byte
+	var members []string
+
+	hdr := make([]byte, arHdrSize)
+	for {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, badArchive()
+		}
+
+		name := strings.TrimRight(string(hdr[0:16]), " ")
+		size, err := strconv.ParseInt(
+			strings.TrimRight(string(hdr[48:58]), " "), 10, 64)
+		if err != nil || size < 0 {
+			return nil, badArchive()
+		}
+
+		switch {
+		case name == "/" || name == "/SYM64/" || name == "//":
+			// The symbol table and the long name table are the only members
+			// whose contents are stored in the archive.  Member data is
+			// padded to an even length.
+			data := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, badArchive()
+			}
+			if name == "//" {
+				nameTable = data[:size]
+			}
+
+		case strings.HasPrefix(name, "/"):
+			// Name is an offset into the long name table.
+			off, err := strconv.Atoi(name[1:])
+			if err != nil || off >= len(nameTable) {
+				return nil, badArchive()
+			}
+			end := bytes.Index(nameTable[off:], []byte("/\n"))
+			if end < 0 {
+				return nil, badArchive()
+			}
+			members = append(members, string(nameTable[off:off+end]))
+
+		default:
+			members = append(members, strings.TrimSuffix(name, "/"))
+		}
+	}
+
+	dir := filepath.Dir(filename)
+	for i, m := range members {
+		if !filepath.IsAbs(m) {
+			members[i] = filepath.ToSlash(filepath.Join(dir, m))
+		}
+	}
+
+	return members, nil
+}
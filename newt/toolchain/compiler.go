@@ -20,6 +20,7 @@
 package toolchain
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io/ioutil"
 	"mynewt.apache.org/newt/newt/cfgv"
@@ -35,8 +36,6 @@ import (
 	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
-
 	"mynewt.apache.org/newt/newt/config"
 	"mynewt.apache.org/newt/newt/project"
 	"mynewt.apache.org/newt/newt/symbol"
@@ -44,6 +43,8 @@ import (
 	"mynewt.apache.org/newt/util"
 )
 
+var log = util.SubsysLogger(util.LOG_SUBSYS_COMPILER)
+
 const COMPILER_FILENAME string = "compiler.yml"
 
 const (
@@ -59,15 +60,18 @@ type CompilerInfo struct {
 	CXXflags    []string
 	Lflags      []string
 	Aflags      []string
-	IgnoreFiles []*regexp.Regexp
-	IgnoreDirs  []*regexp.Regexp
+	IgnoreFiles []*IgnoreRule
+	IgnoreDirs  []*IgnoreRule
 	WholeArch   bool
 }
 
+// An entry in a clang compilation database (compile_commands.json).
 type CompileCommand struct {
-	Directory string `json:"directory"`
-	Command   string `json:"command"`
-	File      string `json:"file"`
+	Directory string   `json:"directory"`
+	Command   string   `json:"command"`
+	Arguments []string `json:"arguments"`
+	File      string   `json:"file"`
+	Output    string   `json:"output"`
 }
 
 type Compiler struct {
@@ -75,6 +79,10 @@ type Compiler struct {
 	LinkerScripts                 []string
 	AutogeneratedLinkerIncludeDir string
 
+	// Whether to record the sections discarded by the linker in a
+	// `<elf>.gc` file.
+	GcReport bool
+
 	// Needs to be locked whenever a mutable field in this struct is accessed
 	// during a build.  Currently, objPathList is the only such member.
 	mutex *sync.Mutex
@@ -90,8 +98,10 @@ type Compiler struct {
 	ldResolveCircularDeps bool
 	ldMapFile             bool
 	ldBinFile             bool
+	arThin                bool
 	baseDir               string
 	srcDir                string
+	pkgDir                string
 	dstDir                string
 	settings              *cfgv.Settings
 
@@ -107,13 +117,51 @@ type Compiler struct {
 	// common info set.  Ensures the local info only gets added once.
 	lclInfoAdded bool
 
-	compileCommands []CompileCommand
+	// How long each source file took to compile, indexed by filename.  Only
+	// files that were actually compiled or restored from the object cache are
+	// present.
+	compileTimes map[string]time.Duration
+
+	// Whether each source file was found in the object cache, indexed by
+	// filename.  Only files that were looked up in the cache are present.
+	cacheResults map[string]bool
 
 	extraDeps []string
+
+	// Describes the per-macro files of syscfg.h; nil if syscfg dependencies
+	// are not tracked precisely.
+	syscfgSymbols *SyscfgSymbols
+
+	// Version constraints that the compiler must satisfy.
+	versionReqs []string
+}
+
+// CompileTimes returns the duration of each compilation performed by the
+// compiler, indexed by source filename.
+func (c *Compiler) CompileTimes() map[string]time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	m := make(map[string]time.Duration, len(c.compileTimes))
+	for k, v := range c.compileTimes {
+		m[k] = v
+	}
+
+	return m
 }
 
-func (c *Compiler) GetCompileCommands() []CompileCommand {
-	return c.compileCommands
+// CacheResults indicates, for each source file that the compiler looked up in
+// the object cache, whether its object was restored from the cache.
+func (c *Compiler) CacheResults() map[string]bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	m := make(map[string]bool, len(c.cacheResults))
+	for k, v := range c.cacheResults {
+		m[k] = v
+	}
+
+	return m
 }
 
 func (c *Compiler) GetCcPath() string {
@@ -169,8 +217,8 @@ func NewCompilerInfo() *CompilerInfo {
 	ci.CXXflags = []string{}
 	ci.Lflags = []string{}
 	ci.Aflags = []string{}
-	ci.IgnoreFiles = []*regexp.Regexp{}
-	ci.IgnoreDirs = []*regexp.Regexp{}
+	ci.IgnoreFiles = []*IgnoreRule{}
+	ci.IgnoreDirs = []*IgnoreRule{}
 	ci.WholeArch = false
 
 	return ci
@@ -264,7 +312,8 @@ func NewCompiler(compilerDir string, dstDir string,
 		srcDir:          "",
 		dstDir:          dstDir,
 		extraDeps:       []string{},
-		compileCommands: []CompileCommand{},
+		compileTimes:    map[string]time.Duration{},
+		cacheResults:    map[string]bool{},
 	}
 
 	c.depTracker = NewDepTracker(c)
@@ -356,6 +405,9 @@ func (c *Compiler) load(compilerDir string, buildProfile string, cfg *cfgv.Setti
 		return err
 	}
 
+	// Everything gets rebuilt when the compiler definition changes.
+	c.AddDeps(compilerDir + "/" + COMPILER_FILENAME)
+
 	if cfg == nil {
 		cfgMap, err := getConfigMap(compilerDir)
 		if err == nil {
@@ -366,6 +418,7 @@ func (c *Compiler) load(compilerDir string, buildProfile string, cfg *cfgv.Setti
 	settings := cfgv.NewSettingsFromMap(map[string]string{
 		buildProfile:                  "1",
 		strings.ToUpper(runtime.GOOS): "1",
+		HostSetting():                 "1",
 	})
 
 	c.ccPath, err = yc.GetValString("compiler.path.cc", settings)
@@ -380,6 +433,9 @@ func (c *Compiler) load(compilerDir string, buildProfile string, cfg *cfgv.Setti
 	c.arPath, err = yc.GetValString("compiler.path.archive", settings)
 	util.OneTimeWarningError(err)
 
+	c.arThin, err = yc.GetValBoolDflt("compiler.ar.thin", settings, false)
+	util.OneTimeWarningError(err)
+
 	c.odPath, err = yc.GetValString("compiler.path.objdump", settings)
 	util.OneTimeWarningError(err)
 
@@ -389,6 +445,43 @@ func (c *Compiler) load(compilerDir string, buildProfile string, cfg *cfgv.Setti
 	c.ocPath, err = yc.GetValString("compiler.path.objcopy", settings)
 	util.OneTimeWarningError(err)
 
+	ccVerReq, err := yc.GetValString("compiler.version", settings)
+	util.OneTimeWarningError(err)
+	for _, req := range []string{
+		ccVerReq, project.GetProject().CompilerVersionReq()} {
+
+		if req != "" {
+			c.versionReqs = append(c.versionReqs, req)
+		}
+	}
+
+	ta := ToolchainArchive{}
+	ta.Url, err = yc.GetValString("compiler.toolchain_url", settings)
+	util.OneTimeWarningError(err)
+
+	// A build container supplies its own toolchain.
+	if ta.Url != "" && !InContainer() {
+		ta.Sha256, err = yc.GetValString("compiler.toolchain_sha256", settings)
+		util.OneTimeWarningError(err)
+		if ta.Sha256 == "" {
+			return util.FmtNewtError(
+				"compiler %s specifies compiler.toolchain_url without "+
+					"compiler.toolchain_sha256", compilerDir)
+		}
+
+		ta.Version, err = yc.GetValString("compiler.toolchain_version",
+			settings)
+		util.OneTimeWarningError(err)
+
+		if err := c.useToolchainArchive(&ta); err != nil {
+			return err
+		}
+	}
+
+	if err := c.checkVersion(); err != nil {
+		return err
+	}
+
 	c.lclInfo.Cflags = loadFlags(yc, settings, "compiler.flags", cfg)
 	c.lclInfo.CXXflags = loadFlags(yc, settings, "compiler.cxx.flags", cfg)
 	c.lclInfo.Lflags = loadFlags(yc, settings, "compiler.ld.flags", cfg)
@@ -430,6 +523,18 @@ func (c *Compiler) AddDeps(depFilenames ...string) {
 	c.extraDeps = append(c.extraDeps, depFilenames...)
 }
 
+// Indicates whether a file was added as a dependency of every compiled file
+// with AddDeps, rather than discovered by the compiler.
+func (c *Compiler) isExtraDep(filename string) bool {
+	for _, dep := range c.extraDeps {
+		if filepath.Clean(dep) == filepath.Clean(filename) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Skips compilation of the specified C or assembly file, but adds the name of
 // the object file that would have been generated to the compiler's list of
 // object files.  This function is used when the object file is already up to
@@ -513,6 +618,18 @@ func (c *Compiler) dstFilePath(srcPath string) string {
 	return dstPath
 }
 
+// ObjPath calculates the path of the object file that the specified source
+// file compiles to.
+func (c *Compiler) ObjPath(srcPath string) string {
+	return c.dstFilePath(srcPath) + ".o"
+}
+
+// DepsPath calculates the path of the dependency (.d) file that the compiler
+// generates for the specified source file.
+func (c *Compiler) DepsPath(srcPath string) string {
+	return c.dstFilePath(srcPath) + ".d"
+}
+
 // Calculates the command-line invocation necessary to compile the specified C
 // or assembly file.
 //
@@ -559,6 +676,30 @@ func (c *Compiler) CompileFileCmd(file string, compilerType int) (
 	return cmd, nil
 }
 
+// Produces the compilation database entry for the specified source file.
+// Returns nil if the package dictates that the file is ignored.
+func (c *Compiler) CompileCommand(file string, compilerType int) (
+	*CompileCommand, error) {
+
+	file = filepath.ToSlash(file)
+	if c.ShouldIgnoreFile(file) {
+		return nil, nil
+	}
+
+	cmd, err := c.CompileFileCmd(file, compilerType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompileCommand{
+		Directory: c.baseDir,
+		Command:   strings.Join(cmd, " "),
+		Arguments: cmd,
+		File:      file,
+		Output:    filepath.ToSlash(c.ObjPath(file)),
+	}, nil
+}
+
 // Generates a dependency Makefile (.d) for the specified source file.
 //
 // @param file                  The name of the source file.
@@ -595,7 +736,7 @@ func (c *Compiler) GenDepsForFile(file string, compilerType int) error {
 	cmd = append(cmd, c.includesStrings()...)
 	cmd = append(cmd, []string{"-MM", "-MG", srcPath}...)
 
-	o, err := util.ShellCommandLimitDbgOutput(cmd, nil, true, 0)
+	o, err := util.ShellCommandLimitDbgOutput(containerCmd(cmd), nil, true, 0)
 	if err != nil {
 		return err
 	}
@@ -611,8 +752,11 @@ func (c *Compiler) GenDepsForFile(file string, compilerType int) error {
 		return util.ChildNewtError(err)
 	}
 
-	// Append the extra dependencies (.yml files) to the .d file.
-	objFile := strings.TrimSuffix(file, filepath.Ext(file)) + ".o"
+	// Append the extra dependencies (.yml files) to the .d file.  The object
+	// name must match the one the compiler emitted (the source file's base
+	// name); otherwise, the dependencies are ignored when the file is parsed.
+	objFile := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) +
+		".o"
 	if _, err := f.WriteString(objFile + ": " + c.depsString()); err != nil {
 		return util.NewNewtError(err.Error())
 	}
@@ -620,12 +764,166 @@ func (c *Compiler) GenDepsForFile(file string, compilerType int) error {
 	return nil
 }
 
+// [compiler-path] => version string
+var toolchainIds = map[string]string{}
+var toolchainIdsMtx sync.Mutex
+
+// Retrieves a string identifying the compiler binary (the first line of
+// `<cc> --version`).  The result is cached; the compiler is only invoked once
+// per run.  "" is returned if the compiler does not report a version.
+func (c *Compiler) toolchainId() string {
+	toolchainIdsMtx.Lock()
+	defer toolchainIdsMtx.Unlock()
+
+	if id, ok := toolchainIds[c.ccPath]; ok {
+		return id
+	}
+
+	id := ""
+	if c.ccPath != "" {
+		o, err := util.ShellCommandLimitDbgOutput(
+			containerCmd([]string{c.ccPath, "--version"}), nil, false, 0)
+		if err != nil {
+			log.Debugf("failed to determine compiler version: %s",
+				err.Error())
+		} else {
+			id = strings.TrimSpace(strings.SplitN(string(o), "\n", 2)[0])
+		}
+	}
+
+	toolchainIds[c.ccPath] = id
+	return id
+}
+
+// [archiver-path] => archiver's --help output
+var arHelpTexts = map[string]string{}
+var arHelpTextsMtx sync.Mutex
+
+// Returns the archiver's --help output, which newt uses to detect the
+// archiver's capabilities.  The result is cached; the archiver is only invoked
+// once per run.
+func (c *Compiler) arHelp() string {
+	arHelpTextsMtx.Lock()
+	defer arHelpTextsMtx.Unlock()
+
+	if h, ok := arHelpTexts[c.arPath]; ok {
+		return h
+	}
+
+	h := ""
+	if c.arPath != "" {
+		// Some archivers report a usage error for --help; the output is
+		// still meaningful.
+		o, _ := util.ShellCommandLimitDbgOutput(
+			containerCmd([]string{c.arPath, "--help"}), nil, false, 0)
+		h = string(o)
+	}
+
+	arHelpTexts[c.arPath] = h
+	return h
+}
+
+// Indicates whether the archiver supports the D (deterministic) modifier,
+// which zeroes the timestamps, uids, and gids of archive members so that an
+// archive's contents depend only on its object files.  GNU ar and llvm-ar list
+// the modifier in their --help output.
+func (c *Compiler) arSupportsDeterministic() bool {
+	d := strings.Contains(c.arHelp(), "[D]")
+	if !d {
+		log.Debugf("archiver %s does not support the D modifier; using "+
+			"ZERO_AR_DATE", c.arPath)
+	}
+
+	return d
+}
+
+// Returns the archiver operation and modifiers used to create archives.  Thin
+// archives are created with the archiver's --thin option; older archivers
+// only provide the T modifier.
+func (c *Compiler) arFlags(thin bool) []string {
+	mods := "rcs"
+	if c.arSupportsDeterministic() {
+		mods += "D"
+	}
+
+	if !thin {
+		return []string{mods}
+	}
+	if strings.Contains(c.arHelp(), "--thin") {
+		return []string{"--thin", mods}
+	}
+	return []string{mods + "T"}
+}
+
+// Returns the environment that the archiver is run with.  Archivers that lack
+// the D modifier (e.g., the macOS archiver) zero member timestamps when
+// ZERO_AR_DATE is set.
+func (c *Compiler) arEnv() map[string]string {
+	if c.arSupportsDeterministic() {
+		return nil
+	}
+
+	return map[string]string{"ZERO_AR_DATE": "1"}
+}
+
+// Produces the record of a build command that gets written to a .cmd file.
+// In addition to the command itself, the record identifies the toolchain, so
+// that switching compilers causes a rebuild even if the command line is
+// unchanged.
+func (c *Compiler) commandRecord(cmd []string) []string {
+	rec := append([]string{}, cmd...)
+	if id := c.toolchainId(); id != "" {
+		rec = append(rec, "# toolchain: "+id)
+	}
+
+	return rec
+}
+
+// Produces the record of a link command.  This is the same as a regular
+// command record, but it also contains a hash of each linker script, as well
+// as each file in the autogenerated linker include directory.  A change to
+// any of these files causes the image to be relinked.
+func (c *Compiler) linkCommandRecord(cmd []string) []string {
+	rec := c.commandRecord(cmd)
+
+	scripts := append([]string{}, c.LinkerScripts...)
+	if c.AutogeneratedLinkerIncludeDir != "" {
+		incs, _ := filepath.Glob(c.AutogeneratedLinkerIncludeDir + "/*")
+		sort.Strings(incs)
+		scripts = append(scripts, incs...)
+	}
+
+	for _, path := range scripts {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			// A missing script will be reported by the linker.
+			continue
+		}
+		rec = append(rec,
+			fmt.Sprintf("# %s: %x", path, sha256.Sum256(contents)))
+	}
+
+	return rec
+}
+
 func serializeCommand(cmd []string) []byte {
 	// Use a newline as the separator rather than a space to disambiguate cases
 	// where arguments contain spaces.
 	return []byte(strings.Join(cmd, "\n"))
 }
 
+// Removes the record of the command that generated a file.  This is done
+// before the file is regenerated, so that the file is considered out of date
+// if the build does not complete.
+func invalidateCommandFile(dstFile string) error {
+	err := os.Remove(dstFile + ".cmd")
+	if err != nil && !os.IsNotExist(err) {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
+
 // Writes a file containing the command-line invocation used to generate the
 // specified file.  The file that this function writes can be used later to
 // determine if the set of compiler options has changed.
@@ -675,31 +973,74 @@ func (c *Compiler) CompileFile(file string, compilerType int) error {
 		return err
 	}
 
+	// Look for an identical compilation in the object cache.  Failing to
+	// calculate the key is not fatal; the compiler will report any problem
+	// with the source file.
+	start := time.Now()
+	cacheKey := ""
+	if ObjCacheEnabled() {
+		cacheKey, err = c.objCacheKey(file, compilerType, cmd, objPath)
+		if err != nil {
+			log.Debugf("not caching %s: %s", file, err.Error())
+			cacheKey = ""
+		}
+	}
+
+	if err := invalidateCommandFile(objPath); err != nil {
+		return err
+	}
+
+	var o []byte
+	cached := false
+	if cacheKey != "" {
+		o, cached = objCacheRestore(cacheKey, objPath)
+
+		c.mutex.Lock()
+		c.cacheResults[file] = cached
+		c.mutex.Unlock()
+	}
+
 	srcPath := strings.TrimPrefix(file, c.baseDir+"/")
+	suffix := ""
+	if cached {
+		suffix = " (cached)"
+	}
 	switch compilerType {
-	case COMPILER_TYPE_C:
-		util.StatusMessage(util.VERBOSITY_DEFAULT, "Compiling %s\n", srcPath)
-	case COMPILER_TYPE_CPP:
-		util.StatusMessage(util.VERBOSITY_DEFAULT, "Compiling %s\n", srcPath)
+	case COMPILER_TYPE_C, COMPILER_TYPE_CPP:
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "Compiling %s%s\n",
+			srcPath, suffix)
 	case COMPILER_TYPE_ASM:
-		util.StatusMessage(util.VERBOSITY_DEFAULT, "Assembling %s\n", srcPath)
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "Assembling %s%s\n",
+			srcPath, suffix)
 	default:
 		return util.NewNewtError("Unknown compiler type")
 	}
 
-	o, err := util.ShellCommand(cmd, nil)
-	if err != nil {
-		return err
+	if !cached {
+		untrack := util.TrackPartialOutput(objPath)
+		o, err = util.ShellCommand(containerCmd(cmd), nil)
+		untrack()
+		if err != nil {
+			return err
+		}
+
+		if cacheKey != "" {
+			objCacheStore(cacheKey, objPath, o)
+		}
 	}
+
+	c.mutex.Lock()
+	c.compileTimes[file] = time.Since(start)
+	c.mutex.Unlock()
+
 	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s", string(o))
 
-	c.compileCommands = append(c.compileCommands,
-		CompileCommand{
-			Command: strings.Join(cmd, " "),
-			File:    file,
-		})
+	err = writeCommandFile(objPath, c.commandRecord(cmd))
+	if err != nil {
+		return err
+	}
 
-	err = writeCommandFile(objPath, cmd)
+	err = writeWarningsFile(objPath, parseWarnings(string(o), c.baseDir))
 	if err != nil {
 		return err
 	}
@@ -711,15 +1052,7 @@ func (c *Compiler) CompileFile(file string, compilerType int) error {
 }
 
 func (c *Compiler) ShouldIgnoreFile(file string) bool {
-	file = strings.TrimPrefix(file, c.srcDir)
-	file = strings.TrimLeft(file, "/\\")
-	for _, re := range c.info.IgnoreFiles {
-		if match := re.MatchString(file); match {
-			return true
-		}
-	}
-
-	return false
+	return c.IgnoreFileRule(file) != nil
 }
 
 func compilerTypeToExts(compilerType int) ([]string, error) {
@@ -753,6 +1086,20 @@ func fileNameToCompilerType(filename string) (int, error) {
 	}
 }
 
+// RebuildReason explains why the specified source file would be recompiled by
+// the next build.  It returns nil if the file is up to date or is ignored.
+func (c *Compiler) RebuildReason(filename string,
+	compilerType int) (*RebuildReason, error) {
+
+	filename = filepath.ToSlash(filename)
+
+	if c.ShouldIgnoreFile(filename) {
+		return nil, nil
+	}
+
+	return c.depTracker.RebuildReason(filename, compilerType)
+}
+
 // Compiles all C files matching the specified file glob.
 func (c *Compiler) CompileC(filename string) error {
 	filename = filepath.ToSlash(filename)
@@ -853,12 +1200,22 @@ func (c *Compiler) CopyArchive(filename string) error {
 	if err != nil {
 		return err
 	}
-	if copyRequired {
-		err = util.CopyFile(filename, tgtFile)
-		util.StatusMessage(util.VERBOSITY_DEFAULT, "Copying %s\n",
-			filepath.ToSlash(tgtFile))
+	if !copyRequired {
+		return nil
 	}
 
+	thin, err := IsThinArchive(filename)
+	if err != nil {
+		return err
+	}
+	if thin {
+		return c.copyThinArchive(filename, tgtFile)
+	}
+
+	err = util.CopyFile(filename, tgtFile)
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Copying %s\n",
+		filepath.ToSlash(tgtFile))
+
 	if err != nil {
 		return err
 	}
@@ -866,6 +1223,48 @@ func (c *Compiler) CopyArchive(filename string) error {
 	return nil
 }
 
+// Copies a thin archive.  A thin archive references its members by paths
+// relative to its own directory, so a byte-for-byte copy in a different
+// directory would be broken.  Instead, a new thin archive that references the
+// same object files is created at the destination.
+func (c *Compiler) copyThinArchive(srcFile string, dstFile string) error {
+	members, err := ThinArchiveMembers(srcFile)
+	if err != nil {
+		return err
+	}
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT, "Copying %s (thin archive)\n",
+		filepath.ToSlash(dstFile))
+
+	if err := os.Remove(dstFile); err != nil && !os.IsNotExist(err) {
+		return util.ChildNewtError(err)
+	}
+
+	untrack := util.TrackPartialOutput(dstFile)
+	defer untrack()
+
+	for len(members) > 0 {
+		// The copy is always thin, even if the compiler package does not
+		// enable thin archives.
+		cmd := []string{c.arPath}
+		cmd = append(cmd, c.arFlags(true)...)
+		cmd = append(cmd, dstFile)
+
+		for len(members) > 0 && len(strings.Join(cmd, " ")) < 30000 {
+			cmd = append(cmd, members[0])
+			members = members[1:]
+		}
+
+		o, err := util.ShellCommand(containerCmd(cmd), c.arEnv())
+		if err != nil {
+			return err
+		}
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s", string(o))
+	}
+
+	return nil
+}
+
 func (c *Compiler) processEntry(node os.FileInfo, cType int,
 	ignDirs []string) ([]CompilerJob, error) {
 
@@ -877,10 +1276,8 @@ func (c *Compiler) processEntry(node os.FileInfo, cType int,
 	}
 
 	// Check in the user specified ignore directories
-	for _, dir := range c.info.IgnoreDirs {
-		if dir.MatchString(node.Name()) {
-			return nil, nil
-		}
+	if c.IgnoreDirRule(c.srcDir+"/"+node.Name()) != nil {
+		return nil, nil
 	}
 
 	// If not, recurse into the directory.  Make the output directory
@@ -995,11 +1392,10 @@ func (c *Compiler) getObjFiles(baseObjFiles []string) []string {
 }
 
 func (c *Compiler) getStaticLibs(baseStaticLib []util.StaticLib) []util.StaticLib {
-	c.mutex.Lock()
-	for objName, _ := range c.objPathList {
+	// Link object files in a fixed order so that the output is reproducible.
+	for _, objName := range c.getObjFiles(nil) {
 		baseStaticLib = append(baseStaticLib, util.NewStaticLib(objName, false))
 	}
-	c.mutex.Unlock()
 	return baseStaticLib
 }
 
@@ -1066,6 +1462,13 @@ func (c *Compiler) CompileBinaryCmd(dstFile string, options map[string]bool,
 
 	if options["mapFile"] {
 		cmd = append(cmd, "-Wl,-Map="+dstFile+".map")
+		// Include a cross reference table in the map file; used by
+		// `newt size --whynot`.
+		cmd = append(cmd, "-Wl,--cref")
+	}
+
+	if options["gcReport"] {
+		cmd = append(cmd, "-Wl,--gc-sections", "-Wl,--print-gc-sections")
 	}
 
 	return cmd
@@ -1098,13 +1501,28 @@ func (c *Compiler) CompileBinary(dstFile string, options map[string]bool,
 	}
 
 	cmd := c.CompileBinaryCmd(dstFile, options, libList, keepSymbols, elfLib)
-	o, err := util.ShellCommand(cmd, nil)
+	if err := invalidateCommandFile(dstFile); err != nil {
+		return err
+	}
+
+	untrack := util.TrackPartialOutput(dstFile)
+	o, err := util.ShellCommand(containerCmd(cmd), nil)
+	untrack()
 	if err != nil {
 		return err
 	}
-	util.StatusMessage(util.VERBOSITY_DEFAULT, "%s", string(o))
 
-	err = writeCommandFile(dstFile, cmd)
+	if options["gcReport"] {
+		// The list of discarded sections is too noisy to display; save it for
+		// later processing instead.
+		if err := ioutil.WriteFile(dstFile+".gc", o, 0644); err != nil {
+			return util.ChildNewtError(err)
+		}
+	} else {
+		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s", string(o))
+	}
+
+	err = writeCommandFile(dstFile, c.linkCommandRecord(cmd))
 	if err != nil {
 		return err
 	}
@@ -1141,7 +1559,7 @@ func (c *Compiler) generateExtras(elfFilename string,
 			elfFilename,
 			binFile,
 		}
-		o, err := util.ShellCommand(cmd, nil)
+		o, err := util.ShellCommand(containerCmd(cmd), nil)
 		if err != nil {
 			return err
 		}
@@ -1162,7 +1580,7 @@ func (c *Compiler) generateExtras(elfFilename string,
 			"-wxdS",
 			elfFilename,
 		}
-		o, err := util.ShellCommandLimitDbgOutput(cmd, nil, true, 0)
+		o, err := util.ShellCommandLimitDbgOutput(containerCmd(cmd), nil, true, 0)
 		if err != nil {
 			// XXX: gobjdump appears to always crash.  Until we get that sorted
 			// out, don't fail the link process if lst generation fails.
@@ -1182,7 +1600,7 @@ func (c *Compiler) generateExtras(elfFilename string,
 				sect,
 				elfFilename,
 			}
-			o, err := util.ShellCommandLimitDbgOutput(cmd, nil, true, 0)
+			o, err := util.ShellCommandLimitDbgOutput(containerCmd(cmd), nil, true, 0)
 			if err != nil {
 				if _, err := f.Write(o); err != nil {
 					return util.NewNewtError(err.Error())
@@ -1194,7 +1612,7 @@ func (c *Compiler) generateExtras(elfFilename string,
 			c.osPath,
 			elfFilename,
 		}
-		o, err = util.ShellCommandLimitDbgOutput(cmd, nil, true, 0)
+		o, err = util.ShellCommandLimitDbgOutput(containerCmd(cmd), nil, true, 0)
 		if err != nil {
 			return err
 		}
@@ -1211,7 +1629,7 @@ func (c *Compiler) PrintSize(elfFilename string) (string, error) {
 		c.osPath,
 		elfFilename,
 	}
-	o, err := util.ShellCommand(cmd, nil)
+	o, err := util.ShellCommand(containerCmd(cmd), nil)
 	if err != nil {
 		return "", err
 	}
@@ -1229,7 +1647,7 @@ func (c *Compiler) PrintSize(elfFilename string) (string, error) {
 func (c *Compiler) CompileElf(binFile string, staticLib []util.StaticLib,
 	keepSymbols []string, elfLib string) error {
 	options := map[string]bool{"mapFile": c.ldMapFile,
-		"listFile": true, "binFile": c.ldBinFile}
+		"listFile": true, "binFile": c.ldBinFile, "gcReport": c.GcReport}
 
 	// Make sure the compiler package info is added to the global set.
 	c.ensureLclInfoAdded()
@@ -1303,11 +1721,9 @@ func (c *Compiler) CopySymbolsCmd(infile string, outfile string, sm *symbol.Symb
 func (c *Compiler) CompileArchiveCmd(archiveFile string,
 	objFiles []string) []string {
 
-	cmd := []string{
-		c.arPath,
-		"rcs",
-		archiveFile,
-	}
+	cmd := []string{c.arPath}
+	cmd = append(cmd, c.arFlags(c.arThin)...)
+	cmd = append(cmd, archiveFile)
 	cmd = append(cmd, c.getObjFiles(objFiles)...)
 	return cmd
 }
@@ -1320,11 +1736,9 @@ func (c *Compiler) CompileArchiveCmdSafe(archiveFile string,
 	objFiles = c.getObjFiles(objFiles)
 
 	for len(objFiles) > 0 {
-		cmd := []string{
-			c.arPath,
-			"rcs",
-			archiveFile,
-		}
+		cmd := []string{c.arPath}
+		cmd = append(cmd, c.arFlags(c.arThin)...)
+		cmd = append(cmd, archiveFile)
 
 		for len(objFiles) > 0 && len(strings.Join(cmd, " ")) < 30000 {
 			var objFile string
@@ -1363,8 +1777,30 @@ func createSplitArchiveLinkerFile(archiveFile string,
 	}
 
 	for _, arch := range archFiles {
-		if _, err := f.WriteString("ADDLIB " + arch + "\n"); err != nil {
-			return util.NewNewtError(err.Error())
+		// ADDLIB does not reliably extract the members of a thin archive;
+		// add the object files that it references instead.
+		thin, err := IsThinArchive(arch)
+		if err != nil {
+			return err
+		}
+
+		lines := []string{"ADDLIB " + arch}
+		if thin {
+			members, err := ThinArchiveMembers(arch)
+			if err != nil {
+				return err
+			}
+
+			lines = nil
+			for _, m := range members {
+				lines = append(lines, "ADDMOD "+m)
+			}
+		}
+
+		for _, line := range lines {
+			if _, err := f.WriteString(line + "\n"); err != nil {
+				return util.NewNewtError(err.Error())
+			}
 		}
 	}
 
@@ -1431,19 +1867,27 @@ func (c *Compiler) CompileArchive(archiveFile string) error {
 	if err != nil && !os.IsNotExist(err) {
 		return util.NewNewtError(err.Error())
 	}
+	if err := invalidateCommandFile(archiveFile); err != nil {
+		return err
+	}
 
 	fullCmd := c.CompileArchiveCmd(archiveFile, objFiles)
 
+	// The archive is built with several invocations if the command line is
+	// long; it is incomplete until all of them have run.
+	untrack := util.TrackPartialOutput(archiveFile)
+	defer untrack()
+
 	cmdSafe := c.CompileArchiveCmdSafe(archiveFile, objFiles)
 	for _, cmd := range cmdSafe {
-		o, err := util.ShellCommand(cmd, nil)
+		o, err := util.ShellCommand(containerCmd(cmd), c.arEnv())
 		if err != nil {
 			return err
 		}
 		util.StatusMessage(util.VERBOSITY_DEFAULT, "%s", string(o))
 	}
 
-	err = writeCommandFile(archiveFile, fullCmd)
+	err = writeCommandFile(archiveFile, c.commandRecord(fullCmd))
 	if err != nil {
 		return err
 	}
@@ -1525,7 +1969,7 @@ func (c *Compiler) RenameSymbols(sm *symbol.SymbolMap, libraryFile string, ext s
 
 	cmd := c.RenameSymbolsCmd(sm, libraryFile, ext)
 
-	o, err := util.ShellCommand(cmd, nil)
+	o, err := util.ShellCommand(containerCmd(cmd), nil)
 	if err != nil {
 		return err
 	}
@@ -1537,7 +1981,7 @@ func (c *Compiler) RenameSymbols(sm *symbol.SymbolMap, libraryFile string, ext s
 func (c *Compiler) ParseLibrary(libraryFile string) (error, []byte) {
 	cmd := c.ParseLibraryCmd(libraryFile)
 
-	out, err := util.ShellCommand(cmd, nil)
+	out, err := util.ShellCommand(containerCmd(cmd), nil)
 	if err != nil {
 		return err, nil
 	}
@@ -1547,7 +1991,7 @@ func (c *Compiler) ParseLibrary(libraryFile string) (error, []byte) {
 func (c *Compiler) CopySymbols(infile string, outfile string, sm *symbol.SymbolMap) error {
 	cmd := c.CopySymbolsCmd(infile, outfile, sm)
 
-	_, err := util.ShellCommand(cmd, nil)
+	_, err := util.ShellCommand(containerCmd(cmd), nil)
 	if err != nil {
 		return err
 	}
@@ -1566,7 +2010,7 @@ func (c *Compiler) ConvertBinToHex(inFile string, outFile string, baseAddr int)
 		inFile,
 		outFile,
 	}
-	o, err := util.ShellCommand(cmd, nil)
+	o, err := util.ShellCommand(containerCmd(cmd), nil)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,221 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/util"
+)
+
+// A container that the toolchain commands (compile, archive, link, etc.) are
+// executed in.  The project directory is bind-mounted at the same path inside
+// the container, so the commands are identical to those that would run on the
+// host.  The same is done for the build output directory and for downloaded
+// toolchains that are located outside the project (see containerMounts()).
+//
+// The container's main process reads from a pipe connected to newt; it exits
+// when the pipe is closed, even if newt is killed.  Since the container is
+// started with `--rm`, it never outlives the newt process.
+type Container struct {
+	Engine string
+	Image  string
+	Name   string
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// The running container, or nil if commands are executed on the host.
+var container *Container
+
+// Determines which container engine to use.  Docker is preferred; Podman is
+// used if Docker is not installed.
+func containerEngine() (string, error) {
+	for _, engine := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(engine); err == nil {
+			return engine, nil
+		}
+	}
+
+	return "", util.NewNewtError(
+		"cannot build in a container: neither docker nor podman is installed")
+}
+
+// InContainer indicates whether toolchain commands are executed in a
+// container.
+func InContainer() bool {
+	return container != nil
+}
+
+// Returns the host directories to bind-mount into the build container: the
+// project directory, the build output directory (--out / NEWT_OUT), and the
+// directories of any downloaded toolchains that compilers have been configured
+// to use.  Directories within the project are covered by the project's mount.
+func containerMounts(basePath string) []string {
+	dirs := []string{basePath}
+
+	addDir := func(dir string) {
+		if dir == "" {
+			return
+		}
+
+		for _, d := range dirs {
+			if dir == d || strings.HasPrefix(dir, d+"/") {
+				return
+			}
+		}
+		dirs = append(dirs, dir)
+	}
+
+	addDir(newtutil.NewtOutDir)
+
+	toolchainDirsMtx.Lock()
+	for _, dir := range toolchainDirs {
+		addDir(dir)
+	}
+	toolchainDirsMtx.Unlock()
+
+	return dirs
+}
+
+// StartContainer starts a container from the specified image.  Until
+// StopContainer is called, all toolchain commands are executed inside it.
+func StartContainer(image string) error {
+	if container != nil {
+		return nil
+	}
+
+	engine, err := containerEngine()
+	if err != nil {
+		return err
+	}
+
+	basePath := project.GetProject().BasePath
+
+	ctr := &Container{
+		Engine: engine,
+		Image:  image,
+		Name:   fmt.Sprintf("newt-build-%d", os.Getpid()),
+	}
+
+	args := []string{
+		"run", "-i", "--rm",
+		"--name", ctr.Name,
+	}
+
+	for _, dir := range containerMounts(basePath) {
+		// Create the directory so that the engine does not create it as
+		// root.
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return util.ChildNewtError(err)
+		}
+		args = append(args, "-v", dir+":"+dir)
+	}
+
+	args = append(args, "-w", basePath)
+
+	// Run as the invoking user so that the build artifacts are not owned by
+	// root.
+	if runtime.GOOS != "windows" {
+		args = append(args, "--user",
+			fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+
+	args = append(args, "--entrypoint", "sh", image,
+		"-c", "echo ready; exec cat > /dev/null")
+
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Starting build container %s (%s)\n", ctr.Name, image)
+	util.StatusMessage(util.VERBOSITY_VERBOSE, "%s %s\n", engine,
+		strings.Join(args, " "))
+
+	ctr.cmd = exec.Command(engine, args...)
+	ctr.cmd.Stderr = os.Stderr
+
+	ctr.stdin, err = ctr.cmd.StdinPipe()
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	stdout, err := ctr.cmd.StdoutPipe()
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	if err := ctr.cmd.Start(); err != nil {
+		return util.FmtNewtError("failed to start build container: %s",
+			err.Error())
+	}
+
+	// Wait for the container to report that it is running.  The engine may
+	// need to pull the image first.
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "ready" {
+		ctr.stdin.Close()
+		ctr.cmd.Wait()
+		return util.FmtNewtError("failed to start build container from "+
+			"image %s", image)
+	}
+
+	container = ctr
+	return nil
+}
+
+// StopContainer stops the build container, if one is running.  Subsequent
+// toolchain commands are executed on the host.
+func StopContainer() {
+	if container == nil {
+		return
+	}
+
+	container.stdin.Close()
+	if err := container.cmd.Wait(); err != nil {
+		log.Debugf("build container %s exited with error: %s",
+			container.Name, err.Error())
+	}
+
+	container = nil
+}
+
+// Converts a toolchain command into one that executes inside the build
+// container.  The command is returned unchanged if there is no container.
+func containerCmd(cmd []string) []string {
+	if container == nil {
+		return cmd
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		wd = project.GetProject().BasePath
+	}
+
+	return append([]string{
+		container.Engine, "exec", "-w", wd, container.Name,
+	}, cmd...)
+}
@@ -173,27 +173,139 @@ func logRebuildReqdModTime(dest string, src string) {
 		"source (%s) newer than destination", src))
 }
 
-func logRebuildReqdNoDep(dest string, dep string) {
-	logRebuildReqd(dest, fmt.Sprintf(
-		"dependency \"%s\" has been deleted", dep))
-}
-
 func logRebuildReqdNewDep(dest string, dep string) {
 	logRebuildReqd(dest, fmt.Sprintf(
 		"destination older than dependency (%s)", dep))
 }
 
+// Reasons that a source file needs to be recompiled.
+const (
+	REBUILD_REASON_NO_OBJ = iota
+	REBUILD_REASON_CMD
+	REBUILD_REASON_SRC
+	REBUILD_REASON_DEP_DELETED
+	REBUILD_REASON_DEP
+	REBUILD_REASON_EXTRA_DEP
+	REBUILD_REASON_SETTING
+)
+
+// Explains why a source file needs to be recompiled.
+type RebuildReason struct {
+	Code int
+
+	// The dependency that triggered the rebuild (REBUILD_REASON_DEP_DELETED,
+	// REBUILD_REASON_DEP, REBUILD_REASON_EXTRA_DEP), or the syscfg macro
+	// whose definition changed (REBUILD_REASON_SETTING).
+	Dep string
+
+	// The arguments that were added to and removed from the compiler
+	// invocation since the file was last built (REBUILD_REASON_CMD).
+	Added   []string
+	Removed []string
+}
+
+func (r *RebuildReason) String() string {
+	switch r.Code {
+	case REBUILD_REASON_NO_OBJ:
+		return "object file missing"
+
+	case REBUILD_REASON_CMD:
+		if len(r.Added) == 0 && len(r.Removed) == 0 {
+			return "command line changed (argument order)"
+		}
+		return "command line changed"
+
+	case REBUILD_REASON_SRC:
+		return "source file changed"
+
+	case REBUILD_REASON_DEP_DELETED:
+		return fmt.Sprintf("dependency deleted (%s)", r.Dep)
+
+	case REBUILD_REASON_DEP:
+		return fmt.Sprintf("dependency changed (%s)", r.Dep)
+
+	case REBUILD_REASON_EXTRA_DEP:
+		return fmt.Sprintf("extra dependency changed (%s)", r.Dep)
+
+	case REBUILD_REASON_SETTING:
+		return fmt.Sprintf("setting changed (%s)", r.Dep)
+
+	default:
+		return "unknown"
+	}
+}
+
+// Compares a file's previous command record with the current one.  The
+// returned reason lists the arguments that differ.
+func commandChangeReason(dstFile string, cmd []string) *RebuildReason {
+	r := &RebuildReason{Code: REBUILD_REASON_CMD}
+
+	prevCmd, err := ioutil.ReadFile(dstFile + ".cmd")
+	if err != nil {
+		return r
+	}
+
+	// [argument] => (count in current command) - (count in previous command)
+	counts := map[string]int{}
+	for _, arg := range cmd {
+		counts[arg]++
+	}
+	for _, arg := range strings.Split(string(prevCmd), "\n") {
+		counts[arg]--
+	}
+
+	for _, arg := range cmd {
+		if counts[arg] > 0 {
+			r.Added = append(r.Added, arg)
+			counts[arg]--
+		}
+	}
+	for _, arg := range strings.Split(string(prevCmd), "\n") {
+		if counts[arg] < 0 {
+			r.Removed = append(r.Removed, arg)
+			counts[arg]++
+		}
+	}
+
+	return r
+}
+
 // Determines if the specified C or assembly file needs to be built.  A compile
 // is required if any of the following is true:
 //     * The destination object file does not exist.
 //     * The existing object file was built with a different compiler
-//       invocation.
+//       invocation or a different compiler version.
 //     * The source file has a newer modification time than the object file.
-//     * One or more included header files has a newer modification time than
-//       the object file.
+//     * One or more included header files or the compiler definition
+//       (compiler.yml) has a newer modification time than the object file.
 func (tracker *DepTracker) CompileRequired(srcFile string,
 	compilerType int) (bool, error) {
 
+	reason, err := tracker.compileReason(srcFile, compilerType, false)
+	if err != nil {
+		return false, err
+	}
+	if reason == nil {
+		return false, nil
+	}
+
+	logRebuildReqd(srcFile, reason.String())
+	return true, nil
+}
+
+// RebuildReason determines why the specified C or assembly file needs to be
+// built, using the same criteria as CompileRequired.  It returns nil if the
+// file is up to date.  Unlike CompileRequired, it does not discard stale
+// dependency files, so it does not affect the next build.
+func (tracker *DepTracker) RebuildReason(srcFile string,
+	compilerType int) (*RebuildReason, error) {
+
+	return tracker.compileReason(srcFile, compilerType, true)
+}
+
+func (tracker *DepTracker) compileReason(srcFile string, compilerType int,
+	dryRun bool) (*RebuildReason, error) {
+
 	objPath := tracker.compiler.dstFilePath(srcFile) + ".o"
 	depPath := tracker.compiler.dstFilePath(srcFile) + ".d"
 
@@ -201,40 +313,45 @@ func (tracker *DepTracker) CompileRequired(srcFile string,
 	// rebuild is necessary.
 	cmd, err := tracker.compiler.CompileFileCmd(srcFile, compilerType)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	if commandHasChanged(objPath, cmd) {
-		logRebuildReqdCmdChanged(srcFile)
-		err := tracker.compiler.GenDepsForFile(srcFile, compilerType)
-		if err != nil {
-			return false, err
+	rec := tracker.compiler.commandRecord(cmd)
+	if util.NodeNotExist(objPath) || commandHasChanged(objPath, rec) {
+		if !dryRun {
+			err := tracker.compiler.GenDepsForFile(srcFile, compilerType)
+			if err != nil {
+				return nil, err
+			}
 		}
-		return true, nil
+
+		if util.NodeNotExist(objPath) {
+			return &RebuildReason{Code: REBUILD_REASON_NO_OBJ}, nil
+		}
+		return commandChangeReason(objPath, rec), nil
 	}
 
 	if util.NodeNotExist(depPath) {
 		err := tracker.compiler.GenDepsForFile(srcFile, compilerType)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 	}
 
 	srcModTime, err := util.FileModificationTime(srcFile)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	objModTime, err := util.FileModificationTime(objPath)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	// If the object doesn't exist or is older than the source file, a build is
-	// required; no need to check dependencies.
+	// If the object is older than the source file, a build is required; no
+	// need to check dependencies.
 	if srcModTime.After(objModTime) {
-		logRebuildReqdModTime(objPath, srcFile)
-		return true, nil
+		return &RebuildReason{Code: REBUILD_REASON_SRC}, nil
 	}
 
 	// Determine if the dependency (.d) file needs to be generated.  If it
@@ -242,20 +359,20 @@ func (tracker *DepTracker) CompileRequired(srcFile string,
 	// needs to be created.
 	depModTime, err := util.FileModificationTime(depPath)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	if srcModTime.After(depModTime) {
 		err := tracker.compiler.GenDepsForFile(srcFile, compilerType)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 	}
 
 	// Extract the dependency filenames from the dependency file.
 	deps, err := ParseDepsFile(depPath)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	// Check if any dependencies are newer than the destination object file.
@@ -265,23 +382,48 @@ func (tracker *DepTracker) CompileRequired(srcFile string,
 			// the dependency file is out of date, so it needs to be deleted.
 			// We cannot regenerate it now because the source file might be
 			// including a nonexistent header.
-			logRebuildReqdNoDep(srcFile, dep)
-			os.Remove(depPath)
-			return true, nil
+			if !dryRun {
+				os.Remove(depPath)
+			}
+			return &RebuildReason{
+				Code: REBUILD_REASON_DEP_DELETED,
+				Dep:  dep,
+			}, nil
 		} else {
 			depModTime, err = util.FileModificationTime(dep)
 			if err != nil {
-				return false, err
+				return nil, err
 			}
 		}
 
 		if depModTime.After(objModTime) {
-			logRebuildReqdNewDep(srcFile, dep)
-			return true, nil
+			// A change to syscfg.h only matters if it affects a setting
+			// that this file uses.
+			s := tracker.compiler.syscfgSymbols
+			if s != nil && s.isHeader(dep) {
+				reason, err := tracker.syscfgReason(srcFile, dep, deps,
+					objModTime)
+				if err != nil {
+					return nil, err
+				}
+				if reason == nil {
+					continue
+				}
+				return reason, nil
+			}
+
+			code := REBUILD_REASON_DEP
+			if tracker.compiler.isExtraDep(dep) {
+				code = REBUILD_REASON_EXTRA_DEP
+			}
+			return &RebuildReason{
+				Code: code,
+				Dep:  dep,
+			}, nil
 		}
 	}
 
-	return false, nil
+	return nil, nil
 }
 
 // Determines if the specified static library needs to be rearchived.  The
@@ -297,7 +439,9 @@ func (tracker *DepTracker) ArchiveRequired(archiveFile string,
 	// If the archive was previously built with a different set of options, a
 	// rebuild is required.
 	cmd := tracker.compiler.CompileArchiveCmd(archiveFile, objFiles)
-	if commandHasChanged(archiveFile, cmd) {
+	if commandHasChanged(archiveFile,
+		tracker.compiler.commandRecord(cmd)) {
+
 		logRebuildReqdCmdChanged(archiveFile)
 		return true, nil
 	}
@@ -334,7 +478,7 @@ func (tracker *DepTracker) LinkRequired(dstFile string,
 	// If the elf file was previously built with a different set of options, a
 	// rebuild is required.
 	cmd := tracker.compiler.CompileBinaryCmd(dstFile, options, staticLib, keepSymbols, elfLib)
-	if commandHasChanged(dstFile, cmd) {
+	if commandHasChanged(dstFile, tracker.compiler.linkCommandRecord(cmd)) {
 		logRebuildReqdCmdChanged(dstFile)
 		return true, nil
 	}
@@ -373,6 +517,7 @@ func (tracker *DepTracker) LinkRequired(dstFile string,
 	for _, ls := range tracker.compiler.LinkerScripts {
 		objFiles = append(objFiles, ls)
 	}
+	objFiles = append(objFiles, tracker.compiler.extraDeps...)
 	for _, obj := range objFiles {
 		objModTime, err := util.FileModificationTime(obj)
 		if err != nil {
@@ -0,0 +1,147 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+import (
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/config"
+	"mynewt.apache.org/newt/newt/pkg"
+	"mynewt.apache.org/newt/newt/project"
+	"mynewt.apache.org/newt/util"
+)
+
+// A compiler package and the architectures it builds for.  The architectures
+// are listed in compiler.yml:
+//
+//	compiler.archs: [cortex_m0, cortex_m3, cortex_m4]
+type CompilerPkgInfo struct {
+	Pkg   *pkg.LocalPackage
+	Archs []string
+}
+
+// Supports reports whether the compiler builds for the specified
+// architecture.
+func (ci *CompilerPkgInfo) Supports(arch string) bool {
+	for _, a := range ci.Archs {
+		if a == arch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CompilerPkgs returns every compiler package in the project, sorted by full
+// name.
+func CompilerPkgs() ([]CompilerPkgInfo, error) {
+	proj := project.GetProject()
+
+	var infos []CompilerPkgInfo
+	for _, p := range proj.PackagesOfType(pkg.PACKAGE_TYPE_COMPILER) {
+		lpkg := p.(*pkg.LocalPackage)
+
+		yc, err := config.ReadFile(lpkg.BasePath() + "/" + COMPILER_FILENAME)
+		if err != nil {
+			return nil, err
+		}
+
+		archs, err := yc.GetValStringSlice("compiler.archs", nil)
+		util.OneTimeWarningError(err)
+		sort.Strings(archs)
+
+		infos = append(infos, CompilerPkgInfo{
+			Pkg:   lpkg,
+			Archs: archs,
+		})
+	}
+
+	sort.Slice(infos, func(i int, j int) bool {
+		return infos[i].Pkg.FullName() < infos[j].Pkg.FullName()
+	})
+
+	return infos, nil
+}
+
+// DetectCompiler selects the compiler package that builds for the BSP's
+// compiler architecture (bsp.compiler_arch).  If several do, a compiler in
+// the BSP's repo is preferred; any remaining ambiguity is an error.
+func DetectCompiler(bsp *pkg.BspPackage) (*pkg.LocalPackage, error) {
+	infos, err := CompilerPkgs()
+	if err != nil {
+		return nil, err
+	}
+
+	arch := bsp.CompilerArch
+
+	var matches []*pkg.LocalPackage
+	for _, ci := range infos {
+		if ci.Supports(arch) {
+			matches = append(matches, ci.Pkg)
+		}
+	}
+
+	if len(matches) > 1 {
+		var local []*pkg.LocalPackage
+		for _, m := range matches {
+			if m.Repo() == bsp.Repo() {
+				local = append(local, m)
+			}
+		}
+		if len(local) > 0 {
+			matches = local
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, util.FmtNewtError(
+			"BSP %s requires a compiler for architecture \"%s\", but no "+
+				"compiler package supports it (compiler.archs)",
+			bsp.FullName(), arch)
+
+	case 1:
+		return matches[0], nil
+
+	default:
+		names := make([]string, len(matches))
+		for i, m := range matches {
+			names[i] = "    " + m.FullName()
+		}
+		return nil, util.FmtNewtError(
+			"BSP %s requires a compiler for architecture \"%s\", and "+
+				"several compiler packages support it:\n%s\n"+
+				"Specify one with bsp.compiler",
+			bsp.FullName(), arch, strings.Join(names, "\n"))
+	}
+}
+
+// BspCompilerPkg returns the compiler package used to build for a BSP: the
+// package named by bsp.compiler, or else the one detected from
+// bsp.compiler_arch.
+func BspCompilerPkg(bsp *pkg.BspPackage) (*pkg.LocalPackage, error) {
+	if bsp.CompilerName != "" {
+		return project.GetProject().ResolvePackage(
+			bsp.Repo(), bsp.CompilerName)
+	}
+
+	return DetectCompiler(bsp)
+}
@@ -0,0 +1,374 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"mynewt.apache.org/newt/newt/settings"
+	"mynewt.apache.org/newt/util"
+)
+
+// Directory, relative to $HOME/.newt, where downloaded toolchains are cached.
+const TOOLCHAINS_DIR = "toolchains"
+
+// A pinned toolchain archive that newt downloads when the system compiler is
+// missing or is the wrong version.  It is specified in compiler.yml:
+//
+//	compiler.toolchain_url.LINUX_AMD64: <url>
+//	compiler.toolchain_sha256.LINUX_AMD64: <hex digest>
+//	compiler.toolchain_version: <string in `<cc> --version` output>
+//
+// The host OS and architecture are available as a setting of the form
+// <GOOS>_<GOARCH> (e.g., LINUX_AMD64, DARWIN_ARM64, WINDOWS_AMD64).
+type ToolchainArchive struct {
+	Url     string
+	Sha256  string
+	Version string
+}
+
+// [url] => directory the archive was extracted to, or "" if the system
+// toolchain is used instead.
+var toolchainDirs = map[string]string{}
+var toolchainDirsMtx sync.Mutex
+
+// HostSetting returns the name of the setting that identifies the host OS and
+// architecture in compiler.yml conditionals.
+func HostSetting() string {
+	return strings.ToUpper(runtime.GOOS + "_" + runtime.GOARCH)
+}
+
+// ToolchainsDir returns the directory where downloaded toolchains are cached.
+func ToolchainsDir() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", util.ChildNewtError(err)
+	}
+
+	return usr.HomeDir + "/" + settings.NEWTRC_DIR + "/" + TOOLCHAINS_DIR, nil
+}
+
+// Each archive is extracted to a directory named after the hash of its URL, so
+// that changing the pinned URL results in a fresh download.  The verified
+// digest of the archive is recorded in a file next to this directory (see
+// digestPath()).
+func (ta *ToolchainArchive) cacheDir() (string, error) {
+	base, err := ToolchainsDir()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(ta.Url))
+	return base + "/" + hex.EncodeToString(sum[:8]), nil
+}
+
+// The file recording the sha256 digest of the archive that was extracted to a
+// cache directory.
+func digestPath(dir string) string {
+	return dir + ".sha256"
+}
+
+// Indicates whether a cached toolchain was extracted from an archive with the
+// pinned digest.
+func (ta *ToolchainArchive) cacheValid(dir string) bool {
+	b, err := ioutil.ReadFile(digestPath(dir))
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(strings.TrimSpace(string(b)), ta.Sha256)
+}
+
+func (ta *ToolchainArchive) download(dstFile string) error {
+	util.StatusMessage(util.VERBOSITY_DEFAULT,
+		"Downloading toolchain %s\n", ta.Url)
+
+	rsp, err := http.Get(ta.Url)
+	if err != nil {
+		return util.FmtNewtError("failed to download toolchain %s: %s",
+			ta.Url, err.Error())
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return util.FmtNewtError("failed to download toolchain %s: %s",
+			ta.Url, rsp.Status)
+	}
+
+	f, err := os.Create(dstFile)
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), rsp.Body); err != nil {
+		return util.FmtNewtError("failed to download toolchain %s: %s",
+			ta.Url, err.Error())
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(sum, ta.Sha256) {
+		return util.FmtNewtError(
+			"toolchain %s has incorrect sha256: have=%s want=%s",
+			ta.Url, sum, ta.Sha256)
+	}
+
+	return nil
+}
+
+func extractZip(archive string, dstDir string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+	defer r.Close()
+
+	prefix := filepath.Clean(dstDir) + string(os.PathSeparator)
+	for _, zf := range r.File {
+		dst := filepath.Join(dstDir, zf.Name)
+		if !strings.HasPrefix(dst, prefix) {
+			return util.FmtNewtError("invalid path in toolchain archive: %s",
+				zf.Name)
+		}
+
+		if zf.FileInfo().IsDir() {
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return util.ChildNewtError(err)
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return util.ChildNewtError(err)
+		}
+
+		src, err := zf.Open()
+		if err != nil {
+			return util.ChildNewtError(err)
+		}
+
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC,
+			zf.Mode())
+		if err != nil {
+			src.Close()
+			return util.ChildNewtError(err)
+		}
+
+		_, err = io.Copy(f, src)
+		f.Close()
+		src.Close()
+		if err != nil {
+			return util.ChildNewtError(err)
+		}
+	}
+
+	return nil
+}
+
+// Extracts a toolchain archive.  Zip files are extracted directly; everything
+// else is passed to the host's tar utility, which handles the compression
+// formats that toolchains are typically distributed in (gzip, bzip2, xz).
+func extractArchive(archive string, name string, dstDir string) error {
+	if strings.HasSuffix(strings.ToLower(name), ".zip") {
+		return extractZip(archive, dstDir)
+	}
+
+	_, err := util.ShellCommand([]string{"tar", "-xf", archive, "-C", dstDir},
+		nil)
+	return err
+}
+
+// Fetch returns the directory containing the extracted toolchain, downloading
+// and extracting the archive if it is not already cached.  A cached toolchain
+// is only reused if it was extracted from an archive with the pinned sha256
+// digest; otherwise it is downloaded again.
+func (ta *ToolchainArchive) Fetch() (string, error) {
+	if ta.Sha256 == "" {
+		return "", util.FmtNewtError(
+			"toolchain %s does not specify a sha256 digest", ta.Url)
+	}
+
+	dir, err := ta.cacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	if util.NodeExist(dir) {
+		if ta.cacheValid(dir) {
+			return dir, nil
+		}
+
+		util.StatusMessage(util.VERBOSITY_DEFAULT,
+			"Cached toolchain %s does not match sha256 %s; removing\n",
+			ta.Url, ta.Sha256)
+		if err := os.RemoveAll(dir); err != nil {
+			return "", util.ChildNewtError(err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", util.ChildNewtError(err)
+	}
+
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dir), "tmp-")
+	if err != nil {
+		return "", util.ChildNewtError(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	name := path.Base(ta.Url)
+	archive := tmpDir + "/" + name
+	if err := ta.download(archive); err != nil {
+		return "", err
+	}
+
+	extractDir := tmpDir + "/extract"
+	if err := os.Mkdir(extractDir, 0755); err != nil {
+		return "", util.ChildNewtError(err)
+	}
+	if err := extractArchive(archive, name, extractDir); err != nil {
+		return "", err
+	}
+
+	// Only move the toolchain into place once it has been fully extracted so
+	// that an interrupted download is not mistaken for a cached toolchain.
+	if err := os.Rename(extractDir, dir); err != nil {
+		return "", util.ChildNewtError(err)
+	}
+
+	err = ioutil.WriteFile(digestPath(dir),
+		[]byte(strings.ToLower(ta.Sha256)+"\n"), 0644)
+	if err != nil {
+		return "", util.ChildNewtError(err)
+	}
+
+	return dir, nil
+}
+
+// Searches an extracted toolchain for the named executable.  Toolchain
+// archives typically contain a top-level directory, so the executable is
+// searched for in every "bin" directory rather than at a fixed path.
+func findToolInDir(dir string, name string) string {
+	names := []string{name}
+	if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+		names = append(names, name+".exe")
+	}
+
+	found := ""
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || found != "" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() || info.Name() != "bin" {
+			return nil
+		}
+
+		for _, n := range names {
+			candidate := filepath.Join(p, n)
+			if util.NodeExist(candidate) {
+				found = filepath.ToSlash(candidate)
+				return filepath.SkipDir
+			}
+		}
+
+		return nil
+	})
+
+	return found
+}
+
+// Indicates whether the system compiler at ccPath is unusable: it is missing,
+// it does not satisfy the compiler's version constraints, or a version is
+// pinned and the compiler does not report it.
+func (c *Compiler) systemToolchainUnusable(ta *ToolchainArchive) bool {
+	if _, err := exec.LookPath(c.ccPath); err != nil {
+		log.Debugf("compiler %s not found: %s", c.ccPath, err.Error())
+		return true
+	}
+
+	if err := c.checkVersion(); err != nil {
+		log.Debugf("%s", err.Error())
+		return true
+	}
+
+	if ta.Version != "" && !strings.Contains(c.toolchainId(), ta.Version) {
+		log.Debugf("compiler %s is not version %s", c.ccPath, ta.Version)
+		return true
+	}
+
+	return false
+}
+
+// Replaces the compiler's tool paths with those from a downloaded toolchain if
+// the system toolchain is unusable.  The decision is made once per archive;
+// all compilers in a build use the same toolchain.
+func (c *Compiler) useToolchainArchive(ta *ToolchainArchive) error {
+	toolchainDirsMtx.Lock()
+	defer toolchainDirsMtx.Unlock()
+
+	dir, ok := toolchainDirs[ta.Url]
+	if !ok {
+		if c.systemToolchainUnusable(ta) {
+			var err error
+			dir, err = ta.Fetch()
+			if err != nil {
+				return err
+			}
+		}
+		toolchainDirs[ta.Url] = dir
+	}
+
+	if dir == "" {
+		return nil
+	}
+
+	paths := []*string{
+		&c.ccPath, &c.cppPath, &c.asPath, &c.arPath,
+		&c.odPath, &c.osPath, &c.ocPath,
+	}
+	for _, p := range paths {
+		if *p == "" {
+			continue
+		}
+
+		tool := findToolInDir(dir, filepath.Base(*p))
+		if tool == "" {
+			return util.FmtNewtError(
+				"toolchain %s does not contain %s", ta.Url, *p)
+		}
+		*p = tool
+	}
+
+	return nil
+}
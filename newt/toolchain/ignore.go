@@ -0,0 +1,189 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Ignore rules beginning with this prefix use glob syntax rather than regular
+// expression syntax.
+const IGNORE_GLOB_PREFIX = "glob:"
+
+// IgnoreRule is a single pkg.ignore_files or pkg.ignore_dirs entry.
+//
+// A regular expression rule matches if it matches any part of the file's path
+// relative to the source directory being collected (or, for a directory rule,
+// any part of the directory's name).
+//
+// A glob rule ("glob:<pattern>") must match the whole path relative to the
+// package directory.  "*" and "?" do not match "/", and "**/" matches any
+// number of directories.  A pattern without a "/" matches the file or
+// directory name at any depth.
+type IgnoreRule struct {
+	// The rule as written in pkg.yml.
+	Text string
+
+	// Whether the rule uses glob syntax.
+	Glob bool
+
+	re      *regexp.Regexp
+	matched int32
+}
+
+func NewIgnoreRule(text string) (*IgnoreRule, error) {
+	rule := &IgnoreRule{
+		Text: text,
+	}
+
+	pattern := text
+	if strings.HasPrefix(text, IGNORE_GLOB_PREFIX) {
+		rule.Glob = true
+		pattern = globToRegexp(strings.TrimPrefix(text, IGNORE_GLOB_PREFIX))
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, util.FmtNewtError(
+			"invalid ignore rule \"%s\": %s", text, err.Error())
+	}
+	rule.re = re
+
+	return rule, nil
+}
+
+// Converts a glob pattern to an anchored regular expression.
+func globToRegexp(glob string) string {
+	glob = strings.TrimPrefix(filepath.ToSlash(glob), "./")
+
+	s := "^"
+	if !strings.Contains(strings.TrimSuffix(glob, "/"), "/") {
+		// No directory component; match at any depth.
+		s += "(.*/)?"
+	}
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			s += "(.*/)?"
+			i += 2
+
+		case strings.HasPrefix(glob[i:], "**"):
+			s += ".*"
+			i++
+
+		case c == '*':
+			s += "[^/]*"
+
+		case c == '?':
+			s += "[^/]"
+
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				s += regexp.QuoteMeta(string(c))
+			} else {
+				class := glob[i+1 : i+1+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				s += "[" + class + "]"
+				i += end + 1
+			}
+
+		default:
+			s += regexp.QuoteMeta(string(c))
+		}
+	}
+
+	return strings.TrimSuffix(s, "/") + "$"
+}
+
+func (r *IgnoreRule) match(s string) bool {
+	if !r.re.MatchString(s) {
+		return false
+	}
+
+	atomic.StoreInt32(&r.matched, 1)
+	return true
+}
+
+// Matched indicates whether the rule has matched a file or directory since it
+// was created.
+func (r *IgnoreRule) Matched() bool {
+	return atomic.LoadInt32(&r.matched) != 0
+}
+
+// SetPkgDir specifies the directory of the package being built.  Glob ignore
+// rules match paths relative to this directory.
+func (c *Compiler) SetPkgDir(pkgDir string) {
+	c.pkgDir = filepath.ToSlash(filepath.Clean(pkgDir))
+}
+
+// Returns the specified path relative to the package directory.
+func (c *Compiler) pkgRelPath(path string) string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if c.pkgDir != "" && strings.HasPrefix(path, c.pkgDir+"/") {
+		path = strings.TrimPrefix(path, c.pkgDir+"/")
+	}
+
+	return path
+}
+
+// IgnoreFileRule returns the first pkg.ignore_files rule that matches the
+// specified source file, or nil if the file is not ignored.
+func (c *Compiler) IgnoreFileRule(file string) *IgnoreRule {
+	srcRel := strings.TrimPrefix(file, c.srcDir)
+	srcRel = strings.TrimLeft(srcRel, "/\\")
+
+	for _, rule := range c.info.IgnoreFiles {
+		s := srcRel
+		if rule.Glob {
+			s = c.pkgRelPath(file)
+		}
+		if rule.match(s) {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// IgnoreDirRule returns the first pkg.ignore_dirs rule that matches the
+// specified directory, or nil if the directory is not ignored.
+func (c *Compiler) IgnoreDirRule(dir string) *IgnoreRule {
+	for _, rule := range c.info.IgnoreDirs {
+		s := filepath.Base(dir)
+		if rule.Glob {
+			s = c.pkgRelPath(dir)
+		}
+		if rule.match(s) {
+			return rule
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+func jobWorker(
+	jobs <-chan CompilerJob,
+	stop chan struct{},
+	results chan error,
+	done func(j CompilerJob)) {
+
+	// Execute each job until failure or until a stop is signalled.
+	for {
+		select {
+		case s := <-stop:
+			// Re-enqueue the stop signal for the other routines.
+			stop <- s
+
+			// Terminate this go routine.
+			results <- nil
+			return
+
+		case j := <-jobs:
+			if err := RunJob(j); err != nil {
+				// Stop the other routines.
+				stop <- struct{}{}
+
+				// Report the error back to the master thread and terminate.
+				results <- err
+				return
+			}
+			if done != nil {
+				done(j)
+			}
+
+		default:
+			// Terminate this go routine.
+			results <- nil
+			return
+		}
+	}
+}
+
+// RunJobs executes the specified jobs on a pool of numJobs workers.  The jobs
+// can belong to any number of packages and compilers; the pool limits the
+// number of concurrent jobs across all of them.  Jobs are started in the order
+// given.  If a job fails, no further jobs are started, and the first error is
+// returned once the running jobs complete.  done, if non-nil, is called after
+// each successful job; it must be safe to call concurrently.
+func RunJobs(jobs []CompilerJob, numJobs int,
+	done func(j CompilerJob)) error {
+
+	if numJobs < 1 {
+		numJobs = 1
+	}
+
+	jobCh := make(chan CompilerJob, len(jobs))
+	defer close(jobCh)
+
+	stop := make(chan struct{}, numJobs)
+	defer close(stop)
+
+	results := make(chan error, numJobs)
+	defer close(results)
+
+	for _, j := range jobs {
+		jobCh <- j
+	}
+
+	for i := 0; i < numJobs; i++ {
+		go jobWorker(jobCh, stop, results, done)
+	}
+
+	var err error
+	for i := 0; i < numJobs; i++ {
+		subErr := <-results
+		if err == nil && subErr != nil {
+			err = subErr
+		}
+	}
+
+	return err
+}
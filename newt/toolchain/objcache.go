@@ -0,0 +1,544 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mynewt.apache.org/newt/newt/settings"
+	"mynewt.apache.org/newt/util"
+)
+
+// Directory, relative to $HOME/.newt, where compiled objects are cached.
+const OBJ_CACHE_DIR = "cache"
+
+// Default limit on the size of the object cache, in megabytes.
+const OBJ_CACHE_DFLT_MAX_MB = 2048
+
+const objCacheObjectsDir = "objects"
+const objCacheDisabledFilename = "disabled"
+const objCacheStatsFilename = "stats.json"
+
+// Compiler options that write files other than the object file.  Commands
+// containing these are never cached, as the other files would not be
+// restored.
+var objCacheUncacheableFlags = []string{
+	"-fstack-usage",
+	"--coverage",
+	"-ftest-coverage",
+	"-save-temps",
+}
+
+// Hits and misses since the statistics were last saved.
+var objCacheHits int64
+var objCacheMisses int64
+
+var objCacheEnabled *bool
+var objCacheEnabledMtx sync.Mutex
+
+// The state of the object cache, as reported by `newt cache info`.
+type ObjCacheInfo struct {
+	Dir     string
+	Enabled bool
+	Entries int
+	Size    int64
+	MaxSize int64
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+// ObjCacheDir returns the directory containing the object cache.  The
+// location can be overridden with `build_cache_dir` in newtrc.yml.
+func ObjCacheDir() (string, error) {
+	newtrc := settings.Newtrc()
+	dir, _ := newtrc.GetValString("build_cache_dir", nil)
+	if dir != "" {
+		return dir, nil
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return "", util.ChildNewtError(err)
+	}
+
+	return usr.HomeDir + "/" + settings.NEWTRC_DIR + "/" + OBJ_CACHE_DIR, nil
+}
+
+// ObjCacheMaxSize returns the size, in bytes, that builds prune the object
+// cache to.  The limit can be set in megabytes with `build_cache_max_size` in
+// newtrc.yml.
+func ObjCacheMaxSize() int64 {
+	newtrc := settings.Newtrc()
+	mb, _ := newtrc.GetValIntDflt("build_cache_max_size", nil,
+		OBJ_CACHE_DFLT_MAX_MB)
+
+	return int64(mb) * 1024 * 1024
+}
+
+// ObjCacheEnabled indicates whether compiled objects are stored in and
+// restored from the object cache.  The cache is enabled unless newtrc.yml
+// contains `build_cache: false` or it has been disabled with `newt cache
+// disable`.
+func ObjCacheEnabled() bool {
+	objCacheEnabledMtx.Lock()
+	defer objCacheEnabledMtx.Unlock()
+
+	if objCacheEnabled == nil {
+		newtrc := settings.Newtrc()
+		enabled, _ := newtrc.GetValBoolDflt("build_cache", nil, true)
+		if enabled {
+			dir, err := ObjCacheDir()
+			enabled = err == nil &&
+				util.NodeNotExist(dir+"/"+objCacheDisabledFilename)
+		}
+		objCacheEnabled = &enabled
+	}
+
+	return *objCacheEnabled
+}
+
+// SetObjCacheEnabled enables or disables the object cache for subsequent
+// builds.  Disabling the cache does not remove its contents.
+func SetObjCacheEnabled(enabled bool) error {
+	dir, err := ObjCacheDir()
+	if err != nil {
+		return err
+	}
+
+	path := dir + "/" + objCacheDisabledFilename
+	if enabled {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return util.ChildNewtError(err)
+		}
+	} else {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return util.ChildNewtError(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			return util.ChildNewtError(err)
+		}
+	}
+
+	objCacheEnabledMtx.Lock()
+	objCacheEnabled = nil
+	objCacheEnabledMtx.Unlock()
+
+	return nil
+}
+
+// BypassObjCache stops the current newt invocation from using the object
+// cache, without changing the cache's setting.
+func BypassObjCache() {
+	objCacheEnabledMtx.Lock()
+	defer objCacheEnabledMtx.Unlock()
+
+	enabled := false
+	objCacheEnabled = &enabled
+}
+
+// RestoreObjCache undoes BypassObjCache: subsequent builds use the object
+// cache according to its setting.
+func RestoreObjCache() {
+	objCacheEnabledMtx.Lock()
+	defer objCacheEnabledMtx.Unlock()
+
+	objCacheEnabled = nil
+}
+
+// Indicates whether the output of the specified compile command can be
+// cached.
+func objCacheable(cmd []string) bool {
+	for _, arg := range cmd {
+		for _, flag := range objCacheUncacheableFlags {
+			if arg == flag {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Indicates whether the specified compile command generates debug
+// information.
+func objCacheHasDebugInfo(cmd []string) bool {
+	debug := false
+	for _, arg := range cmd {
+		if strings.HasPrefix(arg, "-g") {
+			debug = arg != "-g0"
+		}
+	}
+
+	return debug
+}
+
+// Calculates the object cache key of a source file: a hash of the compile
+// command and of the preprocessed source.  The object file's path is not part
+// of the key, so the object can be reused by another target that compiles the
+// file identically.  "" is returned if the file's object cannot be cached.
+func (c *Compiler) objCacheKey(file string, compilerType int,
+	cmd []string, objPath string) (string, error) {
+
+	if !objCacheable(cmd) {
+		return "", nil
+	}
+
+	// Don't let the output path affect the key.
+	keyCmd := make([]string, len(cmd))
+	for i, arg := range cmd {
+		if arg == objPath {
+			arg = "<obj>"
+		}
+		keyCmd[i] = arg
+	}
+
+	h := sha256.New()
+	h.Write(serializeCommand(c.commandRecord(keyCmd)))
+	h.Write([]byte{0})
+
+	// Debug information records the compilation directory, so an object
+	// built with debug information can only be reused from the same
+	// directory.
+	if objCacheHasDebugInfo(cmd) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", util.ChildNewtError(err)
+		}
+		h.Write([]byte(wd))
+		h.Write([]byte{0})
+	}
+
+	var src []byte
+	var err error
+	if compilerType == COMPILER_TYPE_ASM && filepath.Ext(file) == ".s" {
+		// Plain assembly files are not preprocessed.
+		src, err = ioutil.ReadFile(file)
+		if err != nil {
+			return "", util.ChildNewtError(err)
+		}
+	} else {
+		// The compile command ends with "-c -o <obj> <src>".  Preprocess the
+		// source with the same options instead.
+		ppCmd := append([]string{}, cmd[:len(cmd)-4]...)
+		ppCmd = append(ppCmd, "-E", cmd[len(cmd)-1])
+
+		src, err = util.ShellCommandLimitDbgOutput(containerCmd(ppCmd), nil,
+			false, 0)
+		if err != nil {
+			return "", err
+		}
+	}
+	h.Write(src)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func objCacheEntryPath(dir string, key string) string {
+	return dir + "/" + objCacheObjectsDir + "/" + key[:2] + "/" + key
+}
+
+// Copies a cached object to the specified path.  On success, the compiler
+// output that was recorded with the object is returned.  The entry's
+// modification time is updated so that pruning removes the least recently
+// used entries first.
+func objCacheRestore(key string, objPath string) ([]byte, bool) {
+	dir, err := ObjCacheDir()
+	if err != nil {
+		return nil, false
+	}
+
+	entry := objCacheEntryPath(dir, key)
+	out, err := ioutil.ReadFile(entry + ".out")
+	if err != nil {
+		atomic.AddInt64(&objCacheMisses, 1)
+		return nil, false
+	}
+
+	untrack := util.TrackPartialOutput(objPath)
+	err = util.CopyFile(entry+".o", objPath)
+	untrack()
+	if err != nil {
+		log.Debugf("failed to restore %s from object cache: %s",
+			objPath, err.Error())
+		os.Remove(objPath)
+		atomic.AddInt64(&objCacheMisses, 1)
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(entry+".o", now, now)
+	os.Chtimes(entry+".out", now, now)
+
+	atomic.AddInt64(&objCacheHits, 1)
+	return out, true
+}
+
+// Adds a newly compiled object to the cache, along with the compiler's
+// output.  Failures are logged and otherwise ignored; the cache is only an
+// optimization.
+func objCacheStore(key string, objPath string, out []byte) {
+	dir, err := ObjCacheDir()
+	if err != nil {
+		return
+	}
+
+	entry := objCacheEntryPath(dir, key)
+
+	store := func() error {
+		if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+			return err
+		}
+
+		// Write to temporary files and rename them so that a concurrent
+		// build never sees a partial entry.  The output file is renamed last
+		// because its presence marks the entry as complete.
+		obj, err := ioutil.ReadFile(objPath)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range []struct {
+			ext  string
+			data []byte
+		}{
+			{".o", obj},
+			{".out", out},
+		} {
+			tmp, err := ioutil.TempFile(filepath.Dir(entry), "tmp-*"+f.ext)
+			if err != nil {
+				return err
+			}
+			_, err = tmp.Write(f.data)
+			tmp.Close()
+			if err == nil {
+				err = os.Rename(tmp.Name(), entry+f.ext)
+			}
+			if err != nil {
+				os.Remove(tmp.Name())
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := store(); err != nil {
+		log.Debugf("failed to add %s to object cache: %s",
+			objPath, err.Error())
+	}
+}
+
+type objCacheEntry struct {
+	paths   []string
+	size    int64
+	modTime time.Time
+}
+
+// Lists the entries in the object cache.
+func objCacheEntries(dir string) ([]objCacheEntry, error) {
+	// [key] => entry
+	m := map[string]*objCacheEntry{}
+
+	err := filepath.Walk(dir+"/"+objCacheObjectsDir,
+		func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			key := strings.TrimSuffix(path, filepath.Ext(path))
+			e := m[key]
+			if e == nil {
+				e = &objCacheEntry{}
+				m[key] = e
+			}
+			e.paths = append(e.paths, path)
+			e.size += info.Size()
+			if info.ModTime().After(e.modTime) {
+				e.modTime = info.ModTime()
+			}
+
+			return nil
+		})
+	if err != nil {
+		return nil, util.ChildNewtError(err)
+	}
+
+	entries := make([]objCacheEntry, 0, len(m))
+	for _, e := range m {
+		entries = append(entries, *e)
+	}
+
+	return entries, nil
+}
+
+func readObjCacheStats(dir string) ObjCacheInfo {
+	info := ObjCacheInfo{}
+
+	data, err := ioutil.ReadFile(dir + "/" + objCacheStatsFilename)
+	if err == nil {
+		json.Unmarshal(data, &info)
+	}
+
+	return info
+}
+
+func writeObjCacheStats(dir string, info ObjCacheInfo) error {
+	data, err := json.MarshalIndent(struct {
+		Hits   int64 `json:"hits"`
+		Misses int64 `json:"misses"`
+	}{info.Hits, info.Misses}, "", "    ")
+	if err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	if err := ioutil.WriteFile(dir+"/"+objCacheStatsFilename, data,
+		0644); err != nil {
+
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
+
+// ObjCacheStatus reports the location, size, and hit statistics of the object
+// cache.
+func ObjCacheStatus() (ObjCacheInfo, error) {
+	dir, err := ObjCacheDir()
+	if err != nil {
+		return ObjCacheInfo{}, err
+	}
+
+	entries, err := objCacheEntries(dir)
+	if err != nil {
+		return ObjCacheInfo{}, err
+	}
+
+	info := readObjCacheStats(dir)
+	info.Dir = dir
+	info.Enabled = ObjCacheEnabled()
+	info.Entries = len(entries)
+	info.MaxSize = ObjCacheMaxSize()
+	for _, e := range entries {
+		info.Size += e.size
+	}
+
+	return info, nil
+}
+
+// PruneObjCache removes the least recently used entries from the object cache
+// until its size does not exceed maxSize bytes.  A maxSize of 0 empties the
+// cache and resets its statistics.  It returns the number of entries removed
+// and the number of bytes freed.
+func PruneObjCache(maxSize int64) (int, int64, error) {
+	dir, err := ObjCacheDir()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	entries, err := objCacheEntries(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.size
+	}
+
+	sort.Slice(entries, func(i int, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	numRemoved := 0
+	var freed int64
+	for _, e := range entries {
+		if size <= maxSize {
+			break
+		}
+
+		for _, path := range e.paths {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return numRemoved, freed, util.ChildNewtError(err)
+			}
+		}
+
+		size -= e.size
+		freed += e.size
+		numRemoved++
+	}
+
+	if maxSize == 0 {
+		if err := writeObjCacheStats(dir, ObjCacheInfo{}); err != nil {
+			return numRemoved, freed, err
+		}
+	}
+
+	return numRemoved, freed, nil
+}
+
+// FinishObjCache records the hits and misses of the builds performed since
+// the last call and prunes the cache to its size limit.
+func FinishObjCache() error {
+	if !ObjCacheEnabled() {
+		return nil
+	}
+
+	hits := atomic.SwapInt64(&objCacheHits, 0)
+	misses := atomic.SwapInt64(&objCacheMisses, 0)
+	if hits == 0 && misses == 0 {
+		return nil
+	}
+
+	dir, err := ObjCacheDir()
+	if err != nil {
+		return err
+	}
+
+	info := readObjCacheStats(dir)
+	info.Hits += hits
+	info.Misses += misses
+	if err := writeObjCacheStats(dir, info); err != nil {
+		return err
+	}
+
+	_, _, err = PruneObjCache(ObjCacheMaxSize())
+	return err
+}
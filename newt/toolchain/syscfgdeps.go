@@ -0,0 +1,287 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Describes the per-macro files that accompany a generated syscfg.h.  Each
+// file holds the definition of one macro and is only rewritten when that
+// definition changes.
+type SyscfgSymbols struct {
+	// Path of syscfg.h.
+	Header string
+
+	// Directory containing the per-macro files.
+	Dir string
+
+	// Name of the file holding the definitions that every user of syscfg.h
+	// depends on.
+	Common string
+
+	// Name of the file created along with the directory.
+	Stamp string
+}
+
+// Indicates whether the specified path refers to syscfg.h.  Dependency
+// files list paths relative to the project directory.
+func (s *SyscfgSymbols) isHeader(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	return abs == filepath.Clean(s.Header)
+}
+
+// The syscfg macros that a file refers to.
+type syscfgScan struct {
+	modTime time.Time
+	symbols []string
+
+	// False if the file refers to macros in a way that cannot be determined
+	// without preprocessing it, e.g., with token pasting.
+	precise bool
+}
+
+var syscfgRefRe = regexp.MustCompile(
+	`\bMYNEWT_VAL_CHOICE\s*\(([^)]*)\)|\bMYNEWT_VAL\s*\(([^)]*)\)|` +
+		`\b(MYNEWT_(?:VAL|PKG|API)_\w+)`)
+var syscfgPasteRe = regexp.MustCompile(`\bMYNEWT_\w*\s*##`)
+var funcMacroRe = regexp.MustCompile(`^\s*#\s*define\s+\w+\(([^)]*)\)`)
+var identRe = regexp.MustCompile(`^\w+$`)
+
+// [filename] => scan
+var syscfgScans = map[string]*syscfgScan{}
+var syscfgScansMutex sync.Mutex
+
+// SetSyscfgSymbols enables precise syscfg dependency tracking: a change to
+// syscfg.h only causes a source file to be rebuilt if a macro that the file
+// or one of its headers refers to changed.
+func (c *Compiler) SetSyscfgSymbols(s SyscfgSymbols) {
+	c.syscfgSymbols = &s
+}
+
+// Determines which syscfg macros the specified file refers to.
+func scanSyscfgRefs(contents []byte) ([]string, bool) {
+	symbols := map[string]struct{}{}
+
+	// The parameters of the function-like macro being defined, if any.
+	var params map[string]struct{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if params == nil {
+			if m := funcMacroRe.FindStringSubmatch(line); m != nil {
+				params = map[string]struct{}{}
+				for _, p := range strings.Split(m[1], ",") {
+					params[strings.TrimSpace(p)] = struct{}{}
+				}
+			}
+		}
+		contd := strings.HasSuffix(line, "\\")
+
+		if syscfgPasteRe.MatchString(line) {
+			return nil, false
+		}
+
+		for _, m := range syscfgRefRe.FindAllStringSubmatch(line, -1) {
+			switch {
+			case m[1] != "" || m[2] != "":
+				args := strings.Split(m[1]+m[2], ",")
+				for i, a := range args {
+					args[i] = strings.TrimSpace(a)
+					if !identRe.MatchString(args[i]) {
+						return nil, false
+					}
+
+					// The body of a function-like macro passes one of the
+					// macro's parameters to MYNEWT_VAL().
+					if _, ok := params[args[i]]; ok {
+						return nil, false
+					}
+				}
+				if (m[1] != "" && len(args) != 2) ||
+					(m[2] != "" && len(args) != 1) {
+
+					return nil, false
+				}
+
+				symbols["MYNEWT_VAL_"+strings.Join(args, "__")] = struct{}{}
+
+			case m[3] != "MYNEWT_VAL_CHOICE":
+				symbols[m[3]] = struct{}{}
+			}
+		}
+
+		if !contd {
+			params = nil
+		}
+	}
+	if scanner.Err() != nil {
+		return nil, false
+	}
+
+	names := make([]string, 0, len(symbols))
+	for name, _ := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, true
+}
+
+// Retrieves the syscfg macros that the specified file refers to.  Results are
+// cached until the file is modified.
+func syscfgRefs(filename string) ([]string, bool, error) {
+	modTime, err := util.FileModificationTime(filename)
+	if err != nil {
+		return nil, false, err
+	}
+
+	syscfgScansMutex.Lock()
+	scan := syscfgScans[filename]
+	syscfgScansMutex.Unlock()
+
+	if scan == nil || !scan.modTime.Equal(modTime) {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, false, util.ChildNewtError(err)
+		}
+
+		symbols, precise := scanSyscfgRefs(contents)
+		scan = &syscfgScan{
+			modTime: modTime,
+			symbols: symbols,
+			precise: precise,
+		}
+
+		syscfgScansMutex.Lock()
+		syscfgScans[filename] = scan
+		syscfgScansMutex.Unlock()
+	}
+
+	return scan.symbols, scan.precise, nil
+}
+
+// Determines whether a change to syscfg.h requires the specified source file
+// to be rebuilt.  This is the case if the definition of a macro that the file
+// or one of its dependencies refers to, directly or through the definition of
+// another macro, changed since the object file was built.  If the references cannot be determined, any change to syscfg.h
+// requires a rebuild.
+func (tracker *DepTracker) syscfgReason(srcFile string, header string,
+	deps []string, objModTime time.Time) (*RebuildReason, error) {
+
+	s := tracker.compiler.syscfgSymbols
+	headerChanged := &RebuildReason{
+		Code: REBUILD_REASON_DEP,
+		Dep:  header,
+	}
+
+	// The symbol files did not exist when the object file was built.
+	stampModTime, err := util.FileModificationTime(s.Dir + "/" + s.Stamp)
+	if err != nil || stampModTime.After(objModTime) {
+		return headerChanged, nil
+	}
+
+	modTime, err := util.FileModificationTime(s.Dir + "/" + s.Common)
+	if err != nil || modTime.After(objModTime) {
+		return headerChanged, nil
+	}
+
+	symbols := map[string]struct{}{}
+	for _, f := range append([]string{srcFile}, deps...) {
+		if s.isHeader(f) {
+			continue
+		}
+
+		names, precise, err := syscfgRefs(f)
+		if err != nil {
+			return nil, err
+		}
+		if !precise {
+			return headerChanged, nil
+		}
+		for _, name := range names {
+			symbols[name] = struct{}{}
+		}
+	}
+
+	names := make([]string, 0, len(symbols))
+	for name, _ := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// A setting's value can refer to other settings (e.g.,
+	// `#define MYNEWT_VAL_FOO (MYNEWT_VAL(BAR))`), so the references in the
+	// definition of each macro are followed as well.
+	for i := 0; i < len(names); i++ {
+		name := names[i]
+		path := s.Dir + "/" + name
+
+		// A macro without a file has not been defined since the symbol
+		// files were created, i.e., since before the object file was built.
+		if util.NodeNotExist(path) {
+			continue
+		}
+
+		modTime, err := util.FileModificationTime(path)
+		if err != nil {
+			return nil, err
+		}
+		if modTime.After(objModTime) {
+			return &RebuildReason{
+				Code: REBUILD_REASON_SETTING,
+				Dep:  name,
+			}, nil
+		}
+
+		refs, precise, err := syscfgRefs(path)
+		if err != nil {
+			return nil, err
+		}
+		if !precise {
+			return headerChanged, nil
+		}
+		for _, ref := range refs {
+			if _, ok := symbols[ref]; !ok {
+				symbols[ref] = struct{}{}
+				names = append(names, ref)
+			}
+		}
+	}
+
+	return nil, nil
+}
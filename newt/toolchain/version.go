@@ -0,0 +1,184 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mynewt.apache.org/newt/newt/newtutil"
+	"mynewt.apache.org/newt/util"
+)
+
+// A single compiler version constraint (e.g., ">=10.3").
+type VersionConstraint struct {
+	Op  string
+	Ver newtutil.Version
+}
+
+// Matches a version number in `<cc> --version` output.
+var ccVersionRe = regexp.MustCompile(`\b(\d+)\.(\d+)(?:\.(\d+))?\b`)
+
+var versionOps = []string{">=", "<=", "==", ">", "<", "="}
+
+// Parses a version with one to three components; omitted components are 0.
+func parseLooseVersion(s string) (newtutil.Version, error) {
+	v := newtutil.Version{}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, util.FmtNewtError("invalid version: \"%s\"", s)
+	}
+
+	nums := make([]int64, 3)
+	for i, p := range parts {
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return v, util.FmtNewtError("invalid version: \"%s\"", s)
+		}
+		nums[i] = n
+	}
+
+	v.Major = nums[0]
+	v.Minor = nums[1]
+	v.Revision = nums[2]
+
+	return v, nil
+}
+
+// ParseVersionConstraints parses a whitespace-separated list of constraints of
+// the form <op><version>, where <op> is one of >=, <=, ==, >, <, or =.  A
+// version without an operator must match exactly.  All constraints in the list
+// must be satisfied.
+func ParseVersionConstraints(s string) ([]VersionConstraint, error) {
+	var vcs []VersionConstraint
+
+	for _, field := range strings.Fields(s) {
+		vc := VersionConstraint{Op: "=="}
+		for _, op := range versionOps {
+			if strings.HasPrefix(field, op) {
+				vc.Op = op
+				if op == "=" {
+					vc.Op = "=="
+				}
+				field = field[len(op):]
+				break
+			}
+		}
+
+		ver, err := parseLooseVersion(field)
+		if err != nil {
+			return nil, util.FmtNewtError(
+				"invalid compiler version constraint \"%s\": %s",
+				s, err.Error())
+		}
+		vc.Ver = ver
+
+		vcs = append(vcs, vc)
+	}
+
+	return vcs, nil
+}
+
+func (vc *VersionConstraint) Satisfied(v newtutil.Version) bool {
+	cmp := newtutil.VerCmp(v, vc.Ver)
+
+	switch vc.Op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp == 0
+	}
+}
+
+// Extracts the compiler version from the first line of `<cc> --version`
+// output.  The first three-component version is preferred, since toolchain
+// names often contain shorter release numbers (e.g., "(GNU Arm Embedded
+// Toolchain 10.3-2021.10) 10.3.1 20210824").
+func parseCompilerVersion(id string) (newtutil.Version, bool) {
+	matches := ccVersionRe.FindAllStringSubmatch(id, -1)
+	if len(matches) == 0 {
+		return newtutil.Version{}, false
+	}
+
+	best := matches[0]
+	for _, m := range matches {
+		if m[3] != "" {
+			best = m
+			break
+		}
+	}
+
+	v, err := parseLooseVersion(best[0])
+	if err != nil {
+		return newtutil.Version{}, false
+	}
+
+	return v, true
+}
+
+// Verifies that the compiler satisfies the version constraints from
+// compiler.yml (`compiler.version`) and project.yml
+// (`project.compiler_version`).  The compiler is only invoked once per run;
+// see toolchainId().
+func (c *Compiler) checkVersion() error {
+	if len(c.versionReqs) == 0 {
+		return nil
+	}
+
+	id := c.toolchainId()
+	if id == "" {
+		return util.FmtNewtError(
+			"unable to determine the version of compiler %s; is it installed?",
+			c.ccPath)
+	}
+
+	ver, ok := parseCompilerVersion(id)
+	if !ok {
+		return util.FmtNewtError(
+			"unable to determine the version of compiler %s from \"%s\"",
+			c.ccPath, id)
+	}
+
+	for _, req := range c.versionReqs {
+		vcs, err := ParseVersionConstraints(req)
+		if err != nil {
+			return err
+		}
+
+		for _, vc := range vcs {
+			if !vc.Satisfied(ver) {
+				return util.FmtNewtError(
+					"compiler %s version %s does not satisfy the required "+
+						"version \"%s\" (%s)",
+					c.ccPath, ver.String(), req, id)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package toolchain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mynewt.apache.org/newt/util"
+)
+
+// Matches a gcc / clang warning line: "<file>:<line>:<col>: warning: <msg>".
+var warningRe = regexp.MustCompile(`^(.+?):\d+:(?:\d+:)? warning: (.*)$`)
+
+// Extracts the warnings from a compiler's output.  Line and column numbers
+// are discarded so that unrelated edits to a file do not change the set of
+// warnings it produces.  A warning that occurs on several lines of a file is
+// therefore listed once per occurrence.
+func parseWarnings(output string, baseDir string) []string {
+	var warnings []string
+
+	for _, line := range strings.Split(output, "\n") {
+		m := warningRe.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		file := strings.TrimPrefix(filepath.ToSlash(m[1]), baseDir+"/")
+		warnings = append(warnings, file+": "+m[2])
+	}
+
+	return warnings
+}
+
+// Records the warnings generated while compiling the specified object file.
+// The warnings are kept beside the object file so that the complete set of
+// warnings is available even when the file is not rebuilt.
+func writeWarningsFile(objPath string, warnings []string) error {
+	warnPath := objPath + ".warnings"
+
+	if len(warnings) == 0 {
+		if err := os.Remove(warnPath); err != nil && !os.IsNotExist(err) {
+			return util.ChildNewtError(err)
+		}
+		return nil
+	}
+
+	content := strings.Join(warnings, "\n") + "\n"
+	if err := ioutil.WriteFile(warnPath, []byte(content), 0644); err != nil {
+		return util.ChildNewtError(err)
+	}
+
+	return nil
+}
+
+// Warnings returns the sorted list of warnings recorded for every object file
+// this compiler has produced or skipped.  A warning that occurs more than once
+// is listed once per occurrence.
+func (c *Compiler) Warnings() ([]string, error) {
+	c.mutex.Lock()
+	objPaths := make([]string, 0, len(c.objPathList))
+	for objPath, _ := range c.objPathList {
+		objPaths = append(objPaths, objPath)
+	}
+	c.mutex.Unlock()
+
+	var warnings []string
+	for _, objPath := range objPaths {
+		content, err := ioutil.ReadFile(objPath + ".warnings")
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, util.ChildNewtError(err)
+		}
+
+		for _, w := range strings.Split(string(content), "\n") {
+			if w != "" {
+				warnings = append(warnings, w)
+			}
+		}
+	}
+	sort.Strings(warnings)
+
+	return warnings, nil
+}
@@ -0,0 +1,126 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package ycfg
+
+import (
+	"fmt"
+	"sort"
+
+	"mynewt.apache.org/newt/newt/cfgv"
+	"mynewt.apache.org/newt/newt/parse"
+	"mynewt.apache.org/newt/util"
+)
+
+// Number of components in a newt setting name (e.g., "pkg.deps").  Any
+// further components of a key are the conditions under which its value
+// applies.
+const settingNameDepth = 2
+
+// CondEval describes how a single conditional key evaluated.
+type CondEval struct {
+	// The setting that the conditional applies to (e.g., "pkg.deps").
+	Key string
+
+	// The text of the condition.
+	Expr string
+
+	// The file that specified the conditional.
+	FileInfo *util.FileInfo
+
+	// The result of the evaluation; only meaningful if Err is nil.
+	Value bool
+
+	// Settings referenced by the expression that are not defined.
+	Unknown []string
+
+	// Set if the expression could not be parsed or evaluated.
+	Err error
+}
+
+// Returns the location of a node's definition for use in diagnostics.
+func (yc *YCfg) nodeFile(node *YCfgNode) string {
+	if node.FileInfo != nil {
+		return node.FileInfo.Path
+	}
+
+	return yc.name
+}
+
+// exprErrText formats an error for the conditional child of the specified
+// key so that it identifies the file, the key, and the expression.
+func (yc *YCfg) exprErrText(key string, child *YCfgNode, err error) string {
+	return fmt.Sprintf("%s: key \"%s.%s\": %s",
+		yc.nodeFile(child), key, child.Name, err.Error())
+}
+
+// EvalConds evaluates every conditional key in the config against the
+// specified settings.  The results are sorted by key, then by expression.
+func (yc *YCfg) EvalConds(settings *cfgv.Settings) []CondEval {
+	var evals []CondEval
+
+	var iter func(node *YCfgNode, key string, depth int)
+	iter = func(node *YCfgNode, key string, depth int) {
+		for name, child := range node.Children {
+			if depth < settingNameDepth {
+				childKey := name
+				if key != "" {
+					childKey = key + "." + name
+				}
+				iter(child, childKey, depth+1)
+				continue
+			}
+
+			ce := CondEval{
+				Key:      key,
+				Expr:     name,
+				FileInfo: child.FileInfo,
+			}
+
+			expr, err := parse.LexAndParse(name)
+			if err != nil {
+				ce.Err = err
+			} else {
+				for _, ident := range expr.Idents() {
+					if !settings.Exists(ident) {
+						ce.Unknown = append(ce.Unknown, ident)
+					}
+				}
+
+				ce.Value, err = parse.Eval(expr, settings)
+				if err != nil {
+					ce.Err = fmt.Errorf("error evaluating [%s]: %s",
+						name, err.Error())
+				}
+			}
+
+			evals = append(evals, ce)
+		}
+	}
+	iter(&YCfgNode{Children: yc.tree}, "", 0)
+
+	sort.Slice(evals, func(i int, j int) bool {
+		if evals[i].Key != evals[j].Key {
+			return evals[i].Key < evals[j].Key
+		}
+		return evals[i].Expr < evals[j].Expr
+	})
+
+	return evals
+}
@@ -296,14 +296,14 @@ func (yc *YCfg) Get(key string,
 	for _, child := range node.Children {
 		expr, err := parse.LexAndParse(child.Name)
 		if err != nil {
-			errLines = append(errLines,
-				fmt.Sprintf("%s: %s", yc.name, err.Error()))
+			errLines = append(errLines, yc.exprErrText(key, child, err))
 			continue
 		}
 		val, err := parse.Eval(expr, settings)
 		if err != nil {
-			errLines = append(errLines,
-				fmt.Sprintf("%s: %s", yc.name, err.Error()))
+			errLines = append(errLines, yc.exprErrText(key, child,
+				fmt.Errorf("error evaluating [%s]: %s",
+					child.Name, err.Error())))
 			continue
 		}
 		if val {
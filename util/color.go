@@ -0,0 +1,60 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package util
+
+import (
+	"os"
+)
+
+// ANSI SGR codes accepted by ColorText().
+const (
+	COLOR_BOLD   = "1"
+	COLOR_RED    = "31"
+	COLOR_GREEN  = "32"
+	COLOR_YELLOW = "33"
+	COLOR_CYAN   = "36"
+)
+
+// StatusColorEnabled indicates whether status messages can be decorated with
+// ANSI colors.  Colors are only used when status messages are written to a
+// terminal and are not also written to a log file.  Setting the NO_COLOR
+// environment variable disables them.
+func StatusColorEnabled() bool {
+	if logFile != nil || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	fi, err := statusFile.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// ColorText wraps a string in the specified ANSI color code if status
+// messages can be colored.  Otherwise, the string is returned unchanged.
+func ColorText(code string, s string) string {
+	if !StatusColorEnabled() {
+		return s
+	}
+
+	return "\033[" + code + "m" + s + "\033[0m"
+}
@@ -0,0 +1,75 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Paths of the generated files that have been written, or found to be up to
+// date, since the last call to ResetGeneratedFiles().
+var generatedFiles = map[string]struct{}{}
+
+// WriteGeneratedFile writes a generated file if its contents differ from the
+// specified ones.  An up to date file is left untouched so that its
+// modification time does not cause the files that depend on it to be
+// rebuilt.  Either way, the path is recorded as a generated file.
+func WriteGeneratedFile(path string, contents []byte) error {
+	path = filepath.ToSlash(filepath.Clean(path))
+	generatedFiles[path] = struct{}{}
+
+	writeReqd, err := FileContentsChanged(path, contents)
+	if err != nil {
+		return err
+	}
+	if !writeReqd {
+		log.Debugf("generated file unchanged; not writing (%s).", path)
+		return nil
+	}
+
+	log.Debugf("generated file changed; writing (%s).", path)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return NewNewtError(err.Error())
+	}
+
+	if err := ioutil.WriteFile(path, contents, 0644); err != nil {
+		return NewNewtError(err.Error())
+	}
+
+	return nil
+}
+
+// IsGeneratedFile indicates whether a file was recorded as generated since
+// the last call to ResetGeneratedFiles().
+func IsGeneratedFile(path string) bool {
+	_, ok := generatedFiles[filepath.ToSlash(filepath.Clean(path))]
+	return ok
+}
+
+// ResetGeneratedFiles forgets the recorded generated files.  It is called
+// before a target's code is generated.
+func ResetGeneratedFiles() {
+	generatedFiles = map[string]struct{}{}
+}
@@ -0,0 +1,147 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package util
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Child processes that are currently running.  They are killed if newt is
+// interrupted.
+var runningCmds = map[*exec.Cmd]struct{}{}
+
+// Files that are currently being written.  They are removed if newt is
+// interrupted.
+var partialOutputs = map[string]struct{}{}
+
+// Protects the above.  An interrupt handler holds this mutex until newt exits,
+// so no new process can be started while the handler cleans up.
+var interruptMtx sync.Mutex
+
+// TrapInterrupts installs a handler for SIGINT and SIGTERM.  When one of these
+// signals is received, the handler kills all of the child processes that newt
+// is running, removes all files registered with TrackPartialOutput, and exits.
+// Call the returned function to remove the handler.
+func TrapInterrupts() func() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-sigs:
+			handleInterrupt(sig)
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
+
+func handleInterrupt(sig os.Signal) {
+	interruptMtx.Lock()
+
+	ErrorMessage(VERBOSITY_QUIET, "\nInterrupted (%s); stopping\n", sig)
+
+	for cmd, _ := range runningCmds {
+		cmd.Process.Kill()
+	}
+
+	for path, _ := range partialOutputs {
+		if err := os.Remove(path); err == nil {
+			ErrorMessage(VERBOSITY_VERBOSE, "Removed partial output %s\n",
+				path)
+		}
+	}
+
+	if sig == os.Interrupt {
+		os.Exit(130)
+	} else {
+		os.Exit(143)
+	}
+}
+
+// TrackPartialOutput records that a file is about to be written.  If newt is
+// interrupted before the returned function is called, the file is removed so
+// that a later build does not mistake it for a complete output.
+func TrackPartialOutput(path string) func() {
+	interruptMtx.Lock()
+	partialOutputs[path] = struct{}{}
+	interruptMtx.Unlock()
+
+	return func() {
+		interruptMtx.Lock()
+		delete(partialOutputs, path)
+		interruptMtx.Unlock()
+	}
+}
+
+// Starts a child process and registers it so that it is killed if newt is
+// interrupted.
+func startCmd(cmd *exec.Cmd) error {
+	interruptMtx.Lock()
+	defer interruptMtx.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	runningCmds[cmd] = struct{}{}
+
+	return nil
+}
+
+// Waits for a child process started with startCmd to complete.
+func waitCmd(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+
+	interruptMtx.Lock()
+	delete(runningCmds, cmd)
+	interruptMtx.Unlock()
+
+	return err
+}
+
+// Equivalent to cmd.Run(), but the process is killed if newt is interrupted.
+func runCmd(cmd *exec.Cmd) error {
+	if err := startCmd(cmd); err != nil {
+		return err
+	}
+
+	return waitCmd(cmd)
+}
+
+// Equivalent to cmd.CombinedOutput(), but the process is killed if newt is
+// interrupted.
+func runCmdCombinedOutput(cmd *exec.Cmd) ([]byte, error) {
+	var b bytes.Buffer
+	cmd.Stdout = &b
+	cmd.Stderr = &b
+
+	err := runCmd(cmd)
+	return b.Bytes(), err
+}
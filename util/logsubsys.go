@@ -0,0 +1,118 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package util
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Subsystems whose log levels can be configured independently with `--log`.
+const (
+	LOG_SUBSYS_RESOLVER   = "resolver"
+	LOG_SUBSYS_SYSCFG     = "syscfg"
+	LOG_SUBSYS_DOWNLOADER = "downloader"
+	LOG_SUBSYS_COMPILER   = "compiler"
+)
+
+var LogSubsystems = []string{
+	LOG_SUBSYS_RESOLVER,
+	LOG_SUBSYS_SYSCFG,
+	LOG_SUBSYS_DOWNLOADER,
+	LOG_SUBSYS_COMPILER,
+}
+
+// [subsystem-name] => logger
+var subsysLoggers = map[string]*log.Logger{}
+
+// Returns the logger for the specified subsystem.  Unless overridden with
+// InitSubsysLog(), a subsystem logger uses the global log level.
+func SubsysLogger(name string) *log.Logger {
+	l := subsysLoggers[name]
+	if l == nil {
+		l = log.New()
+		l.Formatter = &logFormatter{}
+		l.Level = log.GetLevel()
+		subsysLoggers[name] = l
+	}
+
+	return l
+}
+
+// Applies the global log configuration to every subsystem logger.
+func configSubsysLoggers(level log.Level, writer io.Writer) {
+	for _, l := range subsysLoggers {
+		l.Out = writer
+		l.Formatter = &logFormatter{}
+		l.SetLevel(level)
+	}
+}
+
+// Overrides the log levels of individual subsystems.  The spec is a
+// comma-separated list of `subsystem=level` pairs (e.g.,
+// "resolver=debug,downloader=warn").
+func InitSubsysLog(spec string) error {
+	if spec == "" {
+		return nil
+	}
+
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return FmtNewtError(
+				"invalid log setting \"%s\"; must have form "+
+					"<subsystem>=<level>", field)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		found := false
+		for _, s := range LogSubsystems {
+			if s == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := append([]string{}, LogSubsystems...)
+			sort.Strings(names)
+			return FmtNewtError(
+				"invalid log subsystem \"%s\"; must be one of: %s",
+				name, strings.Join(names, ", "))
+		}
+
+		level, err := log.ParseLevel(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return FmtNewtError("invalid log level for subsystem \"%s\": %s",
+				name, err.Error())
+		}
+
+		SubsysLogger(name).SetLevel(level)
+	}
+
+	return nil
+}
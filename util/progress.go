@@ -0,0 +1,92 @@
+/**
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package util
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// A machine-readable progress event.  When enabled with --progress-json,
+// each event is written as a single line of JSON.
+type ProgressEvent struct {
+	// The operation being performed (e.g., "build", "install", "upgrade").
+	Op string `json:"op"`
+
+	// The stage of the operation (e.g., "compile", "link", "done").
+	Phase string `json:"phase"`
+
+	Package string `json:"package,omitempty"`
+	File    string `json:"file,omitempty"`
+
+	// Approximate completion of the operation, 0-100.
+	Percent int `json:"percent"`
+}
+
+var progressFile *os.File
+var progressMtx sync.Mutex
+
+// Configures the destination of progress events.  An empty string disables
+// progress events; "-" writes them to stdout, in which case status messages
+// are redirected to stderr.  Any other value is the name of a file to create.
+func InitProgress(dst string) error {
+	switch dst {
+	case "":
+		progressFile = nil
+
+	case "-":
+		progressFile = os.Stdout
+		statusFile = os.Stderr
+
+	default:
+		f, err := os.Create(dst)
+		if err != nil {
+			return ChildNewtError(err)
+		}
+		progressFile = f
+	}
+
+	return nil
+}
+
+// Indicates whether progress events are being written.
+func ProgressEnabled() bool {
+	return progressFile != nil
+}
+
+// Writes a progress event.  This is a no-op unless progress events were
+// enabled with InitProgress().
+func Progress(ev ProgressEvent) {
+	if progressFile == nil {
+		return
+	}
+
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+
+	progressMtx.Lock()
+	defer progressMtx.Unlock()
+
+	progressFile.Write(b)
+}
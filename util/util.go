@@ -35,6 +35,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -50,8 +51,10 @@ var ExecuteShell bool
 var EscapeShellCmds bool
 var ShallowCloneDepth int
 var logFile *os.File
+var statusFile *os.File = os.Stdout
 var SkipNewtCompat bool
 var SkipSyscfgRepoHash bool
+var SyscfgProvenance bool
 var HideLoadCmdOutput bool
 
 func ParseEqualsPair(v string) (string, string, error) {
@@ -149,9 +152,11 @@ func WriteMessage(f *os.File, level int, message string,
 	}
 }
 
-// Print Silent, Quiet and Verbose aware status messages to stdout.
+// Print Silent, Quiet and Verbose aware status messages to stdout.  If
+// progress events are written to stdout, status messages go to stderr
+// instead.
 func StatusMessage(level int, message string, args ...interface{}) {
-	WriteMessage(os.Stdout, level, message, args...)
+	WriteMessage(statusFile, level, message, args...)
 }
 
 // Print Silent, Quiet and Verbose aware status messages to stderr.
@@ -257,6 +262,7 @@ func initLog(level log.Level, logFilename string) error {
 
 	log.SetOutput(writer)
 	log.SetFormatter(&logFormatter{})
+	configSubsysLoggers(level, writer)
 
 	return nil
 }
@@ -430,7 +436,7 @@ func ShellCommandStreamOutput(
 		cmd.Stderr = os.Stderr
 	}
 
-	return cmd.Run()
+	return runCmd(cmd)
 }
 
 // Execute the specified process and block until it completes.  Additionally,
@@ -464,7 +470,7 @@ func ShellCommandLimitDbgOutput(
 		LogShellCmd(cmdStrs, env)
 	}
 
-	o, err := cmd.CombinedOutput()
+	o, err := runCmdCombinedOutput(cmd)
 
 	if maxDbgOutputChrs < 0 || len(o) <= maxDbgOutputChrs {
 		dbgStr := string(o)
@@ -926,9 +932,13 @@ func FileContains(contents []byte, path string) (bool, error) {
 // Keeps track of warnings that have already been reported.
 // [warning-text] => struct{}
 var warnings = map[string]struct{}{}
+var warningsMtx sync.Mutex
 
 // Displays the specified warning if it has not been displayed yet.
 func OneTimeWarning(text string, args ...interface{}) {
+	warningsMtx.Lock()
+	defer warningsMtx.Unlock()
+
 	body := fmt.Sprintf(text, args...)
 	if _, ok := warnings[body]; !ok {
 		warnings[body] = struct{}{}